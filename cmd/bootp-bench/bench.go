@@ -0,0 +1,400 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/go-bootp/internal/server"
+)
+
+// maxDeclines сколько раз клиент отклоняет предложения, прежде чем принять
+const maxDeclines = 3
+
+// pxeVendorClass класс производителя PXE клиента BIOS (опция 60)
+const pxeVendorClass = "PXEClient:Arch:00000:UNDI:002001"
+
+// pxeParameters список запрошенных параметров PXE клиента (опция 55)
+var pxeParameters = []byte{1, 3, 6, 12, 15, 28, 43, 60, 66, 67, 93, 94, 97}
+
+// benchConfig параметры нагрузки
+type benchConfig struct {
+	server    *net.UDPAddr
+	giaddr    net.IP // Адрес ретранслятора в запросах (nil - без ретранслятора)
+	clients   int
+	rate      float64       // Клиентов в секунду (0 - все сразу)
+	timeout   time.Duration // Первое ожидание ответа, удваивается при повторе
+	retries   int
+	decline   float64 // Доля отклоняемых предложений
+	loss      float64 // Доля отбрасываемых ответов
+	macPrefix [3]byte
+	release   bool // Освобождать адреса после теста
+}
+
+// validate проверяет параметры нагрузки
+func (c *benchConfig) validate() error {
+	switch {
+	case c.clients <= 0 || c.clients > 1<<24:
+		return fmt.Errorf("--clients must be 1-%d", 1<<24)
+	case c.rate < 0:
+		return errors.New("--rate must not be negative")
+	case c.timeout <= 0:
+		return errors.New("--timeout must be positive")
+	case c.retries < 0:
+		return errors.New("--retries must not be negative")
+	case c.decline < 0 || c.decline > 1:
+		return errors.New("--decline must be 0-1")
+	case c.loss < 0 || c.loss >= 1:
+		return errors.New("--loss must be at least 0 and below 1")
+	}
+	return nil
+}
+
+// benchResult итоги теста
+type benchResult struct {
+	Clients   int     `json:"clients"`
+	Bound     int     `json:"bound"`      // Клиентов, получивших DHCPACK
+	Failed    int     `json:"failed"`     // Клиентов, не получивших адрес
+	ErrorRate float64 `json:"error_rate"` // Доля клиентов без адреса, %
+
+	Duration     time.Duration `json:"duration"`
+	OffersPerSec float64       `json:"offers_per_sec"`
+	AcksPerSec   float64       `json:"acks_per_sec"`
+
+	Discovers   uint64 `json:"discovers"`
+	Requests    uint64 `json:"requests"`
+	Declines    uint64 `json:"declines"`
+	Releases    uint64 `json:"releases"`
+	Retransmits uint64 `json:"retransmits"`
+	Offers      uint64 `json:"offers"`
+	Acks        uint64 `json:"acks"`
+	Naks        uint64 `json:"naks"`
+	Lost        uint64 `json:"lost"` // Ответов, отброшенных по --loss
+
+	// Время от первого DHCPDISCOVER до DHCPACK
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP95 time.Duration `json:"latency_p95"`
+	LatencyP99 time.Duration `json:"latency_p99"`
+	LatencyMax time.Duration `json:"latency_max"`
+}
+
+// print выводит итоги в текстовом виде
+func (r *benchResult) print(out io.Writer) {
+	fmt.Fprintf(out, "Clients: %d (bound %d, failed %d, error rate %.1f%%)\n", r.Clients, r.Bound, r.Failed, r.ErrorRate)
+	fmt.Fprintf(out, "Duration: %v, offers/s %.1f, acks/s %.1f\n", r.Duration.Round(time.Millisecond), r.OffersPerSec, r.AcksPerSec)
+	fmt.Fprintf(out, "Sent: discovers %d, requests %d, declines %d, releases %d, retransmits %d\n",
+		r.Discovers, r.Requests, r.Declines, r.Releases, r.Retransmits)
+	fmt.Fprintf(out, "Received: offers %d, acks %d, naks %d, dropped by --loss %d\n", r.Offers, r.Acks, r.Naks, r.Lost)
+	if r.Bound > 0 {
+		fmt.Fprintf(out, "Latency (DISCOVER to ACK): p50 %v, p95 %v, p99 %v, max %v\n",
+			r.LatencyP50.Round(time.Microsecond), r.LatencyP95.Round(time.Microsecond),
+			r.LatencyP99.Round(time.Microsecond), r.LatencyMax.Round(time.Microsecond))
+	}
+}
+
+// bench эмулирует клиентов через один сокет: ответы сопоставляются с
+// ожидающими транзакциями по xid
+type bench struct {
+	conn *net.UDPConn
+	cfg  benchConfig
+
+	mutex   sync.Mutex
+	pending map[uint32]chan *server.Packet // Ожидающие транзакции по xid
+	random  *rand.Rand
+	xid     uint32
+
+	discovers, requests, declines, releases, retransmits atomic.Uint64
+	offers, acks, naks, lost                             atomic.Uint64
+}
+
+func newBench(conn *net.UDPConn, cfg benchConfig) *bench {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &bench{
+		conn:    conn,
+		cfg:     cfg,
+		pending: make(map[uint32]chan *server.Packet),
+		random:  random,
+		xid:     random.Uint32(),
+	}
+}
+
+// run запускает клиентов, дожидается их завершения и возвращает итоги
+func (b *bench) run() *benchResult {
+	go b.receive()
+
+	latencies := make([]time.Duration, b.cfg.clients)
+	bound := make([]bool, b.cfg.clients)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < b.cfg.clients; i++ {
+		if b.cfg.rate > 0 && i > 0 {
+			time.Sleep(time.Duration(float64(time.Second) / b.cfg.rate))
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			latencies[i], bound[i] = b.client(i)
+		}(i)
+	}
+	wg.Wait()
+	duration := time.Since(start)
+
+	var observed []time.Duration
+	for i, ok := range bound {
+		if ok {
+			observed = append(observed, latencies[i])
+		}
+	}
+	return b.result(duration, observed)
+}
+
+// result собирает итоги по счетчикам и задержкам привязанных клиентов
+func (b *bench) result(duration time.Duration, latencies []time.Duration) *benchResult {
+	r := &benchResult{
+		Clients:     b.cfg.clients,
+		Bound:       len(latencies),
+		Failed:      b.cfg.clients - len(latencies),
+		Duration:    duration,
+		Discovers:   b.discovers.Load(),
+		Requests:    b.requests.Load(),
+		Declines:    b.declines.Load(),
+		Releases:    b.releases.Load(),
+		Retransmits: b.retransmits.Load(),
+		Offers:      b.offers.Load(),
+		Acks:        b.acks.Load(),
+		Naks:        b.naks.Load(),
+		Lost:        b.lost.Load(),
+	}
+	r.ErrorRate = float64(r.Failed) * 100 / float64(r.Clients)
+	if seconds := duration.Seconds(); seconds > 0 {
+		r.OffersPerSec = float64(r.Offers) / seconds
+		r.AcksPerSec = float64(r.Acks) / seconds
+	}
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		percentile := func(p int) time.Duration {
+			return latencies[(len(latencies)-1)*p/100]
+		}
+		r.LatencyP50, r.LatencyP95, r.LatencyP99 = percentile(50), percentile(95), percentile(99)
+		r.LatencyMax = latencies[len(latencies)-1]
+	}
+	return r
+}
+
+// client проходит DISCOVER-OFFER-REQUEST-ACK для клиента с номером index и
+// возвращает время до получения адреса
+func (b *bench) client(index int) (time.Duration, bool) {
+	mac := b.mac(index)
+	start := time.Now()
+	declined := 0
+	for attempt := 0; attempt <= maxDeclines+b.cfg.retries; attempt++ {
+		offer := b.transact(mac, start, server.DHCPDiscover, nil, nil)
+		if offer == nil {
+			return 0, false
+		}
+		yiaddr := net.IP(offer.Header.Yiaddr[:])
+		serverID := offer.Options[server.OptionServerIdentifier]
+
+		if declined < maxDeclines && b.chance(b.cfg.decline) {
+			declined++
+			b.send(mac, b.nextXid(), start, server.DHCPDecline, nil, yiaddr, serverID)
+			continue
+		}
+
+		ack := b.transact(mac, start, server.DHCPRequest, yiaddr, serverID)
+		if ack == nil {
+			return 0, false
+		}
+		if ack.Options[server.OptionMessageType][0] == server.DHCPNak {
+			continue
+		}
+		elapsed := time.Since(start)
+		if b.cfg.release {
+			b.send(mac, b.nextXid(), start, server.DHCPRelease, yiaddr, nil, serverID)
+		}
+		return elapsed, true
+	}
+	return 0, false
+}
+
+// transact отправляет запрос и ждет ответ, повторяя запрос с удвоенным
+// ожиданием. Для DHCPDISCOVER ожидается DHCPOFFER, для DHCPREQUEST -
+// DHCPACK или DHCPNAK. Возвращает nil, если ответа нет.
+func (b *bench) transact(mac net.HardwareAddr, start time.Time, msgType uint8, requested net.IP, serverID []byte) *server.Packet {
+	xid := b.nextXid()
+	replies := make(chan *server.Packet, 4)
+	b.mutex.Lock()
+	b.pending[xid] = replies
+	b.mutex.Unlock()
+	defer func() {
+		b.mutex.Lock()
+		delete(b.pending, xid)
+		b.mutex.Unlock()
+	}()
+
+	timeout := b.cfg.timeout
+	for retry := 0; retry <= b.cfg.retries; retry++ {
+		if retry > 0 {
+			b.retransmits.Add(1)
+		}
+		b.send(mac, xid, start, msgType, nil, requested, serverID)
+
+		timer := time.NewTimer(timeout)
+		for waiting := true; waiting; {
+			select {
+			case reply := <-replies:
+				if expected(msgType, reply) && net.HardwareAddr(reply.Header.Chaddr[:6]).String() == mac.String() {
+					timer.Stop()
+					return reply
+				}
+			case <-timer.C:
+				waiting = false
+			}
+		}
+		timeout *= 2
+	}
+	return nil
+}
+
+// expected возвращает true, если ответ подходит к типу запроса
+func expected(msgType uint8, reply *server.Packet) bool {
+	replyType := reply.Options[server.OptionMessageType]
+	if len(replyType) != 1 {
+		return false
+	}
+	if msgType == server.DHCPDiscover {
+		return replyType[0] == server.DHCPOffer
+	}
+	return replyType[0] == server.DHCPAck || replyType[0] == server.DHCPNak
+}
+
+// send отправляет запрос клиента. ciaddr задается для DHCPRELEASE,
+// requested - для DHCPREQUEST и DHCPDECLINE. Поле secs отсчитывается от
+// начала обмена, как у настоящего клиента.
+func (b *bench) send(mac net.HardwareAddr, xid uint32, start time.Time, msgType uint8, ciaddr, requested net.IP, serverID []byte) {
+	header := server.BOOTPHeader{
+		Op:    server.BOOTPRequest,
+		Htype: server.HTYPE_ETHER,
+		Hlen:  6,
+		Xid:   xid,
+		Secs:  uint16(time.Since(start) / time.Second),
+		Magic: [4]byte{99, 130, 83, 99}, // DHCP magic cookie
+	}
+	copy(header.Chaddr[:], mac)
+	copy(header.Ciaddr[:], ciaddr.To4())
+	copy(header.Giaddr[:], b.cfg.giaddr)
+	if b.cfg.giaddr != nil {
+		header.Hops = 1
+	}
+
+	// Клиентский UUID (опция 97) выводится из MAC адреса
+	uuid := make([]byte, 17)
+	copy(uuid[11:], mac)
+	options := map[uint8][]byte{
+		server.OptionMessageType:    {msgType},
+		server.OptionParameterList:  pxeParameters,
+		server.OptionMaxMessageSize: {0x05, 0xc0},
+		server.OptionVendorClass:    []byte(pxeVendorClass),
+		server.OptionClientArch:     {0, 0},
+		97:                          uuid,
+	}
+	if requested != nil {
+		options[server.OptionRequestedIP] = requested.To4()
+	}
+	if serverID != nil {
+		options[server.OptionServerIdentifier] = serverID
+	}
+
+	switch msgType {
+	case server.DHCPDiscover:
+		b.discovers.Add(1)
+	case server.DHCPRequest:
+		b.requests.Add(1)
+	case server.DHCPDecline:
+		b.declines.Add(1)
+	case server.DHCPRelease:
+		b.releases.Add(1)
+	}
+	data := server.EncodeReply(&header, options)
+	b.conn.WriteToUDP(data, b.cfg.server)
+}
+
+// receive передает ответы сервера ожидающим транзакциям до закрытия сокета
+func (b *bench) receive() {
+	buffer := make([]byte, 1500)
+	for {
+		n, err := b.conn.Read(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		// Опции ответа ссылаются на разобранные данные, а буфер
+		// перезаписывается следующим ответом
+		reply, err := server.DecodePacket(append([]byte(nil), buffer[:n]...))
+		if err != nil || reply.Header.Op != server.BOOTPReply {
+			continue
+		}
+
+		// Ответы на DHCPDECLINE и DHCPRELEASE и повторные ответы на
+		// завершенные транзакции не учитываются
+		b.mutex.Lock()
+		replies := b.pending[reply.Header.Xid]
+		b.mutex.Unlock()
+		if replies == nil {
+			continue
+		}
+
+		switch messageType := reply.Options[server.OptionMessageType]; {
+		case len(messageType) != 1:
+		case messageType[0] == server.DHCPOffer:
+			b.offers.Add(1)
+		case messageType[0] == server.DHCPAck:
+			b.acks.Add(1)
+		case messageType[0] == server.DHCPNak:
+			b.naks.Add(1)
+		}
+		if b.chance(b.cfg.loss) {
+			b.lost.Add(1)
+			continue
+		}
+		select {
+		case replies <- reply:
+		default:
+		}
+	}
+}
+
+// mac возвращает MAC адрес клиента с номером index
+func (b *bench) mac(index int) net.HardwareAddr {
+	p := b.cfg.macPrefix
+	return net.HardwareAddr{p[0], p[1], p[2], byte(index >> 16), byte(index >> 8), byte(index)}
+}
+
+// nextXid возвращает идентификатор новой транзакции
+func (b *bench) nextXid() uint32 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.xid++
+	return b.xid
+}
+
+// chance возвращает true с вероятностью p
+func (b *bench) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.random.Float64() < p
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// startTestServer запускает сервер на свободном порту 127.0.0.1
+func startTestServer(t *testing.T) *server.BOOTPServer {
+	srv, err := server.NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}}},
+		},
+	}, server.WithListenAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+func runBench(t *testing.T, args ...string) string {
+	t.Helper()
+
+	var output bytes.Buffer
+	cmd := newRootCommand()
+	cmd.SetOut(&output)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("bootp-bench %v: %v", args, err)
+	}
+	return output.String()
+}
+
+func TestBench(t *testing.T) {
+	srv := startTestServer(t)
+	addr := srv.LocalAddr().String()
+
+	// Отклоненные предложения и потерянные ответы повторяются
+	output := runBench(t, "--server", addr, "--giaddr", "192.168.1.1", "--clients", "20",
+		"--decline", "0.2", "--loss", "0.1", "--timeout", "50ms", "--retries", "6", "--json")
+	var result benchResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Bound != 20 || result.Failed != 0 || result.ErrorRate != 0 {
+		t.Fatalf("Expected all clients bound, got %+v", result)
+	}
+	if result.Offers < 20 || result.Acks < 20 || result.Requests < 20 || result.Discovers < 20+result.Declines {
+		t.Errorf("Unexpected counters %+v", result)
+	}
+	if result.LatencyMax < result.LatencyP50 || result.LatencyP50 <= 0 {
+		t.Errorf("Unexpected latencies %+v", result)
+	}
+	if leases := srv.Leases(); len(leases) != 20 {
+		t.Errorf("Expected 20 leases, got %d", len(leases))
+	}
+
+	// Те же клиенты получают прежние адреса и освобождают их
+	output = runBench(t, "--server", addr, "--giaddr", "192.168.1.1", "--clients", "5", "--release")
+	if !strings.Contains(output, "bound 5, failed 0") || !strings.Contains(output, "releases 5") {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+}
+
+func TestBenchInvalidFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"--clients", "0"},
+		{"--loss", "1"},
+		{"--decline", "-0.1"},
+		{"--giaddr", "relay"},
+		{"--mac-prefix", "02:42"},
+		{"--server", "127.0.0.1:port"},
+	} {
+		cmd := newRootCommand()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// newRootCommand создает команду нагрузочного теста
+func newRootCommand() *cobra.Command {
+	var target, giaddr, bind, macPrefix string
+	var asJSON bool
+	cfg := benchConfig{}
+
+	cmd := &cobra.Command{
+		Use:           "bootp-bench",
+		Short:         "Emulate concurrent PXE clients against a DHCP server and report throughput",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if cfg.server, err = parseTarget(target); err != nil {
+				return err
+			}
+			if giaddr != "" {
+				if cfg.giaddr = net.ParseIP(giaddr).To4(); cfg.giaddr == nil {
+					return fmt.Errorf("invalid --giaddr %q", giaddr)
+				}
+			}
+			if cfg.macPrefix, err = parseMACPrefix(macPrefix); err != nil {
+				return err
+			}
+			if err := cfg.validate(); err != nil {
+				return err
+			}
+
+			local, err := net.ResolveUDPAddr("udp4", bind)
+			if err != nil {
+				return fmt.Errorf("invalid --bind %q: %v", bind, err)
+			}
+			conn, err := net.ListenUDP("udp4", local)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			result := newBench(conn, cfg).run()
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(result)
+			}
+			result.print(out)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&target, "server", "s", "127.0.0.1", "server address, host[:port]")
+	flags.StringVar(&giaddr, "giaddr", "", "relay address to put in requests, selects the subnet on the server")
+	flags.StringVar(&bind, "bind", ":0", "local address to send from and receive replies on")
+	flags.IntVarP(&cfg.clients, "clients", "n", 100, "number of emulated clients")
+	flags.Float64Var(&cfg.rate, "rate", 0, "clients started per second (0 - all at once)")
+	flags.DurationVar(&cfg.timeout, "timeout", time.Second, "initial reply timeout, doubled on every retransmission")
+	flags.IntVar(&cfg.retries, "retries", 3, "retransmissions before a client gives up")
+	flags.Float64Var(&cfg.decline, "decline", 0, "share of offers declined with DHCPDECLINE (0-1)")
+	flags.Float64Var(&cfg.loss, "loss", 0, "share of replies dropped to force retransmissions (0-1)")
+	flags.StringVar(&macPrefix, "mac-prefix", "02:42:be", "first three bytes of client MAC addresses")
+	flags.BoolVar(&cfg.release, "release", false, "release addresses after the test")
+	flags.BoolVar(&asJSON, "json", false, "print results as JSON")
+
+	return cmd
+}
+
+// parseTarget разбирает адрес сервера, по умолчанию порт 67
+func parseTarget(target string) (*net.UDPAddr, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, strconv.Itoa(server.BOOTP_PORT))
+	}
+	addr, err := net.ResolveUDPAddr("udp4", target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --server %q: %v", target, err)
+	}
+	return addr, nil
+}
+
+// parseMACPrefix разбирает три первых байта MAC адресов клиентов
+func parseMACPrefix(prefix string) ([3]byte, error) {
+	var result [3]byte
+	parts := strings.Split(prefix, ":")
+	if len(parts) != len(result) {
+		return result, fmt.Errorf("invalid --mac-prefix %q, expected three bytes like 02:42:be", prefix)
+	}
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return result, fmt.Errorf("invalid --mac-prefix %q, expected three bytes like 02:42:be", prefix)
+		}
+		result[i] = byte(value)
+	}
+	return result, nil
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
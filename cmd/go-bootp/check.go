@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/config"
+)
+
+// checkResult результат проверки конфигурации в формате JSON
+type checkResult struct {
+	Subnets  int              `json:"subnets"`
+	Hosts    int              `json:"hosts"`
+	Warnings []config.Warning `json:"warnings"`
+}
+
+// newCheckCommand проверяет конфигурацию без запуска сервера
+func newCheckCommand() *cobra.Command {
+	var configPath string
+	var set []string
+	var asJSON bool
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Validate configuration and exit",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := optionOverrides(set)
+			if err != nil {
+				return err
+			}
+			source := configSource{path: configPath, overrides: overrides}
+			cfg, err := loadConfig(source)
+			if err != nil {
+				return err
+			}
+			if _, _, err := loadInstanceConfigs(cfg, source); err != nil {
+				return err
+			}
+			warnings, err := config.Lint(cfg)
+			if err != nil {
+				return err
+			}
+
+			result := checkResult{Subnets: len(cfg.Subnets), Hosts: len(cfg.Hosts), Warnings: warnings}
+			for _, subnet := range cfg.Subnets {
+				result.Hosts += len(subnet.Hosts)
+			}
+			if result.Warnings == nil {
+				result.Warnings = []config.Warning{}
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				if err := encoder.Encode(result); err != nil {
+					return err
+				}
+			} else {
+				for _, warning := range warnings {
+					fmt.Fprintf(out, "warning: %s [%s]\n", warning, warning.Check)
+				}
+				fmt.Fprintf(out, "Configuration OK: %d subnets, %d hosts, %d warnings\n", result.Subnets, result.Hosts, len(warnings))
+			}
+
+			if strict && len(warnings) > 0 {
+				return fmt.Errorf("configuration has %d warnings", len(warnings))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", os.Getenv(envConfig), "path to dhcpd.conf (default /etc/dhcp/dhcpd.conf or configs/dhcpd.conf)")
+	cmd.Flags().StringArrayVar(&set, "set", nil, "override a global option, name=value (repeatable)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print result and warnings as JSON")
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail if there are warnings")
+
+	return cmd
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/control"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Параметры и результаты методов управляющего сокета
+type releaseParams struct {
+	Address string `json:"address"` // IP или MAC адрес
+}
+
+type historyParams struct {
+	Address string    `json:"address,omitempty"` // IP или MAC адрес, пусто - все назначения
+	At      time.Time `json:"at,omitempty"`      // Назначения, действовавшие в этот момент
+	Limit   int       `json:"limit,omitempty"`
+}
+
+type logLevelParams struct {
+	Level string `json:"level,omitempty"` // Пусто - только получить текущий уровень
+}
+
+type logLevelResult struct {
+	Level string `json:"level"`
+}
+
+type drainParams struct {
+	Enabled *bool `json:"enabled,omitempty"` // По умолчанию включает режим
+}
+
+type drainResult struct {
+	Draining bool `json:"draining"`
+}
+
+type reloadResult struct {
+	Subnets int `json:"subnets"`
+	Leases  int `json:"leases"`
+}
+
+type captureParams struct {
+	File    string `json:"file,omitempty"`     // Файл pcap
+	HexDump bool   `json:"hexdump,omitempty"`  // Вывод в журнал вместо файла
+	MaxSize int    `json:"max_size,omitempty"` // Размер файла в МБ (0 - по умолчанию)
+	Files   *int   `json:"files,omitempty"`    // Количество старых файлов
+}
+
+type reservationParams struct {
+	Name string `json:"name"`
+}
+
+// registerControlMethods регистрирует методы управляющего сокета
+func registerControlMethods(ctl *control.Server, srv *server.BOOTPServer, source configSource, instances []*instance) {
+	ctl.Handle("leases.list", func(params json.RawMessage) (interface{}, error) {
+		return srv.Leases(), nil
+	})
+
+	ctl.Handle("leases.release", func(params json.RawMessage) (interface{}, error) {
+		var p releaseParams
+		if err := json.Unmarshal(params, &p); err != nil || p.Address == "" {
+			return nil, control.InvalidParams("expected {\"address\": \"<ip or mac>\"}")
+		}
+		return srv.ReleaseLease(p.Address)
+	})
+
+	ctl.Handle("leases.history", func(params json.RawMessage) (interface{}, error) {
+		var p historyParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil || p.Limit < 0 {
+				return nil, control.InvalidParams("expected {\"address\": \"<ip or mac>\", \"at\": \"<RFC 3339 time>\", \"limit\": <n>}")
+			}
+		}
+		query := server.HistoryQuery{At: p.At, Limit: p.Limit}
+		if p.Address != "" {
+			if ip := net.ParseIP(p.Address).To4(); ip != nil {
+				query.IP = ip.String()
+			} else if mac, err := config.NormalizeMAC(p.Address); err == nil {
+				query.MAC = mac
+			} else {
+				return nil, control.InvalidParams("invalid address %q, expected IPv4 or MAC address", p.Address)
+			}
+		}
+		return srv.LeaseHistory(query)
+	})
+
+	ctl.Handle("server.stats", func(params json.RawMessage) (interface{}, error) {
+		return srv.Stats(), nil
+	})
+
+	ctl.Handle("conflicts.list", func(params json.RawMessage) (interface{}, error) {
+		return srv.Conflicts(), nil
+	})
+
+	ctl.Handle("inventory.list", func(params json.RawMessage) (interface{}, error) {
+		return srv.Inventory(), nil
+	})
+
+	ctl.Handle("log.level", func(params json.RawMessage) (interface{}, error) {
+		var p logLevelParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, control.InvalidParams("expected {\"level\": \"<level>\"}")
+			}
+		}
+		if p.Level != "" {
+			level, err := logrus.ParseLevel(p.Level)
+			if err != nil {
+				return nil, control.InvalidParams("%v", err)
+			}
+			logrus.SetLevel(level)
+			logrus.Infof("Log level set to %s", level)
+		}
+		return logLevelResult{Level: logrus.GetLevel().String()}, nil
+	})
+
+	ctl.Handle("config.reload", func(params json.RawMessage) (interface{}, error) {
+		cfg, err := reloadConfig(srv, source, instances)
+		if err != nil {
+			return nil, err
+		}
+		return reloadResult{Subnets: len(cfg.Subnets), Leases: len(srv.Leases())}, nil
+	})
+
+	ctl.Handle("server.drain", func(params json.RawMessage) (interface{}, error) {
+		var p drainParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, control.InvalidParams("expected {\"enabled\": true|false}")
+			}
+		}
+		enabled := true
+		if p.Enabled != nil {
+			enabled = *p.Enabled
+		}
+		srv.SetDraining(enabled)
+		return drainResult{Draining: srv.Draining()}, nil
+	})
+
+	ctl.Handle("reservations.list", func(params json.RawMessage) (interface{}, error) {
+		return srv.Reservations(), nil
+	})
+
+	ctl.Handle("reservations.add", func(params json.RawMessage) (interface{}, error) {
+		var p server.Reservation
+		if err := json.Unmarshal(params, &p); err != nil || p.Name == "" || p.IP == "" {
+			return nil, control.InvalidParams("expected {\"name\": \"<host>\", \"mac\": \"<mac>\", \"client_id\": \"<id>\", \"ip\": \"<ip>\"}")
+		}
+		return srv.AddReservation(p)
+	})
+
+	ctl.Handle("reservations.delete", func(params json.RawMessage) (interface{}, error) {
+		var p reservationParams
+		if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+			return nil, control.InvalidParams("expected {\"name\": \"<host>\"}")
+		}
+		if err := srv.DeleteReservation(p.Name); err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+
+	ctl.Handle("capture.start", func(params json.RawMessage) (interface{}, error) {
+		var p captureParams
+		if err := json.Unmarshal(params, &p); err != nil || (p.File == "") != p.HexDump {
+			return nil, control.InvalidParams("expected {\"file\": \"<path>\"} or {\"hexdump\": true}")
+		}
+		if p.MaxSize < 0 || (p.Files != nil && *p.Files < 0) {
+			return nil, control.InvalidParams("max_size and files must not be negative")
+		}
+		if p.HexDump {
+			srv.StartHexDump()
+			return srv.PacketCaptureStatus(), nil
+		}
+		files := -1 // Значение по умолчанию
+		if p.Files != nil {
+			files = *p.Files
+		}
+		if err := srv.StartPacketCapture(p.File, int64(p.MaxSize)*1024*1024, files); err != nil {
+			return nil, err
+		}
+		return srv.PacketCaptureStatus(), nil
+	})
+
+	ctl.Handle("capture.stop", func(params json.RawMessage) (interface{}, error) {
+		srv.StopPacketCapture()
+		return srv.PacketCaptureStatus(), nil
+	})
+
+	ctl.Handle("capture.status", func(params json.RawMessage) (interface{}, error) {
+		return srv.PacketCaptureStatus(), nil
+	})
+}
+
+// addSocketFlag добавляет флаг пути к управляющему сокету
+func addSocketFlag(cmd *cobra.Command, socket *string) {
+	cmd.Flags().StringVarP(socket, "socket", "s", defaultControlSocket, "path to the control socket")
+}
+
+// callDaemon вызывает метод работающего сервера
+func callDaemon(socket, method string, params, result interface{}) error {
+	if err := control.Call(socket, method, params, result); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	return nil
+}
+
+// newReleaseCommand освобождает аренду на работающем сервере
+func newReleaseCommand() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "release <ip|mac>",
+		Short: "Release a lease on the running server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var lease server.Lease
+			if err := callDaemon(socket, "leases.release", releaseParams{Address: args[0]}, &lease); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Released %s lease %s for %s\n", lease.Type, lease.IP, lease.MAC)
+			return nil
+		},
+	}
+	addSocketFlag(cmd, &socket)
+
+	return cmd
+}
+
+// newLogLevelCommand показывает или меняет уровень журнала работающего сервера
+func newLogLevelCommand() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "log-level [level]",
+		Short: "Show or change the log level of the running server",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var params logLevelParams
+			if len(args) == 1 {
+				params.Level = args[0]
+			}
+			var result logLevelResult
+			if err := callDaemon(socket, "log.level", params, &result); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Log level: %s\n", result.Level)
+			return nil
+		},
+	}
+	addSocketFlag(cmd, &socket)
+
+	return cmd
+}
+
+// newReloadCommand перечитывает конфигурацию работающего сервера
+func newReloadCommand() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Reload configuration of the running server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var result reloadResult
+			if err := callDaemon(socket, "config.reload", nil, &result); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Configuration reloaded: %d subnets, %d leases\n", result.Subnets, result.Leases)
+			return nil
+		},
+	}
+	addSocketFlag(cmd, &socket)
+
+	return cmd
+}
+
+// newDrainCommand включает или выключает режим вывода из эксплуатации
+func newDrainCommand() *cobra.Command {
+	var socket string
+	var off bool
+
+	cmd := &cobra.Command{
+		Use:   "drain",
+		Short: "Stop allocating new addresses on the running server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			enabled := !off
+			var result drainResult
+			if err := callDaemon(socket, "server.drain", drainParams{Enabled: &enabled}, &result); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Draining: %v\n", result.Draining)
+			return nil
+		},
+	}
+	addSocketFlag(cmd, &socket)
+	cmd.Flags().BoolVar(&off, "off", false, "resume allocating new addresses")
+
+	return cmd
+}
+
+// newCaptureCommand управляет захватом пакетов на работающем сервере
+func newCaptureCommand() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "capture",
+		Short: "Control packet capture on the running server",
+	}
+	cmd.PersistentFlags().StringVarP(&socket, "socket", "s", defaultControlSocket, "path to the control socket")
+
+	// printStatus выводит режим захвата
+	printStatus := func(cmd *cobra.Command, status server.CaptureStatus) {
+		if status.File != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "Packet capture: %s (%s)\n", status.Mode, status.File)
+			return
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Packet capture: %s\n", status.Mode)
+	}
+
+	var params captureParams
+	var files int
+	start := &cobra.Command{
+		Use:   "start",
+		Short: "Start writing packets to a pcap file or to the log",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("files") {
+				params.Files = &files
+			}
+			var status server.CaptureStatus
+			if err := callDaemon(socket, "capture.start", params, &status); err != nil {
+				return err
+			}
+			printStatus(cmd, status)
+			return nil
+		},
+	}
+	start.Flags().StringVarP(&params.File, "file", "f", "", "pcap file to write")
+	start.Flags().BoolVar(&params.HexDump, "hexdump", false, "log packets as hex dump instead of writing a file")
+	start.Flags().IntVar(&params.MaxSize, "max-size", 0, "rotate the file after this many megabytes (default 10)")
+	start.Flags().IntVar(&files, "files", 0, "number of rotated files to keep (default 5)")
+
+	stop := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop packet capture",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var status server.CaptureStatus
+			if err := callDaemon(socket, "capture.stop", nil, &status); err != nil {
+				return err
+			}
+			printStatus(cmd, status)
+			return nil
+		},
+	}
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show packet capture mode",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var status server.CaptureStatus
+			if err := callDaemon(socket, "capture.status", nil, &status); err != nil {
+				return err
+			}
+			printStatus(cmd, status)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(start, stop, status)
+
+	return cmd
+}
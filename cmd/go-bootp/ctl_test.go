@@ -0,0 +1,183 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/control"
+	"github.com/user/go-bootp/internal/server"
+)
+
+func startTestDaemon(t *testing.T) string {
+	path := writeConfig(t, `
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  host client1 {
+    hardware ethernet 00:11:22:33:44:55;
+    fixed-address 192.168.1.10;
+  }
+}
+`)
+	source := configSource{path: path}
+	_, srv, err := loadServer(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	ctl := control.NewServer(socket)
+	registerControlMethods(ctl, srv, source, nil)
+	if err := ctl.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ctl.Stop)
+
+	return socket
+}
+
+func TestControlCommands(t *testing.T) {
+	socket := startTestDaemon(t)
+	defer logrus.SetLevel(logrus.GetLevel())
+
+	tests := []struct {
+		args   []string
+		output string
+	}{
+		{[]string{"leases", "--socket", socket}, "192.168.1.10"},
+		{[]string{"leases", "--socket", socket, "--json"}, `"mac": "00:11:22:33:44:55"`},
+		{[]string{"leases", "export", "--socket", socket}, `"ip": "192.168.1.10"`},
+		{[]string{"leases", "export", "--socket", socket, "--format", "csv"}, "192.168.1.10,00:11:22:33:44:55,,,192.168.1.0,"},
+		{[]string{"leases", "export", "--socket", socket, "--format", "dhcpd"}, "# Exported by go-bootp"},
+		{[]string{"stats", "--socket", socket}, "192.168.1.0/24"},
+		{[]string{"stats", "--socket", socket, "--json"}, `"requests": 0`},
+		{[]string{"release", "--socket", socket, "00:11:22:33:44:55"}, "Released static lease 192.168.1.10"},
+		{[]string{"log-level", "--socket", socket, "debug"}, "Log level: debug"},
+		{[]string{"log-level", "--socket", socket}, "Log level: debug"},
+		{[]string{"drain", "--socket", socket}, "Draining: true"},
+		{[]string{"drain", "--socket", socket, "--off"}, "Draining: false"},
+		{[]string{"reload", "--socket", socket}, "1 subnets, 1 leases"},
+	}
+
+	for _, test := range tests {
+		out, err := runCommand(t, test.args...)
+		if err != nil {
+			t.Errorf("%v failed: %v", test.args, err)
+			continue
+		}
+		if !strings.Contains(out, test.output) {
+			t.Errorf("%v: expected %q in output, got %q", test.args, test.output, out)
+		}
+	}
+}
+
+func TestControlCommandErrors(t *testing.T) {
+	socket := startTestDaemon(t)
+
+	if _, err := runCommand(t, "release", "--socket", socket, "10.0.0.1"); err == nil {
+		t.Error("Expected release of unknown lease to fail")
+	}
+	if _, err := runCommand(t, "leases", "export", "--socket", socket, "--format", "xml"); err == nil {
+		t.Error("Expected unknown export format to fail")
+	}
+	if _, err := runCommand(t, "leases", "history", "--socket", socket); err == nil || !strings.Contains(err.Error(), "does not keep history") {
+		t.Errorf("Expected lease history without a lease store to fail, got %v", err)
+	}
+	if _, err := runCommand(t, "log-level", "--socket", socket, "loud"); err == nil {
+		t.Error("Expected invalid log level to fail")
+	}
+	if _, err := runCommand(t, "capture", "start", "--socket", socket); err == nil {
+		t.Error("Expected capture start without file or hexdump to fail")
+	}
+	if _, err := runCommand(t, "capture", "start", "--socket", socket, "--hexdump", "--file", "x.pcap"); err == nil {
+		t.Error("Expected capture start with both file and hexdump to fail")
+	}
+}
+
+func TestCaptureCommands(t *testing.T) {
+	socket := startTestDaemon(t)
+	file := filepath.Join(t.TempDir(), "bootp.pcap")
+
+	tests := []struct {
+		args   []string
+		output string
+	}{
+		{[]string{"capture", "status", "--socket", socket}, "Packet capture: off"},
+		{[]string{"capture", "start", "--socket", socket, "--file", file, "--files", "0"}, "Packet capture: pcap (" + file + ")"},
+		{[]string{"capture", "status", "--socket", socket}, "Packet capture: pcap"},
+		{[]string{"capture", "start", "--socket", socket, "--hexdump"}, "Packet capture: hexdump"},
+		{[]string{"capture", "stop", "--socket", socket}, "Packet capture: off"},
+	}
+
+	for _, test := range tests {
+		out, err := runCommand(t, test.args...)
+		if err != nil {
+			t.Errorf("%v failed: %v", test.args, err)
+			continue
+		}
+		if !strings.Contains(out, test.output) {
+			t.Errorf("%v: expected %q in output, got %q", test.args, test.output, out)
+		}
+	}
+}
+
+func TestReservationCommands(t *testing.T) {
+	socket := startTestDaemon(t)
+
+	tests := []struct {
+		args   []string
+		output string
+	}{
+		{[]string{"reservations", "add", "--socket", socket, "printer", "--mac", "aa:bb:cc:dd:ee:01", "--ip", "192.168.1.20"}, "Added reservation printer: 192.168.1.20"},
+		{[]string{"reservations", "list", "--socket", socket}, "printer"},
+		{[]string{"reservations", "list", "--socket", socket, "--json"}, `"managed": true`},
+		{[]string{"reservations", "delete", "--socket", socket, "printer"}, "Deleted reservation printer"},
+	}
+
+	for _, test := range tests {
+		out, err := runCommand(t, test.args...)
+		if err != nil {
+			t.Errorf("%v failed: %v", test.args, err)
+			continue
+		}
+		if !strings.Contains(out, test.output) {
+			t.Errorf("%v: expected %q in output, got %q", test.args, test.output, out)
+		}
+	}
+
+	if _, err := runCommand(t, "reservations", "delete", "--socket", socket, "client1"); err == nil {
+		t.Error("Expected delete of a configuration host to fail")
+	}
+	if _, err := runCommand(t, "reservations", "add", "--socket", socket, "printer", "--ip", "192.168.1.20"); err == nil {
+		t.Error("Expected reservation without mac or client-id to fail")
+	}
+}
+
+func TestExportLeasesDhcpd(t *testing.T) {
+	expires := time.Date(2024, 1, 10, 12, 30, 0, 0, time.UTC) // Среда
+	leases := []server.Lease{
+		{IP: "192.168.1.10", MAC: "00:11:22:33:44:55", Type: "static", State: server.LeaseStateReserved},
+		{IP: "192.168.1.100", MAC: "02:00:00:00:00:01", ClientID: "01:02:00:00:00:00:01", Hostname: "node1",
+			Type: "dynamic", State: server.LeaseStateActive, Active: true, Expires: expires, VendorClass: "PXEClient"},
+	}
+
+	var out strings.Builder
+	if err := exportLeasesDhcpd(&out, leases); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+lease 192.168.1.100 {
+  ends 3 2024/01/10 12:30:00;
+  binding state active;
+  hardware ethernet 02:00:00:00:00:01;
+  uid 01:02:00:00:00:00:01;
+  client-hostname "node1";
+  set vendor-class-identifier = "PXEClient";
+}
+`
+	if _, body, _ := strings.Cut(out.String(), "\n"); body != expected {
+		t.Errorf("Unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/agentx"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/control"
+	"github.com/user/go-bootp/internal/daemon"
+	"github.com/user/go-bootp/internal/grpcapi"
+	"github.com/user/go-bootp/internal/httpapi"
+	"github.com/user/go-bootp/internal/logging"
+	"github.com/user/go-bootp/internal/publisher"
+	"github.com/user/go-bootp/internal/systemd"
+)
+
+// newDaemon читает конфигурацию, настраивает журнал и создает модули
+// демона, не запуская их
+func newDaemon(opts *serveOptions) (*daemon.Daemon, error) {
+	level, err := logrus.ParseLevel(opts.logLevel)
+	if err != nil {
+		return nil, err
+	}
+	logrus.SetLevel(level)
+
+	configPath, err := resolveConfigPath(opts.configPath)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := optionOverrides(opts.set)
+	if err != nil {
+		return nil, err
+	}
+	source := configSource{path: configPath, overrides: overrides}
+
+	cfg, err := loadConfig(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Журнал настраивается до создания сервера, чтобы сообщения
+	// о запуске попали в выбранные назначения
+	logConfig, _ := logging.ConfigFromOptions(cfg.GlobalOptions)
+	closeLog, err := logging.Setup(logrus.StandardLogger(), logConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	srv, closeStore, err := newStoredServer(cfg)
+	if err != nil {
+		closeLog()
+		return nil, err
+	}
+	// Хранилища аренд закрываются после остановки серверов
+	d := daemon.New(srv, logrus.StandardLogger())
+	d.OnStop(closeStore)
+	if err := addModules(d, opts, cfg, source); err != nil {
+		d.Stop()
+		closeLog()
+		return nil, err
+	}
+	d.OnStop(closeLog)
+	return d, nil
+}
+
+// addModules создает логические серверы и собирает модули демона
+func addModules(d *daemon.Daemon, opts *serveOptions, cfg *config.DHCPConfig, source configSource) error {
+	srv := d.Server()
+	instances, err := newInstances(cfg, source)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		d.OnStop(instance.closeStore)
+	}
+
+	if len(opts.interfaces) > 0 {
+		if err := srv.SetInterfaces(opts.interfaces); err != nil {
+			return err
+		}
+	}
+
+	// При активации через сокет systemd уже открыл порт, и сервер может
+	// работать без прав на привязку к привилегированному порту
+	conns, err := systemd.Listeners()
+	if err != nil {
+		return err
+	}
+	if len(conns) > 0 {
+		if len(opts.interfaces) > 0 {
+			logrus.Warnf("Using sockets passed by systemd, --interface is ignored")
+		}
+		srv.SetListeners(conns)
+	}
+
+	// Основной сервер без подсетей при логических серверах не принимает
+	// запросы и нужен только управляющему сокету и API
+	serveMain := len(instances) == 0 || len(cfg.Subnets) > 0 || len(conns) > 0
+	if len(instances) > 0 {
+		main := srv
+		if !serveMain || len(conns) > 0 {
+			main = nil
+		}
+		if err := checkInstanceInterfaces(main, instances); err != nil {
+			return err
+		}
+	}
+
+	d.SetReload(func() (*config.DHCPConfig, error) {
+		return reloadConfig(srv, source, instances)
+	})
+
+	if serveMain {
+		d.AddServer("", srv)
+	}
+	addInstanceModules(d, instances)
+
+	if opts.controlSocket != "" {
+		ctl := control.NewServer(opts.controlSocket)
+		registerControlMethods(ctl, srv, source, instances)
+		d.Add("control socket", ctl.Start, ctl.Stop)
+	}
+
+	if apiConfig, _ := grpcapi.ConfigFromOptions(cfg.GlobalOptions); apiConfig != nil {
+		api, err := grpcapi.NewServer(srv, apiConfig)
+		if err != nil {
+			return err
+		}
+		d.Add("gRPC API", api.Start, api.Stop)
+	}
+
+	if managementConfig, _ := httpapi.ConfigFromOptions(cfg.GlobalOptions); managementConfig != nil {
+		management := httpapi.NewServer(srv, managementConfig)
+		d.Add("HTTP API", management.Start, management.Stop)
+	}
+
+	if agentConfig, _ := agentx.ConfigFromOptions(cfg.GlobalOptions); agentConfig != nil {
+		subagent := agentx.NewSubagent(srv, agentConfig)
+		d.Add("AgentX subagent", func() error { subagent.Start(); return nil }, subagent.Stop)
+	}
+
+	if publishConfig, _ := publisher.ConfigFromOptions(cfg.GlobalOptions); publishConfig != nil {
+		events := publisher.New(srv, publishConfig)
+		d.Add("event publisher", func() error { events.Start(); return nil }, events.Stop)
+	}
+
+	return nil
+}
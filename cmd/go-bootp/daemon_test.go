@@ -0,0 +1,113 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/leasestore"
+	"github.com/user/go-bootp/internal/server"
+)
+
+func TestDaemon(t *testing.T) {
+	path := writeConfig(t, `
+bootp-listen "127.0.0.1:0";
+tftp-root "`+t.TempDir()+`";
+tftp-listen "127.0.0.1:0";
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+}
+`)
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	d, err := newDaemon(&serveOptions{configPath: path, logLevel: "info", controlSocket: socket})
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	defer d.Stop()
+
+	if want := []string{"BOOTP server", "TFTP server", "control socket"}; !reflect.DeepEqual(d.Modules(), want) {
+		t.Errorf("Expected modules %v, got %v", want, d.Modules())
+	}
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("Failed to start daemon: %v", err)
+	}
+	if d.Server().LocalAddr() == nil {
+		t.Error("Expected BOOTP server to be listening")
+	}
+	if out, err := runCommand(t, "reload", "--socket", socket); err != nil || !strings.Contains(out, "1 subnets") {
+		t.Errorf("Reload through control socket failed: %q (%v)", out, err)
+	}
+	if cfg, err := d.Reload(); err != nil || len(cfg.Subnets) != 1 {
+		t.Errorf("Reload failed: %v", err)
+	}
+
+	d.Stop()
+	if _, err := runCommand(t, "reload", "--socket", socket); err == nil {
+		t.Error("Expected control socket to be closed after Stop")
+	}
+}
+
+func TestDaemonLeaseStore(t *testing.T) {
+	database := filepath.Join(t.TempDir(), "leases.db")
+	store, err := leasestore.OpenSQLite(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	starts := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, event := range []server.LeaseEvent{
+		{Type: server.LeaseAllocated, Time: starts, Lease: server.Lease{IP: "192.168.1.150", MAC: "00:11:22:33:44:55", Hostname: "printer", Type: "dynamic", Expires: starts.Add(time.Hour / 2)}},
+		{Type: server.LeaseExpired, Time: starts.Add(time.Hour / 2), Lease: server.Lease{IP: "192.168.1.150", MAC: "00:11:22:33:44:55", Type: "dynamic"}},
+		{Type: server.LeaseAllocated, Time: starts.Add(time.Hour / 2), Lease: server.Lease{IP: "192.168.1.150", MAC: "66:77:88:99:aa:bb", Type: "dynamic", Expires: starts.Add(2 * time.Hour)}},
+	} {
+		if err := store.Save(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	path := writeConfig(t, `
+bootp-listen "127.0.0.1:0";
+lease-store "sqlite:`+database+`";
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+}
+`)
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	d, err := newDaemon(&serveOptions{configPath: path, logLevel: "info", controlSocket: socket})
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	defer d.Stop()
+	if leases := d.Server().Leases(); len(leases) != 1 || leases[0].MAC != "66:77:88:99:aa:bb" {
+		t.Errorf("Expected lease to be restored from the store, got %+v", leases)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Failed to start daemon: %v", err)
+	}
+
+	at := starts.Add(10 * time.Minute).Format("2006-01-02 15:04:05")
+	out, err := runCommand(t, "leases", "history", "--socket", socket, "192.168.1.150", "--at", at)
+	if err != nil || !strings.Contains(out, "00:11:22:33:44:55") || !strings.Contains(out, "expired") || strings.Contains(out, "66:77:88:99:aa:bb") {
+		t.Errorf("Unexpected history at %s: %q (%v)", at, out, err)
+	}
+	out, err = runCommand(t, "leases", "history", "--socket", socket, "66-77-88-99-AA-BB", "--json")
+	if err != nil || !strings.Contains(out, `"ip": "192.168.1.150"`) {
+		t.Errorf("Unexpected history of client: %q (%v)", out, err)
+	}
+	if _, err := runCommand(t, "leases", "history", "--socket", socket, "--at", "last tuesday"); err == nil {
+		t.Error("Expected invalid time to fail")
+	}
+
+	// После остановки база закрыта и открывается заново
+	d.Stop()
+	store, err = leasestore.OpenSQLite(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// dryRunFileOptions опции файлов, которые сервер открывает на запись при
+// создании. При пробном запуске они уже проверены loadConfig и
+// отключаются, чтобы не перезаписать файлы работающего сервера.
+var dryRunFileOptions = []string{
+	"packet-capture-file",
+	"audit-log-file",
+}
+
+// dryRunResult модель сервера, которую выводит serve --dry-run
+type dryRunResult struct {
+	server.RuntimeModel
+	Warnings  []config.Warning               `json:"warnings"`
+	Instances map[string]server.RuntimeModel `json:"instances,omitempty"`
+}
+
+// runDryRun загружает конфигурацию, создает серверы со статическими
+// назначениями, не открывая сокетов, и выводит их действующую модель в
+// JSON. Возвращает ошибку, если конфигурация неверна или какие-то опции
+// не будут отправлены клиентам.
+func runDryRun(out io.Writer, opts *serveOptions) error {
+	level, err := logrus.ParseLevel(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(level)
+
+	configPath, err := resolveConfigPath(opts.configPath)
+	if err != nil {
+		return err
+	}
+	overrides, err := optionOverrides(opts.set)
+	if err != nil {
+		return err
+	}
+	source := configSource{path: configPath, overrides: overrides}
+
+	cfg, err := loadConfig(source)
+	if err != nil {
+		return err
+	}
+	_, instanceConfigs, err := loadInstanceConfigs(cfg, source)
+	if err != nil {
+		return err
+	}
+	warnings, err := config.Lint(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Серверы не запускаются и не останавливаются: Stop записал бы файл
+	// закрепления аренд
+	srv, err := newServer(dryRunConfig(cfg))
+	if err != nil {
+		return err
+	}
+	result := dryRunResult{RuntimeModel: srv.RuntimeModel(), Warnings: warnings}
+	if result.Warnings == nil {
+		result.Warnings = []config.Warning{}
+	}
+	issues := len(result.Issues)
+	for i, instanceConfig := range instanceConfigs {
+		name := cfg.Instances[i].Name
+		instance, err := newServer(dryRunConfig(instanceConfig), server.WithLogger(logrus.WithField("instance", name)))
+		if err != nil {
+			return fmt.Errorf("instance %s: %v", name, err)
+		}
+		if result.Instances == nil {
+			result.Instances = make(map[string]server.RuntimeModel)
+		}
+		model := instance.RuntimeModel()
+		result.Instances[name] = model
+		issues += len(model.Issues)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	if issues > 0 {
+		return fmt.Errorf("configuration has %d issues", issues)
+	}
+	return nil
+}
+
+// dryRunConfig возвращает копию конфигурации без опций dryRunFileOptions
+func dryRunConfig(cfg *config.DHCPConfig) *config.DHCPConfig {
+	if cfg.GlobalOptions == nil {
+		return cfg
+	}
+	copied := *cfg
+	copied.GlobalOptions = make(map[string]string, len(cfg.GlobalOptions))
+	for name, value := range cfg.GlobalOptions {
+		copied.GlobalOptions[name] = value
+	}
+	for _, name := range dryRunFileOptions {
+		delete(copied.GlobalOptions, name)
+	}
+	return &copied
+}
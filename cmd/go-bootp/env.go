@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// Переменные окружения для запуска в контейнере. Флаги переопределяют их.
+const (
+	envConfig        = "GO_BOOTP_CONFIG"         // Путь к dhcpd.conf (--config)
+	envConfigData    = "GO_BOOTP_CONFIG_DATA"    // Текст dhcpd.conf вместо файла
+	envInterfaces    = "GO_BOOTP_INTERFACES"     // Интерфейсы через запятую (--interface)
+	envLogLevel      = "GO_BOOTP_LOG_LEVEL"      // Уровень журнала (--log-level)
+	envControlSocket = "GO_BOOTP_CONTROL_SOCKET" // Управляющий сокет (--control-socket, --socket)
+)
+
+// envOptionPrefix префикс переменных, переопределяющих глобальные опции:
+// GO_BOOTP_OPTION_DEFAULT_LEASE_TIME=7200 задает default-lease-time
+const envOptionPrefix = "GO_BOOTP_OPTION_"
+
+// envConfigPath путь, под которым конфигурация из GO_BOOTP_CONFIG_DATA
+// передается между командами и указывается в ошибках разбора
+const envConfigPath = "$" + envConfigData
+
+// envString возвращает значение переменной окружения или fallback, если
+// она не задана
+func envString(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
+
+// envList возвращает список из переменной окружения, заданный через
+// запятую
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// configSource источник конфигурации: путь из resolveConfigPath и
+// переопределения глобальных опций (см. optionOverrides)
+type configSource struct {
+	path      string
+	overrides map[string]string
+}
+
+// parse разбирает конфигурацию из файла или из GO_BOOTP_CONFIG_DATA и
+// применяет переопределения
+func (c configSource) parse() (*config.DHCPConfig, error) {
+	var cfg *config.DHCPConfig
+	var err error
+	if c.path == envConfigPath {
+		cfg, err = config.Parse(strings.NewReader(os.Getenv(envConfigData)), c.path)
+	} else {
+		cfg, err = config.ParseConfig(c.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.overrides {
+		cfg.GlobalOptions[name] = value
+	}
+	return cfg, nil
+}
+
+// optionOverrides собирает переопределения глобальных опций из переменных
+// GO_BOOTP_OPTION_* и флагов --set name=value. Флаги важнее окружения,
+// окружение важнее файла конфигурации. Имя переменной переводится в
+// нижний регистр с заменой _ на -, значение записывается как в файле
+// (без точки с запятой); --set name без значения задает опцию-флаг.
+func optionOverrides(set []string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, envOptionPrefix) || name == envOptionPrefix {
+			continue
+		}
+		name = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, envOptionPrefix), "_", "-"))
+		overrides[name] = value
+	}
+	for _, assignment := range set {
+		name, value, _ := strings.Cut(assignment, "=")
+		if name = strings.TrimSpace(name); name == "" || strings.ContainsAny(name, " \t;{}") {
+			return nil, fmt.Errorf("invalid --set %q, expected name=value", assignment)
+		}
+		overrides[name] = strings.TrimSuffix(strings.TrimSpace(value), ";")
+	}
+	return overrides, nil
+}
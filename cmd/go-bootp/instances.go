@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/daemon"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// instance логический сервер, объявленный оператором instance. У каждого
+// свои интерфейсы, подсети, таблица аренд и хуки; журнал, управляющий
+// сокет и API берутся из основной конфигурации и работают с основным
+// сервером.
+type instance struct {
+	name   string
+	source configSource
+	srv    *server.BOOTPServer
+
+	closeStore func() // Закрывает хранилище аренд после остановки сервера
+}
+
+// Опции с путями к файлам, которые логические серверы не могут делить
+var instanceFileOptions = []string{
+	"lease-affinity-file",
+	"reservations-file",
+	"audit-log-file",
+	"packet-capture-file",
+	"lease-store",
+}
+
+// instanceSource возвращает источник конфигурации логического сервера.
+// Переопределения --set и GO_BOOTP_OPTION_* относятся только к основной
+// конфигурации.
+func instanceSource(mainPath string, declared config.Instance) configSource {
+	path := declared.File
+	if !filepath.IsAbs(path) && mainPath != envConfigPath {
+		path = filepath.Join(filepath.Dir(mainPath), path)
+	}
+	return configSource{path: path}
+}
+
+// loadInstanceConfigs читает и проверяет конфигурации логических серверов,
+// объявленных в cfg. Каждый логический сервер должен быть ограничен
+// интерфейсами и хранить файлы отдельно от остальных.
+func loadInstanceConfigs(cfg *config.DHCPConfig, source configSource) ([]configSource, []*config.DHCPConfig, error) {
+	if len(cfg.Instances) == 0 {
+		return nil, nil, nil
+	}
+	mainPath, err := resolveConfigPath(source.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := make(map[string]string)
+	claim := func(owner string, options map[string]string) error {
+		for _, option := range instanceFileOptions {
+			path := strings.Trim(options[option], "\"")
+			if path == "" {
+				continue
+			}
+			if other, ok := files[path]; ok {
+				return fmt.Errorf("%s: %s %s is already used by %s", owner, option, path, other)
+			}
+			files[path] = owner
+		}
+		return nil
+	}
+	if err := claim("main configuration", cfg.GlobalOptions); err != nil {
+		return nil, nil, err
+	}
+
+	sources := make([]configSource, 0, len(cfg.Instances))
+	configs := make([]*config.DHCPConfig, 0, len(cfg.Instances))
+	for _, declared := range cfg.Instances {
+		src := instanceSource(mainPath, declared)
+		instanceConfig, err := loadInstanceConfig(declared.Name, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := claim("instance "+declared.Name, instanceConfig.GlobalOptions); err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, src)
+		configs = append(configs, instanceConfig)
+	}
+	return sources, configs, nil
+}
+
+// loadInstanceConfig читает и проверяет конфигурацию одного логического сервера
+func loadInstanceConfig(name string, source configSource) (*config.DHCPConfig, error) {
+	cfg, err := loadConfig(source)
+	if err != nil {
+		return nil, fmt.Errorf("instance %s: %v", name, err)
+	}
+	if len(cfg.Instances) > 0 {
+		return nil, fmt.Errorf("instance %s: nested instance declarations are not supported", name)
+	}
+	if strings.Trim(cfg.GlobalOptions["interfaces"], "\" ") == "" {
+		return nil, fmt.Errorf("instance %s: interfaces must be set", name)
+	}
+	return cfg, nil
+}
+
+// newInstances создает логические серверы. Сообщения каждого из них
+// помечаются полем instance.
+func newInstances(cfg *config.DHCPConfig, source configSource) ([]*instance, error) {
+	sources, configs, err := loadInstanceConfigs(cfg, source)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]*instance, 0, len(configs))
+	for i, instanceConfig := range configs {
+		name := cfg.Instances[i].Name
+		srv, closeStore, err := newStoredServer(instanceConfig, server.WithLogger(logrus.WithField("instance", name)))
+		if err != nil {
+			for _, instance := range instances {
+				instance.closeStore()
+			}
+			return nil, fmt.Errorf("instance %s: %v", name, err)
+		}
+		instances = append(instances, &instance{name: name, source: sources[i], srv: srv, closeStore: closeStore})
+	}
+	return instances, nil
+}
+
+// checkInstanceInterfaces проверяет, что серверы не делят интерфейсы:
+// общий интерфейс смешал бы запросы разных таблиц аренд. main равен nil,
+// если основной сервер не принимает запросы.
+func checkInstanceInterfaces(main *server.BOOTPServer, instances []*instance) error {
+	owners := make(map[string]string)
+	claim := func(owner string, names []string) error {
+		for _, name := range names {
+			if other, ok := owners[name]; ok {
+				return fmt.Errorf("%s: interface %s is already served by %s", owner, name, other)
+			}
+			owners[name] = owner
+		}
+		return nil
+	}
+
+	if main != nil {
+		if len(main.Interfaces()) == 0 {
+			return fmt.Errorf("main configuration has subnets and instances, set interfaces to separate them")
+		}
+		if err := claim("main configuration", main.Interfaces()); err != nil {
+			return err
+		}
+	}
+	for _, instance := range instances {
+		if err := claim("instance "+instance.name, instance.srv.Interfaces()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addInstanceModules добавляет логические серверы и их встроенные серверы
+// отдельными модулями демона. Ошибки запуска помечаются именем
+// логического сервера.
+func addInstanceModules(d *daemon.Daemon, instances []*instance) {
+	for _, instance := range instances {
+		instance := instance
+		prefix := "instance " + instance.name + " "
+		start := func() error {
+			if err := instance.srv.Start(); err != nil {
+				return fmt.Errorf("instance %s: %v", instance.name, err)
+			}
+			logrus.Infof("Instance %s serving %s", instance.name, strings.Join(instance.srv.Interfaces(), ", "))
+			return nil
+		}
+		d.Add(prefix+"BOOTP server", start, instance.srv.Stop)
+		for _, service := range instance.srv.Services() {
+			service := service
+			start := func() error {
+				if err := service.Start(); err != nil {
+					return fmt.Errorf("instance %s: %v", instance.name, err)
+				}
+				return nil
+			}
+			d.Add(prefix+service.Name, start, service.Stop)
+		}
+	}
+}
+
+// reloadInstances перечитывает конфигурации логических серверов. Новые и
+// удаленные операторы instance, как и смена интерфейсов, применяются
+// только после перезапуска.
+func reloadInstances(cfg *config.DHCPConfig, instances []*instance) error {
+	declared := make([]string, 0, len(cfg.Instances))
+	for _, instance := range cfg.Instances {
+		declared = append(declared, instance.Name)
+	}
+	running := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		running = append(running, instance.name)
+	}
+	if !reflect.DeepEqual(declared, running) {
+		logrus.Warnf("Instance declarations changed, restart the server to apply them")
+	}
+
+	for _, instance := range instances {
+		instanceConfig, err := loadInstanceConfig(instance.name, instance.source)
+		if err != nil {
+			return err
+		}
+		if err := instance.srv.Reload(instanceConfig); err != nil {
+			return fmt.Errorf("instance %s: %v", instance.name, err)
+		}
+	}
+	return nil
+}
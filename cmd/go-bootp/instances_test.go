@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testInterface возвращает имя любого сетевого интерфейса системы
+func testInterface(t *testing.T) string {
+	interfaces, err := net.Interfaces()
+	if err != nil || len(interfaces) == 0 {
+		t.Skip("no network interfaces")
+	}
+	return interfaces[0].Name
+}
+
+// writeInstanceFiles записывает файлы конфигурации в общий каталог и
+// возвращает путь к файлу main
+func writeInstanceFiles(t *testing.T, files map[string]string, main string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, main)
+}
+
+func TestCheckInstances(t *testing.T) {
+	iface := testInterface(t)
+	tenant := func(subnet, extra string) string {
+		return `interfaces "` + iface + `";
+` + extra + `
+subnet ` + subnet + ` {
+  range ` + strings.TrimSuffix(subnet, "0/24") + `100 ` + strings.TrimSuffix(subnet, "0/24") + `200;
+}
+`
+	}
+
+	path := writeInstanceFiles(t, map[string]string{
+		"dhcpd.conf":    "instance \"a\" \"tenant-a.conf\";\ninstance \"b\" \"tenant-b.conf\";\n",
+		"tenant-a.conf": tenant("10.1.0.0/24", `lease-affinity-file "/var/lib/go-bootp/a";`),
+		"tenant-b.conf": tenant("10.2.0.0/24", `lease-affinity-file "/var/lib/go-bootp/b";`),
+	}, "dhcpd.conf")
+	if _, err := runCommand(t, "check", "--config", path); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{"missing file", map[string]string{"dhcpd.conf": `instance "a" "missing.conf";`}, "instance a:"},
+		{"no interfaces", map[string]string{
+			"dhcpd.conf":    `instance "a" "tenant-a.conf";`,
+			"tenant-a.conf": "subnet 10.1.0.0/24 {\n}\n",
+		}, "instance a: interfaces must be set"},
+		{"nested", map[string]string{
+			"dhcpd.conf":    `instance "a" "tenant-a.conf";`,
+			"tenant-a.conf": tenant("10.1.0.0/24", `instance "b" "tenant-b.conf";`),
+		}, "nested instance"},
+		{"shared file", map[string]string{
+			"dhcpd.conf":    "reservations-file \"/var/lib/go-bootp/hosts\";\ninstance \"a\" \"tenant-a.conf\";\n",
+			"tenant-a.conf": tenant("10.1.0.0/24", `reservations-file "/var/lib/go-bootp/hosts";`),
+		}, "already used by main configuration"},
+	}
+	for _, tt := range tests {
+		_, err := runCommand(t, "check", "--config", writeInstanceFiles(t, tt.files, "dhcpd.conf"))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestInstanceInterfaces(t *testing.T) {
+	iface := testInterface(t)
+	tenant := "interfaces \"" + iface + "\";\nsubnet 10.1.0.0/24 {\n  range 10.1.0.100 10.1.0.200;\n}\n"
+	path := writeInstanceFiles(t, map[string]string{
+		"dhcpd.conf": "instance \"a\" \"a.conf\";\ninstance \"b\" \"b.conf\";\n",
+		"a.conf":     tenant,
+		"b.conf":     strings.ReplaceAll(tenant, "10.1.0.", "10.2.0."),
+	}, "dhcpd.conf")
+
+	source := configSource{path: path}
+	cfg, srv, err := loadServer(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances, err := newInstances(cfg, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 || instances[0].name != "a" || instances[1].srv.Interfaces()[0] != iface {
+		t.Fatalf("Unexpected instances %+v", instances)
+	}
+
+	// Оба логических сервера на одном интерфейсе
+	if err := checkInstanceInterfaces(nil, instances); err == nil || !strings.Contains(err.Error(), "instance b: interface "+iface) {
+		t.Errorf("Expected shared interface error, got %v", err)
+	}
+	// Основной сервер с подсетями должен быть ограничен интерфейсами
+	if err := checkInstanceInterfaces(srv, instances[:1]); err == nil || !strings.Contains(err.Error(), "set interfaces") {
+		t.Errorf("Expected main configuration error, got %v", err)
+	}
+	if err := checkInstanceInterfaces(nil, instances[:1]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Перезагрузка применяет конфигурацию каждого логического сервера
+	if err := reloadInstances(cfg, instances); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "b.conf"), []byte("interfaces \""+iface+"\";\nsubnet 10.2.0.0/24 {\n  range 10.3.0.1 10.3.0.2;\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadInstances(cfg, instances); err == nil || !strings.Contains(err.Error(), "instance b:") {
+		t.Errorf("Expected reload error for instance b, got %v", err)
+	}
+}
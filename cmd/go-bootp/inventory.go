@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// newInventoryCommand выводит клиентов, замеченных работающим сервером
+// в режиме обучения (learning-mode)
+func newInventoryCommand() *cobra.Command {
+	var socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Show clients recorded by the running server in learning mode",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var entries []server.InventoryEntry
+			if err := callDaemon(socket, "inventory.list", nil, &entries); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(entries)
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "MAC\tHOSTNAME\tVENDOR CLASS\tRELAY\tCIRCUIT-ID\tREMOTE-ID\tREQUESTS\tLAST MESSAGE\tLAST SEEN")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					e.MAC, dash(e.Hostname), dash(e.VendorClass), dash(e.Relay), dash(e.CircuitID), dash(e.RemoteID),
+					e.Requests, e.LastMessage, e.LastSeen.Format(time.RFC3339))
+			}
+			return w.Flush()
+		},
+	}
+
+	addSocketFlag(cmd, &socket)
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print inventory as JSON")
+
+	return cmd
+}
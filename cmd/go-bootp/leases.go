@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/leasestore"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// newLeasesCommand выводит аренды работающего сервера
+func newLeasesCommand() *cobra.Command {
+	var socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "leases",
+		Short: "List leases of the running server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var leases []server.Lease
+			if err := callDaemon(socket, "leases.list", nil, &leases); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(leases)
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "IP\tMAC\tHOSTNAME\tSUBNET\tTYPE\tACTIVE\tEXPIRES")
+			for _, lease := range leases {
+				expires := "-"
+				if !lease.Expires.IsZero() {
+					expires = lease.Expires.Format(time.RFC3339)
+				}
+				hostname := lease.Hostname
+				if hostname == "" {
+					hostname = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\t%s\n",
+					lease.IP, lease.MAC, hostname, lease.Subnet, lease.Type, lease.Active, expires)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&socket, "socket", "s", defaultControlSocket, "path to the control socket")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print leases as JSON")
+
+	var format string
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export the lease table as JSON, CSV or dhcpd.leases",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			write, ok := leaseExporters[format]
+			if !ok {
+				return fmt.Errorf("unknown format %q (json, csv, dhcpd)", format)
+			}
+			var leases []server.Lease
+			if err := callDaemon(socket, "leases.list", nil, &leases); err != nil {
+				return err
+			}
+			return write(cmd.OutOrStdout(), leases)
+		},
+	}
+	export.Flags().StringVarP(&format, "format", "f", "json", "output format: json, csv or dhcpd")
+	cmd.AddCommand(export)
+	cmd.AddCommand(newLeasesHistoryCommand(&socket))
+	cmd.AddCommand(newLeasesMigrateCommand())
+
+	return cmd
+}
+
+// newLeasesMigrateCommand переносит аренды между хранилищами. Сервер,
+// работающий с хранилищами, должен быть остановлен.
+func newLeasesMigrateCommand() *cobra.Command {
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "migrate --from <type:path> --to <type:path>",
+		Short: "Move active leases between lease stores while the server is stopped",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fromConfig, err := leasestore.ParseConfig(from)
+			if err != nil {
+				return fmt.Errorf("invalid --from: %v", err)
+			}
+			toConfig, err := leasestore.ParseConfig(to)
+			if err != nil {
+				return fmt.Errorf("invalid --to: %v", err)
+			}
+			if filepath.Clean(fromConfig.Path) == filepath.Clean(toConfig.Path) {
+				return fmt.Errorf("--from and --to must be different files")
+			}
+			// Открытие создало бы пустое исходное хранилище
+			if _, err := os.Stat(fromConfig.Path); err != nil {
+				return fmt.Errorf("lease store %s: %v", fromConfig, err)
+			}
+
+			source, err := leasestore.Open(fromConfig)
+			if err != nil {
+				return err
+			}
+			defer source.Close()
+			target, err := leasestore.Open(toConfig)
+			if err != nil {
+				return err
+			}
+			result, err := server.MigrateLeases(source, target, time.Now())
+			if closeErr := target.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Migrated %d of %d leases from %s to %s (%d skipped, %d duplicates)\n",
+				result.Migrated, result.Loaded, fromConfig, toConfig, result.Skipped, result.Duplicates)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "source lease store, for example file:/var/lib/go-bootp/leases")
+	cmd.Flags().StringVar(&to, "to", "", "target lease store, for example sqlite:/var/lib/go-bootp/leases.db")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+// Форматы времени флага --at, кроме RFC 3339. Время без часового пояса
+// считается местным.
+var historyTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseHistoryTime разбирает момент времени запроса истории
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range historyTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or \"YYYY-MM-DD[ HH:MM[:SS]]\"", value)
+}
+
+// newLeasesHistoryCommand выводит историю назначений адресов из хранилища
+// аренд работающего сервера
+func newLeasesHistoryCommand(socket *string) *cobra.Command {
+	var at string
+	var limit int
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "history [ip|mac]",
+		Short: "Show past address assignments from the lease store",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := historyParams{Limit: limit}
+			if len(args) > 0 {
+				params.Address = args[0]
+			}
+			if at != "" {
+				t, err := parseHistoryTime(at)
+				if err != nil {
+					return err
+				}
+				params.At = t
+			}
+			var records []server.HistoryRecord
+			if err := callDaemon(*socket, "leases.history", params, &records); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				if records == nil {
+					records = []server.HistoryRecord{}
+				}
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(records)
+			}
+
+			now := time.Now()
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "IP\tMAC\tHOSTNAME\tSTARTS\tENDS\tSTATE")
+			for _, record := range records {
+				ends, state := "-", "active"
+				switch {
+				case !record.Ends.IsZero():
+					ends, state = record.Ends.Local().Format(time.RFC3339), record.EndReason
+				case !record.Expires.IsZero() && !record.Expires.After(now):
+					ends, state = record.Expires.Local().Format(time.RFC3339), string(server.LeaseExpired)
+				}
+				hostname := record.Hostname
+				if hostname == "" {
+					hostname = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					record.IP, record.MAC, hostname, record.Starts.Local().Format(time.RFC3339), ends, state)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&at, "at", "", "show assignments active at this time (RFC 3339 or \"YYYY-MM-DD HH:MM\")")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 100, "show at most this many assignments (0 for all)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print assignments as JSON")
+	return cmd
+}
+
+// leaseExporters форматы выгрузки аренд
+var leaseExporters = map[string]func(io.Writer, []server.Lease) error{
+	"json":  exportLeasesJSON,
+	"csv":   exportLeasesCSV,
+	"dhcpd": exportLeasesDhcpd,
+}
+
+// exportLeasesJSON выгружает аренды массивом JSON
+func exportLeasesJSON(out io.Writer, leases []server.Lease) error {
+	if leases == nil {
+		leases = []server.Lease{}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(leases)
+}
+
+// exportLeasesCSV выгружает аренды в CSV с заголовком. Время в RFC 3339,
+// архитектуры клиента через точку с запятой.
+func exportLeasesCSV(out io.Writer, leases []server.Lease) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"ip", "mac", "client_id", "hostname", "subnet", "subnet_id", "type", "state",
+		"active", "expires", "bootp", "vendor_class", "arch", "out_of_range"})
+	for _, lease := range leases {
+		expires := ""
+		if !lease.Expires.IsZero() {
+			expires = lease.Expires.Format(time.RFC3339)
+		}
+		arch := make([]string, len(lease.Arch))
+		for i, a := range lease.Arch {
+			arch[i] = strconv.Itoa(int(a))
+		}
+		w.Write([]string{lease.IP, lease.MAC, lease.ClientID, lease.Hostname, lease.Subnet, lease.SubnetID,
+			lease.Type, lease.State, strconv.FormatBool(lease.Active), expires, strconv.FormatBool(lease.BOOTP),
+			lease.VendorClass, strings.Join(arch, ";"), strconv.FormatBool(lease.OutOfRange)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// exportLeasesDhcpd выгружает аренды в формате dhcpd.leases ISC DHCP.
+// Статические адреса, к которым клиент еще не обращался, не выгружаются.
+func exportLeasesDhcpd(out io.Writer, leases []server.Lease) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "# Exported by go-bootp at %s\n", time.Now().UTC().Format(time.RFC3339))
+	for _, lease := range leases {
+		if lease.State == server.LeaseStateReserved {
+			continue
+		}
+		fmt.Fprintf(w, "\nlease %s {\n", lease.IP)
+		if lease.Expires.IsZero() {
+			fmt.Fprintf(w, "  ends never;\n")
+		} else {
+			ends := lease.Expires.UTC()
+			fmt.Fprintf(w, "  ends %d %s;\n", ends.Weekday(), ends.Format("2006/01/02 15:04:05"))
+		}
+		fmt.Fprintf(w, "  binding state %s;\n", lease.State)
+		fmt.Fprintf(w, "  hardware ethernet %s;\n", lease.MAC)
+		if lease.ClientID != "" {
+			fmt.Fprintf(w, "  uid %s;\n", lease.ClientID)
+		}
+		if lease.Hostname != "" {
+			fmt.Fprintf(w, "  client-hostname %q;\n", lease.Hostname)
+		}
+		if lease.VendorClass != "" {
+			fmt.Fprintf(w, "  set vendor-class-identifier = %q;\n", lease.VendorClass)
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+	return w.Flush()
+}
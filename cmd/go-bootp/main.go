@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// version задается при сборке: -ldflags "-X main.version=..."
+var version = "dev"
+
+// Путь к управляющему сокету по умолчанию для serve и команд управления
+var defaultControlSocket = envString(envControlSocket, "/run/go-bootp.sock")
+
+// newRootCommand создает корневую команду со всеми подкомандами
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "go-bootp",
+		Short:         "BOOTP/DHCP server with ISC-DHCP compatible configuration",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	root.AddCommand(
+		newServeCommand(),
+		newCheckCommand(),
+		newLeasesCommand(),
+		newStatsCommand(),
+		newInventoryCommand(),
+		newReleaseCommand(),
+		newReservationsCommand(),
+		newLogLevelCommand(),
+		newReloadCommand(),
+		newDrainCommand(),
+		newCaptureCommand(),
+		newReplayCommand(),
+		newVersionCommand(),
+	)
+
+	return root
+}
+
+// newVersionCommand выводит версию сервера
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print version",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintf(cmd.OutOrStdout(), "go-bootp %s\n", version)
+		},
+	}
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/leasestore"
+	"github.com/user/go-bootp/internal/server"
+)
+
+func runCommand(t *testing.T, args ...string) (string, error) {
+	root := newRootCommand()
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(out)
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "dhcpd.conf")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVersionCommand(t *testing.T) {
+	out, err := runCommand(t, "version")
+	if err != nil {
+		t.Fatalf("version failed: %v", err)
+	}
+	if !strings.Contains(out, version) {
+		t.Errorf("Expected version in output, got %q", out)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	path := writeConfig(t, `
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  host client1 {
+    hardware ethernet 00:11:22:33:44:55;
+    fixed-address 192.168.1.10;
+  }
+}
+`)
+
+	out, err := runCommand(t, "check", "--config", path)
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !strings.Contains(out, "1 subnets, 1 hosts") {
+		t.Errorf("Unexpected check output: %q", out)
+	}
+}
+
+func TestCheckCommandEnvironment(t *testing.T) {
+	t.Setenv(envConfigData, `
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+}
+`)
+	out, err := runCommand(t, "check")
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !strings.Contains(out, "1 subnets, 0 hosts") {
+		t.Errorf("Unexpected check output: %q", out)
+	}
+
+	// Ошибки указывают на переменную окружения
+	t.Setenv(envConfigData, "subnet 192.168.1.0 {\n")
+	if _, err := runCommand(t, "check"); err == nil || !strings.HasPrefix(err.Error(), "$GO_BOOTP_CONFIG_DATA:") {
+		t.Errorf("Expected error in environment configuration, got %v", err)
+	}
+
+	// Путь из GO_BOOTP_CONFIG важнее текста
+	t.Setenv(envConfig, writeConfig(t, "subnet 10.0.0.0 netmask 255.0.0.0 {\n}\nsubnet 10.1.0.0 netmask 255.255.0.0 {\n}\n"))
+	if out, err := runCommand(t, "check"); err != nil || !strings.Contains(out, "2 subnets") {
+		t.Errorf("Expected configuration from %s, got %q (%v)", envConfig, out, err)
+	}
+}
+
+func TestOptionOverrides(t *testing.T) {
+	t.Setenv("GO_BOOTP_OPTION_DEFAULT_LEASE_TIME", "7200")
+	t.Setenv("GO_BOOTP_OPTION_PING_CHECK", "")
+	t.Setenv("GO_BOOTP_OPTION_MAX_LEASE_TIME", "600")
+
+	overrides, err := optionOverrides([]string{"max-lease-time=86400;", "authoritative-mode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"default-lease-time": "7200",
+		"ping-check":         "",
+		"max-lease-time":     "86400", // Флаг важнее окружения
+		"authoritative-mode": "",
+	}
+	if !reflect.DeepEqual(overrides, expected) {
+		t.Errorf("Expected %v, got %v", expected, overrides)
+	}
+
+	for _, bad := range []string{"=1", "max lease=1"} {
+		if _, err := optionOverrides([]string{bad}); err == nil {
+			t.Errorf("Expected --set %q to fail", bad)
+		}
+	}
+}
+
+func TestCheckCommandOverrides(t *testing.T) {
+	path := writeConfig(t, `
+max-reply-size 576;
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+}
+`)
+
+	// Окружение важнее файла, флаг важнее окружения
+	t.Setenv("GO_BOOTP_OPTION_MAX_REPLY_SIZE", "huge")
+	if _, err := runCommand(t, "check", "--config", path); err == nil || !strings.Contains(err.Error(), "max-reply-size") {
+		t.Errorf("Expected invalid max-reply-size from environment, got %v", err)
+	}
+	if _, err := runCommand(t, "check", "--config", path, "--set", "max-reply-size=1024"); err != nil {
+		t.Errorf("Expected --set to override environment, got %v", err)
+	}
+	if _, err := runCommand(t, "check", "--config", path, "--set", "bad option"); err == nil {
+		t.Error("Expected invalid --set to fail")
+	}
+}
+
+func TestCheckCommandWarnings(t *testing.T) {
+	path := writeConfig(t, `
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  default-lease-time 60;
+}
+`)
+
+	out, err := runCommand(t, "check", "--config", path)
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !strings.Contains(out, "warning: subnet 192.168.1.0: no routers option") || !strings.Contains(out, "2 warnings") {
+		t.Errorf("Unexpected check output: %q", out)
+	}
+
+	out, err = runCommand(t, "check", "--config", path, "--json")
+	if err != nil {
+		t.Fatalf("check --json failed: %v", err)
+	}
+	if !strings.Contains(out, `"check": "short-lease"`) || !strings.Contains(out, `"subnets": 1`) {
+		t.Errorf("Unexpected check --json output: %q", out)
+	}
+
+	if _, err := runCommand(t, "check", "--config", path, "--strict"); err == nil {
+		t.Error("Expected check --strict to fail on warnings")
+	}
+}
+
+func TestCheckCommandUnknownStatement(t *testing.T) {
+	// Операторы всех пакетов сервера известны, опечатка - нет
+	path := writeConfig(t, `
+tftp-root "/srv/tftp";
+management-listen "127.0.0.1:8067";
+management-insecure;
+management-token "secret";
+log-format json;
+event-publish-url "nats://127.0.0.1:4222/leases";
+snmp-agentx "/var/agentx/master";
+tftp-listne "0.0.0.0:69";
+option routers 192.168.1.1;
+`)
+	out, err := runCommand(t, "check", "--config", path)
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !strings.Contains(out, "warning: global: unknown statement tftp-listne is ignored [unknown-statement]") || !strings.Contains(out, "1 warnings") {
+		t.Errorf("Unexpected check output: %q", out)
+	}
+}
+
+func TestCheckCommandInvalid(t *testing.T) {
+	path := writeConfig(t, `
+rate-limit-per-client fast;
+`)
+
+	if _, err := runCommand(t, "check", "--config", path); err == nil {
+		t.Error("Expected check to fail on invalid configuration")
+	}
+	path = writeConfig(t, `
+grpc-listen ":9090";
+`)
+	if _, err := runCommand(t, "check", "--config", path); err == nil {
+		t.Error("Expected check to fail on gRPC API without token")
+	}
+	path = writeConfig(t, `
+retransmit-cache-time abc;
+`)
+	if out, err := runCommand(t, "check", "--config", path); err == nil || !strings.Contains(err.Error(), "invalid retransmit-cache-time: abc") {
+		t.Errorf("Expected check to fail on invalid retransmit-cache-time, got %q (%v)", out, err)
+	}
+	path = writeConfig(t, `
+socket-receive-buffer xyz;
+`)
+	if out, err := runCommand(t, "check", "--config", path); err == nil || !strings.Contains(err.Error(), "invalid socket-receive-buffer: xyz") {
+		t.Errorf("Expected check to fail on invalid socket-receive-buffer, got %q (%v)", out, err)
+	}
+	if _, err := runCommand(t, "check", "--config", filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Error("Expected check to fail on missing configuration")
+	}
+}
+
+func TestCheckCommandNoSideEffects(t *testing.T) {
+	// Проверка конфигурации не должна перезаписывать файл захвата пакетов
+	capture := filepath.Join(t.TempDir(), "bootp.pcap")
+	if err := os.WriteFile(capture, []byte("previous capture"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, `
+packet-capture-file "`+capture+`";
+`)
+
+	if _, err := runCommand(t, "check", "--config", path); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	data, err := os.ReadFile(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "previous capture" {
+		t.Errorf("Expected capture file to be left untouched, got %d bytes", len(data))
+	}
+
+	path = writeConfig(t, `
+packet-capture-file "`+capture+`";
+packet-capture-max-size huge;
+`)
+	if _, err := runCommand(t, "check", "--config", path); err == nil {
+		t.Error("Expected check to fail on invalid packet-capture-max-size")
+	}
+}
+
+func TestLeasesCommandNoDaemon(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "missing.sock")
+	if _, err := runCommand(t, "leases", "--socket", socket); err == nil {
+		t.Error("Expected leases to fail without a running daemon")
+	}
+}
+
+func TestServeDryRun(t *testing.T) {
+	capture := filepath.Join(t.TempDir(), "bootp.pcap")
+	path := writeConfig(t, `
+packet-capture-file "`+capture+`";
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  option routers 192.168.1.1;
+  host client1 {
+    hardware ethernet 00:11:22:33:44:55;
+    fixed-address 192.168.1.10;
+    filename "pxelinux.0";
+  }
+}
+`)
+
+	out, err := runCommand(t, "serve", "--config", path, "--dry-run")
+	if err != nil {
+		t.Fatalf("serve --dry-run failed: %v", err)
+	}
+	var result dryRunResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", out, err)
+	}
+	if len(result.Subnets) != 1 || result.Subnets[0].Size != 101 || result.Subnets[0].Options["routers"] != "192.168.1.1" {
+		t.Errorf("Unexpected subnets %+v", result.Subnets)
+	}
+	if len(result.Reservations) != 1 || result.Reservations[0].Boot.Filename != "pxelinux.0" || result.Reservations[0].SubnetID != "192.168.1.0/24" {
+		t.Errorf("Unexpected reservations %+v", result.Reservations)
+	}
+
+	// Файл захвата пакетов не создается
+	if _, err := os.Stat(capture); !os.IsNotExist(err) {
+		t.Errorf("Expected no capture file, got %v", err)
+	}
+
+	path = writeConfig(t, `
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.300;
+}
+`)
+	if _, err := runCommand(t, "serve", "--config", path, "--dry-run"); err == nil {
+		t.Error("Expected serve --dry-run to fail on invalid configuration")
+	}
+}
+
+func TestLeasesMigrateCommand(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "leases"), filepath.Join(dir, "leases.db")
+
+	store, err := leasestore.OpenFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, lease := range []server.Lease{
+		{IP: "192.168.1.100", MAC: "00:11:22:33:44:55", Type: "dynamic", Expires: now.Add(time.Hour)},
+		{IP: "192.168.1.101", MAC: "00:11:22:33:44:55", Type: "dynamic", Expires: now.Add(time.Minute)},
+		{IP: "192.168.1.102", MAC: "66:77:88:99:aa:bb", Type: "dynamic", Expires: now.Add(-time.Minute)},
+	} {
+		if err := store.Save(server.LeaseEvent{Type: server.LeaseAllocated, Time: now, Lease: lease}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	out, err := runCommand(t, "leases", "migrate", "--from", "file:"+from, "--to", "sqlite:"+to)
+	if err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	if !strings.Contains(out, "Migrated 1 of 3 leases") || !strings.Contains(out, "1 skipped, 1 duplicates") {
+		t.Errorf("Unexpected output %q", out)
+	}
+
+	migrated, err := leasestore.OpenSQLite(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer migrated.Close()
+	leases, err := migrated.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].IP != "192.168.1.100" {
+		t.Errorf("Unexpected migrated leases %+v", leases)
+	}
+
+	for _, args := range [][]string{
+		{"--from", "file:" + filepath.Join(dir, "missing"), "--to", "sqlite:" + to},
+		{"--from", "file:" + from, "--to", "file:" + from},
+		{"--from", from, "--to", "sqlite:" + to},
+		{"--from", "file:" + from},
+	} {
+		if _, err := runCommand(t, append([]string{"leases", "migrate"}, args...)...); err == nil {
+			t.Errorf("Expected migrate %v to fail", args)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Error("Expected missing source store not to be created")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Названия типов ответов в отчете replay
+var replyTypeNames = map[uint8]string{
+	0:                "BOOTP",
+	server.DHCPOffer: "OFFER",
+	server.DHCPAck:   "ACK",
+	server.DHCPNak:   "NAK",
+}
+
+// newReplayCommand отправляет на сервер запросы, записанные захватом пакетов
+func newReplayCommand() *cobra.Command {
+	var target, giaddr string
+	var speed float64
+	var wait time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "replay FILE",
+		Short: "Replay requests recorded in a pcap file against a server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if speed < 0 {
+				return fmt.Errorf("invalid --speed %v", speed)
+			}
+			addr, err := replayTarget(target)
+			if err != nil {
+				return err
+			}
+			var relay net.IP
+			if giaddr != "" {
+				if relay = net.ParseIP(giaddr).To4(); relay == nil {
+					return fmt.Errorf("invalid --giaddr %q", giaddr)
+				}
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			packets, err := server.ReadPcap(file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			requests := replayRequests(packets)
+			if len(requests) == 0 {
+				return fmt.Errorf("no BOOTP requests in %s", args[0])
+			}
+
+			conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+			if err != nil {
+				return err
+			}
+			replies := collectReplies(conn)
+
+			started := time.Now()
+			for _, packet := range requests {
+				if speed > 0 {
+					offset := time.Duration(float64(packet.Time.Sub(requests[0].Time)) / speed)
+					time.Sleep(time.Until(started.Add(offset)))
+				}
+				// giaddr занимает байты 24-27 заголовка
+				data := packet.Data
+				if relay != nil && len(data) >= 28 {
+					data = append([]byte(nil), data...)
+					copy(data[24:28], relay)
+				}
+				if _, err := conn.WriteToUDP(data, addr); err != nil {
+					conn.Close()
+					return err
+				}
+			}
+			elapsed := time.Since(started)
+
+			time.Sleep(wait)
+			conn.Close()
+			counts := replies()
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Replayed %d requests to %v in %v (%d other packets skipped)\n",
+				len(requests), addr, elapsed.Round(time.Millisecond), len(packets)-len(requests))
+			printReplyCounts(out, counts)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&target, "server", "s", "127.0.0.1", "server address, host[:port]")
+	flags.StringVar(&giaddr, "giaddr", "", "replace the relay address in requests so replies come back to this command")
+	flags.Float64Var(&speed, "speed", 1, "replay speed relative to the recording (0 - as fast as possible)")
+	flags.DurationVar(&wait, "wait", time.Second, "time to wait for replies after the last request")
+
+	return cmd
+}
+
+// replayTarget разбирает адрес сервера, по умолчанию порт 67
+func replayTarget(target string) (*net.UDPAddr, error) {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, strconv.Itoa(server.BOOTP_PORT))
+	}
+	addr, err := net.ResolveUDPAddr("udp4", target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --server %q: %v", target, err)
+	}
+	return addr, nil
+}
+
+// replayRequests оставляет только запросы клиентов: захват сервера
+// содержит и его ответы
+func replayRequests(packets []server.CapturedPacket) []server.CapturedPacket {
+	var requests []server.CapturedPacket
+	for _, packet := range packets {
+		if len(packet.Data) > 0 && packet.Data[0] == server.BOOTPRequest {
+			requests = append(requests, packet)
+		}
+	}
+	return requests
+}
+
+// collectReplies считает ответы сервера по типам, пока conn не закрыт.
+// Возвращенная функция дожидается завершения и отдает результат.
+func collectReplies(conn *net.UDPConn) func() map[uint8]int {
+	counts := make(map[uint8]int)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buffer := make([]byte, 65535)
+		for {
+			n, err := conn.Read(buffer)
+			if err != nil {
+				return
+			}
+			packet, err := server.DecodePacket(buffer[:n])
+			if err != nil || packet.Header.Op != server.BOOTPReply {
+				continue
+			}
+			var msgType uint8
+			if value := packet.Options[server.OptionMessageType]; len(value) == 1 {
+				msgType = value[0]
+			}
+			counts[msgType]++
+		}
+	}()
+	return func() map[uint8]int {
+		wg.Wait()
+		return counts
+	}
+}
+
+// printReplyCounts выводит число ответов каждого типа
+func printReplyCounts(out io.Writer, counts map[uint8]int) {
+	total := 0
+	types := make([]int, 0, len(counts))
+	for msgType, count := range counts {
+		total += count
+		types = append(types, int(msgType))
+	}
+	sort.Ints(types)
+
+	parts := make([]string, 0, len(types))
+	for _, msgType := range types {
+		name, ok := replyTypeNames[uint8(msgType)]
+		if !ok {
+			name = fmt.Sprintf("type %d", msgType)
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", name, counts[uint8(msgType)]))
+	}
+	if len(parts) == 0 {
+		fmt.Fprintf(out, "Replies: 0\n")
+		return
+	}
+	fmt.Fprintf(out, "Replies: %d (%s)\n", total, strings.Join(parts, ", "))
+}
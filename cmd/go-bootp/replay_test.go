@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// writeCapture записывает DISCOVER от клиентов с номерами macs и ответ
+// сервера, который replay должен пропустить
+func writeCapture(t *testing.T, macs ...byte) string {
+	path := filepath.Join(t.TempDir(), "field.pcap")
+	writer, err := server.NewPcapWriter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &net.UDPAddr{IP: net.IPv4zero, Port: server.BOOTP_CLIENT_PORT}
+	local := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: server.BOOTP_PORT}
+	for _, mac := range macs {
+		header := server.BOOTPHeader{Op: server.BOOTPRequest, Htype: server.HTYPE_ETHER, Hlen: 6, Xid: uint32(mac)}
+		header.Chaddr[0], header.Chaddr[5] = 0x02, mac
+		copy(header.Magic[:], []byte{99, 130, 83, 99})
+		data := server.EncodeReply(&header, map[uint8][]byte{server.OptionMessageType: {server.DHCPDiscover}})
+		writer.Dump(server.CaptureReceived, client, local, data)
+		data[0] = server.BOOTPReply
+		writer.Dump(server.CaptureSent, local, client, data)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplayCommand(t *testing.T) {
+	srv, err := server.NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}}},
+		},
+	}, server.WithListenAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	capture := writeCapture(t, 1, 2, 3)
+	out, err := runCommand(t, "replay", capture, "--server", srv.LocalAddr().String(),
+		"--giaddr", "192.168.1.1", "--speed", "0", "--wait", "300ms")
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if !strings.Contains(out, "Replayed 3 requests") || !strings.Contains(out, "3 other packets skipped") {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if !strings.Contains(out, "Replies: 3 (OFFER 3)") {
+		t.Errorf("Expected three offers, got %q", out)
+	}
+
+	if _, err := runCommand(t, "replay", capture, "--giaddr", "relay"); err == nil {
+		t.Error("Expected error for invalid --giaddr")
+	}
+	if _, err := runCommand(t, "replay", filepath.Join(t.TempDir(), "missing.pcap")); err == nil {
+		t.Error("Expected error for missing capture")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// newReservationsCommand управляет резервированиями работающего сервера
+func newReservationsCommand() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "reservations",
+		Short: "Manage host reservations of the running server",
+	}
+	cmd.PersistentFlags().StringVarP(&socket, "socket", "s", defaultControlSocket, "path to the control socket")
+
+	var asJSON bool
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List host reservations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var reservations []server.Reservation
+			if err := callDaemon(socket, "reservations.list", nil, &reservations); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(reservations)
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tIP\tMAC\tCLIENT-ID\tSUBNET\tMANAGED")
+			for _, r := range reservations {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\n",
+					r.Name, r.IP, dash(r.MAC), dash(r.ClientID), dash(r.Subnet), r.Managed)
+			}
+			return w.Flush()
+		},
+	}
+	list.Flags().BoolVar(&asJSON, "json", false, "print reservations as JSON")
+
+	var params server.Reservation
+	add := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add or replace a host reservation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.Name = args[0]
+			var r server.Reservation
+			if err := callDaemon(socket, "reservations.add", params, &r); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Added reservation %s: %s\n", r.Name, r.IP)
+			return nil
+		},
+	}
+	add.Flags().StringVar(&params.MAC, "mac", "", "hardware address of the client")
+	add.Flags().StringVar(&params.ClientID, "client-id", "", "client identifier (option 61)")
+	add.Flags().StringVar(&params.IP, "ip", "", "reserved IPv4 address")
+	_ = add.MarkFlagRequired("ip")
+
+	del := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a host reservation added at runtime",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := callDaemon(socket, "reservations.delete", reservationParams{Name: args[0]}, nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Deleted reservation %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(list, add, del)
+
+	return cmd
+}
+
+// dash заменяет пустое значение прочерком для табличного вывода
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
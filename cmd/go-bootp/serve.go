@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/agentx"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/grpcapi"
+	"github.com/user/go-bootp/internal/httpapi"
+	"github.com/user/go-bootp/internal/leasestore"
+	"github.com/user/go-bootp/internal/logging"
+	"github.com/user/go-bootp/internal/publisher"
+	"github.com/user/go-bootp/internal/server"
+	"github.com/user/go-bootp/internal/systemd"
+)
+
+// Пути к конфигурации по умолчанию в порядке поиска
+var defaultConfigPaths = []string{
+	"/etc/dhcp/dhcpd.conf",
+	"configs/dhcpd.conf",
+}
+
+// serveOptions параметры команды serve
+type serveOptions struct {
+	configPath    string
+	set           []string
+	interfaces    []string
+	logLevel      string
+	controlSocket string
+	dryRun        bool
+}
+
+// newServeCommand запускает сервер
+func newServeCommand() *cobra.Command {
+	opts := &serveOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the BOOTP/DHCP server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.dryRun {
+				return runDryRun(cmd.OutOrStdout(), opts)
+			}
+			return runServe(opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.configPath, "config", "c", os.Getenv(envConfig), "path to dhcpd.conf (default /etc/dhcp/dhcpd.conf or configs/dhcpd.conf)")
+	flags.StringArrayVar(&opts.set, "set", nil, "override a global option, name=value (repeatable)")
+	flags.StringSliceVarP(&opts.interfaces, "interface", "i", envList(envInterfaces), "interfaces or patterns such as net* to listen on (default all)")
+	flags.StringVar(&opts.logLevel, "log-level", envString(envLogLevel, "info"), "log level: debug, info, warn, error")
+	flags.StringVar(&opts.controlSocket, "control-socket", defaultControlSocket, "path to the control socket (empty to disable)")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "load configuration, print the effective runtime model as JSON and exit")
+
+	return cmd
+}
+
+// resolveConfigPath возвращает путь к конфигурации, заданный явно или найденный по умолчанию.
+// Без пути используется текст из GO_BOOTP_CONFIG_DATA, если он задан (см. parseConfigFile).
+func resolveConfigPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	if _, ok := os.LookupEnv(envConfigData); ok {
+		return envConfigPath, nil
+	}
+	for _, candidate := range defaultConfigPaths {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no configuration file found, use --config or %s", envConfigData)
+}
+
+// loadConfig читает и проверяет конфигурацию, не создавая сервер
+func loadConfig(source configSource) (*config.DHCPConfig, error) {
+	path, err := resolveConfigPath(source.path)
+	if err != nil {
+		return nil, err
+	}
+	source.path = path
+
+	// Ошибки разбора уже содержат имя файла и положение в нем
+	cfg, err := source.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if _, err := grpcapi.ConfigFromOptions(cfg.GlobalOptions); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if _, err := httpapi.ConfigFromOptions(cfg.GlobalOptions); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if _, err := logging.ConfigFromOptions(cfg.GlobalOptions); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if _, err := agentx.ConfigFromOptions(cfg.GlobalOptions); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if _, err := publisher.ConfigFromOptions(cfg.GlobalOptions); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+	if _, err := leasestore.ConfigFromOptions(cfg.GlobalOptions); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+// loadServer читает конфигурацию и создает сервер
+func loadServer(source configSource) (*config.DHCPConfig, *server.BOOTPServer, error) {
+	cfg, err := loadConfig(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	srv, err := newServer(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, srv, nil
+}
+
+// newServer создает сервер по проверенной конфигурации
+func newServer(cfg *config.DHCPConfig, opts ...server.Option) (*server.BOOTPServer, error) {
+	srv, err := server.NewBOOTPServer(cfg, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	return srv, nil
+}
+
+// newStoredServer создает сервер с хранилищем аренд lease-store, если оно
+// задано. Хранилище закрывает возвращаемая функция после остановки
+// сервера.
+func newStoredServer(cfg *config.DHCPConfig, opts ...server.Option) (*server.BOOTPServer, func(), error) {
+	storeConfig, err := leasestore.ConfigFromOptions(cfg.GlobalOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if storeConfig == nil {
+		srv, err := newServer(cfg, opts...)
+		return srv, func() {}, err
+	}
+
+	store, err := leasestore.Open(storeConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeStore := func() {
+		if err := store.Close(); err != nil {
+			logrus.Errorf("Failed to close lease store %s: %v", storeConfig, err)
+		}
+	}
+	srv, err := newServer(cfg, append(opts, server.WithLeaseStore(store))...)
+	if err != nil {
+		closeStore()
+		return nil, nil, err
+	}
+	return srv, closeStore, nil
+}
+
+func runServe(opts *serveOptions) error {
+	d, err := newDaemon(opts)
+	if err != nil {
+		return err
+	}
+	defer d.Stop()
+	if err := d.Start(); err != nil {
+		return err
+	}
+
+	notify(systemd.Ready)
+
+	// SIGHUP перечитывает конфигурацию, SIGINT и SIGTERM завершают работу
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			if _, err := d.Reload(); err != nil {
+				logrus.Errorf("Reload failed: %v", err)
+			}
+			continue
+		}
+		logrus.Infof("Received %v, shutting down", sig)
+		notify(systemd.Stopping)
+		break
+	}
+
+	return nil
+}
+
+// reloadConfig перечитывает конфигурацию и применяет ее к работающему
+// серверу и логическим серверам
+func reloadConfig(srv *server.BOOTPServer, source configSource, instances []*instance) (*config.DHCPConfig, error) {
+	notify(systemd.Reloading)
+	defer notify(systemd.Ready)
+
+	// Ошибки разбора уже содержат имя файла и положение в нем
+	cfg, err := source.parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.Reload(cfg); err != nil {
+		return nil, err
+	}
+	if err := reloadInstances(cfg, instances); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// notify сообщает systemd о состоянии сервиса
+func notify(state string) {
+	if err := systemd.Notify(state); err != nil {
+		logrus.Warnf("Failed to notify systemd (%s): %v", state, err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// newStatsCommand выводит статистику работающего сервера
+func newStatsCommand() *cobra.Command {
+	var socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show pool utilization and request counters of the running server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var stats server.Stats
+			if err := callDaemon(socket, "server.stats", nil, &stats); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(stats)
+			}
+
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SUBNET\tSIZE\tACTIVE\tEXPIRED\tSTATIC\tABANDONED\tFREE\tUSED")
+			for _, u := range stats.Subnets {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.1f%%\n",
+					u.ID, u.Size, u.Active, u.Expired, u.Static, u.Abandoned, u.Free, u.Utilization)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			c := stats.Counters
+			fmt.Fprintf(out, "\nSince %s:\n", stats.Started.Format(time.RFC3339))
+			fmt.Fprintf(out, "  requests %d, offers %d, acks %d, naks %d, bootp replies %d, ignored %d, retransmits %d\n",
+				c.Requests, c.Offers, c.Acks, c.Naks, c.BOOTPReplies, c.Ignored, c.Retransmits)
+			fmt.Fprintf(out, "  allocations %d, renewals %d, releases %d, expirations %d\n",
+				c.Allocations, c.Renewals, c.Releases, c.Expirations)
+			if c.Dropped > 0 {
+				fmt.Fprintf(out, "  dropped by kernel %d (receive buffer overflow)\n", c.Dropped)
+			}
+			if c.Deferred > 0 {
+				fmt.Fprintf(out, "  deferred %d (secs below min-secs)\n", c.Deferred)
+			}
+			if c.UnknownRelays > 0 {
+				fmt.Fprintf(out, "  unknown relays %d (giaddr not in allowed-relays)\n", c.UnknownRelays)
+			}
+			if c.PoolAlerts > 0 {
+				fmt.Fprintf(out, "  pool alerts %d (free addresses below pool-low-watermark)\n", c.PoolAlerts)
+			}
+			if c.Timeouts > 0 {
+				fmt.Fprintf(out, "  timeouts %d (processing exceeded request-timeout)\n", c.Timeouts)
+			}
+
+			if len(stats.VendorClasses) > 0 {
+				fmt.Fprintln(out)
+				w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VENDOR CLASS\tREQUESTS\tOFFERS")
+				for _, v := range stats.VendorClasses {
+					fmt.Fprintf(w, "%s\t%d\t%d\n", v.Class, v.Requests, v.Offers)
+				}
+				return w.Flush()
+			}
+			return nil
+		},
+	}
+
+	addSocketFlag(cmd, &socket)
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print statistics as JSON")
+
+	return cmd
+}
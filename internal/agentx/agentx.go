@@ -0,0 +1,351 @@
+// Package agentx реализует подагент SNMP (AgentX, RFC 2741), через
+// который мастер-агент (например, snmpd из Net-SNMP) отдает статистику
+// аренд и пулов системам мониторинга, опрашивающим SNMP.
+package agentx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Параметры по умолчанию
+const (
+	defaultRoot    = "1.3.6.1.4.1.8072.9999.9999.67" // Поддерево netSnmpPlaypen
+	defaultPort    = "705"
+	requestTimeout = 10 * time.Second
+	retryInterval  = 5 * time.Second
+	reasonShutdown = 5 // Причина закрытия сессии в Close-PDU
+)
+
+// Config параметры подагента
+type Config struct {
+	Network string // unix или tcp
+	Address string // Путь к сокету или адрес мастер-агента
+	Root    oid    // Поддерево объектов сервера
+}
+
+func init() {
+	config.RegisterGlobalOptions("snmp-agentx", "snmp-agentx-root")
+}
+
+// ConfigFromOptions читает параметры подагента из глобальных опций
+// конфигурации. Адрес мастер-агента задается, как agentXSocket в snmpd:
+//
+//	snmp-agentx "/var/agentx/master";   # или "tcp:127.0.0.1:705"
+//	snmp-agentx-root "1.3.6.1.4.1.8072.9999.9999.67";
+//
+// Возвращает nil, если опция snmp-agentx не задана.
+func ConfigFromOptions(options map[string]string) (*Config, error) {
+	value := strings.Trim(options["snmp-agentx"], "\"")
+	if value == "" {
+		return nil, nil
+	}
+
+	cfg := &Config{Network: "unix", Address: strings.TrimPrefix(value, "unix:")}
+	if address := strings.TrimPrefix(value, "tcp:"); address != value {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, defaultPort)
+		}
+		cfg.Network, cfg.Address = "tcp", address
+	} else if !strings.HasPrefix(cfg.Address, "/") {
+		return nil, fmt.Errorf("invalid snmp-agentx %q, expected socket path or tcp:host:port", value)
+	}
+
+	root := strings.Trim(options["snmp-agentx-root"], "\"")
+	if root == "" {
+		root = defaultRoot
+	}
+	var err error
+	if cfg.Root, err = parseOID(root); err != nil {
+		return nil, fmt.Errorf("snmp-agentx-root: %v", err)
+	}
+	return cfg, nil
+}
+
+// Subagent подагент AgentX. Соединение с мастер-агентом
+// восстанавливается, если он перезапущен или еще не запущен.
+type Subagent struct {
+	config *Config
+	stats  func() server.Stats
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	stop    chan struct{}
+	done    chan struct{}
+	packet  uint32
+	session uint32
+}
+
+// NewSubagent создает подагент для BOOTP сервера
+func NewSubagent(bootp *server.BOOTPServer, cfg *Config) *Subagent {
+	return &Subagent{config: cfg, stats: bootp.Stats}
+}
+
+// Start подключается к мастер-агенту в фоне
+func (a *Subagent) Start() {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.run()
+}
+
+// Stop закрывает сессию и соединение с мастер-агентом
+func (a *Subagent) Stop() {
+	close(a.stop)
+	a.mutex.Lock()
+	if a.conn != nil {
+		a.send(&pdu{typ: pduClose, payload: []byte{reasonShutdown, 0, 0, 0}})
+		a.conn.Close()
+	}
+	a.mutex.Unlock()
+	<-a.done
+}
+
+// run поддерживает сессию с мастер-агентом до Stop
+func (a *Subagent) run() {
+	defer close(a.done)
+	for {
+		err := a.serve()
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+		logrus.Warnf("AgentX session with %s lost: %v, reconnecting in %s", a.config.Address, err, retryInterval)
+
+		select {
+		case <-a.stop:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// serve открывает сессию, регистрирует поддерево и отвечает на запросы
+// мастер-агента до разрыва соединения
+func (a *Subagent) serve() error {
+	conn, err := net.DialTimeout(a.config.Network, a.config.Address, requestTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	a.mutex.Lock()
+	select {
+	case <-a.stop:
+		a.mutex.Unlock()
+		return errors.New("subagent stopped")
+	default:
+	}
+	a.conn, a.session = conn, 0
+	a.mutex.Unlock()
+	defer func() {
+		a.mutex.Lock()
+		a.conn = nil
+		a.mutex.Unlock()
+	}()
+
+	// Open-PDU: таймаут по умолчанию, идентификатор и описание подагента
+	open := encoder{order: networkOrder}
+	open.uint32(0)
+	open.oid(a.config.Root, false)
+	open.octetString([]byte("go-bootp"))
+	response, err := a.request(conn, &pdu{typ: pduOpen, payload: open.data})
+	if err != nil {
+		return fmt.Errorf("open session: %v", err)
+	}
+	a.mutex.Lock()
+	a.session = response.session
+	a.mutex.Unlock()
+
+	// Register-PDU: приоритет по умолчанию (127), без диапазона
+	register := encoder{order: networkOrder}
+	register.uint8(0)
+	register.uint8(127)
+	register.uint8(0)
+	register.uint8(0)
+	register.oid(a.config.Root, false)
+	if _, err := a.request(conn, &pdu{typ: pduRegister, session: a.session, payload: register.data}); err != nil {
+		return fmt.Errorf("register %s: %v", a.config.Root, err)
+	}
+	logrus.Infof("AgentX subagent registered %s with %s", a.config.Root, a.config.Address)
+
+	for {
+		request, err := readPDU(conn)
+		if err != nil {
+			return err
+		}
+		switch request.typ {
+		case pduClose:
+			return errors.New("session closed by master agent")
+		case pduResponse, pduCleanupSet:
+			continue
+		}
+		if response := a.handle(request); response != nil {
+			if err := a.reply(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// networkOrder порядок байт PDU подагента (флаг flagNetworkByteOrder)
+var networkOrder = binary.BigEndian
+
+// send отправляет PDU подагента. Вызывается с захваченным мьютексом.
+func (a *Subagent) send(p *pdu) error {
+	a.packet++
+	p.flags |= flagNetworkByteOrder
+	p.session, p.packet = a.session, a.packet
+	a.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	return writePDU(a.conn, p)
+}
+
+// reply отправляет ответ на запрос мастер-агента
+func (a *Subagent) reply(p *pdu) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	return writePDU(a.conn, p)
+}
+
+// request отправляет PDU и ждет ответ мастер-агента с кодом ошибки
+func (a *Subagent) request(conn net.Conn, p *pdu) (*pdu, error) {
+	a.mutex.Lock()
+	err := a.send(p)
+	a.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		response, err := readPDU(conn)
+		if err != nil {
+			return nil, err
+		}
+		if response.typ != pduResponse || response.packet != p.packet {
+			continue
+		}
+		d := decoder{order: response.order(), data: response.payload}
+		d.uint32() // sysUpTime
+		if code := d.uint16(); d.err == nil && code != errNoError {
+			return nil, fmt.Errorf("master agent error %d", code)
+		}
+		return response, d.err
+	}
+}
+
+// handle отвечает на запрос мастер-агента. Объекты доступны только для
+// чтения. Возвращает nil, если ответ не нужен.
+func (a *Subagent) handle(request *pdu) *pdu {
+	response := &pdu{
+		typ:         pduResponse,
+		flags:       request.flags & flagNetworkByteOrder,
+		session:     request.session,
+		transaction: request.transaction,
+		packet:      request.packet,
+	}
+	e := encoder{order: request.order()}
+	e.uint32(0) // sysUpTime заполняет мастер-агент
+
+	switch request.typ {
+	case pduGet, pduGetNext, pduGetBulk:
+		varbinds, err := a.lookup(request)
+		if err != nil {
+			logrus.Debugf("Malformed AgentX request: %v", err)
+			return nil
+		}
+		e.uint16(errNoError)
+		e.uint16(0)
+		for _, v := range varbinds {
+			e.varbind(v)
+		}
+	case pduTestSet:
+		e.uint16(errNotWritable)
+		e.uint16(1)
+	case pduCommitSet:
+		e.uint16(errCommitFailed)
+		e.uint16(0)
+	case pduUndoSet:
+		e.uint16(errUndoFailed)
+		e.uint16(0)
+	default:
+		return nil
+	}
+	response.payload = e.data
+	return response
+}
+
+// searchRange диапазон поиска запроса Get/GetNext/GetBulk
+type searchRange struct {
+	start   oid
+	include bool
+	end     oid
+}
+
+// lookup выполняет запрос чтения по снимку статистики сервера
+func (a *Subagent) lookup(request *pdu) ([]varbind, error) {
+	d := decoder{order: request.order(), data: request.payload}
+	if request.flags&flagNonDefaultContext != 0 {
+		d.octetString()
+	}
+	var nonRepeaters, repetitions int
+	if request.typ == pduGetBulk {
+		nonRepeaters, repetitions = int(d.uint16()), int(d.uint16())
+	}
+	var ranges []searchRange
+	for len(d.data) > 0 && d.err == nil {
+		var r searchRange
+		r.start, r.include = d.oid()
+		r.end, _ = d.oid()
+		ranges = append(ranges, r)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	view := buildView(a.config.Root, a.stats())
+	var varbinds []varbind
+	switch request.typ {
+	case pduGet:
+		for _, r := range ranges {
+			varbinds = append(varbinds, view.get(r.start))
+		}
+	case pduGetNext:
+		for _, r := range ranges {
+			varbinds = append(varbinds, view.next(r.start, r.include, r.end))
+		}
+	case pduGetBulk:
+		if nonRepeaters > len(ranges) {
+			nonRepeaters = len(ranges)
+		}
+		for _, r := range ranges[:nonRepeaters] {
+			varbinds = append(varbinds, view.next(r.start, r.include, r.end))
+		}
+		repeaters := ranges[nonRepeaters:]
+		for i := 0; i < repetitions && len(repeaters) > 0; i++ {
+			finished := true
+			for j := range repeaters {
+				v := view.next(repeaters[j].start, repeaters[j].include, repeaters[j].end)
+				varbinds = append(varbinds, v)
+				repeaters[j].start, repeaters[j].include = v.name, false
+				finished = finished && v.typ == typeEndOfMIBView
+			}
+			if finished {
+				break
+			}
+		}
+	}
+	return varbinds, nil
+}
@@ -0,0 +1,245 @@
+package agentx
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+func TestConfigFromOptions(t *testing.T) {
+	if cfg, err := ConfigFromOptions(map[string]string{}); cfg != nil || err != nil {
+		t.Errorf("Expected no subagent, got %+v (%v)", cfg, err)
+	}
+
+	cfg, err := ConfigFromOptions(map[string]string{"snmp-agentx": `"/var/agentx/master"`})
+	if err != nil || cfg.Network != "unix" || cfg.Address != "/var/agentx/master" || cfg.Root.String() != defaultRoot {
+		t.Errorf("Unexpected config %+v (%v)", cfg, err)
+	}
+	cfg, err = ConfigFromOptions(map[string]string{"snmp-agentx": `"tcp:localhost"`, "snmp-agentx-root": `".1.3.6.1.4.1.99999"`})
+	if err != nil || cfg.Network != "tcp" || cfg.Address != "localhost:705" || cfg.Root.String() != "1.3.6.1.4.1.99999" {
+		t.Errorf("Unexpected config %+v (%v)", cfg, err)
+	}
+
+	for _, options := range []map[string]string{
+		{"snmp-agentx": "localhost:705"},
+		{"snmp-agentx": "/var/agentx/master", "snmp-agentx-root": "1.3.x"},
+		{"snmp-agentx": "/var/agentx/master", "snmp-agentx-root": "1"},
+	} {
+		if _, err := ConfigFromOptions(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
+
+func TestOID(t *testing.T) {
+	a, _ := parseOID("1.3.6.1.4.1.2")
+	b, _ := parseOID("1.3.6.1.4.1.10")
+	if a.compare(b) >= 0 || b.compare(a) <= 0 || a.compare(a) != 0 || a[:6].compare(a) >= 0 {
+		t.Error("Unexpected OID ordering")
+	}
+	if !b.append(1).hasPrefix(b) || a.hasPrefix(b) {
+		t.Error("Unexpected OID prefix check")
+	}
+
+	// Префикс 1.3.6.1.N сокращается при кодировании
+	e := encoder{order: binary.LittleEndian}
+	e.oid(a, true)
+	if len(e.data) != 4+2*4 || e.data[0] != 2 || e.data[1] != 4 || e.data[2] != 1 {
+		t.Errorf("Unexpected encoding %v", e.data)
+	}
+	d := decoder{order: binary.LittleEndian, data: e.data}
+	if decoded, include := d.oid(); decoded.compare(a) != 0 || !include || d.err != nil {
+		t.Errorf("Expected %s, got %s (%v)", a, decoded, d.err)
+	}
+}
+
+// testMaster мастер-агент, принимающий одного подагента
+type testMaster struct {
+	t     *testing.T
+	conn  net.Conn
+	order binary.ByteOrder
+}
+
+func (m *testMaster) read() *pdu {
+	m.t.Helper()
+	m.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	p, err := readPDU(m.conn)
+	if err != nil {
+		m.t.Fatalf("Failed to read PDU: %v", err)
+	}
+	return p
+}
+
+// respond подтверждает PDU подагента
+func (m *testMaster) respond(request *pdu, session uint32) {
+	m.t.Helper()
+	payload := make([]byte, 8)
+	writePDU(m.conn, &pdu{typ: pduResponse, flags: flagNetworkByteOrder, session: session, packet: request.packet, payload: payload})
+}
+
+// query отправляет запрос в порядке байт мастер-агента и возвращает
+// код ошибки и переменные ответа
+func (m *testMaster) query(typ uint8, prefix []uint16, ranges ...oid) (uint16, []varbind) {
+	m.t.Helper()
+	e := encoder{order: m.order}
+	for _, value := range prefix {
+		e.uint16(value)
+	}
+	for _, r := range ranges {
+		e.oid(r, false)
+		e.oid(nil, false)
+	}
+	flags := uint8(0)
+	if m.order == binary.BigEndian {
+		flags = flagNetworkByteOrder
+	}
+	if err := writePDU(m.conn, &pdu{typ: typ, flags: flags, session: 7, transaction: 1, packet: 99, payload: e.data}); err != nil {
+		m.t.Fatal(err)
+	}
+
+	response := m.read()
+	if response.typ != pduResponse || response.packet != 99 || response.session != 7 || response.flags != flags {
+		m.t.Fatalf("Unexpected response header %+v", response)
+	}
+	d := decoder{order: m.order, data: response.payload}
+	d.uint32()
+	code := d.uint16()
+	d.uint16()
+	var varbinds []varbind
+	for len(d.data) > 0 && d.err == nil {
+		v := varbind{typ: d.uint16()}
+		d.uint16()
+		v.name, _ = d.oid()
+		switch v.typ {
+		case typeInteger, typeCounter32, typeGauge32:
+			v.value = d.uint32()
+		case typeOctetString, typeIPAddress:
+			v.bytes = d.octetString()
+		}
+		varbinds = append(varbinds, v)
+	}
+	if d.err != nil {
+		m.t.Fatal(d.err)
+	}
+	return code, varbinds
+}
+
+func TestSubagent(t *testing.T) {
+	bootp, err := server.NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.199"}}},
+			{Network: config.MustParseNetwork("10.0.0.0/24"), Ranges: []config.Range{{Start: "10.0.0.10", End: "10.0.0.19"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	cfg, err := ConfigFromOptions(map[string]string{"snmp-agentx": "tcp:" + listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := NewSubagent(bootp, cfg)
+	agent.Start()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	master := &testMaster{t: t, conn: conn, order: binary.LittleEndian}
+
+	// Открытие сессии и регистрация поддерева
+	open := master.read()
+	if open.typ != pduOpen || open.flags&flagNetworkByteOrder == 0 {
+		t.Fatalf("Expected Open-PDU, got %+v", open)
+	}
+	master.respond(open, 7)
+	register := master.read()
+	if register.typ != pduRegister || register.session != 7 {
+		t.Fatalf("Expected Register-PDU for session 7, got %+v", register)
+	}
+	d := decoder{order: binary.BigEndian, data: register.payload}
+	d.uint32()
+	if subtree, _ := d.oid(); subtree.compare(cfg.Root) != 0 {
+		t.Errorf("Expected registration of %s, got %s", cfg.Root, subtree)
+	}
+	master.respond(register, 7)
+
+	// Get: счетчик запросов и отсутствующий объект
+	root := cfg.Root
+	code, varbinds := master.query(pduGet, nil, root.append(1, 1, 0), root.append(1, 99, 0))
+	if code != errNoError || len(varbinds) != 2 || varbinds[0].typ != typeCounter32 || varbinds[1].typ != typeNoSuchObject {
+		t.Errorf("Unexpected Get response %d %+v", code, varbinds)
+	}
+
+	// GetNext с корня возвращает первый счетчик
+	_, varbinds = master.query(pduGetNext, nil, root)
+	if len(varbinds) != 1 || varbinds[0].name.compare(root.append(1, 1, 0)) != 0 {
+		t.Errorf("Unexpected GetNext response %+v", varbinds)
+	}
+
+	// GetBulk по таблице подсетей в сетевом порядке байт
+	master.order = binary.BigEndian
+	_, varbinds = master.query(pduGetBulk, []uint16{0, 4}, root.append(2, 1, 2))
+	if len(varbinds) != 4 {
+		t.Fatalf("Expected 4 varbinds, got %+v", varbinds)
+	}
+	if net.IP(varbinds[0].bytes).String() != "192.168.1.0" || net.IP(varbinds[1].bytes).String() != "10.0.0.0" {
+		t.Errorf("Unexpected subnet addresses %+v", varbinds[:2])
+	}
+	if net.IP(varbinds[2].bytes).String() != "255.255.255.0" {
+		t.Errorf("Unexpected netmask %+v", varbinds[2])
+	}
+	_, varbinds = master.query(pduGet, nil, root.append(2, 1, 7, 1), root.append(2, 1, 9, 2), root.append(2, 1, 10, 1))
+	if varbinds[0].typ != typeGauge32 || varbinds[0].value != 100 || varbinds[1].value != 2 || string(varbinds[2].bytes) != "192.168.1.0/24" {
+		t.Errorf("Unexpected subnet row %+v", varbinds)
+	}
+
+	// Конец поддерева
+	_, varbinds = master.query(pduGetNext, nil, root.append(3))
+	if len(varbinds) != 1 || varbinds[0].typ != typeEndOfMIBView {
+		t.Errorf("Expected endOfMibView, got %+v", varbinds)
+	}
+
+	// Объекты доступны только для чтения
+	if code, _ := master.query(pduTestSet, nil, root.append(1, 1, 0)); code != errNotWritable {
+		t.Errorf("Expected notWritable, got %d", code)
+	}
+
+	// Остановка закрывает сессию
+	agent.Stop()
+	if closing := master.read(); closing.typ != pduClose || closing.session != 7 {
+		t.Errorf("Expected Close-PDU, got %+v", closing)
+	}
+}
+
+func TestSubagentReconnects(t *testing.T) {
+	bootp, err := server.NewBOOTPServer(&config.DHCPConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := NewSubagent(bootp, &Config{Network: "unix", Address: t.TempDir() + "/missing", Root: oid{1, 3, 6, 1, 4, 1, 99999}})
+	agent.Start()
+
+	// Остановка не ждет следующей попытки подключения
+	stopped := make(chan struct{})
+	go func() {
+		agent.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked while waiting to reconnect")
+	}
+}
@@ -0,0 +1,127 @@
+package agentx
+
+import (
+	"math"
+	"net"
+	"sort"
+
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Объекты повторяют счетчики и таблицу пулов черновика DHCP Server MIB
+// (dhcpv4ServerCounters, dhcpv4ServerSubnetTable) под корнем root:
+//
+//	root.1.N.0    счетчики (Counter32)
+//	root.2.1.C.I  таблица подсетей: столбец C, строка I (с 1)
+
+// Счетчики root.1
+var counterObjects = []struct {
+	subid uint32
+	value func(c *server.Counters) uint64
+}{
+	{1, func(c *server.Counters) uint64 { return c.Requests }},
+	{2, func(c *server.Counters) uint64 { return c.Offers }},
+	{3, func(c *server.Counters) uint64 { return c.Acks }},
+	{4, func(c *server.Counters) uint64 { return c.Naks }},
+	{5, func(c *server.Counters) uint64 { return c.BOOTPReplies }},
+	{6, func(c *server.Counters) uint64 { return c.Ignored }},
+	{7, func(c *server.Counters) uint64 { return c.Allocations }},
+	{8, func(c *server.Counters) uint64 { return c.Renewals }},
+	{9, func(c *server.Counters) uint64 { return c.Releases }},
+	{10, func(c *server.Counters) uint64 { return c.Expirations }},
+	{11, func(c *server.Counters) uint64 { return c.Conflicts }},
+	{12, func(c *server.Counters) uint64 { return c.Retransmits }},
+	{13, func(c *server.Counters) uint64 { return c.Dropped }},
+	{14, func(c *server.Counters) uint64 { return c.Timeouts }},
+}
+
+// Столбцы таблицы подсетей root.2.1
+var subnetColumns = []struct {
+	subid uint32
+	value func(index int, u *server.SubnetUsage) varbind
+}{
+	{1, func(index int, u *server.SubnetUsage) varbind { return integer(index) }},
+	{2, func(index int, u *server.SubnetUsage) varbind { return ipAddress(u.Network) }},
+	{3, func(index int, u *server.SubnetUsage) varbind { return ipAddress(u.Netmask) }},
+	{4, func(index int, u *server.SubnetUsage) varbind { return gauge(u.Size) }},
+	{5, func(index int, u *server.SubnetUsage) varbind { return gauge(u.Active) }},
+	{6, func(index int, u *server.SubnetUsage) varbind { return gauge(u.Static) }},
+	{7, func(index int, u *server.SubnetUsage) varbind { return gauge(u.Free) }},
+	{8, func(index int, u *server.SubnetUsage) varbind { return gauge(int(math.Round(u.Utilization))) }},
+	{9, func(index int, u *server.SubnetUsage) varbind { return truthValue(u.LowWater) }},
+	{10, func(index int, u *server.SubnetUsage) varbind { return octetString(u.ID) }},
+}
+
+func integer(value int) varbind {
+	return varbind{typ: typeInteger, value: uint32(int32(value))}
+}
+
+func gauge(value int) varbind {
+	if value < 0 {
+		value = 0
+	}
+	return varbind{typ: typeGauge32, value: uint32(value)}
+}
+
+// truthValue значение TruthValue из SNMPv2-TC: 1 - true, 2 - false
+func truthValue(value bool) varbind {
+	if value {
+		return integer(1)
+	}
+	return integer(2)
+}
+
+func octetString(value string) varbind {
+	return varbind{typ: typeOctetString, bytes: []byte(value)}
+}
+
+func ipAddress(value string) varbind {
+	ip := net.ParseIP(value).To4()
+	if ip == nil {
+		ip = net.IPv4zero.To4()
+	}
+	return varbind{typ: typeIPAddress, bytes: ip}
+}
+
+// mibView снимок объектов, упорядоченный по идентификаторам
+type mibView []varbind
+
+// buildView снимает значения объектов со статистики сервера
+func buildView(root oid, stats server.Stats) mibView {
+	var view mibView
+	for _, object := range counterObjects {
+		// Counter32 переполняется, как принято для счетчиков SNMP
+		view = append(view, varbind{name: root.append(1, object.subid, 0), typ: typeCounter32, value: uint32(object.value(&stats.Counters))})
+	}
+	for _, column := range subnetColumns {
+		for i := range stats.Subnets {
+			v := column.value(i+1, &stats.Subnets[i])
+			v.name = root.append(2, 1, column.subid, uint32(i+1))
+			view = append(view, v)
+		}
+	}
+	sort.Slice(view, func(i, j int) bool { return view[i].name.compare(view[j].name) < 0 })
+	return view
+}
+
+// get возвращает объект name или noSuchObject
+func (v mibView) get(name oid) varbind {
+	i := sort.Search(len(v), func(i int) bool { return v[i].name.compare(name) >= 0 })
+	if i < len(v) && v[i].name.compare(name) == 0 {
+		return v[i]
+	}
+	return varbind{name: name, typ: typeNoSuchObject}
+}
+
+// next возвращает первый объект после start (или начиная с него, если
+// include) и перед end (пустой end - без ограничения), иначе endOfMibView
+func (v mibView) next(start oid, include bool, end oid) varbind {
+	i := sort.Search(len(v), func(i int) bool {
+		c := v[i].name.compare(start)
+		return c > 0 || (include && c == 0)
+	})
+	if i < len(v) && (len(end) == 0 || v[i].name.compare(end) < 0) {
+		return v[i]
+	}
+	return varbind{name: start, typ: typeEndOfMIBView}
+}
@@ -0,0 +1,275 @@
+package agentx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Типы PDU протокола AgentX (RFC 2741, раздел 6.1)
+const (
+	pduOpen       = 1
+	pduClose      = 2
+	pduRegister   = 3
+	pduGet        = 5
+	pduGetNext    = 6
+	pduGetBulk    = 7
+	pduTestSet    = 8
+	pduCommitSet  = 9
+	pduUndoSet    = 10
+	pduCleanupSet = 11
+	pduResponse   = 18
+)
+
+// Флаги заголовка PDU
+const (
+	flagNonDefaultContext = 0x08
+	flagNetworkByteOrder  = 0x10
+)
+
+// Коды ошибок в Response-PDU
+const (
+	errNoError      = 0
+	errNotWritable  = 17
+	errCommitFailed = 14
+	errUndoFailed   = 15
+)
+
+// Типы значений переменных
+const (
+	typeInteger      = 2
+	typeOctetString  = 4
+	typeIPAddress    = 64
+	typeCounter32    = 65
+	typeGauge32      = 66
+	typeNoSuchObject = 128
+	typeEndOfMIBView = 130
+)
+
+const (
+	headerSize     = 20
+	maxPayloadSize = 1 << 20
+)
+
+// oid идентификатор объекта SNMP
+type oid []uint32
+
+// parseOID разбирает идентификатор вида 1.3.6.1.4.1
+func parseOID(value string) (oid, error) {
+	var id oid
+	for _, part := range strings.Split(strings.TrimPrefix(value, "."), ".") {
+		subid, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", value)
+		}
+		id = append(id, uint32(subid))
+	}
+	if len(id) < 2 || len(id) > 128 {
+		return nil, fmt.Errorf("invalid OID %q", value)
+	}
+	return id, nil
+}
+
+func (o oid) String() string {
+	parts := make([]string, len(o))
+	for i, subid := range o {
+		parts[i] = strconv.FormatUint(uint64(subid), 10)
+	}
+	return strings.Join(parts, ".")
+}
+
+// append возвращает идентификатор o, продолженный subids
+func (o oid) append(subids ...uint32) oid {
+	return append(append(oid(nil), o...), subids...)
+}
+
+// compare сравнивает идентификаторы лексикографически
+func (o oid) compare(other oid) int {
+	for i := 0; i < len(o) && i < len(other); i++ {
+		if o[i] != other[i] {
+			if o[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(o) - len(other)
+}
+
+// hasPrefix проверяет, что o лежит в поддереве prefix
+func (o oid) hasPrefix(prefix oid) bool {
+	return len(o) >= len(prefix) && o[:len(prefix)].compare(prefix) == 0
+}
+
+// pdu сообщение AgentX
+type pdu struct {
+	typ         uint8
+	flags       uint8
+	session     uint32
+	transaction uint32
+	packet      uint32
+	payload     []byte
+}
+
+// order возвращает порядок байт полей PDU
+func (p *pdu) order() binary.ByteOrder {
+	if p.flags&flagNetworkByteOrder != 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// readPDU читает PDU из r
+func readPDU(r io.Reader) (*pdu, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 1 {
+		return nil, fmt.Errorf("unsupported AgentX version %d", header[0])
+	}
+
+	p := &pdu{typ: header[1], flags: header[2]}
+	order := p.order()
+	p.session = order.Uint32(header[4:8])
+	p.transaction = order.Uint32(header[8:12])
+	p.packet = order.Uint32(header[12:16])
+	length := order.Uint32(header[16:20])
+	if length%4 != 0 || length > maxPayloadSize {
+		return nil, fmt.Errorf("invalid AgentX payload length %d", length)
+	}
+	p.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, p.payload); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// writePDU отправляет PDU в w
+func writePDU(w io.Writer, p *pdu) error {
+	order := p.order()
+	data := make([]byte, headerSize, headerSize+len(p.payload))
+	data[0], data[1], data[2] = 1, p.typ, p.flags
+	order.PutUint32(data[4:8], p.session)
+	order.PutUint32(data[8:12], p.transaction)
+	order.PutUint32(data[12:16], p.packet)
+	order.PutUint32(data[16:20], uint32(len(p.payload)))
+	_, err := w.Write(append(data, p.payload...))
+	return err
+}
+
+var errShortPayload = errors.New("truncated AgentX payload")
+
+// decoder читает поля из тела PDU. Первая ошибка сохраняется, остальные
+// чтения возвращают нулевые значения.
+type decoder struct {
+	order binary.ByteOrder
+	data  []byte
+	err   error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		d.err = errShortPayload
+		return make([]byte, n)
+	}
+	value := d.data[:n]
+	d.data = d.data[n:]
+	return value
+}
+
+func (d *decoder) uint8() uint8   { return d.take(1)[0] }
+func (d *decoder) uint16() uint16 { return d.order.Uint16(d.take(2)) }
+func (d *decoder) uint32() uint32 { return d.order.Uint32(d.take(4)) }
+
+// oid читает идентификатор и флаг include (RFC 2741, раздел 5.1)
+func (d *decoder) oid() (oid, bool) {
+	count, prefix, include := d.uint8(), d.uint8(), d.uint8()
+	d.take(1)
+	var id oid
+	if prefix != 0 {
+		id = oid{1, 3, 6, 1, uint32(prefix)}
+	}
+	for i := 0; i < int(count) && d.err == nil; i++ {
+		id = append(id, d.uint32())
+	}
+	return id, include != 0
+}
+
+// octetString читает строку, дополненную до границы 4 байт
+func (d *decoder) octetString() []byte {
+	length := d.uint32()
+	if length > uint32(len(d.data)) {
+		d.err = errShortPayload
+		return nil
+	}
+	value := d.take(int(length))
+	d.take((4 - int(length)%4) % 4)
+	return value
+}
+
+// encoder собирает тело PDU
+type encoder struct {
+	order binary.ByteOrder
+	data  []byte
+}
+
+func (e *encoder) uint8(value uint8) { e.data = append(e.data, value) }
+
+func (e *encoder) uint16(value uint16) {
+	e.data = append(e.data, 0, 0)
+	e.order.PutUint16(e.data[len(e.data)-2:], value)
+}
+
+func (e *encoder) uint32(value uint32) {
+	e.data = append(e.data, 0, 0, 0, 0)
+	e.order.PutUint32(e.data[len(e.data)-4:], value)
+}
+
+// oid записывает идентификатор; префикс 1.3.6.1.N сокращается
+func (e *encoder) oid(id oid, include bool) {
+	prefix := uint8(0)
+	if len(id) >= 5 && id[:4].compare(oid{1, 3, 6, 1}) == 0 && id[4] > 0 && id[4] < 256 {
+		prefix, id = uint8(id[4]), id[5:]
+	}
+	e.uint8(uint8(len(id)))
+	e.uint8(prefix)
+	if include {
+		e.uint8(1)
+	} else {
+		e.uint8(0)
+	}
+	e.uint8(0)
+	for _, subid := range id {
+		e.uint32(subid)
+	}
+}
+
+func (e *encoder) octetString(value []byte) {
+	e.uint32(uint32(len(value)))
+	e.data = append(e.data, value...)
+	e.data = append(e.data, make([]byte, (4-len(value)%4)%4)...)
+}
+
+// varbind значение объекта
+type varbind struct {
+	name  oid
+	typ   uint16
+	value uint32 // Integer, Counter32, Gauge32
+	bytes []byte // OctetString, IpAddress
+}
+
+func (e *encoder) varbind(v varbind) {
+	e.uint16(v.typ)
+	e.uint16(0)
+	e.oid(v.name, false)
+	switch v.typ {
+	case typeInteger, typeCounter32, typeGauge32:
+		e.uint32(v.value)
+	case typeOctetString, typeIPAddress:
+		e.octetString(v.bytes)
+	}
+}
@@ -0,0 +1,425 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runtime проверенная конфигурация с разобранными значениями. Создается
+// один раз после разбора (см. Compile), чтобы ошибки обнаруживались при
+// загрузке, а при обработке запросов не разбирались строки.
+type Runtime struct {
+	Subnets          []RuntimeSubnet // В порядке DHCPConfig.Subnets
+	Hosts            []RuntimeHost   // Глобальные хосты в порядке DHCPConfig.Hosts
+	BOOTPLeaseLength time.Duration   // bootp-lease-length (0 - бессрочно)
+	DefaultLeaseTime time.Duration   // Глобальный default-lease-time (0 - не задан)
+	MaxLeaseTime     time.Duration   // Глобальный max-lease-time (0 - не задан)
+	Delay            time.Duration   // Глобальный delay (0 - без задержки)
+
+	// Выражения в значениях опций, filename и server-name по исходному
+	// тексту значения
+	Expressions map[string]Expression
+}
+
+// Операторы срока аренды. В подсетях, классах, группах и хостах они
+// хранятся среди опций своего уровня и наследуются так же, как опции;
+// глобальные значения хранятся в GlobalOptions.
+const (
+	DefaultLeaseTime = "default-lease-time"
+	MaxLeaseTime     = "max-lease-time"
+)
+
+// Delay оператор задержки ответа в миллисекундах: delay 500;. Хранится и
+// наследуется так же, как операторы срока аренды, глобальное значение
+// разбирается в Runtime.Delay.
+const Delay = "delay"
+
+// maxDelay наибольшая задержка ответа: клиент повторяет DHCPDISCOVER
+// примерно через 4 секунды
+const maxDelay = 4 * time.Second
+
+// RuntimeSubnet разобранная подсеть
+type RuntimeSubnet struct {
+	Subnet *Subnet        // Исходное объявление
+	Ranges []RuntimeRange // Диапазоны в порядке объявления
+	Hosts  []RuntimeHost
+
+	// Адреса, которые не выдаются динамически, хотя входят в диапазоны
+	Exclusions []RuntimeRange
+}
+
+// RuntimeRange разобранный диапазон динамических адресов
+type RuntimeRange struct {
+	Start        net.IP
+	End          net.IP
+	DynamicBOOTP bool
+
+	RandomizedMAC bool
+}
+
+// RuntimeHost разобранный блок host
+type RuntimeHost struct {
+	Host     *Host            // Исходное объявление
+	Hardware net.HardwareAddr // nil - не задан
+	FixedIP  net.IP           // nil - не задан
+}
+
+// HasRange проверяет, задан ли в подсети хотя бы один диапазон range
+func (s *RuntimeSubnet) HasRange() bool {
+	return len(s.Ranges) > 0
+}
+
+// HasRandomizedMACRange проверяет, задан ли в подсети диапазон
+// range randomized-mac
+func (s *RuntimeSubnet) HasRandomizedMACRange() bool {
+	for i := range s.Ranges {
+		if s.Ranges[i].RandomizedMAC {
+			return true
+		}
+	}
+	return false
+}
+
+// Excluded проверяет, исключен ли адрес из выдачи оператором exclude
+func (s *RuntimeSubnet) Excluded(ip net.IP) bool {
+	for i := range s.Exclusions {
+		if s.Exclusions[i].Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains проверяет, входит ли адрес в диапазон
+func (r *RuntimeRange) Contains(ip net.IP) bool {
+	value := ipUint32(ip)
+	return value >= ipUint32(r.Start) && value <= ipUint32(r.End)
+}
+
+// Compile проверяет конфигурацию и разбирает адреса, аппаратные адреса,
+// сроки и выражения. Указатели в результате ссылаются на элементы cfg, поэтому cfg не
+// должна изменяться, пока используется результат.
+func Compile(cfg *DHCPConfig) (*Runtime, error) {
+	runtime := &Runtime{
+		Subnets:     make([]RuntimeSubnet, len(cfg.Subnets)),
+		Hosts:       make([]RuntimeHost, len(cfg.Hosts)),
+		Expressions: make(map[string]Expression),
+	}
+
+	if err := checkNextServer(cfg.Boot, "global"); err != nil {
+		return nil, err
+	}
+	for i := range cfg.Classes {
+		if err := checkNextServer(cfg.Classes[i].Boot, "class "+cfg.Classes[i].Name); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range cfg.Subnets {
+		subnet, err := compileSubnet(&cfg.Subnets[i], i)
+		if err != nil {
+			return nil, err
+		}
+		runtime.Subnets[i] = subnet
+	}
+	for i := range cfg.Hosts {
+		host, err := compileHost(&cfg.Hosts[i])
+		if err != nil {
+			return nil, err
+		}
+		runtime.Hosts[i] = host
+	}
+
+	if err := compileOptions(cfg, runtime.Expressions); err != nil {
+		return nil, err
+	}
+
+	if value, ok := cfg.GlobalOptions["bootp-lease-length"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid bootp-lease-length: %s", value)
+		}
+		runtime.BOOTPLeaseLength = time.Duration(seconds) * time.Second
+	}
+	if value, ok := cfg.GlobalOptions[DefaultLeaseTime]; ok {
+		duration, err := ParseLeaseTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default-lease-time: %s", value)
+		}
+		runtime.DefaultLeaseTime = duration
+	}
+	if value, ok := cfg.GlobalOptions[MaxLeaseTime]; ok {
+		duration, err := ParseLeaseTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max-lease-time: %s", value)
+		}
+		runtime.MaxLeaseTime = duration
+	}
+	if value, ok := cfg.GlobalOptions[Delay]; ok {
+		delay, err := ParseDelay(value)
+		if err != nil {
+			return nil, err
+		}
+		runtime.Delay = delay
+	}
+
+	return runtime, nil
+}
+
+// compileSubnet проверяет адрес сети и диапазон подсети и разбирает ее хосты
+func compileSubnet(subnet *Subnet, index int) (RuntimeSubnet, error) {
+	result := RuntimeSubnet{Subnet: subnet, Hosts: make([]RuntimeHost, len(subnet.Hosts))}
+
+	if subnet.Network == nil || subnet.Network.IP.To4() == nil {
+		return result, fmt.Errorf("subnet #%d: IPv4 network is not set", index+1)
+	}
+	if _, bits := subnet.Network.Mask.Size(); bits != 8*net.IPv4len {
+		return result, fmt.Errorf("subnet %s: invalid netmask", subnet.Network.IP)
+	}
+	scope := "subnet " + subnet.Network.IP.String()
+
+	for _, declared := range subnet.Ranges {
+		start, end := net.ParseIP(declared.Start).To4(), net.ParseIP(declared.End).To4()
+		if start == nil || end == nil {
+			return result, fmt.Errorf("%s: invalid range %s %s", scope, declared.Start, declared.End)
+		}
+		if !subnet.Network.Contains(start) || !subnet.Network.Contains(end) {
+			return result, fmt.Errorf("%s: range %s %s is outside of the subnet", scope, start, end)
+		}
+		if ipUint32(start) > ipUint32(end) {
+			return result, fmt.Errorf("%s: range start %s is after range end %s", scope, start, end)
+		}
+		// Адреса пересекающихся диапазонов учитывались бы в размере пула
+		// дважды
+		for _, previous := range result.Ranges {
+			if previous.Contains(start) || previous.Contains(end) || ipUint32(start) < ipUint32(previous.Start) && ipUint32(end) > ipUint32(previous.End) {
+				return result, fmt.Errorf("%s: range %s %s overlaps range %s %s", scope, start, end, previous.Start, previous.End)
+			}
+		}
+		result.Ranges = append(result.Ranges, RuntimeRange{Start: start, End: end, DynamicBOOTP: declared.DynamicBOOTP, RandomizedMAC: declared.RandomizedMAC})
+	}
+
+	for _, declared := range subnet.Exclusions {
+		start, end := net.ParseIP(declared.Start).To4(), net.ParseIP(declared.End).To4()
+		if start == nil || end == nil {
+			return result, fmt.Errorf("%s: invalid exclude %s %s", scope, declared.Start, declared.End)
+		}
+		if !subnet.Network.Contains(start) || !subnet.Network.Contains(end) {
+			return result, fmt.Errorf("%s: exclude %s %s is outside of the subnet", scope, start, end)
+		}
+		if ipUint32(start) > ipUint32(end) {
+			return result, fmt.Errorf("%s: exclude start %s is after exclude end %s", scope, start, end)
+		}
+		// Размер пула уменьшается на число исключенных адресов, поэтому
+		// исключения, как и диапазоны, не должны пересекаться
+		for _, previous := range result.Exclusions {
+			if previous.Contains(start) || previous.Contains(end) || ipUint32(start) < ipUint32(previous.Start) && ipUint32(end) > ipUint32(previous.End) {
+				return result, fmt.Errorf("%s: exclude %s %s overlaps exclude %s %s", scope, start, end, previous.Start, previous.End)
+			}
+		}
+		result.Exclusions = append(result.Exclusions, RuntimeRange{Start: start, End: end})
+	}
+
+	if err := checkNextServer(subnet.Boot, scope); err != nil {
+		return result, err
+	}
+	for i := range subnet.Hosts {
+		host, err := compileHost(&subnet.Hosts[i])
+		if err != nil {
+			return result, err
+		}
+		result.Hosts[i] = host
+	}
+	return result, nil
+}
+
+// compileHost разбирает аппаратный и фиксированный адреса хоста
+func compileHost(host *Host) (RuntimeHost, error) {
+	result := RuntimeHost{Host: host}
+
+	if host.Hardware != "" {
+		mac, err := NormalizeMAC(host.Hardware)
+		if err != nil {
+			return result, fmt.Errorf("host %s: %v", host.Name, err)
+		}
+		result.Hardware, _ = hex.DecodeString(strings.ReplaceAll(mac, ":", ""))
+	}
+	if host.FixedIP != "" {
+		if result.FixedIP = net.ParseIP(host.FixedIP).To4(); result.FixedIP == nil {
+			return result, fmt.Errorf("host %s: fixed-address must be an IPv4 address: %s", host.Name, host.FixedIP)
+		}
+	}
+	return result, checkNextServer(host.Boot, "host "+host.Name)
+}
+
+// compileOptions разбирает выражения в опциях и операторах filename и
+// server-name на всех уровнях конфигурации и проверяет значения
+// стандартных опций и опций, объявленных с типом (option <имя> code <код>
+// = <тип>)
+func compileOptions(cfg *DHCPConfig, expressions map[string]Expression) error {
+	compile := func(scope, value string) (bool, error) {
+		expr, ok, err := ParseExpression(value)
+		if err != nil {
+			return false, fmt.Errorf("%s: invalid expression %s: %v", scope, value, err)
+		}
+		if ok {
+			expressions[value] = expr
+		}
+		return ok, nil
+	}
+	add := func(scope string, options map[string]string, boot BootParams) error {
+		for _, value := range []string{boot.ServerName, boot.Filename} {
+			if _, err := compile(scope, value); err != nil {
+				return err
+			}
+		}
+		if err := checkBootNames(boot, options, expressions, scope); err != nil {
+			return err
+		}
+		for name, value := range options {
+			if name == DefaultLeaseTime || name == MaxLeaseTime {
+				if _, err := ParseLeaseTime(value); err != nil {
+					return fmt.Errorf("%s: invalid %s: %s", scope, name, value)
+				}
+				continue
+			}
+			if name == Delay {
+				if _, err := ParseDelay(value); err != nil {
+					return fmt.Errorf("%s: %v", scope, err)
+				}
+				continue
+			}
+			if name == VendorOptionSpace {
+				if !hasOptionSpace(cfg, value) {
+					return fmt.Errorf("%s: undefined option space %s", scope, value)
+				}
+				continue
+			}
+			// Значение выражения проверяется по типу при отправке
+			isExpr, err := compile(scope, value)
+			if err != nil {
+				return err
+			}
+			if isExpr {
+				continue
+			}
+			definition, declared := LookupOption(cfg, name)
+			if !declared {
+				if strings.Contains(name, ".") {
+					return fmt.Errorf("%s: option %s is not declared", scope, name)
+				}
+				continue
+			}
+			if _, err := EncodeOption(definition.Type, value); err != nil {
+				return fmt.Errorf("%s: option %s: %v", scope, name, err)
+			}
+		}
+		return nil
+	}
+	addHosts := func(hosts []Host) error {
+		for i := range hosts {
+			if err := add("host "+hosts[i].Name, hosts[i].Options, hosts[i].Boot); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := add("global", cfg.Options, cfg.Boot); err != nil {
+		return err
+	}
+	for i := range cfg.Subnets {
+		// Адреса подсетей уже проверены в compileSubnet
+		subnet := &cfg.Subnets[i]
+		if err := add("subnet "+subnet.Network.IP.String(), subnet.Options, subnet.Boot); err != nil {
+			return err
+		}
+		if err := addHosts(subnet.Hosts); err != nil {
+			return err
+		}
+	}
+	for i := range cfg.Classes {
+		if err := add("class "+cfg.Classes[i].Name, cfg.Classes[i].Options, cfg.Classes[i].Boot); err != nil {
+			return err
+		}
+	}
+	return addHosts(cfg.Hosts)
+}
+
+// ParseLeaseTime разбирает срок аренды в секундах
+func ParseLeaseTime(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || seconds == 0 {
+		return 0, fmt.Errorf("invalid lease time: %s", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// ParseDelay разбирает задержку ответа в миллисекундах
+func ParseDelay(value string) (time.Duration, error) {
+	ms, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || time.Duration(ms)*time.Millisecond > maxDelay {
+		return 0, fmt.Errorf("invalid delay: %s (must be 0-%d milliseconds)", value, maxDelay.Milliseconds())
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Длины имени сервера и файла загрузки. Поля заголовка sname и file
+// вмещают имя вместе с завершающим нулем, более длинное имя передается
+// только опцией 66 или 67, а в опцию помещается не больше 255 байт.
+const (
+	snameFieldLength = 63
+	fileFieldLength  = 127
+	maxBootNameLen   = 255
+)
+
+// bootName имя сервера или файла загрузки, заданное на уровне конфигурации
+type bootName struct {
+	statement string // Оператор или опция, задающие имя
+	value     string
+	field     int // Длина поля заголовка без завершающего нуля
+}
+
+// bootNames возвращает имена сервера и файла загрузки уровня: операторы
+// server-name и filename и опции tftp-server-name и bootfile-name
+func bootNames(boot BootParams, options map[string]string) []bootName {
+	return []bootName{
+		{"server-name", boot.ServerName, snameFieldLength},
+		{"option tftp-server-name", options["tftp-server-name"], snameFieldLength},
+		{"filename", boot.Filename, fileFieldLength},
+		{"option bootfile-name", options["bootfile-name"], fileFieldLength},
+	}
+}
+
+// checkBootNames проверяет, что имена сервера и файла загрузки помещаются
+// в опции 66 и 67. Длина выражений известна только при отправке.
+func checkBootNames(boot BootParams, options map[string]string, expressions map[string]Expression, scope string) error {
+	for _, name := range bootNames(boot, options) {
+		if _, isExpr := expressions[name.value]; isExpr {
+			continue
+		}
+		if len(name.value) > maxBootNameLen {
+			return fmt.Errorf("%s: %s is %d bytes long, at most %d bytes fit into a DHCP option", scope, name.statement, len(name.value), maxBootNameLen)
+		}
+	}
+	return nil
+}
+
+// checkNextServer проверяет, что next-server задан адресом IPv4: значение
+// передается в поле siaddr ответа
+func checkNextServer(boot BootParams, scope string) error {
+	if boot.NextServer != "" && net.ParseIP(boot.NextServer).To4() == nil {
+		return fmt.Errorf("%s: next-server must be an IPv4 address: %s", scope, boot.NextServer)
+	}
+	return nil
+}
+
+// ipUint32 преобразует адрес IPv4 в число
+func ipUint32(ip net.IP) uint32 {
+	ip = ip.To4()
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
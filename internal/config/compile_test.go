@@ -0,0 +1,116 @@
+package config
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	cfg := &DHCPConfig{
+		Subnets: []Subnet{
+			{
+				Network:    MustParseNetwork("192.168.1.0/24"),
+				Ranges:     []Range{{Start: "192.168.1.100", End: "192.168.1.200"}, {Start: "192.168.1.20", End: "192.168.1.29", DynamicBOOTP: true}},
+				Exclusions: []Range{{Start: "192.168.1.150", End: "192.168.1.150"}},
+				Hosts:      []Host{{Name: "printer", Hardware: "00-11-22-33-44-55", FixedIP: "192.168.1.10"}},
+			},
+			{Network: MustParseNetwork("10.0.0.0/8")},
+		},
+		Hosts:         []Host{{Name: "nas", ClientID: "01:aa:bb", FixedIP: "10.0.0.5"}},
+		GlobalOptions: map[string]string{"bootp-lease-length": "3600"},
+	}
+
+	runtime, err := Compile(cfg)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	subnet := runtime.Subnets[0]
+	if subnet.Subnet != &cfg.Subnets[0] || !subnet.HasRange() {
+		t.Fatalf("Unexpected subnet %+v", subnet)
+	}
+	if len(subnet.Ranges) != 2 {
+		t.Fatalf("Expected 2 ranges, got %+v", subnet.Ranges)
+	}
+	if first := subnet.Ranges[0]; !first.Start.Equal(net.IPv4(192, 168, 1, 100)) || !first.End.Equal(net.IPv4(192, 168, 1, 200)) || first.DynamicBOOTP {
+		t.Errorf("Unexpected range %+v", first)
+	}
+	if second := subnet.Ranges[1]; !second.Contains(net.IPv4(192, 168, 1, 25)) || second.Contains(net.IPv4(192, 168, 1, 30)) || !second.DynamicBOOTP {
+		t.Errorf("Unexpected range %+v", second)
+	}
+	if !subnet.Excluded(net.IPv4(192, 168, 1, 150)) || subnet.Excluded(net.IPv4(192, 168, 1, 151)) {
+		t.Errorf("Unexpected exclusions %+v", subnet.Exclusions)
+	}
+	if runtime.Subnets[1].HasRange() {
+		t.Error("Expected subnet without range")
+	}
+
+	host := subnet.Hosts[0]
+	if host.Hardware.String() != "00:11:22:33:44:55" || !host.FixedIP.Equal(net.IPv4(192, 168, 1, 10)) {
+		t.Errorf("Unexpected host %+v", host)
+	}
+	if host := runtime.Hosts[0]; host.Hardware != nil || !host.FixedIP.Equal(net.IPv4(10, 0, 0, 5)) {
+		t.Errorf("Unexpected global host %+v", host)
+	}
+	if runtime.BOOTPLeaseLength != time.Hour {
+		t.Errorf("Expected one hour BOOTP lease, got %v", runtime.BOOTPLeaseLength)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	subnet := func(start, end string) Subnet {
+		return Subnet{Network: MustParseNetwork("192.168.1.0/24"), Ranges: []Range{{Start: start, End: end}}}
+	}
+
+	tests := []struct {
+		name string
+		cfg  DHCPConfig
+		want string
+	}{
+		{"no network", DHCPConfig{Subnets: []Subnet{{}}}, "subnet #1: IPv4 network is not set"},
+		{"invalid range", DHCPConfig{Subnets: []Subnet{subnet("192.168.1.100", "")}}, "subnet 192.168.1.0: invalid range 192.168.1.100 "},
+		{"range outside of subnet", DHCPConfig{Subnets: []Subnet{subnet("192.168.1.100", "192.168.2.10")}}, "subnet 192.168.1.0: range 192.168.1.100 192.168.2.10 is outside of the subnet"},
+		{"reversed range", DHCPConfig{Subnets: []Subnet{subnet("192.168.1.200", "192.168.1.100")}}, "subnet 192.168.1.0: range start 192.168.1.200 is after range end 192.168.1.100"},
+		{"overlapping ranges", DHCPConfig{Subnets: []Subnet{{
+			Network: MustParseNetwork("192.168.1.0/24"),
+			Ranges:  []Range{{Start: "192.168.1.100", End: "192.168.1.150"}, {Start: "192.168.1.50", End: "192.168.1.200"}},
+		}}}, "subnet 192.168.1.0: range 192.168.1.50 192.168.1.200 overlaps range 192.168.1.100 192.168.1.150"},
+		{"exclude outside of subnet", DHCPConfig{Subnets: []Subnet{{
+			Network:    MustParseNetwork("192.168.1.0/24"),
+			Exclusions: []Range{{Start: "192.168.2.1", End: "192.168.2.1"}},
+		}}}, "subnet 192.168.1.0: exclude 192.168.2.1 192.168.2.1 is outside of the subnet"},
+		{"overlapping exclusions", DHCPConfig{Subnets: []Subnet{{
+			Network:    MustParseNetwork("192.168.1.0/24"),
+			Exclusions: []Range{{Start: "192.168.1.10", End: "192.168.1.20"}, {Start: "192.168.1.15", End: "192.168.1.15"}},
+		}}}, "subnet 192.168.1.0: exclude 192.168.1.15 192.168.1.15 overlaps exclude 192.168.1.10 192.168.1.20"},
+		{"fixed-address", DHCPConfig{Hosts: []Host{{Name: "a", FixedIP: "printer.example.com"}}}, "host a: fixed-address must be an IPv4 address: printer.example.com"},
+		{"next-server", DHCPConfig{Boot: BootParams{NextServer: "tftp"}}, "global: next-server must be an IPv4 address: tftp"},
+		{"expression", DHCPConfig{Hosts: []Host{{Name: "a", Boot: BootParams{Filename: `concat("boot/")`}}}}, `host a: invalid expression concat("boot/"): concat requires at least two arguments`},
+		{"option value", DHCPConfig{
+			Options:     map[string]string{"boot-delay": "70000"},
+			Definitions: map[string]OptionDefinition{"boot-delay": {Code: 225, Type: "unsigned integer 16"}},
+		}, "global: option boot-delay: invalid unsigned integer 16: 70000"},
+		{"undeclared space option", DHCPConfig{Options: map[string]string{"acme.server": "10.0.0.5"}}, "global: option acme.server is not declared"},
+		{"vendor-option-space", DHCPConfig{Options: map[string]string{VendorOptionSpace: "acme"}}, "global: undefined option space acme"},
+		{"default-lease-time", DHCPConfig{GlobalOptions: map[string]string{"default-lease-time": "0"}}, "invalid default-lease-time: 0"},
+		{"subnet max-lease-time", DHCPConfig{Subnets: []Subnet{{
+			Network: MustParseNetwork("192.168.1.0/24"),
+			Options: map[string]string{"max-lease-time": "1h"},
+		}}}, "subnet 192.168.1.0: invalid max-lease-time: 1h"},
+		{"bootp-lease-length", DHCPConfig{GlobalOptions: map[string]string{"bootp-lease-length": "-1"}}, "invalid bootp-lease-length: -1"},
+		{"delay", DHCPConfig{GlobalOptions: map[string]string{"delay": "5000"}}, "invalid delay: 5000 (must be 0-4000 milliseconds)"},
+		{"class delay", DHCPConfig{Classes: []Class{{
+			Name:    "pxe",
+			Options: map[string]string{"delay": "fast"},
+		}}}, "class pxe: invalid delay: fast (must be 0-4000 milliseconds)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(&tt.cfg); err == nil || err.Error() != tt.want {
+				t.Errorf("Compile error = %v, expected %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Expression выражение в значении опции или операторе filename и
+// server-name, вычисляемое для каждого запроса. Поддерживаются функции
+// ISC-DHCP concat(), substring() и binary-to-ascii(), строки в кавычках и
+// значения hardware и leased-address, а также шаблоны text/template с
+// переменными запроса: boot/{{.MAC}}.ipxe (см. templateData).
+type Expression interface {
+	// Evaluate вычисляет значение выражения для запроса
+	Evaluate(ctx ExprContext) []byte
+}
+
+// ExprContext данные запроса, доступные выражениям
+type ExprContext struct {
+	Hardware      []byte // hardware: тип оборудования (htype) и адрес клиента
+	LeasedAddress net.IP // leased-address: адрес клиента (nil - не назначен)
+
+	// Сведения из опций запроса для шаблонов
+	Arch        []uint16 // Архитектуры клиента (опция 93)
+	Hostname    string   // Имя хоста (опция 12)
+	ClientID    string   // Идентификатор клиента (опция 61)
+	VendorClass string   // Класс производителя (опция 60)
+}
+
+// expressionFunctions функции, с вызова которых начинается выражение.
+// Значение, не начинающееся с вызова, считается строкой.
+var expressionFunctions = []string{"concat", "substring", "binary-to-ascii"}
+
+// isExpression проверяет, является ли значение выражением
+func isExpression(value string) bool {
+	for _, name := range expressionFunctions {
+		if strings.HasPrefix(value, name) && strings.HasPrefix(strings.TrimSpace(value[len(name):]), "(") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseExpression разбирает выражение. Второе значение false, если
+// значение не является выражением.
+func ParseExpression(value string) (Expression, bool, error) {
+	if !isExpression(value) {
+		if isTemplate(value) {
+			expr, err := parseTemplate(value)
+			return expr, true, err
+		}
+		return nil, false, nil
+	}
+
+	tokens, err := tokenizeExpression(value)
+	if err != nil {
+		return nil, true, err
+	}
+	p := &expressionParser{tokens: tokens}
+	expr, err := p.data()
+	if err != nil {
+		return nil, true, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, true, fmt.Errorf("unexpected %q after expression", p.tokens[p.pos])
+	}
+	return expr, true, nil
+}
+
+// tokenizeExpression разбивает выражение на скобки, запятые, строки в
+// кавычках (вместе с кавычками) и слова
+func tokenizeExpression(value string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(value); {
+		switch c := value[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := strings.IndexByte(value[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, value[i:i+end+2])
+			i += end + 2
+		default:
+			start := i
+			for i < len(value) && !strings.ContainsRune(" \t(),\"", rune(value[i])) {
+				i++
+			}
+			tokens = append(tokens, value[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// expressionParser разбирает выражение рекурсивным спуском
+type expressionParser struct {
+	tokens []string
+	pos    int
+}
+
+// next возвращает следующую лексему (пустая строка - конец выражения)
+func (p *expressionParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+// expect проверяет, что следующая лексема равна token
+func (p *expressionParser) expect(token string) error {
+	if next := p.next(); next != token {
+		return unexpectedToken(next, token)
+	}
+	return nil
+}
+
+// data разбирает выражение, значение которого - данные
+func (p *expressionParser) data() (Expression, error) {
+	token := p.next()
+	switch {
+	case len(token) >= 2 && strings.HasPrefix(token, "\""):
+		return literalExpression(token[1 : len(token)-1]), nil
+	case token == "hardware":
+		return hardwareExpression{}, nil
+	case token == "leased-address":
+		return leasedAddressExpression{}, nil
+	case token == "concat":
+		return p.concat()
+	case token == "substring":
+		return p.substring()
+	case token == "binary-to-ascii":
+		return p.binaryToASCII()
+	}
+	return nil, unexpectedToken(token, "data expression")
+}
+
+// concat разбирает аргументы concat(<данные>, <данные>, ...)
+func (p *expressionParser) concat() (Expression, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var expr concatExpression
+	for {
+		arg, err := p.data()
+		if err != nil {
+			return nil, err
+		}
+		expr = append(expr, arg)
+		if token := p.next(); token == ")" {
+			break
+		} else if token != "," {
+			return nil, unexpectedToken(token, ", or )")
+		}
+	}
+	if len(expr) < 2 {
+		return nil, fmt.Errorf("concat requires at least two arguments")
+	}
+	return expr, nil
+}
+
+// substring разбирает аргументы substring(<данные>, <смещение>, <длина>)
+func (p *expressionParser) substring() (Expression, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	data, err := p.data()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := p.number(",")
+	if err != nil {
+		return nil, err
+	}
+	length, err := p.number(",")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return substringExpression{data: data, offset: offset, length: length}, nil
+}
+
+// binaryToASCII разбирает аргументы binary-to-ascii(<основание>,
+// <разрядность>, <разделитель>, <данные>)
+func (p *expressionParser) binaryToASCII() (Expression, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	base, err := p.number("")
+	if err != nil {
+		return nil, err
+	}
+	if base < 2 || base > 16 {
+		return nil, fmt.Errorf("binary-to-ascii base must be between 2 and 16: %d", base)
+	}
+	width, err := p.number(",")
+	if err != nil {
+		return nil, err
+	}
+	if width != 8 && width != 16 && width != 32 {
+		return nil, fmt.Errorf("binary-to-ascii width must be 8, 16 or 32: %d", width)
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	separator, err := p.data()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	data, err := p.data()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return binaryToASCIIExpression{base: base, width: width, separator: separator, data: data}, nil
+}
+
+// number разбирает неотрицательное число, перед которым стоит лексема
+// separator (пустая строка - без разделителя)
+func (p *expressionParser) number(separator string) (int, error) {
+	if separator != "" {
+		if err := p.expect(separator); err != nil {
+			return 0, err
+		}
+	}
+	token := p.next()
+	n, err := strconv.Atoi(token)
+	if err != nil || n < 0 {
+		return 0, unexpectedToken(token, "number")
+	}
+	return n, nil
+}
+
+// unexpectedToken ошибка разбора: вместо ожидаемого найдено token
+func unexpectedToken(token, expected string) error {
+	if token == "" {
+		return fmt.Errorf("unexpected end of expression, expected %s", expected)
+	}
+	return fmt.Errorf("unexpected %q, expected %s", token, expected)
+}
+
+// literalExpression строка в кавычках
+type literalExpression string
+
+func (e literalExpression) Evaluate(ExprContext) []byte {
+	return []byte(e)
+}
+
+// hardwareExpression тип оборудования и аппаратный адрес клиента
+type hardwareExpression struct{}
+
+func (hardwareExpression) Evaluate(ctx ExprContext) []byte {
+	return ctx.Hardware
+}
+
+// leasedAddressExpression адрес клиента в виде четырех байт
+type leasedAddressExpression struct{}
+
+func (leasedAddressExpression) Evaluate(ctx ExprContext) []byte {
+	return ctx.LeasedAddress.To4()
+}
+
+// concatExpression объединение значений аргументов
+type concatExpression []Expression
+
+func (e concatExpression) Evaluate(ctx ExprContext) []byte {
+	var result []byte
+	for _, arg := range e {
+		result = append(result, arg.Evaluate(ctx)...)
+	}
+	return result
+}
+
+// substringExpression часть данных. Смещение за концом данных дает пустое
+// значение, длина ограничивается концом данных.
+type substringExpression struct {
+	data   Expression
+	offset int
+	length int
+}
+
+func (e substringExpression) Evaluate(ctx ExprContext) []byte {
+	data := e.data.Evaluate(ctx)
+	if e.offset >= len(data) {
+		return nil
+	}
+	data = data[e.offset:]
+	if e.length < len(data) {
+		data = data[:e.length]
+	}
+	return data
+}
+
+// binaryToASCIIExpression запись данных числами в заданной системе
+// счисления через разделитель. Данные разбиваются на числа по width бит,
+// неполное число в конце отбрасывается. Как и в ISC-DHCP, числа не
+// дополняются нулями.
+type binaryToASCIIExpression struct {
+	base      int
+	width     int
+	separator Expression
+	data      Expression
+}
+
+func (e binaryToASCIIExpression) Evaluate(ctx ExprContext) []byte {
+	data := e.data.Evaluate(ctx)
+	separator := e.separator.Evaluate(ctx)
+	size := e.width / 8
+
+	var result []byte
+	for i := 0; i+size <= len(data); i += size {
+		var value uint64
+		switch size {
+		case 1:
+			value = uint64(data[i])
+		case 2:
+			value = uint64(binary.BigEndian.Uint16(data[i:]))
+		case 4:
+			value = uint64(binary.BigEndian.Uint32(data[i:]))
+		}
+		if i > 0 {
+			result = append(result, separator...)
+		}
+		result = strconv.AppendUint(result, value, e.base)
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	ctx := ExprContext{
+		Hardware:      []byte{1, 0x00, 0x11, 0x22, 0x33, 0x44, 0x5a},
+		LeasedAddress: net.IPv4(192, 168, 1, 100),
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`concat("boot/", "pxelinux.0")`, "boot/pxelinux.0"},
+		{`binary-to-ascii(16, 8, ":", substring(hardware, 1, 6))`, "0:11:22:33:44:5a"},
+		{`concat("pc-", binary-to-ascii(10, 8, "-", leased-address))`, "pc-192-168-1-100"},
+		{`binary-to-ascii(16, 16, "", substring(hardware, 1, 4))`, "112233"},
+		{`substring(hardware, 5, 10)`, "\x44\x5a"},
+		{`substring(hardware, 10, 1)`, ""},
+		{`concat ( "a" , "b", "c" )`, "abc"},
+	}
+
+	for _, tt := range tests {
+		expr, ok, err := ParseExpression(tt.expr)
+		if err != nil || !ok {
+			t.Errorf("ParseExpression(%s) = %v, %v", tt.expr, ok, err)
+			continue
+		}
+		if got := string(expr.Evaluate(ctx)); got != tt.want {
+			t.Errorf("%s = %q, expected %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	ctx := ExprContext{
+		Hardware:      []byte{1, 0x00, 0x11, 0x22, 0x33, 0x44, 0x5a},
+		LeasedAddress: net.IPv4(192, 168, 1, 100),
+		Arch:          []uint16{7},
+		ClientID:      "01:00:11:22:33:44:5a",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"boot/{{.MAC}}.ipxe", "boot/00:11:22:33:44:5a.ipxe"},
+		{`{{upper (replace .MAC ":" "")}}`, "00112233445A"},
+		{"http://boot/{{.IP}}?arch={{.Arch}}&id={{.ClientID}}", "http://boot/192.168.1.100?arch=7&id=01:00:11:22:33:44:5a"},
+		{"{{if .Hostname}}{{.Hostname}}{{else}}unknown{{end}}", "unknown"},
+	}
+	for _, tt := range tests {
+		expr, ok, err := ParseExpression(tt.template)
+		if err != nil || !ok {
+			t.Errorf("ParseExpression(%s) = %v, %v", tt.template, ok, err)
+			continue
+		}
+		if got := string(expr.Evaluate(ctx)); got != tt.want {
+			t.Errorf("%s = %q, expected %q", tt.template, got, tt.want)
+		}
+	}
+
+	// Без адреса и архитектуры переменные пусты
+	expr, _, _ := ParseExpression("{{.IP}}/{{.Arch}}")
+	if got := string(expr.Evaluate(ExprContext{})); got != "/" {
+		t.Errorf("Expected empty variables, got %q", got)
+	}
+
+	for _, value := range []string{"{{.MAC", "{{.Serial}}", "{{unknown .MAC}}"} {
+		if _, ok, err := ParseExpression(value); !ok || err == nil {
+			t.Errorf("ParseExpression(%s) = %v, %v, expected error", value, ok, err)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, value := range []string{
+		`concat("a")`,
+		`concat("a", "b"`,
+		`concat("a", unknown)`,
+		`substring(hardware, 1)`,
+		`substring(hardware, -1, 2)`,
+		`binary-to-ascii(17, 8, ":", hardware)`,
+		`binary-to-ascii(16, 12, ":", hardware)`,
+		`concat("a, "b")`,
+		`concat("a", "b") "c"`,
+	} {
+		if _, ok, err := ParseExpression(value); !ok || err == nil {
+			t.Errorf("ParseExpression(%s) = %v, %v, expected error", value, ok, err)
+		}
+	}
+
+	// Обычные значения выражениями не считаются
+	for _, value := range []string{"pxelinux.0", "hardware", "concatenated", "8.8.8.8, 8.8.4.4"} {
+		if _, ok, err := ParseExpression(value); ok || err != nil {
+			t.Errorf("ParseExpression(%s) = %v, %v, expected plain value", value, ok, err)
+		}
+	}
+}
+
+func FuzzParseExpression(f *testing.F) {
+	for _, seed := range []string{
+		`concat("a", "b")`,
+		`substring(hardware, 1, 6)`,
+		`binary-to-ascii(16, 8, ":", substring(hardware, 1, 6))`,
+		`concat(leased-address, "`,
+		`substring(`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		expr, ok, err := ParseExpression(value)
+		if err != nil || !ok {
+			return
+		}
+		expr.Evaluate(ExprContext{Hardware: []byte{1, 0, 0x11, 0x22, 0x33, 0x44, 0x55}, LeasedAddress: net.IPv4(192, 168, 1, 10)})
+		expr.Evaluate(ExprContext{})
+	})
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"sort"
+	"sync"
+)
+
+// globalStatements глобальные операторы "name value;" и "name;", которые
+// читает программа. Операторы сохраняются парсером в GlobalOptions, не
+// зная, какой пакет их прочитает; Lint сообщает об остальных (опечатки и
+// неподдерживаемые операторы ISC dhcpd молча игнорировались бы).
+var globalStatements = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: map[string]bool{
+	"bootp-lease-length": true,
+	DefaultLeaseTime:     true,
+	MaxLeaseTime:         true,
+	Delay:                true,
+}}
+
+// RegisterGlobalOptions объявляет глобальные операторы, которые читает
+// пакет или плагин. Вызывается из init() пакета, разбирающего операторы.
+func RegisterGlobalOptions(names ...string) {
+	globalStatements.Lock()
+	defer globalStatements.Unlock()
+
+	for _, name := range names {
+		globalStatements.names[name] = true
+	}
+}
+
+// unknownGlobalOptions возвращает упорядоченные имена операторов options,
+// не объявленных через RegisterGlobalOptions
+func unknownGlobalOptions(options map[string]string) []string {
+	globalStatements.Lock()
+	defer globalStatements.Unlock()
+
+	var unknown []string
+	for name := range options {
+		if !globalStatements.names[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
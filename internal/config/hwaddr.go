@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxHardwareLen максимальная длина аппаратного адреса (поле chaddr)
+const maxHardwareLen = 16
+
+// NormalizeMAC приводит аппаратный адрес к каноническому виду: байты в
+// шестнадцатеричном виде в нижнем регистре через двоеточие. Принимаются
+// разделители ":" и "-" (в том числе с однозначными байтами, 0:c:29:...),
+// формат Cisco (0011.2233.4455) и адрес без разделителей. Длина адреса
+// может отличаться от 6 байт для сетей, отличных от Ethernet.
+func NormalizeMAC(addr string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(addr))
+	if isCanonicalMAC(value) {
+		return value, nil
+	}
+
+	var octets []string
+	switch {
+	case strings.ContainsAny(value, ":-"):
+		octets = strings.FieldsFunc(value, func(r rune) bool { return r == ':' || r == '-' })
+		if len(octets) != strings.Count(value, ":")+strings.Count(value, "-")+1 {
+			return "", fmt.Errorf("invalid hardware address: %s", addr)
+		}
+		for i, octet := range octets {
+			if len(octet) == 1 {
+				octets[i] = "0" + octet
+			}
+		}
+	case strings.Contains(value, "."):
+		// Формат Cisco: группы по 4 шестнадцатеричные цифры
+		for _, group := range strings.Split(value, ".") {
+			if len(group) != 4 {
+				return "", fmt.Errorf("invalid hardware address: %s", addr)
+			}
+			octets = append(octets, group[:2], group[2:])
+		}
+	default:
+		if len(value)%2 != 0 {
+			return "", fmt.Errorf("invalid hardware address: %s", addr)
+		}
+		for i := 0; i < len(value); i += 2 {
+			octets = append(octets, value[i:i+2])
+		}
+	}
+
+	if len(octets) == 0 || len(octets) > maxHardwareLen {
+		return "", fmt.Errorf("invalid hardware address length: %s", addr)
+	}
+	for _, octet := range octets {
+		if _, err := hex.DecodeString(octet); err != nil || len(octet) != 2 {
+			return "", fmt.Errorf("invalid hardware address: %s", addr)
+		}
+	}
+
+	return strings.Join(octets, ":"), nil
+}
+
+// isCanonicalMAC проверяет, что адрес уже в каноническом виде, чтобы не
+// разбирать его заново для каждого пакета
+func isCanonicalMAC(value string) bool {
+	if len(value)%3 != 2 || len(value) > 3*maxHardwareLen-1 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if i%3 == 2 {
+			if c != ':' {
+				return false
+			}
+		} else if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeMACPattern приводит к каноническому виду шаблон MAC адреса:
+// полный адрес или префикс с "*" на конце (00-1A-2B-*).
+func NormalizeMACPattern(pattern string) (string, error) {
+	if pattern == "*" {
+		return pattern, nil
+	}
+	prefix := pattern
+	wildcard := strings.HasSuffix(pattern, "*")
+	if wildcard {
+		prefix = strings.TrimSuffix(prefix, "*")
+		prefix = strings.TrimRight(prefix, ":-.")
+	}
+
+	normalized, err := NormalizeMAC(prefix)
+	if err != nil {
+		return "", err
+	}
+	if wildcard {
+		normalized += ":*"
+	}
+	return normalized, nil
+}
+
+// NormalizeClientID приводит идентификатор клиента (опция 61) к виду
+// шестнадцатеричных байт через двоеточие. Строка в кавычках задает байты
+// идентификатора как есть, иначе ожидаются байты через двоеточие
+// (01:00:11:22:33:44:55, где 01 - тип оборудования).
+func NormalizeClientID(value string) (string, error) {
+	data, ok := parseOptionData(value)
+	if !ok {
+		return "", fmt.Errorf("invalid client identifier: %s", value)
+	}
+
+	if len(data) == 0 || len(data) > 255 {
+		return "", fmt.Errorf("invalid client identifier length: %s", value)
+	}
+
+	octets := make([]string, len(data))
+	for i, b := range data {
+		octets[i] = hex.EncodeToString([]byte{b})
+	}
+	return strings.Join(octets, ":"), nil
+}
+
+// parseOptionData разбирает значение опции в синтаксисе ISC-DHCP: строку
+// в кавычках (байты как есть) или байты в шестнадцатеричном виде через
+// двоеточие (00:07, однозначные байты допускаются)
+func parseOptionData(value string) ([]byte, bool) {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return []byte(value[1 : len(value)-1]), true
+	}
+
+	var data []byte
+	for _, octet := range strings.Split(value, ":") {
+		if len(octet) == 1 {
+			octet = "0" + octet
+		}
+		b, err := hex.DecodeString(octet)
+		if err != nil || len(b) != 1 {
+			return nil, false
+		}
+		data = append(data, b[0])
+	}
+	return data, true
+}
@@ -0,0 +1,84 @@
+package config
+
+import "testing"
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"00:11:22:33:44:55", "00:11:22:33:44:55"},
+		{"00:1A:2B:3C:4D:5E", "00:1a:2b:3c:4d:5e"},
+		{"00-1a-2b-3c-4d-5e", "00:1a:2b:3c:4d:5e"},
+		{"001a.2b3c.4d5e", "00:1a:2b:3c:4d:5e"},
+		{"001A2B3C4D5E", "00:1a:2b:3c:4d:5e"},
+		{"0:c:29:0:0:1", "00:0c:29:00:00:01"},
+		// Адреса, отличные от Ethernet
+		{"00:11:22:33:44:55:66:77", "00:11:22:33:44:55:66:77"},
+		{"aa:bb", "aa:bb"},
+	}
+
+	for _, tt := range tests {
+		mac, err := NormalizeMAC(tt.input)
+		if err != nil {
+			t.Errorf("NormalizeMAC(%s) returned error: %v", tt.input, err)
+			continue
+		}
+		if mac != tt.expected {
+			t.Errorf("NormalizeMAC(%s) = %s, expected %s", tt.input, mac, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "00:11:22:33:44:zz", "00::11", "001.2b3c", "abc", "123:11", "00:11:22:33:44:55:66:77:88:99:aa:bb:cc:dd:ee:ff:00"} {
+		if mac, err := NormalizeMAC(input); err == nil {
+			t.Errorf("Expected error for %q, got %s", input, mac)
+		}
+	}
+}
+
+func TestNormalizeMACPattern(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"*", "*"},
+		{"00-1A-2B-*", "00:1a:2b:*"},
+		{"00:1a:2b:*", "00:1a:2b:*"},
+		{"00-11-22-33-44-55", "00:11:22:33:44:55"},
+	}
+
+	for _, tt := range tests {
+		pattern, err := NormalizeMACPattern(tt.input)
+		if err != nil || pattern != tt.expected {
+			t.Errorf("NormalizeMACPattern(%s) = %s (%v), expected %s", tt.input, pattern, err, tt.expected)
+		}
+	}
+
+	if _, err := NormalizeMACPattern("zz:*"); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestNormalizeClientID(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"01:00:11:22:33:44:55", "01:00:11:22:33:44:55"},
+		{"1:0:C:29:0:0:1", "01:00:0c:29:00:00:01"},
+		{`"laptop"`, "6c:61:70:74:6f:70"},
+	}
+
+	for _, tt := range tests {
+		clientID, err := NormalizeClientID(tt.input)
+		if err != nil || clientID != tt.expected {
+			t.Errorf("NormalizeClientID(%s) = %s (%v), expected %s", tt.input, clientID, err, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", `""`, "01:zz", "laptop"} {
+		if clientID, err := NormalizeClientID(input); err == nil {
+			t.Errorf("Expected error for %q, got %s", input, clientID)
+		}
+	}
+}
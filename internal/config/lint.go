@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Warning замечание проверки конфигурации: конфигурация допустима, но
+// скорее всего работает не так, как задумано
+type Warning struct {
+	Check   string `json:"check"` // Идентификатор проверки
+	Scope   string `json:"scope"` // Объявление, к которому относится замечание
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return w.Scope + ": " + w.Message
+}
+
+// Идентификаторы проверок Lint
+const (
+	LintRangeReservation = "range-reservation" // Фиксированный адрес хоста внутри диапазона
+	LintNoRouters        = "no-routers"        // Подсеть без опции routers
+	LintPXEBootfile      = "pxe-bootfile"      // Класс PXE без файла загрузки
+	LintShortLease       = "short-lease"       // Слишком короткий срок аренды
+	LintLongBootName     = "long-boot-name"    // Имя загрузки не помещается в поле заголовка
+	LintUnknownStatement = "unknown-statement" // Неизвестный глобальный оператор
+)
+
+// minLeaseTime срок аренды, меньше которого Lint считает срок слишком
+// коротким: клиенты будут продлевать аренду каждые несколько минут
+const minLeaseTime = 5 * time.Minute
+
+// Lint проверяет конфигурацию (см. Compile) и возвращает замечания в
+// порядке объявлений
+func Lint(cfg *DHCPConfig) ([]Warning, error) {
+	runtime, err := Compile(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []Warning
+	warn := func(check, scope, format string, args ...interface{}) {
+		warnings = append(warnings, Warning{Check: check, Scope: scope, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, name := range unknownGlobalOptions(cfg.GlobalOptions) {
+		warn(LintUnknownStatement, "global", "unknown statement %s is ignored", name)
+	}
+	lintLeaseTimes(warn, "global", cfg.GlobalOptions)
+	lintBootNames(warn, "global", cfg.Boot, cfg.Options, runtime)
+	for i := range runtime.Subnets {
+		subnet := &runtime.Subnets[i]
+		scope := "subnet " + subnet.Subnet.Network.IP.String()
+
+		if _, ok := subnet.Subnet.Options["routers"]; !ok && cfg.Options["routers"] == "" && (subnet.HasRange() || len(subnet.Hosts) > 0) {
+			warn(LintNoRouters, scope, "no routers option, clients will have no default gateway")
+		}
+		lintLeaseTimes(warn, scope, subnet.Subnet.Options)
+		lintBootNames(warn, scope, subnet.Subnet.Boot, subnet.Subnet.Options, runtime)
+		for j := range subnet.Hosts {
+			host := subnet.Hosts[j].Host
+			lintLeaseTimes(warn, "host "+host.Name, host.Options)
+			lintBootNames(warn, "host "+host.Name, host.Boot, host.Options, runtime)
+		}
+
+		// Хосты подсети и глобальные хосты с адресом из этой подсети
+		hosts := append(append([]RuntimeHost(nil), subnet.Hosts...), runtime.Hosts...)
+		for _, host := range hosts {
+			if host.FixedIP == nil || subnet.Excluded(host.FixedIP) {
+				continue
+			}
+			for _, r := range subnet.Ranges {
+				if r.Contains(host.FixedIP) {
+					warn(LintRangeReservation, scope, "fixed-address %s of host %s is inside range %s %s", host.FixedIP, host.Host.Name, r.Start, r.End)
+				}
+			}
+		}
+	}
+	for i := range cfg.Classes {
+		class := &cfg.Classes[i]
+		scope := "class " + class.Name
+		if isPXEClass(class) && class.Boot.Filename == "" && class.Options["bootfile-name"] == "" && cfg.Boot.Filename == "" {
+			warn(LintPXEBootfile, scope, "PXE class has no filename, clients will not boot")
+		}
+		lintLeaseTimes(warn, scope, class.Options)
+		lintBootNames(warn, scope, class.Boot, class.Options, runtime)
+	}
+	for i := range cfg.Hosts {
+		host := &cfg.Hosts[i]
+		lintLeaseTimes(warn, "host "+host.Name, host.Options)
+		lintBootNames(warn, "host "+host.Name, host.Boot, host.Options, runtime)
+	}
+	return warnings, nil
+}
+
+// lintLeaseTimes проверяет default-lease-time и max-lease-time уровня.
+// Значения уже проверены в Compile.
+func lintLeaseTimes(warn func(check, scope, format string, args ...interface{}), scope string, options map[string]string) {
+	for _, name := range []string{DefaultLeaseTime, MaxLeaseTime} {
+		value, ok := options[name]
+		if !ok {
+			continue
+		}
+		if duration, _ := ParseLeaseTime(value); duration < minLeaseTime {
+			warn(LintShortLease, scope, "%s %s is shorter than %v", name, value, minLeaseTime)
+		}
+	}
+}
+
+// lintBootNames проверяет, что имена сервера и файла загрузки уровня
+// помещаются в поля заголовка sname и file. Более длинное имя передается
+// только опцией 66 или 67, которую не читают BOOTP клиенты и часть PXE ROM.
+func lintBootNames(warn func(check, scope, format string, args ...interface{}), scope string, boot BootParams, options map[string]string, runtime *Runtime) {
+	for _, name := range bootNames(boot, options) {
+		if _, isExpr := runtime.Expressions[name.value]; isExpr || len(name.value) <= name.field {
+			continue
+		}
+		warn(LintLongBootName, scope, "%s is %d bytes long and does not fit into the %d byte header field, it is sent only in a DHCP option",
+			name.statement, len(name.value), name.field+1)
+	}
+}
+
+// isPXEClass проверяет, предназначен ли класс для клиентов PXE: по условию
+// на vendor-class-identifier или по имени
+func isPXEClass(class *Class) bool {
+	for _, condition := range class.Match {
+		if condition.Option == "vendor-class-identifier" && strings.HasPrefix(string(condition.Value), "PXEClient") {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(class.Name), "pxe")
+}
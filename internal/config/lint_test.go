@@ -0,0 +1,150 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, `
+default-lease-time 60;
+
+class "pxe" {
+  match if option vendor-class-identifier = "PXEClient";
+}
+
+class "ipxe-clients" {
+  filename "ipxe.efi";
+}
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  exclude 192.168.1.150;
+  max-lease-time 600;
+  host printer {
+    hardware ethernet 00:11:22:33:44:66;
+    fixed-address 192.168.1.120;
+  }
+  host scanner {
+    hardware ethernet 00:11:22:33:44:67;
+    fixed-address 192.168.1.150;
+  }
+}
+
+subnet 10.0.0.0 netmask 255.255.255.0 {
+  range 10.0.0.10 10.0.0.20;
+  option routers 10.0.0.1;
+}
+
+subnet 10.0.1.0 netmask 255.255.255.0 {
+}
+
+host laptop {
+  hardware ethernet 00:11:22:33:44:77;
+  fixed-address 10.0.0.15;
+  default-lease-time 120;
+}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	warnings, err := Lint(cfg)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	expected := []Warning{
+		{LintShortLease, "global", "default-lease-time 60 is shorter than 5m0s"},
+		{LintNoRouters, "subnet 192.168.1.0", "no routers option, clients will have no default gateway"},
+		{LintRangeReservation, "subnet 192.168.1.0", "fixed-address 192.168.1.120 of host printer is inside range 192.168.1.100 192.168.1.200"},
+		{LintRangeReservation, "subnet 10.0.0.0", "fixed-address 10.0.0.15 of host laptop is inside range 10.0.0.10 10.0.0.20"},
+		{LintPXEBootfile, "class pxe", "PXE class has no filename, clients will not boot"},
+		{LintShortLease, "host laptop", "default-lease-time 120 is shorter than 5m0s"},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Unexpected warnings:\n%v\nexpected:\n%v", warnings, expected)
+	}
+
+	// Глобальные routers и filename снимают замечания подсетей и классов
+	cfg.Options["routers"] = "192.168.1.1"
+	cfg.Boot.Filename = "pxelinux.0"
+	if warnings, _ = Lint(cfg); len(warnings) != 4 {
+		t.Errorf("Expected 4 warnings with global routers and filename, got %v", warnings)
+	}
+
+	if _, err := Lint(&DHCPConfig{GlobalOptions: map[string]string{DefaultLeaseTime: "soon"}}); err == nil {
+		t.Error("Expected Lint to fail on invalid configuration")
+	}
+}
+
+func TestLintUnknownStatements(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, `
+option routers 192.168.1.1;
+default-lease-time 600;
+ddns-update-style none;
+tftp-rot "/srv/tftp";
+one-lease-per-client;
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	warnings, err := Lint(cfg)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	expected := []Warning{
+		{LintUnknownStatement, "global", "unknown statement ddns-update-style is ignored"},
+		{LintUnknownStatement, "global", "unknown statement one-lease-per-client is ignored"},
+		{LintUnknownStatement, "global", "unknown statement tftp-rot is ignored"},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Unexpected warnings:\n%v\nexpected:\n%v", warnings, expected)
+	}
+
+	// Операторы, объявленные пакетами программы, не считаются неизвестными
+	RegisterGlobalOptions("ddns-update-style", "one-lease-per-client", "tftp-rot")
+	if warnings, _ = Lint(cfg); len(warnings) != 0 {
+		t.Errorf("Expected no warnings for registered statements, got %v", warnings)
+	}
+}
+
+func TestLintBootNames(t *testing.T) {
+	long := "images/" + strings.Repeat("x", 130) + ".efi"
+	cfg, err := ParseConfig(writeTestConfig(t, `
+option routers 192.168.1.1;
+option tftp-server-name "`+strings.Repeat("tftp.", 13)+`example.com";
+
+class "uefi" {
+  filename "`+long+`";
+}
+
+host kiosk {
+  hardware ethernet 00:11:22:33:44:55;
+  filename concat("boot/", "`+long+`");
+}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	// Длина значения выражения известна только при отправке
+	warnings, err := Lint(cfg)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	expected := []Warning{
+		{LintLongBootName, "global", "option tftp-server-name is 76 bytes long and does not fit into the 64 byte header field, it is sent only in a DHCP option"},
+		{LintLongBootName, "class uefi", "filename is 141 bytes long and does not fit into the 128 byte header field, it is sent only in a DHCP option"},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Unexpected warnings:\n%v\nexpected:\n%v", warnings, expected)
+	}
+
+	// Имя длиннее 255 байт не помещается и в опцию
+	cfg.Classes[0].Boot.Filename = strings.Repeat("x", 256)
+	if _, err := Lint(cfg); err == nil || err.Error() != "class uefi: filename is 256 bytes long, at most 255 bytes fit into a DHCP option" {
+		t.Errorf("Expected error for too long filename, got %v", err)
+	}
+}
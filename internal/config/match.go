@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OptionDefinition объявление опции: option <имя> code <код> = <тип>;
+type OptionDefinition struct {
+	Code uint8
+	Type string // Тип значения, например text или unsigned integer 16
+}
+
+// ClassCondition условие оператора match if класса: наличие опции в
+// запросе клиента (exists) или равенство ее значения
+type ClassCondition struct {
+	Option string // Имя опции
+	Code   uint8  // Код опции, определяется по имени после разбора файла
+	Value  []byte // Ожидаемое значение (nil - достаточно наличия опции)
+}
+
+// standardOptionCodes коды опций, которые можно использовать в условиях
+// классов без объявления option ... code
+var standardOptionCodes = map[string]uint8{
+	"vendor-class-identifier": 60,
+	"user-class":              77,
+	"pxe-system-type":         93,
+}
+
+// parseOptionDefinition разбирает объявление "option <имя> code <код> =
+// <тип>". Второе значение false, если строка не является объявлением.
+func parseOptionDefinition(line string) (string, OptionDefinition, bool, error) {
+	parts := strings.Fields(strings.TrimPrefix(line, "option "))
+	if len(parts) < 5 || parts[1] != "code" || parts[3] != "=" {
+		return "", OptionDefinition{}, false, nil
+	}
+
+	code, err := strconv.Atoi(parts[2])
+	if err != nil || code < 1 || code > 254 {
+		return "", OptionDefinition{}, false, fmt.Errorf("invalid option code: %s", parts[2])
+	}
+	typ := strings.Join(parts[4:], " ")
+	if err := checkOptionType(typ); err != nil {
+		return "", OptionDefinition{}, false, fmt.Errorf("option %s: %v", parts[0], err)
+	}
+	return parts[0], OptionDefinition{Code: uint8(code), Type: typ}, true, nil
+}
+
+// parseOptionSpace разбирает объявление "option space <имя>". Второе
+// значение false, если строка не является объявлением. Коды и длины
+// подопций всегда занимают один байт.
+func parseOptionSpace(line string) (string, bool, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 3 || parts[0] != "option" || parts[1] != "space" {
+		return "", false, nil
+	}
+	if len(parts) != 3 || strings.Contains(parts[2], ".") {
+		return "", false, fmt.Errorf("unsupported option space declaration: %s", line)
+	}
+	return parts[2], true, nil
+}
+
+// checkOptionSpaces проверяет, что опции вида <пространство>.<имя>
+// объявлены в объявленных пространствах
+func checkOptionSpaces(config *DHCPConfig) error {
+	for name := range config.Definitions {
+		space, _, found := strings.Cut(name, ".")
+		if found && !hasOptionSpace(config, space) {
+			return fmt.Errorf("option %s: undefined option space %s", name, space)
+		}
+	}
+	return nil
+}
+
+// hasOptionSpace проверяет, объявлено ли пространство опций
+func hasOptionSpace(config *DHCPConfig, space string) bool {
+	for _, declared := range config.Spaces {
+		if declared == space {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMatchCondition разбирает выражение оператора "match if": условия
+// "exists <опция>" и "option <опция> = <значение>", объединенные через and.
+// Значение - строка в кавычках или байты через двоеточие (00:07).
+func parseMatchCondition(expr string) ([]ClassCondition, error) {
+	var conditions []ClassCondition
+	for _, term := range strings.Split(expr, " and ") {
+		fields := strings.Fields(term)
+		switch {
+		case len(fields) == 2 && fields[0] == "exists":
+			conditions = append(conditions, ClassCondition{Option: fields[1]})
+		case len(fields) >= 4 && fields[0] == "option" && fields[2] == "=":
+			value, ok := parseOptionData(strings.Join(fields[3:], " "))
+			if !ok || len(value) == 0 {
+				return nil, fmt.Errorf("invalid value in match condition: %s", strings.TrimSpace(term))
+			}
+			conditions = append(conditions, ClassCondition{Option: fields[1], Value: value})
+		default:
+			return nil, fmt.Errorf("unsupported match condition: %s", strings.TrimSpace(term))
+		}
+	}
+	return conditions, nil
+}
+
+// resolveConditionCodes определяет коды опций в условиях классов по
+// стандартным именам и объявлениям option ... code
+func resolveConditionCodes(config *DHCPConfig) error {
+	for i := range config.Classes {
+		class := &config.Classes[i]
+		for j := range class.Match {
+			condition := &class.Match[j]
+			if definition, ok := config.Definitions[condition.Option]; ok {
+				condition.Code = definition.Code
+			} else if code, ok := standardOptionCodes[condition.Option]; ok {
+				condition.Code = code
+			} else {
+				return fmt.Errorf("class %q: unknown option %s, declare it with option %s code <n> = <type>",
+					class.Name, condition.Option, condition.Option)
+			}
+		}
+	}
+	return nil
+}
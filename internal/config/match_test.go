@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseMatchClasses(t *testing.T) {
+	configContent := `option arch code 93 = unsigned integer 16;
+option wpad-url code 252 = text;
+option wpad-url "http://wpad.example.com/wpad.dat";
+
+filename "pxelinux.0";
+
+class "uefi" {
+  match if option arch = 00:07;
+  filename "shimx64.efi";
+}
+
+class "ipxe" {
+  match if exists user-class and option user-class = "iPXE";
+  filename "http://boot.example.com/boot.ipxe";
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if definition := cfg.Definitions["wpad-url"]; definition.Code != 252 || definition.Type != "text" {
+		t.Errorf("Unexpected wpad-url definition %+v", definition)
+	}
+	if definition := cfg.Definitions["arch"]; definition.Code != 93 || definition.Type != "unsigned integer 16" {
+		t.Errorf("Unexpected arch definition %+v", definition)
+	}
+	if value := cfg.Options["wpad-url"]; value != "http://wpad.example.com/wpad.dat" {
+		t.Errorf("Expected wpad-url value, got %q", value)
+	}
+
+	if len(cfg.Classes) != 2 {
+		t.Fatalf("Expected 2 classes, got %d", len(cfg.Classes))
+	}
+	uefi := cfg.Classes[0]
+	if len(uefi.Match) != 1 || uefi.Match[0].Code != 93 || !bytes.Equal(uefi.Match[0].Value, []byte{0, 7}) {
+		t.Errorf("Unexpected uefi condition %+v", uefi.Match)
+	}
+	if uefi.Boot.Filename != "shimx64.efi" {
+		t.Errorf("Unexpected uefi boot file %q", uefi.Boot.Filename)
+	}
+	ipxe := cfg.Classes[1]
+	if len(ipxe.Match) != 2 {
+		t.Fatalf("Expected 2 conditions, got %+v", ipxe.Match)
+	}
+	if ipxe.Match[0].Code != 77 || ipxe.Match[0].Value != nil {
+		t.Errorf("Expected exists user-class, got %+v", ipxe.Match[0])
+	}
+	if ipxe.Match[1].Code != 77 || string(ipxe.Match[1].Value) != "iPXE" {
+		t.Errorf("Expected user-class = iPXE, got %+v", ipxe.Match[1])
+	}
+}
+
+func TestParseMatchClassErrors(t *testing.T) {
+	for _, content := range []string{
+		// Опция без объявления
+		"class \"uefi\" {\n  match if option arch = 00:07;\n}\n",
+		// Неподдерживаемые выражения
+		"class \"pxe\" {\n  match if option user-class = \"iPXE\" or exists user-class;\n}\n",
+		"class \"pxe\" {\n  match if option user-class = zz;\n}\n",
+		// Повторный match if
+		"class \"pxe\" {\n  match if exists user-class;\n  match if exists vendor-class-identifier;\n}\n",
+		// Некорректный код опции
+		"option arch code 300 = unsigned integer 16;\n",
+		// Класс с match if в правилах доступа
+		"class \"ipxe\" {\n  match if exists user-class;\n}\nallow members of \"ipxe\";\n",
+	} {
+		if _, err := ParseConfig(writeTestConfig(t, content)); err == nil {
+			t.Errorf("Expected parse error for %q", content)
+		}
+	}
+}
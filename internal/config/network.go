@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseNetwork разбирает адрес подсети в нотации CIDR (192.168.1.0/24).
+// Биты узла в адресе сбрасываются. Поддерживаются только подсети IPv4.
+func ParseNetwork(cidr string) (*net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid IPv4 network: %s", cidr)
+	}
+	network.IP = network.IP.To4()
+	return network, nil
+}
+
+// MustParseNetwork как ParseNetwork, но паникует при ошибке. Предназначена
+// для конфигураций, собираемых в коде.
+func MustParseNetwork(cidr string) *net.IPNet {
+	network, err := ParseNetwork(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// parseSubnetDeclaration разбирает адрес подсети из объявления subnet:
+// "192.168.1.0 netmask 255.255.255.0" (синтаксис ISC-DHCP) или
+// "192.168.1.0/24"
+func parseSubnetDeclaration(parts []string) (*net.IPNet, error) {
+	switch {
+	case len(parts) == 1 && strings.Contains(parts[0], "/"):
+		return ParseNetwork(parts[0])
+	case len(parts) == 3 && parts[1] == "netmask":
+		ip, mask := net.ParseIP(parts[0]).To4(), net.ParseIP(parts[2]).To4()
+		if ip == nil || mask == nil {
+			return nil, fmt.Errorf("invalid subnet %s netmask %s", parts[0], parts[2])
+		}
+		// Маска должна состоять из непрерывных единиц
+		if _, bits := net.IPMask(mask).Size(); bits == 0 {
+			return nil, fmt.Errorf("invalid netmask: %s", parts[2])
+		}
+		return &net.IPNet{IP: ip.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)}, nil
+	}
+	return nil, fmt.Errorf("expected subnet <address> netmask <mask> or subnet <address>/<prefix>")
+}
+
+// parseRangeCIDR преобразует диапазон, заданный блоком CIDR
+// (range 192.168.1.128/25), в первый и последний адреса. Адрес сети и
+// широковещательный адрес подсети в диапазон не входят.
+func parseRangeCIDR(cidr string, subnet *net.IPNet) (string, string, error) {
+	block, err := ParseNetwork(cidr)
+	if err != nil {
+		return "", "", err
+	}
+	if subnet == nil || !subnet.Contains(block.IP) || !sameOrNarrower(block, subnet) {
+		return "", "", fmt.Errorf("range %s is outside of the subnet", cidr)
+	}
+
+	first := binary.BigEndian.Uint32(block.IP)
+	last := first | ^binary.BigEndian.Uint32(net.IP(block.Mask).To4())
+	base := binary.BigEndian.Uint32(subnet.IP.To4())
+	broadcast := base | ^binary.BigEndian.Uint32(net.IP(subnet.Mask).To4())
+	if first == base {
+		first++
+	}
+	if last == broadcast {
+		last--
+	}
+	if first > last {
+		return "", "", fmt.Errorf("range %s has no host addresses", cidr)
+	}
+	return uint32ToIP(first).String(), uint32ToIP(last).String(), nil
+}
+
+// parseFixedAddress разбирает фиксированный адрес хоста. Адрес может быть
+// записан с длиной префикса (192.168.1.10/24); для хоста в подсети
+// префикс должен совпадать с подсетью.
+func parseFixedAddress(value string, subnet *net.IPNet) (string, error) {
+	if !strings.Contains(value, "/") {
+		if net.ParseIP(value).To4() == nil {
+			return "", fmt.Errorf("fixed-address must be an IPv4 address: %s", value)
+		}
+		return value, nil
+	}
+	ip, network, err := net.ParseCIDR(value)
+	if err != nil || ip.To4() == nil {
+		return "", fmt.Errorf("invalid fixed-address: %s", value)
+	}
+	if subnet != nil && network.String() != subnet.String() {
+		return "", fmt.Errorf("fixed-address %s does not match subnet %s", value, subnet)
+	}
+	return ip.To4().String(), nil
+}
+
+// sameOrNarrower проверяет, что префикс блока не короче префикса подсети
+func sameOrNarrower(block, subnet *net.IPNet) bool {
+	blockOnes, _ := block.Mask.Size()
+	subnetOnes, _ := subnet.Mask.Size()
+	return blockOnes >= subnetOnes
+}
+
+// uint32ToIP преобразует адрес из числа в net.IP
+func uint32ToIP(ip uint32) net.IP {
+	result := make(net.IP, 4)
+	binary.BigEndian.PutUint32(result, ip)
+	return result
+}
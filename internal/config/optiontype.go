@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// VendorOptionSpace имя оператора vendor-option-space. Оператор хранится
+// среди опций своего уровня и наследуется так же, как опции.
+const VendorOptionSpace = "vendor-option-space"
+
+// standardOptions опции RFC 2132 и распространенные частные опции, которые сервер передает клиентам без
+// объявления option ... code. Опции загрузки, имени хоста и срока аренды
+// обрабатываются отдельно.
+var standardOptions = map[string]OptionDefinition{
+	"subnet-mask":          {Code: 1, Type: "ip-address"},
+	"time-offset":          {Code: 2, Type: "signed integer 32"},
+	"routers":              {Code: 3, Type: "array of ip-address"},
+	"domain-name-servers":  {Code: 6, Type: "array of ip-address"},
+	"log-servers":          {Code: 7, Type: "array of ip-address"},
+	"domain-name":          {Code: 15, Type: "text"},
+	"root-path":            {Code: 17, Type: "text"},
+	"interface-mtu":        {Code: 26, Type: "unsigned integer 16"},
+	"broadcast-address":    {Code: 28, Type: "ip-address"},
+	"static-routes":        {Code: 33, Type: staticRoutesType},
+	"ntp-servers":          {Code: 42, Type: "array of ip-address"},
+	"netbios-name-servers": {Code: 44, Type: "array of ip-address"},
+	"www-server":           {Code: 72, Type: "array of ip-address"},
+	"wpad-url":             {Code: 252, Type: "text"}, // Адрес файла автонастройки прокси
+
+	"classless-static-routes":         {Code: 121, Type: classlessRoutesType},
+	"rfc3442-classless-static-routes": {Code: 121, Type: classlessRoutesType},
+}
+
+// Типы встроенных опций маршрутов. В объявлениях option ... code они не
+// используются.
+const (
+	// Пары "<сеть> <маршрутизатор>" через запятую (опция 33)
+	staticRoutesType = "static routes"
+	// Пары "<сеть>/<длина> <маршрутизатор>" через запятую, кодируются по
+	// RFC 3442 (опция 121)
+	classlessRoutesType = "classless static routes"
+)
+
+// LookupOption возвращает объявление опции: из конфигурации (option <имя>
+// code <код> = <тип>) или стандартное
+func LookupOption(cfg *DHCPConfig, name string) (OptionDefinition, bool) {
+	if definition, ok := cfg.Definitions[name]; ok {
+		return definition, true
+	}
+	definition, ok := standardOptions[name]
+	return definition, ok
+}
+
+// integerOptionType разбирает тип "unsigned integer N" или "signed integer
+// N". Возвращает разрядность (0 - тип не целочисленный) и знаковость.
+func integerOptionType(typ string) (int, bool) {
+	fields := strings.Fields(typ)
+	if len(fields) != 3 || fields[1] != "integer" || (fields[0] != "unsigned" && fields[0] != "signed") {
+		return 0, false
+	}
+	switch fields[2] {
+	case "8", "16", "32":
+		bits, _ := strconv.Atoi(fields[2])
+		return bits, fields[0] == "signed"
+	}
+	return 0, false
+}
+
+// checkOptionType проверяет, что тип из объявления опции поддерживается
+func checkOptionType(typ string) error {
+	switch typ {
+	case "text", "string", "boolean", "ip-address", "array of ip-address":
+		return nil
+	}
+	if bits, _ := integerOptionType(strings.TrimPrefix(typ, "array of ")); bits != 0 {
+		return nil
+	}
+	return fmt.Errorf("unsupported option type: %s", typ)
+}
+
+// EncodeOption кодирует значение опции по типу из ее объявления
+func EncodeOption(typ, value string) ([]byte, error) {
+	switch typ {
+	case "text":
+		return []byte(value), nil
+	case "string":
+		// Байты через двоеточие или строка
+		if data, ok := parseOptionData(value); ok && strings.Contains(value, ":") {
+			return data, nil
+		}
+		return []byte(value), nil
+	case "boolean":
+		switch value {
+		case "true", "on":
+			return []byte{1}, nil
+		case "false", "off":
+			return []byte{0}, nil
+		}
+		return nil, fmt.Errorf("invalid boolean: %s", value)
+	case "ip-address":
+		ip := net.ParseIP(value).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address: %s", value)
+		}
+		return ip, nil
+	case "array of ip-address":
+		var data []byte
+		for _, item := range strings.Split(value, ",") {
+			ip := net.ParseIP(strings.TrimSpace(item)).To4()
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IPv4 address: %s", strings.TrimSpace(item))
+			}
+			data = append(data, ip...)
+		}
+		return data, nil
+	case staticRoutesType:
+		return encodeRoutes(value, false)
+	case classlessRoutesType:
+		return encodeRoutes(value, true)
+	}
+
+	// Массив целых чисел через запятую
+	if element := strings.TrimPrefix(typ, "array of "); element != typ {
+		var data []byte
+		for _, item := range strings.Split(value, ",") {
+			number, err := encodeInteger(element, strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, number...)
+		}
+		return data, nil
+	}
+	return encodeInteger(typ, value)
+}
+
+// encodeInteger кодирует целое число типа "unsigned integer N" или
+// "signed integer N"
+func encodeInteger(typ, value string) ([]byte, error) {
+	bits, signed := integerOptionType(typ)
+	if bits == 0 {
+		return nil, fmt.Errorf("unsupported option type: %s", typ)
+	}
+	var number uint64
+	if signed {
+		n, err := strconv.ParseInt(value, 0, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", typ, value)
+		}
+		number = uint64(n)
+	} else {
+		n, err := strconv.ParseUint(value, 0, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", typ, value)
+		}
+		number = n
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, number)
+	return data[8-bits/8:], nil
+}
+
+// encodeRoutes кодирует маршруты "<сеть> <маршрутизатор>, ...". Для
+// опции 33 сеть - адрес, для опции 121 - блок CIDR, который кодируется
+// длиной префикса и значащими октетами адреса сети (RFC 3442, раздел 3).
+func encodeRoutes(value string, classless bool) ([]byte, error) {
+	var data []byte
+	for _, item := range strings.Split(value, ",") {
+		fields := strings.Fields(item)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid route: %s", strings.TrimSpace(item))
+		}
+		router := net.ParseIP(fields[1]).To4()
+		if router == nil {
+			return nil, fmt.Errorf("invalid IPv4 address: %s", fields[1])
+		}
+
+		if !classless {
+			destination := net.ParseIP(fields[0]).To4()
+			if destination == nil {
+				return nil, fmt.Errorf("invalid IPv4 address: %s", fields[0])
+			}
+			data = append(data, destination...)
+			data = append(data, router...)
+			continue
+		}
+
+		ip, network, err := net.ParseCIDR(fields[0])
+		if err != nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid route destination: %s", fields[0])
+		}
+		if !ip.Equal(network.IP) {
+			return nil, fmt.Errorf("route destination %s has host bits set", fields[0])
+		}
+		ones, _ := network.Mask.Size()
+		data = append(data, byte(ones))
+		data = append(data, network.IP.To4()[:(ones+7)/8]...)
+		data = append(data, router...)
+	}
+	return data, nil
+}
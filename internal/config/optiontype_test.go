@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeOption(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		want  []byte
+	}{
+		{"text", "http://example.com/", []byte("http://example.com/")},
+		{"string", "01:02:0a", []byte{1, 2, 10}},
+		{"string", "acme", []byte("acme")},
+		{"boolean", "on", []byte{1}},
+		{"boolean", "false", []byte{0}},
+		{"ip-address", "10.0.0.1", []byte{10, 0, 0, 1}},
+		{"array of ip-address", "10.0.0.1, 10.0.0.2", []byte{10, 0, 0, 1, 10, 0, 0, 2}},
+		{"unsigned integer 8", "200", []byte{200}},
+		{"unsigned integer 16", "0x1234", []byte{0x12, 0x34}},
+		{"unsigned integer 32", "3600", []byte{0, 0, 0x0e, 0x10}},
+		{"signed integer 16", "-2", []byte{0xff, 0xfe}},
+		{"array of unsigned integer 16", "1, 0x0203", []byte{0, 1, 2, 3}},
+		{staticRoutesType, "10.1.0.0 192.168.1.1, 10.2.0.0 192.168.1.2", []byte{10, 1, 0, 0, 192, 168, 1, 1, 10, 2, 0, 0, 192, 168, 1, 2}},
+		// RFC 3442: длина префикса и только значащие октеты сети
+		{classlessRoutesType, "10.0.0.0/8 192.168.1.1, 172.16.32.0/20 192.168.1.2, 0.0.0.0/0 192.168.1.254", []byte{
+			8, 10, 192, 168, 1, 1,
+			20, 172, 16, 32, 192, 168, 1, 2,
+			0, 192, 168, 1, 254,
+		}},
+		{classlessRoutesType, "192.168.2.7/32 192.168.1.1", []byte{32, 192, 168, 2, 7, 192, 168, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		got, err := EncodeOption(tt.typ, tt.value)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeOption(%s, %s) = %x, %v, expected %x", tt.typ, tt.value, got, err, tt.want)
+		}
+	}
+
+	invalid := []struct{ typ, value string }{
+		{"boolean", "yes"},
+		{"ip-address", "printer"},
+		{"array of ip-address", "10.0.0.1,"},
+		{"unsigned integer 8", "256"},
+		{"signed integer 8", "128"},
+		{"unsigned integer 12", "1"},
+		{"array of unsigned integer 8", "1, 256"},
+		{"array of text", "a"},
+		{staticRoutesType, "10.1.0.0"},
+		{classlessRoutesType, "10.0.0.0 192.168.1.1"},
+		{classlessRoutesType, "10.0.0.1/8 192.168.1.1"},
+		{classlessRoutesType, "10.0.0.0/8 gateway"},
+	}
+	for _, tt := range invalid {
+		if _, err := EncodeOption(tt.typ, tt.value); err == nil {
+			t.Errorf("EncodeOption(%s, %s): expected error", tt.typ, tt.value)
+		}
+	}
+}
+
+func TestParseOptionSpace(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, `option provisioning-url code 224 = text;
+option acme.server code 1 = ip-address;
+option space acme;
+option provisioning-url "http://provision.example.com/";
+
+class "acme-phones" {
+  match hardware prefix 00:1a:2b;
+  vendor-option-space acme;
+  option acme.server 10.0.0.5;
+}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if len(cfg.Spaces) != 1 || cfg.Spaces[0] != "acme" {
+		t.Errorf("Expected option space acme, got %v", cfg.Spaces)
+	}
+	if definition := cfg.Definitions["acme.server"]; definition.Code != 1 || definition.Type != "ip-address" {
+		t.Errorf("Unexpected acme.server definition %+v", definition)
+	}
+	class := cfg.Classes[0]
+	if class.Options[VendorOptionSpace] != "acme" || class.Options["acme.server"] != "10.0.0.5" {
+		t.Errorf("Unexpected class options %v", class.Options)
+	}
+
+	for _, content := range []string{
+		"option acme.server code 1 = ip-address;\n",
+		"option space acme code width 2;\n",
+		"option arch code 93 = encapsulate acme;\n",
+	} {
+		if _, err := ParseConfig(writeTestConfig(t, content)); err == nil {
+			t.Errorf("Expected parse error for %q", content)
+		}
+	}
+}
@@ -3,37 +3,144 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+
+	"github.com/sirupsen/logrus"
 )
 
 // DHCPConfig представляет конфигурацию ISC-DHCP
 type DHCPConfig struct {
 	Subnets       []Subnet
 	Hosts         []Host
+	Classes       []Class
 	GlobalOptions map[string]string
+	Options       map[string]string // Глобальные DHCP опции (option ...)
+	Access        AccessRules
+	// Объявления опций: option <имя> code <код> = <тип>;
+	Definitions   map[string]OptionDefinition
+	Spaces        []string   // Пространства опций: option space <имя>;
+	Boot          BootParams // Глобальные next-server и filename
+	Authoritative *bool      // authoritative; или not authoritative; (nil - не задано)
+	Comment       string     // Комментарий в начале файла, отделенный пустой строкой
+
+	Instances []Instance // Логические серверы: instance "<имя>" "<файл>";
+}
+
+// Instance представляет оператор instance: логический сервер со своей
+// конфигурацией из отдельного файла (интерфейсы, подсети, аренды, хуки).
+// Относительный путь отсчитывается от каталога основной конфигурации.
+type Instance struct {
+	Name    string
+	File    string
+	Comment string
+}
+
+// BootParams представляет операторы next-server, server-name и filename,
+// задающие поля siaddr, sname и file ответа. Пустое значение означает,
+// что оператор не задан.
+type BootParams struct {
+	NextServer string
+	ServerName string
+	Filename   string
+}
+
+// AccessRules представляет правила доступа клиентов (allow/deny)
+type AccessRules struct {
+	KnownClients   string   // "allow", "deny" или "" если не задано
+	UnknownClients string   // "allow", "deny" или "" если не задано
+	BOOTP          string   // allow/deny bootp: "allow", "deny" или "" если не задано
+	AllowMACs      []string // MAC адреса или OUI префиксы вида 00:1a:2b:*
+	DenyMACs       []string // MAC адреса или OUI префиксы вида 00:1a:2b:*
+	AllowClasses   []string // allow members of "класс"
+	DenyClasses    []string // deny members of "класс"
 }
 
 // Subnet представляет подсеть в конфигурации
 type Subnet struct {
-	Network    string
-	Netmask    string
-	RangeStart string
-	RangeEnd   string
-	Options    map[string]string
-	Hosts      []Host
+	Network       *net.IPNet // Адрес и маска подсети
+	Ranges        []Range    // Диапазоны динамических адресов в порядке объявления
+	Exclusions    []Range    // Адреса диапазонов, которые не выдаются (exclude)
+	Options       map[string]string
+	Hosts         []Host
+	Access        AccessRules
+	Boot          BootParams
+	Authoritative *bool // Переопределяет глобальный authoritative (nil - не задано)
+	Comment       string
+}
+
+// Range представляет оператор range: диапазон динамических адресов.
+// В подсети может быть несколько диапазонов. Оператор exclude задается
+// тем же типом без DynamicBOOTP и RandomizedMAC; для одного адреса End совпадает со Start.
+type Range struct {
+	Start        string
+	End          string
+	DynamicBOOTP bool // range dynamic-bootp: диапазон выдается и BOOTP клиентам
+
+	// range randomized-mac: диапазон выдается только клиентам со
+	// случайными MAC адресами
+	RandomizedMAC bool
 }
 
-// Host представляет хост в конфигурации
+// Class представляет класс клиентов (блок class с "match hardware" или
+// "match if"). Члены класса задаются операторами subclass, префиксами MAC
+// адресов (match hardware prefix) или условиями на опции запроса (match
+// if), опции и параметры загрузки класса применяются ко всем его членам.
+type Class struct {
+	Name     string
+	Hardware []string          // MAC адреса членов класса в нижнем регистре
+	Prefixes []string          // Префиксы MAC адресов вида 00:1a:2b:*
+	Match    []ClassCondition  // Условия match if по опциям запроса (все должны выполняться)
+	Options  map[string]string // DHCP опции класса
+	Boot     BootParams
+	Comment  string
+}
+
+// Host представляет хост в конфигурации. Comment в хостах, подсетях и
+// классах - строки комментариев (вместе с #), стоящие непосредственно
+// перед объявлением.
 type Host struct {
 	Name     string
 	Hardware string
 	Address  string
 	FixedIP  string
+	ClientID string // Идентификатор клиента (option dhcp-client-identifier)
 	Options  map[string]string
+	Boot     BootParams
+	Comment  string
+}
+
+// ParseError ошибка в тексте конфигурации с ее положением в файле
+type ParseError struct {
+	File string // Имя файла (пусто - текст не из файла)
+	Line int    // Номер строки с 1 (0 - ошибка не относится к строке)
+	Col  int    // Номер столбца с 1 (0 - неизвестен)
+	Msg  string
+}
+
+// Error возвращает ошибку в виде "файл:строка:столбец: сообщение"
+func (e *ParseError) Error() string {
+	var position []string
+	if e.File != "" {
+		position = append(position, e.File)
+	}
+	if e.Line > 0 {
+		position = append(position, strconv.Itoa(e.Line))
+		if e.Col > 0 {
+			position = append(position, strconv.Itoa(e.Col))
+		}
+	}
+	if len(position) == 0 {
+		return e.Msg
+	}
+	return strings.Join(position, ":") + ": " + e.Msg
 }
 
-// ParseConfig парсит конфигурационный файл ISC-DHCP
+// ParseConfig парсит конфигурационный файл ISC-DHCP. Ошибки в тексте
+// конфигурации возвращаются как *ParseError.
 func ParseConfig(filename string) (*DHCPConfig, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -41,10 +148,25 @@ func ParseConfig(filename string) (*DHCPConfig, error) {
 	}
 	defer file.Close()
 
+	return parseConfig(file, filename)
+}
+
+// Parse разбирает текст конфигурации ISC-DHCP, прочитанный не из файла
+// (например, из переменной окружения). name используется в ошибках
+// вместо имени файла.
+func Parse(r io.Reader, name string) (*DHCPConfig, error) {
+	return parseConfig(r, name)
+}
+
+// parseConfig разбирает текст конфигурации; filename используется в ошибках
+func parseConfig(r io.Reader, filename string) (*DHCPConfig, error) {
+	var err error
 	config := &DHCPConfig{
 		Subnets:       make([]Subnet, 0),
 		Hosts:         make([]Host, 0),
 		GlobalOptions: make(map[string]string),
+		Options:       make(map[string]string),
+		Definitions:   make(map[string]OptionDefinition),
 	}
 
 	// Состояния парсера
@@ -53,40 +175,89 @@ func ParseConfig(filename string) (*DHCPConfig, error) {
 		StateSubnet
 		StateHostInSubnet
 		StateHostGlobal
+		StateClass
+		StateGroupGlobal
+		StateGroupInSubnet
 	)
 
 	state := StateGlobal
 	currentSubnet := Subnet{}
 	currentHost := Host{}
+	currentClass := Class{}
+	subclasses := make(map[string][]string) // Члены классов, объявленные через subclass
+	subclassLines := make(map[string]int)   // Строка первого subclass каждого класса
+	// Открытый блок group (nil - вне группы)
+	var currentGroup *group
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	lineNumber := 0
+	var comments []string // Строки комментариев перед текущей строкой
+	statements := 0
+	// Положение открытого блока верхнего уровня
+	blockLine, blockCol := 0, 0
+
+	// fail возвращает ошибку в текущей строке. Столбец указывает на token,
+	// если он найден в строке, иначе на начало оператора.
+	fail := func(token, format string, args ...interface{}) error {
+		text := scanner.Text()
+		col := statementColumn(text)
+		if i := strings.Index(text, token); token != "" && i >= 0 {
+			col = i + 1
+		}
+		return &ParseError{File: filename, Line: lineNumber, Col: col, Msg: fmt.Sprintf(format, args...)}
+	}
 
 	for scanner.Scan() {
 		lineNumber++
-		line := strings.TrimSpace(scanner.Text())
+		if raw := strings.TrimSpace(scanner.Text()); strings.HasPrefix(raw, "#") {
+			comments = append(comments, raw)
+			continue
+		}
+		line := strings.TrimSpace(stripComment(scanner.Text()))
 
-		// Пропускаем пустые строки и комментарии
-		if line == "" || strings.HasPrefix(line, "#") {
+		// Комментарий, отделенный пустой строкой, относится к файлу, если
+		// стоит в его начале, иначе не сохраняется
+		if line == "" {
+			if statements == 0 && len(comments) > 0 {
+				if config.Comment != "" {
+					config.Comment += "\n\n"
+				}
+				config.Comment += strings.Join(comments, "\n")
+			}
+			comments = nil
 			continue
 		}
+		comment := strings.Join(comments, "\n")
+		comments = nil
+		statements++
+
+		// Каждая строка должна завершать оператор, открывать или закрывать блок
+		if !strings.HasSuffix(line, ";") && !strings.HasSuffix(line, "{") && !strings.HasSuffix(line, "}") {
+			return nil, fail("", "expected ';' at end of statement: %s", line)
+		}
 
 		// Убираем точку с запятой в конце для обработки
 		trimmedLine := strings.TrimSuffix(line, ";")
 
 		// Отладочный вывод
-		fmt.Printf("Line %d: State=%d, Line='%s'\n", lineNumber, state, line)
+		logrus.Debugf("Line %d: State=%d, Line='%s'", lineNumber, state, line)
+
+		// Незакрытый блок указывается по строке, которая его открыла
+		if state == StateGlobal {
+			blockLine, blockCol = lineNumber, statementColumn(scanner.Text())
+		}
 
 		switch state {
 		case StateGlobal:
 			// Проверяем начало подсети с учетом пробелов перед {
 			if strings.HasPrefix(line, "subnet ") && strings.Contains(line, "{") {
 				// Начало подсети
-				fmt.Printf("  -> Starting subnet block\n")
+				logrus.Debugf("  -> Starting subnet block")
 				state = StateSubnet
 				currentSubnet = Subnet{
 					Options: make(map[string]string),
 					Hosts:   make([]Host, 0),
+					Comment: comment,
 				}
 
 				// Убираем { и все после нее, затем убираем концевые пробелы
@@ -95,171 +266,739 @@ func ParseConfig(filename string) (*DHCPConfig, error) {
 					subnetDecl := strings.TrimSpace(line[:blockStart])
 					// Парсим параметры подсети
 					parts := strings.Fields(subnetDecl)
-					fmt.Printf("  -> Subnet parts: %v (len=%d)\n", parts, len(parts))
+					logrus.Debugf("  -> Subnet parts: %v (len=%d)", parts, len(parts))
 					// parts = [subnet 192.168.1.0 netmask 255.255.255.0]
-					// indices: 0      1            2       3
-					if len(parts) == 4 && parts[2] == "netmask" {
-						currentSubnet.Network = parts[1] // IP адрес сети
-						currentSubnet.Netmask = parts[3] // Маска подсети
-						fmt.Printf("  -> Network: %s, Netmask: %s\n", currentSubnet.Network, currentSubnet.Netmask)
+					// или     [subnet 192.168.1.0/24]
+					network, err := parseSubnetDeclaration(parts[1:])
+					if err != nil {
+						return nil, fail(strings.Join(parts[1:], " "), "invalid subnet declaration: %s: %v", line, err)
 					}
+					currentSubnet.Network = network
+					logrus.Debugf("  -> Network: %s", currentSubnet.Network)
 				}
 			} else if strings.HasPrefix(line, "host ") && strings.Contains(line, "{") {
 				// Начало глобального хоста
-				fmt.Printf("  -> Starting global host block\n")
+				logrus.Debugf("  -> Starting global host block")
 				state = StateHostGlobal
-				// Убираем { и все после нее, затем убираем концевые пробелы
-				blockStart := strings.Index(line, "{")
-				if blockStart > 0 {
-					hostDecl := strings.TrimSpace(line[:blockStart])
-					parts := strings.Fields(hostDecl)
-					fmt.Printf("  -> Host parts: %v (len=%d)\n", parts, len(parts))
-					if len(parts) >= 2 {
-						currentHost = Host{
-							Name:    parts[1],
-							Options: make(map[string]string),
-						}
-						fmt.Printf("  -> Host name: %s\n", currentHost.Name)
+				if currentHost, err = parseHostDeclaration(line); err != nil {
+					return nil, fail("", "%v", err)
+				}
+				currentHost.Comment = comment
+			} else if isGroupDeclaration(line) {
+				// Начало группы глобальных хостов
+				logrus.Debugf("  -> Starting global group block")
+				state = StateGroupGlobal
+				currentGroup = newGroup()
+			} else if strings.HasPrefix(line, "class ") && strings.HasSuffix(line, "{") {
+				// Начало класса
+				logrus.Debugf("  -> Starting class block")
+				name := unquote(strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "class "), "{")))
+				if name == "" {
+					return nil, fail("", "class declaration without name: %s", line)
+				}
+				state = StateClass
+				currentClass = Class{Name: name, Options: make(map[string]string), Comment: comment}
+				logrus.Debugf("  -> Class name: %s", currentClass.Name)
+			} else if instance, ok, err := parseInstanceStatement(trimmedLine); err != nil {
+				return nil, fail("", "%v", err)
+			} else if ok {
+				// Логический сервер
+				for _, existing := range config.Instances {
+					if existing.Name == instance.Name {
+						return nil, fail("", "duplicate instance %q", instance.Name)
 					}
 				}
+				instance.Comment = comment
+				config.Instances = append(config.Instances, instance)
+				logrus.Debugf("  -> Instance %s: %s", instance.Name, instance.File)
+			} else if strings.HasPrefix(trimmedLine, "subclass ") {
+				// Член класса: subclass "name" 1:00:11:22:33:44:55;
+				parts := strings.Fields(trimmedLine)
+				if len(parts) != 3 {
+					return nil, fail("", "invalid subclass: %s", line)
+				}
+				name := unquote(parts[1])
+				mac, err := subclassMAC(parts[2])
+				if err != nil {
+					return nil, fail("", "%v", err)
+				}
+				subclasses[name] = append(subclasses[name], mac)
+				if subclassLines[name] == 0 {
+					subclassLines[name] = lineNumber
+				}
+				logrus.Debugf("  -> Subclass %s: %s", name, parts[2])
+			} else if ok, err := parseAccessStatement(trimmedLine, &config.Access); err != nil {
+				return nil, fail("", "%v", err)
+			} else if ok {
+				// Глобальное правило доступа
+				logrus.Debugf("  -> Global access rule: %s", trimmedLine)
+			} else if parseBootStatement(trimmedLine, &config.Boot) {
+				// Глобальные параметры загрузки
+				logrus.Debugf("  -> Global boot parameter: %s", trimmedLine)
+			} else if authoritative, ok := parseAuthoritativeStatement(trimmedLine); ok {
+				config.Authoritative = &authoritative
+				logrus.Debugf("  -> Global authoritative: %v", authoritative)
+			} else if space, ok, err := parseOptionSpace(trimmedLine); err != nil {
+				return nil, fail("", "%v", err)
+			} else if ok {
+				// Объявление пространства опций
+				config.Spaces = append(config.Spaces, space)
+				logrus.Debugf("  -> Option space: %s", space)
+			} else if parseVendorOptionSpace(trimmedLine, config.Options) {
+				logrus.Debugf("  -> Global vendor option space: %s", config.Options[VendorOptionSpace])
+			} else if name, definition, ok, err := parseOptionDefinition(trimmedLine); err != nil {
+				return nil, fail("", "%v", err)
+			} else if ok {
+				// Объявление опции
+				config.Definitions[name] = definition
+				logrus.Debugf("  -> Option definition: %s = code %d (%s)", name, definition.Code, definition.Type)
+			} else if strings.HasPrefix(trimmedLine, "option ") {
+				// Глобальная DHCP опция
+				key, value, ok := parseOptionStatement(trimmedLine)
+				if !ok {
+					return nil, fail("", "option without value: %s", line)
+				}
+				config.Options[key] = value
+				logrus.Debugf("  -> Global DHCP option: %s = %s", key, value)
 			} else if strings.Contains(line, " ") && !strings.Contains(line, "{") && strings.HasSuffix(line, ";") {
 				// Глобальная опция
-				fmt.Printf("  -> Processing global option with value\n")
+				logrus.Debugf("  -> Processing global option with value")
 				parts := strings.SplitN(trimmedLine, " ", 2)
-				fmt.Printf("  -> Global option parts: %v (len=%d)\n", parts, len(parts))
+				logrus.Debugf("  -> Global option parts: %v (len=%d)", parts, len(parts))
 				if len(parts) == 2 {
 					config.GlobalOptions[parts[0]] = parts[1]
-					fmt.Printf("  -> Global option: %s = %s\n", parts[0], parts[1])
+					logrus.Debugf("  -> Global option: %s = %s", parts[0], parts[1])
 				}
 			} else if strings.HasSuffix(line, ";") && !strings.Contains(line, " ") {
-				// Глобальная опция без значения (например, authoritative;)
-				fmt.Printf("  -> Processing global option without value\n")
+				// Глобальная опция без значения (например, ddns-updates;)
+				logrus.Debugf("  -> Processing global option without value")
 				config.GlobalOptions[trimmedLine] = ""
-				fmt.Printf("  -> Global option: %s = ''\n", trimmedLine)
+				logrus.Debugf("  -> Global option: %s = ''", trimmedLine)
+			} else {
+				return nil, fail("", "unexpected statement: %s", line)
 			}
 
 		case StateSubnet:
 			if strings.HasPrefix(line, "}") {
 				// Конец подсети
-				fmt.Printf("  -> Ending subnet block\n")
+				logrus.Debugf("  -> Ending subnet block")
 				config.Subnets = append(config.Subnets, currentSubnet)
 				state = StateGlobal
 			} else if strings.HasPrefix(line, "host ") && strings.Contains(line, "{") {
 				// Начало хоста в подсети
-				fmt.Printf("  -> Starting host in subnet block\n")
+				logrus.Debugf("  -> Starting host in subnet block")
 				state = StateHostInSubnet
-				// Убираем { и все после нее, затем убираем концевые пробелы
-				blockStart := strings.Index(line, "{")
-				if blockStart > 0 {
-					hostDecl := strings.TrimSpace(line[:blockStart])
-					parts := strings.Fields(hostDecl)
-					fmt.Printf("  -> Host parts: %v (len=%d)\n", parts, len(parts))
-					if len(parts) >= 2 {
-						currentHost = Host{
-							Name:    parts[1],
-							Options: make(map[string]string),
-						}
-						fmt.Printf("  -> Host name: %s\n", currentHost.Name)
-					}
+				if currentHost, err = parseHostDeclaration(line); err != nil {
+					return nil, fail("", "%v", err)
 				}
+				currentHost.Comment = comment
+			} else if isGroupDeclaration(line) {
+				// Начало группы хостов подсети
+				logrus.Debugf("  -> Starting group in subnet block")
+				state = StateGroupInSubnet
+				currentGroup = newGroup()
+			} else if ok, err := parseAccessStatement(trimmedLine, &currentSubnet.Access); err != nil {
+				return nil, fail("", "%v", err)
+			} else if ok {
+				// Правило доступа подсети
+				logrus.Debugf("  -> Subnet access rule: %s", trimmedLine)
+			} else if parseBootStatement(trimmedLine, &currentSubnet.Boot) {
+				// Параметры загрузки подсети
+				logrus.Debugf("  -> Subnet boot parameter: %s", trimmedLine)
+			} else if authoritative, ok := parseAuthoritativeStatement(trimmedLine); ok {
+				currentSubnet.Authoritative = &authoritative
+				logrus.Debugf("  -> Subnet authoritative: %v", authoritative)
 			} else if strings.HasPrefix(trimmedLine, "range ") {
 				// Диапазон IP адресов
-				fmt.Printf("  -> Processing range\n")
-				parts := strings.Fields(trimmedLine[6:]) // Убираем "range "
-				fmt.Printf("  -> Range parts: %v (len=%d)\n", parts, len(parts))
-				if len(parts) >= 2 {
-					currentSubnet.RangeStart = parts[0]
-					currentSubnet.RangeEnd = parts[1]
-					fmt.Printf("  -> Range: %s - %s\n", currentSubnet.RangeStart, currentSubnet.RangeEnd)
+				logrus.Debugf("  -> Processing range")
+				parts := strings.Fields(strings.TrimPrefix(trimmedLine, "range "))
+				addressRange := Range{}
+				if len(parts) > 0 && parts[0] == "dynamic-bootp" {
+					addressRange.DynamicBOOTP = true
+					parts = parts[1:]
+				} else if len(parts) > 0 && parts[0] == "randomized-mac" {
+					addressRange.RandomizedMAC = true
+					parts = parts[1:]
 				}
+				logrus.Debugf("  -> Range parts: %v (len=%d)", parts, len(parts))
+				switch {
+				case len(parts) == 1 && strings.Contains(parts[0], "/"):
+					// Диапазон в виде блока CIDR
+					start, end, err := parseRangeCIDR(parts[0], currentSubnet.Network)
+					if err != nil {
+						return nil, fail(parts[0], "%v", err)
+					}
+					addressRange.Start, addressRange.End = start, end
+				case len(parts) == 2:
+					addressRange.Start = parts[0]
+					addressRange.End = parts[1]
+				default:
+					return nil, fail("", "invalid range: %s", line)
+				}
+				if token := invalidAddress(addressRange.Start, addressRange.End); token != "" {
+					return nil, fail(token, "invalid address in range: %s", token)
+				}
+				currentSubnet.Ranges = append(currentSubnet.Ranges, addressRange)
+				logrus.Debugf("  -> Range: %s - %s", addressRange.Start, addressRange.End)
+			} else if strings.HasPrefix(trimmedLine, "exclude ") {
+				// Адрес или диапазон адресов, исключенный из выдачи
+				parts := strings.Fields(strings.TrimPrefix(trimmedLine, "exclude "))
+				switch len(parts) {
+				case 1:
+					currentSubnet.Exclusions = append(currentSubnet.Exclusions, Range{Start: parts[0], End: parts[0]})
+				case 2:
+					currentSubnet.Exclusions = append(currentSubnet.Exclusions, Range{Start: parts[0], End: parts[1]})
+				default:
+					return nil, fail("", "invalid exclude: %s", line)
+				}
+				if token := invalidAddress(parts...); token != "" {
+					return nil, fail(token, "invalid address in exclude: %s", token)
+				}
+				logrus.Debugf("  -> Exclude: %v", parts)
+			} else if parseDurationStatement(trimmedLine, currentSubnet.Options) {
+				logrus.Debugf("  -> Subnet lease time: %s", trimmedLine)
+			} else if parseVendorOptionSpace(trimmedLine, currentSubnet.Options) {
+				logrus.Debugf("  -> Subnet vendor option space: %s", currentSubnet.Options[VendorOptionSpace])
 			} else if strings.HasPrefix(trimmedLine, "option ") {
 				// Опция подсети
-				fmt.Printf("  -> Processing subnet option\n")
-				parts := strings.Fields(trimmedLine[7:]) // Убираем "option "
-				fmt.Printf("  -> Option parts: %v (len=%d)\n", parts, len(parts))
-				if len(parts) >= 2 {
-					// Объединяем все части после ключа в значение
-					key := parts[0]
-					value := strings.Join(parts[1:], " ")
-					// Убираем кавычки, если есть
-					value = strings.Trim(value, "\"")
-					currentSubnet.Options[key] = value
-					fmt.Printf("  -> Subnet option: %s = %s\n", key, value)
+				key, value, ok := parseOptionStatement(trimmedLine)
+				if !ok {
+					return nil, fail("", "option without value: %s", line)
 				}
+				currentSubnet.Options[key] = value
+				logrus.Debugf("  -> Subnet option: %s = %s", key, value)
+			} else {
+				return nil, fail("", "unknown statement in subnet block: %s", line)
 			}
 
 		case StateHostInSubnet:
 			if strings.HasPrefix(line, "}") {
 				// Конец хоста в подсети
-				fmt.Printf("  -> Ending host in subnet block\n")
-				currentSubnet.Hosts = append(currentSubnet.Hosts, currentHost)
-				state = StateSubnet
-			} else if strings.HasPrefix(trimmedLine, "hardware ethernet ") {
-				// MAC адрес
-				fmt.Printf("  -> Processing hardware ethernet\n")
-				currentHost.Hardware = strings.TrimSpace(trimmedLine[18:]) // Убираем "hardware ethernet "
-				fmt.Printf("  -> Hardware: %s\n", currentHost.Hardware)
+				logrus.Debugf("  -> Ending host in subnet block")
+				if currentGroup != nil {
+					currentGroup.Hosts = append(currentGroup.Hosts, currentHost)
+					state = StateGroupInSubnet
+				} else {
+					currentSubnet.Hosts = append(currentSubnet.Hosts, currentHost)
+					state = StateSubnet
+				}
+			} else if hardware, ok := parseHardwareStatement(trimmedLine); ok {
+				// Аппаратный адрес
+				logrus.Debugf("  -> Processing hardware address")
+				mac, err := NormalizeMAC(hardware)
+				if err != nil {
+					return nil, fail(hardware, "%v", err)
+				}
+				currentHost.Hardware = mac
+				logrus.Debugf("  -> Hardware: %s", currentHost.Hardware)
 			} else if strings.HasPrefix(trimmedLine, "fixed-address ") {
 				// Фиксированный IP адрес
-				fmt.Printf("  -> Processing fixed-address\n")
-				currentHost.FixedIP = strings.TrimSpace(trimmedLine[14:]) // Убираем "fixed-address "
-				fmt.Printf("  -> Fixed IP: %s\n", currentHost.FixedIP)
+				logrus.Debugf("  -> Processing fixed-address")
+				address := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "fixed-address "))
+				fixedIP, err := parseFixedAddress(address, currentSubnet.Network)
+				if err != nil {
+					return nil, fail(address, "%v", err)
+				}
+				currentHost.FixedIP = fixedIP
+				logrus.Debugf("  -> Fixed IP: %s", currentHost.FixedIP)
+			} else if parseBootStatement(trimmedLine, &currentHost.Boot) {
+				// Параметры загрузки хоста
+				logrus.Debugf("  -> Host boot parameter: %s", trimmedLine)
+			} else if strings.HasPrefix(trimmedLine, "option dhcp-client-identifier ") {
+				// Резервирование по идентификатору клиента
+				clientID, err := NormalizeClientID(strings.TrimSpace(strings.TrimPrefix(trimmedLine, "option dhcp-client-identifier ")))
+				if err != nil {
+					return nil, fail("", "%v", err)
+				}
+				currentHost.ClientID = clientID
+				logrus.Debugf("  -> Client identifier: %s", currentHost.ClientID)
+			} else if parseDurationStatement(trimmedLine, currentHost.Options) {
+				logrus.Debugf("  -> Host lease time: %s", trimmedLine)
+			} else if parseVendorOptionSpace(trimmedLine, currentHost.Options) {
+				logrus.Debugf("  -> Host vendor option space: %s", currentHost.Options[VendorOptionSpace])
 			} else if strings.HasPrefix(trimmedLine, "option ") {
 				// Опция хоста
-				fmt.Printf("  -> Processing host option\n")
-				parts := strings.Fields(trimmedLine[7:]) // Убираем "option "
-				fmt.Printf("  -> Option parts: %v (len=%d)\n", parts, len(parts))
-				if len(parts) >= 2 {
-					// Объединяем все части после ключа в значение
-					key := parts[0]
-					value := strings.Join(parts[1:], " ")
-					// Убираем кавычки, если есть
-					value = strings.Trim(value, "\"")
-					currentHost.Options[key] = value
-					fmt.Printf("  -> Host option: %s = %s\n", key, value)
+				key, value, ok := parseOptionStatement(trimmedLine)
+				if !ok {
+					return nil, fail("", "option without value: %s", line)
 				}
+				currentHost.Options[key] = value
+				logrus.Debugf("  -> Host option: %s = %s", key, value)
+			} else {
+				return nil, fail("", "unknown statement in host block: %s", line)
 			}
 
 		case StateHostGlobal:
 			if strings.HasPrefix(line, "}") {
 				// Конец глобального хоста
-				fmt.Printf("  -> Ending global host block\n")
-				config.Hosts = append(config.Hosts, currentHost)
-				state = StateGlobal
-			} else if strings.HasPrefix(trimmedLine, "hardware ethernet ") {
-				// MAC адрес
-				fmt.Printf("  -> Processing hardware ethernet\n")
-				currentHost.Hardware = strings.TrimSpace(trimmedLine[18:]) // Убираем "hardware ethernet "
-				fmt.Printf("  -> Hardware: %s\n", currentHost.Hardware)
+				logrus.Debugf("  -> Ending global host block")
+				if currentGroup != nil {
+					currentGroup.Hosts = append(currentGroup.Hosts, currentHost)
+					state = StateGroupGlobal
+				} else {
+					config.Hosts = append(config.Hosts, currentHost)
+					state = StateGlobal
+				}
+			} else if hardware, ok := parseHardwareStatement(trimmedLine); ok {
+				// Аппаратный адрес
+				logrus.Debugf("  -> Processing hardware address")
+				mac, err := NormalizeMAC(hardware)
+				if err != nil {
+					return nil, fail(hardware, "%v", err)
+				}
+				currentHost.Hardware = mac
+				logrus.Debugf("  -> Hardware: %s", currentHost.Hardware)
 			} else if strings.HasPrefix(trimmedLine, "fixed-address ") {
 				// Фиксированный IP адрес
-				fmt.Printf("  -> Processing fixed-address\n")
-				currentHost.FixedIP = strings.TrimSpace(trimmedLine[14:]) // Убираем "fixed-address "
-				fmt.Printf("  -> Fixed IP: %s\n", currentHost.FixedIP)
+				logrus.Debugf("  -> Processing fixed-address")
+				address := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "fixed-address "))
+				fixedIP, err := parseFixedAddress(address, nil)
+				if err != nil {
+					return nil, fail(address, "%v", err)
+				}
+				currentHost.FixedIP = fixedIP
+				logrus.Debugf("  -> Fixed IP: %s", currentHost.FixedIP)
+			} else if parseBootStatement(trimmedLine, &currentHost.Boot) {
+				// Параметры загрузки хоста
+				logrus.Debugf("  -> Host boot parameter: %s", trimmedLine)
+			} else if strings.HasPrefix(trimmedLine, "option dhcp-client-identifier ") {
+				// Резервирование по идентификатору клиента
+				clientID, err := NormalizeClientID(strings.TrimSpace(strings.TrimPrefix(trimmedLine, "option dhcp-client-identifier ")))
+				if err != nil {
+					return nil, fail("", "%v", err)
+				}
+				currentHost.ClientID = clientID
+				logrus.Debugf("  -> Client identifier: %s", currentHost.ClientID)
+			} else if parseDurationStatement(trimmedLine, currentHost.Options) {
+				logrus.Debugf("  -> Host lease time: %s", trimmedLine)
+			} else if parseVendorOptionSpace(trimmedLine, currentHost.Options) {
+				logrus.Debugf("  -> Host vendor option space: %s", currentHost.Options[VendorOptionSpace])
 			} else if strings.HasPrefix(trimmedLine, "option ") {
 				// Опция хоста
-				fmt.Printf("  -> Processing host option\n")
-				parts := strings.Fields(trimmedLine[7:]) // Убираем "option "
-				fmt.Printf("  -> Option parts: %v (len=%d)\n", parts, len(parts))
-				if len(parts) >= 2 {
-					// Объединяем все части после ключа в значение
-					key := parts[0]
-					value := strings.Join(parts[1:], " ")
-					// Убираем кавычки, если есть
-					value = strings.Trim(value, "\"")
-					currentHost.Options[key] = value
-					fmt.Printf("  -> Host option: %s = %s\n", key, value)
+				key, value, ok := parseOptionStatement(trimmedLine)
+				if !ok {
+					return nil, fail("", "option without value: %s", line)
 				}
+				currentHost.Options[key] = value
+				logrus.Debugf("  -> Host option: %s = %s", key, value)
+			} else {
+				return nil, fail("", "unknown statement in host block: %s", line)
+			}
+
+		case StateGroupGlobal, StateGroupInSubnet:
+			if strings.HasPrefix(line, "}") {
+				// Конец группы: параметры группы переносятся в ее хосты
+				logrus.Debugf("  -> Ending group block")
+				hosts := currentGroup.flatten()
+				if state == StateGroupInSubnet {
+					currentSubnet.Hosts = append(currentSubnet.Hosts, hosts...)
+					state = StateSubnet
+				} else {
+					config.Hosts = append(config.Hosts, hosts...)
+					state = StateGlobal
+				}
+				currentGroup = nil
+			} else if strings.HasPrefix(line, "host ") && strings.Contains(line, "{") {
+				// Начало хоста в группе
+				logrus.Debugf("  -> Starting host in group block")
+				if state == StateGroupInSubnet {
+					state = StateHostInSubnet
+				} else {
+					state = StateHostGlobal
+				}
+				if currentHost, err = parseHostDeclaration(line); err != nil {
+					return nil, fail("", "%v", err)
+				}
+				currentHost.Comment = comment
+			} else if parseBootStatement(trimmedLine, &currentGroup.Boot) {
+				// Параметры загрузки группы
+				logrus.Debugf("  -> Group boot parameter: %s", trimmedLine)
+			} else if parseDurationStatement(trimmedLine, currentGroup.Options) {
+				logrus.Debugf("  -> Group lease time: %s", trimmedLine)
+			} else if parseVendorOptionSpace(trimmedLine, currentGroup.Options) {
+				logrus.Debugf("  -> Group vendor option space: %s", currentGroup.Options[VendorOptionSpace])
+			} else if strings.HasPrefix(trimmedLine, "option ") {
+				// Опция группы
+				key, value, ok := parseOptionStatement(trimmedLine)
+				if !ok {
+					return nil, fail("", "option without value: %s", line)
+				}
+				currentGroup.Options[key] = value
+				logrus.Debugf("  -> Group option: %s = %s", key, value)
+			} else {
+				return nil, fail("", "unknown statement in group block: %s", line)
+			}
+
+		case StateClass:
+			if strings.HasPrefix(line, "}") {
+				// Конец класса
+				logrus.Debugf("  -> Ending class block")
+				config.Classes = append(config.Classes, currentClass)
+				state = StateGlobal
+			} else if trimmedLine == "match hardware" {
+				// Членство определяется по MAC адресу через subclass
+				logrus.Debugf("  -> Class matches hardware")
+			} else if strings.HasPrefix(trimmedLine, "match if ") {
+				// Членство определяется опциями запроса
+				if len(currentClass.Match) > 0 {
+					return nil, fail("", "class %q already has a match condition", currentClass.Name)
+				}
+				conditions, err := parseMatchCondition(strings.TrimPrefix(trimmedLine, "match if "))
+				if err != nil {
+					return nil, fail("", "%v", err)
+				}
+				currentClass.Match = conditions
+				logrus.Debugf("  -> Class match condition: %s", trimmedLine)
+			} else if strings.HasPrefix(trimmedLine, "match hardware prefix ") {
+				// Членство определяется префиксом MAC адреса (OUI)
+				prefix := strings.TrimSpace(strings.TrimPrefix(trimmedLine, "match hardware prefix "))
+				pattern, err := NormalizeMACPattern(strings.TrimSuffix(prefix, "*") + "*")
+				if err != nil || pattern == "*" {
+					return nil, fail(prefix, "invalid hardware prefix: %s", prefix)
+				}
+				currentClass.Prefixes = append(currentClass.Prefixes, pattern)
+				logrus.Debugf("  -> Class hardware prefix: %s", pattern)
+			} else if parseBootStatement(trimmedLine, &currentClass.Boot) {
+				// Параметры загрузки класса
+				logrus.Debugf("  -> Class boot parameter: %s", trimmedLine)
+			} else if parseDurationStatement(trimmedLine, currentClass.Options) {
+				logrus.Debugf("  -> Class lease time: %s", trimmedLine)
+			} else if parseVendorOptionSpace(trimmedLine, currentClass.Options) {
+				logrus.Debugf("  -> Class vendor option space: %s", currentClass.Options[VendorOptionSpace])
+			} else if strings.HasPrefix(trimmedLine, "option ") {
+				// Опция класса
+				key, value, ok := parseOptionStatement(trimmedLine)
+				if !ok {
+					return nil, fail("", "option without value: %s", line)
+				}
+				currentClass.Options[key] = value
+				logrus.Debugf("  -> Class option: %s = %s", key, value)
+			} else {
+				return nil, fail("", "unknown statement in class block: %s", line)
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, &ParseError{File: filename, Line: lineNumber + 1, Msg: err.Error()}
+	}
+
+	if state != StateGlobal {
+		return nil, &ParseError{File: filename, Line: blockLine, Col: blockCol, Msg: "unexpected end of file, block is not closed"}
+	}
+
+	// subclass может быть объявлен до или после своего класса
+	for name := range subclasses {
+		found := false
+		for i := range config.Classes {
+			if config.Classes[i].Name == name {
+				config.Classes[i].Hardware = append(config.Classes[i].Hardware, subclasses[name]...)
+				found = true
+			}
+		}
+		if !found {
+			return nil, &ParseError{File: filename, Line: subclassLines[name], Msg: fmt.Sprintf("subclass of undefined class %q", name)}
+		}
+	}
+
+	// Объявления опций и классы могут следовать в любом порядке
+	if err := resolveConditionCodes(config); err != nil {
+		return nil, &ParseError{File: filename, Msg: err.Error()}
+	}
+
+	// Пространство опций может быть объявлено после своих опций
+	if err := checkOptionSpaces(config); err != nil {
+		return nil, &ParseError{File: filename, Msg: err.Error()}
 	}
 
-	fmt.Printf("Parsing complete. Subnets: %d, Hosts: %d, Global options: %d\n",
+	// allow/deny members of может ссылаться на класс, объявленный ниже
+	if err := checkClassReferences(config); err != nil {
+		return nil, &ParseError{File: filename, Msg: err.Error()}
+	}
+
+	logrus.Debugf("Parsing complete. Subnets: %d, Hosts: %d, Global options: %d",
 		len(config.Subnets), len(config.Hosts), len(config.GlobalOptions))
 
 	return config, nil
 }
+
+// group представляет блок group: параметры, общие для объявленных в нем
+// хостов. Отдельно от хостов группа не хранится.
+type group struct {
+	Options map[string]string
+	Boot    BootParams
+	Hosts   []Host
+}
+
+// newGroup создает пустую группу
+func newGroup() *group {
+	return &group{Options: make(map[string]string)}
+}
+
+// isGroupDeclaration проверяет, открывает ли строка блок group
+func isGroupDeclaration(line string) bool {
+	return strings.TrimSpace(strings.TrimSuffix(line, "{")) == "group" && strings.HasSuffix(line, "{")
+}
+
+// flatten переносит опции и параметры загрузки группы в ее хосты.
+// Значения, заданные в самом хосте, имеют приоритет; порядок операторов
+// внутри группы значения не имеет.
+func (g *group) flatten() []Host {
+	for i := range g.Hosts {
+		host := &g.Hosts[i]
+		for key, value := range g.Options {
+			if _, ok := host.Options[key]; !ok {
+				host.Options[key] = value
+			}
+		}
+		if host.Boot.NextServer == "" {
+			host.Boot.NextServer = g.Boot.NextServer
+		}
+		if host.Boot.ServerName == "" {
+			host.Boot.ServerName = g.Boot.ServerName
+		}
+		if host.Boot.Filename == "" {
+			host.Boot.Filename = g.Boot.Filename
+		}
+	}
+	return g.Hosts
+}
+
+// statementColumn возвращает столбец начала оператора в строке (с 1)
+func statementColumn(text string) int {
+	return len(text) - len(strings.TrimLeft(text, " \t")) + 1
+}
+
+// invalidAddress возвращает первое значение, не являющееся адресом IPv4
+// (пустая строка - все значения допустимы)
+func invalidAddress(values ...string) string {
+	for _, value := range values {
+		if net.ParseIP(value).To4() == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+// parseHostDeclaration разбирает заголовок блока host: host <имя> {
+func parseHostDeclaration(line string) (Host, error) {
+	declaration, _, _ := strings.Cut(line, "{")
+	parts := strings.Fields(declaration)
+	logrus.Debugf("  -> Host parts: %v (len=%d)", parts, len(parts))
+	if len(parts) < 2 {
+		return Host{}, fmt.Errorf("host declaration without name: %s", line)
+	}
+	logrus.Debugf("  -> Host name: %s", parts[1])
+	return Host{Name: parts[1], Options: make(map[string]string)}, nil
+}
+
+// parseAccessStatement разбирает правила вида "allow known-clients",
+// "deny unknown-clients", "allow|deny bootp", "allow|deny hardware
+// <mac или префикс>" и "allow|deny members of \"класс\"".
+// Возвращает false, если строка не является правилом доступа.
+func parseAccessStatement(line string, rules *AccessRules) (bool, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 || (parts[0] != "allow" && parts[0] != "deny") {
+		return false, nil
+	}
+
+	action := parts[0]
+	switch {
+	case len(parts) == 2 && parts[1] == "known-clients":
+		rules.KnownClients = action
+	case len(parts) == 2 && parts[1] == "unknown-clients":
+		rules.UnknownClients = action
+	case len(parts) == 2 && parts[1] == "bootp":
+		rules.BOOTP = action
+	case len(parts) == 3 && parts[1] == "hardware":
+		mac, err := NormalizeMACPattern(parts[2])
+		if err != nil {
+			return false, err
+		}
+		if action == "allow" {
+			rules.AllowMACs = append(rules.AllowMACs, mac)
+		} else {
+			rules.DenyMACs = append(rules.DenyMACs, mac)
+		}
+	case len(parts) >= 4 && parts[1] == "members" && parts[2] == "of":
+		name := unquote(strings.Join(parts[3:], " "))
+		if action == "allow" {
+			rules.AllowClasses = append(rules.AllowClasses, name)
+		} else {
+			rules.DenyClasses = append(rules.DenyClasses, name)
+		}
+	default:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// checkClassReferences проверяет, что правила allow/deny members of
+// ссылаются на объявленные классы. Классы с match if не допускаются:
+// правила доступа проверяются и без запроса клиента (при перезагрузке
+// конфигурации), когда его опции неизвестны.
+func checkClassReferences(config *DHCPConfig) error {
+	declared := make(map[string]*Class)
+	for i := range config.Classes {
+		declared[config.Classes[i].Name] = &config.Classes[i]
+	}
+
+	check := func(rules *AccessRules, scope string) error {
+		for _, names := range [][]string{rules.AllowClasses, rules.DenyClasses} {
+			for _, name := range names {
+				class := declared[name]
+				if class == nil {
+					return fmt.Errorf("%s: members of undefined class %q", scope, name)
+				}
+				if len(class.Match) > 0 {
+					return fmt.Errorf("%s: members of class %q with match if cannot be used in access rules", scope, name)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := check(&config.Access, "global"); err != nil {
+		return err
+	}
+	for i := range config.Subnets {
+		if err := check(&config.Subnets[i].Access, "subnet "+config.Subnets[i].Network.IP.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseHardwareStatement разбирает оператор "hardware <тип> <адрес>".
+// Поддерживаются типы ethernet, token-ring и fddi, как в ISC-DHCP.
+func parseHardwareStatement(line string) (string, bool) {
+	parts := strings.Fields(line)
+	if len(parts) != 3 || parts[0] != "hardware" {
+		return "", false
+	}
+
+	switch parts[1] {
+	case "ethernet", "token-ring", "fddi":
+		return parts[2], true
+	}
+	return "", false
+}
+
+// parseBootStatement разбирает операторы "next-server <адрес>",
+// "server-name <имя>" и "filename <имя>". Возвращает false, если строка
+// не является таким оператором.
+func parseBootStatement(line string, boot *BootParams) bool {
+	keyword, value, found := strings.Cut(line, " ")
+	value = strings.TrimSpace(value)
+	if !found || value == "" {
+		return false
+	}
+
+	switch keyword {
+	case "next-server":
+		boot.NextServer = value
+	case "server-name":
+		boot.ServerName = unquote(value)
+	case "filename":
+		boot.Filename = unquote(value)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// parseVendorOptionSpace разбирает оператор "vendor-option-space <имя>":
+// опции этого пространства передаются клиенту в опции 43. Оператор
+// сохраняется в options под именем VendorOptionSpace.
+func parseVendorOptionSpace(line string, options map[string]string) bool {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || fields[0] != VendorOptionSpace {
+		return false
+	}
+	options[VendorOptionSpace] = fields[1]
+	return true
+}
+
+// parseDurationStatement разбирает операторы длительности подсети,
+// класса, группы или хоста: сроки аренды default-lease-time и
+// max-lease-time и задержку ответа delay. Значения сохраняются в options
+// и проверяются при компиляции конфигурации.
+func parseDurationStatement(line string, options map[string]string) bool {
+	fields := strings.Fields(line)
+	if len(fields) != 2 || (fields[0] != DefaultLeaseTime && fields[0] != MaxLeaseTime && fields[0] != Delay) {
+		return false
+	}
+	options[fields[0]] = fields[1]
+	return true
+}
+
+// parseAuthoritativeStatement разбирает операторы "authoritative" и
+// "not authoritative". Второе значение false, если строка не является
+// таким оператором.
+func parseAuthoritativeStatement(line string) (bool, bool) {
+	switch strings.Join(strings.Fields(line), " ") {
+	case "authoritative":
+		return true, true
+	case "not authoritative":
+		return false, true
+	}
+	return false, false
+}
+
+// stripComment удаляет комментарий, начинающийся с # вне кавычек
+func stripComment(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// parseOptionStatement разбирает строку "option <имя> <значение>".
+// Кавычки вокруг значения убираются. Возвращает false, если значение не задано.
+func parseOptionStatement(line string) (string, string, bool) {
+	parts := strings.Fields(strings.TrimPrefix(line, "option "))
+	if len(parts) < 2 {
+		return "", "", false
+	}
+
+	// Объединяем все части после ключа в значение
+	return parts[0], unquote(strings.Join(parts[1:], " ")), true
+}
+
+// parseInstanceStatement разбирает оператор instance "<имя>" "<файл>".
+// Второе значение false, если строка не является таким оператором.
+func parseInstanceStatement(line string) (Instance, bool, error) {
+	parts := strings.Fields(line)
+	if len(parts) == 0 || parts[0] != "instance" {
+		return Instance{}, false, nil
+	}
+	if len(parts) != 3 || unquote(parts[1]) == "" || unquote(parts[2]) == "" {
+		return Instance{}, false, fmt.Errorf("invalid instance, expected instance \"<name>\" \"<file>\": %s", line)
+	}
+	return Instance{Name: unquote(parts[1]), File: unquote(parts[2])}, true, nil
+}
+
+// unquote убирает кавычки вокруг значения
+func unquote(value string) string {
+	return strings.Trim(value, "\"")
+}
+
+// subclassMAC преобразует значение subclass вида 1:0:11:22:33:44:55
+// (тип оборудования Ethernet и MAC адрес) в канонический MAC адрес
+func subclassMAC(value string) (string, error) {
+	parts := strings.Split(unquote(value), ":")
+	if len(parts) == 7 {
+		parts = parts[1:]
+	}
+	return NormalizeMAC(strings.Join(parts, ":"))
+}
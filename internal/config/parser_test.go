@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -36,8 +39,12 @@ authoritative;
 	}
 
 	// Проверяем глобальные опции
-	if len(cfg.GlobalOptions) != 4 {
-		t.Errorf("Expected 4 global options, got %d", len(cfg.GlobalOptions))
+	if len(cfg.GlobalOptions) != 3 {
+		t.Errorf("Expected 3 global options, got %d", len(cfg.GlobalOptions))
+	}
+
+	if cfg.Authoritative == nil || !*cfg.Authoritative {
+		t.Error("Expected authoritative to be set")
 	}
 
 	if leaseTime, ok := cfg.GlobalOptions["default-lease-time"]; !ok || leaseTime != "600" {
@@ -91,20 +98,12 @@ func TestParseSubnet(t *testing.T) {
 	}
 
 	subnet := cfg.Subnets[0]
-	if subnet.Network != "192.168.1.0" {
-		t.Errorf("Expected network 192.168.1.0, got %s", subnet.Network)
+	if subnet.Network.String() != "192.168.1.0/24" {
+		t.Errorf("Expected network 192.168.1.0/24, got %s", subnet.Network)
 	}
 
-	if subnet.Netmask != "255.255.255.0" {
-		t.Errorf("Expected netmask 255.255.255.0, got %s", subnet.Netmask)
-	}
-
-	if subnet.RangeStart != "192.168.1.100" {
-		t.Errorf("Expected range start 192.168.1.100, got %s", subnet.RangeStart)
-	}
-
-	if subnet.RangeEnd != "192.168.1.200" {
-		t.Errorf("Expected range end 192.168.1.200, got %s", subnet.RangeEnd)
+	if len(subnet.Ranges) != 1 || subnet.Ranges[0] != (Range{Start: "192.168.1.100", End: "192.168.1.200"}) {
+		t.Errorf("Expected range 192.168.1.100 - 192.168.1.200, got %+v", subnet.Ranges)
 	}
 
 	// Проверяем опции подсети
@@ -284,9 +283,12 @@ host global-client {
 		t.Fatalf("Failed to parse config: %v", err)
 	}
 
-	// Проверяем глобальные опции
-	if len(cfg.GlobalOptions) != 3 {
-		t.Errorf("Expected 3 global options, got %d", len(cfg.GlobalOptions))
+	// Проверяем глобальные опции (authoritative хранится отдельно)
+	if len(cfg.GlobalOptions) != 2 {
+		t.Errorf("Expected 2 global options, got %d", len(cfg.GlobalOptions))
+	}
+	if cfg.Authoritative == nil || !*cfg.Authoritative {
+		t.Error("Expected authoritative to be set")
 	}
 
 	// Проверяем подсети
@@ -295,12 +297,8 @@ host global-client {
 	}
 
 	subnet := cfg.Subnets[0]
-	if subnet.Network != "192.168.1.0" {
-		t.Errorf("Expected network 192.168.1.0, got %s", subnet.Network)
-	}
-
-	if subnet.Netmask != "255.255.255.0" {
-		t.Errorf("Expected netmask 255.255.255.0, got %s", subnet.Netmask)
+	if subnet.Network.String() != "192.168.1.0/24" {
+		t.Errorf("Expected network 192.168.1.0/24, got %s", subnet.Network)
 	}
 
 	// Проверяем хосты в подсети
@@ -323,3 +321,674 @@ host global-client {
 		t.Errorf("Expected global host name global-client, got %s", globalHost.Name)
 	}
 }
+
+func TestParseAccessRules(t *testing.T) {
+	// Создаем тестовую конфигурацию с правилами доступа
+	configContent := `deny unknown-clients;
+deny hardware 00:1A:2B:*;
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  allow known-clients;
+  allow hardware 00:11:22:33:44:55;
+  deny hardware aa:bb:cc:dd:ee:ff;
+}
+
+subnet 10.0.1.0 netmask 255.255.255.0 {
+  allow bootp;
+  range dynamic-bootp 10.0.1.100 10.0.1.150;
+}
+`
+
+	// Создаем временный файл
+	tmpfile, err := os.CreateTemp("", "dhcpd_test.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	// Записываем тестовую конфигурацию в файл
+	if _, err := tmpfile.Write([]byte(configContent)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Тестируем парсер
+	cfg, err := ParseConfig(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	// Правила доступа не должны попадать в глобальные опции
+	if len(cfg.GlobalOptions) != 0 {
+		t.Errorf("Expected 0 global options, got %d", len(cfg.GlobalOptions))
+	}
+
+	if cfg.Access.UnknownClients != "deny" {
+		t.Errorf("Expected global unknown-clients deny, got %s", cfg.Access.UnknownClients)
+	}
+
+	if len(cfg.Access.DenyMACs) != 1 || cfg.Access.DenyMACs[0] != "00:1a:2b:*" {
+		t.Errorf("Expected global deny list [00:1a:2b:*], got %v", cfg.Access.DenyMACs)
+	}
+
+	if len(cfg.Subnets) != 2 {
+		t.Fatalf("Expected 2 subnets, got %d", len(cfg.Subnets))
+	}
+
+	bootp := cfg.Subnets[1]
+	if bootp.Access.BOOTP != "allow" || len(bootp.Ranges) != 1 || bootp.Ranges[0] != (Range{Start: "10.0.1.100", End: "10.0.1.150", DynamicBOOTP: true}) {
+		t.Errorf("Unexpected dynamic-bootp subnet %+v", bootp)
+	}
+	if cfg.Subnets[0].Ranges[0].DynamicBOOTP {
+		t.Error("Expected plain range not to be dynamic-bootp")
+	}
+
+	access := cfg.Subnets[0].Access
+	if access.KnownClients != "allow" {
+		t.Errorf("Expected subnet known-clients allow, got %s", access.KnownClients)
+	}
+
+	if len(access.AllowMACs) != 1 || access.AllowMACs[0] != "00:11:22:33:44:55" {
+		t.Errorf("Expected subnet allow list [00:11:22:33:44:55], got %v", access.AllowMACs)
+	}
+
+	if len(access.DenyMACs) != 1 || access.DenyMACs[0] != "aa:bb:cc:dd:ee:ff" {
+		t.Errorf("Expected subnet deny list [aa:bb:cc:dd:ee:ff], got %v", access.DenyMACs)
+	}
+}
+
+// writeTestConfig записывает конфигурацию во временный файл и возвращает его имя
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	tmpfile, err := os.CreateTemp(t.TempDir(), "dhcpd_test.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tmpfile.Name()
+}
+
+func TestParseInlineComments(t *testing.T) {
+	configContent := `allocation-hook-timeout 500;            # мс
+allocation-hook-url "http://ipam.local/#hook";  # адрес хука
+
+subnet 192.168.1.0 netmask 255.255.255.0 { # основная подсеть
+  range 192.168.1.100 192.168.1.200; # пул
+  option bootfile-name "pxelinux.0"; # загрузчик
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if value := cfg.GlobalOptions["allocation-hook-timeout"]; value != "500" {
+		t.Errorf("Expected allocation-hook-timeout 500, got %q", value)
+	}
+
+	// # внутри кавычек не считается началом комментария
+	if value := cfg.GlobalOptions["allocation-hook-url"]; value != `"http://ipam.local/#hook"` {
+		t.Errorf("Expected quoted hook URL to be kept, got %q", value)
+	}
+
+	if len(cfg.Subnets) != 1 || len(cfg.Subnets[0].Ranges) != 1 || cfg.Subnets[0].Ranges[0].End != "192.168.1.200" {
+		t.Fatalf("Expected subnet with range end 192.168.1.200, got %+v", cfg.Subnets)
+	}
+	if value := cfg.Subnets[0].Options["bootfile-name"]; value != "pxelinux.0" {
+		t.Errorf("Expected bootfile-name pxelinux.0, got %q", value)
+	}
+}
+
+func TestParseInvalidStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"missing semicolon", "default-lease-time 600\n"},
+		{"unknown subnet statement", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  pool-size 10;\n}\n"},
+		{"unknown host statement", "host a {\n  hardware wifi 00:11:22:33:44:55;\n}\n"},
+		{"invalid hardware address", "host a {\n  hardware ethernet 00:11:22:33:44:zz;\n}\n"},
+		{"invalid access pattern", "deny hardware 00:1g:*;\n"},
+		{"invalid range", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  range 10.0.0.1;\n}\n"},
+		{"invalid exclude", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  exclude 10.0.0.1 10.0.0.2 10.0.0.3;\n}\n"},
+		{"option without value", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  option routers;\n}\n"},
+		{"invalid subnet declaration", "subnet 10.0.0.0 {\n}\n"},
+		{"invalid netmask", "subnet 10.0.0.0 netmask 255.0.255.0 {\n}\n"},
+		{"invalid subnet prefix", "subnet 10.0.0.0/33 {\n}\n"},
+		{"range outside of subnet", "subnet 10.0.0.0/24 {\n  range 10.0.1.0/25;\n}\n"},
+		{"range wider than subnet", "subnet 10.0.0.0/24 {\n  range 10.0.0.0/16;\n}\n"},
+		{"fixed-address prefix mismatch", "subnet 10.0.0.0/24 {\n  host a {\n    fixed-address 10.0.0.5/16;\n  }\n}\n"},
+		{"unsupported block", "shared-network lan {\n}\n"},
+		{"nested group", "group {\n  group {\n  }\n}\n"},
+		{"range in group", "subnet 10.0.0.0/24 {\n  group {\n    range 10.0.0.10 10.0.0.20;\n  }\n}\n"},
+		{"unclosed block", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  range 10.0.0.1 10.0.0.9;\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfig(writeTestConfig(t, tt.content)); err == nil {
+				t.Error("Expected parse error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		line     int
+		col      int
+		contains string
+	}{
+		{"unknown statement", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  rnage 10.0.0.1 10.0.0.9;\n}\n", 2, 3, "unknown statement in subnet block"},
+		{"bad range address", "subnet 10.0.0.0 netmask 255.0.0.0 {\n  range 10.0.0.1 10.0.0.256;\n}\n", 2, 18, "invalid address in range: 10.0.0.256"},
+		{"bad fixed address", "host pc {\n    fixed-address 10.0.0;\n}\n", 2, 19, "10.0.0"},
+		{"bad hardware", "host pc {\n  hardware ethernet 00:11:zz;\n}\n", 2, 21, "invalid hardware address"},
+		{"missing semicolon", "authoritative;\nddns-update-style none\n", 2, 1, "expected ';'"},
+		{"unclosed block", "ddns-update-style none;\n\n  subnet 10.0.0.0 netmask 255.0.0.0 {\n  range 10.0.0.1 10.0.0.9;\n", 3, 3, "block is not closed"},
+		{"undefined subclass", "\nsubclass \"pxe\" 1:00:11:22:33:44:55;\n", 2, 0, "undefined class"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestConfig(t, tt.content)
+			_, err := ParseConfig(path)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected *ParseError, got %v", err)
+			}
+			if parseErr.File != path || parseErr.Line != tt.line || parseErr.Col != tt.col {
+				t.Errorf("Expected %s:%d:%d, got %s:%d:%d", path, tt.line, tt.col, parseErr.File, parseErr.Line, parseErr.Col)
+			}
+			if !strings.Contains(parseErr.Msg, tt.contains) {
+				t.Errorf("Expected message to contain %q, got %q", tt.contains, parseErr.Msg)
+			}
+		})
+	}
+
+	err := &ParseError{File: "dhcpd.conf", Line: 12, Col: 3, Msg: "unknown statement"}
+	if err.Error() != "dhcpd.conf:12:3: unknown statement" {
+		t.Errorf("Unexpected error text %q", err.Error())
+	}
+}
+
+func TestParseOptionScopes(t *testing.T) {
+	configContent := `option domain-name-servers 10.0.0.53;
+subclass "vmware" 1:0:c:29:0:0:1;
+
+class "vmware" {
+  match hardware;
+  option bootfile-name "vmware.efi";
+}
+
+subclass "vmware" 1:00:0C:29:00:00:02;
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  option domain-name "lab.example.com";
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if value := cfg.Options["domain-name-servers"]; value != "10.0.0.53" {
+		t.Errorf("Expected global domain-name-servers 10.0.0.53, got %q", value)
+	}
+	if _, exists := cfg.GlobalOptions["option"]; exists {
+		t.Error("Expected option statement not to be stored as a global parameter")
+	}
+
+	if len(cfg.Classes) != 1 {
+		t.Fatalf("Expected 1 class, got %d", len(cfg.Classes))
+	}
+	class := cfg.Classes[0]
+	if class.Name != "vmware" || class.Options["bootfile-name"] != "vmware.efi" {
+		t.Errorf("Unexpected class %+v", class)
+	}
+	// subclass объявлен до и после класса, MAC адреса нормализуются
+	expected := []string{"00:0c:29:00:00:01", "00:0c:29:00:00:02"}
+	if len(class.Hardware) != len(expected) {
+		t.Fatalf("Expected members %v, got %v", expected, class.Hardware)
+	}
+	for i, mac := range expected {
+		if class.Hardware[i] != mac {
+			t.Errorf("Expected member %s, got %s", mac, class.Hardware[i])
+		}
+	}
+
+	if value := cfg.Subnets[0].Options["domain-name"]; value != "lab.example.com" {
+		t.Errorf("Expected subnet domain-name lab.example.com, got %q", value)
+	}
+
+	// subclass неизвестного класса и неподдерживаемые условия класса
+	for _, content := range []string{
+		"subclass \"missing\" 1:00:0c:29:00:00:01;\n",
+		"class \"pxe\" {\n  match if substring (option vendor-class-identifier, 0, 9) = \"PXEClient\";\n}\n",
+	} {
+		if _, err := ParseConfig(writeTestConfig(t, content)); err == nil {
+			t.Errorf("Expected parse error for %q", content)
+		}
+	}
+}
+
+func TestParseHardwarePrefixClass(t *testing.T) {
+	configContent := `class "raspberry-pi" {
+  match hardware prefix B8:27:EB;
+  match hardware prefix dc-a6-32;
+  next-server 10.0.0.5;
+  filename "bootcode.bin";
+  option vendor-class-identifier "PXEClient";
+}
+
+deny members of "idrac";
+
+subnet 10.0.0.0 netmask 255.255.255.0 {
+  range 10.0.0.100 10.0.0.200;
+  allow members of "raspberry-pi";
+}
+
+class "idrac" {
+  match hardware prefix f8:bc:12:*;
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if len(cfg.Classes) != 2 {
+		t.Fatalf("Expected 2 classes, got %d", len(cfg.Classes))
+	}
+	class := cfg.Classes[0]
+	if len(class.Prefixes) != 2 || class.Prefixes[0] != "b8:27:eb:*" || class.Prefixes[1] != "dc:a6:32:*" {
+		t.Errorf("Unexpected prefixes %v", class.Prefixes)
+	}
+	if class.Boot.NextServer != "10.0.0.5" || class.Boot.Filename != "bootcode.bin" {
+		t.Errorf("Unexpected class boot parameters %+v", class.Boot)
+	}
+	if cfg.Classes[1].Prefixes[0] != "f8:bc:12:*" {
+		t.Errorf("Unexpected prefixes %v", cfg.Classes[1].Prefixes)
+	}
+	if len(cfg.Access.DenyClasses) != 1 || cfg.Access.DenyClasses[0] != "idrac" {
+		t.Errorf("Unexpected global access rules %+v", cfg.Access)
+	}
+	if rules := cfg.Subnets[0].Access; len(rules.AllowClasses) != 1 || rules.AllowClasses[0] != "raspberry-pi" {
+		t.Errorf("Unexpected subnet access rules %+v", rules)
+	}
+
+	for _, content := range []string{
+		"class \"bad\" {\n  match hardware prefix zz:zz;\n}\n",
+		"class \"bad\" {\n  match hardware prefix *;\n}\n",
+		"allow members of \"missing\";\n",
+	} {
+		if _, err := ParseConfig(writeTestConfig(t, content)); err == nil {
+			t.Errorf("Expected parse error for %q", content)
+		}
+	}
+}
+
+func TestParseBootStatements(t *testing.T) {
+	configContent := `next-server 10.0.0.1;
+filename "pxelinux.0";
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  next-server 192.168.1.10;
+
+  host rescue {
+    hardware ethernet 00:11:22:33:44:55;
+    filename "rescue/vmlinuz";
+  }
+}
+
+host uefi {
+  hardware ethernet 00:11:22:33:44:66;
+  filename "EFI Boot/bootx64.efi";
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if cfg.Boot.NextServer != "10.0.0.1" || cfg.Boot.Filename != "pxelinux.0" {
+		t.Errorf("Unexpected global boot parameters %+v", cfg.Boot)
+	}
+	if _, exists := cfg.GlobalOptions["next-server"]; exists {
+		t.Error("Expected next-server not to be stored as a global parameter")
+	}
+
+	subnet := cfg.Subnets[0]
+	if subnet.Boot.NextServer != "192.168.1.10" || subnet.Boot.Filename != "" {
+		t.Errorf("Unexpected subnet boot parameters %+v", subnet.Boot)
+	}
+	if boot := subnet.Hosts[0].Boot; boot.Filename != "rescue/vmlinuz" || boot.NextServer != "" {
+		t.Errorf("Unexpected host boot parameters %+v", boot)
+	}
+
+	// Имя файла в кавычках может содержать пробелы
+	if boot := cfg.Hosts[0].Boot; boot.Filename != "EFI Boot/bootx64.efi" {
+		t.Errorf("Expected filename with space, got %q", boot.Filename)
+	}
+}
+
+func TestParseClientIdentifier(t *testing.T) {
+	configContent := `host laptop {
+  option dhcp-client-identifier "laptop";
+  fixed-address 192.168.1.20;
+}
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  host nas {
+    option dhcp-client-identifier 1:0:11:22:33:44:55;
+    fixed-address 192.168.1.21;
+  }
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if host := cfg.Hosts[0]; host.ClientID != "6c:61:70:74:6f:70" || len(host.Options) != 0 {
+		t.Errorf("Unexpected host %+v", host)
+	}
+	if host := cfg.Subnets[0].Hosts[0]; host.ClientID != "01:00:11:22:33:44:55" {
+		t.Errorf("Expected client-id 01:00:11:22:33:44:55, got %s", host.ClientID)
+	}
+
+	if _, err := ParseConfig(writeTestConfig(t, "host a {\n  option dhcp-client-identifier 01:zz;\n}\n")); err == nil {
+		t.Error("Expected error for invalid client identifier")
+	}
+}
+
+func TestParseAuthoritative(t *testing.T) {
+	configContent := `not authoritative;
+
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  authoritative;
+}
+
+subnet 10.0.0.0 netmask 255.255.255.0 {
+  range 10.0.0.100 10.0.0.200;
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if cfg.Authoritative == nil || *cfg.Authoritative {
+		t.Error("Expected global not authoritative")
+	}
+	if len(cfg.GlobalOptions) != 0 {
+		t.Errorf("Expected authoritative not to be stored as a global parameter, got %v", cfg.GlobalOptions)
+	}
+	if value := cfg.Subnets[0].Authoritative; value == nil || !*value {
+		t.Error("Expected authoritative subnet 192.168.1.0")
+	}
+	if cfg.Subnets[1].Authoritative != nil {
+		t.Error("Expected subnet 10.0.0.0 to inherit the global setting")
+	}
+}
+
+func TestParseInstances(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(`
+instance "tenant-a" "/etc/go-bootp/tenant-a.conf";
+# VLAN 20
+instance tenant-b tenant-b.conf;
+`), "test.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Instance{
+		{Name: "tenant-a", File: "/etc/go-bootp/tenant-a.conf"},
+		{Name: "tenant-b", File: "tenant-b.conf", Comment: "# VLAN 20"},
+	}
+	if !reflect.DeepEqual(cfg.Instances, want) {
+		t.Errorf("Expected instances %+v, got %+v", want, cfg.Instances)
+	}
+	if len(cfg.GlobalOptions) != 0 {
+		t.Errorf("Expected instance not to be stored as a global parameter, got %v", cfg.GlobalOptions)
+	}
+
+	for _, content := range []string{
+		"instance \"tenant-a\";\n",
+		"instance \"\" \"a.conf\";\n",
+		"instance \"a\" \"a.conf\";\ninstance \"a\" \"b.conf\";\n",
+	} {
+		if _, err := Parse(strings.NewReader(content), "test.conf"); err == nil {
+			t.Errorf("Expected error for %q", content)
+		}
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	configContent := `subnet 10.0.0.0/24 {
+  range 10.0.0.10 10.0.0.19;
+  range 10.0.0.128/25;
+  host printer {
+    hardware ethernet 00:11:22:33:44:55;
+    fixed-address 10.0.0.10/24;
+  }
+}
+
+subnet 192.168.1.7 netmask 255.255.255.0 {
+  range dynamic-bootp 192.168.1.0/28;
+}
+
+host nas {
+  hardware ethernet 00:11:22:33:44:66;
+  fixed-address 192.168.1.20/24;
+}
+`
+
+	cfg, err := ParseConfig(writeTestConfig(t, configContent))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(cfg.Subnets) != 2 {
+		t.Fatalf("Expected 2 subnets, got %d", len(cfg.Subnets))
+	}
+
+	// Адрес сети и широковещательный адрес не входят в диапазон
+	tests := []struct {
+		network, start, end string
+	}{
+		{"10.0.0.0/24", "10.0.0.128", "10.0.0.254"},
+		{"192.168.1.0/24", "192.168.1.1", "192.168.1.15"},
+	}
+	for i, tt := range tests {
+		subnet := cfg.Subnets[i]
+		if subnet.Network.String() != tt.network {
+			t.Errorf("Expected network %s, got %s", tt.network, subnet.Network)
+		}
+		last := subnet.Ranges[len(subnet.Ranges)-1]
+		if last.Start != tt.start || last.End != tt.end {
+			t.Errorf("Expected range %s - %s, got %+v", tt.start, tt.end, subnet.Ranges)
+		}
+	}
+
+	// Несколько операторов range в одной подсети
+	if ranges := cfg.Subnets[0].Ranges; len(ranges) != 2 || ranges[0] != (Range{Start: "10.0.0.10", End: "10.0.0.19"}) {
+		t.Errorf("Expected two ranges, got %+v", ranges)
+	}
+
+	if ip := cfg.Subnets[0].Hosts[0].FixedIP; ip != "10.0.0.10" {
+		t.Errorf("Expected fixed address 10.0.0.10, got %s", ip)
+	}
+	if ip := cfg.Hosts[0].FixedIP; ip != "192.168.1.20" {
+		t.Errorf("Expected fixed address 192.168.1.20, got %s", ip)
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	network, err := ParseNetwork("192.168.1.77/26")
+	if err != nil || network.String() != "192.168.1.64/26" {
+		t.Errorf("ParseNetwork = %v (%v), expected 192.168.1.64/26", network, err)
+	}
+
+	for _, input := range []string{"192.168.1.0", "192.168.1.0/40", "2001:db8::/64"} {
+		if _, err := ParseNetwork(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseExclude(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, `subnet 192.168.1.0/24 {
+  range 192.168.1.100 192.168.1.200;
+  exclude 192.168.1.150 192.168.1.160;
+  exclude 192.168.1.170;
+}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	want := []Range{{Start: "192.168.1.150", End: "192.168.1.160"}, {Start: "192.168.1.170", End: "192.168.1.170"}}
+	exclusions := cfg.Subnets[0].Exclusions
+	if len(exclusions) != len(want) || exclusions[0] != want[0] || exclusions[1] != want[1] {
+		t.Errorf("Expected exclusions %+v, got %+v", want, exclusions)
+	}
+}
+
+func TestParseGroup(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, `group {
+  host pc1 {
+    hardware ethernet 00:11:22:33:44:01;
+  }
+  filename "pxelinux.0";
+  next-server 10.0.0.1;
+  option domain-name "lab";
+  host pc2 {
+    hardware ethernet 00:11:22:33:44:02;
+    filename "ipxe.efi";
+    option domain-name "pc2.lab";
+  }
+}
+subnet 192.168.1.0/24 {
+  group {
+    option domain-name "office";
+    host printer {
+      hardware ethernet 00:11:22:33:44:03;
+      fixed-address 192.168.1.10/24;
+    }
+  }
+  host nas {
+    hardware ethernet 00:11:22:33:44:04;
+  }
+}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if len(cfg.Hosts) != 2 {
+		t.Fatalf("Expected 2 global hosts, got %+v", cfg.Hosts)
+	}
+	// Операторы группы после хоста также применяются к нему
+	if pc1 := cfg.Hosts[0]; pc1.Boot != (BootParams{NextServer: "10.0.0.1", Filename: "pxelinux.0"}) || pc1.Options["domain-name"] != "lab" {
+		t.Errorf("Unexpected host %+v", pc1)
+	}
+	// Значения хоста имеют приоритет над значениями группы
+	if pc2 := cfg.Hosts[1]; pc2.Boot != (BootParams{NextServer: "10.0.0.1", Filename: "ipxe.efi"}) || pc2.Options["domain-name"] != "pc2.lab" {
+		t.Errorf("Unexpected host %+v", pc2)
+	}
+
+	hosts := cfg.Subnets[0].Hosts
+	if len(hosts) != 2 || hosts[0].Name != "printer" || hosts[0].FixedIP != "192.168.1.10" || hosts[0].Options["domain-name"] != "office" {
+		t.Fatalf("Unexpected subnet hosts %+v", hosts)
+	}
+	if _, ok := hosts[1].Options["domain-name"]; ok {
+		t.Errorf("Group option leaked to host outside of the group: %+v", hosts[1])
+	}
+}
+
+func TestParseScopedLeaseTime(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, `default-lease-time 43200;
+subnet 10.0.99.0/24 {
+  default-lease-time 900;
+  max-lease-time 1800;
+  host kiosk {
+    hardware ethernet 00:11:22:33:44:55;
+    default-lease-time 600;
+  }
+}
+class "phones" {
+  match hardware prefix 00:1a:2b;
+  default-lease-time 86400;
+  delay 0;
+}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	if value := cfg.GlobalOptions[DefaultLeaseTime]; value != "43200" {
+		t.Errorf("Expected global default-lease-time 43200, got %q", value)
+	}
+	subnet := cfg.Subnets[0]
+	if subnet.Options[DefaultLeaseTime] != "900" || subnet.Options[MaxLeaseTime] != "1800" {
+		t.Errorf("Unexpected subnet options %v", subnet.Options)
+	}
+	if value := subnet.Hosts[0].Options[DefaultLeaseTime]; value != "600" {
+		t.Errorf("Expected host default-lease-time 600, got %q", value)
+	}
+	if value := cfg.Classes[0].Options[DefaultLeaseTime]; value != "86400" {
+		t.Errorf("Expected class default-lease-time 86400, got %q", value)
+	}
+	if value, ok := cfg.Classes[0].Options[Delay]; !ok || value != "0" {
+		t.Errorf("Expected class delay 0, got %q", value)
+	}
+}
+
+func FuzzParseConfig(f *testing.F) {
+	if data, err := os.ReadFile("../../configs/dhcpd.conf"); err == nil {
+		f.Add(string(data))
+	}
+	f.Add(writerTestConfig)
+	for _, seed := range []string{
+		"fixed-address;\n",
+		"range;\n",
+		"subnet {\n",
+		"subnet 10.0.0.0 netmask 255.0.0.0 {\n  range dynamic-bootp;\n  fixed-address;\n}\n",
+		"host a {\n  fixed-address\n;\n  option dhcp-client-identifier;\n}\n",
+		"class \"a\" {\n  match if;\n  match hardware prefix;\n}\n",
+		"option a code = ;\noption space;\nsubclass;\n",
+		"group {\n  host b {\n  }\n}\n",
+		"option x concat(substring(hardware, 1, 6), \"\");\n",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		cfg, err := parseConfig(strings.NewReader(content), "fuzz.conf")
+		if err != nil {
+			return
+		}
+		// Разобранная конфигурация не должна вызывать панику и дальше
+		if _, err := Lint(cfg); err != nil {
+			return
+		}
+		if _, err := Marshal(cfg); err != nil {
+			return
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateFunctions функции, доступные шаблонам
+var templateFunctions = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": strings.ReplaceAll,
+}
+
+// templateData переменные шаблона значения опции
+type templateData struct {
+	MAC         string // Аппаратный адрес клиента: 00:11:22:33:44:55
+	IP          string // Адрес клиента (пустая строка - не назначен)
+	Arch        string // Первый код архитектуры из опции 93, например 7 - EFI x64
+	Hostname    string // Имя хоста из опции 12
+	ClientID    string // Идентификатор клиента из опции 61: 01:00:11:...
+	VendorClass string // Класс производителя из опции 60
+}
+
+// isTemplate проверяет, является ли значение шаблоном
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// templateExpression значение с шаблоном text/template, например
+// boot/{{.MAC}}.ipxe
+type templateExpression struct {
+	template *template.Template
+}
+
+// parseTemplate разбирает шаблон и проверяет его на данных без значений,
+// чтобы обращение к неизвестной переменной обнаружилось при загрузке
+func parseTemplate(value string) (Expression, error) {
+	tmpl, err := template.New("value").Funcs(templateFunctions).Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, templateData{}); err != nil {
+		return nil, err
+	}
+	return templateExpression{template: tmpl}, nil
+}
+
+func (e templateExpression) Evaluate(ctx ExprContext) []byte {
+	data := templateData{
+		Hostname:    ctx.Hostname,
+		ClientID:    ctx.ClientID,
+		VendorClass: ctx.VendorClass,
+	}
+	if len(ctx.Hardware) > 1 {
+		data.MAC = net.HardwareAddr(ctx.Hardware[1:]).String()
+	}
+	if ip := ctx.LeasedAddress.To4(); ip != nil {
+		data.IP = ip.String()
+	}
+	if len(ctx.Arch) > 0 {
+		data.Arch = strconv.Itoa(int(ctx.Arch[0]))
+	}
+
+	var result bytes.Buffer
+	if err := e.template.Execute(&result, data); err != nil {
+		// Шаблон проверен при загрузке; пустое значение не отправляется
+		return nil
+	}
+	return result.Bytes()
+}
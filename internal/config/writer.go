@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Marshal записывает конфигурацию в синтаксисе ISC-DHCP. Значения без
+// собственного порядка (опции, объявления опций) выводятся по алфавиту,
+// поэтому одна и та же конфигурация всегда дает один и тот же текст.
+// Сохраняются комментарий файла и комментарии объявлений (Comment);
+// группы записываются развернутыми в хосты, комментарии в конце строк
+// не сохраняются.
+func Marshal(cfg *DHCPConfig) ([]byte, error) {
+	w := &configWriter{}
+	if err := w.config(cfg); err != nil {
+		return nil, err
+	}
+	return []byte(w.b.String()), nil
+}
+
+// Write записывает конфигурацию в w (см. Marshal)
+func Write(w io.Writer, cfg *DHCPConfig) error {
+	data, err := Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// configWriter собирает текст конфигурации
+type configWriter struct {
+	b strings.Builder
+}
+
+// line записывает строку с отступом в два пробела на уровень
+func (w *configWriter) line(indent int, format string, args ...interface{}) {
+	w.b.WriteString(strings.Repeat("  ", indent))
+	fmt.Fprintf(&w.b, format, args...)
+	w.b.WriteByte('\n')
+}
+
+// block отделяет объявление пустой строкой и записывает его комментарий
+func (w *configWriter) block(indent int, comment string) {
+	if w.b.Len() > 0 && !strings.HasSuffix(w.b.String(), "\n\n") {
+		w.b.WriteByte('\n')
+	}
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		if line == "" {
+			w.b.WriteByte('\n')
+			continue
+		}
+		w.line(indent, "%s", line)
+	}
+}
+
+// config записывает глобальные параметры, классы, подсети и хосты
+func (w *configWriter) config(cfg *DHCPConfig) error {
+	// Комментарий файла отделяется от операторов пустой строкой
+	if cfg.Comment != "" {
+		w.block(0, cfg.Comment)
+		w.b.WriteByte('\n')
+	}
+
+	w.authoritative(0, cfg.Authoritative)
+	for _, name := range sortedKeys(cfg.GlobalOptions) {
+		if value := cfg.GlobalOptions[name]; value != "" {
+			w.line(0, "%s %s;", name, value)
+		} else {
+			w.line(0, "%s;", name)
+		}
+	}
+	for _, space := range cfg.Spaces {
+		w.line(0, "option space %s;", space)
+	}
+	names := make([]string, 0, len(cfg.Definitions))
+	for name := range cfg.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.line(0, "option %s code %d = %s;", name, cfg.Definitions[name].Code, cfg.Definitions[name].Type)
+	}
+	w.boot(0, cfg.Boot)
+	w.access(0, cfg.Access)
+	w.options(0, cfg.Options)
+	for _, instance := range cfg.Instances {
+		w.block(0, instance.Comment)
+		w.line(0, "instance %q %q;", instance.Name, instance.File)
+	}
+
+	for i := range cfg.Classes {
+		w.class(&cfg.Classes[i])
+	}
+	for i := range cfg.Subnets {
+		if err := w.subnet(&cfg.Subnets[i], i); err != nil {
+			return err
+		}
+	}
+	for i := range cfg.Hosts {
+		w.host(0, &cfg.Hosts[i])
+	}
+	return nil
+}
+
+// class записывает блок class и операторы subclass его членов
+func (w *configWriter) class(class *Class) {
+	w.block(0, class.Comment)
+	w.line(0, "class \"%s\" {", class.Name)
+	if len(class.Hardware) > 0 {
+		w.line(1, "match hardware;")
+	}
+	for _, prefix := range class.Prefixes {
+		w.line(1, "match hardware prefix %s;", prefix)
+	}
+	if len(class.Match) > 0 {
+		terms := make([]string, len(class.Match))
+		for i, condition := range class.Match {
+			if condition.Value == nil {
+				terms[i] = "exists " + condition.Option
+			} else {
+				terms[i] = fmt.Sprintf("option %s = %s", condition.Option, formatData(condition.Value))
+			}
+		}
+		w.line(1, "match if %s;", strings.Join(terms, " and "))
+	}
+	w.boot(1, class.Boot)
+	w.options(1, class.Options)
+	w.line(0, "}")
+	for _, mac := range class.Hardware {
+		w.line(0, "subclass \"%s\" 1:%s;", class.Name, mac)
+	}
+}
+
+// subnet записывает блок subnet с диапазонами и хостами
+func (w *configWriter) subnet(subnet *Subnet, index int) error {
+	if subnet.Network == nil {
+		return fmt.Errorf("subnet #%d: network is not set", index+1)
+	}
+
+	w.block(0, subnet.Comment)
+	w.line(0, "subnet %s netmask %s {", subnet.Network.IP, net.IP(subnet.Network.Mask))
+	for _, r := range subnet.Ranges {
+		if r.DynamicBOOTP {
+			w.line(1, "range dynamic-bootp %s %s;", r.Start, r.End)
+		} else if r.RandomizedMAC {
+			w.line(1, "range randomized-mac %s %s;", r.Start, r.End)
+		} else {
+			w.line(1, "range %s %s;", r.Start, r.End)
+		}
+	}
+	for _, r := range subnet.Exclusions {
+		if r.Start == r.End {
+			w.line(1, "exclude %s;", r.Start)
+		} else {
+			w.line(1, "exclude %s %s;", r.Start, r.End)
+		}
+	}
+	w.authoritative(1, subnet.Authoritative)
+	w.access(1, subnet.Access)
+	w.boot(1, subnet.Boot)
+	w.options(1, subnet.Options)
+	for i := range subnet.Hosts {
+		w.host(1, &subnet.Hosts[i])
+	}
+	w.line(0, "}")
+	return nil
+}
+
+// host записывает блок host
+func (w *configWriter) host(indent int, host *Host) {
+	w.block(indent, host.Comment)
+	w.line(indent, "host %s {", host.Name)
+	if host.Hardware != "" {
+		w.line(indent+1, "hardware ethernet %s;", host.Hardware)
+	}
+	if host.ClientID != "" {
+		w.line(indent+1, "option dhcp-client-identifier %s;", host.ClientID)
+	}
+	if host.FixedIP != "" {
+		w.line(indent+1, "fixed-address %s;", host.FixedIP)
+	}
+	w.boot(indent+1, host.Boot)
+	w.options(indent+1, host.Options)
+	w.line(indent, "}")
+}
+
+// authoritative записывает authoritative или not authoritative
+func (w *configWriter) authoritative(indent int, value *bool) {
+	switch {
+	case value == nil:
+	case *value:
+		w.line(indent, "authoritative;")
+	default:
+		w.line(indent, "not authoritative;")
+	}
+}
+
+// boot записывает операторы next-server, server-name и filename
+func (w *configWriter) boot(indent int, boot BootParams) {
+	if boot.NextServer != "" {
+		w.line(indent, "next-server %s;", boot.NextServer)
+	}
+	if boot.ServerName != "" {
+		w.line(indent, "server-name %s;", formatValue(boot.ServerName))
+	}
+	if boot.Filename != "" {
+		w.line(indent, "filename %s;", formatValue(boot.Filename))
+	}
+}
+
+// access записывает правила allow и deny
+func (w *configWriter) access(indent int, rules AccessRules) {
+	for _, rule := range []struct{ action, name string }{
+		{rules.KnownClients, "known-clients"},
+		{rules.UnknownClients, "unknown-clients"},
+		{rules.BOOTP, "bootp"},
+	} {
+		if rule.action != "" {
+			w.line(indent, "%s %s;", rule.action, rule.name)
+		}
+	}
+	for _, mac := range rules.AllowMACs {
+		w.line(indent, "allow hardware %s;", mac)
+	}
+	for _, mac := range rules.DenyMACs {
+		w.line(indent, "deny hardware %s;", mac)
+	}
+	for _, class := range rules.AllowClasses {
+		w.line(indent, "allow members of \"%s\";", class)
+	}
+	for _, class := range rules.DenyClasses {
+		w.line(indent, "deny members of \"%s\";", class)
+	}
+}
+
+// options записывает опции уровня и операторы, хранящиеся среди опций
+// (default-lease-time, max-lease-time, delay, vendor-option-space)
+func (w *configWriter) options(indent int, options map[string]string) {
+	for _, name := range sortedKeys(options) {
+		switch value := options[name]; name {
+		case DefaultLeaseTime, MaxLeaseTime, Delay, VendorOptionSpace:
+			w.line(indent, "%s %s;", name, value)
+		default:
+			w.line(indent, "option %s %s;", name, formatValue(value))
+		}
+	}
+}
+
+// formatValue записывает значение опции или имени файла. Выражения,
+// адреса, числа, байты через двоеточие и логические значения записываются
+// без кавычек, остальные значения - в кавычках.
+func formatValue(value string) string {
+	switch {
+	case isExpression(value), value == "true", value == "false", value == "on", value == "off":
+		return value
+	case value != "" && strings.Trim(value, "0123456789abcdefABCDEF.:,% ") == "":
+		return value
+	}
+	return "\"" + value + "\""
+}
+
+// formatData записывает значение условия match if: печатаемый текст в
+// кавычках, иначе байты через двоеточие
+func formatData(data []byte) string {
+	for _, b := range data {
+		if b < 0x20 || b > 0x7e || b == '"' {
+			octets := make([]string, len(data))
+			for i, b := range data {
+				octets[i] = fmt.Sprintf("%02x", b)
+			}
+			return strings.Join(octets, ":")
+		}
+	}
+	return "\"" + string(data) + "\""
+}
+
+// sortedKeys возвращает ключи в алфавитном порядке
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const writerTestConfig = `# Test configuration
+# for the config writer
+
+authoritative;
+ddns-update-style none;
+default-lease-time 600;
+option space pxe;
+option pxe.mtftp-ip code 1 = ip-address;
+option site-id code 224 = unsigned integer 16;
+option domain-name "example.org";
+option site-id 7;
+
+# Tenant VLAN 10
+instance "tenant-a" "tenants/a.conf";
+
+# PXE clients
+class "pxe" {
+  match hardware;
+  vendor-option-space pxe;
+  option pxe.mtftp-ip 10.0.0.1;
+  filename "pxelinux.0";
+}
+subclass "pxe" 1:00:11:22:33:44:55;
+
+class "vendor" {
+  match hardware prefix 00:1a:2b:*;
+  match if option vendor-class-identifier = "PXEClient" and exists user-class;
+}
+
+# Office network
+subnet 192.168.1.0 netmask 255.255.255.0 {
+  range 192.168.1.100 192.168.1.200;
+  range dynamic-bootp 192.168.1.210 192.168.1.220;
+  range randomized-mac 192.168.1.230 192.168.1.240;
+  exclude 192.168.1.150;
+  exclude 192.168.1.160 192.168.1.169;
+  not authoritative;
+  deny unknown-clients;
+  allow members of "pxe";
+  next-server 192.168.1.1;
+  max-lease-time 7200;
+  option routers 192.168.1.1;
+  option host-name concat("pc-", binary-to-ascii(16, 8, "", substring(hardware, 1, 6)));
+
+  # Printer
+  host printer {
+    hardware ethernet 00:11:22:33:44:66;
+    fixed-address 192.168.1.10;
+    server-name "boot server";
+  }
+}
+
+host laptop {
+  option dhcp-client-identifier 01:00:11:22:33:44:77;
+  fixed-address 192.168.1.11;
+  option domain-name-servers 8.8.8.8, 8.8.4.4;
+}
+`
+
+func TestMarshalRoundTrip(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, writerTestConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	reparsed, err := ParseConfig(writeTestConfig(t, string(data)))
+	if err != nil {
+		t.Fatalf("ParseConfig of written config failed: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(cfg, reparsed) {
+		t.Errorf("config changed after round trip:\n%s\nbefore: %+v\nafter:  %+v", data, cfg, reparsed)
+	}
+
+	// Повторная запись дает тот же текст
+	again, err := Marshal(reparsed)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("output is not stable:\n%s\n---\n%s", data, again)
+	}
+}
+
+func TestMarshalComments(t *testing.T) {
+	cfg, err := ParseConfig(writeTestConfig(t, writerTestConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.Comment != "# Test configuration\n# for the config writer" {
+		t.Errorf("Expected file comment, got %q", cfg.Comment)
+	}
+
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		"# Test configuration\n# for the config writer\n\nauthoritative;\n",
+		"# Tenant VLAN 10\ninstance \"tenant-a\" \"tenants/a.conf\";\n",
+		"# PXE clients\nclass \"pxe\" {\n",
+		"# Office network\nsubnet 192.168.1.0 netmask 255.255.255.0 {\n",
+		"  # Printer\n  host printer {\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMarshalOrder(t *testing.T) {
+	cfg := &DHCPConfig{
+		GlobalOptions: map[string]string{"max-lease-time": "7200", "ddns-update-style": "none"},
+		Options:       map[string]string{"routers": "10.0.0.1", "domain-name": "example.org"},
+		Hosts:         []Host{{Name: "pc", Hardware: "00:11:22:33:44:55", FixedIP: "10.0.0.5"}},
+	}
+
+	data, err := Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `ddns-update-style none;
+max-lease-time 7200;
+option domain-name "example.org";
+option routers 10.0.0.1;
+
+host pc {
+  hardware ethernet 00:11:22:33:44:55;
+  fixed-address 10.0.0.5;
+}
+`
+	if string(data) != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	if _, err := Marshal(&DHCPConfig{Subnets: []Subnet{{}}}); err == nil {
+		t.Error("Expected error for subnet without network")
+	}
+}
@@ -0,0 +1,53 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"time"
+)
+
+// defaultTimeout время ожидания ответа управляющего сокета
+const defaultTimeout = 5 * time.Second
+
+// Call вызывает метод на управляющем сокете path и декодирует результат в result
+func Call(path string, method string, params interface{}, result interface{}) error {
+	conn, err := net.DialTimeout("unix", path, defaultTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(defaultTimeout))
+
+	request := &Request{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: method}
+	if params != nil {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = encoded
+	}
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+
+	response := &Response{}
+	if err := json.Unmarshal(line, response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if result != nil && len(response.Result) > 0 {
+		return json.Unmarshal(response.Result, result)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Коды ошибок JSON-RPC 2.0
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// maxRequestSize максимальный размер одного запроса
+const maxRequestSize = 1024 * 1024
+
+// Request представляет запрос JSON-RPC 2.0
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response представляет ответ JSON-RPC 2.0
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error представляет ошибку JSON-RPC 2.0
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// InvalidParams возвращает ошибку некорректных параметров метода
+func InvalidParams(format string, args ...interface{}) error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// HandlerFunc обрабатывает вызов метода
+type HandlerFunc func(params json.RawMessage) (interface{}, error)
+
+// Server управляющий сервер на Unix сокете. Каждая строка - отдельный
+// запрос JSON-RPC 2.0, ответ также записывается одной строкой.
+type Server struct {
+	path     string
+	listener net.Listener
+	mutex    sync.RWMutex
+	handlers map[string]HandlerFunc
+	wg       sync.WaitGroup
+}
+
+// NewServer создает управляющий сервер на сокете path
+func NewServer(path string) *Server {
+	return &Server{
+		path:     path,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle регистрирует обработчик метода
+func (s *Server) Handle(method string, handler HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.handlers[method] = handler
+}
+
+// Start начинает прием соединений
+func (s *Server) Start() error {
+	// Удаляем оставшийся от предыдущего запуска сокет
+	if _, err := os.Stat(s.path); err == nil {
+		if conn, err := net.Dial("unix", s.path); err == nil {
+			conn.Close()
+			return fmt.Errorf("control socket %s is in use", s.path)
+		}
+		os.Remove(s.path)
+	}
+
+	// Доступ к сокету только для владельца
+	listener, err := listenUnix(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.listener = listener
+	logrus.Infof("Control socket listening on %s", s.path)
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	return nil
+}
+
+// Stop закрывает сокет
+func (s *Server) Stop() {
+	if s.listener != nil {
+		s.listener.Close()
+		s.wg.Wait()
+	}
+}
+
+// acceptLoop принимает входящие соединения
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logrus.Errorf("Error accepting control connection: %v", err)
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn обрабатывает запросы одного соединения
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxRequestSize)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		response := s.dispatch(scanner.Bytes())
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// dispatch выполняет один запрос
+func (s *Server) dispatch(data []byte) *Response {
+	response := &Response{JSONRPC: "2.0"}
+
+	request := &Request{}
+	if err := json.Unmarshal(data, request); err != nil {
+		response.Error = &Error{Code: CodeParseError, Message: err.Error()}
+		return response
+	}
+	response.ID = request.ID
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		response.Error = &Error{Code: CodeInvalidRequest, Message: "invalid request"}
+		return response
+	}
+
+	s.mutex.RLock()
+	handler, ok := s.handlers[request.Method]
+	s.mutex.RUnlock()
+	if !ok {
+		response.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + request.Method}
+		return response
+	}
+
+	result, err := handler(request.Params)
+	if err != nil {
+		var rpcErr *Error
+		if errors.As(err, &rpcErr) {
+			response.Error = rpcErr
+		} else {
+			response.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return response
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		response.Error = &Error{Code: CodeInternalError, Message: err.Error()}
+		return response
+	}
+	response.Result = encoded
+
+	return response
+}
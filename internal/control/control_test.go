@@ -0,0 +1,119 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func startTestServer(t *testing.T) (*Server, string) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	server := NewServer(path)
+
+	server.Handle("echo", func(params json.RawMessage) (interface{}, error) {
+		var args map[string]string
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, InvalidParams("expected object: %v", err)
+		}
+		return args, nil
+	})
+	server.Handle("fail", func(params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("something went wrong")
+	})
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start control server: %v", err)
+	}
+	t.Cleanup(server.Stop)
+
+	return server, path
+}
+
+func TestControlCall(t *testing.T) {
+	_, path := startTestServer(t)
+
+	var result map[string]string
+	if err := Call(path, "echo", map[string]string{"mac": "00:11:22:33:44:55"}, &result); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if result["mac"] != "00:11:22:33:44:55" {
+		t.Errorf("Expected echoed mac, got %v", result)
+	}
+}
+
+func TestControlErrors(t *testing.T) {
+	_, path := startTestServer(t)
+
+	tests := []struct {
+		method string
+		params interface{}
+		code   int
+	}{
+		{"missing", nil, CodeMethodNotFound},
+		{"echo", []int{1, 2}, CodeInvalidParams},
+		{"fail", nil, CodeInternalError},
+	}
+
+	for _, test := range tests {
+		err := Call(path, test.method, test.params, nil)
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			t.Errorf("%s: expected RPC error, got %v", test.method, err)
+			continue
+		}
+		if rpcErr.Code != test.code {
+			t.Errorf("%s: expected code %d, got %d", test.method, test.code, rpcErr.Code)
+		}
+	}
+}
+
+func TestControlMalformedRequest(t *testing.T) {
+	_, path := startTestServer(t)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("not json\n{\"method\":\"echo\"}\n"))
+
+	decoder := json.NewDecoder(conn)
+	for _, code := range []int{CodeParseError, CodeInvalidRequest} {
+		response := &Response{}
+		if err := decoder.Decode(response); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if response.Error == nil || response.Error.Code != code {
+			t.Errorf("Expected error code %d, got %+v", code, response.Error)
+		}
+	}
+}
+
+func TestControlSocketPermissionsAndReuse(t *testing.T) {
+	server, path := startTestServer(t)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Второй сервер не может занять используемый сокет
+	if err := NewServer(path).Start(); err == nil {
+		t.Error("Expected error starting second server on the same socket")
+	}
+
+	// После остановки сокет можно занять снова
+	server.Stop()
+	second := NewServer(path)
+	if err := second.Start(); err != nil {
+		t.Fatalf("Expected to reuse stale socket: %v", err)
+	}
+	second.Stop()
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package control
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix открывает unix сокет и ограничивает доступ к нему владельцем
+func listenUnix(path string) (net.Listener, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package control
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix открывает unix сокет под umask 0177, чтобы файл сокета
+// с момента создания был доступен только владельцу
+func listenUnix(path string) (net.Listener, error) {
+	mask := syscall.Umask(0177)
+	defer syscall.Umask(mask)
+
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,119 @@
+// Package daemon собирает процесс serve из модулей: основной BOOTP/DHCPv4
+// сервер и логические серверы, их встроенные TFTP, HTTP boot и DNS
+// серверы, управляющий сокет, API, подагент SNMP и публикацию событий.
+// Модули запускаются по порядку добавления и останавливаются в обратном,
+// журнал и статистика основного сервера у них общие.
+package daemon
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// module модуль демона
+type module struct {
+	name  string
+	start func() error
+	stop  func()
+}
+
+// Daemon модули процесса serve с общими запуском, остановкой и
+// перезагрузкой конфигурации
+type Daemon struct {
+	srv     *server.BOOTPServer
+	logger  logrus.FieldLogger
+	modules []module
+	started []module // Запущенные модули в порядке запуска
+	reload  func() (*config.DHCPConfig, error)
+	onStop  []func()
+}
+
+// New создает демон без модулей. srv - основной сервер, статистику и
+// аренды которого отдают управляющий сокет и API (может быть nil).
+func New(srv *server.BOOTPServer, logger logrus.FieldLogger) *Daemon {
+	return &Daemon{srv: srv, logger: logger}
+}
+
+// Server возвращает основной сервер
+func (d *Daemon) Server() *server.BOOTPServer {
+	return d.srv
+}
+
+// Add добавляет модуль в конец порядка запуска
+func (d *Daemon) Add(name string, start func() error, stop func()) {
+	d.modules = append(d.modules, module{name: name, start: start, stop: stop})
+}
+
+// AddServer добавляет BOOTP сервер и его встроенные серверы (см.
+// server.Services) отдельными модулями. prefix добавляется к именам
+// модулей, например "instance lab ".
+func (d *Daemon) AddServer(prefix string, srv *server.BOOTPServer) {
+	d.Add(prefix+"BOOTP server", srv.Start, srv.Stop)
+	for _, service := range srv.Services() {
+		d.Add(prefix+service.Name, service.Start, service.Stop)
+	}
+}
+
+// Modules возвращает имена модулей в порядке запуска
+func (d *Daemon) Modules() []string {
+	names := make([]string, 0, len(d.modules))
+	for _, module := range d.modules {
+		names = append(names, module.name)
+	}
+	return names
+}
+
+// SetReload задает перезагрузку конфигурации серверов демона
+func (d *Daemon) SetReload(reload func() (*config.DHCPConfig, error)) {
+	d.reload = reload
+}
+
+// OnStop добавляет действие, выполняемое после остановки модулей, например
+// закрытие назначений журнала. Действия выполняются один раз.
+func (d *Daemon) OnStop(action func()) {
+	d.onStop = append(d.onStop, action)
+}
+
+// Start запускает модули. При ошибке уже запущенные модули
+// останавливаются.
+func (d *Daemon) Start() error {
+	for _, module := range d.modules {
+		if err := module.start(); err != nil {
+			d.stopModules()
+			return err
+		}
+		d.logger.Debugf("Started %s", module.name)
+		d.started = append(d.started, module)
+	}
+	return nil
+}
+
+// Stop останавливает запущенные модули в обратном порядке и выполняет
+// действия OnStop
+func (d *Daemon) Stop() {
+	d.stopModules()
+	for _, action := range d.onStop {
+		action()
+	}
+	d.onStop = nil
+}
+
+// stopModules останавливает запущенные модули в обратном порядке
+func (d *Daemon) stopModules() {
+	for i := len(d.started) - 1; i >= 0; i-- {
+		d.started[i].stop()
+		d.logger.Debugf("Stopped %s", d.started[i].name)
+	}
+	d.started = nil
+}
+
+// Reload перечитывает конфигурацию серверов демона
+func (d *Daemon) Reload() (*config.DHCPConfig, error) {
+	if d.reload == nil {
+		return nil, errors.New("reload is not supported")
+	}
+	return d.reload()
+}
@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDaemonStartFailure(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	var events []string
+	d := New(nil, logger)
+	add := func(name string, err error) {
+		d.Add(name, func() error {
+			events = append(events, "start "+name)
+			return err
+		}, func() { events = append(events, "stop "+name) })
+	}
+	add("a", nil)
+	add("b", nil)
+	add("c", errors.New("address in use"))
+	add("d", nil)
+
+	stopped := 0
+	d.OnStop(func() { stopped++ })
+
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(d.Modules(), want) {
+		t.Errorf("Expected modules %v, got %v", want, d.Modules())
+	}
+	if err := d.Start(); err == nil || err.Error() != "address in use" {
+		t.Fatalf("Expected start error, got %v", err)
+	}
+	want := []string{"start a", "start b", "start c", "stop b", "stop a"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Expected %v, got %v", want, events)
+	}
+
+	// Повторная остановка не затрагивает модули, действия OnStop
+	// выполняются один раз
+	d.Stop()
+	d.Stop()
+	if len(events) != len(want) {
+		t.Errorf("Unexpected events after Stop: %v", events[len(want):])
+	}
+	if stopped != 1 {
+		t.Errorf("Expected OnStop action to run once, ran %d times", stopped)
+	}
+
+	if _, err := d.Reload(); err == nil {
+		t.Error("Expected Reload to fail without reload function")
+	}
+}
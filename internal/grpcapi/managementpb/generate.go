@@ -0,0 +1,5 @@
+// Package managementpb содержит сгенерированный код сервиса Management
+// из management.proto. Файлы *.pb.go не редактируются вручную.
+package managementpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative management.proto
@@ -0,0 +1,273 @@
+// Описание gRPC API управления go-bootp. Код Go генерируется командой
+// go generate (см. generate.go).
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: management.proto
+
+package managementpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Management_Leases_FullMethodName       = "/gobootp.v1.Management/Leases"
+	Management_Reservations_FullMethodName = "/gobootp.v1.Management/Reservations"
+	Management_Events_FullMethodName       = "/gobootp.v1.Management/Events"
+)
+
+// ManagementClient is the client API for Management service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ManagementClient interface {
+	// Leases передает текущую таблицу назначений и завершает поток
+	Leases(ctx context.Context, in *LeasesRequest, opts ...grpc.CallOption) (Management_LeasesClient, error)
+	// Reservations передает статические резервирования и завершает поток
+	Reservations(ctx context.Context, in *ReservationsRequest, opts ...grpc.CallOption) (Management_ReservationsClient, error)
+	// Events передает события аренд до отключения клиента
+	Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Management_EventsClient, error)
+}
+
+type managementClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewManagementClient(cc grpc.ClientConnInterface) ManagementClient {
+	return &managementClient{cc}
+}
+
+func (c *managementClient) Leases(ctx context.Context, in *LeasesRequest, opts ...grpc.CallOption) (Management_LeasesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Management_ServiceDesc.Streams[0], Management_Leases_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementLeasesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Management_LeasesClient interface {
+	Recv() (*Lease, error)
+	grpc.ClientStream
+}
+
+type managementLeasesClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementLeasesClient) Recv() (*Lease, error) {
+	m := new(Lease)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managementClient) Reservations(ctx context.Context, in *ReservationsRequest, opts ...grpc.CallOption) (Management_ReservationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Management_ServiceDesc.Streams[1], Management_Reservations_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementReservationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Management_ReservationsClient interface {
+	Recv() (*Reservation, error)
+	grpc.ClientStream
+}
+
+type managementReservationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementReservationsClient) Recv() (*Reservation, error) {
+	m := new(Reservation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managementClient) Events(ctx context.Context, in *EventsRequest, opts ...grpc.CallOption) (Management_EventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Management_ServiceDesc.Streams[2], Management_Events_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Management_EventsClient interface {
+	Recv() (*LeaseEvent, error)
+	grpc.ClientStream
+}
+
+type managementEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementEventsClient) Recv() (*LeaseEvent, error) {
+	m := new(LeaseEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ManagementServer is the server API for Management service.
+// All implementations must embed UnimplementedManagementServer
+// for forward compatibility
+type ManagementServer interface {
+	// Leases передает текущую таблицу назначений и завершает поток
+	Leases(*LeasesRequest, Management_LeasesServer) error
+	// Reservations передает статические резервирования и завершает поток
+	Reservations(*ReservationsRequest, Management_ReservationsServer) error
+	// Events передает события аренд до отключения клиента
+	Events(*EventsRequest, Management_EventsServer) error
+	mustEmbedUnimplementedManagementServer()
+}
+
+// UnimplementedManagementServer must be embedded to have forward compatible implementations.
+type UnimplementedManagementServer struct {
+}
+
+func (UnimplementedManagementServer) Leases(*LeasesRequest, Management_LeasesServer) error {
+	return status.Errorf(codes.Unimplemented, "method Leases not implemented")
+}
+func (UnimplementedManagementServer) Reservations(*ReservationsRequest, Management_ReservationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Reservations not implemented")
+}
+func (UnimplementedManagementServer) Events(*EventsRequest, Management_EventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Events not implemented")
+}
+func (UnimplementedManagementServer) mustEmbedUnimplementedManagementServer() {}
+
+// UnsafeManagementServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ManagementServer will
+// result in compilation errors.
+type UnsafeManagementServer interface {
+	mustEmbedUnimplementedManagementServer()
+}
+
+func RegisterManagementServer(s grpc.ServiceRegistrar, srv ManagementServer) {
+	s.RegisterService(&Management_ServiceDesc, srv)
+}
+
+func _Management_Leases_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LeasesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagementServer).Leases(m, &managementLeasesServer{stream})
+}
+
+type Management_LeasesServer interface {
+	Send(*Lease) error
+	grpc.ServerStream
+}
+
+type managementLeasesServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementLeasesServer) Send(m *Lease) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Management_Reservations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReservationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagementServer).Reservations(m, &managementReservationsServer{stream})
+}
+
+type Management_ReservationsServer interface {
+	Send(*Reservation) error
+	grpc.ServerStream
+}
+
+type managementReservationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementReservationsServer) Send(m *Reservation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Management_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagementServer).Events(m, &managementEventsServer{stream})
+}
+
+type Management_EventsServer interface {
+	Send(*LeaseEvent) error
+	grpc.ServerStream
+}
+
+type managementEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementEventsServer) Send(m *LeaseEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Management_ServiceDesc is the grpc.ServiceDesc for Management service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Management_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gobootp.v1.Management",
+	HandlerType: (*ManagementServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Leases",
+			Handler:       _Management_Leases_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Reservations",
+			Handler:       _Management_Reservations_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Events",
+			Handler:       _Management_Events_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "management.proto",
+}
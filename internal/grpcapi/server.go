@@ -0,0 +1,156 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/grpcapi/managementpb"
+	"github.com/user/go-bootp/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Config параметры gRPC API
+type Config struct {
+	Listen   string // Адрес прослушивания
+	CertFile string // Сертификат TLS
+	KeyFile  string // Закрытый ключ TLS
+	Token    string // Токен, передаваемый клиентом в "authorization: Bearer <token>"
+	Insecure bool   // Разрешить работу без TLS
+}
+
+func init() {
+	config.RegisterGlobalOptions("grpc-listen", "grpc-tls-cert", "grpc-tls-key", "grpc-token", "grpc-token-file", "grpc-insecure")
+}
+
+// ConfigFromOptions читает параметры API из глобальных опций конфигурации.
+// Возвращает nil, если опция grpc-listen не задана.
+func ConfigFromOptions(options map[string]string) (*Config, error) {
+	listen := strings.Trim(options["grpc-listen"], "\"")
+	if listen == "" {
+		return nil, nil
+	}
+
+	cfg := &Config{
+		Listen:   listen,
+		CertFile: strings.Trim(options["grpc-tls-cert"], "\""),
+		KeyFile:  strings.Trim(options["grpc-tls-key"], "\""),
+		Token:    strings.Trim(options["grpc-token"], "\""),
+	}
+	_, cfg.Insecure = options["grpc-insecure"]
+
+	// Токен удобнее хранить в отдельном файле с ограниченными правами
+	if path := strings.Trim(options["grpc-token-file"], "\""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("grpc-token-file: %v", err)
+		}
+		cfg.Token = strings.TrimSpace(string(data))
+	}
+
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("grpc-listen requires grpc-token or grpc-token-file")
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("grpc-tls-cert and grpc-tls-key must be set together")
+	}
+	if cfg.CertFile == "" && !cfg.Insecure {
+		return nil, fmt.Errorf("grpc-listen requires grpc-tls-cert and grpc-tls-key (or grpc-insecure)")
+	}
+
+	return cfg, nil
+}
+
+// Server gRPC сервер API управления
+type Server struct {
+	config   *Config
+	grpc     *grpc.Server
+	listener net.Listener
+}
+
+// NewServer создает сервер API для BOOTP сервера
+func NewServer(bootp *server.BOOTPServer, cfg *Config) (*Server, error) {
+	s := &Server{config: cfg}
+
+	opts := []grpc.ServerOption{
+		grpc.StreamInterceptor(s.authorizeStream),
+	}
+	if cfg.CertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("grpc tls: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s.grpc = grpc.NewServer(opts...)
+	managementpb.RegisterManagementServer(s.grpc, &service{bootp: bootp})
+
+	return s, nil
+}
+
+// Start начинает прием соединений
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.Listen)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	if s.config.CertFile == "" {
+		logrus.Warnf("gRPC API listening on %s without TLS", listener.Addr())
+	} else {
+		logrus.Infof("gRPC API listening on %s", listener.Addr())
+	}
+
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			logrus.Errorf("gRPC API stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr возвращает адрес прослушивания
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop закрывает все соединения, включая открытые потоки событий
+func (s *Server) Stop() {
+	s.grpc.Stop()
+}
+
+// authorizeStream проверяет токен перед вызовом потокового метода
+func (s *Server) authorizeStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(stream.Context()); err != nil {
+		logrus.Warnf("Rejected gRPC call %s: %v", info.FullMethod, err)
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// authorize сравнивает токен из метаданных с настроенным
+func (s *Server) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token := strings.TrimPrefix(value, "Bearer ")
+		if token != value && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
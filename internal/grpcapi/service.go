@@ -0,0 +1,109 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/user/go-bootp/internal/grpcapi/managementpb"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Тип события для аренд, переданных в начале потока Events
+const eventSnapshot = "snapshot"
+
+// service реализует managementpb.ManagementServer поверх BOOTP сервера
+type service struct {
+	managementpb.UnimplementedManagementServer
+
+	bootp *server.BOOTPServer
+}
+
+// Leases передает текущие назначения
+func (s *service) Leases(req *managementpb.LeasesRequest, stream managementpb.Management_LeasesServer) error {
+	for _, lease := range s.bootp.Leases() {
+		if req.Subnet != "" && lease.Subnet != req.Subnet {
+			continue
+		}
+		if err := stream.Send(newLease(lease)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reservations передает статические резервирования
+func (s *service) Reservations(req *managementpb.ReservationsRequest, stream managementpb.Management_ReservationsServer) error {
+	for _, reservation := range s.bootp.Reservations() {
+		m := &managementpb.Reservation{
+			Name:     reservation.Name,
+			Mac:      reservation.MAC,
+			Ip:       reservation.IP,
+			Subnet:   reservation.Subnet,
+			ClientId: reservation.ClientID,
+		}
+		if err := stream.Send(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events передает события аренд, пока клиент не отключится
+func (s *service) Events(req *managementpb.EventsRequest, stream managementpb.Management_EventsServer) error {
+	// Подписываемся до снимка, чтобы не пропустить изменения между ними
+	events, unsubscribe := s.bootp.SubscribeLeaseEvents(0)
+	defer unsubscribe()
+
+	if req.Snapshot {
+		now := time.Now()
+		for _, lease := range s.bootp.Leases() {
+			if err := stream.Send(newLeaseEvent(eventSnapshot, now, lease)); err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(newLeaseEvent(string(event.Type), event.Time, event.Lease)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newLease преобразует назначение сервера в сообщение
+func newLease(lease server.Lease) *managementpb.Lease {
+	m := &managementpb.Lease{
+		Ip:       lease.IP,
+		Mac:      lease.MAC,
+		Subnet:   lease.Subnet,
+		Type:     lease.Type,
+		Active:   lease.Active,
+		ClientId: lease.ClientID,
+		Hostname: lease.Hostname,
+		SubnetId: lease.SubnetID,
+		Bootp:    lease.BOOTP,
+	}
+	if !lease.Expires.IsZero() {
+		m.ExpiresUnix = lease.Expires.Unix()
+	}
+	return m
+}
+
+// newLeaseEvent преобразует событие сервера в сообщение
+func newLeaseEvent(eventType string, when time.Time, lease server.Lease) *managementpb.LeaseEvent {
+	return &managementpb.LeaseEvent{Type: eventType, TimeUnixNano: when.UnixNano(), Lease: newLease(lease)}
+}
+
+// LeaseEventMessage преобразует событие аренды в сообщение LeaseEvent,
+// которое передает метод Events
+func LeaseEventMessage(event server.LeaseEvent) *managementpb.LeaseEvent {
+	return newLeaseEvent(string(event.Type), event.Time, event.Lease)
+}
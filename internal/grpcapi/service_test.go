@@ -0,0 +1,256 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/grpcapi/managementpb"
+	"github.com/user/go-bootp/internal/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testStream реализует серверный поток для вызова методов сервиса напрямую
+type testStream[T any] struct {
+	grpc.ServerStream
+	ctx    context.Context
+	mutex  sync.Mutex
+	sent   []T
+	notify chan struct{}
+}
+
+func newTestStream[T any](ctx context.Context) *testStream[T] {
+	return &testStream[T]{ctx: ctx, notify: make(chan struct{}, 16)}
+}
+
+func (s *testStream[T]) Context() context.Context { return s.ctx }
+
+func (s *testStream[T]) Send(m T) error {
+	s.mutex.Lock()
+	s.sent = append(s.sent, m)
+	s.mutex.Unlock()
+
+	s.notify <- struct{}{}
+	return nil
+}
+
+func (s *testStream[T]) messages() []T {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]T(nil), s.sent...)
+}
+
+func newTestBOOTPServer(t *testing.T) *server.BOOTPServer {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Hosts: []config.Host{
+					{Name: "client1", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"},
+				},
+			},
+		},
+	}
+
+	bootp, err := server.NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return bootp
+}
+
+func TestLeasesStream(t *testing.T) {
+	svc := &service{bootp: newTestBOOTPServer(t)}
+	stream := newTestStream[*managementpb.Lease](context.Background())
+	if err := svc.Leases(&managementpb.LeasesRequest{}, stream); err != nil {
+		t.Fatalf("Leases failed: %v", err)
+	}
+
+	sent := stream.messages()
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 lease, got %d", len(sent))
+	}
+	if lease := sent[0]; lease.Ip != "192.168.1.10" || lease.Type != "static" {
+		t.Errorf("Unexpected lease: %+v", lease)
+	}
+
+	// Фильтр по подсети
+	stream = newTestStream[*managementpb.Lease](context.Background())
+	svc.Leases(&managementpb.LeasesRequest{Subnet: "10.0.0.0"}, stream)
+	if len(stream.messages()) != 0 {
+		t.Error("Expected no leases for unknown subnet")
+	}
+}
+
+func TestReservationsStream(t *testing.T) {
+	svc := &service{bootp: newTestBOOTPServer(t)}
+	stream := newTestStream[*managementpb.Reservation](context.Background())
+	if err := svc.Reservations(&managementpb.ReservationsRequest{}, stream); err != nil {
+		t.Fatalf("Reservations failed: %v", err)
+	}
+
+	sent := stream.messages()
+	if len(sent) != 1 || sent[0].Name != "client1" {
+		t.Errorf("Unexpected reservations: %+v", sent)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	bootp := newTestBOOTPServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newTestStream[*managementpb.LeaseEvent](ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&service{bootp: bootp}).Events(&managementpb.EventsRequest{Snapshot: true}, stream)
+	}()
+
+	// Снимок текущих аренд
+	select {
+	case <-stream.notify:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for snapshot")
+	}
+
+	// Живое событие (ReleaseLease публикует событие released)
+	bootp.ReleaseLease("192.168.1.10")
+	select {
+	case <-stream.notify:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for lease event")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean stream end, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Events did not stop after client disconnect")
+	}
+
+	sent := stream.messages()
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(sent))
+	}
+	if event := sent[0]; event.Type != eventSnapshot || event.Lease.Ip != "192.168.1.10" {
+		t.Errorf("Unexpected snapshot event: %+v", event)
+	}
+	if event := sent[1]; event.Type != "released" || event.Lease.Mac != "00:11:22:33:44:55" {
+		t.Errorf("Unexpected live event: %+v", event)
+	}
+}
+
+func TestManagementOverGRPC(t *testing.T) {
+	api, err := NewServer(newTestBOOTPServer(t), &Config{Listen: "127.0.0.1:0", Token: "secret", Insecure: true})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	if err := api.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer api.Stop()
+
+	conn, err := grpc.Dial(api.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := managementpb.NewManagementClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Без токена вызов отклоняется
+	stream, err := client.Leases(ctx, &managementpb.LeasesRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without token, got %v", err)
+	}
+
+	authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	stream, err = client.Leases(authorized, &managementpb.LeasesRequest{})
+	if err != nil {
+		t.Fatalf("Leases failed: %v", err)
+	}
+	lease, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if lease.Ip != "192.168.1.10" || lease.Mac != "00:11:22:33:44:55" {
+		t.Errorf("Unexpected lease: %+v", lease)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected end of stream, got %v", err)
+	}
+}
+
+func TestAuthorizeStream(t *testing.T) {
+	s := &Server{config: &Config{Token: "secret"}}
+	handler := func(srv interface{}, stream grpc.ServerStream) error { return nil }
+	info := &grpc.StreamServerInfo{FullMethod: "/gobootp.v1.Management/Leases"}
+
+	tests := []struct {
+		md   metadata.MD
+		code codes.Code
+	}{
+		{metadata.Pairs("authorization", "Bearer secret"), codes.OK},
+		{metadata.Pairs("authorization", "Bearer wrong"), codes.Unauthenticated},
+		{metadata.Pairs("authorization", "secretive"), codes.Unauthenticated},
+		{metadata.Pairs("authorization", "secret"), codes.Unauthenticated},
+		{nil, codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, test.md)
+		}
+		err := s.authorizeStream(nil, newTestStream[*managementpb.Lease](ctx), info, handler)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("%v: expected %v, got %v", test.md, test.code, code)
+		}
+	}
+}
+
+func TestConfigFromOptions(t *testing.T) {
+	if cfg, err := ConfigFromOptions(map[string]string{}); cfg != nil || err != nil {
+		t.Errorf("Expected API to be disabled without grpc-listen, got %+v, %v", cfg, err)
+	}
+
+	invalid := []map[string]string{
+		{"grpc-listen": ":9090", "grpc-tls-cert": "cert.pem", "grpc-tls-key": "key.pem"},
+		{"grpc-listen": ":9090", "grpc-token": "secret"},
+		{"grpc-listen": ":9090", "grpc-token": "secret", "grpc-tls-cert": "cert.pem"},
+		{"grpc-listen": ":9090", "grpc-token-file": "/nonexistent/token"},
+	}
+	for _, options := range invalid {
+		if _, err := ConfigFromOptions(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+
+	cfg, err := ConfigFromOptions(map[string]string{
+		"grpc-listen":   "\"127.0.0.1:9090\"",
+		"grpc-token":    "\"secret\"",
+		"grpc-insecure": "",
+	})
+	if err != nil {
+		t.Fatalf("ConfigFromOptions failed: %v", err)
+	}
+	if cfg.Listen != "127.0.0.1:9090" || cfg.Token != "secret" || !cfg.Insecure {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+}
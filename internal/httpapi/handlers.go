@@ -0,0 +1,222 @@
+package httpapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Ограничения журнала последних запросов и выборки из журнала аудита
+const (
+	defaultRequestsLimit = 100
+	maxRequestsLimit     = 1000
+	defaultAuditLimit    = 100
+	maxAuditLimit        = 10000
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// handler обработчики HTTP API
+type handler struct {
+	bootp *server.BOOTPServer
+}
+
+// NewHandler возвращает обработчик REST API и, если включен, веб-интерфейса.
+// Страница веб-интерфейса не содержит данных и отдается без токена,
+// все запросы к /api/ требуют токен.
+func NewHandler(bootp *server.BOOTPServer, cfg *Config) http.Handler {
+	h := &handler{bootp: bootp}
+
+	api := http.NewServeMux()
+	api.HandleFunc("/api/v1/subnets", h.subnets)
+	api.HandleFunc("/api/v1/stats", h.stats)
+	api.HandleFunc("/api/v1/leases", h.leases)
+	api.HandleFunc("/api/v1/leases/", h.release)
+	api.HandleFunc("/api/v1/reservations", h.reservations)
+	api.HandleFunc("/api/v1/requests", h.requests)
+	api.HandleFunc("/api/v1/timeline/", h.timeline)
+	api.HandleFunc("/api/v1/audit", h.audit)
+	api.HandleFunc("/api/v1/conflicts", h.conflicts)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", authorize(cfg.Token, api))
+	if cfg.Dashboard {
+		mux.HandleFunc("/", h.dashboard)
+	}
+
+	return mux
+}
+
+// writeJSON отправляет ответ в формате JSON
+func writeJSON(w http.ResponseWriter, r *http.Request, value interface{}) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.Debugf("Error writing API response: %v", err)
+	}
+}
+
+// subnets GET /api/v1/subnets - заполненность пулов
+func (h *handler) subnets(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, h.bootp.SubnetUtilization())
+}
+
+// stats GET /api/v1/stats - заполненность пулов и счетчики запросов
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, h.bootp.Stats())
+}
+
+// leases GET /api/v1/leases?subnet=&state= - таблица назначений
+func (h *handler) leases(w http.ResponseWriter, r *http.Request) {
+	subnet := r.URL.Query().Get("subnet")
+	state := r.URL.Query().Get("state")
+
+	leases := make([]server.Lease, 0)
+	for _, lease := range h.bootp.Leases() {
+		if subnet != "" && lease.Subnet != subnet {
+			continue
+		}
+		if state != "" && lease.State != state {
+			continue
+		}
+		leases = append(leases, lease)
+	}
+	writeJSON(w, r, leases)
+}
+
+// release DELETE /api/v1/leases/<ip|mac> - освобождает аренду, например
+// выведенной из работы машины
+func (h *handler) release(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	addr := strings.TrimPrefix(r.URL.Path, "/api/v1/leases/")
+	if addr == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	lease, err := h.bootp.ReleaseLease(addr)
+	if errors.Is(err, server.ErrLeaseNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(lease); err != nil {
+		logrus.Debugf("Error writing API response: %v", err)
+	}
+}
+
+// reservations GET /api/v1/reservations - статические резервирования
+func (h *handler) reservations(w http.ResponseWriter, r *http.Request) {
+	reservations := h.bootp.Reservations()
+	if reservations == nil {
+		reservations = []server.Reservation{}
+	}
+	writeJSON(w, r, reservations)
+}
+
+// conflicts GET /api/v1/conflicts - конфликты адресов, найденные
+// последним сканированием ARP
+func (h *handler) conflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts := h.bootp.Conflicts()
+	if conflicts == nil {
+		conflicts = []server.Conflict{}
+	}
+	writeJSON(w, r, conflicts)
+}
+
+// requests GET /api/v1/requests?limit= - последние обработанные запросы
+func (h *handler) requests(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRequestsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxRequestsLimit {
+			n = maxRequestsLimit
+		}
+		limit = n
+	}
+
+	events := h.bootp.RecentRequests(limit)
+	if events == nil {
+		events = []server.BootEvent{}
+	}
+	writeJSON(w, r, events)
+}
+
+// timeline GET /api/v1/timeline/<mac> - хронология загрузки клиента
+func (h *handler) timeline(w http.ResponseWriter, r *http.Request) {
+	mac := strings.TrimPrefix(r.URL.Path, "/api/v1/timeline/")
+	if mac == "" {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, r, h.bootp.BootTimeline(mac))
+}
+
+// audit GET /api/v1/audit?mac=&ip=&action=&since=&limit= - журнал аудита,
+// since задается в формате RFC 3339
+func (h *handler) audit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := server.AuditFilter{
+		MAC:    query.Get("mac"),
+		IP:     query.Get("ip"),
+		Action: server.AuditAction(query.Get("action")),
+		Limit:  defaultAuditLimit,
+	}
+	if value := query.Get("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		filter.Since = since
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if n > maxAuditLimit {
+			n = maxAuditLimit
+		}
+		filter.Limit = n
+	}
+
+	writeJSON(w, r, h.bootp.AuditLog(filter))
+}
+
+// dashboard GET / - веб-интерфейс
+func (h *handler) dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
@@ -0,0 +1,278 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// testToken токен доступа в тестах API
+const testToken = "secret"
+
+func newTestBOOTPServer(t *testing.T) *server.BOOTPServer {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.109"}},
+				Hosts: []config.Host{
+					{Name: "client1", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"},
+				},
+			},
+		},
+	}
+
+	bootp, err := server.NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return bootp
+}
+
+func get(t *testing.T, handler http.Handler, path string, result interface{}) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.Header.Set("Authorization", "Bearer "+testToken)
+	handler.ServeHTTP(recorder, request)
+	if result != nil && recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", path, err)
+		}
+	}
+	return recorder
+}
+
+func TestSubnetsEndpoint(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken})
+
+	var subnets []server.SubnetUsage
+	if recorder := get(t, handler, "/api/v1/subnets", &subnets); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	if len(subnets) != 1 {
+		t.Fatalf("Expected 1 subnet, got %d", len(subnets))
+	}
+	if subnets[0].Size != 10 || subnets[0].Static != 1 || subnets[0].Free != 10 {
+		t.Errorf("Unexpected subnet usage: %+v", subnets[0])
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken})
+
+	var stats server.Stats
+	if recorder := get(t, handler, "/api/v1/stats", &stats); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	if len(stats.Subnets) != 1 || stats.Subnets[0].ID != "192.168.1.0/24" {
+		t.Errorf("Unexpected subnets: %+v", stats.Subnets)
+	}
+	if stats.Counters.Requests != 0 || stats.Started.IsZero() {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestAuditEndpoint(t *testing.T) {
+	bootp := newTestBOOTPServer(t)
+	handler := NewHandler(bootp, &Config{Token: testToken})
+
+	if _, err := bootp.ReleaseLease("00:11:22:33:44:55"); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []server.AuditEntry
+	get(t, handler, "/api/v1/audit?mac=00:11:22:33:44:55", &entries)
+	if len(entries) != 1 || entries[0].Action != server.AuditReleased || entries[0].IP != "192.168.1.10" {
+		t.Errorf("Unexpected audit entries: %+v", entries)
+	}
+
+	entries = nil
+	get(t, handler, "/api/v1/audit?action=nak", &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected no NAK entries, got %+v", entries)
+	}
+
+	for _, path := range []string{"/api/v1/audit?since=yesterday", "/api/v1/audit?limit=0"} {
+		if recorder := get(t, handler, path, nil); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, recorder.Code)
+		}
+	}
+}
+
+func TestReleaseEndpoint(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken})
+
+	release := func(method, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set("Authorization", "Bearer "+testToken)
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	recorder := release(http.MethodDelete, "/api/v1/leases/00:11:22:33:44:55")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	var lease server.Lease
+	if err := json.Unmarshal(recorder.Body.Bytes(), &lease); err != nil || lease.IP != "192.168.1.10" {
+		t.Errorf("Unexpected released lease %+v: %v", lease, err)
+	}
+
+	if recorder := release(http.MethodDelete, "/api/v1/leases/192.168.1.200"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown lease, got %d", recorder.Code)
+	}
+	if recorder := release(http.MethodGet, "/api/v1/leases/192.168.1.10"); recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", recorder.Code)
+	}
+}
+
+func TestLeasesEndpointFilters(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken})
+
+	var leases []server.Lease
+	get(t, handler, "/api/v1/leases", &leases)
+	if len(leases) != 1 || leases[0].State != server.LeaseStateReserved {
+		t.Fatalf("Unexpected leases: %+v", leases)
+	}
+
+	leases = nil
+	get(t, handler, "/api/v1/leases?state=active", &leases)
+	if len(leases) != 0 {
+		t.Errorf("Expected no active leases, got %+v", leases)
+	}
+
+	// Пустой результат кодируется как [], а не null
+	recorder := get(t, handler, "/api/v1/leases?subnet=10.0.0.0", nil)
+	if body := strings.TrimSpace(recorder.Body.String()); body != "[]" {
+		t.Errorf("Expected empty array, got %s", body)
+	}
+}
+
+func TestReservationsAndRequestsEndpoints(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken})
+
+	var reservations []server.Reservation
+	get(t, handler, "/api/v1/reservations", &reservations)
+	if len(reservations) != 1 || reservations[0].Name != "client1" {
+		t.Errorf("Unexpected reservations: %+v", reservations)
+	}
+
+	var events []server.BootEvent
+	if recorder := get(t, handler, "/api/v1/requests?limit=10", &events); recorder.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", recorder.Code)
+	}
+	if recorder := get(t, handler, "/api/v1/requests?limit=abc", nil); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid limit, got %d", recorder.Code)
+	}
+
+	var conflicts []server.Conflict
+	if recorder := get(t, handler, "/api/v1/conflicts", &conflicts); recorder.Code != http.StatusOK || conflicts == nil {
+		t.Errorf("Expected empty conflict list, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/leases", nil)
+	request.Header.Set("Authorization", "Bearer "+testToken)
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", recorder.Code)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	bootp := newTestBOOTPServer(t)
+
+	if recorder := get(t, NewHandler(bootp, &Config{Token: testToken}), "/", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected dashboard to be disabled by default, got %d", recorder.Code)
+	}
+
+	handler := NewHandler(bootp, &Config{Token: testToken, Dashboard: true})
+	recorder := get(t, handler, "/", nil)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "api/v1/subnets") {
+		t.Errorf("Expected dashboard page, got %d", recorder.Code)
+	}
+	if recorder := get(t, handler, "/other", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown path, got %d", recorder.Code)
+	}
+}
+
+func TestTokenAuthorization(t *testing.T) {
+	handler := NewHandler(newTestBOOTPServer(t), &Config{Token: testToken, Dashboard: true})
+
+	request := func(path, header string) int {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		handler.ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	if code := request("/api/v1/leases", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", code)
+	}
+	if code := request("/api/v1/leases?token="+testToken, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with token parameter, got %d", code)
+	}
+	if code := request("/api/v1/leases", testToken); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with token without Bearer prefix, got %d", code)
+	}
+	if code := request("/api/v1/leases", "Bearer wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with wrong token, got %d", code)
+	}
+	if code := request("/api/v1/leases", "Bearer "+testToken); code != http.StatusOK {
+		t.Errorf("Expected 200 with bearer token, got %d", code)
+	}
+
+	// Страница веб-интерфейса не содержит данных и отдается без токена
+	if code := request("/", ""); code != http.StatusOK {
+		t.Errorf("Expected 200 for dashboard page, got %d", code)
+	}
+
+	// Без настроенного токена доступ к API запрещен
+	handler = NewHandler(newTestBOOTPServer(t), &Config{})
+	if code := request("/api/v1/leases", "Bearer "); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without configured token, got %d", code)
+	}
+}
+
+func TestConfigFromOptions(t *testing.T) {
+	if cfg, err := ConfigFromOptions(map[string]string{}); cfg != nil || err != nil {
+		t.Errorf("Expected API to be disabled without management-listen, got %+v, %v", cfg, err)
+	}
+
+	invalid := []map[string]string{
+		{"management-listen": ":8067", "management-insecure": ""},
+		{"management-listen": ":8067", "management-token": "secret", "management-tls-cert": "cert.pem"},
+		{"management-listen": ":8067", "management-token": "secret"},
+	}
+	for _, options := range invalid {
+		if _, err := ConfigFromOptions(options); err == nil {
+			t.Errorf("Expected error for options %v", options)
+		}
+	}
+
+	cfg, err := ConfigFromOptions(map[string]string{
+		"management-listen":    "\"127.0.0.1:8067\"",
+		"management-token":     "\"secret\"",
+		"management-insecure":  "",
+		"management-dashboard": "",
+	})
+	if err != nil {
+		t.Fatalf("ConfigFromOptions failed: %v", err)
+	}
+	if cfg.Listen != "127.0.0.1:8067" || cfg.Token != "secret" || !cfg.Insecure || !cfg.Dashboard {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+}
@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Config параметры HTTP API управления
+type Config struct {
+	Listen    string // Адрес прослушивания
+	CertFile  string // Сертификат TLS (пусто - без TLS)
+	KeyFile   string // Закрытый ключ TLS
+	Token     string // Токен доступа
+	Insecure  bool   // Разрешить работу без TLS
+	Dashboard bool   // Отдавать веб-интерфейс по адресу /
+}
+
+func init() {
+	config.RegisterGlobalOptions(
+		"management-listen", "management-tls-cert", "management-tls-key",
+		"management-token", "management-token-file", "management-insecure", "management-dashboard",
+	)
+}
+
+// ConfigFromOptions читает параметры API из глобальных опций конфигурации.
+// Возвращает nil, если опция management-listen не задана.
+func ConfigFromOptions(options map[string]string) (*Config, error) {
+	listen := strings.Trim(options["management-listen"], "\"")
+	if listen == "" {
+		return nil, nil
+	}
+
+	cfg := &Config{
+		Listen:   listen,
+		CertFile: strings.Trim(options["management-tls-cert"], "\""),
+		KeyFile:  strings.Trim(options["management-tls-key"], "\""),
+		Token:    strings.Trim(options["management-token"], "\""),
+	}
+	_, cfg.Dashboard = options["management-dashboard"]
+	_, cfg.Insecure = options["management-insecure"]
+
+	// Токен удобнее хранить в отдельном файле с ограниченными правами
+	if path := strings.Trim(options["management-token-file"], "\""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("management-token-file: %v", err)
+		}
+		cfg.Token = strings.TrimSpace(string(data))
+	}
+
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("management-listen requires management-token or management-token-file")
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("management-tls-cert and management-tls-key must be set together")
+	}
+	if cfg.CertFile == "" && !cfg.Insecure {
+		return nil, fmt.Errorf("management-listen requires management-tls-cert and management-tls-key (or management-insecure)")
+	}
+
+	return cfg, nil
+}
+
+// Server HTTP сервер API управления
+type Server struct {
+	config   *Config
+	http     *http.Server
+	listener net.Listener
+}
+
+// NewServer создает сервер API для BOOTP сервера
+func NewServer(bootp *server.BOOTPServer, cfg *Config) *Server {
+	return &Server{
+		config: cfg,
+		http: &http.Server{
+			Handler:           NewHandler(bootp, cfg),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Start начинает прием соединений
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.config.Listen)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	if s.config.CertFile == "" {
+		logrus.Warnf("Management API on %s is running without TLS", listener.Addr())
+	}
+	logrus.Infof("Management API listening on %s", listener.Addr())
+
+	go func() {
+		var err error
+		if s.config.CertFile != "" {
+			err = s.http.ServeTLS(listener, s.config.CertFile, s.config.KeyFile)
+		} else {
+			err = s.http.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("Management API stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr возвращает адрес прослушивания
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop останавливает сервер
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.http.Shutdown(ctx)
+}
+
+// authorize проверяет токен в заголовке "Authorization: Bearer <token>".
+// Токен в адресе не принимается, чтобы он не попадал в историю браузера
+// и журналы прокси. Пустой токен запрещает доступ.
+func authorize(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		provided := strings.TrimPrefix(header, "Bearer ")
+		if token == "" || provided == header || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
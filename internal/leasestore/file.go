@@ -0,0 +1,180 @@
+package leasestore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/go-bootp/internal/server"
+)
+
+// minCompactRecords журнал короче не сжимается во время работы
+const minCompactRecords = 1024
+
+// File хранилище аренд в файле: журнал событий аренд, по событию в JSON
+// на строку. Сохранение дописывает строку в конец файла; при открытии и
+// когда событий становится вдвое больше, чем аренд, файл переписывается
+// текущими арендами. Истории назначений не ведет.
+type File struct {
+	mutex   sync.Mutex
+	path    string
+	file    *os.File
+	leases  map[string]server.Lease // Текущие аренды по IP
+	records int                     // Событий в файле
+}
+
+// OpenFile открывает файл аренд, создавая его при отсутствии. Усеченная
+// последняя строка (запись, прерванная остановкой) пропускается.
+func OpenFile(path string) (*File, error) {
+	f := &File{path: path, leases: make(map[string]server.Lease)}
+	if err := f.read(); err != nil {
+		return nil, fmt.Errorf("failed to read lease file %s: %v", path, err)
+	}
+	if err := f.compact(); err != nil {
+		return nil, fmt.Errorf("failed to write lease file %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// read восстанавливает текущие аренды по журналу
+func (f *File) read() error {
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Строка без перевода строки не дописана
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var event server.LeaseEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := f.apply(event); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+}
+
+// apply применяет событие к текущим арендам
+func (f *File) apply(event server.LeaseEvent) error {
+	switch event.Type {
+	case server.LeaseAllocated, server.LeaseRenewed:
+		f.leases[event.Lease.IP] = event.Lease
+	case server.LeaseReleased, server.LeaseExpired:
+		delete(f.leases, event.Lease.IP)
+	default:
+		return fmt.Errorf("unknown lease event %s", event.Type)
+	}
+	return nil
+}
+
+// compact переписывает файл текущими арендами через временный файл и
+// открывает его для дописывания
+func (f *File) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	now := time.Now()
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, lease := range f.sorted() {
+		if err := encoder.Encode(server.LeaseEvent{Type: server.LeaseAllocated, Time: now, Lease: lease}); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	f.records = len(f.leases)
+	return nil
+}
+
+// sorted возвращает текущие аренды, упорядоченные по IP
+func (f *File) sorted() []server.Lease {
+	leases := make([]server.Lease, 0, len(f.leases))
+	for _, lease := range f.leases {
+		leases = append(leases, lease)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].IP < leases[j].IP })
+	return leases
+}
+
+// Load возвращает текущие аренды
+func (f *File) Load() ([]server.Lease, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.sorted(), nil
+}
+
+// Save дописывает событие в файл
+func (f *File) Save(event server.LeaseEvent) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.apply(event); err != nil {
+		return err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	f.records++
+	if f.records >= minCompactRecords && f.records > 2*len(f.leases) {
+		return f.compact()
+	}
+	return nil
+}
+
+// Close закрывает файл
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,84 @@
+package leasestore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/server"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases")
+	store, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 13, 9, 0, 0, 0, time.UTC)
+	save := func(eventType server.LeaseEventType, ip, mac string) {
+		t.Helper()
+		lease := server.Lease{IP: ip, MAC: mac, Type: "dynamic", Expires: now.Add(time.Hour)}
+		if err := store.Save(server.LeaseEvent{Type: eventType, Time: now, Lease: lease}); err != nil {
+			t.Fatalf("Failed to save %s: %v", eventType, err)
+		}
+	}
+	save(server.LeaseAllocated, "192.168.1.101", "00:11:22:33:44:55")
+	save(server.LeaseAllocated, "192.168.1.100", "66:77:88:99:aa:bb")
+	save(server.LeaseRenewed, "192.168.1.101", "00:11:22:33:44:55")
+	save(server.LeaseAllocated, "192.168.1.102", "02:00:00:00:00:01")
+	save(server.LeaseReleased, "192.168.1.102", "02:00:00:00:00:01")
+	store.Close()
+
+	// Запись, прерванная остановкой, пропускается
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"type":"allocated","lease":{"ip":"192.168.1.1`)
+	file.Close()
+
+	if store, err = OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	leases, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 || leases[0].IP != "192.168.1.100" || leases[1].MAC != "00:11:22:33:44:55" || !leases[1].Expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Unexpected leases: %+v", leases)
+	}
+
+	// При открытии файл сжат до текущих аренд
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("Expected 2 lines after compaction, got %d:\n%s", lines, data)
+	}
+
+	// Во время работы файл сжимается, когда событий становится много
+	for i := 0; i < minCompactRecords; i++ {
+		save(server.LeaseRenewed, "192.168.1.100", "66:77:88:99:aa:bb")
+	}
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines >= minCompactRecords {
+		t.Errorf("Expected lease file to be compacted, got %d lines", lines)
+	}
+}
+
+func TestFileStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases")
+	if err := os.WriteFile(path, []byte("{\"type\":\"allocated\",\"lease\":{\"ip\":\"192.168.1.100\"}}\nnot json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error for line 2, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package leasestore содержит хранилища аренд сервера (см.
+// server.LeaseStore). Хранилище задается глобальной опцией lease-store в
+// виде "<тип>:<путь>".
+package leasestore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/server"
+)
+
+// Типы хранилищ
+const (
+	KindFile   = "file"   // Журнал событий аренд в файле
+	KindSQLite = "sqlite" // База SQLite с историей назначений
+)
+
+// Store открытое хранилище аренд
+type Store interface {
+	server.LeaseStore
+
+	// Close закрывает хранилище после остановки сервера
+	Close() error
+}
+
+// Config тип и путь хранилища
+type Config struct {
+	Kind string
+	Path string
+}
+
+// String возвращает хранилище в виде "<тип>:<путь>"
+func (c *Config) String() string {
+	return c.Kind + ":" + c.Path
+}
+
+func init() {
+	config.RegisterGlobalOptions("lease-store")
+}
+
+// ConfigFromOptions читает хранилище аренд из глобальных опций:
+//
+//	lease-store "sqlite:/var/lib/go-bootp/leases.db";
+//	lease-store "file:/var/lib/go-bootp/leases";
+//
+// Возвращает nil, если опция lease-store не задана.
+func ConfigFromOptions(options map[string]string) (*Config, error) {
+	value := strings.Trim(options["lease-store"], "\"")
+	if value == "" {
+		return nil, nil
+	}
+	cfg, err := ParseConfig(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lease-store: %v", err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig разбирает хранилище в виде "<тип>:<путь>"
+func ParseConfig(value string) (*Config, error) {
+	kind, path, ok := strings.Cut(value, ":")
+	if !ok || path == "" {
+		return nil, fmt.Errorf("%q must be <type>:<path>, for example sqlite:/var/lib/go-bootp/leases.db", value)
+	}
+	switch kind {
+	case KindFile, KindSQLite:
+	default:
+		return nil, fmt.Errorf("unknown lease store type %q (file, sqlite)", kind)
+	}
+	return &Config{Kind: kind, Path: path}, nil
+}
+
+// Open открывает хранилище, создавая его при отсутствии
+func Open(cfg *Config) (Store, error) {
+	switch cfg.Kind {
+	case KindFile:
+		return OpenFile(cfg.Path)
+	case KindSQLite:
+		return OpenSQLite(cfg.Path)
+	}
+	return nil, fmt.Errorf("unknown lease store type %q", cfg.Kind)
+}
@@ -0,0 +1,21 @@
+package leasestore
+
+import "testing"
+
+func TestConfigFromOptions(t *testing.T) {
+	if cfg, err := ConfigFromOptions(map[string]string{}); cfg != nil || err != nil {
+		t.Errorf("Expected no lease store, got %v (%v)", cfg, err)
+	}
+	cfg, err := ConfigFromOptions(map[string]string{"lease-store": `"sqlite:/var/lib/go-bootp/leases.db"`})
+	if err != nil || cfg.Kind != KindSQLite || cfg.Path != "/var/lib/go-bootp/leases.db" {
+		t.Errorf("Unexpected lease store %v (%v)", cfg, err)
+	}
+	if cfg, err := ConfigFromOptions(map[string]string{"lease-store": `"file:leases"`}); err != nil || cfg.Kind != KindFile || cfg.String() != "file:leases" {
+		t.Errorf("Unexpected lease store %v (%v)", cfg, err)
+	}
+	for _, value := range []string{`"/var/lib/leases.db"`, `"sqlite:"`, `"mysql:leases"`} {
+		if _, err := ConfigFromOptions(map[string]string{"lease-store": value}); err == nil {
+			t.Errorf("Expected error for %s", value)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package leasestore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/go-bootp/internal/server"
+	_ "modernc.org/sqlite"
+)
+
+// Причины окончания назначения в истории, кроме событий released и
+// expired
+const endReassigned = "reassigned" // Адрес выдан другому клиенту
+
+// sqliteSchema таблица leases содержит текущие аренды (аренда целиком в
+// JSON в столбце lease), lease_history - назначения адресов. Время
+// хранится в секундах Unix, NULL в expires - бессрочная аренда, в ends -
+// действующее назначение.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS leases (
+	ip        TEXT PRIMARY KEY,
+	mac       TEXT NOT NULL,
+	client_id TEXT NOT NULL DEFAULT '',
+	lease     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS lease_history (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	ip         TEXT NOT NULL,
+	mac        TEXT NOT NULL,
+	client_id  TEXT NOT NULL DEFAULT '',
+	hostname   TEXT NOT NULL DEFAULT '',
+	type       TEXT NOT NULL,
+	starts     INTEGER NOT NULL,
+	expires    INTEGER,
+	ends       INTEGER,
+	end_reason TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS lease_history_ip ON lease_history (ip, starts);
+CREATE INDEX IF NOT EXISTS lease_history_mac ON lease_history (mac, starts);
+`
+
+// SQLite хранилище аренд в базе SQLite. Кроме текущих аренд хранит
+// историю назначений: кому и когда был выдан адрес (см. History). История
+// не очищается автоматически, по записи на каждую выдачу адреса.
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite открывает базу аренд, создавая файл и таблицы при
+// отсутствии
+func OpenSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Save вызывается под блокировкой сервера, одно соединение
+	// упорядочивает записи и запросы истории
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+		"PRAGMA busy_timeout = 5000",
+		sqliteSchema,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open lease database %s: %v", path, err)
+		}
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Close закрывает базу
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// Load возвращает текущие аренды
+func (s *SQLite) Load() ([]server.Lease, error) {
+	rows, err := s.db.Query("SELECT lease FROM leases ORDER BY ip")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []server.Lease
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var lease server.Lease
+		if err := json.Unmarshal([]byte(data), &lease); err != nil {
+			return nil, fmt.Errorf("invalid stored lease: %v", err)
+		}
+		leases = append(leases, lease)
+	}
+	return leases, rows.Err()
+}
+
+// Save обновляет текущую аренду и историю назначений
+func (s *SQLite) Save(event server.LeaseEvent) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lease := event.Lease
+	switch event.Type {
+	case server.LeaseAllocated, server.LeaseRenewed:
+		data, err := json.Marshal(lease)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO leases (ip, mac, client_id, lease) VALUES (?, ?, ?, ?)
+			ON CONFLICT (ip) DO UPDATE SET mac = excluded.mac, client_id = excluded.client_id, lease = excluded.lease`,
+			lease.IP, lease.MAC, lease.ClientID, string(data)); err != nil {
+			return err
+		}
+		if err := recordAssignment(tx, event); err != nil {
+			return err
+		}
+	case server.LeaseReleased, server.LeaseExpired:
+		if _, err := tx.Exec("DELETE FROM leases WHERE ip = ?", lease.IP); err != nil {
+			return err
+		}
+		if err := endAssignment(tx, lease.IP, event.Time, string(event.Type)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown lease event %s", event.Type)
+	}
+	return tx.Commit()
+}
+
+// recordAssignment продолжает действующее назначение адреса тому же
+// клиенту или начинает новое. Продления и повторные выдачи только
+// обновляют имя узла и срок аренды.
+func recordAssignment(tx *sql.Tx, event server.LeaseEvent) error {
+	lease := event.Lease
+	var id int64
+	var mac, clientID string
+	err := tx.QueryRow("SELECT id, mac, client_id FROM lease_history WHERE ip = ? AND ends IS NULL ORDER BY id DESC LIMIT 1",
+		lease.IP).Scan(&id, &mac, &clientID)
+	switch {
+	case err == nil && mac == lease.MAC && clientID == lease.ClientID:
+		_, err = tx.Exec("UPDATE lease_history SET hostname = COALESCE(NULLIF(?, ''), hostname), expires = ? WHERE id = ?",
+			lease.Hostname, unixTime(lease.Expires), id)
+		return err
+	case err == nil:
+		if err := endAssignment(tx, lease.IP, event.Time, endReassigned); err != nil {
+			return err
+		}
+	case err != sql.ErrNoRows:
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO lease_history (ip, mac, client_id, hostname, type, starts, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		lease.IP, lease.MAC, lease.ClientID, lease.Hostname, lease.Type, event.Time.Unix(), unixTime(lease.Expires))
+	return err
+}
+
+// endAssignment завершает действующие назначения адреса
+func endAssignment(tx *sql.Tx, ip string, at time.Time, reason string) error {
+	_, err := tx.Exec("UPDATE lease_history SET ends = ?, end_reason = ? WHERE ip = ? AND ends IS NULL",
+		at.Unix(), reason, ip)
+	return err
+}
+
+// History возвращает назначения из истории, начиная с последних.
+// Назначение действовало в момент query.At, если началось не позже него и
+// не было завершено или истекло к этому моменту.
+func (s *SQLite) History(query server.HistoryQuery) ([]server.HistoryRecord, error) {
+	var conditions []string
+	var args []interface{}
+	if query.IP != "" {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, query.IP)
+	}
+	if query.MAC != "" {
+		conditions = append(conditions, "mac = ?")
+		args = append(args, query.MAC)
+	}
+	if !query.At.IsZero() {
+		at := query.At.Unix()
+		conditions = append(conditions, "starts <= ?", "(ends IS NULL OR ends > ?)", "(expires IS NULL OR expires > ?)")
+		args = append(args, at, at, at)
+	}
+
+	statement := "SELECT ip, mac, client_id, hostname, type, starts, expires, ends, end_reason FROM lease_history"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY starts DESC, id DESC"
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []server.HistoryRecord
+	for rows.Next() {
+		var record server.HistoryRecord
+		var starts int64
+		var expires, ends sql.NullInt64
+		if err := rows.Scan(&record.IP, &record.MAC, &record.ClientID, &record.Hostname, &record.Type,
+			&starts, &expires, &ends, &record.EndReason); err != nil {
+			return nil, err
+		}
+		record.Starts = time.Unix(starts, 0)
+		if expires.Valid {
+			record.Expires = time.Unix(expires.Int64, 0)
+		}
+		if ends.Valid {
+			record.Ends = time.Unix(ends.Int64, 0)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// unixTime возвращает время в секундах Unix, нулевое время - NULL
+func unixTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
@@ -0,0 +1,106 @@
+package leasestore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/server"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases.db")
+	store, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 10, 13, 9, 0, 0, 0, time.UTC)
+	event := func(eventType server.LeaseEventType, at time.Duration, ip, mac, hostname string) {
+		t.Helper()
+		now := start.Add(at)
+		lease := server.Lease{IP: ip, MAC: mac, Hostname: hostname, Type: "dynamic", Active: true, Expires: now.Add(time.Hour)}
+		if err := store.Save(server.LeaseEvent{Type: eventType, Time: now, Lease: lease}); err != nil {
+			t.Fatalf("Failed to save %s: %v", eventType, err)
+		}
+	}
+
+	event(server.LeaseAllocated, 0, "192.168.1.57", "00:11:22:33:44:55", "printer")
+	// Повторная выдача в состоянии SELECTING и продление продолжают
+	// назначение
+	event(server.LeaseAllocated, time.Second, "192.168.1.57", "00:11:22:33:44:55", "")
+	event(server.LeaseRenewed, 30*time.Minute, "192.168.1.57", "00:11:22:33:44:55", "")
+	event(server.LeaseReleased, 80*time.Minute, "192.168.1.57", "00:11:22:33:44:55", "")
+	event(server.LeaseAllocated, 2*time.Hour, "192.168.1.57", "66:77:88:99:aa:bb", "laptop")
+	event(server.LeaseAllocated, 2*time.Hour, "192.168.1.58", "00:11:22:33:44:55", "printer")
+
+	// Текущие аренды переживают повторное открытие базы
+	store.Close()
+	if store, err = OpenSQLite(path); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	leases, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 || leases[0].MAC != "66:77:88:99:aa:bb" || leases[1].IP != "192.168.1.58" {
+		t.Fatalf("Unexpected leases: %+v", leases)
+	}
+
+	records, err := store.History(server.HistoryQuery{IP: "192.168.1.57", At: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].MAC != "00:11:22:33:44:55" || records[0].Hostname != "printer" ||
+		!records[0].Ends.Equal(start.Add(80*time.Minute)) || records[0].EndReason != "released" {
+		t.Errorf("Unexpected history at 10:00: %+v", records)
+	}
+
+	// Между освобождением и новой выдачей адрес свободен
+	if records, err = store.History(server.HistoryQuery{IP: "192.168.1.57", At: start.Add(90 * time.Minute)}); err != nil || len(records) != 0 {
+		t.Errorf("Expected no assignment at 10:30, got %+v (%v)", records, err)
+	}
+
+	// Назначение без события окончания ограничено сроком аренды
+	if records, err = store.History(server.HistoryQuery{IP: "192.168.1.57", At: start.Add(4 * time.Hour)}); err != nil || len(records) != 0 {
+		t.Errorf("Expected lease to be over after expiry, got %+v (%v)", records, err)
+	}
+
+	records, err = store.History(server.HistoryQuery{MAC: "00:11:22:33:44:55"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].IP != "192.168.1.58" || !records[0].Ends.IsZero() || records[1].IP != "192.168.1.57" {
+		t.Errorf("Unexpected history of client: %+v", records)
+	}
+
+	if records, err = store.History(server.HistoryQuery{Limit: 1}); err != nil || len(records) != 1 {
+		t.Errorf("Expected one record, got %+v (%v)", records, err)
+	}
+}
+
+func TestSQLiteStoreReassigned(t *testing.T) {
+	store, err := OpenSQLite(filepath.Join(t.TempDir(), "leases.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	now := time.Date(2026, 10, 13, 9, 0, 0, 0, time.UTC)
+	for i, mac := range []string{"00:11:22:33:44:55", "66:77:88:99:aa:bb"} {
+		lease := server.Lease{IP: "192.168.1.57", MAC: mac, Type: "dynamic"}
+		if err := store.Save(server.LeaseEvent{Type: server.LeaseAllocated, Time: now.Add(time.Duration(i) * time.Minute), Lease: lease}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := store.History(server.HistoryQuery{IP: "192.168.1.57"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[0].Ends.IsZero() || !records[0].Expires.IsZero() || records[1].EndReason != "reassigned" {
+		t.Errorf("Unexpected history: %+v", records)
+	}
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// journalSocket сокет нативного протокола systemd-journald
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalHook передает записи журнала в systemd-journald по нативному
+// протоколу, сохраняя поля logrus как поля журнала
+type journalHook struct {
+	conn     net.Conn
+	facility int
+}
+
+func newJournalHook(socket string, facility int) (*journalHook, error) {
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, fmt.Errorf("journald: %v", err)
+	}
+	return &journalHook{conn: conn, facility: facility}, nil
+}
+
+// Levels возвращает уровни, передаваемые в journald (все)
+func (h *journalHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire отправляет запись одной датаграммой
+func (h *journalHook) Fire(entry *logrus.Entry) error {
+	_, err := h.conn.Write(encodeJournalEntry(entry, h.facility))
+	return err
+}
+
+// Close закрывает соединение с journald
+func (h *journalHook) Close() error {
+	return h.conn.Close()
+}
+
+// encodeJournalEntry кодирует запись в формате нативного протокола journald:
+// строки FIELD=value, а значения с переводом строки - в виде имени поля,
+// 64-битной длины (little endian) и значения
+func encodeJournalEntry(entry *logrus.Entry, facility int) []byte {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", entry.Message)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(severity(entry.Level)))
+	writeJournalField(&b, "SYSLOG_FACILITY", strconv.Itoa(facility))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", identifier)
+	for key, value := range entry.Data {
+		if name := journalFieldName(key); name != "" {
+			writeJournalField(&b, name, fmt.Sprint(value))
+		}
+	}
+	return b.Bytes()
+}
+
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalFieldName приводит имя поля logrus к допустимому в journald:
+// заглавные латинские буквы, цифры и подчеркивание, не с подчеркивания
+// (такие поля зарезервированы) и не с цифры
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
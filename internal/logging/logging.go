@@ -0,0 +1,180 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+)
+
+// Назначения журнала
+const (
+	OutputStderr   = "stderr"
+	OutputStdout   = "stdout"
+	OutputSyslog   = "syslog"
+	OutputJournald = "journald"
+)
+
+// Форматы журнала для stdout и stderr
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// identifier имя программы в syslog и journald
+const identifier = "go-bootp"
+
+// facilities коды syslog facility по именам, как в ISC dhcpd
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Config параметры журналирования
+type Config struct {
+	Outputs  []string // Назначения: stderr, stdout, syslog, journald
+	Format   string   // Формат для stdout и stderr: text или json
+	Facility int      // Код syslog facility
+}
+
+func init() {
+	config.RegisterGlobalOptions("log-output", "log-format", "log-facility")
+}
+
+// ConfigFromOptions читает параметры журналирования из глобальных опций
+// log-output, log-format и log-facility. По умолчанию журнал выводится
+// в stderr в текстовом формате, facility - daemon.
+func ConfigFromOptions(options map[string]string) (*Config, error) {
+	cfg := &Config{Outputs: []string{OutputStderr}, Format: FormatText, Facility: facilities["daemon"]}
+
+	if value, ok := options["log-output"]; ok {
+		cfg.Outputs = nil
+		for _, output := range strings.Split(strings.Trim(value, "\""), ",") {
+			output = strings.ToLower(strings.TrimSpace(output))
+			switch output {
+			case "":
+				continue
+			case OutputStderr, OutputStdout, OutputSyslog, OutputJournald:
+				cfg.Outputs = append(cfg.Outputs, output)
+			default:
+				return nil, fmt.Errorf("invalid log-output: %s", output)
+			}
+		}
+		if len(cfg.Outputs) == 0 {
+			return nil, fmt.Errorf("log-output must not be empty")
+		}
+	}
+
+	if value, ok := options["log-format"]; ok {
+		switch format := strings.ToLower(strings.Trim(value, "\"")); format {
+		case FormatText, FormatJSON:
+			cfg.Format = format
+		default:
+			return nil, fmt.Errorf("invalid log-format: %s", value)
+		}
+	}
+
+	if value, ok := options["log-facility"]; ok {
+		facility, exists := facilities[strings.ToLower(strings.Trim(value, "\""))]
+		if !exists {
+			return nil, fmt.Errorf("invalid log-facility: %s", value)
+		}
+		cfg.Facility = facility
+	}
+
+	return cfg, nil
+}
+
+// Setup настраивает вывод журнала logger. Возвращенную функцию нужно
+// вызвать при завершении, чтобы закрыть соединения с syslog и journald.
+func Setup(logger *logrus.Logger, cfg *Config) (func(), error) {
+	var writers []io.Writer
+	var closers []io.Closer
+	closeAll := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+
+	for _, output := range cfg.Outputs {
+		switch output {
+		case OutputStderr:
+			writers = append(writers, os.Stderr)
+		case OutputStdout:
+			writers = append(writers, os.Stdout)
+		case OutputSyslog:
+			hook, err := newSyslogHook(cfg.Facility)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			logger.AddHook(hook)
+			closers = append(closers, hook)
+		case OutputJournald:
+			hook, err := newJournalHook(journalSocket, cfg.Facility)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			logger.AddHook(hook)
+			closers = append(closers, hook)
+		}
+	}
+
+	switch len(writers) {
+	case 0:
+		logger.SetOutput(io.Discard)
+	case 1:
+		logger.SetOutput(writers[0])
+	default:
+		logger.SetOutput(io.MultiWriter(writers...))
+	}
+	if cfg.Format == FormatJSON {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+
+	return closeAll, nil
+}
+
+// formatMessage возвращает сообщение с полями записи в виде key=value.
+// Время и уровень передаются syslog и journald отдельно.
+func formatMessage(entry *logrus.Entry) string {
+	if len(entry.Data) == 0 {
+		return entry.Message
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(entry.Message)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, entry.Data[key])
+	}
+	return b.String()
+}
+
+// severity возвращает уровень syslog для уровня logrus
+func severity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2 // crit
+	case logrus.ErrorLevel:
+		return 3 // err
+	case logrus.WarnLevel:
+		return 4 // warning
+	case logrus.InfoLevel:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConfigFromOptions(t *testing.T) {
+	cfg, err := ConfigFromOptions(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Outputs) != 1 || cfg.Outputs[0] != OutputStderr || cfg.Format != FormatText || cfg.Facility != 3 {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+
+	cfg, err = ConfigFromOptions(map[string]string{
+		"log-output":   "\"stdout, syslog, journald\"",
+		"log-format":   "json",
+		"log-facility": "local7",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cfg.Outputs, ",") != "stdout,syslog,journald" || cfg.Format != FormatJSON || cfg.Facility != 23 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+
+	for _, options := range []map[string]string{
+		{"log-output": "file"},
+		{"log-output": "\"\""},
+		{"log-format": "xml"},
+		{"log-facility": "local8"},
+	} {
+		if _, err := ConfigFromOptions(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	entry := &logrus.Entry{Message: "Lease allocated", Data: logrus.Fields{"mac": "aa:bb", "ip": "10.0.0.1"}}
+	if message := formatMessage(entry); message != "Lease allocated ip=10.0.0.1 mac=aa:bb" {
+		t.Errorf("Unexpected message: %q", message)
+	}
+}
+
+func TestJournalHook(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets are not available: %v", err)
+	}
+	defer conn.Close()
+
+	hook, err := newJournalHook(socket, 23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	entry := &logrus.Entry{
+		Level:   logrus.WarnLevel,
+		Message: "first line\nsecond line",
+		Data:    logrus.Fields{"mac": "aa:bb", "_hidden": 1},
+	}
+	if err := hook.Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := make([]byte, 4096)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buffer[:n]
+
+	for _, field := range []string{"PRIORITY=4\n", "SYSLOG_FACILITY=23\n", "SYSLOG_IDENTIFIER=go-bootp\n", "MAC=aa:bb\n", "HIDDEN=1\n"} {
+		if !bytes.Contains(data, []byte(field)) {
+			t.Errorf("Expected field %q in %q", field, data)
+		}
+	}
+
+	// Многострочное значение передается с длиной
+	var expected bytes.Buffer
+	expected.WriteString("MESSAGE\n")
+	binary.Write(&expected, binary.LittleEndian, uint64(len(entry.Message)))
+	expected.WriteString(entry.Message + "\n")
+	if !bytes.HasPrefix(data, expected.Bytes()) {
+		t.Errorf("Unexpected MESSAGE encoding: %q", data)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"mac":       "MAC",
+		"client-id": "CLIENT_ID",
+		"_private":  "PRIVATE",
+		"9lives":    "LIVES",
+		"__":        "",
+		"subnet.id": "SUBNET_ID",
+	}
+	for key, expected := range tests {
+		if name := journalFieldName(key); name != expected {
+			t.Errorf("%q: expected %q, got %q", key, expected, name)
+		}
+	}
+}
+
+func TestSetupOutputs(t *testing.T) {
+	logger := logrus.New()
+	closeLog, err := Setup(logger, &Config{Outputs: []string{OutputStdout}, Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeLog()
+
+	if _, ok := logger.Formatter.(*logrus.JSONFormatter); !ok {
+		t.Errorf("Expected JSON formatter, got %T", logger.Formatter)
+	}
+
+	if _, err := newJournalHook(filepath.Join(t.TempDir(), "missing"), 3); err == nil {
+		t.Error("Expected error without journald socket")
+	}
+}
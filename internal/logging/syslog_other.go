@@ -0,0 +1,20 @@
+//go:build !unix
+
+package logging
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogHook не поддерживается на этой платформе
+type syslogHook struct{}
+
+func newSyslogHook(facility int) (*syslogHook, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
+
+func (h *syslogHook) Levels() []logrus.Level   { return nil }
+func (h *syslogHook) Fire(*logrus.Entry) error { return nil }
+func (h *syslogHook) Close() error             { return nil }
@@ -0,0 +1,55 @@
+//go:build unix
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogHook передает записи журнала локальному syslog
+type syslogHook struct {
+	writer *syslog.Writer
+}
+
+func newSyslogHook(facility int) (*syslogHook, error) {
+	return dialSyslogHook("", "", facility)
+}
+
+// dialSyslogHook подключается к syslog по адресу (пустой адрес - локальный сокет)
+func dialSyslogHook(network, addr string, facility int) (*syslogHook, error) {
+	writer, err := syslog.Dial(network, addr, syslog.Priority(facility<<3)|syslog.LOG_INFO, identifier)
+	if err != nil {
+		return nil, fmt.Errorf("syslog: %v", err)
+	}
+	return &syslogHook{writer: writer}, nil
+}
+
+// Levels возвращает уровни, передаваемые в syslog (все)
+func (h *syslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire отправляет запись с уровнем syslog, соответствующим уровню logrus
+func (h *syslogHook) Fire(entry *logrus.Entry) error {
+	message := formatMessage(entry)
+	switch severity(entry.Level) {
+	case 2:
+		return h.writer.Crit(message)
+	case 3:
+		return h.writer.Err(message)
+	case 4:
+		return h.writer.Warning(message)
+	case 6:
+		return h.writer.Info(message)
+	default:
+		return h.writer.Debug(message)
+	}
+}
+
+// Close закрывает соединение с syslog
+func (h *syslogHook) Close() error {
+	return h.writer.Close()
+}
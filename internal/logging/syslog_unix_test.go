@@ -0,0 +1,41 @@
+//go:build unix
+
+package logging
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSyslogHook(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets are not available: %v", err)
+	}
+	defer conn.Close()
+
+	hook, err := dialSyslogHook("unixgram", socket, 23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hook.Close()
+
+	if err := hook.Fire(&logrus.Entry{Level: logrus.ErrorLevel, Message: "Reload failed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local7 (23) * 8 + err (3)
+	if message := string(buffer[:n]); !strings.HasPrefix(message, "<187>") || !strings.Contains(message, "go-bootp") || !strings.Contains(message, "Reload failed") {
+		t.Errorf("Unexpected syslog message: %q", message)
+	}
+}
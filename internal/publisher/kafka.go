@@ -0,0 +1,383 @@
+package publisher
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Запросы протокола Kafka. Используются версии без сжатых полей
+// (flexible versions), которые принимают и брокеры Kafka 4.
+const (
+	kafkaProduce         = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadata        = 3
+	kafkaMetadataVersion = 1
+)
+
+const (
+	defaultKafkaPort = "9092"
+	maxKafkaResponse = 16 << 20
+)
+
+// Коды ошибок Kafka, с которыми раздел остается доступным
+const (
+	kafkaNoError            = 0
+	kafkaReplicaUnavailable = 9 // Одна из реплик недоступна, ведущий брокер работает
+)
+
+// kafkaErrors имена кодов ошибок Kafka, которые встречаются при отправке
+var kafkaErrors = map[int16]string{
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	9:  "REPLICA_NOT_AVAILABLE",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+// kafkaError описывает код ошибки Kafka
+func kafkaError(code int16) string {
+	if name, ok := kafkaErrors[code]; ok {
+		return fmt.Sprintf("%s (error code %d)", name, code)
+	}
+	return fmt.Sprintf("error code %d", code)
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaPartition раздел темы, его ведущий брокер и код ошибки из
+// метаданных
+type kafkaPartition struct {
+	id     int32
+	leader string // Пусто, если ведущий брокер неизвестен
+	code   int16
+}
+
+// kafkaConn производитель Kafka: сообщение отправляется ведущему брокеру
+// раздела с acks=-1 (подтверждение всеми синхронными репликами).
+// Реализованы только Metadata v1 и Produce v3 по открытому TCP: TLS, SASL,
+// сжатие и идемпотентная отправка не поддерживаются, брокер должен быть
+// доступен в доверенной сети (listener PLAINTEXT).
+type kafkaConn struct {
+	bootstrap   []string
+	brokers     map[string]net.Conn // Соединения по адресам брокеров
+	partitions  map[string][]kafkaPartition
+	correlation int32
+}
+
+// dialKafka подключается к первому доступному брокеру из списка
+func dialKafka(bootstrap []string) (*kafkaConn, error) {
+	c := &kafkaConn{brokers: make(map[string]net.Conn), partitions: make(map[string][]kafkaPartition)}
+	for _, address := range bootstrap {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, defaultKafkaPort)
+		}
+		c.bootstrap = append(c.bootstrap, address)
+	}
+
+	var lastErr error
+	for _, address := range c.bootstrap {
+		if _, lastErr = c.broker(address); lastErr == nil {
+			return c, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// broker возвращает соединение с брокером address
+func (c *kafkaConn) broker(address string) (net.Conn, error) {
+	if conn, ok := c.brokers[address]; ok {
+		return conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", address, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c.brokers[address] = conn
+	return conn, nil
+}
+
+// kafkaWriter собирает запрос в порядке байт протокола (big endian)
+type kafkaWriter struct {
+	data []byte
+}
+
+func (w *kafkaWriter) int8(v int8)   { w.data = append(w.data, byte(v)) }
+func (w *kafkaWriter) int16(v int16) { w.data = binary.BigEndian.AppendUint16(w.data, uint16(v)) }
+func (w *kafkaWriter) int32(v int32) { w.data = binary.BigEndian.AppendUint32(w.data, uint32(v)) }
+func (w *kafkaWriter) int64(v int64) { w.data = binary.BigEndian.AppendUint64(w.data, uint64(v)) }
+
+func (w *kafkaWriter) string(v string) {
+	w.int16(int16(len(v)))
+	w.data = append(w.data, v...)
+}
+
+func (w *kafkaWriter) bytes(v []byte) {
+	w.int32(int32(len(v)))
+	w.data = append(w.data, v...)
+}
+
+// varint записывает число в формате zigzag varint записей Kafka
+func (w *kafkaWriter) varint(v int64) {
+	w.data = binary.AppendVarint(w.data, v)
+}
+
+// kafkaReader разбирает ответ. Первая ошибка сохраняется.
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil || n < 0 || len(r.data) < n {
+		r.err = errors.New("truncated Kafka response")
+		return make([]byte, 8)
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *kafkaReader) int16() int16 { return int16(binary.BigEndian.Uint16(r.take(2))) }
+func (r *kafkaReader) int32() int32 { return int32(binary.BigEndian.Uint32(r.take(4))) }
+func (r *kafkaReader) int64() int64 { return int64(binary.BigEndian.Uint64(r.take(8))) }
+
+func (r *kafkaReader) string() string {
+	n := int(r.int16())
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(n))
+}
+
+// array читает длину массива; отрицательная длина (null) - пустой массив
+func (r *kafkaReader) array() int {
+	n := int(r.int32())
+	if n < 0 || n > len(r.data) {
+		if n > len(r.data) {
+			r.err = errors.New("truncated Kafka response")
+		}
+		return 0
+	}
+	return n
+}
+
+// roundTrip отправляет запрос брокеру и возвращает тело ответа
+func (c *kafkaConn) roundTrip(conn net.Conn, apiKey, version int16, body []byte) (*kafkaReader, error) {
+	c.correlation++
+	request := &kafkaWriter{}
+	request.int32(0) // Длина, заполняется ниже
+	request.int16(apiKey)
+	request.int16(version)
+	request.int32(c.correlation)
+	request.string("go-bootp")
+	request.data = append(request.data, body...)
+	binary.BigEndian.PutUint32(request.data, uint32(len(request.data)-4))
+
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+	if _, err := conn.Write(request.data); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 || length > maxKafkaResponse {
+		return nil, fmt.Errorf("invalid Kafka response length %d", length)
+	}
+	if correlation := int32(binary.BigEndian.Uint32(header[4:])); correlation != c.correlation {
+		return nil, fmt.Errorf("unexpected Kafka correlation id %d", correlation)
+	}
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	return &kafkaReader{data: data}, nil
+}
+
+// refreshMetadata запрашивает разделы темы и их ведущих брокеров
+func (c *kafkaConn) refreshMetadata(topic string) error {
+	var conn net.Conn
+	var err error
+	for _, address := range c.bootstrap {
+		if conn, err = c.broker(address); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	body := &kafkaWriter{}
+	body.int32(1)
+	body.string(topic)
+	r, err := c.roundTrip(conn, kafkaMetadata, kafkaMetadataVersion, body.data)
+	if err != nil {
+		return err
+	}
+
+	brokers := make(map[int32]string)
+	for i, n := 0, r.array(); i < n; i++ {
+		id, host, port := r.int32(), r.string(), r.int32()
+		r.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller_id
+
+	var partitions []kafkaPartition
+	for i, n := 0, r.array(); i < n; i++ {
+		code, name := r.int16(), r.string()
+		r.take(1) // is_internal
+		for j, m := 0, r.array(); j < m; j++ {
+			partitionCode, id, leader := r.int16(), r.int32(), r.int32()
+			for k, replicas := 0, r.array(); k < replicas; k++ {
+				r.int32()
+			}
+			for k, isr := 0, r.array(); k < isr; k++ {
+				r.int32()
+			}
+			// Недоступные разделы сохраняются: ключ всегда попадает в
+			// свой раздел, а не перераспределяется по оставшимся
+			if name == topic {
+				partitions = append(partitions, kafkaPartition{id: id, leader: brokers[leader], code: partitionCode})
+			}
+		}
+		if name == topic && code != kafkaNoError {
+			return fmt.Errorf("kafka topic %s: %s", topic, kafkaError(code))
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("kafka topic %s has no partitions", topic)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].id < partitions[j].id })
+	c.partitions[topic] = partitions
+	return nil
+}
+
+func (c *kafkaConn) publish(topic, key string, value []byte) error {
+	if _, ok := c.partitions[topic]; !ok {
+		if err := c.refreshMetadata(topic); err != nil {
+			return err
+		}
+	}
+	partitions := c.partitions[topic]
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	partition := partitions[hash.Sum32()%uint32(len(partitions))]
+	if partition.code != kafkaNoError && partition.code != kafkaReplicaUnavailable {
+		delete(c.partitions, topic)
+		return fmt.Errorf("kafka topic %s partition %d: %s", topic, partition.id, kafkaError(partition.code))
+	}
+	if partition.leader == "" {
+		delete(c.partitions, topic)
+		return fmt.Errorf("kafka topic %s partition %d has no leader", topic, partition.id)
+	}
+
+	conn, err := c.broker(partition.leader)
+	if err != nil {
+		return err
+	}
+
+	// Produce v3: без транзакций, acks=-1
+	body := &kafkaWriter{}
+	body.int16(-1)
+	body.int16(-1)
+	body.int32(int32(requestTimeout / time.Millisecond))
+	body.int32(1)
+	body.string(topic)
+	body.int32(1)
+	body.int32(partition.id)
+	body.bytes(recordBatch(time.Now(), []byte(key), value))
+	r, err := c.roundTrip(conn, kafkaProduce, kafkaProduceVersion, body.data)
+	if err != nil {
+		return err
+	}
+
+	// Ответ должен подтвердить запись в выбранный раздел
+	acknowledged := false
+	for i, n := 0, r.array(); i < n; i++ {
+		name := r.string()
+		for j, m := 0, r.array(); j < m; j++ {
+			id, code := r.int32(), r.int16()
+			r.int64() // base_offset
+			r.int64() // log_append_time
+			if r.err != nil || name != topic || id != partition.id {
+				continue
+			}
+			if code != kafkaNoError {
+				// Ведущий брокер мог смениться, разделы запрашиваются заново
+				delete(c.partitions, topic)
+				return fmt.Errorf("kafka topic %s partition %d: %s", topic, partition.id, kafkaError(code))
+			}
+			acknowledged = true
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if !acknowledged {
+		return fmt.Errorf("kafka topic %s partition %d: no result in produce response", topic, partition.id)
+	}
+	return nil
+}
+
+// recordBatch собирает пакет записей формата v2 из одной записи
+func recordBatch(now time.Time, key, value []byte) []byte {
+	record := &kafkaWriter{}
+	record.int8(0)   // attributes
+	record.varint(0) // timestamp_delta
+	record.varint(0) // offset_delta
+	record.varint(int64(len(key)))
+	record.data = append(record.data, key...)
+	record.varint(int64(len(value)))
+	record.data = append(record.data, value...)
+	record.varint(0) // headers
+
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	batch := &kafkaWriter{}
+	batch.int64(0)  // base_offset
+	batch.int32(0)  // batch_length, заполняется ниже
+	batch.int32(-1) // partition_leader_epoch
+	batch.int8(2)   // magic
+	batch.int32(0)  // crc, заполняется ниже
+	crcStart := len(batch.data)
+	batch.int16(0) // attributes: без сжатия и транзакций
+	batch.int32(0) // last_offset_delta
+	batch.int64(timestamp)
+	batch.int64(timestamp)
+	batch.int64(-1) // producer_id
+	batch.int16(-1) // producer_epoch
+	batch.int32(-1) // base_sequence
+	batch.int32(1)
+	batch.varint(int64(len(record.data)))
+	batch.data = append(batch.data, record.data...)
+
+	binary.BigEndian.PutUint32(batch.data[8:12], uint32(len(batch.data)-12))
+	binary.BigEndian.PutUint32(batch.data[crcStart-4:crcStart], crc32.Checksum(batch.data[crcStart:], castagnoli))
+	return batch.data
+}
+
+func (c *kafkaConn) Close() error {
+	for address, conn := range c.brokers {
+		conn.Close()
+		delete(c.brokers, address)
+	}
+	return nil
+}
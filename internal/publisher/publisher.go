@@ -0,0 +1,225 @@
+// Package publisher отправляет события аренд в брокер сообщений (NATS или
+// Kafka), чтобы внешние системы, например конвейер установки ОС, узнавали
+// о новых узлах без опроса API.
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/grpcapi"
+	"github.com/user/go-bootp/internal/server"
+	"google.golang.org/protobuf/proto"
+)
+
+// Параметры доставки
+const (
+	eventBuffer    = 1024 // Очередь событий, ожидающих отправки
+	requestTimeout = 10 * time.Second
+	retryInterval  = 5 * time.Second
+)
+
+// Форматы сообщений
+const (
+	FormatJSON     = "json"     // server.LeaseEvent в JSON
+	FormatProtobuf = "protobuf" // gobootp.v1.LeaseEvent (см. management.proto)
+)
+
+// Config параметры публикации событий
+type Config struct {
+	URL    *url.URL                       // nats://host:4222/subject или kafka://host1:9092,host2:9092/topic
+	Topic  string                         // Тема Kafka или тема NATS (к ней добавляется тип события)
+	Format string                         // FormatJSON или FormatProtobuf
+	Types  map[server.LeaseEventType]bool // Публикуемые типы событий (nil - все)
+}
+
+func init() {
+	config.RegisterGlobalOptions("event-publish-url", "event-publish-format", "event-publish-types")
+}
+
+// ConfigFromOptions читает параметры публикации из глобальных опций:
+//
+//	event-publish-url "kafka://broker1:9092,broker2:9092/dhcp-leases";
+//	event-publish-format protobuf;              # json по умолчанию
+//	event-publish-types "allocated, released";  # по умолчанию все
+//
+// Возвращает nil, если опция event-publish-url не задана.
+func ConfigFromOptions(options map[string]string) (*Config, error) {
+	value := strings.Trim(options["event-publish-url"], "\"")
+	if value == "" {
+		return nil, nil
+	}
+
+	// Список брокеров Kafka через запятую url.Parse не принимает
+	var u *url.URL
+	if rest := strings.TrimPrefix(value, "kafka://"); rest != value {
+		brokers, topic, _ := strings.Cut(rest, "/")
+		u = &url.URL{Scheme: "kafka", Host: brokers, Path: "/" + topic}
+	} else {
+		var err error
+		if u, err = url.Parse(value); err != nil {
+			return nil, fmt.Errorf("invalid event-publish-url: %v", err)
+		}
+		if u.Scheme != "nats" {
+			return nil, fmt.Errorf("event-publish-url must be a nats:// or kafka:// URL: %s", value)
+		}
+	}
+	cfg := &Config{URL: u, Topic: strings.Trim(u.Path, "/"), Format: FormatJSON}
+	if u.Host == "" || cfg.Topic == "" || strings.Contains(cfg.Topic, "/") {
+		return nil, fmt.Errorf("event-publish-url must name brokers and a topic: %s", value)
+	}
+	if u.Scheme == "nats" && strings.ContainsAny(cfg.Topic, " \t*>") {
+		return nil, fmt.Errorf("invalid NATS subject %q in event-publish-url", cfg.Topic)
+	}
+
+	if format := strings.Trim(options["event-publish-format"], "\""); format != "" {
+		if format != FormatJSON && format != FormatProtobuf {
+			return nil, fmt.Errorf("invalid event-publish-format %s, expected json or protobuf", format)
+		}
+		cfg.Format = format
+	}
+
+	for _, name := range strings.Split(strings.Trim(options["event-publish-types"], "\""), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		eventType := server.LeaseEventType(name)
+		switch eventType {
+		case server.LeaseAllocated, server.LeaseRenewed, server.LeaseReleased, server.LeaseExpired:
+		default:
+			return nil, fmt.Errorf("invalid event-publish-types entry %q", name)
+		}
+		if cfg.Types == nil {
+			cfg.Types = make(map[server.LeaseEventType]bool)
+		}
+		cfg.Types[eventType] = true
+	}
+	return cfg, nil
+}
+
+// transport соединение с брокером. publish возвращает ошибку, если брокер
+// не подтвердил прием сообщения; после ошибки соединение закрывается.
+type transport interface {
+	publish(topic, key string, value []byte) error
+	Close() error
+}
+
+// Publisher отправляет события аренд сервера в брокер. События
+// отправляются по одному в порядке возникновения; если брокер недоступен,
+// отправка повторяется, а новые события копятся в очереди, при ее
+// переполнении теряются (с предупреждением в журнале).
+type Publisher struct {
+	config *Config
+	bootp  *server.BOOTPServer
+	dial   func() (transport, error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New создает публикатор событий BOOTP сервера
+func New(bootp *server.BOOTPServer, cfg *Config) *Publisher {
+	p := &Publisher{config: cfg, bootp: bootp}
+	p.dial = func() (transport, error) {
+		if cfg.URL.Scheme == "nats" {
+			return dialNATS(cfg.URL)
+		}
+		return dialKafka(strings.Split(cfg.URL.Host, ","))
+	}
+	return p
+}
+
+// Start подписывается на события аренд и начинает их отправку
+func (p *Publisher) Start() {
+	events, unsubscribe := p.bootp.SubscribeLeaseEvents(eventBuffer)
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		defer unsubscribe()
+		p.run(events)
+	}()
+	logrus.Infof("Publishing lease events to %s://%s/%s", p.config.URL.Scheme, p.config.URL.Host, p.config.Topic)
+}
+
+// Stop прекращает отправку. События, еще не отправленные брокеру,
+// теряются.
+func (p *Publisher) Stop() {
+	close(p.stop)
+	<-p.done
+}
+
+// run отправляет события до Stop
+func (p *Publisher) run(events <-chan server.LeaseEvent) {
+	var conn transport
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var event server.LeaseEvent
+		select {
+		case <-p.stop:
+			return
+		case event = <-events:
+		}
+		if p.config.Types != nil && !p.config.Types[event.Type] {
+			continue
+		}
+		topic, key, value, err := p.encode(event)
+		if err != nil {
+			logrus.Errorf("Failed to encode %s event for %s: %v", event.Type, event.Lease.MAC, err)
+			continue
+		}
+
+		// Отправка повторяется, пока брокер не примет событие
+		for {
+			if conn == nil {
+				conn, err = p.dial()
+			}
+			if err == nil {
+				if err = conn.publish(topic, key, value); err != nil {
+					conn.Close()
+					conn = nil
+				}
+			}
+			if err == nil {
+				break
+			}
+			logrus.Warnf("Failed to publish %s event for %s to %s: %v, retrying in %s",
+				event.Type, event.Lease.MAC, p.config.URL.Scheme, err, retryInterval)
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+	}
+}
+
+// encode возвращает тему, ключ и тело сообщения о событии. Ключ - MAC
+// адрес клиента: события одного клиента попадают в один раздел Kafka и
+// сохраняют порядок. В NATS тип события добавляется к теме
+// (dhcp.leases.allocated), чтобы подписчики могли выбирать события.
+func (p *Publisher) encode(event server.LeaseEvent) (string, string, []byte, error) {
+	topic := p.config.Topic
+	if p.config.URL.Scheme == "nats" {
+		topic += "." + string(event.Type)
+	}
+
+	var value []byte
+	var err error
+	if p.config.Format == FormatProtobuf {
+		value, err = proto.Marshal(grpcapi.LeaseEventMessage(event))
+	} else {
+		value, err = json.Marshal(event)
+	}
+	return topic, event.Lease.MAC, value, err
+}
@@ -0,0 +1,383 @@
+package publisher
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+	"github.com/user/go-bootp/internal/grpcapi/managementpb"
+	"github.com/user/go-bootp/internal/server"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConfigFromOptions(t *testing.T) {
+	if cfg, err := ConfigFromOptions(map[string]string{}); cfg != nil || err != nil {
+		t.Errorf("Expected no publisher, got %+v (%v)", cfg, err)
+	}
+
+	cfg, err := ConfigFromOptions(map[string]string{
+		"event-publish-url":    `"kafka://broker1:9092,broker2/dhcp-leases"`,
+		"event-publish-format": "protobuf",
+		"event-publish-types":  `"allocated, released"`,
+	})
+	if err != nil || cfg.URL.Host != "broker1:9092,broker2" || cfg.Topic != "dhcp-leases" || cfg.Format != FormatProtobuf {
+		t.Fatalf("Unexpected config %+v (%v)", cfg, err)
+	}
+	if len(cfg.Types) != 2 || !cfg.Types[server.LeaseAllocated] || !cfg.Types[server.LeaseReleased] {
+		t.Errorf("Unexpected event types %v", cfg.Types)
+	}
+
+	for _, options := range []map[string]string{
+		{"event-publish-url": "amqp://broker/leases"},
+		{"event-publish-url": "nats://localhost"},
+		{"event-publish-url": "nats://localhost/dhcp.*"},
+		{"event-publish-url": "kafka://broker/a/b"},
+		{"event-publish-url": "nats://localhost/dhcp", "event-publish-format": "xml"},
+		{"event-publish-url": "nats://localhost/dhcp", "event-publish-types": "allocated, offered"},
+	} {
+		if _, err := ConfigFromOptions(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	event := server.LeaseEvent{
+		Type:  server.LeaseAllocated,
+		Time:  time.Unix(1700000000, 0),
+		Lease: server.Lease{IP: "192.168.1.100", MAC: "00:11:22:33:44:55"},
+	}
+
+	cfg, _ := ConfigFromOptions(map[string]string{"event-publish-url": "nats://localhost/dhcp.leases"})
+	topic, key, value, err := (&Publisher{config: cfg}).encode(event)
+	if err != nil || topic != "dhcp.leases.allocated" || key != "00:11:22:33:44:55" {
+		t.Fatalf("Unexpected message %s %s (%v)", topic, key, err)
+	}
+	var decoded server.LeaseEvent
+	if err := json.Unmarshal(value, &decoded); err != nil || decoded.Type != server.LeaseAllocated || decoded.Lease.IP != "192.168.1.100" {
+		t.Errorf("Unexpected JSON %s (%v)", value, err)
+	}
+
+	cfg, _ = ConfigFromOptions(map[string]string{"event-publish-url": "kafka://localhost/leases", "event-publish-format": "protobuf"})
+	topic, _, value, err = (&Publisher{config: cfg}).encode(event)
+	if err != nil || topic != "leases" {
+		t.Fatalf("Unexpected message %s (%v)", topic, err)
+	}
+	var message managementpb.LeaseEvent
+	if err := proto.Unmarshal(value, &message); err != nil || message.Type != "allocated" || message.Lease.GetIp() != "192.168.1.100" {
+		t.Errorf("Unexpected protobuf %v (%v)", &message, err)
+	}
+}
+
+// testNATSServer принимает одно соединение и передает опубликованные
+// сообщения в канал
+func testNATSServer(t *testing.T, info string) (*url.URL, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO %s\r\n", info)
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				messages <- line
+			case "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(reader, payload)
+				// Сервер проверяет соединение перед подтверждением
+				io.WriteString(conn, "PING\r\n")
+				messages <- fields[1] + " " + string(payload[:size])
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+			}
+		}
+	}()
+	u, _ := url.Parse("nats://secret@" + listener.Addr().String() + "/dhcp.leases")
+	return u, messages
+}
+
+func TestNATS(t *testing.T) {
+	u, messages := testNATSServer(t, `{"server_id":"test"}`)
+	conn, err := dialNATS(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if connect := <-messages; !strings.Contains(connect, `"auth_token":"secret"`) {
+		t.Errorf("Expected auth token in %s", connect)
+	}
+
+	if err := conn.publish("dhcp.leases.allocated", "", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if message := <-messages; message != "dhcp.leases.allocated hello" {
+		t.Errorf("Unexpected message %q", message)
+	}
+
+	// TLS не поддерживается
+	u, _ = testNATSServer(t, `{"tls_required":true}`)
+	if _, err := dialNATS(u); err == nil {
+		t.Error("Expected error for TLS-only server")
+	}
+}
+
+// testKafkaCodes коды ошибок разделов, которые возвращает тестовый брокер
+type testKafkaCodes struct {
+	metadata [2]int16 // Коды разделов в ответе Metadata (у раздела с ошибкой нет ведущего)
+	produce  [2]int16 // Коды разделов в ответе Produce
+}
+
+// testKafkaBroker отвечает на Metadata и Produce для темы с двумя
+// разделами и передает записи (ключ и значение) в канал
+func testKafkaBroker(t *testing.T, topic string, codes testKafkaCodes) (string, <-chan [2]string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	host, portString, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portString)
+
+	records := make(chan [2]string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return
+			}
+			data := make([]byte, binary.BigEndian.Uint32(header))
+			if _, err := io.ReadFull(conn, data); err != nil {
+				return
+			}
+			r := &kafkaReader{data: data}
+			apiKey, version, correlation := r.int16(), r.int16(), r.int32()
+			r.string() // client_id
+
+			response := &kafkaWriter{}
+			response.int32(correlation)
+			switch {
+			case apiKey == kafkaMetadata && version == kafkaMetadataVersion:
+				response.int32(1)
+				response.int32(1)
+				response.string(host)
+				response.int32(int32(port))
+				response.int16(-1)
+				response.int32(1)
+				response.int32(1)
+				response.int16(0)
+				response.string(topic)
+				response.int8(0)
+				response.int32(2)
+				for id := int32(0); id < 2; id++ {
+					leader := int32(1)
+					if codes.metadata[id] != 0 {
+						leader = -1
+					}
+					response.int16(codes.metadata[id])
+					response.int32(id)
+					response.int32(leader)
+					response.int32(0)
+					response.int32(0)
+				}
+			case apiKey == kafkaProduce && version == kafkaProduceVersion:
+				r.int16() // transactional_id
+				if acks := r.int16(); acks != -1 {
+					t.Errorf("Unexpected acks %d", acks)
+				}
+				r.int32()
+				r.int32()
+				name := r.string()
+				r.int32()
+				partition := r.int32()
+				batch := r.take(int(r.int32()))
+				records <- decodeRecordBatch(t, batch)
+
+				response.int32(1)
+				response.string(name)
+				response.int32(1)
+				response.int32(partition)
+				response.int16(codes.produce[partition])
+				response.int64(0)
+				response.int64(-1)
+				response.int32(0) // throttle_time_ms
+			default:
+				t.Errorf("Unexpected request %d v%d", apiKey, version)
+				return
+			}
+			length := make([]byte, 4)
+			binary.BigEndian.PutUint32(length, uint32(len(response.data)))
+			conn.Write(append(length, response.data...))
+		}
+	}()
+	return listener.Addr().String(), records
+}
+
+// decodeRecordBatch проверяет CRC пакета записей и возвращает ключ и
+// значение единственной записи
+func decodeRecordBatch(t *testing.T, batch []byte) [2]string {
+	if len(batch) < 61 || batch[16] != 2 {
+		t.Errorf("Unexpected record batch %v", batch)
+		return [2]string{}
+	}
+	if length := binary.BigEndian.Uint32(batch[8:12]); int(length) != len(batch)-12 {
+		t.Errorf("Batch length %d, expected %d", length, len(batch)-12)
+	}
+	if crc := binary.BigEndian.Uint32(batch[17:21]); crc != crc32.Checksum(batch[21:], castagnoli) {
+		t.Errorf("Invalid record batch CRC %08x", crc)
+	}
+	if count := binary.BigEndian.Uint32(batch[57:61]); count != 1 {
+		t.Errorf("Expected one record, got %d", count)
+	}
+
+	record := batch[61:]
+	varint := func() int64 {
+		v, n := binary.Varint(record)
+		record = record[n:]
+		return v
+	}
+	varint()            // length
+	record = record[1:] // attributes
+	varint()            // timestamp_delta
+	varint()            // offset_delta
+	keyLength := varint()
+	key := string(record[:keyLength])
+	record = record[keyLength:]
+	valueLength := varint()
+	return [2]string{key, string(record[:valueLength])}
+}
+
+func TestKafka(t *testing.T) {
+	address, records := testKafkaBroker(t, "leases", testKafkaCodes{})
+	conn, err := dialKafka([]string{address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.publish("leases", "00:11:22:33:44:55", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if record := <-records; record != [2]string{"00:11:22:33:44:55", "hello"} {
+		t.Errorf("Unexpected record %q", record)
+	}
+	if len(conn.partitions["leases"]) != 2 {
+		t.Errorf("Unexpected partitions %+v", conn.partitions)
+	}
+
+	// Тема без разделов
+	if err := conn.publish("missing", "", nil); err == nil {
+		t.Error("Expected error for unknown topic")
+	}
+}
+
+// kafkaKey подбирает ключ сообщения, попадающий в раздел partition из двух
+func kafkaKey(partition uint32) string {
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("00:11:22:33:44:%02x", i)
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		if hash.Sum32()%2 == partition {
+			return key
+		}
+	}
+}
+
+func TestKafkaPartitionErrors(t *testing.T) {
+	// Раздел 1 без ведущего брокера, запись в раздел 0 отклоняется
+	address, records := testKafkaBroker(t, "leases", testKafkaCodes{metadata: [2]int16{0, 5}, produce: [2]int16{6, 0}})
+	conn, err := dialKafka([]string{address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Ключ раздела без ведущего не переносится в другой раздел
+	err = conn.publish("leases", kafkaKey(1), []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "partition 1: LEADER_NOT_AVAILABLE (error code 5)") {
+		t.Errorf("Expected LEADER_NOT_AVAILABLE for partition 1, got %v", err)
+	}
+	if _, cached := conn.partitions["leases"]; cached {
+		t.Error("Expected partitions to be refreshed after a partition error")
+	}
+
+	err = conn.publish("leases", kafkaKey(0), []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "partition 0: NOT_LEADER_OR_FOLLOWER (error code 6)") {
+		t.Errorf("Expected NOT_LEADER_OR_FOLLOWER for partition 0, got %v", err)
+	}
+	<-records
+	if _, cached := conn.partitions["leases"]; cached {
+		t.Error("Expected partitions to be refreshed after a produce error")
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	bootp, err := server.NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{{
+			Network: config.MustParseNetwork("192.168.1.0/24"),
+			Hosts:   []config.Host{{Name: "client1", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, messages := testNATSServer(t, `{}`)
+	cfg, err := ConfigFromOptions(map[string]string{"event-publish-url": u.String(), "event-publish-types": "released"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(bootp, cfg)
+	p.Start()
+	defer p.Stop()
+
+	bootp.ReleaseLease("192.168.1.10")
+	select {
+	case <-messages: // CONNECT
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for connection")
+	}
+	select {
+	case message := <-messages:
+		subject, payload, _ := strings.Cut(message, " ")
+		var event server.LeaseEvent
+		if err := json.Unmarshal([]byte(payload), &event); subject != "dhcp.leases.released" || err != nil || event.Lease.IP != "192.168.1.10" {
+			t.Errorf("Unexpected message %q (%v)", message, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for lease event")
+	}
+}
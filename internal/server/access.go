@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// matchMAC проверяет MAC адрес на соответствие шаблону.
+// Шаблон может быть полным адресом или префиксом с "*" на конце (00:1a:2b:*).
+func matchMAC(pattern, macAddr string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(macAddr, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == macAddr
+}
+
+// matchAny проверяет MAC адрес на соответствие любому из шаблонов
+func matchAny(patterns []string, macAddr string) bool {
+	for _, pattern := range patterns {
+		if matchMAC(pattern, macAddr) {
+			return true
+		}
+	}
+	return false
+}
+
+// bootpAllowed проверяет, обслуживает ли подсеть BOOTP клиентов. Оператор
+// allow/deny bootp подсети переопределяет глобальный, по умолчанию BOOTP
+// разрешен. Для глобальных хостов (subnet nil) действует глобальное правило.
+func (s *BOOTPServer) bootpAllowed(subnet *config.Subnet) bool {
+	rule := s.config.Access.BOOTP
+	if subnet != nil && subnet.Access.BOOTP != "" {
+		rule = subnet.Access.BOOTP
+	}
+	return rule != "deny"
+}
+
+// hasDynamicBOOTP проверяет, есть ли в конфигурации диапазоны dynamic-bootp
+func (s *BOOTPServer) hasDynamicBOOTP() bool {
+	for _, subnet := range s.runtime.Subnets {
+		for _, addressRange := range subnet.Ranges {
+			if addressRange.DynamicBOOTP {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMember проверяет, входит ли клиент в класс: по MAC адресу из
+// subclass, по префиксу match hardware prefix или по условиям match if
+// на опции запроса (request может быть nil, если запрос неизвестен)
+func isMember(class *config.Class, macAddr string, request map[uint8][]byte) bool {
+	for _, mac := range class.Hardware {
+		if mac == macAddr {
+			return true
+		}
+	}
+	if matchAny(class.Prefixes, macAddr) {
+		return true
+	}
+	return len(class.Match) > 0 && matchConditions(class.Match, request)
+}
+
+// matchConditions проверяет, что запрос удовлетворяет всем условиям
+func matchConditions(conditions []config.ClassCondition, request map[uint8][]byte) bool {
+	for _, condition := range conditions {
+		value, exists := request[condition.Code]
+		if !exists || condition.Value != nil && !bytes.Equal(value, condition.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// clientClasses возвращает классы, в которые входит клиент, в порядке
+// объявления. Вызывается с захваченным s.mutex.
+func (s *BOOTPServer) clientClasses(macAddr string, request map[uint8][]byte) []*config.Class {
+	var classes []*config.Class
+	for i := range s.config.Classes {
+		if isMember(&s.config.Classes[i], macAddr, request) {
+			classes = append(classes, &s.config.Classes[i])
+		}
+	}
+	return classes
+}
+
+// isClassMember проверяет, входит ли клиент хотя бы в один из классов.
+// Классы с match if в правилах доступа запрещены конфигурацией.
+func (s *BOOTPServer) isClassMember(names []string, macAddr string) bool {
+	for _, name := range names {
+		for i := range s.config.Classes {
+			if s.config.Classes[i].Name == name && isMember(&s.config.Classes[i], macAddr, nil) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isKnown проверяет, описан ли клиент в блоке host по MAC адресу или по
+// идентификатору клиента clientID (может быть пустым)
+func (s *BOOTPServer) isKnown(macAddr, clientID string) bool {
+	if s.knownClients[macAddr] {
+		return true
+	}
+	return clientID != "" && s.knownClients[clientKey(macAddr, clientID)]
+}
+
+// isPermitted проверяет, разрешено ли клиенту получать адрес согласно правилам.
+// Порядок проверки: явный запрет, явное разрешение, known/unknown-clients.
+// Запрет и разрешение задаются MAC адресами или членством в классах. Если
+// задан список разрешенных адресов или классов, не попавшие в него клиенты
+// запрещены. Известным считается клиент, описанный в блоке host по MAC
+// адресу или по client-id.
+func (s *BOOTPServer) isPermitted(macAddr, clientID string, rules *config.AccessRules) bool {
+	macAddr = normalizeMAC(macAddr)
+
+	if matchAny(rules.DenyMACs, macAddr) || s.isClassMember(rules.DenyClasses, macAddr) {
+		return false
+	}
+	if matchAny(rules.AllowMACs, macAddr) || s.isClassMember(rules.AllowClasses, macAddr) {
+		return true
+	}
+
+	policy := rules.UnknownClients
+	if s.isKnown(macAddr, clientID) {
+		policy = rules.KnownClients
+	}
+	switch policy {
+	case "deny":
+		return false
+	case "allow":
+		return true
+	}
+
+	return len(rules.AllowMACs) == 0 && len(rules.AllowClasses) == 0
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestMatchMAC(t *testing.T) {
+	tests := []struct {
+		pattern string
+		mac     string
+		match   bool
+	}{
+		{"00:11:22:33:44:55", "00:11:22:33:44:55", true},
+		{"00:11:22:33:44:55", "00:11:22:33:44:56", false},
+		{"00:1A:2B:*", "00:1a:2b:00:00:01", true},
+		{"00:1a:2b:*", "00:1a:2c:00:00:01", false},
+		{"*", "aa:bb:cc:dd:ee:ff", true},
+	}
+
+	for _, test := range tests {
+		if result := matchMAC(test.pattern, test.mac); result != test.match {
+			t.Errorf("matchMAC(%s, %s) = %v, expected %v", test.pattern, test.mac, result, test.match)
+		}
+	}
+}
+
+func TestGlobalDenyUnknownClients(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Hosts: []config.Host{
+					{Name: "known", Hardware: "00:11:22:33:44:55"},
+					{Name: "by-id", ClientID: "01:aa:bb:cc:dd:ee:ff"},
+				},
+			},
+		},
+		Access: config.AccessRules{UnknownClients: "deny"},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Известный клиент без fixed-address получает адрес из диапазона
+	if ip, _ := server.findClientConfig("00:11:22:33:44:55"); ip == "" {
+		t.Error("Expected known client to get an address")
+	}
+
+	// Неизвестный клиент не получает адрес
+	if ip, _ := server.findClientConfig("00:00:00:00:00:01"); ip != "" {
+		t.Errorf("Expected unknown client to be denied, got %s", ip)
+	}
+
+	// Клиент, описанный только идентификатором, известен по опции 61
+	packet := discoverPacket(2)
+	packet.Options[OptionClientIdentifier] = []byte{0x01, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	if reply := server.processPacket(packet); reply == nil {
+		t.Error("Expected client known by client-id to get an address")
+	}
+	packet = discoverPacket(3)
+	packet.Options[OptionClientIdentifier] = []byte{0x01, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x00}
+	if reply := server.processPacket(packet); reply != nil {
+		t.Error("Expected client with unknown client-id to be denied")
+	}
+}
+
+func TestGlobalDenyOUI(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Hosts: []config.Host{
+					{Name: "blocked", Hardware: "00:1a:2b:00:00:01", FixedIP: "192.168.1.10"},
+				},
+			},
+		},
+		Access: config.AccessRules{DenyMACs: []string{"00:1a:2b:*"}},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Явный запрет имеет приоритет даже над статическим назначением
+	if ip, _ := server.findClientConfig("00:1A:2B:00:00:01"); ip != "" {
+		t.Errorf("Expected denied OUI to get no address, got %s", ip)
+	}
+
+	if ip, _ := server.findClientConfig("00:1a:2c:00:00:01"); ip == "" {
+		t.Error("Expected client outside denied OUI to get an address")
+	}
+}
+
+func TestSubnetAllowList(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				// Подсеть для подготовки серверов - только разрешенные адреса
+				Network: config.MustParseNetwork("10.0.0.0/24"),
+				Ranges:  []config.Range{{Start: "10.0.0.100", End: "10.0.0.110"}},
+				Access:  config.AccessRules{AllowMACs: []string{"aa:bb:cc:*"}},
+			},
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	if ip, _ := server.findClientConfig("aa:bb:cc:00:00:01"); ip != "10.0.0.100" {
+		t.Errorf("Expected allowed client to get 10.0.0.100, got %s", ip)
+	}
+
+	// Остальные клиенты пропускают подсеть с ограничениями
+	if ip, _ := server.findClientConfig("00:00:00:00:00:01"); ip != "192.168.1.100" {
+		t.Errorf("Expected other client to get 192.168.1.100, got %s", ip)
+	}
+}
+
+func TestClassPolicies(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Classes: []config.Class{
+			{
+				Name:     "raspberry-pi",
+				Prefixes: []string{"b8:27:eb:*", "dc:a6:32:*"},
+				Options:  map[string]string{"vendor-encapsulated-options": "Raspberry Pi Boot"},
+				Boot:     config.BootParams{NextServer: "10.0.0.5", Filename: "bootcode.bin"},
+			},
+			{
+				Name:     "idrac",
+				Prefixes: []string{"f8:bc:12:*"},
+			},
+		},
+		Access: config.AccessRules{DenyClasses: []string{"idrac"}},
+		Subnets: []config.Subnet{
+			{
+				// Пул только для Raspberry Pi
+				Network: config.MustParseNetwork("10.0.0.0/24"),
+				Ranges:  []config.Range{{Start: "10.0.0.100", End: "10.0.0.110"}},
+				Access:  config.AccessRules{AllowClasses: []string{"raspberry-pi"}},
+			},
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	if ip, _ := server.findClientConfig("DC:A6:32:00:00:01"); ip != "10.0.0.100" {
+		t.Errorf("Expected class member to get 10.0.0.100, got %s", ip)
+	}
+	if ip, _ := server.findClientConfig("00:00:00:00:00:01"); ip != "192.168.1.100" {
+		t.Errorf("Expected other client to get 192.168.1.100, got %s", ip)
+	}
+	if ip, _ := server.findClientConfig("f8:bc:12:00:00:01"); ip != "" {
+		t.Errorf("Expected denied class member to get no address, got %s", ip)
+	}
+
+	// Опции и параметры загрузки класса
+	subnet := &server.config.Subnets[0]
+	options := server.clientOptions("b8:27:eb:00:00:01", nil, subnet, nil)
+	if options["vendor-encapsulated-options"] != "Raspberry Pi Boot" {
+		t.Errorf("Expected class option, got %v", options)
+	}
+	if boot := server.bootParameters("b8:27:eb:00:00:01", nil, subnet, nil, options); boot.NextServer != "10.0.0.5" || boot.Filename != "bootcode.bin" {
+		t.Errorf("Expected class boot parameters, got %+v", boot)
+	}
+	if boot := server.bootParameters("00:00:00:00:00:01", nil, subnet, nil, nil); boot.Filename != "" {
+		t.Errorf("Expected no boot parameters for other clients, got %+v", boot)
+	}
+}
+
+func TestKnownClientsPolicy(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	server.knownClients["00:11:22:33:44:55"] = true
+
+	rules := &config.AccessRules{KnownClients: "deny"}
+	if server.isPermitted("00:11:22:33:44:55", "", rules) {
+		t.Error("Expected known client to be denied")
+	}
+	if !server.isPermitted("00:00:00:00:00:01", "", rules) {
+		t.Error("Expected unknown client to be permitted")
+	}
+
+	// allow known-clients разрешает известных клиентов вне списка разрешенных
+	rules = &config.AccessRules{KnownClients: "allow", AllowMACs: []string{"aa:bb:cc:*"}}
+	if !server.isPermitted("00:11:22:33:44:55", "", rules) {
+		t.Error("Expected known client to be permitted")
+	}
+	if server.isPermitted("00:00:00:00:00:01", "", rules) {
+		t.Error("Expected unlisted unknown client to be denied")
+	}
+}
+
+func TestSubnetAccessRecheckedOnRenew(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("10.0.0.0/24"),
+				Ranges:  []config.Range{{Start: "10.0.0.100", End: "10.0.0.110"}},
+			},
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	if ip, _ := server.findClientConfig("aa:bb:cc:00:00:01"); ip != "10.0.0.100" {
+		t.Fatalf("Expected client to get 10.0.0.100, got %s", ip)
+	}
+
+	// Клиент попадает под запрет - при продлении аренда в подсети не сохраняется
+	server.config.Subnets[0].Access.DenyMACs = []string{"aa:bb:cc:*"}
+	if ip, _ := server.findClientConfig("aa:bb:cc:00:00:01"); ip != "192.168.1.100" {
+		t.Errorf("Expected denied client to move to 192.168.1.100, got %s", ip)
+	}
+	if server.isIPAllocated(ipToInt(net.ParseIP("10.0.0.100"))) {
+		t.Error("Expected lease in denied subnet to be dropped")
+	}
+}
+
+func TestBOOTPGating(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+			{
+				Network: config.MustParseNetwork("192.168.2.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.2.100", End: "192.168.2.110", DynamicBOOTP: true}},
+			},
+			{
+				Network: config.MustParseNetwork("192.168.3.0/24"),
+				Access:  config.AccessRules{BOOTP: "deny"},
+				Hosts:   []config.Host{{Name: "legacy", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.3.10"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := func(mac byte, dhcp bool) string {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, mac}},
+			Options: map[uint8][]byte{},
+		}
+		if dhcp {
+			packet.Options[OptionMessageType] = []byte{DHCPDiscover}
+		}
+		reply := server.processPacket(packet)
+		if reply == nil {
+			return ""
+		}
+		return net.IP(reply.Header.Yiaddr[:]).String()
+	}
+
+	// BOOTP клиенты ограничены диапазоном dynamic-bootp, DHCP клиенты - нет
+	if ip := request(0x01, false); ip != "192.168.2.100" {
+		t.Errorf("Expected BOOTP client in dynamic-bootp range, got %s", ip)
+	}
+	if ip := request(0x02, true); ip != "192.168.1.100" {
+		t.Errorf("Expected DHCP client in first range, got %s", ip)
+	}
+
+	// deny bootp в подсети запрещает и статическое резервирование
+	if ip := request(0x55, false); ip != "" {
+		t.Errorf("Expected BOOTP client to be denied, got %s", ip)
+	}
+	if ip := request(0x55, true); ip != "192.168.3.10" {
+		t.Errorf("Expected DHCP client to get reservation, got %s", ip)
+	}
+
+	// Глобальный deny bootp отключает BOOTP везде, где подсеть не разрешает его явно
+	cfg.Access.BOOTP = "deny"
+	if ip := request(0x03, false); ip != "" {
+		t.Errorf("Expected BOOTP to be denied globally, got %s", ip)
+	}
+	cfg.Subnets[1].Access.BOOTP = "allow"
+	if ip := request(0x03, false); ip != "192.168.2.101" {
+		t.Errorf("Expected subnet allow bootp to override global deny, got %s", ip)
+	}
+}
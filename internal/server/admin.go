@@ -0,0 +1,257 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// ErrLeaseNotFound возвращается, если назначение не найдено
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// ReleaseLease освобождает назначение по IP или MAC адресу.
+// Динамическая аренда удаляется, статическое назначение деактивируется.
+func (s *BOOTPServer) ReleaseLease(addr string) (Lease, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	allocated := s.findAllocation(addr)
+	if allocated == nil {
+		return Lease{}, ErrLeaseNotFound
+	}
+
+	s.releaseAllocation(allocated)
+	s.logger.Infof("Released %s lease %s for %s", allocated.Type, intToIP(allocated.IP), allocated.MAC)
+	return newLease(allocated, s.clock.Now()), nil
+}
+
+// findAllocation находит назначение по IP или MAC адресу (nil - не
+// найдено). Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) findAllocation(addr string) *AllocatedIP {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+		return s.allocatedIP[ipToInt(ip)]
+	}
+	mac := normalizeMAC(addr)
+	if allocated := s.allocatedMAC[mac]; allocated != nil {
+		return allocated
+	}
+	// Аренда клиента с client-id хранится по идентификатору
+	for _, candidate := range s.allocatedMAC {
+		if candidate.MAC == mac {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// releaseAllocation удаляет динамическую аренду или деактивирует
+// статическое назначение. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) releaseAllocation(allocated *AllocatedIP) {
+	if allocated.Type == StaticAllocation {
+		allocated.Active = false
+		allocated.Expires = time.Time{}
+	} else {
+		delete(s.allocatedIP, allocated.IP)
+		delete(s.allocatedMAC, allocated.key())
+		allocated.Active = false
+	}
+	s.publishLeaseEvent(LeaseReleased, allocated)
+	s.checkPoolWatermark(allocated.IP)
+}
+
+// SetDraining включает или выключает режим вывода из эксплуатации.
+// В этом режиме сервер продлевает существующие назначения, но не выдает
+// новых динамических адресов.
+func (s *BOOTPServer) SetDraining(draining bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.draining = draining
+	if draining {
+		s.logger.Infof("Draining mode enabled, new addresses will not be allocated")
+	} else {
+		s.logger.Infof("Draining mode disabled")
+	}
+}
+
+// Draining возвращает true, если сервер выводится из эксплуатации
+func (s *BOOTPServer) Draining() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.draining
+}
+
+// Reload применяет новую конфигурацию без перезапуска. Статические
+// назначения пересоздаются, динамические аренды сверяются с новой
+// конфигурацией (см. admitLease): аренды вне диапазонов сохраняются с
+// отметкой, аренды удаленных подсетей и занятые резервированиями
+// отзываются.
+// Резервирования перечитываются из reservations-file, если он задан,
+// иначе сохраняются добавленные во время работы.
+// Интерфейсы (в том числе vlan-trunk), встроенные файловые серверы и
+// захват пакетов не перенастраиваются и требуют перезапуска, как и журнал
+// аудита и lease-affinity-file.
+func (s *BOOTPServer) Reload(cfg *config.DHCPConfig) error {
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	s.mutex.Lock()
+	managed := s.reservations
+	s.mutex.Unlock()
+
+	effective := withReservations(cfg, managed)
+	if cfg.GlobalOptions != nil && reservationsFile(cfg.GlobalOptions) != "" {
+		var err error
+		if effective, managed, err = effectiveConfig(cfg); err != nil {
+			return err
+		}
+	}
+	runtime, err := config.Compile(effective)
+	if err != nil {
+		return err
+	}
+	if err := checkReservations(effective); err != nil {
+		return err
+	}
+	if err := checkLeaseTimers(effective); err != nil {
+		return err
+	}
+
+	// affinity-file применяется только при запуске, но проверяется и здесь
+	parsed, err := parseGlobalOptions(cfg.GlobalOptions, effective.Subnets)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.baseConfig = cfg
+	s.reservations = managed
+	s.hook = parsed.hook
+	s.ipam = parsed.ipam
+	s.allocator = parsed.allocator
+	s.limiter = parsed.limiter
+	s.reuse = parsed.reuse
+	s.bootpLease = runtime.BOOTPLeaseLength
+	s.maxReply = parsed.maxReply
+	s.alwaysSend = parsed.alwaysSend
+	s.minSecs = parsed.minSecs
+	s.requestTimeout = parsed.requestTimeout
+	s.randomMAC = parsed.randomMAC
+	s.relays = parsed.relays
+	s.alerts = parsed.alerts
+	s.ifaces = parsed.ifaces
+	s.learning = parsed.learning
+	s.serverNames = make(map[string]net.IP)
+	s.replies.reset(parsed.window)
+
+	// Адреса VLAN меняются сразу, транковые интерфейсы - только при
+	// следующем запуске
+	if !reflect.DeepEqual(parsed.vlans.trunks, s.vlans.trunks) {
+		s.logger.Warnf("vlan-trunk changed, restart the server to apply it")
+	}
+	s.vlans.addresses = parsed.vlans.addresses
+
+	// Буферы открытых сокетов меняются сразу, read-batch-size - только
+	// при следующем запуске
+	s.socket.receiveBuffer, s.socket.sendBuffer = parsed.socket.receiveBuffer, parsed.socket.sendBuffer
+	for _, conn := range s.conns {
+		if err := s.socket.apply(conn); err != nil {
+			s.logger.Warnf("Failed to tune socket %s: %v", conn.LocalAddr(), err)
+		}
+	}
+	kept, total := s.applyConfig(effective, runtime)
+	s.checkPoolWatermarks()
+
+	s.logger.Infof("Configuration reloaded: %d subnets, %d of %d dynamic leases kept",
+		len(cfg.Subnets), kept, total)
+	return nil
+}
+
+// applyConfig пересоздает назначения для новой конфигурации и возвращает
+// количество сохраненных и всех действовавших динамических аренд.
+// Вызывается с захваченным s.mutex.
+func (s *BOOTPServer) applyConfig(cfg *config.DHCPConfig, runtime *config.Runtime) (int, int) {
+	// Запоминаем действующие динамические аренды и активные статические
+	// назначения
+	var dynamic, static []*AllocatedIP
+	for _, allocated := range s.allocatedIP {
+		if allocated.Type == DynamicAllocation {
+			dynamic = append(dynamic, allocated)
+		} else if allocated.Active {
+			static = append(static, allocated)
+		}
+	}
+
+	s.config = cfg
+	s.runtime = runtime
+	s.pools = newSubnetPools(runtime)
+	s.allocatedIP = make(map[uint32]*AllocatedIP)
+	s.allocatedMAC = make(map[string]*AllocatedIP)
+	s.knownClients = make(map[string]bool)
+	s.hosts = make(map[string]*config.Host)
+	s.loadStaticAllocations()
+
+	// Статические назначения, оставшиеся в конфигурации с тем же адресом,
+	// сохраняют активность; удаленные из конфигурации освобождаются
+	for _, previous := range static {
+		if current := s.allocatedMAC[previous.key()]; current != nil && current.Type == StaticAllocation && current.IP == previous.IP {
+			current.Active = previous.Active
+			current.Expires = previous.Expires
+			current.Hostname = previous.Hostname
+			continue
+		}
+		previous.Active = false
+		s.publishLeaseEvent(LeaseReleased, previous)
+	}
+
+	kept := 0
+	for _, allocated := range dynamic {
+		// Клиенты, запрещенные новыми правилами доступа, теряют аренду
+		if subnet := subnetOf(s.config, allocated.IP); subnet != nil &&
+			(!s.isPermitted(allocated.MAC, allocated.ClientID, &s.config.Access) || !s.isPermitted(allocated.MAC, allocated.ClientID, &subnet.Access)) {
+			continue
+		}
+		if s.admitLease(allocated) {
+			kept++
+		}
+	}
+	return kept, len(dynamic)
+}
+
+// rangeSubnet возвращает подсеть, в диапазон которой входит адрес.
+// Для исключенных адресов возвращает nil: они не выдаются динамически.
+func (s *BOOTPServer) rangeSubnet(ip uint32) *config.Subnet {
+	if s.excluded(ip) {
+		return nil
+	}
+	for _, subnet := range s.runtime.Subnets {
+		for _, addressRange := range subnet.Ranges {
+			if ip >= ipToInt(addressRange.Start) && ip <= ipToInt(addressRange.End) {
+				return subnet.Subnet
+			}
+		}
+	}
+	return nil
+}
+
+// allocationHook возвращает текущий хук выделения адресов
+func (s *BOOTPServer) allocationHook() *AllocationHook {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.hook
+}
+
+// rateLimiter возвращает текущий ограничитель частоты запросов
+func (s *BOOTPServer) rateLimiter() *RateLimiter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.limiter
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func newAdminTestServer(t *testing.T) *BOOTPServer {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Hosts: []config.Host{
+					{Name: "static", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"},
+				},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return server
+}
+
+func TestReleaseLease(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:01")
+	if ip != "192.168.1.100" {
+		t.Fatalf("Expected 192.168.1.100, got %s", ip)
+	}
+
+	lease, err := server.ReleaseLease(ip)
+	if err != nil {
+		t.Fatalf("ReleaseLease failed: %v", err)
+	}
+	if lease.MAC != "aa:bb:cc:dd:ee:01" || lease.Active {
+		t.Errorf("Unexpected released lease: %+v", lease)
+	}
+	if _, exists := server.allocatedMAC["aa:bb:cc:dd:ee:01"]; exists {
+		t.Error("Expected dynamic lease to be removed")
+	}
+
+	// Статическое назначение деактивируется, но остается в таблице
+	server.findClientConfig("00:11:22:33:44:55")
+	if _, err := server.ReleaseLease("00:11:22:33:44:55"); err != nil {
+		t.Fatalf("ReleaseLease failed for static host: %v", err)
+	}
+	allocated := server.allocatedMAC["00:11:22:33:44:55"]
+	if allocated == nil || allocated.Active {
+		t.Error("Expected static allocation to stay inactive in the table")
+	}
+
+	if _, err := server.ReleaseLease("10.0.0.1"); err != ErrLeaseNotFound {
+		t.Errorf("Expected ErrLeaseNotFound, got %v", err)
+	}
+}
+
+func TestDraining(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	existing, _ := server.findClientConfig("aa:bb:cc:dd:ee:01")
+	server.SetDraining(true)
+
+	// Существующие клиенты продолжают обслуживаться
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:01"); ip != existing {
+		t.Errorf("Expected existing lease %s to be renewed, got %s", existing, ip)
+	}
+	if ip, _ := server.findClientConfig("00:11:22:33:44:55"); ip != "192.168.1.10" {
+		t.Errorf("Expected static host to be served while draining, got %s", ip)
+	}
+
+	// Новые клиенты адрес не получают
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:02"); ip != "" {
+		t.Errorf("Expected no allocation while draining, got %s", ip)
+	}
+
+	server.SetDraining(false)
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:02"); ip == "" {
+		t.Error("Expected allocation after draining is disabled")
+	}
+}
+
+func TestReload(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	server.findClientConfig("aa:bb:cc:dd:ee:01") // 192.168.1.100
+	server.findClientConfig("aa:bb:cc:dd:ee:02") // 192.168.1.101
+
+	// Новая конфигурация: 192.168.1.101 становится статическим адресом другого хоста
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.120"}},
+				Hosts: []config.Host{
+					{Name: "printer", Hardware: "00:aa:bb:cc:dd:ee", FixedIP: "192.168.1.101"},
+				},
+			},
+		},
+	}
+	if err := server.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if allocated := server.allocatedMAC["aa:bb:cc:dd:ee:01"]; allocated == nil || allocated.Subnet.Ranges[0].End != "192.168.1.120" {
+		t.Error("Expected dynamic lease to be kept and bound to the new subnet")
+	}
+	if _, exists := server.allocatedMAC["aa:bb:cc:dd:ee:02"]; exists {
+		t.Error("Expected lease colliding with a new static address to be dropped")
+	}
+	if _, exists := server.allocatedMAC["00:11:22:33:44:55"]; exists {
+		t.Error("Expected removed static host to be dropped")
+	}
+	if ip, _ := server.findClientConfig("00:aa:bb:cc:dd:ee"); ip != "192.168.1.101" {
+		t.Errorf("Expected new static address, got %s", ip)
+	}
+
+	// Некорректная конфигурация не применяется
+	bad := &config.DHCPConfig{GlobalOptions: map[string]string{"rate-limit-global": "fast"}}
+	if err := server.Reload(bad); err == nil {
+		t.Error("Expected reload of invalid configuration to fail")
+	}
+	if len(server.config.Subnets) != 1 {
+		t.Error("Expected previous configuration to stay active")
+	}
+}
+
+func TestReloadLeaseConsistency(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	server.findClientConfig("aa:bb:cc:dd:ee:01") // 192.168.1.100
+	server.findClientConfig("aa:bb:cc:dd:ee:02") // 192.168.1.101
+	server.findClientConfig("aa:bb:cc:dd:ee:03") // 192.168.1.102
+
+	// Клиент 01 получает резервирование, адрес клиента 02 резервируется
+	// за другим хостом, адрес клиента 03 выходит из диапазона
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.101"}},
+				Hosts: []config.Host{
+					{Name: "printer", Hardware: "00:aa:bb:cc:dd:ee", FixedIP: "192.168.1.101"},
+					{Name: "client1", Hardware: "aa:bb:cc:dd:ee:01", FixedIP: "192.168.1.50"},
+				},
+			},
+		},
+	}
+	if err := server.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if allocated := server.allocatedMAC["aa:bb:cc:dd:ee:03"]; allocated == nil || !allocated.OutOfRange {
+		t.Fatal("Expected lease outside of ranges to be kept and flagged")
+	}
+	var flagged bool
+	for _, lease := range server.Leases() {
+		flagged = flagged || lease.IP == "192.168.1.102" && lease.OutOfRange
+	}
+	if !flagged {
+		t.Error("Expected flagged lease to be reported as out of range")
+	}
+
+	tests := []struct {
+		mac       string
+		requested string
+		want      requestVerdict
+	}{
+		{"aa:bb:cc:dd:ee:01", "192.168.1.100", requestNak},
+		{"aa:bb:cc:dd:ee:02", "192.168.1.101", requestNak},
+		{"aa:bb:cc:dd:ee:02", "192.168.1.101", requestIgnore}, // Отказ отправляется один раз
+		{"aa:bb:cc:dd:ee:03", "192.168.1.102", requestAccept},
+	}
+	for _, test := range tests {
+		if verdict := server.verifyRequestedAddress(test.mac, "", net.ParseIP(test.requested), false); verdict != test.want {
+			t.Errorf("%s requesting %s: expected verdict %d, got %d", test.mac, test.requested, test.want, verdict)
+		}
+	}
+
+	// Отметка снимается, когда адрес снова входит в диапазон
+	cfg.Subnets[0].Ranges[0].End = "192.168.1.110"
+	if err := server.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if allocated := server.allocatedMAC["aa:bb:cc:dd:ee:03"]; allocated == nil || allocated.OutOfRange {
+		t.Error("Expected out of range flag to be cleared")
+	}
+}
+
+func TestReloadAccessRules(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+	server.findClientConfig("00:0c:29:00:00:01")
+
+	// Новые правила запрещают клиентов с OUI 00:0c:29
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Access:  config.AccessRules{DenyMACs: []string{"00:0c:29:*"}},
+			},
+		},
+	}
+	if err := server.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if _, exists := server.allocatedMAC["aa:bb:cc:dd:ee:01"]; !exists {
+		t.Error("Expected permitted lease to be kept")
+	}
+	if _, exists := server.allocatedMAC["00:0c:29:00:00:01"]; exists {
+		t.Error("Expected lease of denied client to be dropped")
+	}
+
+	// Глобальный запрет неизвестных клиентов также применяется к арендам
+	cfg = &config.DHCPConfig{
+		Subnets: cfg.Subnets,
+		Access:  config.AccessRules{UnknownClients: "deny"},
+	}
+	if err := server.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, exists := server.allocatedMAC["aa:bb:cc:dd:ee:01"]; exists {
+		t.Error("Expected lease of unknown client to be dropped")
+	}
+}
+
+func TestStaticAllocationLifecycle(t *testing.T) {
+	server := newAdminTestServer(t)
+	events, cancel := server.SubscribeLeaseEvents(8)
+	defer cancel()
+
+	if ip, _ := server.findClientConfig("00:11:22:33:44:55"); ip != "192.168.1.10" {
+		t.Fatalf("Expected static 192.168.1.10, got %s", ip)
+	}
+	<-events // allocated
+
+	// Активность сохраняется при перезагрузке неизмененной конфигурации
+	if err := server.Reload(server.config); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if allocated := server.allocatedIP[ipToInt(net.ParseIP("192.168.1.10"))]; allocated == nil || !allocated.Active {
+		t.Error("Expected static allocation to stay active after reload")
+	}
+
+	// Без продления статическое назначение становится неактивным
+	server.mutex.Lock()
+	allocated := server.allocatedIP[ipToInt(net.ParseIP("192.168.1.10"))]
+	allocated.Expires = time.Now().Add(-time.Second)
+	if server.isIPAllocated(allocated.IP) || allocated.Active {
+		t.Error("Expected inactive static allocation to be deactivated")
+	}
+	server.mutex.Unlock()
+	if event := <-events; event.Type != LeaseExpired {
+		t.Errorf("Expected expired event, got %s", event.Type)
+	}
+
+	// Хост, удаленный из конфигурации, освобождает адрес
+	server.findClientConfig("00:11:22:33:44:55")
+	<-events // allocated
+	cfg := &config.DHCPConfig{Subnets: []config.Subnet{server.config.Subnets[0]}}
+	cfg.Subnets[0].Hosts = nil
+	if err := server.Reload(cfg); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if event := <-events; event.Type != LeaseReleased || event.Lease.IP != "192.168.1.10" {
+		t.Errorf("Expected released event for 192.168.1.10, got %+v", event)
+	}
+}
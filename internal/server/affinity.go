@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAffinityInterval период записи файла закрепления адресов
+const defaultAffinityInterval = time.Minute
+
+// leaseAffinity закрепление адресов: последний динамический адрес каждого
+// клиента. В отличие от хранилища аренд (LeaseStore) сроки аренд не
+// сохраняются, а файл записывается периодически. После перезапуска клиенту
+// предлагается прежний адрес, если он свободен, а свободные адреса,
+// закрепленные за другими клиентами, выдаются в последнюю очередь.
+// Таблицы изменяются под мьютексом сервера.
+type leaseAffinity struct {
+	path      string
+	interval  time.Duration
+	addresses map[string]uint32 // Ключ клиента (см. clientKey) - последний адрес
+	owners    map[uint32]string // Адрес - ключ клиента
+	dirty     bool              // Есть изменения, не записанные в файл
+
+	saveMutex sync.Mutex // Сериализует запись файла
+}
+
+// parseAffinityOptions читает глобальные опции lease-affinity-file и
+// lease-affinity-interval (секунды). Без файла возвращает nil.
+func parseAffinityOptions(options map[string]string) (*leaseAffinity, error) {
+	path := strings.Trim(options["lease-affinity-file"], "\"")
+	if path == "" {
+		return nil, nil
+	}
+
+	affinity := &leaseAffinity{
+		path:      path,
+		interval:  defaultAffinityInterval,
+		addresses: make(map[string]uint32),
+		owners:    make(map[uint32]string),
+	}
+	if value, ok := options["lease-affinity-interval"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid lease-affinity-interval: %s", value)
+		}
+		affinity.interval = time.Duration(seconds) * time.Second
+	}
+	return affinity, nil
+}
+
+// load читает файл закрепления: строки "<ключ клиента> <IP адрес>".
+// Отсутствующий файл не считается ошибкой, неверные строки пропускаются.
+func (a *leaseAffinity) load() (int, error) {
+	data, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("lease affinity: %v", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if ip := net.ParseIP(fields[1]).To4(); ip != nil {
+			a.remember(fields[0], ipToInt(ip))
+		}
+	}
+	a.dirty = false
+	return len(a.addresses), nil
+}
+
+// remember закрепляет адрес за клиентом. Прежний владелец адреса теряет
+// закрепление.
+func (a *leaseAffinity) remember(key string, ip uint32) {
+	if previous, ok := a.addresses[key]; ok {
+		if previous == ip {
+			return
+		}
+		delete(a.owners, previous)
+	}
+	if owner, ok := a.owners[ip]; ok {
+		delete(a.addresses, owner)
+	}
+	a.addresses[key] = ip
+	a.owners[ip] = key
+	a.dirty = true
+}
+
+// lookup возвращает адрес, закрепленный за клиентом (0 - нет)
+func (a *leaseAffinity) lookup(key string) uint32 {
+	if a == nil {
+		return 0
+	}
+	return a.addresses[key]
+}
+
+// claimed возвращает true, если адрес закреплен за другим клиентом
+func (a *leaseAffinity) claimed(ip uint32, key string) bool {
+	owner, ok := a.owners[ip]
+	return ok && owner != key
+}
+
+// encode возвращает содержимое файла и сбрасывает признак изменений
+func (a *leaseAffinity) encode() []byte {
+	keys := make([]string, 0, len(a.addresses))
+	for key := range a.addresses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&buffer, "%s %s\n", key, intToIP(a.addresses[key]))
+	}
+	a.dirty = false
+	return buffer.Bytes()
+}
+
+// write атомарно заменяет файл закрепления
+func (a *leaseAffinity) write(data []byte) error {
+	a.saveMutex.Lock()
+	defer a.saveMutex.Unlock()
+
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// rememberAffinity закрепляет адрес динамической аренды за клиентом.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) rememberAffinity(allocated *AllocatedIP) {
+	if s.affinity != nil && allocated.Type == DynamicAllocation {
+		s.affinity.remember(allocated.key(), allocated.IP)
+	}
+}
+
+// saveAffinity записывает файл закрепления, если он изменился
+func (s *BOOTPServer) saveAffinity() {
+	s.mutex.Lock()
+	if !s.affinity.dirty {
+		s.mutex.Unlock()
+		return
+	}
+	data := s.affinity.encode()
+	s.mutex.Unlock()
+
+	if err := s.affinity.write(data); err != nil {
+		s.logger.Warnf("Failed to save lease affinity to %s: %v", s.affinity.path, err)
+		s.mutex.Lock()
+		s.affinity.dirty = true
+		s.mutex.Unlock()
+	}
+}
+
+// startAffinitySaver запускает периодическую запись файла закрепления,
+// если задан lease-affinity-file
+func (s *BOOTPServer) startAffinitySaver() {
+	if s.affinity == nil {
+		return
+	}
+	s.affinityStop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(s.affinity.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.saveAffinity()
+			}
+		}
+	}(s.affinityStop)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseAffinityOptions(t *testing.T) {
+	if affinity, err := parseAffinityOptions(map[string]string{}); err != nil || affinity != nil {
+		t.Errorf("Expected no affinity by default, got %+v (%v)", affinity, err)
+	}
+	if _, err := parseAffinityOptions(map[string]string{"lease-affinity-file": "\"/tmp/affinity\"", "lease-affinity-interval": "0"}); err == nil {
+		t.Error("Expected error for zero lease-affinity-interval")
+	}
+}
+
+func TestLeaseAffinity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "affinity")
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"lease-affinity-file": "\"" + path + "\""},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.103"}},
+			},
+		},
+	}
+	assigned := func(server *BOOTPServer, mac byte) string {
+		t.Helper()
+		reply := server.processPacket(requestPacket(mac))
+		if reply == nil {
+			t.Fatalf("Expected client %d to get an address", mac)
+		}
+		return net.IP(reply.Header.Yiaddr[:]).String()
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for mac := byte(1); mac <= 3; mac++ {
+		assigned(server, mac)
+	}
+	server.Stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "02:00:00:00:00:01 192.168.1.100\n02:00:00:00:00:02 192.168.1.101\n02:00:00:00:00:03 192.168.1.102\n"
+	if string(data) != want {
+		t.Errorf("Expected affinity file %q, got %q", want, data)
+	}
+
+	// После перезапуска клиенты получают прежние адреса в любом порядке,
+	// новый клиент - незакрепленный адрес
+	server, err = NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		mac  byte
+		want string
+	}{
+		{3, "192.168.1.102"},
+		{9, "192.168.1.103"},
+		{1, "192.168.1.100"},
+		{10, "192.168.1.101"}, // Пул исчерпан: чужой закрепленный адрес
+	} {
+		if ip := assigned(server, test.mac); ip != test.want {
+			t.Errorf("Client %d: expected %s, got %s", test.mac, test.want, ip)
+		}
+	}
+
+	// Закрепление переходит к новому владельцу адреса
+	server.Stop()
+	if data, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	want = "02:00:00:00:00:01 192.168.1.100\n02:00:00:00:00:03 192.168.1.102\n02:00:00:00:00:09 192.168.1.103\n02:00:00:00:00:0a 192.168.1.101\n"
+	if string(data) != want {
+		t.Errorf("Expected affinity file %q, got %q", want, data)
+	}
+}
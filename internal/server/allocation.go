@@ -0,0 +1,244 @@
+package server
+
+import (
+	"net"
+	"sync"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// Параметры поиска свободного адреса
+const (
+	allocScanChunk   = 256 // Адресов, проверяемых за один захват мьютекса сервера
+	maxAllocAttempts = 3   // Попыток выделить адрес, если его занял параллельный запрос
+)
+
+// subnetPool состояние выдачи адресов подсети. Мьютекс пула упорядочивает
+// поиск свободного адреса в подсети: запросы к разным подсетям ищут адреса
+// параллельно, а мьютекс сервера захватывается только на проверку
+// очередной части диапазона. Порядок захвата: мьютекс пула, затем
+// мьютекс сервера.
+type subnetPool struct {
+	mutex   sync.Mutex
+	pending map[uint32]string // Адреса выполняющихся выделений и ключи их клиентов
+}
+
+// newSubnetPools создает пулы подсетей конфигурации в порядке
+// runtime.Subnets
+func newSubnetPools(runtime *config.Runtime) []*subnetPool {
+	pools := make([]*subnetPool, len(runtime.Subnets))
+	for i := range pools {
+		pools[i] = &subnetPool{pending: make(map[uint32]string)}
+	}
+	return pools
+}
+
+// release освобождает адрес, удерживаемый выделением клиента key
+func (p *subnetPool) release(ip uint32, key string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.pending[ip] == key {
+		delete(p.pending, ip)
+	}
+}
+
+// selectDynamicIP ищет свободный динамический IP адрес для клиента с
+// ключом назначения key и удерживает его за клиентом до releaseOffer.
+// BOOTP клиенты получают адреса только в подсетях, где BOOTP разрешен, и,
+// если в конфигурации есть диапазоны dynamic-bootp, только в них.
+// networks ограничивает выбор подсетями, содержащими эти адреса (см. selectLease).
+// Клиенты со случайными MAC адресами получают адреса из диапазонов
+// randomized-mac (см. rangeAllowed).
+// С lease-affinity-file клиенту сначала предлагается его прежний адрес, а
+// адреса, закрепленные за другими клиентами, выдаются в последнюю очередь.
+// Вызывается без захваченного мьютекса; если конфигурация перезагружена
+// во время поиска, поиск повторяется.
+func (s *BOOTPServer) selectDynamicIP(macAddr, clientID string, bootp bool, networks []net.IP) *leaseOffer {
+	key := clientKey(macAddr, clientID)
+	for attempt := 1; attempt <= maxAllocAttempts; attempt++ {
+		// Подсети, в которых клиенту разрешено получить адрес
+		s.mutex.Lock()
+		runtime, pools := s.runtime, s.pools
+		confined := bootp && s.hasDynamicBOOTP()
+		randomized := isRandomizedMAC(macAddr)
+		var candidates []int
+		for i := range runtime.Subnets {
+			subnet := runtime.Subnets[i].Subnet
+			if !inNetwork(subnet, networks) || !s.isPermitted(macAddr, clientID, &subnet.Access) || (bootp && !s.bootpAllowed(subnet)) {
+				continue
+			}
+			// Остаток пула в пределах pool-reserve выдается только
+			// клиентам, описанным в блоках host
+			if s.alerts.reserve > 0 && !s.isKnown(macAddr, clientID) && s.alerts.reserved(s.subnetUsage(i, s.clock.Now())) {
+				s.logger.Debugf("Pool of subnet %s is down to its reserve, skipping unknown client %s", subnetID(subnet), macAddr)
+				continue
+			}
+			candidates = append(candidates, i)
+		}
+		preferred := s.affinity.lookup(key)
+		s.mutex.Unlock()
+
+		offer, stale := s.scanCandidates(pools, runtime, candidates, confined, randomized, key, preferred)
+		if offer != nil {
+			return offer
+		}
+		if !stale {
+			// Не найдено свободных IP адресов
+			return nil
+		}
+		s.logger.Debugf("Configuration reloaded while allocating address for %s, retrying", macAddr)
+	}
+	return nil
+}
+
+// scanCandidates ищет адрес в пулах подсетей candidates: сначала прежний
+// адрес клиента preferred (0 - нет), затем свободные адреса, не
+// закрепленные за другими клиентами, и только потом закрепленные.
+// Второе значение сообщает, что конфигурация runtime больше не действует.
+func (s *BOOTPServer) scanCandidates(pools []*subnetPool, runtime *config.Runtime, candidates []int, confined, randomized bool, key string, preferred uint32) (*leaseOffer, bool) {
+	offer := func(i int, ip uint32) *leaseOffer {
+		return &leaseOffer{ip: ip, subnet: runtime.Subnets[i].Subnet, pool: pools[i], held: ip}
+	}
+
+	if preferred != 0 {
+		for _, i := range candidates {
+			found, stale := s.scanPreferred(pools[i], runtime, i, confined, randomized, key, preferred)
+			if stale {
+				return nil, true
+			}
+			if found {
+				return offer(i, preferred), false
+			}
+		}
+	}
+
+	passes := []bool{false}
+	if s.affinity != nil {
+		passes = []bool{true, false}
+	}
+	for _, avoidClaimed := range passes {
+		for _, i := range candidates {
+			ip, found, stale := s.scanPool(pools[i], runtime, i, confined, randomized, key, avoidClaimed)
+			if stale {
+				return nil, true
+			}
+			if found {
+				return offer(i, ip), false
+			}
+		}
+	}
+	return nil, false
+}
+
+// scanPool ищет первый свободный адрес в диапазонах подсети с индексом
+// index в порядке объявления, пропуская исключенные адреса и адреса
+// других выполняющихся выделений, и удерживает его за клиентом key.
+// avoidClaimed пропускает адреса, закрепленные за другими клиентами (см.
+// leaseAffinity). stale сообщает, что конфигурация runtime больше не
+// действует.
+func (s *BOOTPServer) scanPool(pool *subnetPool, runtime *config.Runtime, index int, confined, randomized bool, key string, avoidClaimed bool) (ip uint32, found, stale bool) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	subnet := &runtime.Subnets[index]
+	for _, addressRange := range subnet.Ranges {
+		if !rangeAllowed(subnet, addressRange, confined, randomized) {
+			continue
+		}
+		start, end := uint64(ipToInt(addressRange.Start)), uint64(ipToInt(addressRange.End))
+		for chunk := start; chunk <= end; chunk += allocScanChunk {
+			last := chunk + allocScanChunk - 1
+			if last > end {
+				last = end
+			}
+			if ip, found, stale = s.scanChunk(pool, runtime, uint32(chunk), uint32(last), key, avoidClaimed); found || stale {
+				return ip, found, stale
+			}
+		}
+	}
+	return 0, false, false
+}
+
+// scanPreferred удерживает за клиентом key адрес ip, если он входит в
+// диапазоны подсети с индексом index и свободен
+func (s *BOOTPServer) scanPreferred(pool *subnetPool, runtime *config.Runtime, index int, confined, randomized bool, key string, ip uint32) (found, stale bool) {
+	subnet := &runtime.Subnets[index]
+	for _, addressRange := range subnet.Ranges {
+		if !rangeAllowed(subnet, addressRange, confined, randomized) || ip < ipToInt(addressRange.Start) || ip > ipToInt(addressRange.End) {
+			continue
+		}
+		pool.mutex.Lock()
+		defer pool.mutex.Unlock()
+
+		_, found, stale = s.scanChunk(pool, runtime, ip, ip, key, false)
+		return found, stale
+	}
+	return false, false
+}
+
+// scanChunk проверяет адреса first-last под мьютексом сервера.
+// Вызывается с захваченным мьютексом пула.
+func (s *BOOTPServer) scanChunk(pool *subnetPool, runtime *config.Runtime, first, last uint32, key string, avoidClaimed bool) (uint32, bool, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.runtime != runtime {
+		return 0, false, true
+	}
+	for ip := uint64(first); ip <= uint64(last); ip++ {
+		if owner, exists := pool.pending[uint32(ip)]; exists && owner != key {
+			continue
+		}
+		if avoidClaimed && s.affinity.claimed(uint32(ip), key) {
+			continue
+		}
+		if !s.isIPAllocated(uint32(ip)) && !s.excluded(uint32(ip)) {
+			pool.pending[uint32(ip)] = key
+			return uint32(ip), true, false
+		}
+	}
+	return 0, false, false
+}
+
+// rangeAllowed проверяет, выдаются ли клиенту адреса диапазона подсети.
+// При confined (BOOTP клиент) выдаются только диапазоны dynamic-bootp.
+// Диапазоны randomized-mac выдаются только клиентам со случайными MAC
+// адресами, и в подсети с такими диапазонами эти клиенты получают адреса
+// только из них.
+func rangeAllowed(subnet *config.RuntimeSubnet, addressRange config.RuntimeRange, confined, randomized bool) bool {
+	if confined && !addressRange.DynamicBOOTP {
+		return false
+	}
+	if randomized && subnet.HasRandomizedMACRange() {
+		return addressRange.RandomizedMAC
+	}
+	return !addressRange.RandomizedMAC
+}
+
+// releaseOffer освобождает адрес, удерживаемый предложением (см.
+// selectDynamicIP). Вызывается без захваченного мьютекса сервера после
+// фиксации или отказа от предложения.
+func releaseOffer(offer *leaseOffer) {
+	if offer != nil && offer.pool != nil {
+		offer.pool.release(offer.held, offer.key)
+		offer.pool = nil
+	}
+}
+
+// inNetwork проверяет, что подсеть subnet содержит один из адресов
+// networks. networks nil разрешает любую подсеть.
+func inNetwork(subnet *config.Subnet, networks []net.IP) bool {
+	if networks == nil {
+		return true
+	}
+	if subnet == nil || subnet.Network == nil {
+		return false
+	}
+	for _, network := range networks {
+		if subnet.Network.Contains(network) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentAllocation(t *testing.T) {
+	server := newReuseTestServer(t, nil) // Диапазон 192.168.1.100-110
+
+	var wg sync.WaitGroup
+	results := make([]string, 11)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = server.findClientConfig(fmt.Sprintf("aa:bb:cc:dd:ee:%02x", i+1))
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, ip := range results {
+		if ip == "" || seen[ip] {
+			t.Errorf("Client %d got duplicate or no address %q", i+1, ip)
+		}
+		seen[ip] = true
+	}
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:ff"); ip != "" {
+		t.Errorf("Expected exhausted pool, got %s", ip)
+	}
+}
+
+func TestPendingOffer(t *testing.T) {
+	server := newReuseTestServer(t, nil)
+
+	// Адрес выполняющегося выделения не предлагается другому клиенту
+	first := server.selectLease("aa:bb:cc:dd:ee:01", "", false, nil)
+	second := server.selectLease("aa:bb:cc:dd:ee:02", "", false, nil)
+	if first == nil || second == nil || first.ip == second.ip {
+		t.Fatalf("Expected different pending addresses, got %+v and %+v", first, second)
+	}
+
+	// Отмененное выделение освобождает адрес
+	releaseOffer(first)
+	third := server.selectLease("aa:bb:cc:dd:ee:03", "", false, nil)
+	if third == nil || third.ip != first.ip {
+		t.Errorf("Expected released address %s, got %+v", intToIP(first.ip), third)
+	}
+	releaseOffer(second)
+	releaseOffer(third)
+}
+
+func TestAllocationRetry(t *testing.T) {
+	server := newReuseTestServer(t, map[string]string{"ping-check": "true"})
+
+	// Пока клиент ждет ICMP проверки, предложенный адрес занимает
+	// назначение, созданное в обход пула. Мьютекс сервера во время
+	// проверки свободен.
+	taken := false
+	server.probe = func(ip net.IP, timeout time.Duration) bool {
+		if !server.mutex.TryLock() {
+			t.Error("Server mutex is held during ping check")
+			return false
+		}
+		defer server.mutex.Unlock()
+		if !taken {
+			taken = true
+			other := &AllocatedIP{IP: ipToInt(ip), MAC: "aa:bb:cc:dd:ee:99", Type: DynamicAllocation, Active: true, Expires: time.Now().Add(time.Hour)}
+			server.allocatedIP[other.IP] = other
+			server.allocatedMAC[other.key()] = other
+		}
+		return false
+	}
+
+	packet := &Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	}
+	response := server.processPacket(packet)
+	if response == nil {
+		t.Fatal("Expected offer after retry")
+	}
+	if ip := net.IP(response.Header.Yiaddr[:]).String(); ip != "192.168.1.101" {
+		t.Errorf("Expected next address 192.168.1.101, got %s", ip)
+	}
+}
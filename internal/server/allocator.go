@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// AllocatorPlugin собственная логика выбора адреса динамического клиента,
+// например по расположению стойки из опции 82. Плагины собираются в
+// программу вместе с сервером и регистрируются RegisterAllocator.
+type AllocatorPlugin interface {
+	// Allocate возвращает адрес клиента с MAC адресом mac в подсети subnet
+	// (nil - адрес из локального пула). request - опции запроса клиента,
+	// включая опцию 82 релея.
+	Allocate(ctx context.Context, mac string, subnet *net.IPNet, request map[uint8][]byte) (net.IP, error)
+}
+
+// AllocatorFactory создает плагин по глобальным опциям конфигурации
+type AllocatorFactory func(options map[string]string) (AllocatorPlugin, error)
+
+// allocators зарегистрированные плагины по именам
+var allocators = struct {
+	sync.Mutex
+	factories map[string]AllocatorFactory
+}{factories: make(map[string]AllocatorFactory)}
+
+// RegisterAllocator регистрирует плагин выделения адресов под именем,
+// которое выбирается опцией allocator-plugin. Вызывается из init() при
+// сборке программы; повторная регистрация имени - ошибка программы.
+// Собственные глобальные опции плагин объявляет через
+// config.RegisterGlobalOptions.
+func RegisterAllocator(name string, factory AllocatorFactory) {
+	allocators.Lock()
+	defer allocators.Unlock()
+
+	if _, ok := allocators.factories[name]; ok {
+		panic(fmt.Sprintf("allocator plugin %s is already registered", name))
+	}
+	allocators.factories[name] = factory
+}
+
+// NewAllocatorPlugin создает плагин, выбранный глобальной опцией
+// allocator-plugin "rack";. Возвращает nil, если опция не задана.
+func NewAllocatorPlugin(options map[string]string) (AllocatorPlugin, error) {
+	name := strings.Trim(options["allocator-plugin"], "\"")
+	if name == "" {
+		return nil, nil
+	}
+
+	allocators.Lock()
+	factory, ok := allocators.factories[name]
+	allocators.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown allocator-plugin: %s", name)
+	}
+	plugin, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("allocator-plugin %s: %v", name, err)
+	}
+	return plugin, nil
+}
+
+// allocatorOffer заменяет адрес динамического предложения адресом,
+// выбранным плагином. При ошибке плагина или недопустимом адресе
+// (вне подсети, исключен, занят) остается адрес из локального пула.
+func (s *BOOTPServer) allocatorOffer(ctx context.Context, macAddr string, request map[uint8][]byte, offer *leaseOffer) {
+	plugin := s.allocatorPlugin()
+	if plugin == nil || offer.existing != nil && offer.existing.Type == StaticAllocation {
+		return
+	}
+
+	ip, err := plugin.Allocate(ctx, normalizeMAC(macAddr), offer.subnet.Network, request)
+	if err != nil {
+		s.logger.Warnf("Allocator plugin failed for %s, using local pools: %v", macAddr, err)
+		return
+	}
+	if ip == nil || ipToInt(ip) == offer.ip {
+		return
+	}
+	if err := s.reassignIP(macAddr, offer, ip.String()); err != nil {
+		s.logger.Warnf("Allocator plugin address %s for %s cannot be used, using local pools: %v", ip, macAddr, err)
+		return
+	}
+	s.logger.Debugf("Using allocator plugin address %s for %s", ip, macAddr)
+}
+
+// allocatorPlugin возвращает текущий плагин выделения адресов (nil - не настроен)
+func (s *BOOTPServer) allocatorPlugin() AllocatorPlugin {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.allocator
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// rackAllocator выбирает адрес по номеру стойки из circuit-id опции 82
+// (rack-N): первый адрес блока стойки со смещением base-offset
+type rackAllocator struct {
+	offset int
+}
+
+func (a rackAllocator) Allocate(ctx context.Context, mac string, subnet *net.IPNet, request map[uint8][]byte) (net.IP, error) {
+	agent := request[OptionRelayAgentInfo]
+	if len(agent) < 2 || agent[0] != 1 || int(agent[1]) > len(agent)-2 {
+		return nil, nil
+	}
+	circuit := string(agent[2 : 2+agent[1]])
+	rack, err := strconv.Atoi(strings.TrimPrefix(circuit, "rack-"))
+	if err != nil {
+		return nil, errors.New("unknown circuit " + circuit)
+	}
+	ip := make(net.IP, 4)
+	copy(ip, subnet.IP.To4())
+	ip[3] = byte(a.offset + rack*10)
+	return ip, nil
+}
+
+func init() {
+	RegisterAllocator("test-rack", func(options map[string]string) (AllocatorPlugin, error) {
+		offset, err := strconv.Atoi(options["rack-offset"])
+		if err != nil {
+			return nil, errors.New("rack-offset is required")
+		}
+		return rackAllocator{offset: offset}, nil
+	})
+}
+
+func rackPacket(mac byte, circuit string) *Packet {
+	packet := discoverPacket(mac)
+	packet.Options[OptionRelayAgentInfo] = append([]byte{1, byte(len(circuit))}, circuit...)
+	return packet
+}
+
+func TestNewAllocatorPlugin(t *testing.T) {
+	if plugin, err := NewAllocatorPlugin(map[string]string{}); err != nil || plugin != nil {
+		t.Errorf("Expected no allocator plugin by default, got %v (%v)", plugin, err)
+	}
+	if _, err := NewAllocatorPlugin(map[string]string{"allocator-plugin": "\"unknown\""}); err == nil {
+		t.Error("Expected error for unregistered plugin")
+	}
+	if _, err := NewAllocatorPlugin(map[string]string{"allocator-plugin": "test-rack"}); err == nil {
+		t.Error("Expected error from plugin factory")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for duplicate registration")
+		}
+	}()
+	RegisterAllocator("test-rack", nil)
+}
+
+func TestAllocatorPlugin(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+		Hosts: []config.Host{
+			{Name: "static", Hardware: "02:00:00:00:00:03", FixedIP: "192.168.1.10"},
+		},
+		GlobalOptions: map[string]string{"allocator-plugin": "\"test-rack\"", "rack-offset": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	tests := []struct {
+		packet *Packet
+		want   string
+	}{
+		{rackPacket(1, "rack-2"), "192.168.1.21"},  // Адрес стойки
+		{rackPacket(2, "rack-2"), "192.168.1.100"}, // Адрес стойки занят: локальный пул
+		{rackPacket(3, "rack-4"), "192.168.1.10"},  // Статическое назначение из конфигурации
+		{rackPacket(4, "spine"), "192.168.1.101"},  // Ошибка плагина: локальный пул
+		{discoverPacket(6), "192.168.1.102"},       // Без опции 82: локальный пул
+	}
+	for i, test := range tests {
+		reply := server.processPacket(test.packet)
+		if reply == nil {
+			t.Fatalf("Test %d: expected reply", i)
+		}
+		if yiaddr := net.IP(reply.Header.Yiaddr[:]).String(); yiaddr != test.want {
+			t.Errorf("Test %d: expected %s, got %s", i, test.want, yiaddr)
+		}
+	}
+}
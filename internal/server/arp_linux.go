@@ -0,0 +1,45 @@
+//go:build linux
+
+package server
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// arpFlagComplete флаг разрешенной записи таблицы ARP (ATF_COM)
+const arpFlagComplete = 0x2
+
+// readARPTable читает разрешенные записи таблицы ARP ядра из /proc/net/arp
+func readARPTable() (map[uint32]string, error) {
+	file, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return parseARPTable(bufio.NewScanner(file))
+}
+
+// parseARPTable разбирает таблицу в формате /proc/net/arp:
+// IP address  HW type  Flags  HW address  Mask  Device
+func parseARPTable(scanner *bufio.Scanner) (map[uint32]string, error) {
+	table := make(map[uint32]string)
+	scanner.Scan() // Заголовок
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		ip := net.ParseIP(fields[0]).To4()
+		flags, err := strconv.ParseUint(fields[2], 0, 32)
+		if ip == nil || err != nil || flags&arpFlagComplete == 0 || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		table[ipToInt(ip)] = strings.ToLower(fields[3])
+	}
+	return table, scanner.Err()
+}
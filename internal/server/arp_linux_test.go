@@ -0,0 +1,25 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseARPTable(t *testing.T) {
+	table, err := parseARPTable(bufio.NewScanner(strings.NewReader(
+		"IP address       HW type     Flags       HW address            Mask     Device\n" +
+			"192.168.1.100    0x1         0x2         02:00:00:00:00:AA     *        eth0\n" +
+			"192.168.1.101    0x1         0x0         00:00:00:00:00:00     *        eth0\n" +
+			"192.168.1.102    0x1         0x6         02:00:00:00:00:bb     *        eth0\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 2 {
+		t.Fatalf("Expected 2 resolved entries, got %v", table)
+	}
+	if mac := table[ipToInt(net.IPv4(192, 168, 1, 100))]; mac != "02:00:00:00:00:aa" {
+		t.Errorf("Expected normalized MAC for 192.168.1.100, got %q", mac)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package server
+
+import "errors"
+
+// readARPTable не поддерживается на этой платформе
+func readARPTable() (map[uint32]string, error) {
+	return nil, errors.New("ARP scanning is not supported on this platform")
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// Адресов, проверяемых за одно сканирование
+	maxScanAddresses = 4096
+
+	// Время ожидания ARP ответов после рассылки
+	arpScanWait = 2 * time.Second
+)
+
+// Conflict расхождение между таблицей назначений и адресом, найденным
+// сканированием ARP
+type Conflict struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac"`                 // MAC адрес, ответивший в сети
+	LeaseMAC  string    `json:"lease_mac,omitempty"` // MAC адрес назначения (пусто - адрес свободен)
+	Abandoned bool      `json:"abandoned"`           // Адрес исключен из пула
+	Detected  time.Time `json:"detected"`            // Время первого обнаружения
+}
+
+// neighborScanner находит в сети узлы с указанными адресами и возвращает
+// их MAC адреса (ключ - IP адрес в виде числа)
+type neighborScanner func(ips []net.IP) (map[uint32]string, error)
+
+// parseConflictScanInterval читает опцию conflict-scan-interval (секунды).
+// 0 или отсутствие опции отключает сканирование.
+func parseConflictScanInterval(options map[string]string) (time.Duration, error) {
+	value, ok := options["conflict-scan-interval"]
+	if !ok {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid conflict-scan-interval: %s", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// startConflictScan запускает периодическое сканирование подсетей, если
+// задан conflict-scan-interval
+func (s *BOOTPServer) startConflictScan() error {
+	interval, err := parseConflictScanInterval(s.config.GlobalOptions)
+	if err != nil || interval == 0 {
+		return err
+	}
+
+	s.scanStop = make(chan struct{})
+	s.logger.Infof("Scanning subnets for address conflicts every %v", interval)
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.ScanConflicts(); err != nil {
+					s.logger.Warnf("Conflict scan failed: %v", err)
+				}
+			}
+		}
+	}(s.scanStop)
+	return nil
+}
+
+// ScanConflicts сканирует диапазоны подсетей и статические адреса и
+// сверяет найденные пары MAC и IP адресов с таблицей назначений
+func (s *BOOTPServer) ScanConflicts() error {
+	observed, err := s.neighbors(s.scanAddresses())
+	if err != nil {
+		return err
+	}
+	conflicts := s.checkNeighbors(observed, s.clock.Now())
+	if len(conflicts) > 0 {
+		s.logger.Warnf("Conflict scan found %d address conflicts", len(conflicts))
+	}
+	return nil
+}
+
+// Conflicts возвращает конфликты, найденные последним сканированием
+func (s *BOOTPServer) Conflicts() []Conflict {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Conflict(nil), s.scanned...)
+}
+
+// scanAddresses возвращает адреса для сканирования: диапазоны range без
+// исключенных адресов и статические назначения, не более maxScanAddresses
+func (s *BOOTPServer) scanAddresses() []net.IP {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	seen := make(map[uint32]bool)
+	for _, subnet := range s.runtime.Subnets {
+		for _, addressRange := range subnet.Ranges {
+			for ip := ipToInt(addressRange.Start); ip <= ipToInt(addressRange.End) && len(seen) < maxScanAddresses; ip++ {
+				if !s.excluded(ip) {
+					seen[ip] = true
+				}
+			}
+		}
+	}
+	for ip, allocated := range s.allocatedIP {
+		if allocated.Type == StaticAllocation && len(seen) < maxScanAddresses {
+			seen[ip] = true
+		}
+	}
+	if len(seen) == maxScanAddresses {
+		s.logger.Warnf("Conflict scan is limited to %d addresses", maxScanAddresses)
+	}
+
+	ips := make([]uint32, 0, len(seen))
+	for ip := range seen {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i] < ips[j] })
+
+	result := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		result[i] = intToIP(ip)
+	}
+	return result
+}
+
+// checkNeighbors сверяет найденные в сети адреса с таблицей назначений.
+// Адрес назначения, на котором отвечает другой MAC адрес, отмечается как
+// конфликт; динамический адрес исключается из пула после окончания аренды.
+// Свободный адрес диапазона, занятый посторонним узлом, исключается из
+// пула сразу. Статические адреса не исключаются: их задает конфигурация.
+func (s *BOOTPServer) checkNeighbors(observed map[uint32]string, now time.Time) []Conflict {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := make(map[string]time.Time, len(s.scanned))
+	for _, conflict := range s.scanned {
+		previous[conflict.IP+" "+conflict.MAC] = conflict.Detected
+	}
+
+	var conflicts []Conflict
+	for ip, mac := range observed {
+		mac = normalizeMAC(mac)
+		conflict := Conflict{IP: intToIP(ip).String(), MAC: mac, Detected: now}
+
+		allocated := s.allocatedIP[ip]
+		switch {
+		case allocated != nil && normalizeMAC(allocated.MAC) == mac:
+			continue
+		case allocated != nil && allocated.Type == StaticAllocation:
+			conflict.LeaseMAC = allocated.MAC
+		case allocated != nil && allocated.state(now) == LeaseStateActive:
+			conflict.LeaseMAC = allocated.MAC
+			conflict.Abandoned = true
+		case s.inRange(ip):
+			conflict.Abandoned = true
+		default:
+			// Адрес вне пулов и назначений сервера
+			continue
+		}
+
+		if detected, seen := previous[conflict.IP+" "+conflict.MAC]; seen {
+			conflict.Detected = detected
+		} else {
+			s.logger.Warnf("Address %s is used by %s, leased to %q", conflict.IP, mac, conflict.LeaseMAC)
+			s.counters.conflicts.Add(1)
+			if conflict.Abandoned {
+				s.recordAudit(AuditConflict, mac, conflict.IP)
+			}
+		}
+		if conflict.Abandoned {
+			until := now.Add(s.leaseTime)
+			if allocated != nil && allocated.Expires.After(now) {
+				until = allocated.Expires.Add(s.leaseTime)
+			}
+			s.conflicts[ip] = until
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return ipToInt(net.ParseIP(conflicts[i].IP)) < ipToInt(net.ParseIP(conflicts[j].IP))
+	})
+	s.scanned = conflicts
+	return conflicts
+}
+
+// inRange проверяет, входит ли адрес в диапазон range одной из подсетей.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) inRange(ip uint32) bool {
+	return s.rangeSubnet(ip) != nil
+}
+
+// scanNeighbors отправляет на каждый адрес UDP датаграмму, чтобы ядро
+// разрешило его через ARP, и читает таблицу соседей
+func scanNeighbors(ips []net.IP) (map[uint32]string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Порт discard: ответ не нужен, важен только ARP запрос
+	for _, ip := range ips {
+		conn.WriteToUDP([]byte{0}, &net.UDPAddr{IP: ip, Port: 9})
+	}
+	time.Sleep(arpScanWait)
+
+	return readARPTable()
+}
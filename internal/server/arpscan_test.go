@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseConflictScanInterval(t *testing.T) {
+	if interval, err := parseConflictScanInterval(map[string]string{}); err != nil || interval != 0 {
+		t.Errorf("Expected scanning to be disabled by default, got %v (%v)", interval, err)
+	}
+	if interval, err := parseConflictScanInterval(map[string]string{"conflict-scan-interval": "300"}); err != nil || interval != 5*time.Minute {
+		t.Errorf("Expected 5m, got %v (%v)", interval, err)
+	}
+	if _, err := parseConflictScanInterval(map[string]string{"conflict-scan-interval": "-1"}); err == nil {
+		t.Error("Expected error for negative interval")
+	}
+}
+
+func TestScanConflicts(t *testing.T) {
+	server := newIPAMTestServer(t, "http://ipam.invalid")
+	server.ipam = nil
+
+	// Клиент 1 получил 192.168.1.100
+	if reply := server.processPacket(discoverPacket(1)); reply == nil {
+		t.Fatal("Expected reply")
+	}
+	server.findClientConfig("02:00:00:00:00:01")
+
+	var scanned []net.IP
+	server.neighbors = func(ips []net.IP) (map[uint32]string, error) {
+		scanned = ips
+		return map[uint32]string{
+			ipToInt(net.IPv4(192, 168, 1, 100)): "02:00:00:00:00:01", // Совпадает с арендой
+			ipToInt(net.IPv4(192, 168, 1, 101)): "02:00:00:00:00:0a", // Свободный адрес пула
+			ipToInt(net.IPv4(192, 168, 1, 10)):  "02:00:00:00:00:0b", // Чужой статический адрес
+			ipToInt(net.IPv4(192, 168, 1, 50)):  "02:00:00:00:00:0c", // Вне пула
+		}, nil
+	}
+
+	if err := server.ScanConflicts(); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != 12 {
+		t.Errorf("Expected range and static addresses to be scanned, got %d", len(scanned))
+	}
+
+	conflicts := server.Conflicts()
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %+v", conflicts)
+	}
+	if c := conflicts[0]; c.IP != "192.168.1.10" || c.LeaseMAC != "02:00:00:00:00:03" || c.Abandoned {
+		t.Errorf("Expected static address conflict to be flagged only, got %+v", c)
+	}
+	if c := conflicts[1]; c.IP != "192.168.1.101" || c.LeaseMAC != "" || !c.Abandoned {
+		t.Errorf("Expected free address to be abandoned, got %+v", c)
+	}
+
+	// Исключенный адрес не выдается
+	if reply := server.processPacket(discoverPacket(2)); reply == nil || net.IP(reply.Header.Yiaddr[:]).String() != "192.168.1.102" {
+		t.Errorf("Expected abandoned address to be skipped, got %+v", reply)
+	}
+	if stats := server.Stats(); stats.Counters.Conflicts != 2 {
+		t.Errorf("Expected 2 conflicts counted, got %d", stats.Counters.Conflicts)
+	}
+
+	// Повторное обнаружение сохраняет время и не учитывается снова
+	detected := conflicts[0].Detected
+	if err := server.ScanConflicts(); err != nil {
+		t.Fatal(err)
+	}
+	if conflicts := server.Conflicts(); len(conflicts) != 2 || !conflicts[0].Detected.Equal(detected) {
+		t.Errorf("Expected conflicts to keep detection time, got %+v", conflicts)
+	}
+	if stats := server.Stats(); stats.Counters.Conflicts != 2 {
+		t.Errorf("Expected repeated conflicts not to be counted, got %d", stats.Counters.Conflicts)
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultAuditLogSize записей журнала аудита, хранимых в памяти по умолчанию
+const defaultAuditLogSize = 10000
+
+// AuditAction действие, записанное в журнал аудита
+type AuditAction string
+
+const (
+	AuditAllocated AuditAction = "allocated" // Адрес выдан клиенту
+	AuditRenewed   AuditAction = "renewed"   // Аренда продлена
+	AuditReleased  AuditAction = "released"  // Назначение освобождено
+	AuditExpired   AuditAction = "expired"   // Срок аренды истек
+	AuditNak       AuditAction = "nak"       // Клиенту отказано в запрошенном адресе
+	AuditConflict  AuditAction = "conflict"  // Адрес ответил на ICMP проверку и исключен из пула
+)
+
+// AuditEntry запись журнала аудита
+type AuditEntry struct {
+	Time     time.Time   `json:"time"`
+	Action   AuditAction `json:"action"`
+	MAC      string      `json:"mac,omitempty"`
+	IP       string      `json:"ip,omitempty"`
+	Hostname string      `json:"hostname,omitempty"`
+	Subnet   string      `json:"subnet,omitempty"` // Идентификатор подсети, см. Lease.SubnetID
+}
+
+// AuditFilter условия выборки из журнала аудита. Пустые поля не ограничивают выборку.
+type AuditFilter struct {
+	MAC    string
+	IP     string
+	Action AuditAction
+	Since  time.Time
+	Limit  int
+}
+
+// auditLog хранит последние записи аудита в кольцевом буфере и, если
+// задан файл, дописывает каждую запись в него строкой JSON
+type auditLog struct {
+	mutex   sync.Mutex
+	entries []AuditEntry
+	next    int // Позиция следующей записи при заполненном буфере
+	size    int
+	file    *os.File
+}
+
+// auditSettings параметры журнала аудита из глобальных опций
+type auditSettings struct {
+	size int
+	path string
+}
+
+// parseAuditOptions читает глобальные опции audit-log-size и audit-log-file
+func parseAuditOptions(options map[string]string) (auditSettings, error) {
+	settings := auditSettings{
+		size: defaultAuditLogSize,
+		path: strings.Trim(options["audit-log-file"], "\""),
+	}
+
+	if value, ok := options["audit-log-size"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return settings, fmt.Errorf("invalid audit-log-size: %s", value)
+		}
+		settings.size = n
+	}
+
+	return settings, nil
+}
+
+func newAuditLog(size int) *auditLog {
+	return &auditLog{size: size}
+}
+
+// resize меняет размер буфера, сохраняя последние записи
+func (a *auditLog) resize(size int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if size == a.size {
+		return
+	}
+	entries := make([]AuditEntry, 0, len(a.entries))
+	entries = append(entries, a.entries[a.next:]...)
+	entries = append(entries, a.entries[:a.next]...)
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	a.entries, a.next, a.size = entries, 0, size
+}
+
+// openFile начинает дописывать записи в файл
+func (a *auditLog) openFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("audit log: %v", err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.file != nil {
+		a.file.Close()
+	}
+	a.file = file
+	return nil
+}
+
+// close закрывает файл журнала
+func (a *auditLog) close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+}
+
+// record добавляет запись, вытесняя самую старую при заполненном буфере
+func (a *auditLog) record(entry AuditEntry) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.entries) < a.size {
+		a.entries = append(a.entries, entry)
+	} else {
+		a.entries[a.next] = entry
+		a.next = (a.next + 1) % a.size
+	}
+
+	if a.file != nil {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			_, err = a.file.Write(append(data, '\n'))
+		}
+		if err != nil {
+			logrus.Errorf("Error writing audit log: %v", err)
+		}
+	}
+}
+
+// query возвращает записи, подходящие под фильтр, от новых к старым
+func (a *auditLog) query(filter AuditFilter) []AuditEntry {
+	mac := normalizeMAC(filter.MAC)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	result := make([]AuditEntry, 0)
+	for i := 0; i < len(a.entries); i++ {
+		// Самая новая запись находится перед позицией next
+		entry := a.entries[(a.next-1-i+2*len(a.entries))%len(a.entries)]
+		if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+			break
+		}
+		if (filter.MAC != "" && entry.MAC != mac) ||
+			(filter.IP != "" && entry.IP != filter.IP) ||
+			(filter.Action != "" && entry.Action != filter.Action) {
+			continue
+		}
+		result = append(result, entry)
+		if filter.Limit > 0 && len(result) == filter.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// configureAuditLog задает размер журнала аудита и файл по глобальным опциям
+func (s *BOOTPServer) configureAuditLog(options map[string]string) error {
+	settings, err := parseAuditOptions(options)
+	if err != nil {
+		return err
+	}
+
+	s.audit.resize(settings.size)
+	if settings.path != "" {
+		if err := s.audit.openFile(settings.path); err != nil {
+			return err
+		}
+		s.logger.Infof("Audit log enabled, writing to %s", settings.path)
+	}
+	return nil
+}
+
+// recordAudit добавляет в журнал аудита запись, не связанную с назначением
+func (s *BOOTPServer) recordAudit(action AuditAction, mac, ip string) {
+	s.audit.record(AuditEntry{Time: s.clock.Now(), Action: action, MAC: mac, IP: ip})
+}
+
+// AuditLog возвращает записи журнала аудита о выдаче, продлении и
+// освобождении адресов, отказах и конфликтах, от новых к старым
+func (s *BOOTPServer) AuditLog(filter AuditFilter) []AuditEntry {
+	return s.audit.query(filter)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestAuditLogRing(t *testing.T) {
+	audit := newAuditLog(3)
+	start := time.Now()
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		audit.record(AuditEntry{Time: start.Add(time.Duration(i) * time.Second), Action: AuditAllocated, MAC: "aa:bb:cc:dd:ee:01", IP: ip})
+	}
+
+	entries := audit.query(AuditFilter{})
+	if len(entries) != 3 || entries[0].IP != "10.0.0.4" || entries[2].IP != "10.0.0.2" {
+		t.Fatalf("Expected 3 newest entries, got %+v", entries)
+	}
+
+	if entries := audit.query(AuditFilter{Limit: 1}); len(entries) != 1 || entries[0].IP != "10.0.0.4" {
+		t.Errorf("Expected newest entry, got %+v", entries)
+	}
+	if entries := audit.query(AuditFilter{Since: start.Add(2 * time.Second)}); len(entries) != 2 {
+		t.Errorf("Expected 2 entries since start+2s, got %+v", entries)
+	}
+	if entries := audit.query(AuditFilter{IP: "10.0.0.3"}); len(entries) != 1 {
+		t.Errorf("Expected 1 entry for 10.0.0.3, got %+v", entries)
+	}
+	if entries := audit.query(AuditFilter{MAC: "AA-BB-CC-DD-EE-01", Action: AuditNak}); len(entries) != 0 {
+		t.Errorf("Expected no NAK entries, got %+v", entries)
+	}
+
+	// Уменьшение буфера сохраняет последние записи
+	audit.resize(2)
+	if entries := audit.query(AuditFilter{}); len(entries) != 2 || entries[0].IP != "10.0.0.4" || entries[1].IP != "10.0.0.3" {
+		t.Errorf("Unexpected entries after resize: %+v", entries)
+	}
+}
+
+func TestAuditLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"audit-log-file": "\"" + path + "\"", "audit-log-size": "100"},
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}}},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+	if _, err := server.ReleaseLease("aa:bb:cc:dd:ee:01"); err != nil {
+		t.Fatal(err)
+	}
+	server.Stop()
+
+	entries := server.AuditLog(AuditFilter{MAC: "aa:bb:cc:dd:ee:01"})
+	if len(entries) != 2 || entries[0].Action != AuditReleased || entries[1].Action != AuditAllocated {
+		t.Fatalf("Expected release after allocation, got %+v", entries)
+	}
+	if entries[1].IP != "192.168.1.100" || entries[1].Subnet != "192.168.1.0/24" {
+		t.Errorf("Unexpected allocation entry: %+v", entries[1])
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var actions []AuditAction
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid audit line %q: %v", scanner.Text(), err)
+		}
+		actions = append(actions, entry.Action)
+	}
+	if len(actions) != 2 || actions[0] != AuditAllocated || actions[1] != AuditReleased {
+		t.Errorf("Unexpected audit file actions: %v", actions)
+	}
+}
+
+func TestAuditNakAndConflict(t *testing.T) {
+	server := newAdminTestServer(t) // Диапазон 192.168.1.100-110
+	server.reuse.pingCheck = true
+	server.probe = func(ip net.IP, timeout time.Duration) bool {
+		return ip.Equal(net.ParseIP("192.168.1.100"))
+	}
+	authoritative := true
+	server.config.Authoritative = &authoritative
+
+	server.processPacket(&Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	})
+	server.processPacket(&Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 2}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPRequest}, OptionRequestedIP: net.ParseIP("10.0.0.1").To4()},
+	})
+
+	if entries := server.AuditLog(AuditFilter{Action: AuditConflict}); len(entries) != 1 || entries[0].IP != "192.168.1.100" {
+		t.Errorf("Expected conflict for 192.168.1.100, got %+v", entries)
+	}
+	if entries := server.AuditLog(AuditFilter{Action: AuditNak}); len(entries) != 1 || entries[0].MAC != "02:00:00:00:00:02" || entries[0].IP != "10.0.0.1" {
+		t.Errorf("Expected NAK for 10.0.0.1, got %+v", entries)
+	}
+}
+
+func TestParseAuditOptions(t *testing.T) {
+	settings, err := parseAuditOptions(map[string]string{})
+	if err != nil || settings.size != defaultAuditLogSize || settings.path != "" {
+		t.Errorf("Unexpected defaults: %+v (%v)", settings, err)
+	}
+	for _, value := range []string{"0", "-1", "many"} {
+		if _, err := parseAuditOptions(map[string]string{"audit-log-size": value}); err == nil {
+			t.Errorf("Expected error for audit-log-size %q", value)
+		}
+	}
+	if err := ValidateConfig(&config.DHCPConfig{GlobalOptions: map[string]string{"audit-log-size": "0"}}); err == nil {
+		t.Error("Expected ValidateConfig to reject invalid audit-log-size")
+	}
+}
@@ -1,10 +1,11 @@
 package server
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +20,8 @@ const (
 
 	HTYPE_ETHER = 1
 
-	BOOTP_PORT = 67
+	BOOTP_PORT        = 67
+	BOOTP_CLIENT_PORT = 68
 )
 
 // BOOTPHeader представляет заголовок BOOTP пакета
@@ -51,162 +53,791 @@ const (
 
 // AllocatedIP хранит информацию о выделенном IP адресе
 type AllocatedIP struct {
-	IP      uint32         // IP адрес в виде целого числа
-	MAC     string         // MAC адрес клиента
-	Subnet  *config.Subnet // Подсеть
-	Type    AllocationType // Тип выделения
-	Active  bool           // Флаг активности (для статических адресов)
-	Expires time.Time      // Время истечения аренды (для динамических адресов)
+	IP       uint32         // IP адрес в виде целого числа
+	MAC      string         // MAC адрес клиента
+	ClientID string         // Идентификатор клиента (опция 61), пусто - аренда по MAC адресу
+	Hostname string         // Имя хоста клиента (опция 12 или назначенное сервером)
+	Subnet   *config.Subnet // Подсеть
+	Type     AllocationType // Тип выделения
+	Active   bool           // Флаг активности (для статических адресов)
+	Expires  time.Time      // Время истечения аренды (для статических - срок активности)
+	BOOTP    bool           // Назначение выдано BOOTP клиенту, который не продлевает аренду
+
+	// Сведения из последнего запроса клиента для отладки загрузки
+	VendorClass     string   // Класс производителя (опция 60)
+	Arch            []uint16 // Архитектуры клиента (опция 93, RFC 4578)
+	RequestClientID string   // Опция 61 запроса (ClientID пуст у назначений по MAC адресу)
+
+	OutOfRange bool // Адрес вне диапазонов действующей конфигурации (см. admitLease)
 }
 
 // BOOTPServer представляет BOOTP сервер
 type BOOTPServer struct {
-	config       *config.DHCPConfig
-	conn         *net.UDPConn
+	config       *config.DHCPConfig      // Действующая конфигурация с резервированиями, добавленными во время работы
+	baseConfig   *config.DHCPConfig      // Конфигурация из файла
+	runtime      *config.Runtime         // Разобранная действующая конфигурация (см. config.Compile)
+	reservations []config.Host           // Резервирования, добавленные во время работы (см. reservations.go)
+	adminMutex   sync.Mutex              // Сериализует перезагрузку конфигурации и изменение резервирований
+	conn         *net.UDPConn            // Основной сокет (первый из conns)
+	conns        []*net.UDPConn          // Сокеты по одному на интерфейс
+	interfaces   []string                // Интерфейсы для обслуживания (пусто - все)
+	listen       *net.UDPAddr            // Адрес и порт приема запросов (bootp-listen, WithListenAddress)
 	allocatedIP  map[uint32]*AllocatedIP // Выделенные IP адреса (ключ - IP адрес в виде числа)
-	allocatedMAC map[string]*AllocatedIP // Выделенные IP адреса (ключ - MAC адрес)
+	allocatedMAC map[string]*AllocatedIP // Выделенные IP адреса (ключ - client-id или MAC адрес, см. clientKey)
+	knownClients map[string]bool         // Клиенты, описанные в блоках host (ключи clientKey: MAC адрес или client-id)
+	hosts        map[string]*config.Host // Блоки host по client-id или MAC адресу (см. clientKey)
 	mutex        sync.Mutex              // Мьютекс для синхронизации доступа к allocated
+	hook         *AllocationHook         // Внешний хук принятия решения (может быть nil)
+	ipam         IPAMDriver              // Внешняя IPAM, источник адресов клиентов (может быть nil)
+	limiter      *RateLimiter            // Ограничитель частоты запросов (может быть nil)
+	capture      PacketDumper            // Захват пакетов для отладки (может быть nil)
+	captureMutex sync.RWMutex            // Мьютекс для переключения захвата во время работы
+	timeline     *BootTimeline           // Хронология загрузки клиентов
+	tftp         *TFTPServer             // Встроенный TFTP сервер (может быть nil)
+	httpBoot     *http.Server            // Встроенный HTTP сервер загрузки (может быть nil)
+	dns          *DNSServer              // Встроенный DNS сервер имен клиентов (может быть nil)
+	draining     bool                    // Режим вывода из эксплуатации: новые адреса не выдаются
+	events       *eventBus               // Подписчики на события аренд
+	middleware   []Middleware            // Обработчики запросов, добавленные через Use
+	handler      Handler                 // Цепочка обработчиков, собранная из middleware и встроенных
+	reuse        reusePolicy             // Удержание истекших аренд и ICMP проверка адресов
+	conflicts    map[uint32]time.Time    // Адреса, ответившие на ICMP проверку или найденные сканированием, и срок их исключения
+	scanned      []Conflict              // Конфликты, найденные последним сканированием ARP
+	scanStop     chan struct{}           // Остановка периодического сканирования (nil - не запущено)
+	reapStop     chan struct{}           // Остановка удаления истекших аренд (nil - не запущено)
+	neighbors    neighborScanner         // Поиск узлов в сети (заменяется в тестах)
+	bootpLease   time.Duration           // Срок аренды BOOTP клиентов (0 - бессрочно)
+	maxReply     int                     // Ограничение размера ответа max-reply-size (0 - не задано)
+	alwaysSend   []uint8                 // Опции, отправляемые без запроса в опции 55 (always-send-options)
+	minSecs      uint16                  // Минимальное время ожидания клиента для ответа (min-secs)
+	relays       *relayPolicy            // Доверенные релеи (allowed-relays, nil - без проверки)
+	alerts       poolAlerts              // Порог заполнения пулов и запас для известных клиентов
+	poolLow      map[string]bool         // Подсети, свободных адресов в которых меньше порога
+	affinity     *leaseAffinity          // Последние адреса клиентов (lease-affinity-file, может быть nil)
+	affinityStop chan struct{}           // Остановка записи файла закрепления (nil - не запущена)
+	started      time.Time               // Время создания сервера
+	counters     counters                // Счетчики запросов и событий аренд
+	audit        *auditLog               // Журнал аудита назначений
+	probe        addressProber           // ICMP проверка адреса (заменяется в тестах)
+	lookup       DNSResolver             // Разрешение имени сервера загрузки (заменяется в тестах)
+	serverNames  map[string]net.IP       // Разрешенные имена серверов загрузки (nil - не найдено) до перезагрузки
+	replies      *retransmitCache        // Ответы на повторные запросы
+	pools        []*subnetPool           // Выдача адресов подсетей в порядке runtime.Subnets
+	socket       socketTuning            // Буферы сокетов и пакетное чтение (применяются в Start)
+	revoked      map[string]uint32       // Аренды, отозванные при сверке с конфигурацией, по ключу клиента (см. revokeLease)
+
+	vlans  vlanConfig    // VLAN транковых интерфейсов (vlan-trunk, vlan-map)
+	trunks []trunkSocket // Сокеты транковых интерфейсов, открытые в Start
+
+	requestTimeout time.Duration // Предельное время обработки запроса (request-timeout, 0 - без ограничения)
+
+	randomMAC randomizedMACPolicy // Обработка клиентов со случайными MAC адресами
+
+	allocator AllocatorPlugin // Плагин выделения адресов (allocator-plugin, может быть nil)
+
+	ifaces interfaceOverrides // Параметры интерфейсов (interface-server-identifier, interface-next-server, interface-subnets)
+
+	learning  bool             // Режим обучения: запросы записываются в опись без ответа (learning-mode)
+	inventory *clientInventory // Клиенты, замеченные в режиме обучения
+
+	delayed delayedReplies // Ответы, отложенные оператором delay
+
+	logger    logrus.FieldLogger // Журнал сервера (WithLogger)
+	clock     Clock              // Источник времени для сроков аренд (WithClock)
+	store     LeaseStore         // Хранилище аренд (WithLeaseStore, может быть nil)
+	leaseTime time.Duration      // Срок динамической аренды (WithLeaseDuration)
 }
 
-// NewBOOTPServer создает новый BOOTP сервер
-func NewBOOTPServer(cfg *config.DHCPConfig) (*BOOTPServer, error) {
+// NewBOOTPServer создает новый BOOTP сервер. Параметры opts применяются
+// после конфигурации и переопределяют ее.
+func NewBOOTPServer(cfg *config.DHCPConfig, opts ...Option) (*BOOTPServer, error) {
+	effective, managed, err := effectiveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := config.Compile(effective)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &BOOTPServer{
-		config:       cfg,
+		config:       effective,
+		baseConfig:   cfg,
+		runtime:      runtime,
+		reservations: managed,
 		allocatedIP:  make(map[uint32]*AllocatedIP),
 		allocatedMAC: make(map[string]*AllocatedIP),
+		knownClients: make(map[string]bool),
+		hosts:        make(map[string]*config.Host),
+		timeline:     NewBootTimeline(),
+		events:       newEventBus(),
+		conflicts:    make(map[uint32]time.Time),
+		listen:       &net.UDPAddr{Port: BOOTP_PORT},
+		probe:        pingAddress,
+		lookup:       lookupIPv4,
+		serverNames:  make(map[string]net.IP),
+		replies:      newRetransmitCache(defaultRetransmitWindow),
+		pools:        newSubnetPools(runtime),
+		poolLow:      make(map[string]bool),
+		revoked:      make(map[string]uint32),
+		socket:       socketTuning{readBatch: defaultReadBatch},
+		neighbors:    scanNeighbors,
+		started:      time.Now(),
+		audit:        newAuditLog(defaultAuditLogSize),
+		inventory:    newClientInventory(),
+		logger:       logrus.StandardLogger(),
+		clock:        systemClock{},
+		leaseTime:    defaultLeaseDuration,
+		bootpLease:   runtime.BOOTPLeaseLength,
 	}
+	server.handler = server.chain()
 
 	// Инициализируем статические назначения
+	if err := checkReservations(effective); err != nil {
+		return nil, err
+	}
+	if err := checkLeaseTimers(effective); err != nil {
+		return nil, err
+	}
 	server.initStaticAllocations()
 
+	// Разбираем глобальные параметры: хук и IPAM, ограничения запросов,
+	// VLAN и параметры интерфейсов, сокеты
+	if cfg.GlobalOptions != nil {
+		parsed, err := parseGlobalOptions(cfg.GlobalOptions, effective.Subnets)
+		if err != nil {
+			return nil, err
+		}
+		server.hook = parsed.hook
+		server.ipam = parsed.ipam
+		server.allocator = parsed.allocator
+		server.limiter = parsed.limiter
+		server.reuse = parsed.reuse
+		server.maxReply = parsed.maxReply
+		server.alwaysSend = parsed.alwaysSend
+		server.minSecs = parsed.minSecs
+		server.requestTimeout = parsed.requestTimeout
+		server.randomMAC = parsed.randomMAC
+		server.relays = parsed.relays
+		server.alerts = parsed.alerts
+		server.affinity = parsed.affinity
+		server.vlans = parsed.vlans
+		server.ifaces = parsed.ifaces
+		server.learning = parsed.learning
+		server.replies.reset(parsed.window)
+		server.socket = parsed.socket
+
+		if err := server.configurePacketCapture(cfg.GlobalOptions); err != nil {
+			return nil, err
+		}
+
+		if err := server.configureAuditLog(cfg.GlobalOptions); err != nil {
+			return nil, err
+		}
+
+		if names := parseInterfaces(cfg.GlobalOptions); len(names) > 0 {
+			if err := server.SetInterfaces(names); err != nil {
+				return nil, err
+			}
+		}
+
+		if server.listen, err = parseListenAddress(cfg.GlobalOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, opt := range opts {
+		if err := opt(server); err != nil {
+			return nil, err
+		}
+	}
+
+	if server.affinity != nil {
+		loaded, err := server.affinity.load()
+		if err != nil {
+			return nil, err
+		}
+		server.logger.Infof("Loaded %d lease affinities from %s", loaded, server.affinity.path)
+	}
+
+	// Сохраненные аренды восстанавливаются после статических назначений,
+	// чтобы не занять зарезервированные адреса
+	if server.store != nil {
+		if err := server.loadLeases(); err != nil {
+			return nil, err
+		}
+	}
+	server.checkPoolWatermarks()
+
 	return server, nil
 }
 
+// ValidateConfig проверяет глобальные опции конфигурации так же, как
+// NewBOOTPServer, но без побочных эффектов: файлы захвата не создаются
+// и сокеты не открываются.
+func ValidateConfig(cfg *config.DHCPConfig) error {
+	effective, _, err := effectiveConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := config.Compile(effective); err != nil {
+		return err
+	}
+	if err := checkReservations(effective); err != nil {
+		return err
+	}
+	if err := checkLeaseTimers(effective); err != nil {
+		return err
+	}
+	if cfg.GlobalOptions == nil {
+		return nil
+	}
+
+	if _, err := parseGlobalOptions(cfg.GlobalOptions, effective.Subnets); err != nil {
+		return err
+	}
+	if _, err := parseCaptureOptions(cfg.GlobalOptions); err != nil {
+		return err
+	}
+	if _, err := parseAuditOptions(cfg.GlobalOptions); err != nil {
+		return err
+	}
+	if _, err := parseDNSOptions(cfg.GlobalOptions); err != nil {
+		return err
+	}
+	if _, err := parseConflictScanInterval(cfg.GlobalOptions); err != nil {
+		return err
+	}
+	if _, err := parseListenAddress(cfg.GlobalOptions); err != nil {
+		return err
+	}
+	if _, err := resolveInterfaces(parseInterfaces(cfg.GlobalOptions)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseInterfaces читает список интерфейсов, заданный через запятую:
+// interfaces "eth0, eth1";
+func parseInterfaces(options map[string]string) []string {
+	var names []string
+	for _, name := range strings.Split(strings.Trim(options["interfaces"], "\""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseListenAddress читает адрес и порт приема запросов:
+// bootp-listen "0.0.0.0:67"; По умолчанию запросы принимаются на порту 67
+// всех адресов. Порт 0 выбирает свободный порт (для тестов).
+func parseListenAddress(options map[string]string) (*net.UDPAddr, error) {
+	value := strings.Trim(options["bootp-listen"], "\"")
+	if value == "" {
+		return &net.UDPAddr{Port: BOOTP_PORT}, nil
+	}
+	addr, err := net.ResolveUDPAddr("udp4", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootp-listen %s: %v", value, err)
+	}
+	return addr, nil
+}
+
+// checkReservations проверяет, что блоки host не повторяют MAC адрес,
+// client-id или фиксированный адрес друг друга. Иначе более поздний блок
+// молча заменил бы более ранний в таблицах назначений. Фиксированный адрес
+// хоста в подсети должен принадлежать этой подсети: иначе клиент получил
+// бы опции чужой сети. Подсеть глобального хоста определяется по адресу
+// (см. loadStaticAllocations).
+func checkReservations(cfg *config.DHCPConfig) error {
+	type declaration struct {
+		host  *config.Host
+		scope string
+	}
+	byKey := make(map[string]declaration)
+	byIP := make(map[uint32]declaration)
+
+	check := func(host *config.Host, subnet *config.Subnet, scope string) error {
+		current := declaration{host: host, scope: scope}
+		if host.Hardware != "" || host.ClientID != "" {
+			key := hostKey(host)
+			if previous, exists := byKey[key]; exists {
+				return fmt.Errorf("host %s (%s) and host %s (%s) declare the same client %s",
+					previous.host.Name, previous.scope, host.Name, scope, key)
+			}
+			byKey[key] = current
+		}
+		if host.FixedIP != "" && (host.Hardware != "" || host.ClientID != "") {
+			if ip := net.ParseIP(host.FixedIP); ip != nil {
+				ipInt := ipToInt(ip)
+				if previous, exists := byIP[ipInt]; exists {
+					return fmt.Errorf("host %s (%s) and host %s (%s) reserve the same address %s",
+						previous.host.Name, previous.scope, host.Name, scope, host.FixedIP)
+				}
+				byIP[ipInt] = current
+
+				if subnet != nil && !subnetContains(subnet, ipInt) {
+					return fmt.Errorf("host %s (%s): fixed-address %s is outside of the subnet",
+						host.Name, scope, host.FixedIP)
+				}
+			}
+		}
+		return nil
+	}
+
+	for i := range cfg.Subnets {
+		subnet := &cfg.Subnets[i]
+		for j := range subnet.Hosts {
+			if err := check(&subnet.Hosts[j], subnet, "subnet "+subnet.Network.IP.String()); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range cfg.Hosts {
+		if err := check(&cfg.Hosts[i], nil, "global"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // initStaticAllocations инициализирует статические назначения IP адресов
 func (s *BOOTPServer) initStaticAllocations() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	s.loadStaticAllocations()
+}
+
+// loadStaticAllocations заполняет таблицы статическими назначениями из
+// конфигурации. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) loadStaticAllocations() {
 	// Обрабатываем статические назначения в подсетях
-	for _, subnet := range s.config.Subnets {
+	for i := range s.runtime.Subnets {
+		subnet := &s.runtime.Subnets[i]
 		for _, host := range subnet.Hosts {
-			if host.FixedIP != "" && host.Hardware != "" {
-				ip := net.ParseIP(host.FixedIP)
-				if ip != nil {
-					ipInt := ipToInt(ip)
-					mac := strings.ToLower(host.Hardware)
-					allocated := &AllocatedIP{
-						IP:      ipInt,
-						MAC:     mac,
-						Subnet:  &subnet,
-						Type:    StaticAllocation,
-						Active:  false,       // Будет активирован при первом запросе
-						Expires: time.Time{}, // Не истекает для статических адресов
-					}
-					s.allocatedIP[ipInt] = allocated
-					s.allocatedMAC[mac] = allocated
-				}
-			}
+			s.loadHost(host, subnet.Subnet)
 		}
 	}
 
 	// Обрабатываем глобальные хосты
-	for _, host := range s.config.Hosts {
-		if host.FixedIP != "" && host.Hardware != "" {
-			ip := net.ParseIP(host.FixedIP)
-			if ip != nil {
-				ipInt := ipToInt(ip)
-				mac := strings.ToLower(host.Hardware)
-				allocated := &AllocatedIP{
-					IP:      ipInt,
-					MAC:     mac,
-					Subnet:  nil,
-					Type:    StaticAllocation,
-					Active:  false,       // Будет активирован при первом запросе
-					Expires: time.Time{}, // Не истекает для статических адресов
-				}
-				s.allocatedIP[ipInt] = allocated
-				s.allocatedMAC[mac] = allocated
-			}
+	for _, host := range s.runtime.Hosts {
+		if host.FixedIP == nil {
+			s.loadHost(host, nil)
+			continue
 		}
+		subnet := subnetOf(s.config, ipToInt(host.FixedIP))
+		if subnet == nil {
+			s.logger.Warnf("Host %s: fixed-address %s is not in any declared subnet, subnet options will not be sent", host.Host.Name, host.FixedIP)
+		}
+		s.loadHost(host, subnet)
+	}
+}
+
+// loadHost добавляет блок host в таблицы известных клиентов и, если задан
+// фиксированный адрес, статическое назначение в подсети subnet.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) loadHost(compiled config.RuntimeHost, subnet *config.Subnet) {
+	host := compiled.Host
+	if host.Hardware != "" {
+		s.knownClients[normalizeMAC(host.Hardware)] = true
+	}
+	if host.ClientID != "" {
+		s.knownClients[clientKey("", host.ClientID)] = true
+	}
+	if host.Hardware == "" && host.ClientID == "" {
+		return
+	}
+	s.hosts[hostKey(host)] = host
+
+	if compiled.FixedIP != nil {
+		allocated := &AllocatedIP{
+			IP:       ipToInt(compiled.FixedIP),
+			MAC:      normalizeMAC(host.Hardware),
+			ClientID: host.ClientID,
+			Subnet:   subnet,
+			Type:     StaticAllocation,
+			Active:   false,       // Будет активирован при первом запросе
+			Expires:  time.Time{}, // Не истекает для статических адресов
+		}
+		s.allocatedIP[allocated.IP] = allocated
+		s.allocatedMAC[allocated.key()] = allocated
 	}
 }
 
 // Start запускает BOOTP сервер
 func (s *BOOTPServer) Start() error {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", BOOTP_PORT))
-	if err != nil {
+	if len(s.conns) > 0 {
+		for _, conn := range s.conns {
+			s.logger.Infof("BOOTP server listening on inherited socket %s", conn.LocalAddr())
+		}
+	} else if len(s.interfaces) == 0 {
+		conn, err := net.ListenUDP("udp", s.listen)
+		if err != nil {
+			return permissionError(err, capNetBindService, fmt.Sprintf("listening on port %d", s.listen.Port))
+		}
+		s.conns = append(s.conns, conn)
+
+		s.logger.Infof("BOOTP server listening on %s", conn.LocalAddr())
+	} else {
+		// Сокеты интерфейсов привязываются ко всем адресам, из
+		// bootp-listen используется только порт
+		for _, iface := range s.interfaces {
+			conn, err := listenUDPInterface(iface, s.listen.Port)
+			if err != nil {
+				s.Stop()
+				return err
+			}
+			s.conns = append(s.conns, conn)
+
+			s.logger.Infof("BOOTP server listening on %s:%d", iface, s.listen.Port)
+		}
+	}
+	s.conn = s.conns[0]
+	s.checkInterfaceOverrides()
+	if s.Learning() {
+		s.logger.Infof("Learning mode is on: requests are recorded and not answered")
+	}
+	for _, conn := range s.conns {
+		if err := s.socket.apply(conn); err != nil {
+			s.logger.Warnf("Failed to tune socket %s: %v", conn.LocalAddr(), err)
+		}
+	}
+
+	// Запуск обработки запросов в отдельных горутинах
+	for _, conn := range s.conns {
+		go s.handleRequests(conn)
+	}
+	if err := s.startTrunks(); err != nil {
+		s.Stop()
 		return err
 	}
 
-	s.conn, err = net.ListenUDP("udp", addr)
-	if err != nil {
+	if err := s.startConflictScan(); err != nil {
+		s.Stop()
 		return err
 	}
+	s.startLeaseReaper()
+	s.startAffinitySaver()
 
-	logrus.Infof("BOOTP server listening on %s", addr.String())
+	if s.reuse.pingCheck && !hasCapability(capNetRaw) {
+		s.logger.Warnf("ping-check requires CAP_NET_RAW, addresses will be offered without ICMP probing")
+	}
 
-	// Запуск обработки запросов в отдельной горутине
-	go s.handleRequests()
+	return nil
+}
+
+// LocalAddr возвращает адрес основного сокета (nil до Start). Позволяет
+// узнать порт, выбранный при bootp-listen с портом 0.
+func (s *BOOTPServer) LocalAddr() net.Addr {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.LocalAddr()
+}
 
+// SetInterfaces ограничивает обслуживание указанными сетевыми интерфейсами.
+// Имена могут быть шаблонами (см. resolveInterfaces), они раскрываются
+// при вызове. Должен вызываться до Start.
+func (s *BOOTPServer) SetInterfaces(names []string) error {
+	resolved, err := resolveInterfaces(names)
+	if err != nil {
+		return err
+	}
+	s.interfaces = resolved
 	return nil
 }
 
+// Interfaces возвращает интерфейсы, которыми ограничено обслуживание,
+// с раскрытыми шаблонами (пусто - все интерфейсы)
+func (s *BOOTPServer) Interfaces() []string {
+	return append([]string(nil), s.interfaces...)
+}
+
+// SetListeners задает уже открытые сокеты (например, переданные systemd),
+// которые используются вместо открытия собственных. Список интерфейсов
+// при этом не учитывается. Должен вызываться до Start.
+func (s *BOOTPServer) SetListeners(conns []*net.UDPConn) {
+	s.conns = conns
+}
+
 // Stop останавливает BOOTP сервер
 func (s *BOOTPServer) Stop() {
-	if s.conn != nil {
-		s.conn.Close()
+	s.delayed.stop()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	for _, trunk := range s.trunks {
+		trunk.Close()
+	}
+	if s.scanStop != nil {
+		close(s.scanStop)
+		s.scanStop = nil
 	}
+	if s.reapStop != nil {
+		close(s.reapStop)
+		s.reapStop = nil
+	}
+	if s.affinityStop != nil {
+		close(s.affinityStop)
+		s.affinityStop = nil
+	}
+	if s.affinity != nil {
+		s.saveAffinity()
+	}
+	s.StopPacketCapture()
+	s.audit.close()
 }
 
-// handleRequests обрабатывает входящие BOOTP запросы
-func (s *BOOTPServer) handleRequests() {
-	buffer := make([]byte, 1024)
+// BootTimeline возвращает хронологию загрузки клиента
+// (DISCOVER, ACK, скачивание загрузочного файла)
+func (s *BOOTPServer) BootTimeline(macAddr string) []BootEvent {
+	return s.timeline.Events(macAddr)
+}
+
+// RecentRequests возвращает последние события обработки запросов всех клиентов
+func (s *BOOTPServer) RecentRequests(limit int) []BootEvent {
+	return s.timeline.Recent(limit)
+}
+
+// handleRequests обрабатывает входящие BOOTP запросы на сокете conn
+func (s *BOOTPServer) handleRequests(conn *net.UDPConn) {
+	// Буфер больше максимального размера пакета, чтобы обнаруживать слишком большие пакеты
+	buffer := make([]byte, maxPacketSize+1)
+	receive := newReceiver(conn, s.connInterface(conn), s.socket.readBatch, func(n uint64) {
+		s.counters.dropped.Add(n)
+		s.logger.Debugf("Receive buffer of %s overflowed, %d requests dropped", conn.LocalAddr(), n)
+	})
 
 	for {
-		n, clientAddr, err := s.conn.ReadFromUDP(buffer)
+		n, clientAddr, local, err := receive(buffer)
 		if err != nil {
-			logrus.Errorf("Error reading UDP message: %v", err)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.Errorf("Error reading UDP message: %v", err)
 			continue
 		}
 
-		// Парсим BOOTP заголовок
-		header := &BOOTPHeader{}
-		reader := bytes.NewReader(buffer[:n])
-		err = binary.Read(reader, binary.BigEndian, header)
-		if err != nil {
-			logrus.Errorf("Error parsing BOOTP header: %v", err)
-			continue
-		}
+		s.receivePacket(conn, buffer[:n], clientAddr, local)
+	}
+}
 
-		// Обрабатываем только BOOTP запросы
-		if header.Op != BOOTPRequest {
-			continue
+// receivePacket разбирает принятый пакет data и передает запрос на
+// обработку с учетом ограничения частоты запросов
+func (s *BOOTPServer) receivePacket(conn replySender, data []byte, clientAddr *net.UDPAddr, local net.IP) {
+	s.dumpPacket(CaptureReceived, clientAddr, senderAddr(conn), data)
+
+	// Разбираем и проверяем пакет
+	packet, err := DecodePacket(data)
+	if err != nil {
+		s.logger.Debugf("Dropping malformed packet from %s: %v", clientAddr, err)
+		return
+	}
+	header := &packet.Header
+
+	// Обрабатываем только BOOTP запросы
+	if header.Op != BOOTPRequest {
+		return
+	}
+
+	// Определяем тип DHCP сообщения
+	msgType := messageType(packet.Options)
+
+	// Ограничиваем частоту запросов
+	if limiter := s.rateLimiter(); limiter != nil {
+		macAddr := chaddrToMAC(header.Chaddr, header.Hlen)
+		allowed, wait := limiter.Allow(macAddr)
+		if !allowed {
+			s.logger.Debugf("Rate limit exceeded, dropping request from %s", macAddr)
+			return
+		}
+		if wait > 0 {
+			// Опции ссылаются на буфер приема, который будет
+			// перезаписан следующим пакетом
+			packet.Options = copyOptions(packet.Options)
+			time.AfterFunc(wait, func() {
+				s.handlePacket(conn, packet, msgType, clientAddr, local)
+			})
+			return
 		}
+	}
+
+	s.handlePacket(conn, packet, msgType, clientAddr, local)
+}
 
-		// Обрабатываем запрос
-		reply := s.processRequest(header)
+// handlePacket обрабатывает разобранный запрос и отправляет ответ. local -
+// адрес интерфейса, на который пришел запрос (nil, если неизвестен).
+func (s *BOOTPServer) handlePacket(conn replySender, packet *Packet, msgType uint8, clientAddr *net.UDPAddr, local net.IP) {
+	// Сокет интерфейса (nil для запросов из VLAN транкового интерфейса)
+	udp, _ := conn.(*net.UDPConn)
+	request := &Request{Packet: packet, MessageType: msgType, ClientAddr: clientAddr, Local: local, Interface: s.connInterface(udp)}
+	if trunk, ok := conn.(*vlanReply); ok {
+		request.VLAN = trunk.vlan
+	}
+
+	s.recordRequestStage(&packet.Header, msgType)
+	s.counters.requests.Add(1)
+	vendorClass := vendorClassIndex(packet.Options)
+	s.counters.vendorRequests[vendorClass].Add(1)
 
-		// Отправляем ответ
-		var replyBuffer bytes.Buffer
-		err = binary.Write(&replyBuffer, binary.BigEndian, reply)
+	// Повтор запроса получает сохраненный ответ без повторной обработки,
+	// иначе запрос обрабатывается цепочкой обработчиков
+	key := retransmitKey{xid: packet.Header.Xid, chaddr: packet.Header.Chaddr, msgType: msgType}
+	reply := s.replies.get(key, s.clock.Now())
+	if reply != nil {
+		s.counters.retransmits.Add(1)
+		s.logger.Debugf("Answering retransmitted request from %s with saved reply", chaddrToMAC(packet.Header.Chaddr, packet.Header.Hlen))
+	} else {
+		var err error
+		reply, err = s.serveRequest(request)
 		if err != nil {
-			logrus.Errorf("Error serializing BOOTP reply: %v", err)
-			continue
+			s.logger.Warnf("Request from %s rejected: %v", chaddrToMAC(packet.Header.Chaddr, packet.Header.Hlen), err)
+		}
+		if reply == nil {
+			// DHCPRELEASE остается без ответа по протоколу
+			if msgType != DHCPRelease {
+				s.counters.ignored.Add(1)
+			}
+			return
+		}
+		if request.delay > 0 {
+			s.sendLater(conn, request, reply, vendorClass)
+			return
 		}
+		s.replies.put(key, reply, s.clock.Now())
+	}
+	s.sendReply(conn, request, reply, vendorClass)
+}
 
-		_, err = s.conn.WriteToUDP(replyBuffer.Bytes(), clientAddr)
-		if err != nil {
-			logrus.Errorf("Error sending BOOTP reply: %v", err)
+// sendReply дополняет ответ на запрос req опциями сервера и отправляет его
+func (s *BOOTPServer) sendReply(conn replySender, req *Request, reply *Packet, vendorClass int) {
+	udp, _ := conn.(*net.UDPConn)
+	packet, msgType, clientAddr, local := req.Packet, req.MessageType, req.ClientAddr, req.Local
+	if messageType(reply.Options) == DHCPNak {
+		s.timeline.Record(chaddrToMAC(reply.Header.Chaddr, reply.Header.Hlen), "", StageNak, "")
+	} else {
+		s.recordReplyStage(&reply.Header, msgType)
+
+		// Без next-server сервером загрузки считается сервер, заданный
+		// для интерфейса, или сам сервер
+		if net.IP(reply.Header.Siaddr[:]).IsUnspecified() {
+			if override := s.interfaceOverride(req.Interface); override != nil && override.nextServer != nil {
+				copy(reply.Header.Siaddr[:], override.nextServer)
+			} else if id := s.serverIdentifier(udp, &reply.Header, local); id != nil {
+				copy(reply.Header.Siaddr[:], id.To4())
+			}
 		}
 	}
+
+	// Отправляем ответ. Тип сообщения, заданный при обработке (NAK),
+	// не переопределяется
+	for code, value := range s.replyOptions(udp, &reply.Header, msgType, local) {
+		if _, exists := reply.Options[code]; !exists {
+			reply.Options[code] = value
+		}
+	}
+	// Клиент получает только запрошенные опции в порядке запроса
+	requested := packet.Options[OptionParameterList]
+	if omitted := filterRequested(reply.Options, requested, s.alwaysSendOptions()); len(omitted) > 0 {
+		s.logger.Debugf("Omitting options %v not requested by %s", omitted, chaddrToMAC(reply.Header.Chaddr, reply.Header.Hlen))
+	}
+	limit := replySizeLimit(packet.Options, s.replySize())
+	buffer := replyBuffers.Get().(*[]byte)
+	defer replyBuffers.Put(buffer)
+	data, dropped := encodeReply((*buffer)[:0], &reply.Header, reply.Options, limit, requested)
+	*buffer = data[:0]
+	if len(dropped) > 0 {
+		s.logger.Warnf("Reply to %s does not fit into %d bytes, dropped options %v",
+			chaddrToMAC(reply.Header.Chaddr, reply.Header.Hlen), limit, dropped)
+	}
+
+	if clientAddr = replyAddress(&packet.Header, clientAddr); clientAddr == nil {
+		clientAddr = broadcastAddress(s.connInterface(udp), net.IP(reply.Header.Yiaddr[:]))
+	}
+	s.dumpPacket(CaptureSent, senderAddr(conn), clientAddr, data)
+
+	if _, err := conn.WriteToUDP(data, clientAddr); err != nil {
+		s.logger.Errorf("Error sending BOOTP reply: %v", err)
+		return
+	}
+	s.counters.countReply(messageType(reply.Options))
+	if messageType(reply.Options) == DHCPOffer {
+		s.counters.vendorOffers[vendorClass].Add(1)
+	}
+}
+
+// RateLimitStats возвращает счетчики отброшенных и отложенных запросов
+func (s *BOOTPServer) RateLimitStats() RateLimitStats {
+	limiter := s.rateLimiter()
+	if limiter == nil {
+		return RateLimitStats{}
+	}
+	return limiter.Stats()
+}
+
+// recordRequestStage отмечает в хронологии получение запроса
+func (s *BOOTPServer) recordRequestStage(request *BOOTPHeader, msgType uint8) {
+	stage := StageBootRequest
+	switch msgType {
+	case DHCPDiscover:
+		stage = StageDiscover
+	case DHCPRequest:
+		stage = StageRequest
+	case DHCPInform:
+		stage = StageInform
+	}
+	s.timeline.Record(chaddrToMAC(request.Chaddr, request.Hlen), "", stage, "")
+}
+
+// recordReplyStage отмечает в хронологии отправку ответа
+func (s *BOOTPServer) recordReplyStage(reply *BOOTPHeader, msgType uint8) {
+	stage := StageBootReply
+	switch msgType {
+	case DHCPDiscover:
+		stage = StageOffer
+	case DHCPRequest, DHCPInform:
+		stage = StageAck
+	}
+	// Ответ на DHCPINFORM не содержит yiaddr, адрес клиента - ciaddr
+	ip := net.IP(reply.Yiaddr[:])
+	if ip.IsUnspecified() {
+		ip = net.IP(reply.Ciaddr[:])
+	}
+	file := fieldString(reply.File[:])
+	s.timeline.Record(chaddrToMAC(reply.Chaddr, reply.Hlen), ip.String(), stage, file)
 }
 
-// processRequest обрабатывает BOOTP запрос и формирует ответ
+// processRequest обрабатывает BOOTP запрос без DHCP опций и формирует
+// заголовок ответа
 func (s *BOOTPServer) processRequest(request *BOOTPHeader) *BOOTPHeader {
-	reply := &BOOTPHeader{}
+	reply := s.processPacket(&Packet{Header: *request})
+	if reply == nil {
+		return nil
+	}
+	return &reply.Header
+}
+
+// processPacket обрабатывает разобранный запрос цепочкой обработчиков
+// (см. Use) и формирует ответ с опциями. Используется, когда адреса
+// отправителя и интерфейса неизвестны.
+func (s *BOOTPServer) processPacket(packet *Packet) *Packet {
+	reply, err := s.serve(context.Background(), &Request{Packet: packet, MessageType: messageType(packet.Options)})
+	if err != nil {
+		s.logger.Warnf("Request from %s rejected: %v", chaddrToMAC(packet.Header.Chaddr, packet.Header.Hlen), err)
+		return nil
+	}
+	return reply
+}
+
+// newResponse создает ответ на запрос с полями, скопированными из запроса
+func newResponse(request *BOOTPHeader) *Packet {
+	response := &Packet{Options: make(map[uint8][]byte)}
+	reply := &response.Header
 
-	// Копируем поля из запроса
 	reply.Op = BOOTPReply
 	reply.Htype = request.Htype
 	reply.Hlen = request.Hlen
@@ -215,125 +846,852 @@ func (s *BOOTPServer) processRequest(request *BOOTPHeader) *BOOTPHeader {
 	reply.Secs = 0
 	reply.Flags = request.Flags
 	copy(reply.Chaddr[:], request.Chaddr[:])
+	return response
+}
 
-	// Получаем MAC адрес клиента
-	macAddr := fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
-		request.Chaddr[0], request.Chaddr[1], request.Chaddr[2],
-		request.Chaddr[3], request.Chaddr[4], request.Chaddr[5])
+// allocate выделяет клиенту адрес и формирует ответ с опциями. Последний
+// обработчик встроенной цепочки.
+func (s *BOOTPServer) allocate(ctx context.Context, req *Request) (*Packet, error) {
+	packet := req.Packet
+	request := &packet.Header
+	response := newResponse(request)
+	reply := &response.Header
 
-	// Ищем конфигурацию для клиента
-	clientIP, subnet := s.findClientConfig(macAddr)
-	if clientIP == "" {
-		logrus.Warnf("No configuration found for client %s", macAddr)
-		return nil
+	// Получаем MAC адрес и идентификатор клиента
+	macAddr := chaddrToMAC(request.Chaddr, request.Hlen)
+	clientID := clientIDString(packet.Options[OptionClientIdentifier])
+	msgType := req.MessageType
+
+	// Запрошенный адрес уже проверен (см. verifyRequestMiddleware)
+	if requested := requestedAddress(packet); msgType == DHCPRequest && requested != nil {
+		copy(reply.Ciaddr[:], request.Ciaddr[:])
+	}
+
+	// Выбираем адрес для клиента. Назначение фиксируется только после
+	// решения хука, чтобы запрет не занимал адрес в пуле. Если адрес новой
+	// аренды за это время занял другой клиент, выбор повторяется.
+	var offer *leaseOffer
+	defer func() { releaseOffer(offer) }()
+	var options map[string]string
+	var hostname, clientIP string
+	var assigned bool
+	held := heldAddress(packet)
+	for attempt := 1; ; attempt++ {
+		offer = s.selectLease(macAddr, clientID, msgType == 0, s.requestNetworks(req))
+		if offer == nil {
+			s.logger.Warnf("No configuration found for client %s", macAddr)
+			return nil, nil
+		}
+		// Клиент, подтверждающий уже выданный ему адрес, продлевает
+		// аренду: адрес не выбирается заново, хук получает отметку
+		// продления, событием аренды будет renewed
+		renewal := msgType == DHCPRequest && offer.existing != nil && offer.existing.Type == DynamicAllocation &&
+			held != nil && ipToInt(held) == offer.existing.IP
+		offer.offered = msgType != 0 && !renewal
+		if !renewal {
+			s.ipamOffer(ctx, macAddr, offer)
+			s.allocatorOffer(ctx, macAddr, packet.Options, offer)
+		}
+		if offer = s.probeOffer(macAddr, clientID, offer); offer == nil {
+			return nil, nil
+		}
+
+		// Формируем набор опций для ответа
+		options = s.clientOptions(macAddr, packet.Options, offer.subnet, offer.host)
+
+		// Запрашиваем решение у внешнего хука
+		if hook := s.allocationHook(); hook != nil {
+			hookReq := &HookRequest{MAC: macAddr, IP: intToIP(offer.ip).String(), Options: options, Renewal: renewal}
+			if offer.subnet != nil {
+				hookReq.Subnet = offer.subnet.Network.IP.String()
+			}
+
+			decision, ok := s.evaluateHook(ctx, hook, hookReq)
+			if !ok {
+				return nil, nil
+			}
+
+			if decision != nil {
+				if decision.IP != "" && decision.IP != hookReq.IP {
+					if renewal {
+						s.logger.Debugf("Allocation hook address %s for %s ignored, client renews %s", decision.IP, macAddr, hookReq.IP)
+					} else if err := s.reassignIP(macAddr, offer, decision.IP); err != nil {
+						s.logger.Warnf("Allocation hook requested %s for %s: %v", decision.IP, macAddr, err)
+						return nil, nil
+					}
+				}
+				for key, value := range decision.Options {
+					options[key] = value
+				}
+			}
+		}
+
+		// Адрес клиента окончательно выбран, вычисляем выражения в опциях
+		s.evaluateOptions(options, requestContext(request, packet.Options, intToIP(offer.ip)))
+
+		// Срок аренды зависит от уровней конфигурации клиента
+		if msgType != 0 {
+			offer.lease = s.randomizedLease(macAddr, s.leaseDuration(options, packet.Options[OptionLeaseTime]))
+		}
+
+		// Предложение адреса и ответ BOOTP клиенту могут откладываться
+		if msgType == DHCPDiscover || msgType == 0 {
+			req.delay = s.replyDelay(options)
+		}
+
+		// Определяем имя хоста клиента
+		hostname, assigned = s.clientHostname(offer, options, packet.Options[OptionHostName])
+		offer.hostname = hostname
+
+		// Ответ после истечения request-timeout будет отброшен, адрес
+		// не занимается
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Фиксируем назначение
+		offer.client = requestClientInfo(packet.Options)
+		if clientIP, _ = s.commitLease(macAddr, offer); clientIP != "" {
+			break
+		}
+		if offer.existing != nil || attempt == maxAllocAttempts {
+			s.logger.Warnf("Address %s for %s was taken by another client", intToIP(offer.ip), macAddr)
+			return nil, nil
+		}
+		s.logger.Debugf("Address %s for %s was taken by another client, retrying", intToIP(offer.ip), macAddr)
+		releaseOffer(offer)
 	}
+	exprCtx := requestContext(request, packet.Options, intToIP(offer.ip))
 
 	// Устанавливаем IP адреса
 	copy(reply.Yiaddr[:], net.ParseIP(clientIP).To4())
 
+	// Устанавливаем адрес и имя сервера загрузки и имя файла загрузки.
+	// Адрес самого DHCP сервера передается отдельно в опции 54
+	boot := s.nextServer(s.evaluateBoot(s.bootParameters(macAddr, packet.Options, offer.subnet, offer.host, options), exprCtx))
+	if err := setBootParameters(response, boot); err != nil {
+		return nil, err
+	}
+	// BOOTP клиент не читает опцию 67 и не узнает длинное имя файла
+	if msgType == 0 && boot.Filename != "" && fieldString(reply.File[:]) == "" {
+		s.logger.Warnf("Boot file name for BOOTP client %s does not fit into the file field, it is sent only in option 67", macAddr)
+	}
+
+	// Срок аренды и таймеры продления передаются только DHCP клиентам
+	if msgType != 0 {
+		for code, value := range leaseTimeOptions(offer.lease, options) {
+			response.Options[code] = value
+		}
+	}
+
+	// Имя хоста передается, только если его назначил сервер
+	if assigned {
+		response.Options[OptionHostName] = []byte(hostname)
+	}
+	s.setConfigOptions(response, options, offer.subnet)
+
+	// Устанавливаем magic cookie
+	reply.Magic = magicCookie
+
+	return response, nil
+}
+
+// clientOptions собирает DHCP опции клиента по цепочке наследования:
+// глобальные, подсети, классов клиента и хоста. Каждый следующий уровень
+// переопределяет значения предыдущего. request - опции запроса клиента
+// для классов с match if.
+func (s *BOOTPServer) clientOptions(macAddr string, request map[uint8][]byte, subnet *config.Subnet, host *config.Host) map[string]string {
+	macAddr = normalizeMAC(macAddr)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	options := make(map[string]string)
+	merge := func(values map[string]string) {
+		for key, value := range values {
+			options[key] = value
+		}
+	}
+
+	merge(s.config.Options)
 	if subnet != nil {
-		// Устанавливаем адрес сервера
-		if nextServer, ok := subnet.Options["tftp-server-name"]; ok {
-			copy(reply.Siaddr[:], net.ParseIP(nextServer).To4())
+		merge(subnet.Options)
+	}
+	for _, class := range s.clientClasses(macAddr, request) {
+		merge(class.Options)
+	}
+	if host != nil {
+		merge(host.Options)
+	}
+
+	return options
+}
+
+// bootParameters определяет сервер загрузки и имя загрузочного файла.
+// Операторы next-server, server-name и filename наследуются по цепочке
+// глобальные → подсеть → классы клиента → хост. Если server-name или filename не заданы ни
+// на одном уровне, используется опция tftp-server-name или bootfile-name.
+// next-server задается оператором или, при resolve-server-name on, по
+// имени сервера (см. nextServer). Без next-server siaddr заполняется
+// адресом сервера при отправке ответа (см. handlePacket).
+func (s *BOOTPServer) bootParameters(macAddr string, request map[uint8][]byte, subnet *config.Subnet, host *config.Host, options map[string]string) config.BootParams {
+	macAddr = normalizeMAC(macAddr)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	boot := config.BootParams{}
+	merge := func(values config.BootParams) {
+		if values.NextServer != "" {
+			boot.NextServer = values.NextServer
 		}
+		if values.ServerName != "" {
+			boot.ServerName = values.ServerName
+		}
+		if values.Filename != "" {
+			boot.Filename = values.Filename
+		}
+	}
 
-		// Устанавливаем имя файла загрузки
-		if bootfile, ok := subnet.Options["bootfile-name"]; ok {
-			copy(reply.File[:], []byte(bootfile))
+	merge(s.config.Boot)
+	if subnet != nil {
+		merge(subnet.Boot)
+	}
+	for _, class := range s.clientClasses(macAddr, request) {
+		merge(class.Boot)
+	}
+	if host != nil {
+		merge(host.Boot)
+	}
+
+	if boot.ServerName == "" {
+		boot.ServerName = options["tftp-server-name"]
+	}
+	if boot.Filename == "" {
+		boot.Filename = options["bootfile-name"]
+	}
+
+	return boot
+}
+
+// nextServer при глобальной опции resolve-server-name on задает адрес
+// сервера загрузки по его имени (server-name или tftp-server-name), если
+// next-server не задан: адрес IPv4 используется как есть, имя разрешается
+// через DNS. Результат разрешения сохраняется до перезагрузки конфигурации.
+func (s *BOOTPServer) nextServer(boot config.BootParams) config.BootParams {
+	if boot.NextServer != "" || boot.ServerName == "" {
+		return boot
+	}
+
+	s.mutex.Lock()
+	value, enabled := s.config.GlobalOptions["resolve-server-name"]
+	ip, resolved := s.serverNames[boot.ServerName]
+	s.mutex.Unlock()
+	if !enabled || (value != "" && !isEnabled(value)) {
+		return boot
+	}
+	if literal := net.ParseIP(boot.ServerName).To4(); literal != nil {
+		boot.NextServer = literal.String()
+		return boot
+	}
+
+	if !resolved {
+		// Имя разрешается без блокировки, чтобы не задерживать другие запросы
+		if ip = s.lookup(boot.ServerName); ip == nil {
+			s.logger.Warnf("Server name %s has no IPv4 address, next-server is not set", boot.ServerName)
 		}
+		s.mutex.Lock()
+		s.serverNames[boot.ServerName] = ip
+		s.mutex.Unlock()
 	}
+	if ip != nil {
+		boot.NextServer = ip.String()
+	}
+	return boot
+}
 
-	// Устанавливаем magic cookie
-	reply.Magic = [4]byte{99, 130, 83, 99}
+// serverNameTimeout ограничивает время разрешения имени сервера загрузки
+const serverNameTimeout = 2 * time.Second
 
-	return reply
+// lookupIPv4 возвращает первый адрес IPv4 имени (nil - не найден)
+func lookupIPv4(name string) net.IP {
+	ctx, cancel := context.WithTimeout(context.Background(), serverNameTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", name)
+	if err != nil || len(addrs) == 0 {
+		return nil
+	}
+	return addrs[0].To4()
+}
+
+// setBootParameters заполняет siaddr, sname и file ответа и дублирует имя
+// сервера и файла загрузки в опциях 66 и 67: одни PXE ROM читают только
+// поля заголовка, другие только опции. Имя, не помещающееся в поле
+// заголовка вместе с завершающим нулем, передается только опцией. Имя,
+// не помещающееся и в опцию, или с нулевым байтом (значение выражения)
+// не обрезается, а возвращается ошибка.
+func setBootParameters(response *Packet, boot config.BootParams) error {
+	if err := checkBootName("server name", boot.ServerName); err != nil {
+		return err
+	}
+	if err := checkBootName("boot file name", boot.Filename); err != nil {
+		return err
+	}
+
+	reply := &response.Header
+	if boot.NextServer != "" {
+		copy(reply.Siaddr[:], net.ParseIP(boot.NextServer).To4())
+	}
+	if boot.ServerName != "" {
+		if len(boot.ServerName) < len(reply.Sname) {
+			copy(reply.Sname[:], []byte(boot.ServerName))
+		}
+		response.Options[OptionTFTPServerName] = []byte(boot.ServerName)
+	}
+	if boot.Filename != "" {
+		if len(boot.Filename) < len(reply.File) {
+			copy(reply.File[:], []byte(boot.Filename))
+		}
+		response.Options[OptionBootfileName] = []byte(boot.Filename)
+	}
+	return nil
+}
+
+// maxBootNameLen наибольшая длина имени в опциях 66 и 67
+const maxBootNameLen = 255
+
+// checkBootName проверяет, что имя сервера или файла загрузки можно
+// передать клиенту без искажения
+func checkBootName(kind, name string) error {
+	if len(name) > maxBootNameLen {
+		return fmt.Errorf("%s is %d bytes long, at most %d bytes fit into a DHCP option", kind, len(name), maxBootNameLen)
+	}
+	if strings.IndexByte(name, 0) >= 0 {
+		return fmt.Errorf("%s %q contains a NUL byte", kind, name)
+	}
+	return nil
+}
+
+// setConfigOptions добавляет в ответ опции конфигурации, которые сервер
+// передает клиентам: стандартные опции (routers, domain-name-servers и
+// другие), опции, объявленные с типом (option provisioning-url code 224 =
+// text;),
+// и опции пространства vendor-option-space в опции 43. Маска и
+// широковещательный адрес подсети клиента передаются, даже если не заданы
+// опциями.
+func (s *BOOTPServer) setConfigOptions(response *Packet, options map[string]string, subnet *config.Subnet) {
+	if mask, broadcast := subnetBroadcast(subnet); mask != nil {
+		response.Options[OptionSubnetMask] = mask
+		response.Options[OptionBroadcastAddress] = broadcast
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	space := options[config.VendorOptionSpace]
+	var vendor map[uint8][]byte
+	for name, value := range options {
+		definition, ok := config.LookupOption(s.config, name)
+		if !ok || value == "" {
+			continue
+		}
+		// Значения выражений проверяются только при отправке
+		data, err := config.EncodeOption(definition.Type, value)
+		if err != nil {
+			s.logger.Warnf("Option %s is not sent: %v", name, err)
+			continue
+		}
+
+		prefix, _, inSpace := strings.Cut(name, ".")
+		switch {
+		case !inSpace:
+			response.Options[definition.Code] = data
+		case prefix == space:
+			if vendor == nil {
+				vendor = make(map[uint8][]byte)
+			}
+			vendor[definition.Code] = data
+		}
+	}
+	if len(vendor) > 0 {
+		// Подопции в порядке кодов, без завершающей End
+		var data []byte
+		for _, code := range optionCodes(vendor) {
+			data = appendOption(data, code, vendor[code])
+		}
+		response.Options[OptionVendorSpecific] = data
+	}
+}
+
+// subnetBroadcast возвращает маску и широковещательный адрес подсети
+// (nil, если подсеть не задана или не IPv4)
+func subnetBroadcast(subnet *config.Subnet) (net.IP, net.IP) {
+	if subnet == nil || subnet.Network == nil {
+		return nil, nil
+	}
+	mask := net.IP(subnet.Network.Mask).To4()
+	network := subnet.Network.IP.To4()
+	if mask == nil || network == nil {
+		return nil, nil
+	}
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = network[i] | ^mask[i]
+	}
+	return append(net.IP(nil), mask...), broadcast
+}
+
+// requestContext собирает данные запроса для вычисления выражений
+func requestContext(request *BOOTPHeader, options map[uint8][]byte, ip net.IP) config.ExprContext {
+	hlen := int(request.Hlen)
+	if hlen > len(request.Chaddr) {
+		hlen = len(request.Chaddr)
+	}
+	hardware := append([]byte{request.Htype}, request.Chaddr[:hlen]...)
+	info := requestClientInfo(options)
+	return config.ExprContext{
+		Hardware:      hardware,
+		LeasedAddress: ip,
+		Arch:          info.arch,
+		Hostname:      string(options[OptionHostName]),
+		ClientID:      info.clientID,
+		VendorClass:   info.vendorClass,
+	}
+}
+
+// evaluateOptions заменяет выражения в значениях опций их значениями для
+// запроса
+func (s *BOOTPServer) evaluateOptions(options map[string]string, ctx config.ExprContext) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, value := range options {
+		if expr, ok := s.runtime.Expressions[value]; ok {
+			options[key] = string(expr.Evaluate(ctx))
+		}
+	}
+}
+
+// evaluateBoot вычисляет выражения в server-name и filename
+func (s *BOOTPServer) evaluateBoot(boot config.BootParams, ctx config.ExprContext) config.BootParams {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if expr, ok := s.runtime.Expressions[boot.ServerName]; ok {
+		boot.ServerName = string(expr.Evaluate(ctx))
+	}
+	if expr, ok := s.runtime.Expressions[boot.Filename]; ok {
+		boot.Filename = string(expr.Evaluate(ctx))
+	}
+	return boot
+}
+
+// leaseOffer описывает выбранный для клиента адрес, еще не
+// зафиксированный в таблицах назначений
+type leaseOffer struct {
+	ip       uint32         // Выбранный IP адрес
+	subnet   *config.Subnet // Подсеть адреса
+	existing *AllocatedIP   // Существующее назначение клиента (nil - новая аренда)
+	key      string         // Ключ назначения в allocatedMAC
+	clientID string         // Идентификатор клиента для новой аренды
+	host     *config.Host   // Блок host клиента (может быть nil)
+	bootp    bool           // Запрос BOOTP клиента (без типа DHCP сообщения)
+	hostname string         // Имя хоста для записи в назначение
+	lease    time.Duration  // Срок аренды DHCP клиента (0 - глобальный)
+	offered  bool           // DHCP запрос, не продлевающий аренду: назначение уже объявлено при выдаче
+
+	pool *subnetPool // Пул, удерживающий адрес новой аренды (nil - не удерживается)
+	held uint32      // Удерживаемый адрес (ip может смениться по решению IPAM или хука)
+
+	client clientInfo // Сведения о клиенте из запроса для записи в назначение
+
+	networks []net.IP // Адреса, подсетями которых ограничен выбор (nil - любая подсеть)
 }
 
 // findClientConfig находит конфигурацию для клиента по MAC адресу
+// и сразу фиксирует назначение
 func (s *BOOTPServer) findClientConfig(macAddr string) (string, *config.Subnet) {
-	macAddr = strings.ToLower(macAddr)
+	offer := s.selectLease(macAddr, "", false, nil)
+	if offer == nil {
+		return "", nil
+	}
+	defer releaseOffer(offer)
+	return s.commitLease(macAddr, offer)
+}
+
+// selectLease выбирает адрес для клиента, не занимая его. Назначение
+// ищется по идентификатору клиента (опция 61), а при его отсутствии или
+// для резервирования по MAC адресу - по MAC адресу. Флаг bootp отмечает
+// запрос без типа DHCP сообщения. Если networks не nil, адрес выбирается
+// только в подсетях, содержащих эти адреса (запрос из VLAN транкового
+// интерфейса или с интерфейса с interface-subnets). Возвращает nil, если
+// выдать адрес нельзя.
+// Адрес новой аренды удерживается за клиентом до releaseOffer.
+func (s *BOOTPServer) selectLease(macAddr, clientID string, bootp bool, networks []net.IP) *leaseOffer {
+	macAddr = normalizeMAC(macAddr)
+	key := clientKey(macAddr, clientID)
+
+	// Существующее назначение выбирается под мьютексом, свободный адрес
+	// ищется без него
+	s.mutex.Lock()
+	offer, search := s.selectAllocation(macAddr, clientID, bootp, networks)
+	host := s.hosts[key]
+	if host == nil {
+		host = s.hosts[macAddr]
+	}
+	s.mutex.Unlock()
+
+	if search {
+		offer = s.selectDynamicIP(macAddr, clientID, bootp, networks)
+	}
+	if offer == nil {
+		return nil
+	}
+	offer.bootp = bootp
+	offer.networks = networks
+	offer.host = host
+
+	offer.key = key
+	offer.clientID = clientID
+	if offer.existing != nil {
+		offer.key = offer.existing.key()
+		offer.clientID = offer.existing.ClientID
+	}
+	return offer
+}
+
+// lookupAllocation находит назначение клиента: по client-id, а если его
+// нет - статическое резервирование по MAC адресу. Клиент без client-id
+// ищется по MAC адресу. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) lookupAllocation(macAddr, clientID string) *AllocatedIP {
+	if allocated, exists := s.allocatedMAC[clientKey(macAddr, clientID)]; exists {
+		return allocated
+	}
+	if allocated, exists := s.allocatedMAC[macAddr]; exists && (clientID == "" || allocated.Type == StaticAllocation) {
+		return allocated
+	}
+	return nil
+}
+
+// selectAllocation выбирает существующее назначение клиента. search
+// сообщает, что назначения нет и клиенту можно выдать свободный адрес
+// (см. selectDynamicIP). Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) selectAllocation(macAddr, clientID string, bootp bool, networks []net.IP) (offer *leaseOffer, search bool) {
+	// Проверяем глобальные правила доступа
+	if !s.isPermitted(macAddr, clientID, &s.config.Access) {
+		s.logger.Infof("Client %s denied by global access rules", macAddr)
+		return nil, false
+	}
+
+	allocated := s.lookupAllocation(macAddr, clientID)
 
 	// Проверяем статические назначения
+	if allocated != nil && allocated.Type == StaticAllocation {
+		if allocated.Subnet != nil && !s.isPermitted(macAddr, clientID, &allocated.Subnet.Access) {
+			s.logger.Infof("Client %s denied by access rules of subnet %s", macAddr, allocated.Subnet.Network.IP)
+			return nil, false
+		}
+		if bootp && !s.bootpAllowed(allocated.Subnet) {
+			s.logger.Infof("BOOTP client %s denied by deny bootp", macAddr)
+			return nil, false
+		}
+		if !inNetwork(allocated.Subnet, networks) {
+			s.logger.Infof("Reserved address %s of client %s is not in the subnets of %v", intToIP(allocated.IP), macAddr, networks)
+			return nil, false
+		}
+		return &leaseOffer{ip: allocated.IP, subnet: allocated.Subnet, existing: allocated}, false
+	}
+
+	// Проверяем динамические назначения
+	if allocated != nil && allocated.Type == DynamicAllocation {
+		switch {
+		case bootp && !s.bootpAllowed(allocated.Subnet):
+			// Аренда, полученная по DHCP, сохраняется для следующих DHCP запросов
+			s.logger.Infof("BOOTP client %s denied by deny bootp", macAddr)
+			return nil, false
+		case allocated.Subnet != nil && !s.isPermitted(macAddr, clientID, &allocated.Subnet.Access):
+			// Правила подсети больше не разрешают клиента - аренда не продлевается,
+			// клиент может получить адрес в другой подсети
+			s.logger.Infof("Client %s no longer permitted in subnet %s, dropping lease %s",
+				macAddr, allocated.Subnet.Network.IP, intToIP(allocated.IP))
+			delete(s.allocatedIP, allocated.IP)
+			delete(s.allocatedMAC, allocated.key())
+			allocated.Active = false
+			s.publishLeaseEvent(LeaseReleased, allocated)
+		case !inNetwork(allocated.Subnet, networks):
+			// Клиент перешел в другую VLAN или сеть другого интерфейса -
+			// адрес в прежней подсети ему больше не подходит
+			s.logger.Infof("Client %s moved out of subnets of %v, dropping lease %s",
+				macAddr, networks, intToIP(allocated.IP))
+			delete(s.allocatedIP, allocated.IP)
+			delete(s.allocatedMAC, allocated.key())
+			allocated.Active = false
+			s.publishLeaseEvent(LeaseReleased, allocated)
+		case allocated.Expires.IsZero() || s.reuse.held(allocated, s.clock.Now()):
+			// Действующая аренда или истекшая, но еще удерживаемая за клиентом
+			return &leaseOffer{ip: allocated.IP, subnet: allocated.Subnet, existing: allocated}, false
+		default:
+			// Если срок удержания истек, удаляем запись
+			delete(s.allocatedIP, allocated.IP)
+			delete(s.allocatedMAC, allocated.key())
+			allocated.Active = false
+			s.publishLeaseEvent(LeaseExpired, allocated)
+		}
+	}
+
+	// В режиме вывода из эксплуатации новые адреса не выдаются
+	if s.draining {
+		s.logger.Infof("Server is draining, not allocating address for %s", macAddr)
+		return nil, false
+	}
+
+	return nil, true
+}
+
+// commitLease фиксирует выбранное назначение: активирует статический адрес,
+// продлевает или создает динамическую аренду. Возвращает пустую строку,
+// если за время принятия решения адрес занял другой клиент.
+func (s *BOOTPServer) commitLease(macAddr string, offer *leaseOffer) (string, *config.Subnet) {
+	macAddr = normalizeMAC(macAddr)
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if allocated, exists := s.allocatedMAC[macAddr]; exists && allocated.Type == StaticAllocation {
-		// Активируем статический адрес
-		allocated.Active = true
+	if allocated := offer.existing; allocated != nil {
+		// Назначение могло быть освобождено, пока принималось решение
+		if s.allocatedMAC[offer.key] != allocated {
+			return "", nil
+		}
+		// Клиент с client-id может сменить MAC адрес (случайные MAC)
+		if allocated.ClientID != "" {
+			allocated.MAC = macAddr
+		}
+		if offer.hostname != "" {
+			allocated.Hostname = offer.hostname
+		}
+		if allocated.IP != offer.ip {
+			if s.isIPAllocated(offer.ip) {
+				return "", nil
+			}
+			delete(s.allocatedIP, allocated.IP)
+			allocated.IP = offer.ip
+			s.allocatedIP[offer.ip] = allocated
+		}
+
+		allocated.BOOTP = offer.bootp
+		offer.client.apply(allocated)
+		switch {
+		case allocated.Type == StaticAllocation && !allocated.Active:
+			// Активируем статический адрес. Без продления он снова
+			// станет неактивным по истечении срока аренды
+			allocated.Active = true
+			allocated.Expires = s.leaseExpiry(offer, s.clock.Now())
+			s.publishLeaseEvent(LeaseAllocated, allocated)
+		case allocated.Type == StaticAllocation:
+			allocated.Expires = s.leaseExpiry(offer, s.clock.Now())
+			s.publishLeaseEvent(LeaseRenewed, allocated)
+		case allocated.Type == DynamicAllocation && offer.offered:
+			// Повторный DHCPDISCOVER или DHCPREQUEST в состоянии SELECTING
+			// завершают выдачу, о которой уже объявлено событием allocated:
+			// срок аренды только сохраняется в хранилище
+			now := s.clock.Now()
+			allocated.Expires = s.leaseExpiry(offer, now)
+			s.saveLease(LeaseEvent{Type: LeaseAllocated, Time: now, Lease: newLease(allocated, now)})
+		case allocated.Type == DynamicAllocation:
+			// Продлеваем аренду
+			allocated.Expires = s.leaseExpiry(offer, s.clock.Now())
+			s.publishLeaseEvent(LeaseRenewed, allocated)
+		default:
+			s.publishLeaseEvent(LeaseRenewed, allocated)
+		}
 		return intToIP(allocated.IP).String(), allocated.Subnet
 	}
 
-	// Проверяем динамические назначения
-	if allocated, exists := s.allocatedMAC[macAddr]; exists && allocated.Type == DynamicAllocation {
-		// Проверяем, не истек ли срок действия
-		if allocated.Expires.IsZero() || allocated.Expires.After(time.Now()) {
-			// Продлеваем аренду
-			allocated.Expires = time.Now().Add(1 * time.Hour)
-			return intToIP(allocated.IP).String(), allocated.Subnet
-		}
-		// Если срок истек, удаляем запись
-		delete(s.allocatedIP, allocated.IP)
-		delete(s.allocatedMAC, macAddr)
-	}
-
-	// Реализовать динамическое назначение IP адресов
-	return s.allocateDynamicIP(macAddr)
-}
-
-// allocateDynamicIP выделяет динамический IP адрес для клиента
-func (s *BOOTPServer) allocateDynamicIP(macAddr string) (string, *config.Subnet) {
-	macAddr = strings.ToLower(macAddr)
-
-	// Ищем свободный IP адрес в подсетях с диапазонами
-	for _, subnet := range s.config.Subnets {
-		if subnet.RangeStart != "" && subnet.RangeEnd != "" {
-			startIP := net.ParseIP(subnet.RangeStart)
-			endIP := net.ParseIP(subnet.RangeEnd)
-
-			if startIP != nil && endIP != nil {
-				// Ищем первый свободный IP в диапазоне
-				for ip := ipToInt(startIP); ip <= ipToInt(endIP); ip++ {
-					// Проверяем, не занят ли этот IP
-					if !s.isIPAllocated(ip) {
-						// Найден свободный IP, выделяем его
-						allocated := &AllocatedIP{
-							IP:      ip,
-							MAC:     macAddr,
-							Subnet:  &subnet,
-							Type:    DynamicAllocation,
-							Active:  true,
-							Expires: time.Now().Add(1 * time.Hour), // 1 час аренды
-						}
-						s.allocatedIP[ip] = allocated
-						s.allocatedMAC[macAddr] = allocated
-						return intToIP(ip).String(), &subnet
-					}
-				}
+	// Новая аренда: адрес или клиент могли быть заняты параллельным запросом
+	if s.isIPAllocated(offer.ip) {
+		return "", nil
+	}
+	if _, exists := s.allocatedMAC[offer.key]; exists {
+		return "", nil
+	}
+
+	allocated := &AllocatedIP{
+		IP:       offer.ip,
+		MAC:      macAddr,
+		ClientID: offer.clientID,
+		Hostname: offer.hostname,
+		Subnet:   offer.subnet,
+		Type:     DynamicAllocation,
+		Active:   true,
+		Expires:  s.leaseExpiry(offer, s.clock.Now()),
+		BOOTP:    offer.bootp,
+	}
+	offer.client.apply(allocated)
+	s.allocatedIP[offer.ip] = allocated
+	s.allocatedMAC[offer.key] = allocated
+	s.publishLeaseEvent(LeaseAllocated, allocated)
+	s.checkPoolWatermark(offer.ip)
+
+	return intToIP(offer.ip).String(), offer.subnet
+}
+
+// reassignIP заменяет адрес в еще не зафиксированном назначении.
+// Статические резервирования не переносятся, новый адрес должен
+// принадлежать подсети клиента, не быть исключен из выдачи и не быть
+// занят другим клиентом.
+func (s *BOOTPServer) reassignIP(macAddr string, offer *leaseOffer, ipAddr string) error {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid address")
+	}
+	ipInt := ipToInt(ip)
+	macAddr = normalizeMAC(macAddr)
+
+	if offer.existing != nil && offer.existing.Type == StaticAllocation {
+		return fmt.Errorf("client has a static reservation %s", intToIP(offer.existing.IP))
+	}
+	if !subnetContains(offer.subnet, ipInt) {
+		return fmt.Errorf("address is outside of the client subnet")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.excluded(ipInt) {
+		return fmt.Errorf("address is excluded")
+	}
+	// Адрес чужого статического назначения или активной аренды не отдаем
+	if existing, exists := s.allocatedIP[ipInt]; exists && existing.key() != clientKey(macAddr, offer.clientID) {
+		if existing.Type == StaticAllocation || s.isIPAllocated(ipInt) {
+			return fmt.Errorf("address is in use by %s", existing.MAC)
+		}
+	}
+
+	offer.ip = ipInt
+	return nil
+}
+
+// excluded проверяет, исключен ли адрес из выдачи оператором exclude.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) excluded(ip uint32) bool {
+	for i := range s.runtime.Subnets {
+		for _, exclusion := range s.runtime.Subnets[i].Exclusions {
+			if ip >= ipToInt(exclusion.Start) && ip <= ipToInt(exclusion.End) {
+				return true
 			}
 		}
 	}
+	return false
+}
+
+// subnetContains проверяет, что адрес является адресом узла подсети
+// (не совпадает с адресом сети и широковещательным адресом)
+func subnetContains(subnet *config.Subnet, ip uint32) bool {
+	if subnet == nil || subnet.Network == nil {
+		return false
+	}
+	mask := ipToInt(net.IP(subnet.Network.Mask))
+	base := ipToInt(subnet.Network.IP) & mask
+	return ip&mask == base && ip != base && ip != base|^mask
+}
 
-	// Не найдено свободных IP адресов
-	return "", nil
+// subnetOf возвращает подсеть конфигурации, которой принадлежит адрес
+// (nil - адрес вне подсетей)
+func subnetOf(cfg *config.DHCPConfig, ip uint32) *config.Subnet {
+	for i := range cfg.Subnets {
+		if subnetContains(&cfg.Subnets[i], ip) {
+			return &cfg.Subnets[i]
+		}
+	}
+	return nil
 }
 
 // isIPAllocated проверяет, занят ли IP адрес
 func (s *BOOTPServer) isIPAllocated(ip uint32) bool {
 	if allocated, exists := s.allocatedIP[ip]; exists {
-		// Для статических адресов проверяем активность
+		// Статический адрес занят, пока клиент продлевает его
 		if allocated.Type == StaticAllocation {
+			if allocated.Active && !allocated.Expires.IsZero() && !allocated.Expires.After(s.clock.Now()) {
+				allocated.Active = false
+				s.publishLeaseEvent(LeaseExpired, allocated)
+			}
 			return allocated.Active
 		}
-		// Для динамических адресов проверяем срок аренды
-		if !allocated.Expires.IsZero() && allocated.Expires.Before(time.Now()) {
-			// Срок аренды истек, удаляем запись
+		// Для динамических адресов проверяем срок аренды с учетом удержания
+		if !allocated.Expires.IsZero() && !s.reuse.held(allocated, s.clock.Now()) {
+			// Срок аренды и удержания истек, удаляем запись
 			delete(s.allocatedIP, ip)
-			delete(s.allocatedMAC, allocated.MAC)
+			delete(s.allocatedMAC, allocated.key())
+			allocated.Active = false
+			s.publishLeaseEvent(LeaseExpired, allocated)
 			return false
 		}
 		return true
 	}
-	return false
+	return s.conflicted(ip, s.clock.Now())
+}
+
+// chaddrToMAC форматирует первые hlen байт аппаратного адреса клиента.
+// Нулевая длина трактуется как адрес Ethernet.
+func chaddrToMAC(chaddr [16]byte, hlen uint8) string {
+	if hlen == 0 {
+		hlen = 6
+	}
+	if int(hlen) > len(chaddr) {
+		hlen = uint8(len(chaddr))
+	}
+
+	const digits = "0123456789abcdef"
+	mac := make([]byte, 0, 3*int(hlen))
+	for i := 0; i < int(hlen); i++ {
+		if i > 0 {
+			mac = append(mac, ':')
+		}
+		mac = append(mac, digits[chaddr[i]>>4], digits[chaddr[i]&0x0f])
+	}
+	return string(mac)
+}
+
+// clientKey возвращает ключ назначения: идентификатор клиента, если он
+// задан, иначе MAC адрес
+func clientKey(macAddr, clientID string) string {
+	if clientID != "" {
+		return "id:" + clientID
+	}
+	return macAddr
+}
+
+// key возвращает ключ назначения в таблице allocatedMAC
+func (a *AllocatedIP) key() string {
+	return clientKey(a.MAC, a.ClientID)
+}
+
+// hostKey возвращает ключ блока host: client-id, если он задан, иначе MAC адрес
+func hostKey(host *config.Host) string {
+	return clientKey(normalizeMAC(host.Hardware), host.ClientID)
+}
+
+// clientIDString форматирует идентификатор клиента (опция 61) в виде
+// шестнадцатеричных байт через двоеточие, как в конфигурации
+func clientIDString(value []byte) string {
+	if len(value) == 0 {
+		return ""
+	}
+	parts := make([]string, len(value))
+	for i, b := range value {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// normalizeMAC приводит MAC адрес к каноническому виду. Некорректный
+// адрес возвращается в нижнем регистре: он не совпадет ни с одной записью.
+func normalizeMAC(addr string) string {
+	if mac, err := config.NormalizeMAC(addr); err == nil {
+		return mac
+	}
+	return strings.ToLower(addr)
 }
 
 // Вспомогательные функции для работы с IP адресами
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,10 +13,8 @@ import (
 func TestFindClientConfig(t *testing.T) {
 	// Создаем тестовую конфигурацию
 	subnet := config.Subnet{
-		Network:    "192.168.1.0",
-		Netmask:    "255.255.255.0",
-		RangeStart: "192.168.1.100",
-		RangeEnd:   "192.168.1.200",
+		Network: config.MustParseNetwork("192.168.1.0/24"),
+		Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
 		Hosts: []config.Host{
 			{
 				Name:     "client1",
@@ -73,10 +72,8 @@ func TestFindClientConfig(t *testing.T) {
 func TestProcessRequest(t *testing.T) {
 	// Создаем тестовую конфигурацию
 	subnet := config.Subnet{
-		Network:    "192.168.1.0",
-		Netmask:    "255.255.255.0",
-		RangeStart: "192.168.1.100",
-		RangeEnd:   "192.168.1.200",
+		Network: config.MustParseNetwork("192.168.1.0/24"),
+		Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
 		Options: map[string]string{
 			"tftp-server-name": "192.168.1.10",
 			"bootfile-name":    "pxelinux.0",
@@ -139,10 +136,12 @@ func TestProcessRequest(t *testing.T) {
 		t.Errorf("Expected yiaddr %v, got %v", expectedIP, reply.Yiaddr[:])
 	}
 
-	// Проверяем IP адрес сервера
-	expectedServerIP := net.ParseIP("192.168.1.10").To4()
-	if !bytes.Equal(reply.Siaddr[:], expectedServerIP) {
-		t.Errorf("Expected siaddr %v, got %v", expectedServerIP, reply.Siaddr[:])
+	// Имя TFTP сервера попадает в sname, siaddr без next-server не заполняется
+	if sname := string(bytes.Trim(reply.Sname[:], "\x00")); sname != "192.168.1.10" {
+		t.Errorf("Expected sname 192.168.1.10, got %s", sname)
+	}
+	if !net.IP(reply.Siaddr[:]).Equal(net.IPv4zero) {
+		t.Errorf("Expected empty siaddr, got %v", reply.Siaddr[:])
 	}
 
 	// Проверяем имя файла загрузки
@@ -161,10 +160,8 @@ func TestProcessRequest(t *testing.T) {
 func TestDynamicAllocation(t *testing.T) {
 	// Создаем тестовую конфигурацию с диапазоном IP адресов
 	subnet := config.Subnet{
-		Network:    "192.168.1.0",
-		Netmask:    "255.255.255.0",
-		RangeStart: "192.168.1.100",
-		RangeEnd:   "192.168.1.102",
+		Network: config.MustParseNetwork("192.168.1.0/24"),
+		Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.102"}},
 	}
 
 	cfg := &config.DHCPConfig{
@@ -210,10 +207,8 @@ func TestDynamicAllocation(t *testing.T) {
 func TestIPLeaseExpiration(t *testing.T) {
 	// Создаем тестовую конфигурацию с диапазоном IP адресов
 	subnet := config.Subnet{
-		Network:    "192.168.1.0",
-		Netmask:    "255.255.255.0",
-		RangeStart: "192.168.1.100",
-		RangeEnd:   "192.168.1.100",
+		Network: config.MustParseNetwork("192.168.1.0/24"),
+		Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.100"}},
 	}
 
 	cfg := &config.DHCPConfig{
@@ -250,8 +245,7 @@ func TestIPLeaseExpiration(t *testing.T) {
 func TestInitStaticAllocations(t *testing.T) {
 	// Создаем тестовую конфигурацию с статическими назначениями
 	subnet := config.Subnet{
-		Network: "192.168.1.0",
-		Netmask: "255.255.255.0",
+		Network: config.MustParseNetwork("192.168.1.0/24"),
 		Hosts: []config.Host{
 			{
 				Name:     "client1",
@@ -505,6 +499,31 @@ func TestStop(t *testing.T) {
 	server.Stop()
 }
 
+func TestStartWithListeners(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Переданный сокет используется вместо открытия порта 67
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetListeners([]*net.UDPConn{conn})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if server.conn != conn || len(server.conns) != 1 {
+		t.Errorf("Expected inherited socket to be used, got %v", server.conns)
+	}
+
+	server.Stop()
+	if _, err := conn.WriteToUDP([]byte{0}, conn.LocalAddr().(*net.UDPAddr)); err == nil {
+		t.Error("Expected inherited socket to be closed by Stop")
+	}
+}
+
 func TestHandleRequestsNilConn(t *testing.T) {
 	// Создаем тестовую конфигурацию
 	cfg := &config.DHCPConfig{}
@@ -526,8 +545,7 @@ func TestProcessRequestNilReply(t *testing.T) {
 	cfg := &config.DHCPConfig{
 		Subnets: []config.Subnet{
 			{
-				Network: "192.168.1.0",
-				Netmask: "255.255.255.0",
+				Network: config.MustParseNetwork("192.168.1.0/24"),
 			},
 		},
 	}
@@ -754,9 +772,8 @@ func TestAllocateDynamicIPWithoutRange(t *testing.T) {
 	cfg := &config.DHCPConfig{
 		Subnets: []config.Subnet{
 			{
-				Network: "192.168.1.0",
-				Netmask: "255.255.255.0",
-				// Нет RangeStart и RangeEnd
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				// Нет диапазонов range
 			},
 		},
 	}
@@ -768,16 +785,11 @@ func TestAllocateDynamicIPWithoutRange(t *testing.T) {
 	}
 
 	// Тестируем выделение динамического IP без диапазонов
-	ip, subnet := server.allocateDynamicIP("00:00:00:00:00:01")
+	offer := server.selectDynamicIP("00:00:00:00:00:01", "", false, nil)
 
-	// Проверяем, что возвращается пустой IP
-	if ip != "" {
-		t.Errorf("Expected empty IP when no ranges defined, got %s", ip)
-	}
-
-	// Проверяем, что возвращается nil subnet
-	if subnet != nil {
-		t.Error("Expected nil subnet when no ranges defined")
+	// Проверяем, что адрес не выбран
+	if offer != nil {
+		t.Errorf("Expected no offer when no ranges defined, got %s", intToIP(offer.ip))
 	}
 }
 
@@ -798,3 +810,808 @@ func TestIsIPAllocatedWithInvalidIP(t *testing.T) {
 		t.Error("Expected false for unallocated IP")
 	}
 }
+
+func TestClientOptionsInheritance(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Options: map[string]string{
+			"domain-name-servers": "10.0.0.53",
+			"domain-name":         "example.com",
+			"bootfile-name":       "pxelinux.0",
+		},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Options: map[string]string{"domain-name": "lab.example.com"},
+				Hosts: []config.Host{
+					{Name: "uefi", Hardware: "00:11:22:33:44:55", Options: map[string]string{"bootfile-name": "host.efi"}},
+				},
+			},
+		},
+		Classes: []config.Class{
+			{
+				Name:     "vmware",
+				Hardware: []string{"00:0c:29:00:00:01", "00:11:22:33:44:55"},
+				Options:  map[string]string{"bootfile-name": "vmware.efi", "domain-name-servers": "10.0.1.53"},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	subnet := &server.config.Subnets[0]
+
+	tests := []struct {
+		mac      string
+		expected map[string]string
+	}{
+		// Глобальные опции переопределяются подсетью
+		{"aa:bb:cc:dd:ee:ff", map[string]string{
+			"domain-name-servers": "10.0.0.53", "domain-name": "lab.example.com", "bootfile-name": "pxelinux.0"}},
+		// Класс переопределяет подсеть и глобальные опции
+		{"00:0C:29:00:00:01", map[string]string{
+			"domain-name-servers": "10.0.1.53", "domain-name": "lab.example.com", "bootfile-name": "vmware.efi"}},
+		// Хост переопределяет класс
+		{"00:11:22:33:44:55", map[string]string{
+			"domain-name-servers": "10.0.1.53", "domain-name": "lab.example.com", "bootfile-name": "host.efi"}},
+	}
+
+	for _, tt := range tests {
+		options := server.clientOptions(tt.mac, nil, subnet, server.hosts[normalizeMAC(tt.mac)])
+		if len(options) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.mac, tt.expected, options)
+			continue
+		}
+		for key, value := range tt.expected {
+			if options[key] != value {
+				t.Errorf("%s: expected %s = %s, got %s", tt.mac, key, value, options[key])
+			}
+		}
+	}
+
+	// Опции хоста попадают в ответ
+	request := &BOOTPHeader{
+		Op:     BOOTPRequest,
+		Htype:  HTYPE_ETHER,
+		Hlen:   6,
+		Chaddr: [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	}
+	reply := server.processRequest(request)
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	if file := string(bytes.Trim(reply.File[:], "\x00")); file != "host.efi" {
+		t.Errorf("Expected file host.efi, got %s", file)
+	}
+}
+
+func TestBootParametersPrecedence(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Boot: config.BootParams{NextServer: "10.0.0.1", Filename: "pxelinux.0"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Boot:    config.BootParams{NextServer: "192.168.1.10"},
+				Options: map[string]string{"tftp-server-name": "192.168.1.20", "bootfile-name": "option.0"},
+				Hosts: []config.Host{
+					{Name: "rescue", Hardware: "00:11:22:33:44:55", Boot: config.BootParams{Filename: "rescue.0"}},
+				},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	subnet := &server.config.Subnets[0]
+	options := subnet.Options
+
+	// Подсеть переопределяет next-server, filename наследуется глобально;
+	// операторы имеют приоритет над опциями
+	boot := server.bootParameters("aa:bb:cc:dd:ee:ff", nil, subnet, nil, options)
+	if boot.NextServer != "192.168.1.10" || boot.Filename != "pxelinux.0" {
+		t.Errorf("Unexpected boot parameters %+v", boot)
+	}
+
+	// Хост переопределяет filename
+	boot = server.bootParameters("00:11:22:33:44:55", nil, subnet, server.hosts["00:11:22:33:44:55"], options)
+	if boot.NextServer != "192.168.1.10" || boot.Filename != "rescue.0" {
+		t.Errorf("Unexpected host boot parameters %+v", boot)
+	}
+
+	// Без операторов используются опции; опция tftp-server-name задает
+	// имя сервера, а не адрес
+	server.config.Boot = config.BootParams{}
+	subnet.Boot = config.BootParams{}
+	boot = server.bootParameters("aa:bb:cc:dd:ee:ff", nil, subnet, nil, options)
+	if boot.NextServer != "" || boot.ServerName != "192.168.1.20" || boot.Filename != "option.0" {
+		t.Errorf("Expected fallback to options, got %+v", boot)
+	}
+
+	// Значения попадают в siaddr и file ответа
+	reply := server.processRequest(&BOOTPHeader{
+		Op:     BOOTPRequest,
+		Htype:  HTYPE_ETHER,
+		Hlen:   6,
+		Chaddr: [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	})
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	if sname := string(bytes.Trim(reply.Sname[:], "\x00")); sname != "192.168.1.20" {
+		t.Errorf("Expected sname 192.168.1.20, got %s", sname)
+	}
+	if file := string(bytes.Trim(reply.File[:], "\x00")); file != "rescue.0" {
+		t.Errorf("Expected file rescue.0, got %s", file)
+	}
+}
+
+func TestSetBootParameters(t *testing.T) {
+	// Имена передаются и в полях заголовка, и в опциях 66/67
+	response := &Packet{Options: make(map[uint8][]byte)}
+	if err := setBootParameters(response, config.BootParams{NextServer: "10.0.0.5", ServerName: "tftp.example.com", Filename: "pxelinux.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if siaddr := net.IP(response.Header.Siaddr[:]); !siaddr.Equal(net.IPv4(10, 0, 0, 5)) {
+		t.Errorf("Expected siaddr 10.0.0.5, got %v", siaddr)
+	}
+	if sname := fieldString(response.Header.Sname[:]); sname != "tftp.example.com" {
+		t.Errorf("Expected sname tftp.example.com, got %q", sname)
+	}
+	if file := fieldString(response.Header.File[:]); file != "pxelinux.0" {
+		t.Errorf("Expected file pxelinux.0, got %q", file)
+	}
+	if name := string(response.Options[OptionTFTPServerName]); name != "tftp.example.com" {
+		t.Errorf("Expected option 66 tftp.example.com, got %q", name)
+	}
+	if name := string(response.Options[OptionBootfileName]); name != "pxelinux.0" {
+		t.Errorf("Expected option 67 pxelinux.0, got %q", name)
+	}
+
+	// Имя длиннее поля file передается только опцией
+	long := "images/" + strings.Repeat("x", 130) + ".efi"
+	response = &Packet{Options: make(map[uint8][]byte)}
+	if err := setBootParameters(response, config.BootParams{Filename: long}); err != nil {
+		t.Fatal(err)
+	}
+	if file := fieldString(response.Header.File[:]); file != "" {
+		t.Errorf("Expected truncated name not to be written to the file field, got %q", file)
+	}
+	if name := string(response.Options[OptionBootfileName]); name != long {
+		t.Errorf("Expected option 67 with the full name, got %q", name)
+	}
+
+	// Имя, не помещающееся в опцию или с нулевым байтом, не обрезается
+	for _, boot := range []config.BootParams{
+		{Filename: strings.Repeat("x", 256)},
+		{ServerName: strings.Repeat("x", 256)},
+		{Filename: "pxelinux.0\x00.efi"},
+	} {
+		response = &Packet{Options: make(map[uint8][]byte)}
+		if err := setBootParameters(response, boot); err == nil {
+			t.Errorf("Expected error for %+v", boot)
+		}
+		if len(response.Options) != 0 || fieldString(response.Header.File[:]) != "" {
+			t.Errorf("Expected no boot parameters for %+v, got %+v", boot, response)
+		}
+	}
+}
+
+func TestBootChainClasses(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Boot:        config.BootParams{Filename: "pxelinux.0"},
+		Options:     map[string]string{"wpad-url": "http://wpad.example.com/wpad.dat"},
+		Definitions: map[string]config.OptionDefinition{"wpad-url": {Code: 252, Type: "text"}},
+		Classes: []config.Class{
+			{
+				Name:  "uefi",
+				Match: []config.ClassCondition{{Option: "pxe-system-type", Code: 93, Value: []byte{0, 7}}},
+				Boot:  config.BootParams{Filename: "shimx64.efi"},
+			},
+			// iPXE объявлен последним и переопределяет файл для UEFI
+			{
+				Name: "ipxe",
+				Match: []config.ClassCondition{
+					{Option: "user-class", Code: 77},
+					{Option: "user-class", Code: 77, Value: []byte("iPXE")},
+				},
+				Boot: config.BootParams{Filename: "http://boot.example.com/boot.ipxe"},
+			},
+		},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options map[uint8][]byte
+		file    string
+	}{
+		{"bios", map[uint8][]byte{93: {0, 0}}, "pxelinux.0"},
+		{"uefi", map[uint8][]byte{93: {0, 7}}, "shimx64.efi"},
+		{"ipxe on uefi", map[uint8][]byte{93: {0, 7}, 77: []byte("iPXE")}, "http://boot.example.com/boot.ipxe"},
+		{"other user-class", map[uint8][]byte{77: []byte("gPXE")}, "pxelinux.0"},
+	}
+
+	for i, tt := range tests {
+		tt.options[OptionMessageType] = []byte{DHCPDiscover}
+		reply := server.processPacket(&Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, byte(i + 1)}},
+			Options: tt.options,
+		})
+		if reply == nil {
+			t.Fatalf("%s: expected reply", tt.name)
+		}
+		if file := string(reply.Options[OptionBootfileName]); file != tt.file {
+			t.Errorf("%s: expected boot file %s, got %s", tt.name, tt.file, file)
+		}
+		if wpad := string(reply.Options[252]); wpad != "http://wpad.example.com/wpad.dat" {
+			t.Errorf("%s: expected option 252, got %q", tt.name, wpad)
+		}
+	}
+}
+
+func TestCustomOptions(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Options: map[string]string{
+			"provisioning-url":    "http://provision.example.com/",
+			"boot-delay":          "300",
+			"acme.server":         "10.0.0.5",
+			"acme.mode":           "2",
+			"other.server":        "10.0.0.6",
+			"vendor-option-space": "acme",
+		},
+		Definitions: map[string]config.OptionDefinition{
+			"provisioning-url": {Code: 224, Type: "text"},
+			"boot-delay":       {Code: 225, Type: "unsigned integer 16"},
+			"acme.server":      {Code: 1, Type: "ip-address"},
+			"acme.mode":        {Code: 2, Type: "unsigned integer 8"},
+			"other.server":     {Code: 1, Type: "ip-address"},
+		},
+		Spaces: []string{"acme", "other"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	reply := server.processPacket(&Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	})
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	if url := string(reply.Options[224]); url != "http://provision.example.com/" {
+		t.Errorf("Expected option 224, got %q", url)
+	}
+	if delay := reply.Options[225]; !bytes.Equal(delay, []byte{0x01, 0x2c}) {
+		t.Errorf("Expected option 225 0x012c, got %x", delay)
+	}
+	// Только опции выбранного пространства, в порядке кодов
+	if vendor := reply.Options[OptionVendorSpecific]; !bytes.Equal(vendor, []byte{1, 4, 10, 0, 0, 5, 2, 1, 2}) {
+		t.Errorf("Unexpected option 43: %x", vendor)
+	}
+}
+
+func TestStandardOptions(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Options: map[string]string{
+			"domain-name-servers": "10.0.0.53, 10.0.0.54",
+			"domain-name":         "example.org",
+		},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Options: map[string]string{
+					"routers":                 "192.168.1.1",
+					"ntp-servers":             "192.168.1.2",
+					"interface-mtu":           "9000",
+					"classless-static-routes": "10.0.0.0/8 192.168.1.254, 0.0.0.0/0 192.168.1.1",
+					"wpad-url":                "http://wpad.example.com/wpad.dat",
+					"www-server":              "192.168.1.3",
+				},
+			},
+			{
+				Network: config.MustParseNetwork("10.1.0.0/16"),
+				Ranges:  []config.Range{{Start: "10.1.0.100", End: "10.1.0.200"}},
+				Options: map[string]string{"broadcast-address": "10.1.0.255"},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	reply := server.processPacket(&Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	})
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	expected := map[uint8][]byte{
+		OptionSubnetMask:       {255, 255, 255, 0},
+		3:                      {192, 168, 1, 1},
+		6:                      {10, 0, 0, 53, 10, 0, 0, 54},
+		OptionDomainName:       []byte("example.org"),
+		OptionBroadcastAddress: {192, 168, 1, 255},
+		42:                     {192, 168, 1, 2},
+		26:                     {0x23, 0x28},
+		121:                    {8, 10, 192, 168, 1, 254, 0, 192, 168, 1, 1},
+		72:                     {192, 168, 1, 3},
+		252:                    []byte("http://wpad.example.com/wpad.dat"),
+	}
+	for code, value := range expected {
+		if !bytes.Equal(reply.Options[code], value) {
+			t.Errorf("Expected option %d = %v, got %v", code, value, reply.Options[code])
+		}
+	}
+
+	// Опция broadcast-address подсети заменяет вычисленный адрес
+	response := &Packet{Options: make(map[uint8][]byte)}
+	server.setConfigOptions(response, server.config.Subnets[1].Options, &server.config.Subnets[1])
+	if mask := response.Options[OptionSubnetMask]; !bytes.Equal(mask, []byte{255, 255, 0, 0}) {
+		t.Errorf("Expected subnet mask 255.255.0.0, got %v", mask)
+	}
+	if broadcast := response.Options[OptionBroadcastAddress]; !bytes.Equal(broadcast, []byte{10, 1, 0, 255}) {
+		t.Errorf("Expected configured broadcast address, got %v", broadcast)
+	}
+
+	// Значение стандартной опции проверяется при загрузке
+	cfg.Subnets[0].Options["routers"] = "gateway.example.org"
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected error for invalid routers option")
+	}
+}
+
+func TestResolveServerName(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"resolve-server-name": "on"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Boot:    config.BootParams{ServerName: "tftp.example.com", Filename: "pxelinux.0"},
+			},
+		},
+		Hosts: []config.Host{
+			{Name: "literal", Hardware: "00:11:22:33:44:55", Boot: config.BootParams{ServerName: "192.168.1.6"}},
+			{Name: "pinned", Hardware: "00:11:22:33:44:66", Boot: config.BootParams{NextServer: "192.168.1.7"}},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	lookups := 0
+	server.lookup = func(name string) net.IP {
+		lookups++
+		if name == "tftp.example.com" {
+			return net.IPv4(192, 168, 1, 5)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		mac    [16]byte
+		siaddr net.IP
+	}{
+		// Имя разрешается через DNS
+		{[16]byte{0x02, 0, 0, 0, 0, 1}, net.IPv4(192, 168, 1, 5)},
+		// Результат разрешения сохраняется
+		{[16]byte{0x02, 0, 0, 0, 0, 2}, net.IPv4(192, 168, 1, 5)},
+		// Адрес в server-name используется без разрешения
+		{[16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, net.IPv4(192, 168, 1, 6)},
+		// next-server имеет приоритет
+		{[16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}, net.IPv4(192, 168, 1, 7)},
+	}
+	for _, tt := range tests {
+		reply := server.processPacket(&Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: tt.mac},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+		})
+		if reply == nil {
+			t.Fatalf("%x: expected reply, got nil", tt.mac[:6])
+		}
+		if !net.IP(reply.Header.Siaddr[:]).Equal(tt.siaddr) {
+			t.Errorf("%x: expected siaddr %s, got %v", tt.mac[:6], tt.siaddr, net.IP(reply.Header.Siaddr[:]))
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("Expected one lookup, got %d", lookups)
+	}
+
+	// Без resolve-server-name siaddr по имени не заполняется
+	delete(server.config.GlobalOptions, "resolve-server-name")
+	if boot := server.nextServer(config.BootParams{ServerName: "192.168.1.6"}); boot.NextServer != "" {
+		t.Errorf("Expected no next-server without resolve-server-name, got %s", boot.NextServer)
+	}
+}
+
+func TestPerHostBootParameters(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Boot:    config.BootParams{NextServer: "192.168.1.10", Filename: "pxelinux.0"},
+			},
+		},
+		// Хост без fixed-address получает динамический адрес и собственный образ
+		Hosts: []config.Host{
+			{
+				Name:     "rescue",
+				Hardware: "00:11:22:33:44:55",
+				Boot:     config.BootParams{NextServer: "192.168.1.20", Filename: "rescue/vmlinuz"},
+				Options:  map[string]string{"root-path": "/srv/rescue"},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	tests := []struct {
+		chaddr     [16]byte
+		nextServer string
+		file       string
+	}{
+		{[16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, "192.168.1.20", "rescue/vmlinuz"},
+		{[16]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}, "192.168.1.10", "pxelinux.0"},
+	}
+
+	for _, tt := range tests {
+		reply := server.processRequest(&BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: tt.chaddr})
+		if reply == nil {
+			t.Fatal("Expected reply, got nil")
+		}
+		if yiaddr := net.IP(reply.Yiaddr[:]); !yiaddr.IsGlobalUnicast() {
+			t.Errorf("Expected dynamic address, got %v", yiaddr)
+		}
+		if siaddr := net.IP(reply.Siaddr[:]).String(); siaddr != tt.nextServer {
+			t.Errorf("Expected siaddr %s, got %s", tt.nextServer, siaddr)
+		}
+		if file := string(bytes.Trim(reply.File[:], "\x00")); file != tt.file {
+			t.Errorf("Expected file %s, got %s", tt.file, file)
+		}
+	}
+
+	if options := server.clientOptions("00:11:22:33:44:55", nil, &server.config.Subnets[0], server.hosts["00:11:22:33:44:55"]); options["root-path"] != "/srv/rescue" {
+		t.Errorf("Expected host option root-path, got %v", options)
+	}
+}
+
+func TestBootExpressions(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Boot:    config.BootParams{Filename: `concat("boot/", binary-to-ascii(16, 8, "-", substring(hardware, 1, 6)), ".cfg")`},
+				Options: map[string]string{"domain-name": `concat("host-", binary-to-ascii(10, 8, "-", leased-address), ".lan")`},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := &Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0xaa, 0xbb, 0xcc, 0x0d, 0xee, 0xff}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	}
+	reply := server.processPacket(request)
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	if file := string(bytes.Trim(reply.Header.File[:], "\x00")); file != "boot/aa-bb-cc-d-ee-ff.cfg" {
+		t.Errorf("Unexpected file %q", file)
+	}
+	if domain := string(reply.Options[OptionDomainName]); domain != "host-192-168-1-100.lan" {
+		t.Errorf("Unexpected domain-name %q", domain)
+	}
+}
+
+func TestBootTemplates(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Boot:    config.BootParams{Filename: `{{if eq .Arch "7"}}efi{{else}}bios{{end}}/{{replace .MAC ":" "-"}}.ipxe`},
+				Options: map[string]string{"domain-name": "{{.Hostname}}.{{.IP}}.lan"},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := &Packet{
+		Header: BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0xaa, 0xbb, 0xcc, 0x0d, 0xee, 0xff}},
+		Options: map[uint8][]byte{
+			OptionMessageType: {DHCPDiscover},
+			OptionClientArch:  {0, 7},
+			OptionHostName:    []byte("pc1"),
+		},
+	}
+	reply := server.processPacket(request)
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	if file := string(bytes.Trim(reply.Header.File[:], "\x00")); file != "efi/aa-bb-cc-0d-ee-ff.ipxe" {
+		t.Errorf("Unexpected file %q", file)
+	}
+	if domain := string(reply.Options[OptionDomainName]); domain != "pc1.192.168.1.100.lan" {
+		t.Errorf("Unexpected domain-name %q", domain)
+	}
+
+	// Неизвестная переменная обнаруживается при загрузке
+	cfg.Subnets[0].Boot.Filename = "{{.Serial}}.ipxe"
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected error for unknown template variable")
+	}
+}
+
+func TestHardwareAddressFormats(t *testing.T) {
+	chaddr := [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}
+	if mac := chaddrToMAC(chaddr, 6); mac != "00:11:22:33:44:55" {
+		t.Errorf("Expected Ethernet address, got %s", mac)
+	}
+	if mac := chaddrToMAC(chaddr, 8); mac != "00:11:22:33:44:55:66:77" {
+		t.Errorf("Expected 8-byte address, got %s", mac)
+	}
+
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Hosts: []config.Host{
+					{Name: "cisco", Hardware: "0011.2233.4455", FixedIP: "192.168.1.10"},
+					{Name: "fddi", Hardware: "00-11-22-33-44-55-66-77", FixedIP: "192.168.1.11"},
+				},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Адрес из конфигурации в формате Cisco сопоставляется с запросом
+	reply := server.processRequest(&BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: chaddr})
+	if reply == nil || net.IP(reply.Yiaddr[:]).String() != "192.168.1.10" {
+		t.Errorf("Expected 192.168.1.10 for Ethernet client, got %v", reply)
+	}
+
+	// Клиент с адресом длиной 8 байт (не Ethernet)
+	reply = server.processRequest(&BOOTPHeader{Op: BOOTPRequest, Htype: 8, Hlen: 8, Chaddr: chaddr})
+	if reply == nil || net.IP(reply.Yiaddr[:]).String() != "192.168.1.11" {
+		t.Errorf("Expected 192.168.1.11 for 8-byte client, got %v", reply)
+	}
+
+	// Административные операции принимают любой формат адреса
+	if _, err := server.ReleaseLease("00-11-22-33-44-55"); err != nil {
+		t.Errorf("Expected release by dashed MAC to succeed: %v", err)
+	}
+}
+
+func TestClientIdentifierLeaseKey(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Hosts: []config.Host{
+					{Name: "by-id", ClientID: "01:aa:bb:cc:dd:ee:ff", FixedIP: "192.168.1.10"},
+					{Name: "by-mac", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.11"},
+				},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := func(chaddr [16]byte, clientID []byte) string {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: chaddr},
+			Options: map[uint8][]byte{},
+		}
+		if clientID != nil {
+			packet.Options[OptionClientIdentifier] = clientID
+		}
+		reply := server.processPacket(packet)
+		if reply == nil {
+			return ""
+		}
+		return net.IP(reply.Header.Yiaddr[:]).String()
+	}
+
+	// Клиент со случайными MAC адресами сохраняет аренду по client-id
+	id := []byte("laptop")
+	first := request([16]byte{0x02, 0, 0, 0, 0, 1}, id)
+	if first == "" {
+		t.Fatal("Expected address for client with client-id")
+	}
+	if second := request([16]byte{0x02, 0, 0, 0, 0, 2}, id); second != first {
+		t.Errorf("Expected same address %s for same client-id, got %s", first, second)
+	}
+
+	// Без client-id аренда ищется по MAC адресу
+	if other := request([16]byte{0x02, 0, 0, 0, 0, 1}, nil); other == first || other == "" {
+		t.Errorf("Expected different address for client without client-id, got %s", other)
+	}
+
+	// Резервирование по client-id
+	if ip := request([16]byte{0x02, 0, 0, 0, 0, 3}, []byte{0x01, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}); ip != "192.168.1.10" {
+		t.Errorf("Expected reserved 192.168.1.10 by client-id, got %s", ip)
+	}
+
+	// Резервирование по MAC адресу действует и при наличии client-id
+	if ip := request([16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, []byte("printer")); ip != "192.168.1.11" {
+		t.Errorf("Expected reserved 192.168.1.11 by MAC, got %s", ip)
+	}
+
+	// Аренду по client-id можно освободить по MAC адресу
+	lease, err := server.ReleaseLease("02:00:00:00:00:02")
+	if err != nil {
+		t.Fatalf("Expected release by MAC to succeed: %v", err)
+	}
+	if lease.ClientID != "6c:61:70:74:6f:70" || lease.IP != first {
+		t.Errorf("Unexpected released lease %+v", lease)
+	}
+}
+
+func TestDuplicateReservations(t *testing.T) {
+	subnet := func(hosts ...config.Host) config.Subnet {
+		return config.Subnet{Network: config.MustParseNetwork("192.168.1.0/24"), Hosts: hosts}
+	}
+
+	tests := []struct {
+		name string
+		cfg  *config.DHCPConfig
+		want string
+	}{
+		{
+			name: "same MAC in subnet and global hosts",
+			cfg: &config.DHCPConfig{
+				Subnets: []config.Subnet{subnet(config.Host{Name: "a", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"})},
+				Hosts:   []config.Host{{Name: "b", Hardware: "00-11-22-33-44-55", FixedIP: "192.168.1.11"}},
+			},
+			want: "host a (subnet 192.168.1.0) and host b (global) declare the same client 00:11:22:33:44:55",
+		},
+		{
+			name: "same fixed address",
+			cfg: &config.DHCPConfig{
+				Subnets: []config.Subnet{subnet(
+					config.Host{Name: "a", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"},
+					config.Host{Name: "b", ClientID: "01:aa:bb:cc:dd:ee:ff", FixedIP: "192.168.1.10"},
+				)},
+			},
+			want: "host a (subnet 192.168.1.0) and host b (subnet 192.168.1.0) reserve the same address 192.168.1.10",
+		},
+		{
+			name: "fixed address outside of the subnet",
+			cfg: &config.DHCPConfig{
+				Subnets: []config.Subnet{subnet(config.Host{Name: "a", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.2.10"})},
+			},
+			want: "host a (subnet 192.168.1.0): fixed-address 192.168.2.10 is outside of the subnet",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBOOTPServer(tt.cfg); err == nil || err.Error() != tt.want {
+				t.Errorf("NewBOOTPServer error = %v, expected %q", err, tt.want)
+			}
+			if err := ValidateConfig(tt.cfg); err == nil || err.Error() != tt.want {
+				t.Errorf("ValidateConfig error = %v, expected %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubnetWithoutNetwork(t *testing.T) {
+	cfg := &config.DHCPConfig{Subnets: []config.Subnet{{Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}}}}}
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected error for subnet without network")
+	}
+	if err := ValidateConfig(cfg); err == nil {
+		t.Error("Expected validation error for subnet without network")
+	}
+}
+
+func TestGlobalHostSubnet(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Options: map[string]string{"domain-name": "one.example.com"}},
+			{Network: config.MustParseNetwork("192.168.2.0/24"), Options: map[string]string{"domain-name": "two.example.com"}},
+		},
+		Hosts: []config.Host{{Name: "printer", Hardware: "02:00:00:00:00:01", FixedIP: "192.168.2.10"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Глобальный хост получает опции подсети своего адреса
+	reply := server.processPacket(discoverPacket(1))
+	if reply == nil {
+		t.Fatal("Expected reply for global host")
+	}
+	if yiaddr := net.IP(reply.Header.Yiaddr[:]); !yiaddr.Equal(net.IPv4(192, 168, 2, 10)) {
+		t.Errorf("Expected fixed address, got %v", yiaddr)
+	}
+	if domain := string(reply.Options[OptionDomainName]); domain != "two.example.com" {
+		t.Errorf("Expected domain name of 192.168.2.0, got %q", domain)
+	}
+}
+
+func BenchmarkProcessRequest(b *testing.B) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.0.0/16"),
+				Ranges:  []config.Range{{Start: "192.168.1.0", End: "192.168.255.254"}},
+				Options: map[string]string{"domain-name": "example.com"},
+			},
+		},
+	})
+	if err != nil {
+		b.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Клиенты повторяют запросы и получают свои назначения
+	requests := make([]BOOTPHeader, 1024)
+	for i := range requests {
+		requests[i] = BOOTPHeader{
+			Op:     BOOTPRequest,
+			Htype:  HTYPE_ETHER,
+			Hlen:   6,
+			Xid:    uint32(i),
+			Chaddr: [16]byte{0x02, 0, 0, 0, byte(i >> 8), byte(i)},
+		}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if reply := server.processRequest(&requests[i%len(requests)]); reply == nil {
+			b.Fatal("Expected reply, got nil")
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// capability возможность Linux (linux/capability.h), нужная серверу
+type capability struct {
+	bit  uint
+	name string
+}
+
+var (
+	capNetBindService = capability{10, "NET_BIND_SERVICE"} // Привязка к портам ниже 1024 (67, 69, 53)
+	capNetRaw         = capability{13, "NET_RAW"}          // SO_BINDTODEVICE на ядрах до 5.7, ICMP проверка адресов и сокеты VLAN транков
+)
+
+// permissionError дополняет ошибку доступа подсказкой о недостающей
+// возможности: в контейнере сервер часто запущен без нее. Остальные ошибки
+// и ошибки при имеющейся возможности возвращаются без изменений.
+func permissionError(err error, capability capability, action string) error {
+	if !errors.Is(err, os.ErrPermission) || hasCapability(capability) {
+		return err
+	}
+	return fmt.Errorf("%v: %s requires CAP_%s, run as root or grant it "+
+		"(securityContext.capabilities.add: [%s] in Kubernetes, --cap-add=%s in Docker)",
+		err, action, capability.name, capability.name, capability.name)
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package server
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hasCapability проверяет возможность в эффективном наборе процесса
+// (CapEff в /proc/self/status). Если набор прочитать не удалось,
+// считается, что возможность есть.
+func hasCapability(capability capability) bool {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return true
+		}
+		return mask&(1<<capability.bit) != 0
+	}
+	return true
+}
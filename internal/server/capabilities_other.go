@@ -0,0 +1,8 @@
+//go:build !linux
+
+package server
+
+// hasCapability всегда возвращает true: возможности есть только в Linux
+func hasCapability(capability capability) bool {
+	return true
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// ClientConfig опции и параметры загрузки клиента аренды. Кроме опций,
+// которые сервер отправляет в ответе, содержит опции без объявления
+// (например, параметры сценариев iPXE), заданные на уровнях клиента.
+type ClientConfig struct {
+	Lease
+	Options map[string]string `json:"options"`
+	Boot    BootModel         `json:"boot"`
+}
+
+// ClientConfig возвращает действующую конфигурацию клиента по IP или MAC
+// адресу его аренды. Классы клиента определяются по сведениям из его
+// последнего запроса (опции 60, 61, 93 и имя хоста), выражения и шаблоны
+// вычисляются для адреса аренды. Возвращает ErrLeaseNotFound, если
+// аренды нет.
+func (s *BOOTPServer) ClientConfig(addr string) (*ClientConfig, error) {
+	s.mutex.Lock()
+	found := s.findAllocation(addr)
+	if found == nil {
+		s.mutex.Unlock()
+		return nil, ErrLeaseNotFound
+	}
+	allocated := *found
+	lease := newLease(&allocated, s.clock.Now())
+	host := s.hosts[allocated.key()]
+	if host == nil {
+		host = s.hosts[allocated.MAC]
+	}
+	s.mutex.Unlock()
+
+	header, request := allocationRequest(&allocated)
+	exprCtx := requestContext(header, request, intToIP(allocated.IP))
+	options := s.clientOptions(allocated.MAC, request, allocated.Subnet, host)
+	s.evaluateOptions(options, exprCtx)
+	boot := s.bootParameters(allocated.MAC, request, allocated.Subnet, host, options)
+	boot = s.nextServer(s.evaluateBoot(boot, exprCtx))
+
+	return &ClientConfig{
+		Lease:   lease,
+		Options: options,
+		Boot:    BootModel{NextServer: boot.NextServer, ServerName: boot.ServerName, Filename: boot.Filename},
+	}, nil
+}
+
+// allocationRequest восстанавливает заголовок и опции последнего запроса
+// клиента по сведениям, сохраненным в назначении
+func allocationRequest(allocated *AllocatedIP) (*BOOTPHeader, map[uint8][]byte) {
+	header := &BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER}
+	if hardware, err := net.ParseMAC(allocated.MAC); err == nil {
+		header.Hlen = byte(len(hardware))
+		copy(header.Chaddr[:], hardware)
+	}
+
+	request := make(map[uint8][]byte)
+	if allocated.VendorClass != "" {
+		request[OptionVendorClass] = []byte(allocated.VendorClass)
+	}
+	if allocated.Hostname != "" {
+		request[OptionHostName] = []byte(allocated.Hostname)
+	}
+	var arch []byte
+	for _, code := range allocated.Arch {
+		arch = binary.BigEndian.AppendUint16(arch, code)
+	}
+	if arch != nil {
+		request[OptionClientArch] = arch
+	}
+	clientID := allocated.RequestClientID
+	if clientID == "" {
+		clientID = allocated.ClientID
+	}
+	if id, err := hex.DecodeString(strings.ReplaceAll(clientID, ":", "")); err == nil && len(id) > 0 {
+		request[OptionClientIdentifier] = id
+	}
+	return header, request
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestClientConfig(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Options: map[string]string{"ipxe-cmdline": "console=ttyS0 ip={{.IP}}"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Boot:    config.BootParams{Filename: "{{.Arch}}/{{.MAC}}.ipxe"},
+			},
+		},
+		Classes: []config.Class{
+			{
+				Name:    "efi",
+				Match:   []config.ClassCondition{{Option: "pxe-system-type", Code: OptionClientArch, Value: []byte{0, 7}}},
+				Options: map[string]string{"ipxe-kernel": "http://boot/efi/vmlinuz"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	if _, err := server.ClientConfig("02:00:00:00:00:01"); !errors.Is(err, ErrLeaseNotFound) {
+		t.Fatalf("Expected ErrLeaseNotFound, got %v", err)
+	}
+
+	request := discoverPacket(1)
+	request.Options[OptionClientArch] = []byte{0, 7}
+	if reply := server.processPacket(request); reply == nil {
+		t.Fatal("Expected reply")
+	}
+
+	// Клиент находится и по MAC, и по адресу аренды
+	for _, addr := range []string{"02:00:00:00:00:01", "192.168.1.100"} {
+		client, err := server.ClientConfig(addr)
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+		if client.IP != "192.168.1.100" || client.Options["ipxe-kernel"] != "http://boot/efi/vmlinuz" || client.Options["ipxe-cmdline"] != "console=ttyS0 ip=192.168.1.100" {
+			t.Errorf("%s: unexpected config %+v", addr, client)
+		}
+		if client.Boot.Filename != "7/02:00:00:00:00:01.ipxe" {
+			t.Errorf("%s: unexpected filename %q", addr, client.Boot.Filename)
+		}
+	}
+}
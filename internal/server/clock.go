@@ -0,0 +1,17 @@
+package server
+
+import "time"
+
+// Clock источник текущего времени для сроков аренд: выдачи, продления,
+// удержания после истечения и удаления истекших аренд. Подменяется в
+// тестах (см. WithClock), чтобы проверять истечение аренд без ожидания.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock системные часы
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
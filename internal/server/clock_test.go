@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// fakeClock часы, которые идут только при вызове Advance
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance переводит часы вперед на d
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestLeaseExpiryWithClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"lease-grace-period": "300"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.100"}},
+				Hosts:   []config.Host{{Name: "static", Hardware: "02:00:00:00:00:09", FixedIP: "192.168.1.10"}},
+			},
+		},
+	}, WithClock(clock), WithLeaseDuration(10*time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	events, unsubscribe := server.SubscribeLeaseEvents(16)
+	defer unsubscribe()
+
+	offered := func(mac byte) string {
+		reply := server.processPacket(discoverPacket(mac))
+		if reply == nil {
+			return ""
+		}
+		return net.IP(reply.Header.Yiaddr[:]).String()
+	}
+	state := func(ip string) string {
+		for _, lease := range server.Leases() {
+			if lease.IP == ip {
+				return lease.State
+			}
+		}
+		return ""
+	}
+
+	if ip := offered(1); ip != "192.168.1.100" {
+		t.Fatalf("Expected 192.168.1.100, got %q", ip)
+	}
+	if ip := offered(9); ip != "192.168.1.10" {
+		t.Fatalf("Expected static address 192.168.1.10, got %q", ip)
+	}
+
+	// Аренда действует до истечения срока
+	clock.Advance(9 * time.Minute)
+	if expired := server.ExpireLeases(); expired != 0 || state("192.168.1.100") != LeaseStateActive {
+		t.Errorf("Expected active lease before expiry, expired %d, state %s", expired, state("192.168.1.100"))
+	}
+
+	// После истечения срока адрес удерживается за клиентом
+	clock.Advance(2 * time.Minute)
+	if state("192.168.1.100") != LeaseStateExpired || state("192.168.1.10") != LeaseStateReserved {
+		t.Errorf("Expected expired lease and reserved static address, got %s and %s",
+			state("192.168.1.100"), state("192.168.1.10"))
+	}
+	if ip := offered(2); ip != "" {
+		t.Errorf("Expected held address not to be offered, got %s", ip)
+	}
+
+	// Статическое назначение перестает быть активным, динамическое
+	// удаляется по окончании удержания
+	if expired := server.ExpireLeases(); expired != 1 {
+		t.Errorf("Expected static assignment to expire, got %d", expired)
+	}
+	clock.Advance(5 * time.Minute)
+	if expired := server.ExpireLeases(); expired != 1 {
+		t.Errorf("Expected lease to expire after grace period, got %d", expired)
+	}
+	if ip := offered(2); ip != "192.168.1.100" {
+		t.Errorf("Expected released address to be offered, got %q", ip)
+	}
+
+	var expiredEvents []LeaseEvent
+	for len(events) > 0 {
+		if event := <-events; event.Type == LeaseExpired {
+			expiredEvents = append(expiredEvents, event)
+		}
+	}
+	if len(expiredEvents) != 2 || !expiredEvents[1].Time.Equal(clock.Now()) {
+		t.Errorf("Expected two expired events at fake time, got %+v", expiredEvents)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"github.com/user/go-bootp/internal/config"
+)
+
+// leaseVerdict результат сверки динамической аренды с конфигурацией
+type leaseVerdict int
+
+const (
+	leaseValid      leaseVerdict = iota // Адрес входит в диапазон подсети
+	leaseOutOfRange                     // Адрес в подсети, но вне диапазонов: аренда сохраняется с отметкой
+	leaseOrphaned                       // Подсеть адреса удалена из конфигурации
+	leaseConflict                       // Адрес или клиент закреплены резервированием
+	leaseDuplicate                      // Адрес или клиент уже заняты другой арендой
+)
+
+// checkLease сверяет динамическую аренду с действующей конфигурацией и
+// возвращает подсеть, в которой аренда сохраняется. Вызывается с
+// захваченным мьютексом после loadStaticAllocations.
+func (s *BOOTPServer) checkLease(allocated *AllocatedIP) (leaseVerdict, *config.Subnet) {
+	if existing, exists := s.allocatedIP[allocated.IP]; exists {
+		if existing.Type == StaticAllocation {
+			return leaseConflict, nil
+		}
+		return leaseDuplicate, nil
+	}
+	if existing := s.lookupAllocation(allocated.MAC, allocated.ClientID); existing != nil {
+		if existing.Type == StaticAllocation {
+			return leaseConflict, nil
+		}
+		return leaseDuplicate, nil
+	}
+	if subnet := s.rangeSubnet(allocated.IP); subnet != nil {
+		return leaseValid, subnet
+	}
+	if subnet := subnetOf(s.config, allocated.IP); subnet != nil {
+		return leaseOutOfRange, subnet
+	}
+	return leaseOrphaned, nil
+}
+
+// admitLease добавляет динамическую аренду в таблицы назначений по
+// результату checkLease. Аренда вне диапазонов сохраняется до истечения с
+// отметкой OutOfRange. Аренды удаленных подсетей и аренды, несовместимые
+// с резервированиями, отзываются (см. revokeLease). Возвращает true, если
+// аренда сохранена. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) admitLease(allocated *AllocatedIP) bool {
+	verdict, subnet := s.checkLease(allocated)
+	switch verdict {
+	case leaseDuplicate:
+		s.logger.Warnf("Dropping lease %s for %s: address or client is already assigned", intToIP(allocated.IP), allocated.MAC)
+		return false
+	case leaseConflict:
+		s.logger.Warnf("Expiring lease %s for %s: conflicts with a reservation", intToIP(allocated.IP), allocated.MAC)
+		s.revokeLease(allocated, LeaseExpired)
+		return false
+	case leaseOrphaned:
+		s.logger.Warnf("Releasing lease %s for %s: address is outside of configured subnets", intToIP(allocated.IP), allocated.MAC)
+		s.revokeLease(allocated, LeaseReleased)
+		return false
+	case leaseOutOfRange:
+		s.logger.Warnf("Lease %s for %s is outside of configured ranges, keeping it until expiry", intToIP(allocated.IP), allocated.MAC)
+	}
+
+	allocated.Subnet = subnet
+	allocated.OutOfRange = verdict == leaseOutOfRange
+	s.allocatedIP[allocated.IP] = allocated
+	s.allocatedMAC[allocated.key()] = allocated
+	return true
+}
+
+// revokeLease снимает аренду, несовместимую с новой конфигурацией, и
+// запоминает адрес: на следующее продление этого адреса клиент получит
+// DHCPNAK и запросит новый, а не будет молча пользоваться устаревшим
+// назначением. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) revokeLease(allocated *AllocatedIP, event LeaseEventType) {
+	allocated.Active = false
+	s.revoked[allocated.key()] = allocated.IP
+	s.publishLeaseEvent(event, allocated)
+}
+
+// revokedRequest проверяет, запрашивает ли клиент отозванный у него
+// адрес, и забывает отзыв после ответа. Вызывается с захваченным
+// мьютексом.
+func (s *BOOTPServer) revokedRequest(key string, ip uint32) bool {
+	if revoked, exists := s.revoked[key]; exists && revoked == ip {
+		delete(s.revoked, key)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// parseRequestTimeout читает опцию request-timeout: предельное время
+// обработки запроса в миллисекундах (0 или отсутствие опции - без
+// ограничения).
+func parseRequestTimeout(options map[string]string) (time.Duration, error) {
+	value, ok := options["request-timeout"]
+	if !ok {
+		return 0, nil
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 0 {
+		return 0, fmt.Errorf("invalid request-timeout: %s", value)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// serveRequest обрабатывает запрос цепочкой обработчиков не дольше
+// request-timeout. Контекст обработчиков отменяется по истечении срока;
+// обработчик, который его не учитывает (например, зависший хук),
+// продолжает работу отдельно, а его ответ отбрасывается, и сервер
+// принимает следующие запросы.
+func (s *BOOTPServer) serveRequest(req *Request) (*Packet, error) {
+	s.mutex.Lock()
+	timeout := s.requestTimeout
+	s.mutex.Unlock()
+	if timeout == 0 {
+		return s.serve(context.Background(), req)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Опции ссылаются на буфер приема, который будет перезаписан
+	// следующим пакетом, пока обработчик еще работает
+	req.Packet.Options = copyOptions(req.Packet.Options)
+
+	type result struct {
+		reply *Packet
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		reply, err := s.serve(ctx, req)
+		done <- result{reply, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err == nil || !errors.Is(r.err, context.DeadlineExceeded) {
+			return r.reply, r.err
+		}
+	case <-ctx.Done():
+	}
+	s.counters.timeouts.Add(1)
+	return nil, fmt.Errorf("processing exceeded request-timeout %v", timeout)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseRequestTimeout(t *testing.T) {
+	if timeout, err := parseRequestTimeout(map[string]string{}); err != nil || timeout != 0 {
+		t.Errorf("Expected no timeout by default, got %v (%v)", timeout, err)
+	}
+	if timeout, err := parseRequestTimeout(map[string]string{"request-timeout": "1500"}); err != nil || timeout != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s, got %v (%v)", timeout, err)
+	}
+	for _, value := range []string{"-1", "1s"} {
+		if _, err := parseRequestTimeout(map[string]string{"request-timeout": value}); err == nil {
+			t.Errorf("Expected error for request-timeout %s", value)
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"request-timeout": "50"},
+		Subnets: []config.Subnet{{
+			Network: config.MustParseNetwork("192.168.1.0/24"),
+			Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Обработчик, не учитывающий контекст, не задерживает ответ сервера
+	release := make(chan struct{})
+	defer close(release)
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Packet, error) {
+			if req.Packet.Header.Chaddr[5] == 1 {
+				<-release
+			}
+			return next(ctx, req)
+		}
+	})
+
+	start := time.Now()
+	if reply, err := server.serveRequest(&Request{Packet: discoverPacket(1), MessageType: DHCPDiscover}); reply != nil || err == nil {
+		t.Errorf("Expected timeout, got %v (%v)", reply, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request took %v", elapsed)
+	}
+	if reply, err := server.serveRequest(&Request{Packet: discoverPacket(2), MessageType: DHCPDiscover}); reply == nil || err != nil {
+		t.Errorf("Expected reply within timeout, got %v (%v)", reply, err)
+	}
+	if got := server.Stats().Counters.Timeouts; got != 1 {
+		t.Errorf("Expected 1 timeout, got %d", got)
+	}
+}
+
+func TestRequestTimeoutCancelsHook(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	}))
+	defer hook.Close()
+
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{
+			"request-timeout":         "50",
+			"allocation-hook-url":     hook.URL,
+			"allocation-hook-timeout": "10000",
+		},
+		Subnets: []config.Subnet{{
+			Network: config.MustParseNetwork("192.168.1.0/24"),
+			Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := server.serveRequest(&Request{Packet: discoverPacket(1), MessageType: DHCPDiscover}); reply != nil || err == nil {
+		t.Errorf("Expected timeout, got %v (%v)", reply, err)
+	}
+	// Адрес не занят запросом, ответ на который отброшен
+	time.Sleep(50 * time.Millisecond)
+	if leases := server.Leases(); len(leases) != 0 {
+		t.Errorf("Expected no leases after timeout, got %+v", leases)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// replyDelay определяет задержку ответа клиенту. Оператор delay
+// наследуется по цепочке глобальные → подсеть → классы → хост, как
+// default-lease-time. Задержка позволяет работать рядом с основным
+// DHCP сервером сети: обычные клиенты получают его предложение раньше,
+// а классы, которым задан delay 0, получают ответ сразу.
+func (s *BOOTPServer) replyDelay(options map[string]string) time.Duration {
+	s.mutex.Lock()
+	delay := s.runtime.Delay
+	s.mutex.Unlock()
+
+	// Значение проверено при компиляции конфигурации
+	if value, ok := options[config.Delay]; ok {
+		if duration, err := config.ParseDelay(value); err == nil {
+			delay = duration
+		}
+	}
+	return delay
+}
+
+// delayedReplies таймеры отложенных ответов. Остановка сервера отменяет
+// ожидающие ответы и дожидается отправляемых.
+type delayedReplies struct {
+	mutex   sync.Mutex
+	timers  map[*time.Timer]struct{}
+	sending sync.WaitGroup
+	stopped bool
+}
+
+// schedule вызывает send по истечении delay, если до этого не вызван stop
+func (d *delayedReplies) schedule(delay time.Duration, send func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.stopped {
+		return
+	}
+	if d.timers == nil {
+		d.timers = make(map[*time.Timer]struct{})
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		d.mutex.Lock()
+		if _, pending := d.timers[timer]; !pending {
+			d.mutex.Unlock()
+			return
+		}
+		delete(d.timers, timer)
+		d.sending.Add(1)
+		d.mutex.Unlock()
+
+		defer d.sending.Done()
+		send()
+	})
+	d.timers[timer] = struct{}{}
+}
+
+// stop отменяет ожидающие ответы и ждет завершения отправляемых.
+// Ответы, отложенные после stop, не отправляются.
+func (d *delayedReplies) stop() {
+	d.mutex.Lock()
+	d.stopped = true
+	for timer := range d.timers {
+		timer.Stop()
+	}
+	d.timers = nil
+	d.mutex.Unlock()
+
+	d.sending.Wait()
+}
+
+// pending возвращает число ожидающих ответов
+func (d *delayedReplies) pending() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return len(d.timers)
+}
+
+// sendLater отправляет ответ по истечении задержки запроса, не задерживая
+// прием следующих запросов. Отложенный ответ не сохраняется для повторов:
+// повтор запроса обрабатывается заново и тоже ждет.
+func (s *BOOTPServer) sendLater(conn replySender, req *Request, reply *Packet, vendorClass int) {
+	// Опции ссылаются на буфер приема, который будет перезаписан
+	// следующим пакетом
+	req.Packet.Options = copyOptions(req.Packet.Options)
+	reply.Options = copyOptions(reply.Options)
+
+	s.logger.Debugf("Delaying reply to %s by %v", chaddrToMAC(reply.Header.Chaddr, reply.Header.Hlen), req.delay)
+	s.delayed.schedule(req.delay, func() {
+		s.sendReply(conn, req, reply, vendorClass)
+	})
+}
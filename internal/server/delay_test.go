@@ -0,0 +1,128 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestReplyDelay(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"delay": "300"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+		// PXE клиенты получают ответ сразу
+		Classes: []config.Class{
+			{
+				Name:    "pxe",
+				Match:   []config.ClassCondition{{Option: "pxe-system-type", Code: 93}},
+				Options: map[string]string{"delay": "0"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clientAddr := conn.LocalAddr().(*net.UDPAddr)
+
+	// Отложенный ответ не задерживает обработку следующего запроса
+	start := time.Now()
+	server.handlePacket(conn, discoverPacket(1), DHCPDiscover, clientAddr, nil)
+	pxe := discoverPacket(2)
+	pxe.Options[OptionClientArch] = []byte{0, 7}
+	server.handlePacket(conn, pxe, DHCPDiscover, clientAddr, nil)
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Errorf("Delayed reply blocked request processing for %v", elapsed)
+	}
+
+	buffer := make([]byte, maxPacketSize)
+	for _, want := range []byte{2, 1} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offer, err := DecodePacket(buffer[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mac := offer.Header.Chaddr[5]; mac != want {
+			t.Fatalf("Expected offer to client %d, got client %d", want, mac)
+		}
+		elapsed := time.Since(start)
+		if want == 1 && elapsed < delay {
+			t.Errorf("Expected offer to client 1 after %v, got it after %v", delay, elapsed)
+		}
+		if want == 2 && elapsed >= delay {
+			t.Errorf("Expected immediate offer to PXE client, got it after %v", elapsed)
+		}
+	}
+
+	// DHCPREQUEST не откладывается
+	request := discoverPacket(1)
+	request.Options[OptionMessageType] = []byte{DHCPRequest}
+	request.Options[OptionRequestedIP] = []byte{192, 168, 1, 100}
+	start = time.Now()
+	server.handlePacket(conn, request, DHCPRequest, clientAddr, nil)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(buffer); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Errorf("Expected immediate ack, got it after %v", elapsed)
+	}
+}
+
+func TestReplyDelayStop(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"delay": "200"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clientAddr := conn.LocalAddr().(*net.UDPAddr)
+
+	server.handlePacket(conn, discoverPacket(1), DHCPDiscover, clientAddr, nil)
+	if pending := server.delayed.pending(); pending != 1 {
+		t.Fatalf("Expected 1 pending reply, got %d", pending)
+	}
+
+	// Остановка сервера отменяет отложенные ответы
+	server.Stop()
+	if pending := server.delayed.pending(); pending != 0 {
+		t.Errorf("Expected no pending replies after stop, got %d", pending)
+	}
+	server.handlePacket(conn, discoverPacket(2), DHCPDiscover, clientAddr, nil)
+	if pending := server.delayed.pending(); pending != 0 {
+		t.Errorf("Expected reply after stop to be dropped, got %d pending", pending)
+	}
+	conn.SetReadDeadline(time.Now().Add(400 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, maxPacketSize)); err == nil {
+		t.Error("Expected no reply after stop")
+	}
+}
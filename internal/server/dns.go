@@ -0,0 +1,403 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Поля и коды сообщений DNS (RFC 1035)
+const (
+	dnsHeaderSize = 12
+
+	dnsFlagResponse  = 0x8000
+	dnsFlagAuthority = 0x0400
+	dnsFlagRecursion = 0x0100 // RD: клиент просит рекурсию
+	dnsFlagAvailable = 0x0080 // RA: рекурсия доступна
+	dnsOpcodeMask    = 0x7800
+
+	dnsRcodeFormErr  = 1
+	dnsRcodeServFail = 2
+	dnsRcodeNXDomain = 3
+	dnsRcodeNotImp   = 4
+	dnsRcodeRefused  = 5
+
+	dnsTypeA   = 1
+	dnsTypeANY = 255
+	dnsClassIN = 1
+)
+
+const (
+	dnsTTL            = 60 // Срок кеширования ответов о клиентах, секунд
+	dnsForwardTimeout = 2 * time.Second
+	dnsMaxMessageSize = 4096
+
+	// Ограничение одновременно пересылаемых запросов
+	maxDNSForwards = 64
+)
+
+// DNSResolver возвращает адрес клиента по имени хоста (nil - не найден)
+type DNSResolver func(name string) net.IP
+
+// dnsConfig параметры встроенного DNS сервера
+type dnsConfig struct {
+	listen     string   // Адрес приема запросов
+	domain     string   // Домен имен клиентов (пусто - только короткие имена)
+	forwarders []string // Вышестоящие серверы в виде host:port
+}
+
+// parseDNSOptions читает настройки встроенного DNS сервера:
+// dns-listen ":53"; dns-domain "lab.example.com";
+// dns-forwarders "1.1.1.1, 8.8.8.8";
+// Без dns-listen сервер не запускается (nil).
+func parseDNSOptions(options map[string]string) (*dnsConfig, error) {
+	listen := strings.Trim(options["dns-listen"], "\"")
+	if listen == "" {
+		return nil, nil
+	}
+	if _, err := net.ResolveUDPAddr("udp", listen); err != nil {
+		return nil, fmt.Errorf("invalid dns-listen: %v", err)
+	}
+
+	cfg := &dnsConfig{
+		listen: listen,
+		domain: strings.ToLower(strings.Trim(strings.Trim(options["dns-domain"], "\""), ".")),
+	}
+	for _, forwarder := range strings.Split(strings.Trim(options["dns-forwarders"], "\""), ",") {
+		if forwarder = strings.TrimSpace(forwarder); forwarder == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(forwarder)
+		if err != nil {
+			host, port = forwarder, "53"
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid dns-forwarders address: %s", forwarder)
+		}
+		cfg.forwarders = append(cfg.forwarders, net.JoinHostPort(host, port))
+	}
+	return cfg, nil
+}
+
+// DNSServer встроенный DNS сервер: отвечает записями A для имен хостов
+// клиентов с активной арендой и пересылает остальные запросы вышестоящим
+// серверам
+type DNSServer struct {
+	conn       *net.UDPConn
+	domain     string
+	forwarders []string
+	resolve    DNSResolver
+	slots      chan struct{} // Семафор пересылаемых запросов
+}
+
+// NewDNSServer создает DNS сервер. Имена в домене domain и короткие имена
+// без точек ищутся через resolve, остальные запросы пересылаются
+// forwarders.
+func NewDNSServer(domain string, forwarders []string, resolve DNSResolver) *DNSServer {
+	return &DNSServer{
+		domain:     domain,
+		forwarders: forwarders,
+		resolve:    resolve,
+		slots:      make(chan struct{}, maxDNSForwards),
+	}
+}
+
+// Start запускает DNS сервер на указанном адресе
+func (d *DNSServer) Start(listen string) error {
+	addr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		return err
+	}
+
+	d.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("DNS server listening on %s, domain %q, forwarders %v", d.conn.LocalAddr().String(), d.domain, d.forwarders)
+
+	go d.handleRequests()
+
+	return nil
+}
+
+// Stop останавливает DNS сервер
+func (d *DNSServer) Stop() {
+	if d.conn != nil {
+		d.conn.Close()
+	}
+}
+
+// handleRequests принимает запросы и отвечает на них
+func (d *DNSServer) handleRequests() {
+	buffer := make([]byte, dnsMaxMessageSize)
+
+	for {
+		n, clientAddr, err := d.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logrus.Errorf("Error reading DNS message: %v", err)
+			continue
+		}
+
+		query := make([]byte, n)
+		copy(query, buffer[:n])
+
+		reply, forward := d.answer(query)
+		if reply != nil {
+			d.conn.WriteToUDP(reply, clientAddr)
+			continue
+		}
+		if !forward {
+			continue
+		}
+
+		select {
+		case d.slots <- struct{}{}:
+		default:
+			logrus.Warnf("Too many forwarded DNS queries, rejecting query from %s", clientAddr)
+			if reply := dnsError(query, dnsRcodeServFail); reply != nil {
+				d.conn.WriteToUDP(reply, clientAddr)
+			}
+			continue
+		}
+
+		go func(clientAddr *net.UDPAddr, query []byte) {
+			defer func() { <-d.slots }()
+			d.conn.WriteToUDP(d.forward(query), clientAddr)
+		}(clientAddr, query)
+	}
+}
+
+// answer отвечает на запрос об имени клиента. Возвращает nil и true, если
+// запрос нужно переслать, и nil и false, если его нужно отбросить
+// (например, это ответ, а не запрос).
+func (d *DNSServer) answer(query []byte) ([]byte, bool) {
+	if len(query) < dnsHeaderSize {
+		return nil, false
+	}
+	flags := binary.BigEndian.Uint16(query[2:4])
+	if flags&dnsFlagResponse != 0 {
+		return nil, false
+	}
+	if flags&dnsOpcodeMask != 0 {
+		return dnsError(query, dnsRcodeNotImp), false
+	}
+	if binary.BigEndian.Uint16(query[4:6]) != 1 {
+		return dnsError(query, dnsRcodeFormErr), false
+	}
+
+	name, end, err := parseDNSQuestion(query)
+	if err != nil {
+		return dnsError(query, dnsRcodeFormErr), false
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4 : end-2])
+
+	// Короткие имена и имена в домене клиентов обслуживаются только
+	// локально, остальные - если совпадают с именем хоста клиента
+	host, local := name, !strings.Contains(name, ".")
+	if d.domain != "" && strings.HasSuffix(name, "."+d.domain) {
+		host, local = strings.TrimSuffix(name, "."+d.domain), true
+	}
+
+	var ip net.IP
+	if d.resolve != nil && host != "" {
+		ip = d.resolve(host)
+	}
+	if ip == nil {
+		if local {
+			logrus.Debugf("DNS: no client named %s", name)
+			return d.reply(query, end, dnsRcodeNXDomain, nil), false
+		}
+		return nil, true
+	}
+
+	logrus.Debugf("DNS: %s is %s", name, ip)
+	if qtype != dnsTypeA && qtype != dnsTypeANY {
+		// Имя существует, но записей запрошенного типа нет
+		return d.reply(query, end, 0, nil), false
+	}
+	return d.reply(query, end, 0, ip), false
+}
+
+// reply формирует авторитетный ответ с вопросом из запроса (end - конец
+// вопроса) и записью A, если ip не nil
+func (d *DNSServer) reply(query []byte, end int, rcode uint16, ip net.IP) []byte {
+	reply := make([]byte, end, end+16)
+	copy(reply, query[:end])
+
+	flags := dnsFlagResponse | dnsFlagAuthority | binary.BigEndian.Uint16(query[2:4])&dnsFlagRecursion | rcode
+	if len(d.forwarders) > 0 {
+		flags |= dnsFlagAvailable
+	}
+	binary.BigEndian.PutUint16(reply[2:4], flags)
+	binary.BigEndian.PutUint16(reply[8:10], 0)
+	binary.BigEndian.PutUint16(reply[10:12], 0)
+
+	if ip == nil {
+		binary.BigEndian.PutUint16(reply[6:8], 0)
+		return reply
+	}
+
+	binary.BigEndian.PutUint16(reply[6:8], 1)
+	record := make([]byte, 16)
+	binary.BigEndian.PutUint16(record[0:2], 0xC000|dnsHeaderSize) // Ссылка на имя в вопросе
+	binary.BigEndian.PutUint16(record[2:4], dnsTypeA)
+	binary.BigEndian.PutUint16(record[4:6], dnsClassIN)
+	binary.BigEndian.PutUint32(record[6:10], dnsTTL)
+	binary.BigEndian.PutUint16(record[10:12], 4)
+	copy(record[12:16], ip.To4())
+	return append(reply, record...)
+}
+
+// forward пересылает запрос вышестоящим серверам по очереди и возвращает
+// первый полученный ответ. Без серверов или при их недоступности
+// возвращается ошибка REFUSED или SERVFAIL.
+func (d *DNSServer) forward(query []byte) []byte {
+	if len(d.forwarders) == 0 {
+		return dnsError(query, dnsRcodeRefused)
+	}
+
+	buffer := make([]byte, dnsMaxMessageSize)
+	for _, forwarder := range d.forwarders {
+		n, err := exchangeDNS(forwarder, query, buffer)
+		if err != nil {
+			logrus.Warnf("DNS forwarder %s failed: %v", forwarder, err)
+			continue
+		}
+		return buffer[:n]
+	}
+	return dnsError(query, dnsRcodeServFail)
+}
+
+// exchangeDNS отправляет запрос серверу и ждет ответ с тем же идентификатором
+func exchangeDNS(server string, query, buffer []byte) (int, error) {
+	conn, err := net.Dial("udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(query); err != nil {
+		return 0, err
+	}
+	conn.SetReadDeadline(time.Now().Add(dnsForwardTimeout))
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return 0, err
+		}
+		if n >= dnsHeaderSize && buffer[0] == query[0] && buffer[1] == query[1] {
+			return n, nil
+		}
+	}
+}
+
+// parseDNSQuestion разбирает имя в вопросе запроса. Возвращает имя в нижнем
+// регистре без завершающей точки и смещение конца вопроса (после типа и
+// класса).
+func parseDNSQuestion(query []byte) (string, int, error) {
+	var labels []string
+	offset := dnsHeaderSize
+	for {
+		if offset >= len(query) {
+			return "", 0, errors.New("truncated question")
+		}
+		length := int(query[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		// Сжатие имен в вопросе не используется
+		if length > 63 || offset+length > len(query) {
+			return "", 0, errors.New("invalid label")
+		}
+		labels = append(labels, strings.ToLower(string(query[offset:offset+length])))
+		offset += length
+	}
+	if offset+4 > len(query) {
+		return "", 0, errors.New("truncated question")
+	}
+	return strings.Join(labels, "."), offset + 4, nil
+}
+
+// dnsError формирует ответ с кодом ошибки без вопроса. Возвращает nil для
+// сообщений короче заголовка.
+func dnsError(query []byte, rcode uint16) []byte {
+	if len(query) < dnsHeaderSize {
+		return nil
+	}
+	reply := make([]byte, dnsHeaderSize)
+	copy(reply[0:2], query[0:2])
+	flags := dnsFlagResponse | binary.BigEndian.Uint16(query[2:4])&(dnsOpcodeMask|dnsFlagRecursion) | rcode
+	binary.BigEndian.PutUint16(reply[2:4], flags)
+	return reply
+}
+
+// startDNS запускает встроенный DNS сервер, если задан dns-listen. Без
+// dns-domain имена клиентов ищутся в домене из опции domain-name.
+func (s *BOOTPServer) startDNS() error {
+	cfg, err := parseDNSOptions(s.config.GlobalOptions)
+	if err != nil || cfg == nil {
+		return err
+	}
+	if cfg.domain == "" {
+		cfg.domain = strings.ToLower(strings.Trim(strings.Trim(s.config.Options["domain-name"], "\""), "."))
+	}
+
+	s.dns = NewDNSServer(cfg.domain, cfg.forwarders, s.lookupHostname)
+	if err := s.dns.Start(cfg.listen); err != nil {
+		s.dns = nil
+		return permissionError(err, capNetBindService, "DNS server")
+	}
+	return nil
+}
+
+func (s *BOOTPServer) stopDNS() {
+	if s.dns != nil {
+		s.dns.Stop()
+		s.dns = nil
+	}
+}
+
+// lookupHostname возвращает адрес клиента с активной арендой и указанным
+// именем хоста (без учета регистра). Если имя занято несколькими клиентами,
+// выбирается аренда, истекающая позже.
+func (s *BOOTPServer) lookupHostname(name string) net.IP {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	var found *AllocatedIP
+	for _, allocated := range s.allocatedIP {
+		if !strings.EqualFold(allocated.Hostname, name) || allocated.state(now) != LeaseStateActive {
+			continue
+		}
+		if found == nil || laterExpiry(allocated, found) {
+			found = allocated
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return intToIP(found.IP)
+}
+
+// laterExpiry сообщает, что назначение a истекает позже b. Назначения без
+// срока считаются бессрочными.
+func laterExpiry(a, b *AllocatedIP) bool {
+	switch {
+	case a.Expires.IsZero() != b.Expires.IsZero():
+		return a.Expires.IsZero()
+	case a.Expires.Equal(b.Expires):
+		return a.IP < b.IP
+	}
+	return a.Expires.After(b.Expires)
+}
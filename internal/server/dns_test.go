@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dnsQuery формирует запрос записи типа qtype для имени name
+func dnsQuery(id uint16, name string, qtype uint16) []byte {
+	query := make([]byte, dnsHeaderSize)
+	binary.BigEndian.PutUint16(query[0:2], id)
+	binary.BigEndian.PutUint16(query[2:4], dnsFlagRecursion)
+	binary.BigEndian.PutUint16(query[4:6], 1)
+	for _, label := range strings.Split(name, ".") {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0, byte(qtype>>8), byte(qtype), 0, dnsClassIN)
+	return query
+}
+
+// exchange отправляет запрос DNS серверу и возвращает ответ
+func exchange(t *testing.T, addr net.Addr, query []byte) []byte {
+	t.Helper()
+	buffer := make([]byte, dnsMaxMessageSize)
+	n, err := exchangeDNS(addr.String(), query, buffer)
+	if err != nil {
+		t.Fatalf("DNS exchange failed: %v", err)
+	}
+	return buffer[:n]
+}
+
+func dnsRcode(reply []byte) uint16 {
+	return binary.BigEndian.Uint16(reply[2:4]) & 0x000F
+}
+
+func TestParseDNSOptions(t *testing.T) {
+	cfg, err := parseDNSOptions(map[string]string{})
+	if err != nil || cfg != nil {
+		t.Errorf("Expected DNS server to be disabled, got %+v (%v)", cfg, err)
+	}
+
+	cfg, err = parseDNSOptions(map[string]string{
+		"dns-listen":     "\"127.0.0.1:5353\"",
+		"dns-domain":     "\"Lab.Example.com.\"",
+		"dns-forwarders": "\"1.1.1.1, 192.168.1.1:5353\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.domain != "lab.example.com" {
+		t.Errorf("Expected normalized domain, got %q", cfg.domain)
+	}
+	if len(cfg.forwarders) != 2 || cfg.forwarders[0] != "1.1.1.1:53" || cfg.forwarders[1] != "192.168.1.1:5353" {
+		t.Errorf("Unexpected forwarders: %v", cfg.forwarders)
+	}
+
+	for _, options := range []map[string]string{
+		{"dns-listen": "\"bad:address:53\""},
+		{"dns-listen": "\":53\"", "dns-forwarders": "\"dns.example.com\""},
+	} {
+		if _, err := parseDNSOptions(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
+
+func TestDNSServer(t *testing.T) {
+	// Вышестоящий сервер отвечает на любой запрос кодом NXDOMAIN
+	upstream, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		buffer := make([]byte, dnsMaxMessageSize)
+		for {
+			n, addr, err := upstream.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			upstream.WriteToUDP(dnsError(buffer[:n], dnsRcodeNXDomain), addr)
+		}
+	}()
+
+	resolve := func(name string) net.IP {
+		if name == "node1" {
+			return net.IPv4(192, 168, 1, 101)
+		}
+		return nil
+	}
+	dns := NewDNSServer("lab", []string{upstream.LocalAddr().String()}, resolve)
+	if err := dns.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer dns.Stop()
+	addr := dns.conn.LocalAddr()
+
+	for _, name := range []string{"node1", "NODE1.lab"} {
+		reply := exchange(t, addr, dnsQuery(1, name, dnsTypeA))
+		if dnsRcode(reply) != 0 || binary.BigEndian.Uint16(reply[6:8]) != 1 {
+			t.Fatalf("%s: expected one answer, got %x", name, reply)
+		}
+		if ip := net.IP(reply[len(reply)-4:]); !ip.Equal(net.IPv4(192, 168, 1, 101)) {
+			t.Errorf("%s: expected 192.168.1.101, got %v", name, ip)
+		}
+		if flags := binary.BigEndian.Uint16(reply[2:4]); flags&dnsFlagAuthority == 0 || flags&dnsFlagAvailable == 0 {
+			t.Errorf("%s: expected authoritative answer with recursion available, flags %04x", name, flags)
+		}
+	}
+
+	// Имя существует, но записи AAAA нет
+	if reply := exchange(t, addr, dnsQuery(2, "node1.lab", 28)); dnsRcode(reply) != 0 || binary.BigEndian.Uint16(reply[6:8]) != 0 {
+		t.Errorf("Expected empty NOERROR answer for AAAA, got %x", reply)
+	}
+
+	// Неизвестный клиент в домене не пересылается
+	if reply := exchange(t, addr, dnsQuery(3, "node2.lab", dnsTypeA)); dnsRcode(reply) != dnsRcodeNXDomain || reply[2]&0x04 == 0 {
+		t.Errorf("Expected authoritative NXDOMAIN, got %x", reply)
+	}
+
+	// Остальные имена пересылаются вышестоящему серверу
+	reply := exchange(t, addr, dnsQuery(4, "www.example.com", dnsTypeA))
+	if dnsRcode(reply) != dnsRcodeNXDomain || binary.BigEndian.Uint16(reply[0:2]) != 4 || reply[2]&0x04 != 0 {
+		t.Errorf("Expected forwarded answer, got %x", reply)
+	}
+}
+
+func TestDNSServerWithoutForwarders(t *testing.T) {
+	dns := NewDNSServer("", nil, func(string) net.IP { return nil })
+	if err := dns.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer dns.Stop()
+
+	if reply := exchange(t, dns.conn.LocalAddr(), dnsQuery(1, "www.example.com", dnsTypeA)); dnsRcode(reply) != dnsRcodeRefused {
+		t.Errorf("Expected REFUSED without forwarders, got %x", reply)
+	}
+}
+
+func TestLookupHostname(t *testing.T) {
+	server := newInformTestServer(t)
+
+	now := time.Now()
+	server.allocatedIP[1] = &AllocatedIP{IP: ipToInt(net.IPv4(192, 168, 1, 101)), Hostname: "node1", Type: DynamicAllocation, Active: true, Expires: now.Add(time.Hour)}
+	server.allocatedIP[2] = &AllocatedIP{IP: ipToInt(net.IPv4(192, 168, 1, 102)), Hostname: "node1", Type: DynamicAllocation, Active: true, Expires: now.Add(2 * time.Hour)}
+	server.allocatedIP[3] = &AllocatedIP{IP: ipToInt(net.IPv4(192, 168, 1, 103)), Hostname: "node3", Type: DynamicAllocation, Active: true, Expires: now.Add(-time.Minute)}
+
+	if ip := server.lookupHostname("Node1"); !ip.Equal(net.IPv4(192, 168, 1, 102)) {
+		t.Errorf("Expected latest lease 192.168.1.102, got %v", ip)
+	}
+	if ip := server.lookupHostname("node3"); ip != nil {
+		t.Errorf("Expected expired lease not to resolve, got %v", ip)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LeaseEventType тип события изменения аренды
+type LeaseEventType string
+
+const (
+	LeaseAllocated LeaseEventType = "allocated" // Адрес выдан клиенту
+	LeaseRenewed   LeaseEventType = "renewed"   // Аренда продлена
+	LeaseReleased  LeaseEventType = "released"  // Аренда освобождена администратором, отозвана правилами доступа или удалена из конфигурации
+	LeaseExpired   LeaseEventType = "expired"   // Срок аренды истек
+)
+
+// LeaseEvent событие изменения аренды для внешних подписчиков
+type LeaseEvent struct {
+	Type  LeaseEventType `json:"type"`
+	Time  time.Time      `json:"time"`
+	Lease Lease          `json:"lease"`
+}
+
+// defaultEventBuffer размер очереди событий подписчика по умолчанию
+const defaultEventBuffer = 64
+
+// eventBus рассылает события аренд подписчикам. Медленные подписчики
+// не блокируют обработку запросов: при переполнении очереди событие
+// для них теряется.
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[int]chan LeaseEvent
+	next        int
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[int]chan LeaseEvent)}
+}
+
+// subscribe регистрирует подписчика и возвращает канал событий и функцию отписки
+func (b *eventBus) subscribe(buffer int) (<-chan LeaseEvent, func()) {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.next
+	b.next++
+	events := make(chan LeaseEvent, buffer)
+	b.subscribers[id] = events
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+
+			delete(b.subscribers, id)
+			close(events)
+		})
+	}
+}
+
+// publish отправляет событие всем подписчикам без блокировки
+func (b *eventBus) publish(event LeaseEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for id, events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			logrus.Warnf("Lease event subscriber %d is too slow, dropping %s event for %s", id, event.Type, event.Lease.MAC)
+		}
+	}
+}
+
+// SubscribeLeaseEvents подписывает на события аренд. Возвращенную функцию
+// нужно вызвать для отписки, после этого канал закрывается.
+func (s *BOOTPServer) SubscribeLeaseEvents(buffer int) (<-chan LeaseEvent, func()) {
+	return s.events.subscribe(buffer)
+}
+
+// publishLeaseEvent публикует событие по записи о назначении
+func (s *BOOTPServer) publishLeaseEvent(eventType LeaseEventType, allocated *AllocatedIP) {
+	s.counters.countLeaseEvent(eventType)
+	if eventType == LeaseAllocated || eventType == LeaseRenewed {
+		s.rememberAffinity(allocated)
+	}
+
+	now := s.clock.Now()
+	event := LeaseEvent{Type: eventType, Time: now, Lease: newLease(allocated, now)}
+	s.audit.record(AuditEntry{
+		Time:     event.Time,
+		Action:   AuditAction(eventType),
+		MAC:      event.Lease.MAC,
+		IP:       event.Lease.IP,
+		Hostname: event.Lease.Hostname,
+		Subnet:   event.Lease.SubnetID,
+	})
+	s.saveLease(event)
+	s.events.publish(event)
+}
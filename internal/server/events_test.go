@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, events <-chan LeaseEvent) LeaseEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for lease event")
+	}
+	return LeaseEvent{}
+}
+
+func TestLeaseEvents(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	events, unsubscribe := server.SubscribeLeaseEvents(0)
+	defer unsubscribe()
+
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+	server.findClientConfig("00:11:22:33:44:55")
+	server.ReleaseLease("aa:bb:cc:dd:ee:01")
+
+	expected := []struct {
+		eventType LeaseEventType
+		ip        string
+	}{
+		{LeaseAllocated, "192.168.1.100"},
+		{LeaseRenewed, "192.168.1.100"},
+		{LeaseAllocated, "192.168.1.10"},
+		{LeaseReleased, "192.168.1.100"},
+	}
+	for _, exp := range expected {
+		event := receiveEvent(t, events)
+		if event.Type != exp.eventType || event.Lease.IP != exp.ip {
+			t.Errorf("Expected %s %s, got %s %s", exp.eventType, exp.ip, event.Type, event.Lease.IP)
+		}
+	}
+}
+
+func TestLeaseEventsSlowSubscriber(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	events, unsubscribe := server.SubscribeLeaseEvents(1)
+
+	// Переполнение очереди не блокирует выдачу адресов
+	done := make(chan struct{})
+	go func() {
+		server.findClientConfig("aa:bb:cc:dd:ee:01")
+		server.findClientConfig("aa:bb:cc:dd:ee:02")
+		server.findClientConfig("aa:bb:cc:dd:ee:03")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Allocation blocked on a slow subscriber")
+	}
+
+	if event := receiveEvent(t, events); event.Lease.MAC != "aa:bb:cc:dd:ee:01" {
+		t.Errorf("Expected first event to be kept, got %+v", event)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+}
+
+func TestReservations(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	reservations := server.Reservations()
+	if len(reservations) != 1 {
+		t.Fatalf("Expected 1 reservation, got %d", len(reservations))
+	}
+	if reservations[0].Name != "static" || reservations[0].IP != "192.168.1.10" || reservations[0].Subnet != "192.168.1.0" {
+		t.Errorf("Unexpected reservation: %+v", reservations[0])
+	}
+}
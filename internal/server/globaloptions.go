@@ -0,0 +1,127 @@
+package server
+
+import (
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func init() {
+	config.RegisterGlobalOptions(
+		"allocation-hook-url", "allocation-hook-timeout", "allocation-hook-policy",
+		"allocation-hook-failure-threshold", "allocation-hook-cooldown",
+		"allocator-plugin", "ipam-driver", "ipam-url", "ipam-token", "ipam-timeout",
+		"allowed-relays", "unknown-relay-action", "always-send-options",
+		"audit-log-file", "audit-log-size", "bootp-listen", "interfaces",
+		"conflict-scan-interval", "dns-domain", "dns-forwarders", "dns-listen",
+		"generate-hostnames", "use-host-decl-names", "resolve-server-name",
+		"http-boot-listen", "http-boot-root", "tftp-listen", "tftp-root", "ipxe-script-path",
+		"interface-next-server", "interface-server-identifier", "interface-subnets",
+		"learning-mode", "lease-affinity-file", "lease-affinity-interval",
+		"lease-grace-period", "ping-check", "ping-timeout",
+		"max-reply-size", "min-secs", "request-timeout", "retransmit-cache-time",
+		"packet-capture-file", "packet-capture-files", "packet-capture-hexdump", "packet-capture-max-size",
+		"pool-alert-url", "pool-low-watermark", "pool-reserve",
+		"randomized-mac-lease-time", "randomized-mac-require-client-id",
+		"rate-limit-global", "rate-limit-global-burst", "rate-limit-per-client", "rate-limit-per-client-burst",
+		"rate-limit-action", "rate-limit-max-delay",
+		"read-batch-size", "socket-receive-buffer", "socket-send-buffer",
+		"reservations-file", "server-identifier", "vlan-map", "vlan-trunk",
+	)
+}
+
+// globalOptions глобальные параметры сервера, разобранные из конфигурации.
+// Создание сервера, перезагрузка и проверка конфигурации разбирают их
+// одной функцией (parseGlobalOptions), чтобы check не принимал то, что
+// отвергнет serve.
+type globalOptions struct {
+	hook           *AllocationHook
+	ipam           IPAMDriver
+	allocator      AllocatorPlugin
+	limiter        *RateLimiter
+	reuse          reusePolicy
+	maxReply       int
+	alwaysSend     []uint8
+	minSecs        uint16
+	requestTimeout time.Duration
+	randomMAC      randomizedMACPolicy
+	relays         *relayPolicy
+	alerts         poolAlerts
+	affinity       *leaseAffinity // Применяется только при создании сервера
+	vlans          vlanConfig
+	ifaces         interfaceOverrides
+	learning       bool
+	window         time.Duration // retransmit-cache-time
+	socket         socketTuning
+}
+
+// parseGlobalOptions разбирает глобальные параметры сервера и сверяет их
+// с подсетями конфигурации subnets
+func parseGlobalOptions(options map[string]string, subnets []config.Subnet) (*globalOptions, error) {
+	parsed := &globalOptions{}
+	var err error
+	if parsed.hook, err = NewAllocationHook(options); err != nil {
+		return nil, err
+	}
+	if parsed.ipam, err = NewIPAMDriver(options); err != nil {
+		return nil, err
+	}
+	if parsed.allocator, err = NewAllocatorPlugin(options); err != nil {
+		return nil, err
+	}
+	if parsed.limiter, err = NewRateLimiter(options); err != nil {
+		return nil, err
+	}
+	if _, err = parseServerIdentifier(options); err != nil {
+		return nil, err
+	}
+	if parsed.reuse, err = parseReusePolicy(options); err != nil {
+		return nil, err
+	}
+	if parsed.maxReply, err = parseMaxReplySize(options); err != nil {
+		return nil, err
+	}
+	if parsed.alwaysSend, err = parseAlwaysSendOptions(options); err != nil {
+		return nil, err
+	}
+	if parsed.minSecs, err = parseMinSecs(options); err != nil {
+		return nil, err
+	}
+	if parsed.requestTimeout, err = parseRequestTimeout(options); err != nil {
+		return nil, err
+	}
+	if parsed.randomMAC, err = parseRandomizedMACPolicy(options); err != nil {
+		return nil, err
+	}
+	if parsed.relays, err = parseRelayPolicy(options); err != nil {
+		return nil, err
+	}
+	if parsed.alerts, err = parsePoolAlerts(options); err != nil {
+		return nil, err
+	}
+	if parsed.affinity, err = parseAffinityOptions(options); err != nil {
+		return nil, err
+	}
+	if parsed.vlans, err = parseVLANOptions(options); err != nil {
+		return nil, err
+	}
+	if err = parsed.vlans.checkSubnets(subnets); err != nil {
+		return nil, err
+	}
+	if parsed.ifaces, err = parseInterfaceOverrides(options); err != nil {
+		return nil, err
+	}
+	if err = parsed.ifaces.checkSubnets(subnets); err != nil {
+		return nil, err
+	}
+	if parsed.learning, err = parseLearningMode(options); err != nil {
+		return nil, err
+	}
+	if parsed.window, err = parseRetransmitWindow(options); err != nil {
+		return nil, err
+	}
+	if parsed.socket, err = parseSocketTuning(options); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
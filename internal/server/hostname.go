@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxHostnameLen максимальная длина имени хоста (RFC 1035)
+const maxHostnameLen = 253
+
+// clientHostname определяет имя хоста клиента. Назначенное сервером имя
+// (option host-name, имя блока host при use-host-decl-names on или
+// сгенерированное при generate-hostnames on) имеет приоритет над именем
+// из опции 12 запроса. Второе значение сообщает, что имя назначил сервер
+// и его нужно передать клиенту.
+func (s *BOOTPServer) clientHostname(offer *leaseOffer, options map[string]string, requested []byte) (string, bool) {
+	if name := sanitizeHostname(options["host-name"]); name != "" {
+		return name, true
+	}
+
+	s.mutex.Lock()
+	useHostDecl := isEnabled(s.config.GlobalOptions["use-host-decl-names"])
+	generate := isEnabled(s.config.GlobalOptions["generate-hostnames"])
+	s.mutex.Unlock()
+
+	if useHostDecl && offer.host != nil {
+		if name := sanitizeHostname(offer.host.Name); name != "" {
+			return name, true
+		}
+	}
+	if name := sanitizeHostname(string(requested)); name != "" {
+		return name, false
+	}
+	if generate {
+		ip := intToIP(offer.ip).To4()
+		return fmt.Sprintf("dhcp-%d-%d-%d-%d", ip[0], ip[1], ip[2], ip[3]), true
+	}
+	return "", false
+}
+
+// sanitizeHostname оставляет в имени хоста только буквы, цифры, дефисы и
+// точки и ограничивает его длину. Клиенты присылают произвольные байты.
+func sanitizeHostname(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		}
+		if b.Len() == maxHostnameLen {
+			break
+		}
+	}
+	return strings.Trim(b.String(), ".-")
+}
+
+// isEnabled проверяет значение флага конфигурации вида "on"/"true"
+func isEnabled(value string) bool {
+	switch strings.ToLower(strings.Trim(value, "\"")) {
+	case "on", "true", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestSanitizeHostname(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"laptop", "laptop"},
+		{"my laptop\x00", "mylaptop"},
+		{"-printer.", "printer"},
+		{"web_01.lab", "web01.lab"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeHostname(tt.input); got != tt.expected {
+			t.Errorf("sanitizeHostname(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestClientHostname(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Options: map[string]string{"domain-name": "lab.example.com"},
+				Hosts: []config.Host{
+					{Name: "printer", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"},
+					{Name: "named", Hardware: "00:11:22:33:44:66", FixedIP: "192.168.1.11",
+						Options: map[string]string{"host-name": "scanner"}},
+				},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := func(chaddr [16]byte, hostname string) *Packet {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: chaddr},
+			Options: map[uint8][]byte{},
+		}
+		if hostname != "" {
+			packet.Options[OptionHostName] = []byte(hostname)
+		}
+		reply := server.processPacket(packet)
+		if reply == nil {
+			t.Fatalf("Expected reply for %x", chaddr[:6])
+		}
+		return reply
+	}
+
+	// Имя клиента записывается в аренду, но не возвращается ему
+	reply := request([16]byte{0x02, 0, 0, 0, 0, 1}, "laptop")
+	if _, ok := reply.Options[OptionHostName]; ok {
+		t.Error("Expected client supplied hostname not to be echoed")
+	}
+	if domain := string(reply.Options[OptionDomainName]); domain != "lab.example.com" {
+		t.Errorf("Expected domain-name lab.example.com, got %q", domain)
+	}
+	if lease := server.allocatedMAC["02:00:00:00:00:01"]; lease == nil || lease.Hostname != "laptop" {
+		t.Errorf("Expected lease hostname laptop, got %+v", lease)
+	}
+
+	// option host-name имеет приоритет над именем клиента
+	reply = request([16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}, "other")
+	if name := string(reply.Options[OptionHostName]); name != "scanner" {
+		t.Errorf("Expected host-name scanner, got %q", name)
+	}
+
+	// Имя блока host передается только при use-host-decl-names on
+	reply = request([16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, "")
+	if _, ok := reply.Options[OptionHostName]; ok {
+		t.Error("Expected no hostname without use-host-decl-names")
+	}
+	cfg.GlobalOptions["use-host-decl-names"] = "on"
+	reply = request([16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, "")
+	if name := string(reply.Options[OptionHostName]); name != "printer" {
+		t.Errorf("Expected host declaration name printer, got %q", name)
+	}
+
+	// Анонимным клиентам имя генерируется по адресу
+	cfg.GlobalOptions["generate-hostnames"] = "on"
+	reply = request([16]byte{0x02, 0, 0, 0, 0, 2}, "")
+	if name := string(reply.Options[OptionHostName]); name != "dhcp-192-168-1-101" {
+		t.Errorf("Expected generated hostname dhcp-192-168-1-101, got %q", name)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// statusRecorder запоминает код ответа HTTP обработчика
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// NewHTTPBootHandler создает обработчик, отдающий загрузочные файлы из root
+// и сообщающий наблюдателю о каждом скачивании
+func NewHTTPBootHandler(root string, observer FetchObserver) http.Handler {
+	files := http.FileServer(http.Dir(root))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		files.ServeHTTP(recorder, r)
+
+		if observer == nil || r.Method != http.MethodGet {
+			return
+		}
+
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+
+		filename := strings.TrimPrefix(r.URL.Path, "/")
+		if recorder.status >= http.StatusBadRequest {
+			observer(clientIP, "http", filename, fmt.Errorf("status %d", recorder.status))
+			return
+		}
+		observer(clientIP, "http", filename, nil)
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPBootHandler(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "boot.ipxe"), []byte("#!ipxe\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type fetch struct {
+		ip, proto, file string
+		failed          bool
+	}
+	var fetches []fetch
+	observer := func(clientIP, proto, filename string, err error) {
+		fetches = append(fetches, fetch{clientIP, proto, filename, err != nil})
+	}
+
+	handler := NewHTTPBootHandler(root, observer)
+
+	request := httptest.NewRequest(http.MethodGet, "/boot.ipxe", nil)
+	request.RemoteAddr = "192.168.1.100:40000"
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", recorder.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/missing.efi", nil)
+	request.RemoteAddr = "192.168.1.100:40001"
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	if len(fetches) != 2 {
+		t.Fatalf("Expected 2 fetches, got %d", len(fetches))
+	}
+	if fetches[0] != (fetch{"192.168.1.100", "http", "boot.ipxe", false}) {
+		t.Errorf("Unexpected first fetch: %+v", fetches[0])
+	}
+	if !fetches[1].failed {
+		t.Error("Expected second fetch to fail")
+	}
+}
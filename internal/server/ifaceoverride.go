@@ -0,0 +1,143 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// interfaceOverride параметры, заменяющие глобальные для запросов,
+// принятых сокетом интерфейса. Нужны серверу с несколькими сетевыми
+// картами, где каждая смотрит в свою сеть.
+type interfaceOverride struct {
+	serverID   net.IP       // Идентификатор сервера (interface-server-identifier)
+	nextServer net.IP       // siaddr, если next-server не задан (interface-next-server)
+	subnets    []*net.IPNet // Подсети, которыми ограничен выбор адреса (interface-subnets)
+}
+
+// interfaceOverrides параметры интерфейсов по именам
+type interfaceOverrides map[string]*interfaceOverride
+
+// parseInterfaceOverrides читает параметры интерфейсов:
+//
+//	interface-server-identifier "eth0=192.168.1.1, eth1=10.0.0.1";
+//	interface-next-server "eth1=10.0.0.5";
+//	interface-subnets "eth0=192.168.1.0/24, eth1=10.0.0.0/24 10.0.1.0/24";
+func parseInterfaceOverrides(options map[string]string) (interfaceOverrides, error) {
+	overrides := make(interfaceOverrides)
+	entries := func(option string, parse func(override *interfaceOverride, value string) bool) error {
+		for _, item := range strings.Split(strings.Trim(options[option], "\""), ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			name, value, found := strings.Cut(item, "=")
+			name = strings.TrimSpace(name)
+			if !found || name == "" {
+				return fmt.Errorf("invalid %s entry %q, expected <interface>=<value>", option, item)
+			}
+			override := overrides[name]
+			if override == nil {
+				override = &interfaceOverride{}
+				overrides[name] = override
+			}
+			if !parse(override, strings.TrimSpace(value)) {
+				return fmt.Errorf("invalid %s entry %q", option, item)
+			}
+		}
+		return nil
+	}
+
+	if err := entries("interface-server-identifier", func(override *interfaceOverride, value string) bool {
+		return parseOverrideAddress(&override.serverID, value)
+	}); err != nil {
+		return nil, err
+	}
+	if err := entries("interface-next-server", func(override *interfaceOverride, value string) bool {
+		return parseOverrideAddress(&override.nextServer, value)
+	}); err != nil {
+		return nil, err
+	}
+	if err := entries("interface-subnets", func(override *interfaceOverride, value string) bool {
+		if override.subnets != nil {
+			return false
+		}
+		for _, cidr := range strings.Fields(value) {
+			network, err := config.ParseNetwork(cidr)
+			if err != nil {
+				return false
+			}
+			override.subnets = append(override.subnets, network)
+		}
+		return len(override.subnets) > 0
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	return overrides, nil
+}
+
+// parseOverrideAddress разбирает IPv4 адрес параметра интерфейса в target.
+// Повторное значение для того же интерфейса - ошибка.
+func parseOverrideAddress(target *net.IP, value string) bool {
+	ip := net.ParseIP(value).To4()
+	if ip == nil || *target != nil {
+		return false
+	}
+	*target = ip
+	return true
+}
+
+// checkSubnets проверяет, что подсети интерфейсов объявлены в конфигурации
+func (o interfaceOverrides) checkSubnets(subnets []config.Subnet) error {
+	for name, override := range o {
+		for _, network := range override.subnets {
+			found := false
+			for i := range subnets {
+				if subnets[i].Network != nil && subnets[i].Network.String() == network.String() {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("interface-subnets: subnet %s of interface %s is not declared", network, name)
+			}
+		}
+	}
+	return nil
+}
+
+// interfaceOverride возвращает параметры интерфейса name (nil - не заданы)
+func (s *BOOTPServer) interfaceOverride(name string) *interfaceOverride {
+	if name == "" {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.ifaces[name]
+}
+
+// checkInterfaceOverrides предупреждает о параметрах интерфейсов, которые
+// не будут применены: сервер различает интерфейсы только по сокетам,
+// открытым для каждого из них (interfaces, --interface)
+func (s *BOOTPServer) checkInterfaceOverrides() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name := range s.ifaces {
+		bound := false
+		for i := range s.conns {
+			if i < len(s.interfaces) && s.interfaces[i] == name {
+				bound = true
+			}
+		}
+		if !bound {
+			s.logger.Warnf("Overrides for interface %s are ignored, the server has no socket bound to it", name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseInterfaceOverrides(t *testing.T) {
+	if overrides, err := parseInterfaceOverrides(map[string]string{}); err != nil || overrides != nil {
+		t.Errorf("Expected no overrides by default, got %v (%v)", overrides, err)
+	}
+
+	overrides, err := parseInterfaceOverrides(map[string]string{
+		"interface-server-identifier": "\"eth0=192.168.1.1, eth1=10.0.0.1\"",
+		"interface-next-server":       "\"eth1=10.0.0.5\"",
+		"interface-subnets":           "\"eth1=10.0.0.0/24 10.0.1.0/24\"",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth0, eth1 := overrides["eth0"], overrides["eth1"]
+	if eth0 == nil || !eth0.serverID.Equal(net.IPv4(192, 168, 1, 1)) || eth0.nextServer != nil || eth0.subnets != nil {
+		t.Errorf("Unexpected eth0 overrides %+v", eth0)
+	}
+	if eth1 == nil || !eth1.serverID.Equal(net.IPv4(10, 0, 0, 1)) || !eth1.nextServer.Equal(net.IPv4(10, 0, 0, 5)) || len(eth1.subnets) != 2 {
+		t.Errorf("Unexpected eth1 overrides %+v", eth1)
+	}
+
+	for _, options := range []map[string]string{
+		{"interface-server-identifier": "\"eth0\""},
+		{"interface-server-identifier": "\"=192.168.1.1\""},
+		{"interface-server-identifier": "\"eth0=server\""},
+		{"interface-server-identifier": "\"eth0=192.168.1.1, eth0=192.168.1.2\""},
+		{"interface-next-server": "\"eth0=\""},
+		{"interface-subnets": "\"eth0=192.168.1.0\""},
+		{"interface-subnets": "\"eth0=\""},
+	} {
+		if _, err := parseInterfaceOverrides(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+
+	// Подсети интерфейсов должны быть объявлены в конфигурации
+	cfg := &config.DHCPConfig{
+		Subnets:       []config.Subnet{{Network: config.MustParseNetwork("192.168.1.0/24")}},
+		GlobalOptions: map[string]string{"interface-subnets": "\"eth0=192.168.2.0/24\""},
+	}
+	if err := ValidateConfig(cfg); err == nil {
+		t.Error("Expected error for undeclared interface subnet")
+	}
+	cfg.GlobalOptions["interface-subnets"] = "\"eth0=192.168.1.0/24\""
+	if err := ValidateConfig(cfg); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestInterfaceOverrides(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}}},
+			{Network: config.MustParseNetwork("10.0.0.0/24"), Ranges: []config.Range{{Start: "10.0.0.100", End: "10.0.0.110"}}},
+		},
+		GlobalOptions: map[string]string{
+			"server-identifier":           "172.16.0.1",
+			"interface-server-identifier": "\"lan1=10.0.0.1\"",
+			"interface-next-server":       "\"lan1=10.0.0.5\"",
+			"interface-subnets":           "\"lan1=10.0.0.0/24\"",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Сокеты интерфейсов lan0 и lan1
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		server.conns = append(server.conns, conn)
+	}
+	server.interfaces = []string{"lan0", "lan1"}
+
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	tests := []struct {
+		conn     int
+		yiaddr   string
+		siaddr   string
+		serverID string
+	}{
+		{0, "192.168.1.100", "172.16.0.1", "172.16.0.1"}, // Глобальные параметры
+		{1, "10.0.0.100", "10.0.0.5", "10.0.0.1"},        // Параметры lan1
+	}
+	for i, test := range tests {
+		packet := discoverPacket(byte(i + 1))
+		server.handlePacket(server.conns[test.conn], packet, DHCPDiscover, client.LocalAddr().(*net.UDPAddr), nil)
+
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		buffer := make([]byte, maxPacketSize)
+		n, err := client.Read(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		offer, err := DecodePacket(buffer[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if yiaddr := net.IP(offer.Header.Yiaddr[:]).String(); yiaddr != test.yiaddr {
+			t.Errorf("Interface %d: expected yiaddr %s, got %s", test.conn, test.yiaddr, yiaddr)
+		}
+		if siaddr := net.IP(offer.Header.Siaddr[:]).String(); siaddr != test.siaddr {
+			t.Errorf("Interface %d: expected siaddr %s, got %s", test.conn, test.siaddr, siaddr)
+		}
+		if id := net.IP(offer.Options[OptionServerIdentifier]).String(); id != test.serverID {
+			t.Errorf("Interface %d: expected server identifier %s, got %s", test.conn, test.serverID, id)
+		}
+	}
+}
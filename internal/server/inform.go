@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// processInform отвечает на DHCPINFORM клиента со статическим адресом.
+// Ответ DHCPACK содержит опции подсети, в которую входит ciaddr, но не
+// содержит yiaddr и срока аренды (RFC 2131, 3.4): назначение не создается.
+// Возвращает nil, если адрес клиента не входит ни в одну подсеть или
+// клиенту запрещено обслуживание.
+func (s *BOOTPServer) processInform(packet *Packet, response *Packet) *Packet {
+	request := &packet.Header
+	reply := &response.Header
+
+	macAddr := chaddrToMAC(request.Chaddr, request.Hlen)
+	clientID := clientIDString(packet.Options[OptionClientIdentifier])
+
+	ciaddr := net.IP(request.Ciaddr[:])
+	if ciaddr.IsUnspecified() {
+		s.logger.Debugf("Ignoring DHCPINFORM from %s without ciaddr", macAddr)
+		return nil
+	}
+
+	subnet, host, permitted := s.informScope(macAddr, clientID, ipToInt(ciaddr))
+	if subnet == nil {
+		s.logger.Debugf("Ignoring DHCPINFORM from %s: %s is not in a configured subnet", macAddr, ciaddr)
+		return nil
+	}
+	if !permitted {
+		s.logger.Infof("Client %s denied by access rules, ignoring DHCPINFORM", macAddr)
+		return nil
+	}
+
+	copy(reply.Ciaddr[:], request.Ciaddr[:])
+
+	// leased-address в выражениях - адрес, которым пользуется клиент
+	exprCtx := requestContext(request, packet.Options, ciaddr)
+	options := s.clientOptions(macAddr, packet.Options, subnet, host)
+	s.evaluateOptions(options, exprCtx)
+	boot := s.bootParameters(macAddr, packet.Options, subnet, host, options)
+	if err := setBootParameters(response, s.nextServer(s.evaluateBoot(boot, exprCtx))); err != nil {
+		s.logger.Warnf("Not answering DHCPINFORM from %s: %v", macAddr, err)
+		return nil
+	}
+	s.setConfigOptions(response, options, subnet)
+
+	reply.Magic = magicCookie
+	s.logger.Debugf("Answering DHCPINFORM from %s (%s) in subnet %s", macAddr, ciaddr, subnet.Network.IP)
+	return response
+}
+
+// informScope находит подсеть адреса клиента, его блок host и проверяет
+// правила доступа
+func (s *BOOTPServer) informScope(macAddr, clientID string, ip uint32) (*config.Subnet, *config.Host, bool) {
+	macAddr = normalizeMAC(macAddr)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var subnet *config.Subnet
+	for i := range s.config.Subnets {
+		if subnetContains(&s.config.Subnets[i], ip) {
+			subnet = &s.config.Subnets[i]
+			break
+		}
+	}
+	if subnet == nil {
+		return nil, nil, false
+	}
+
+	host := s.hosts[clientKey(macAddr, clientID)]
+	if host == nil {
+		host = s.hosts[macAddr]
+	}
+	permitted := s.isPermitted(macAddr, clientID, &s.config.Access) && s.isPermitted(macAddr, clientID, &subnet.Access)
+	return subnet, host, permitted
+}
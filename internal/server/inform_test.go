@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func newInformTestServer(t *testing.T) *BOOTPServer {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Options: map[string]string{"domain-name": "example.com"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Options: map[string]string{"domain-name": "lab.example.com"},
+				Boot:    config.BootParams{NextServer: "192.168.1.5", Filename: "pxelinux.0"},
+				Access:  config.AccessRules{DenyMACs: []string{"02:00:00:00:00:09"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return server
+}
+
+func informPacket(mac byte, ciaddr string) *Packet {
+	packet := &Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, mac}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPInform}},
+	}
+	if ciaddr != "" {
+		copy(packet.Header.Ciaddr[:], net.ParseIP(ciaddr).To4())
+	}
+	return packet
+}
+
+func TestProcessInform(t *testing.T) {
+	server := newInformTestServer(t)
+
+	reply := server.processPacket(informPacket(1, "192.168.1.50"))
+	if reply == nil {
+		t.Fatal("Expected reply to DHCPINFORM")
+	}
+	if yiaddr := net.IP(reply.Header.Yiaddr[:]); !yiaddr.IsUnspecified() {
+		t.Errorf("Expected empty yiaddr, got %v", yiaddr)
+	}
+	if ciaddr := net.IP(reply.Header.Ciaddr[:]); !ciaddr.Equal(net.IPv4(192, 168, 1, 50)) {
+		t.Errorf("Expected ciaddr 192.168.1.50, got %v", ciaddr)
+	}
+	if domain := string(reply.Options[OptionDomainName]); domain != "lab.example.com" {
+		t.Errorf("Expected subnet domain-name, got %q", domain)
+	}
+	if file := fieldString(reply.Header.File[:]); file != "pxelinux.0" {
+		t.Errorf("Expected boot file pxelinux.0, got %q", file)
+	}
+	for _, code := range []uint8{OptionLeaseTime, OptionRenewalTime, OptionRebindingTime} {
+		if _, exists := reply.Options[code]; exists {
+			t.Errorf("Expected no lease timer option %d in reply to DHCPINFORM", code)
+		}
+	}
+	if leases := server.Leases(); len(leases) != 0 {
+		t.Errorf("Expected no lease to be created, got %+v", leases)
+	}
+
+	// Без ciaddr, вне подсетей и для запрещенных клиентов ответа нет
+	for _, packet := range []*Packet{
+		informPacket(1, ""),
+		informPacket(1, "10.0.0.5"),
+		informPacket(9, "192.168.1.50"),
+	} {
+		if reply := server.processPacket(packet); reply != nil {
+			t.Errorf("Expected DHCPINFORM from %v to be ignored", net.IP(packet.Header.Ciaddr[:]))
+		}
+	}
+}
+
+func TestHandleInform(t *testing.T) {
+	server := newInformTestServer(t)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Запрос через ретранслятор: ответ отправляется ему, а не на ciaddr
+	relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	packet := informPacket(1, "192.168.1.50")
+	copy(packet.Header.Giaddr[:], net.IPv4(192, 168, 1, 1).To4())
+	server.handlePacket(conn, packet, DHCPInform, relay.LocalAddr().(*net.UDPAddr), nil)
+
+	relay.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, maxPacketSize)
+	n, err := relay.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := DecodePacket(buffer[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgType := messageType(ack.Options); msgType != DHCPAck {
+		t.Errorf("Expected DHCPACK, got %d", msgType)
+	}
+	if id := net.IP(ack.Options[OptionServerIdentifier]); !id.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected server identifier 127.0.0.1, got %v", id)
+	}
+	if stats := server.Stats(); stats.Counters.Acks != 1 {
+		t.Errorf("Expected ACK to be counted, got %+v", stats.Counters)
+	}
+}
@@ -0,0 +1,282 @@
+//go:build integration && linux
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// Интеграционные тесты запускают сервер на одном конце пары veth, а
+// клиента - в отдельном сетевом пространстве имен на другом, и проверяют
+// обмен через настоящие сокеты и широковещательные ответы:
+//
+//	sudo go test -tags integration -run Integration ./internal/server
+//
+// Нужны права root и утилита ip (iproute2).
+
+// integrationNetwork пространство имен клиента и пара veth
+type integrationNetwork struct {
+	namespace string // Сетевое пространство имен клиента
+	serverIf  string // Интерфейс сервера в исходном пространстве имен
+	clientIf  string // Интерфейс клиента в пространстве имен namespace
+	serverIP  net.IP
+}
+
+// newIntegrationNetwork создает пространство имен с парой veth
+// 10.99.0.1/24 (сервер) - без адреса (клиент) и удаляет их по завершении
+// теста
+func newIntegrationNetwork(t *testing.T) *integrationNetwork {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("integration tests require root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("integration tests require iproute2")
+	}
+
+	id := os.Getpid() % 100000
+	network := &integrationNetwork{
+		namespace: fmt.Sprintf("go-bootp-%d", id),
+		serverIf:  fmt.Sprintf("gbs%d", id),
+		clientIf:  fmt.Sprintf("gbc%d", id),
+		serverIP:  net.IPv4(10, 99, 0, 1).To4(),
+	}
+	t.Cleanup(func() {
+		// Удаление пространства имен удаляет и пару veth
+		exec.Command("ip", "netns", "del", network.namespace).Run()
+		exec.Command("ip", "link", "del", network.serverIf).Run()
+	})
+
+	network.run(t, "ip", "netns", "add", network.namespace)
+	network.run(t, "ip", "link", "add", network.serverIf, "type", "veth", "peer", "name", network.clientIf)
+	network.run(t, "ip", "link", "set", network.clientIf, "netns", network.namespace)
+	network.run(t, "ip", "addr", "add", network.serverIP.String()+"/24", "dev", network.serverIf)
+	network.run(t, "ip", "link", "set", network.serverIf, "up")
+	network.exec(t, "ip", "link", "set", "lo", "up")
+	network.exec(t, "ip", "link", "set", network.clientIf, "up")
+	return network
+}
+
+// run выполняет команду в исходном пространстве имен
+func (n *integrationNetwork) run(t *testing.T, name string, args ...string) {
+	t.Helper()
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		t.Fatalf("%s %v: %v\n%s", name, args, err, output)
+	}
+}
+
+// exec выполняет команду в пространстве имен клиента
+func (n *integrationNetwork) exec(t *testing.T, name string, args ...string) {
+	t.Helper()
+	n.run(t, "ip", append([]string{"netns", "exec", n.namespace, name}, args...)...)
+}
+
+// enter вызывает fn в потоке, переключенном в пространство имен клиента.
+// Сокеты, созданные в fn, остаются в этом пространстве имен.
+func (n *integrationNetwork) enter(t *testing.T, fn func()) {
+	t.Helper()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	original, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer original.Close()
+	target, err := os.Open("/run/netns/" + n.namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	if err := setns(target); err != nil {
+		t.Fatalf("setns: %v", err)
+	}
+	defer func() {
+		if err := setns(original); err != nil {
+			// Поток нельзя вернуть в пул с чужим пространством имен
+			panic(err)
+		}
+	}()
+	fn()
+}
+
+func setns(file *os.File) error {
+	return unix.Setns(int(file.Fd()), unix.CLONE_NEWNET)
+}
+
+// startIntegrationServer запускает сервер на интерфейсе serverIf
+func startIntegrationServer(t *testing.T, network *integrationNetwork) *BOOTPServer {
+	t.Helper()
+
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("10.99.0.0/24"),
+				Ranges:  []config.Range{{Start: "10.99.0.100", End: "10.99.0.110"}},
+				Options: map[string]string{"routers": "10.99.0.1"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SetInterfaces([]string{network.serverIf}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// integrationClient DHCP клиент на интерфейсе в пространстве имен клиента
+type integrationClient struct {
+	conn *net.UDPConn
+	mac  net.HardwareAddr
+	xid  uint32
+}
+
+// newIntegrationClient открывает порт 68 на интерфейсе клиента
+func newIntegrationClient(t *testing.T, network *integrationNetwork) *integrationClient {
+	t.Helper()
+
+	client := &integrationClient{xid: uint32(time.Now().UnixNano())}
+	network.enter(t, func() {
+		iface, err := net.InterfaceByName(network.clientIf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.mac = iface.HardwareAddr
+
+		// Без адреса на интерфейсе широковещательный запрос уходит только
+		// с привязкой к интерфейсу
+		lc := net.ListenConfig{Control: func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1); sockErr != nil {
+					return
+				}
+				sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, network.clientIf)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		}}
+		conn, err := lc.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", BOOTP_CLIENT_PORT))
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.conn = conn.(*net.UDPConn)
+	})
+	t.Cleanup(func() { client.conn.Close() })
+	return client
+}
+
+// exchange отправляет запрос на адрес dst и ждет ответ с тем же xid
+func (c *integrationClient) exchange(t *testing.T, dst net.IP, ciaddr net.IP, options map[uint8][]byte) *Packet {
+	t.Helper()
+
+	c.xid++
+	header := BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Xid: c.xid, Magic: magicCookie}
+	copy(header.Chaddr[:], c.mac)
+	if ciaddr != nil {
+		copy(header.Ciaddr[:], ciaddr.To4())
+	}
+	data := EncodeReply(&header, options)
+	if _, err := c.conn.WriteToUDP(data, &net.UDPAddr{IP: dst, Port: BOOTP_PORT}); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := make([]byte, maxPacketSize)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		c.conn.SetReadDeadline(deadline)
+		n, err := c.conn.Read(buffer)
+		if err != nil {
+			t.Fatalf("No reply to xid %#x: %v", c.xid, err)
+		}
+		reply, err := DecodePacket(buffer[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.Header.Op == BOOTPReply && reply.Header.Xid == c.xid {
+			return reply
+		}
+	}
+}
+
+func TestIntegrationExchange(t *testing.T) {
+	network := newIntegrationNetwork(t)
+	server := startIntegrationServer(t, network)
+	client := newIntegrationClient(t, network)
+
+	// DISCOVER -> OFFER широковещательно
+	offer := client.exchange(t, net.IPv4bcast, nil, map[uint8][]byte{OptionMessageType: {DHCPDiscover}})
+	if messageType(offer.Options) != DHCPOffer {
+		t.Fatalf("Expected OFFER, got %v", offer.Options[OptionMessageType])
+	}
+	yiaddr := net.IP(offer.Header.Yiaddr[:])
+	if !yiaddr.Equal(net.IPv4(10, 99, 0, 100)) {
+		t.Errorf("Expected 10.99.0.100 offered, got %v", yiaddr)
+	}
+	if id := net.IP(offer.Options[OptionServerIdentifier]); !id.Equal(network.serverIP) {
+		t.Errorf("Expected server identifier %v, got %v", network.serverIP, id)
+	}
+	if router := net.IP(offer.Options[3]); !router.Equal(network.serverIP) {
+		t.Errorf("Expected router %v, got %v", network.serverIP, router)
+	}
+
+	// REQUEST -> ACK широковещательно
+	ack := client.exchange(t, net.IPv4bcast, nil, map[uint8][]byte{
+		OptionMessageType:      {DHCPRequest},
+		OptionRequestedIP:      yiaddr.To4(),
+		OptionServerIdentifier: offer.Options[OptionServerIdentifier],
+	})
+	if messageType(ack.Options) != DHCPAck || !net.IP(ack.Header.Yiaddr[:]).Equal(yiaddr) {
+		t.Fatalf("Expected ACK for %v, got %v %v", yiaddr, ack.Options[OptionMessageType], net.IP(ack.Header.Yiaddr[:]))
+	}
+
+	// Продление с ciaddr отправляется серверу и возвращается клиенту напрямую
+	network.exec(t, "ip", "addr", "add", yiaddr.String()+"/24", "dev", network.clientIf)
+	renew := client.exchange(t, network.serverIP, yiaddr, map[uint8][]byte{OptionMessageType: {DHCPRequest}})
+	if messageType(renew.Options) != DHCPAck || !net.IP(renew.Header.Yiaddr[:]).Equal(yiaddr) {
+		t.Fatalf("Expected renewal ACK for %v, got %v", yiaddr, renew.Options[OptionMessageType])
+	}
+
+	leases := server.Leases()
+	if len(leases) != 1 || leases[0].IP != yiaddr.String() || leases[0].MAC != client.mac.String() {
+		t.Errorf("Expected lease of %v for %v, got %+v", yiaddr, client.mac, leases)
+	}
+}
+
+func TestIntegrationUdhcpc(t *testing.T) {
+	udhcpc, err := exec.LookPath("udhcpc")
+	if err != nil {
+		t.Skip("udhcpc not found")
+	}
+	network := newIntegrationNetwork(t)
+	server := startIntegrationServer(t, network)
+
+	// -s /bin/true: адрес не настраивается, достаточно полученной аренды
+	network.exec(t, udhcpc, "-f", "-q", "-n", "-t", "3", "-T", "1", "-s", "/bin/true", "-i", network.clientIf)
+
+	if leases := server.Leases(); len(leases) != 1 || leases[0].IP != "10.99.0.100" {
+		t.Errorf("Expected lease of 10.99.0.100, got %+v", leases)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// resolveInterfaces проверяет имена интерфейсов и раскрывает шаблоны
+// path.Match (net*, eth[01]): в контейнере имена интерфейсов macvlan и
+// ipvlan, добавленных CNI, заранее не известны. Суффикс @ifN, с которым
+// ip link выводит macvlan и veth (net1@if12), отбрасывается. Шаблонам не
+// соответствуют петлевые интерфейсы.
+func resolveInterfaces(names []string) ([]string, error) {
+	var resolved []string
+	var all []net.Interface
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			resolved = append(resolved, name)
+		}
+	}
+
+	for _, name := range names {
+		if i := strings.IndexByte(name, '@'); i > 0 {
+			name = name[:i]
+		}
+		if !strings.ContainsAny(name, "*?[") {
+			if _, err := net.InterfaceByName(name); err != nil {
+				return nil, fmt.Errorf("interface %s: %v", name, err)
+			}
+			add(name)
+			continue
+		}
+
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("interface pattern %s: %v", name, err)
+		}
+		if all == nil {
+			var err error
+			if all, err = net.Interfaces(); err != nil {
+				return nil, err
+			}
+		}
+		matched := false
+		for _, iface := range all {
+			if ok, _ := path.Match(name, iface.Name); ok && iface.Flags&net.FlagLoopback == 0 {
+				add(iface.Name)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("interface pattern %s matches no interfaces", name)
+		}
+	}
+	return resolved, nil
+}
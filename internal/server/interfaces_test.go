@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestResolveInterfaces(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("Cannot list interfaces: %v", err)
+	}
+	var loopback, other string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+		} else if other == "" {
+			other = iface.Name
+		}
+	}
+	if loopback == "" {
+		t.Skip("No loopback interface")
+	}
+
+	// Имя в виде вывода ip link и повтор сводятся к одному интерфейсу
+	names, err := resolveInterfaces([]string{loopback + "@if12", loopback})
+	if err != nil || !reflect.DeepEqual(names, []string{loopback}) {
+		t.Errorf("Expected [%s], got %v (%v)", loopback, names, err)
+	}
+
+	// Петлевой интерфейс не попадает под шаблон
+	if names, err := resolveInterfaces([]string{loopback[:1] + "*"}); err == nil && len(names) > 0 && names[0] == loopback {
+		t.Errorf("Expected loopback to be excluded from pattern, got %v", names)
+	}
+	if other != "" {
+		if names, err := resolveInterfaces([]string{other[:1] + "*"}); err != nil || len(names) == 0 {
+			t.Errorf("Expected pattern to match %s, got %v (%v)", other, names, err)
+		}
+	}
+
+	for _, bad := range []string{"go-bootp-missing0", "go-bootp-missing*", "eth["} {
+		if _, err := resolveInterfaces([]string{bad}); err == nil {
+			t.Errorf("Expected %q to fail", bad)
+		}
+	}
+}
+
+func TestPermissionError(t *testing.T) {
+	err := &net.OpError{Op: "listen", Net: "udp", Err: net.UnknownNetworkError("udp9")}
+	if permissionError(err, capNetBindService, "listening on port 67") != err {
+		t.Error("Expected unrelated error to be returned unchanged")
+	}
+	if permissionError(nil, capNetRaw, "binding to an interface") != nil {
+		t.Error("Expected nil error to stay nil")
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInventoryClients ограничивает число клиентов в описи: новые клиенты
+// сверх него не записываются
+const maxInventoryClients = 65536
+
+// InventoryEntry клиент, замеченный в режиме обучения
+type InventoryEntry struct {
+	MAC         string    `json:"mac"`
+	ClientID    string    `json:"client_id,omitempty"`
+	Hostname    string    `json:"hostname,omitempty"`
+	VendorClass string    `json:"vendor_class,omitempty"`
+	Arch        []uint16  `json:"arch,omitempty"`
+	Relay       string    `json:"relay,omitempty"`      // giaddr последнего запроса
+	CircuitID   string    `json:"circuit_id,omitempty"` // Подопция 1 опции 82
+	RemoteID    string    `json:"remote_id,omitempty"`  // Подопция 2 опции 82
+	Interface   string    `json:"interface,omitempty"`
+	VLAN        uint16    `json:"vlan,omitempty"`
+	LastMessage string    `json:"last_message"`
+	Requests    uint64    `json:"requests"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// clientInventory опись клиентов по MAC адресам
+type clientInventory struct {
+	mutex   sync.Mutex
+	clients map[string]*InventoryEntry
+	full    bool // Предупреждение о переполнении уже выведено
+}
+
+func newClientInventory() *clientInventory {
+	return &clientInventory{clients: make(map[string]*InventoryEntry)}
+}
+
+// parseLearningMode читает режим обучения:
+//
+//	learning-mode on;
+//
+// В режиме обучения сервер ни на что не отвечает, а только записывает
+// запрашивающих клиентов в опись (см. Inventory). Так можно узнать, кто
+// есть в сети, до включения сервера.
+func parseLearningMode(options map[string]string) (bool, error) {
+	value, ok := options["learning-mode"]
+	if !ok {
+		return false, nil
+	}
+	switch strings.ToLower(strings.Trim(value, "\"")) {
+	case "on", "true", "yes":
+		return true, nil
+	case "off", "false", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid learning-mode: %s (must be on or off)", value)
+}
+
+// learningMiddleware в режиме обучения записывает клиента в опись и
+// оставляет запрос без ответа. Стоит первым в цепочке: в режиме обучения
+// не отвечают и добавленные обработчики.
+func (s *BOOTPServer) learningMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		s.mutex.Lock()
+		learning := s.learning
+		s.mutex.Unlock()
+
+		if !learning {
+			return next(ctx, req)
+		}
+		if !s.inventory.record(req, s.clock.Now()) {
+			s.logger.Warnf("Client inventory is full (%d clients), new clients are not recorded", maxInventoryClients)
+		}
+		return nil, nil
+	}
+}
+
+// record добавляет запрос в опись. Возвращает false один раз, когда опись
+// переполнилась и клиент не записан.
+func (i *clientInventory) record(req *Request, now time.Time) bool {
+	header := &req.Packet.Header
+	options := req.Packet.Options
+	macAddr := chaddrToMAC(header.Chaddr, header.Hlen)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	entry := i.clients[macAddr]
+	if entry == nil {
+		if len(i.clients) >= maxInventoryClients {
+			if i.full {
+				return true
+			}
+			i.full = true
+			return false
+		}
+		entry = &InventoryEntry{MAC: macAddr, FirstSeen: now}
+		i.clients[macAddr] = entry
+	}
+
+	info := requestClientInfo(options)
+	entry.ClientID = info.clientID
+	entry.VendorClass = info.vendorClass
+	entry.Arch = info.arch
+	entry.Hostname = string(options[OptionHostName])
+	entry.Relay = ""
+	if giaddr := net.IP(header.Giaddr[:]); !giaddr.IsUnspecified() {
+		entry.Relay = giaddr.String()
+	}
+	suboptions := relayAgentSuboptions(options[OptionRelayAgentInfo])
+	entry.CircuitID = relayAgentID(suboptions[1])
+	entry.RemoteID = relayAgentID(suboptions[2])
+	entry.Interface = req.Interface
+	entry.VLAN = req.VLAN
+	entry.LastMessage = messageTypeName(req.MessageType)
+	entry.Requests++
+	entry.LastSeen = now
+	return true
+}
+
+// entries возвращает копию описи, упорядоченную по MAC адресам
+func (i *clientInventory) entries() []InventoryEntry {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	entries := make([]InventoryEntry, 0, len(i.clients))
+	for _, entry := range i.clients {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].MAC < entries[b].MAC })
+	return entries
+}
+
+// relayAgentSuboptions разбирает подопции опции 82 (RFC 3046).
+// Разбор останавливается на усеченной подопции.
+func relayAgentSuboptions(value []byte) map[uint8][]byte {
+	suboptions := make(map[uint8][]byte)
+	for len(value) >= 2 {
+		code, length := value[0], int(value[1])
+		if len(value) < 2+length {
+			break
+		}
+		suboptions[code] = value[2 : 2+length]
+		value = value[2+length:]
+	}
+	return suboptions
+}
+
+// relayAgentID представляет идентификатор из опции 82 строкой, если он
+// печатаемый, иначе шестнадцатеричными байтами
+func relayAgentID(value []byte) string {
+	for _, b := range value {
+		if b < 0x20 || b > 0x7e {
+			return clientIDString(value)
+		}
+	}
+	return string(value)
+}
+
+// messageTypeName возвращает имя типа DHCP сообщения
+func messageTypeName(msgType uint8) string {
+	switch msgType {
+	case 0:
+		return "BOOTREQUEST"
+	case DHCPDiscover:
+		return "DHCPDISCOVER"
+	case DHCPRequest:
+		return "DHCPREQUEST"
+	case DHCPDecline:
+		return "DHCPDECLINE"
+	case DHCPRelease:
+		return "DHCPRELEASE"
+	case DHCPInform:
+		return "DHCPINFORM"
+	}
+	return strconv.Itoa(int(msgType))
+}
+
+// Learning сообщает, включен ли режим обучения
+func (s *BOOTPServer) Learning() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.learning
+}
+
+// Inventory возвращает клиентов, замеченных в режиме обучения
+func (s *BOOTPServer) Inventory() []InventoryEntry {
+	return s.inventory.entries()
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseLearningMode(t *testing.T) {
+	tests := []struct {
+		options map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{map[string]string{}, false, false},
+		{map[string]string{"learning-mode": "on"}, true, false},
+		{map[string]string{"learning-mode": "\"off\""}, false, false},
+		{map[string]string{"learning-mode": "maybe"}, false, true},
+	}
+	for _, test := range tests {
+		learning, err := parseLearningMode(test.options)
+		if (err != nil) != test.wantErr || learning != test.want {
+			t.Errorf("parseLearningMode(%v) = %v, %v; want %v", test.options, learning, err, test.want)
+		}
+	}
+}
+
+func TestLearningMode(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"learning-mode": "on"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Добавленные обработчики в режиме обучения не вызываются
+	calls := 0
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Packet, error) {
+			calls++
+			return next(ctx, req)
+		}
+	})
+
+	packet := discoverPacket(1)
+	packet.Options[OptionHostName] = []byte("pc-1")
+	packet.Options[OptionVendorClass] = []byte("PXEClient:Arch:00007")
+	if reply := server.processPacket(packet); reply != nil {
+		t.Error("Expected no reply in learning mode")
+	}
+
+	// Запрос через релей с опцией 82
+	relayed := discoverPacket(2)
+	relayed.Header.Giaddr = [4]byte{10, 0, 0, 1}
+	relayed.Options[OptionMessageType] = []byte{DHCPRequest}
+	relayed.Options[OptionRelayAgentInfo] = []byte{1, 6, 's', 'w', '1', '/', '1', '2', 2, 2, 0x00, 0xff}
+	server.processPacket(relayed)
+	server.processPacket(packet)
+
+	entries := server.Inventory()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 inventory entries, got %d", len(entries))
+	}
+	first, second := entries[0], entries[1]
+	if first.MAC != "02:00:00:00:00:01" || first.Hostname != "pc-1" || first.VendorClass != "PXEClient:Arch:00007" ||
+		first.Requests != 2 || first.LastMessage != "DHCPDISCOVER" {
+		t.Errorf("Unexpected entry %+v", first)
+	}
+	if second.Relay != "10.0.0.1" || second.CircuitID != "sw1/12" || second.RemoteID != "00:ff" || second.LastMessage != "DHCPREQUEST" {
+		t.Errorf("Unexpected relayed entry %+v", second)
+	}
+	if leases := server.Leases(); len(leases) != 0 || calls != 0 {
+		t.Errorf("Expected no leases and middleware calls in learning mode, got %v and %d calls", leases, calls)
+	}
+
+	// После выключения режима обучения сервер отвечает
+	cfg.GlobalOptions["learning-mode"] = "off"
+	if err := server.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	reply := server.processPacket(discoverPacket(1))
+	if reply == nil || net.IP(reply.Header.Yiaddr[:]).IsUnspecified() {
+		t.Error("Expected offer after learning mode is disabled")
+	}
+	if len(server.Inventory()) != 2 {
+		t.Error("Expected inventory to be kept after learning mode is disabled")
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultIPAMTimeout время ожидания ответа внешней IPAM
+const defaultIPAMTimeout = 2 * time.Second
+
+// IPAMDriver источник адресов клиентов во внешней системе IPAM
+type IPAMDriver interface {
+	// LookupAddress возвращает IPv4 адрес, назначенный MAC адресу в IPAM
+	// (nil - записи нет)
+	LookupAddress(ctx context.Context, mac string) (net.IP, error)
+}
+
+// NewIPAMDriver создает драйвер IPAM по глобальным опциям конфигурации:
+// ipam-driver netbox; ipam-url "https://netbox.example.com";
+// ipam-token "..."; ipam-timeout 2000; (мс).
+// Возвращает nil, если ipam-driver не задан.
+func NewIPAMDriver(options map[string]string) (IPAMDriver, error) {
+	driver := strings.Trim(options["ipam-driver"], "\"")
+	if driver == "" {
+		return nil, nil
+	}
+
+	base := strings.TrimRight(strings.Trim(options["ipam-url"], "\""), "/")
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		return nil, fmt.Errorf("ipam-url must be an http(s) URL: %s", base)
+	}
+
+	client := &http.Client{Timeout: defaultIPAMTimeout}
+	if value, ok := options["ipam-timeout"]; ok {
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid ipam-timeout: %s", value)
+		}
+		client.Timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	switch driver {
+	case "netbox":
+		return &netboxIPAM{url: base, token: strings.Trim(options["ipam-token"], "\""), client: client}, nil
+	}
+	return nil, fmt.Errorf("unsupported ipam-driver: %s", driver)
+}
+
+// netboxIPAM ищет адреса в NetBox: интерфейс устройства или виртуальной
+// машины с MAC адресом клиента и IPv4 адрес, назначенный этому интерфейсу
+type netboxIPAM struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// netboxList страница результатов REST API NetBox
+type netboxList struct {
+	Results []struct {
+		ID      int    `json:"id"`
+		Address string `json:"address"` // Адрес с длиной префикса (192.168.1.10/24)
+		Status  struct {
+			Value string `json:"value"`
+		} `json:"status"`
+	} `json:"results"`
+}
+
+// netboxInterfaceKinds пути интерфейсов и фильтры адресов по ним
+var netboxInterfaceKinds = []struct {
+	path   string
+	filter string
+}{
+	{"dcim/interfaces", "interface_id"},
+	{"virtualization/interfaces", "vminterface_id"},
+}
+
+// LookupAddress ищет интерфейс с MAC адресом сначала среди устройств,
+// затем среди виртуальных машин. Устаревшие (deprecated) адреса
+// пропускаются.
+func (n *netboxIPAM) LookupAddress(ctx context.Context, mac string) (net.IP, error) {
+	for _, kind := range netboxInterfaceKinds {
+		var interfaces netboxList
+		if err := n.get(ctx, kind.path, url.Values{"mac_address": {mac}}, &interfaces); err != nil {
+			return nil, err
+		}
+
+		for _, iface := range interfaces.Results {
+			var addresses netboxList
+			query := url.Values{kind.filter: {strconv.Itoa(iface.ID)}, "family": {"4"}}
+			if err := n.get(ctx, "ipam/ip-addresses", query, &addresses); err != nil {
+				return nil, err
+			}
+			for _, address := range addresses.Results {
+				if address.Status.Value == "deprecated" {
+					continue
+				}
+				if ip, _, err := net.ParseCIDR(address.Address); err == nil && ip.To4() != nil {
+					return ip.To4(), nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// get выполняет запрос к REST API NetBox и разбирает ответ в result
+func (n *netboxIPAM) get(ctx context.Context, path string, query url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.url+"/api/"+path+"/?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Token "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%s: invalid response: %v", path, err)
+	}
+	return nil
+}
+
+// ipamOffer заменяет адрес динамического предложения адресом из IPAM.
+// Если в IPAM нет записи, она недоступна или ее адрес нельзя выдать в
+// подсети клиента, предложение из локального пула не меняется. Клиенты
+// со статическим назначением в IPAM не ищутся.
+func (s *BOOTPServer) ipamOffer(ctx context.Context, macAddr string, offer *leaseOffer) {
+	ipam := s.ipamDriver()
+	if ipam == nil || offer.existing != nil && offer.existing.Type == StaticAllocation {
+		return
+	}
+
+	ip, err := ipam.LookupAddress(ctx, normalizeMAC(macAddr))
+	if err != nil {
+		s.logger.Warnf("IPAM lookup for %s failed, using local pools: %v", macAddr, err)
+		return
+	}
+	if ip == nil || ipToInt(ip) == offer.ip {
+		return
+	}
+	if err := s.reassignIP(macAddr, offer, ip.String()); err != nil {
+		s.logger.Warnf("IPAM address %s for %s cannot be used, using local pools: %v", ip, macAddr, err)
+		return
+	}
+	s.logger.Debugf("Using IPAM address %s for %s", ip, macAddr)
+}
+
+// ipamDriver возвращает текущий драйвер IPAM (nil - не настроен)
+func (s *BOOTPServer) ipamDriver() IPAMDriver {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.ipam
+}
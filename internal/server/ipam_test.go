@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// newNetboxTestServer эмулирует REST API NetBox: интерфейс устройства
+// 02:00:00:00:00:01 с адресом 192.168.1.50 и интерфейс виртуальной машины
+// 02:00:00:00:00:02 с адресом вне подсети клиента
+func newNetboxTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		query := r.URL.Query()
+		switch {
+		case r.URL.Path == "/api/dcim/interfaces/" && query.Get("mac_address") == "02:00:00:00:00:01":
+			w.Write([]byte(`{"count": 1, "results": [{"id": 7}]}`))
+		case r.URL.Path == "/api/virtualization/interfaces/" && query.Get("mac_address") == "02:00:00:00:00:02":
+			w.Write([]byte(`{"count": 1, "results": [{"id": 9}]}`))
+		case r.URL.Path == "/api/ipam/ip-addresses/" && query.Get("interface_id") == "7":
+			w.Write([]byte(`{"count": 2, "results": [
+				{"id": 1, "address": "192.168.1.40/24", "status": {"value": "deprecated"}},
+				{"id": 2, "address": "192.168.1.50/24", "status": {"value": "active"}}
+			]}`))
+		case r.URL.Path == "/api/ipam/ip-addresses/" && query.Get("vminterface_id") == "9":
+			w.Write([]byte(`{"count": 1, "results": [{"id": 3, "address": "10.0.0.9/24", "status": {"value": "active"}}]}`))
+		default:
+			w.Write([]byte(`{"count": 0, "results": []}`))
+		}
+	}))
+}
+
+func newIPAMTestServer(t *testing.T, url string) *BOOTPServer {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+		Hosts: []config.Host{
+			{Name: "static", Hardware: "02:00:00:00:00:03", FixedIP: "192.168.1.10"},
+		},
+		GlobalOptions: map[string]string{
+			"ipam-driver": "netbox",
+			"ipam-url":    "\"" + url + "\"",
+			"ipam-token":  "\"secret\"",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return server
+}
+
+func TestNewIPAMDriver(t *testing.T) {
+	if driver, err := NewIPAMDriver(map[string]string{}); err != nil || driver != nil {
+		t.Errorf("Expected no IPAM driver by default, got %v (%v)", driver, err)
+	}
+
+	for _, options := range []map[string]string{
+		{"ipam-driver": "phpipam", "ipam-url": "\"http://ipam.local\""},
+		{"ipam-driver": "netbox", "ipam-url": "\"ipam.local\""},
+		{"ipam-driver": "netbox", "ipam-url": "\"http://ipam.local\"", "ipam-timeout": "0"},
+	} {
+		if _, err := NewIPAMDriver(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
+
+func TestNetboxLookupAddress(t *testing.T) {
+	netbox := newNetboxTestServer()
+	defer netbox.Close()
+
+	driver, err := NewIPAMDriver(map[string]string{"ipam-driver": "netbox", "ipam-url": "\"" + netbox.URL + "/\"", "ipam-token": "\"secret\""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		mac  string
+		want net.IP
+	}{
+		{"02:00:00:00:00:01", net.IPv4(192, 168, 1, 50)},
+		{"02:00:00:00:00:02", net.IPv4(10, 0, 0, 9)},
+		{"02:00:00:00:00:09", nil},
+	}
+	for _, test := range tests {
+		ip, err := driver.LookupAddress(context.Background(), test.mac)
+		if err != nil {
+			t.Fatalf("%s: lookup failed: %v", test.mac, err)
+		}
+		if !ip.Equal(test.want) {
+			t.Errorf("%s: expected %v, got %v", test.mac, test.want, ip)
+		}
+	}
+
+	// Без токена NetBox отвечает ошибкой
+	driver, _ = NewIPAMDriver(map[string]string{"ipam-driver": "netbox", "ipam-url": "\"" + netbox.URL + "\""})
+	if _, err := driver.LookupAddress(context.Background(), "02:00:00:00:00:01"); err == nil {
+		t.Error("Expected error for forbidden request")
+	}
+}
+
+func TestIPAMAllocation(t *testing.T) {
+	netbox := newNetboxTestServer()
+	server := newIPAMTestServer(t, netbox.URL)
+
+	tests := []struct {
+		mac  byte
+		want string
+	}{
+		{1, "192.168.1.50"},  // Адрес из IPAM
+		{2, "192.168.1.100"}, // Адрес IPAM вне подсети: локальный пул
+		{3, "192.168.1.10"},  // Статическое назначение из конфигурации
+		{4, "192.168.1.101"}, // Нет записи в IPAM: локальный пул
+	}
+	for _, test := range tests {
+		reply := server.processPacket(discoverPacket(test.mac))
+		if reply == nil {
+			t.Fatalf("Expected reply for client %d", test.mac)
+		}
+		if yiaddr := net.IP(reply.Header.Yiaddr[:]).String(); yiaddr != test.want {
+			t.Errorf("Client %d: expected %s, got %s", test.mac, test.want, yiaddr)
+		}
+	}
+
+	// Недоступная IPAM не мешает выдаче адресов из пула
+	netbox.Close()
+	reply := server.processPacket(discoverPacket(5))
+	if reply == nil || net.IP(reply.Header.Yiaddr[:]).String() != "192.168.1.102" {
+		t.Errorf("Expected fallback to local pool, got %+v", reply)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Опции клиента, из которых собирается сценарий iPXE. Задаются на любом
+// уровне конфигурации и наследуются как остальные опции; в ответ DHCP не
+// попадают.
+const (
+	ipxeKernelOption  = "ipxe-kernel"  // Адрес ядра
+	ipxeInitrdOption  = "ipxe-initrd"  // Адреса initrd через запятую
+	ipxeCmdlineOption = "ipxe-cmdline" // Параметры ядра
+)
+
+// ipxeScriptPath возвращает путь сценария iPXE на HTTP сервере загрузки
+// (опция ipxe-script-path; пусто - сценарии не отдаются)
+func ipxeScriptPath(options map[string]string) string {
+	path := strings.Trim(options["ipxe-script-path"], "\"")
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// ipxeScriptHandler отдает по пути path сценарий iPXE клиента, остальные
+// запросы передает next. Клиент определяется по параметру mac (в iPXE -
+// ${net0/mac}), а без него - по адресу, с которого пришел запрос.
+func (s *BOOTPServer) ipxeScriptHandler(path string, observer FetchObserver, next http.Handler) http.Handler {
+	filename := strings.TrimPrefix(path, "/")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+		addr := r.URL.Query().Get("mac")
+		if addr == "" {
+			addr = clientIP
+		}
+		client, err := s.ClientConfig(addr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s: %v", addr, err), http.StatusNotFound)
+			if observer != nil && r.Method == http.MethodGet {
+				observer(clientIP, "http", filename, fmt.Errorf("status %d", http.StatusNotFound))
+			}
+			return
+		}
+
+		s.logger.Debugf("Serving iPXE script to %s (%s)", client.MAC, client.IP)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, ipxeScript(client.Options))
+		if observer != nil && r.Method == http.MethodGet {
+			observer(clientIP, "http", filename, nil)
+		}
+	})
+}
+
+// ipxeScript собирает сценарий iPXE из опций клиента. Без ядра сценарий
+// завершает iPXE, и прошивка переходит к следующему устройству загрузки.
+func ipxeScript(options map[string]string) string {
+	var script strings.Builder
+	script.WriteString("#!ipxe\n")
+
+	kernel := options[ipxeKernelOption]
+	if kernel == "" {
+		script.WriteString("exit\n")
+		return script.String()
+	}
+	script.WriteString("kernel " + kernel)
+	if cmdline := options[ipxeCmdlineOption]; cmdline != "" {
+		script.WriteString(" " + cmdline)
+	}
+	script.WriteString("\n")
+	for _, initrd := range strings.Split(options[ipxeInitrdOption], ",") {
+		if initrd = strings.TrimSpace(initrd); initrd != "" {
+			script.WriteString("initrd " + initrd + "\n")
+		}
+	}
+	script.WriteString("boot\n")
+	return script.String()
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestIPXEScript(t *testing.T) {
+	script := ipxeScript(map[string]string{
+		"ipxe-kernel":  "http://boot/vmlinuz",
+		"ipxe-initrd":  "http://boot/initrd.img, http://boot/firmware.cpio",
+		"ipxe-cmdline": "console=ttyS0",
+	})
+	want := "#!ipxe\nkernel http://boot/vmlinuz console=ttyS0\ninitrd http://boot/initrd.img\ninitrd http://boot/firmware.cpio\nboot\n"
+	if script != want {
+		t.Errorf("Unexpected script:\n%s", script)
+	}
+
+	if script := ipxeScript(map[string]string{}); script != "#!ipxe\nexit\n" {
+		t.Errorf("Expected exit without kernel, got:\n%s", script)
+	}
+
+	if path := ipxeScriptPath(map[string]string{"ipxe-script-path": `"boot.ipxe"`}); path != "/boot.ipxe" {
+		t.Errorf("Expected /boot.ipxe, got %s", path)
+	}
+}
+
+func TestIPXEScriptHandler(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Options: map[string]string{"ipxe-kernel": "http://boot/vmlinuz"},
+		Subnets: []config.Subnet{{
+			Network: config.MustParseNetwork("192.168.1.0/24"),
+			Hosts: []config.Host{{
+				Name:     "client1",
+				Hardware: "00:11:22:33:44:55",
+				FixedIP:  "192.168.1.10",
+				Options:  map[string]string{"ipxe-cmdline": "id={{.MAC}}-{{.IP}}"},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	var fetches []string
+	observer := func(clientIP, proto, filename string, err error) {
+		fetches = append(fetches, clientIP+" "+filename)
+	}
+	handler := server.ipxeScriptHandler("/boot.ipxe", observer, http.NotFoundHandler())
+
+	const script = "#!ipxe\nkernel http://boot/vmlinuz id=00:11:22:33:44:55-192.168.1.10\nboot\n"
+	tests := []struct {
+		path   string
+		remote string
+		code   int
+		body   string
+	}{
+		{"/boot.ipxe?mac=00-11-22-33-44-55", "192.0.2.1:1234", http.StatusOK, script},
+		{"/boot.ipxe", "192.168.1.10:1234", http.StatusOK, script},
+		{"/boot.ipxe", "192.168.1.11:1234", http.StatusNotFound, ""},
+		{"/vmlinuz", "192.168.1.10:1234", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		request.RemoteAddr = test.remote
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.code || (test.body != "" && recorder.Body.String() != test.body) {
+			t.Errorf("%s from %s: %d %q", test.path, test.remote, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	// Скачивания сценария попадают в хронологию загрузки
+	if len(fetches) != 3 || fetches[1] != "192.168.1.10 boot.ipxe" {
+		t.Errorf("Unexpected fetches %v", fetches)
+	}
+}
@@ -0,0 +1,364 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// String возвращает название типа выделения
+func (t AllocationType) String() string {
+	switch t {
+	case StaticAllocation:
+		return "static"
+	case DynamicAllocation:
+		return "dynamic"
+	}
+	return "unknown"
+}
+
+// leaseReapInterval период удаления истекших аренд
+const leaseReapInterval = time.Minute
+
+// Состояния назначения
+const (
+	LeaseStateActive   = "active"   // Адрес используется клиентом
+	LeaseStateExpired  = "expired"  // Срок аренды истек, запись еще не удалена
+	LeaseStateReserved = "reserved" // Статический адрес, клиент еще не обращался
+)
+
+// Lease описывает назначение IP адреса для внешних потребителей
+// (управляющий сокет, API, экспорт)
+type Lease struct {
+	IP       string    `json:"ip"`
+	MAC      string    `json:"mac"`
+	ClientID string    `json:"client_id,omitempty"`
+	Hostname string    `json:"hostname,omitempty"`
+	Subnet   string    `json:"subnet,omitempty"`
+	SubnetID string    `json:"subnet_id,omitempty"`
+	Type     string    `json:"type"`
+	State    string    `json:"state"`
+	Active   bool      `json:"active"`
+	Expires  time.Time `json:"expires,omitempty"`
+	BOOTP    bool      `json:"bootp,omitempty"`
+
+	// Сведения из последнего запроса клиента: класс производителя (опция
+	// 60) и архитектуры (опция 93, например 7 - EFI x64)
+	VendorClass string   `json:"vendor_class,omitempty"`
+	Arch        []uint16 `json:"arch,omitempty"`
+
+	// Адрес вне диапазонов действующей конфигурации: аренда сохранена
+	// после перезагрузки до истечения срока
+	OutOfRange bool `json:"out_of_range,omitempty"`
+
+	// Случайный MAC адрес клиента (бит локального администрирования)
+	RandomizedMAC bool `json:"randomized_mac,omitempty"`
+}
+
+// newLease формирует описание назначения по внутренней записи на момент now
+func newLease(allocated *AllocatedIP, now time.Time) Lease {
+	lease := Lease{
+		IP:       intToIP(allocated.IP).String(),
+		MAC:      allocated.MAC,
+		ClientID: allocated.ClientID,
+		Hostname: allocated.Hostname,
+		Type:     allocated.Type.String(),
+		State:    allocated.state(now),
+		Active:   allocated.Active,
+		Expires:  allocated.Expires,
+		BOOTP:    allocated.BOOTP,
+
+		VendorClass: allocated.VendorClass,
+		Arch:        allocated.Arch,
+
+		OutOfRange: allocated.OutOfRange,
+
+		RandomizedMAC: isRandomizedMAC(allocated.MAC),
+	}
+	if lease.ClientID == "" {
+		lease.ClientID = allocated.RequestClientID
+	}
+	if allocated.Subnet != nil {
+		lease.Subnet = allocated.Subnet.Network.IP.String()
+		lease.SubnetID = subnetID(allocated.Subnet)
+	}
+	return lease
+}
+
+// subnetID возвращает идентификатор подсети в виде CIDR (192.168.1.0/24).
+// В отличие от адреса сети он различает подсети с разными масками.
+func subnetID(subnet *config.Subnet) string {
+	return subnet.Network.String()
+}
+
+// state возвращает состояние назначения на момент now
+func (a *AllocatedIP) state(now time.Time) string {
+	switch {
+	case a.Type == StaticAllocation && !a.Active:
+		return LeaseStateReserved
+	case a.Type == StaticAllocation && !a.Expires.IsZero() && !a.Expires.After(now):
+		return LeaseStateReserved
+	case a.Type == DynamicAllocation && !a.Expires.IsZero() && !a.Expires.After(now):
+		return LeaseStateExpired
+	case a.Type == DynamicAllocation && !a.Active:
+		return LeaseStateExpired
+	}
+	return LeaseStateActive
+}
+
+// Leases возвращает снимок таблицы назначений, упорядоченный по IP адресу
+func (s *BOOTPServer) Leases() []Lease {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ips := make([]uint32, 0, len(s.allocatedIP))
+	for ip := range s.allocatedIP {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i] < ips[j] })
+
+	now := s.clock.Now()
+	leases := make([]Lease, 0, len(ips))
+	for _, ip := range ips {
+		leases = append(leases, newLease(s.allocatedIP[ip], now))
+	}
+	return leases
+}
+
+// ExpireLeases удаляет динамические аренды, у которых истек срок аренды
+// и удержания, и снимает активность со статических назначений, которые
+// клиенты перестали продлевать. Для каждого такого назначения публикуется
+// событие expired. Возвращает число истекших назначений. Без вызова
+// ExpireLeases аренды истекают при обращении к адресу или клиенту.
+func (s *BOOTPServer) ExpireLeases() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expired := 0
+	for ip, allocated := range s.allocatedIP {
+		active := allocated.Active
+		if !s.isIPAllocated(ip) && active {
+			expired++
+		}
+	}
+	if expired > 0 {
+		s.checkPoolWatermarks()
+	}
+	return expired
+}
+
+// startLeaseReaper запускает периодическое удаление истекших аренд
+func (s *BOOTPServer) startLeaseReaper() {
+	s.reapStop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(leaseReapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if expired := s.ExpireLeases(); expired > 0 {
+					s.logger.Debugf("Expired %d leases", expired)
+				}
+			}
+		}
+	}(s.reapStop)
+}
+
+// Reservation описывает статическое резервирование из блока host
+type Reservation struct {
+	Name     string `json:"name"`
+	MAC      string `json:"mac,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	IP       string `json:"ip"`
+	Subnet   string `json:"subnet,omitempty"`
+	Managed  bool   `json:"managed,omitempty"` // Добавлено во время работы (см. AddReservation)
+}
+
+// Reservations возвращает статические резервирования текущей конфигурации
+func (s *BOOTPServer) Reservations() []Reservation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var reservations []Reservation
+	for _, subnet := range s.config.Subnets {
+		for _, host := range subnet.Hosts {
+			if host.FixedIP != "" && (host.Hardware != "" || host.ClientID != "") {
+				reservations = append(reservations, Reservation{
+					Name:     host.Name,
+					MAC:      normalizeMAC(host.Hardware),
+					ClientID: host.ClientID,
+					IP:       host.FixedIP,
+					Subnet:   subnet.Network.IP.String(),
+				})
+			}
+		}
+	}
+	for _, host := range s.config.Hosts {
+		if host.FixedIP != "" && (host.Hardware != "" || host.ClientID != "") {
+			reservations = append(reservations, Reservation{
+				Name:     host.Name,
+				MAC:      normalizeMAC(host.Hardware),
+				ClientID: host.ClientID,
+				IP:       host.FixedIP,
+				Managed:  s.isManagedReservation(host.Name),
+			})
+		}
+	}
+	return reservations
+}
+
+// SubnetUsage описывает заполненность пула подсети
+type SubnetUsage struct {
+	ID          string  `json:"id"` // Идентификатор подсети, см. Lease.SubnetID
+	Network     string  `json:"network"`
+	Netmask     string  `json:"netmask"`
+	Size        int     `json:"size"`        // Адресов в диапазоне range
+	Active      int     `json:"active"`      // Действующих динамических аренд
+	Expired     int     `json:"expired"`     // Истекших аренд, ожидающих удаления
+	Static      int     `json:"static"`      // Статических назначений в подсети
+	Abandoned   int     `json:"abandoned"`   // Адресов, исключенных после ICMP конфликта
+	Free        int     `json:"free"`        // Свободных адресов в диапазоне
+	Utilization float64 `json:"utilization"` // Доля занятых адресов диапазона, %
+	LowWater    bool    `json:"low_water"`   // Свободных адресов меньше pool-low-watermark
+
+	Ranges []AddressRange `json:"ranges,omitempty"` // Диапазоны range в порядке объявления
+}
+
+// AddressRange диапазон динамических адресов подсети
+type AddressRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SubnetUtilization возвращает заполненность пулов всех подсетей
+func (s *BOOTPServer) SubnetUtilization() []SubnetUsage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	usage := make([]SubnetUsage, 0, len(s.config.Subnets))
+	for i := range s.config.Subnets {
+		u := s.subnetUsage(i, now)
+		u.LowWater = s.poolLow[u.ID]
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// subnetUsage возвращает заполненность пула подсети с индексом index.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) subnetUsage(index int, now time.Time) SubnetUsage {
+	subnet := &s.config.Subnets[index]
+	u := SubnetUsage{
+		ID:      subnetID(subnet),
+		Network: subnet.Network.IP.String(),
+		Netmask: net.IP(subnet.Network.Mask).String(),
+	}
+
+	// Подсеть и диапазоны определяются по адресам, а не по указателю
+	// на подсеть в записи назначения
+	network, mask := ipToInt(subnet.Network.IP), ipToInt(net.IP(subnet.Network.Mask))
+	ranges, exclusions := s.runtime.Subnets[index].Ranges, s.runtime.Subnets[index].Exclusions
+	for _, addressRange := range ranges {
+		u.Ranges = append(u.Ranges, AddressRange{Start: addressRange.Start.String(), End: addressRange.End.String()})
+		u.Size += int(ipToInt(addressRange.End) - ipToInt(addressRange.Start) + 1)
+		// Исключенные адреса в размер пула не входят. Ни диапазоны, ни
+		// исключения между собой не пересекаются.
+		for _, exclusion := range exclusions {
+			start, end := ipToInt(exclusion.Start), ipToInt(exclusion.End)
+			if start < ipToInt(addressRange.Start) {
+				start = ipToInt(addressRange.Start)
+			}
+			if end > ipToInt(addressRange.End) {
+				end = ipToInt(addressRange.End)
+			}
+			if start <= end {
+				u.Size -= int(end - start + 1)
+			}
+		}
+	}
+	inRanges := func(ip uint32) bool {
+		if s.excluded(ip) {
+			return false
+		}
+		for _, addressRange := range ranges {
+			if ip >= ipToInt(addressRange.Start) && ip <= ipToInt(addressRange.End) {
+				return true
+			}
+		}
+		return false
+	}
+
+	used := 0
+	for ip, allocated := range s.allocatedIP {
+		inRange := inRanges(ip)
+		if allocated.Type == StaticAllocation {
+			if mask != 0 && ip&mask == network&mask {
+				u.Static++
+				if inRange {
+					used++
+				}
+			}
+			continue
+		}
+		if !inRange {
+			continue
+		}
+		switch allocated.state(now) {
+		case LeaseStateActive:
+			u.Active++
+			used++
+		case LeaseStateExpired:
+			u.Expired++
+		}
+	}
+
+	// Адреса, занятые посторонними узлами, не выдаются до истечения
+	// срока исключения
+	for ip := range s.conflicts {
+		if _, allocated := s.allocatedIP[ip]; !allocated && inRanges(ip) && s.conflicted(ip, now) {
+			u.Abandoned++
+			used++
+		}
+	}
+
+	u.Free = u.Size - used
+	if u.Size > 0 {
+		u.Utilization = float64(used) * 100 / float64(u.Size)
+	}
+	return u
+}
+
+// clientInfo сведения о клиенте из запроса (см. AllocatedIP)
+type clientInfo struct {
+	vendorClass string
+	arch        []uint16
+	clientID    string
+}
+
+// requestClientInfo читает опции 60, 93 и 61 запроса
+func requestClientInfo(options map[uint8][]byte) clientInfo {
+	info := clientInfo{
+		vendorClass: string(options[OptionVendorClass]),
+		clientID:    clientIDString(options[OptionClientIdentifier]),
+	}
+	data := options[OptionClientArch]
+	for i := 0; i+1 < len(data); i += 2 {
+		info.arch = append(info.arch, binary.BigEndian.Uint16(data[i:]))
+	}
+	return info
+}
+
+// apply записывает сведения в назначение. Вызывается с захваченным
+// мьютексом.
+func (c clientInfo) apply(allocated *AllocatedIP) {
+	allocated.VendorClass = c.vendorClass
+	allocated.Arch = c.arch
+	allocated.RequestClientID = c.clientID
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestLeaseState(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		allocated AllocatedIP
+		state     string
+	}{
+		{AllocatedIP{Type: StaticAllocation}, LeaseStateReserved},
+		{AllocatedIP{Type: StaticAllocation, Active: true}, LeaseStateActive},
+		{AllocatedIP{Type: DynamicAllocation, Active: true, Expires: now.Add(time.Hour)}, LeaseStateActive},
+		{AllocatedIP{Type: DynamicAllocation, Active: true, Expires: now.Add(-time.Minute)}, LeaseStateExpired},
+	}
+
+	for i, test := range tests {
+		if state := test.allocated.state(now); state != test.state {
+			t.Errorf("Case %d: expected %s, got %s", i, test.state, state)
+		}
+	}
+}
+
+func TestSubnetUtilization(t *testing.T) {
+	server := newAdminTestServer(t) // Диапазон 192.168.1.100-110, статический 192.168.1.10
+
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+	server.findClientConfig("aa:bb:cc:dd:ee:02")
+	server.findClientConfig("aa:bb:cc:dd:ee:03")
+
+	server.mutex.Lock()
+	server.allocatedMAC["aa:bb:cc:dd:ee:02"].Expires = time.Now().Add(-time.Minute)
+	server.mutex.Unlock()
+
+	usage := server.SubnetUtilization()
+	if len(usage) != 1 {
+		t.Fatalf("Expected 1 subnet, got %d", len(usage))
+	}
+	u := usage[0]
+	if u.Size != 11 || u.Active != 2 || u.Expired != 1 || u.Static != 1 || u.Free != 9 {
+		t.Errorf("Unexpected usage: %+v", u)
+	}
+	if u.Utilization < 18 || u.Utilization > 18.2 {
+		t.Errorf("Expected utilization ~18.2%%, got %.2f", u.Utilization)
+	}
+}
+
+func TestMultipleRanges(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges: []config.Range{
+					{Start: "192.168.1.100", End: "192.168.1.101"},
+					{Start: "192.168.1.200", End: "192.168.1.202"},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Второй диапазон используется, когда первый исчерпан
+	want := []string{"192.168.1.100", "192.168.1.101", "192.168.1.200"}
+	for i, ip := range want {
+		if got, _ := server.findClientConfig(fmt.Sprintf("aa:bb:cc:dd:ee:%02x", i+1)); got != ip {
+			t.Errorf("Client %d: expected %s, got %s", i+1, ip, got)
+		}
+	}
+
+	u := server.SubnetUtilization()[0]
+	if u.Size != 5 || u.Active != 3 || u.Free != 2 || len(u.Ranges) != 2 || u.Ranges[1] != (AddressRange{Start: "192.168.1.200", End: "192.168.1.202"}) {
+		t.Errorf("Unexpected usage: %+v", u)
+	}
+}
+
+func TestExcludedAddresses(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network:    config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:     []config.Range{{Start: "192.168.1.100", End: "192.168.1.104"}},
+				Exclusions: []config.Range{{Start: "192.168.1.101", End: "192.168.1.102"}, {Start: "192.168.1.104", End: "192.168.1.104"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	want := []string{"192.168.1.100", "192.168.1.103", ""}
+	for i, ip := range want {
+		if got, _ := server.findClientConfig(fmt.Sprintf("aa:bb:cc:dd:ee:%02x", i+1)); got != ip {
+			t.Errorf("Client %d: expected %q, got %q", i+1, ip, got)
+		}
+	}
+
+	u := server.SubnetUtilization()[0]
+	if u.Size != 2 || u.Active != 2 || u.Free != 0 {
+		t.Errorf("Unexpected usage: %+v", u)
+	}
+
+	offer := &leaseOffer{subnet: &server.config.Subnets[0]}
+	if err := server.reassignIP("aa:bb:cc:dd:ee:09", offer, "192.168.1.101"); err == nil {
+		t.Error("Expected excluded address to be refused")
+	}
+}
+
+func TestRecentRequests(t *testing.T) {
+	timeline := NewBootTimeline()
+	timeline.Record("aa:bb:cc:dd:ee:01", "", StageDiscover, "")
+	timeline.Record("aa:bb:cc:dd:ee:02", "", StageDiscover, "")
+	timeline.Record("aa:bb:cc:dd:ee:01", "192.168.1.100", StageOffer, "")
+
+	recent := timeline.Recent(2)
+	if len(recent) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(recent))
+	}
+	if recent[0].Stage != StageOffer || recent[0].MAC != "aa:bb:cc:dd:ee:01" {
+		t.Errorf("Expected newest event first, got %+v", recent[0])
+	}
+}
+
+func TestLeaseSubnetID(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Hosts:   []config.Host{{Name: "first", Hardware: "00:11:22:33:44:01", FixedIP: "192.168.1.10"}},
+			},
+			{
+				Network: config.MustParseNetwork("10.0.0.0/16"),
+				Ranges:  []config.Range{{Start: "10.0.1.1", End: "10.0.1.10"}},
+				Hosts:   []config.Host{{Name: "second", Hardware: "00:11:22:33:44:02", FixedIP: "10.0.0.10"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+
+	// Каждое назначение ссылается на свою подсеть, а не на последнюю
+	// подсеть цикла
+	expected := map[string]string{
+		"192.168.1.10": "192.168.1.0/24",
+		"10.0.0.10":    "10.0.0.0/16",
+		"10.0.1.1":     "10.0.0.0/16",
+	}
+	leases := server.Leases()
+	if len(leases) != len(expected) {
+		t.Fatalf("Expected %d leases, got %d", len(expected), len(leases))
+	}
+	for _, lease := range leases {
+		if lease.SubnetID != expected[lease.IP] {
+			t.Errorf("Lease %s: expected subnet %s, got %s", lease.IP, expected[lease.IP], lease.SubnetID)
+		}
+	}
+	if id := server.SubnetUtilization()[1].ID; id != "10.0.0.0/16" {
+		t.Errorf("Expected subnet usage id 10.0.0.0/16, got %s", id)
+	}
+}
+
+func TestLeaseClientInfo(t *testing.T) {
+	server := newAdminTestServer(t) // Диапазон 192.168.1.100-110, статический 192.168.1.10
+
+	request := func(chaddr [16]byte, options map[uint8][]byte) {
+		options[OptionMessageType] = []byte{DHCPDiscover}
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: chaddr},
+			Options: options,
+		}
+		if server.processPacket(packet) == nil {
+			t.Fatalf("Expected reply for client %x", chaddr[:6])
+		}
+	}
+
+	request([16]byte{0x02, 0, 0, 0, 0, 1}, map[uint8][]byte{
+		OptionVendorClass:      []byte("PXEClient:Arch:00007:UNDI:003016"),
+		OptionClientArch:       {0x00, 0x07},
+		OptionClientIdentifier: {0x01, 0x02, 0, 0, 0, 0, 0x01},
+	})
+	request([16]byte{0x02, 0, 0, 0, 0, 2}, map[uint8][]byte{OptionClientArch: {0x00, 0x00, 0x00, 0x09}})
+	// Резервирование по MAC адресу: идентификатор клиента тоже виден
+	request([16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, map[uint8][]byte{OptionClientIdentifier: {0x01, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55}})
+
+	leases := make(map[string]Lease)
+	for _, lease := range server.Leases() {
+		leases[lease.MAC] = lease
+	}
+	first := leases["02:00:00:00:00:01"]
+	if first.VendorClass != "PXEClient:Arch:00007:UNDI:003016" || fmt.Sprint(first.Arch) != "[7]" || first.ClientID != "01:02:00:00:00:00:01" {
+		t.Errorf("Unexpected client info: %+v", first)
+	}
+	if second := leases["02:00:00:00:00:02"]; second.VendorClass != "" || fmt.Sprint(second.Arch) != "[0 9]" || second.ClientID != "" {
+		t.Errorf("Unexpected client info: %+v", second)
+	}
+	if static := leases["00:11:22:33:44:55"]; static.Type != "static" || static.ClientID != "01:00:11:22:33:44:55" {
+		t.Errorf("Unexpected static lease: %+v", static)
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// LeaseStore постоянное хранилище аренд. Восстанавливаются только
+// действующие динамические аренды: статические назначения задает
+// конфигурация.
+type LeaseStore interface {
+	// Load возвращает аренды, сохраненные при предыдущей работе сервера
+	Load() ([]Lease, error)
+
+	// Save сохраняет изменение аренды. Вызывается при каждом событии
+	// аренды под блокировкой таблицы назначений, поэтому не должен
+	// выполнять долгих операций.
+	Save(event LeaseEvent) error
+}
+
+// LeaseHistory хранилище аренд, которое ведет историю назначений адресов
+type LeaseHistory interface {
+	// History возвращает назначения, подходящие под запрос, начиная с
+	// последних
+	History(query HistoryQuery) ([]HistoryRecord, error)
+}
+
+// HistoryQuery запрос к истории назначений. Пустые поля не ограничивают
+// выборку.
+type HistoryQuery struct {
+	IP    string    `json:"ip,omitempty"`
+	MAC   string    `json:"mac,omitempty"`
+	At    time.Time `json:"at,omitempty"`    // Назначения, действовавшие в этот момент
+	Limit int       `json:"limit,omitempty"` // Не больше стольких записей (0 - все)
+}
+
+// HistoryRecord назначение адреса клиенту от выдачи до освобождения или
+// истечения
+type HistoryRecord struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Type      string    `json:"type"`
+	Starts    time.Time `json:"starts"`
+	Expires   time.Time `json:"expires,omitempty"` // Срок аренды на момент последнего продления
+	Ends      time.Time `json:"ends,omitempty"`    // Пусто, пока назначение действует
+	EndReason string    `json:"end_reason,omitempty"`
+}
+
+// loadLeases восстанавливает аренды из хранилища. Истекшие аренды
+// пропускаются, остальные сверяются с конфигурацией так же, как при
+// перезагрузке (см. admitLease).
+func (s *BOOTPServer) loadLeases() error {
+	leases, err := s.store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load leases: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	restored := 0
+	for _, lease := range leases {
+		if lease.Type != DynamicAllocation.String() {
+			continue
+		}
+		if !lease.Expires.IsZero() && !lease.Expires.After(now) {
+			continue
+		}
+
+		ip := net.ParseIP(lease.IP).To4()
+		if ip == nil {
+			s.logger.Warnf("Skipping stored lease with invalid address %q", lease.IP)
+			continue
+		}
+		allocated := &AllocatedIP{
+			IP:       ipToInt(ip),
+			MAC:      normalizeMAC(lease.MAC),
+			ClientID: lease.ClientID,
+			Hostname: lease.Hostname,
+			Type:     DynamicAllocation,
+			Active:   true,
+			Expires:  lease.Expires,
+			BOOTP:    lease.BOOTP,
+
+			VendorClass: lease.VendorClass,
+			Arch:        lease.Arch,
+		}
+		if s.admitLease(allocated) {
+			restored++
+		}
+	}
+
+	s.logger.Infof("Restored %d of %d stored leases", restored, len(leases))
+	return nil
+}
+
+// saveLease передает событие аренды в хранилище, если оно задано.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) saveLease(event LeaseEvent) {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.Save(event); err != nil {
+		s.logger.Errorf("Failed to save %s lease %s for %s: %v", event.Type, event.Lease.IP, event.Lease.MAC, err)
+	}
+}
+
+// LeaseHistory возвращает назначения адресов из истории хранилища аренд
+// (см. LeaseHistory)
+func (s *BOOTPServer) LeaseHistory(query HistoryQuery) ([]HistoryRecord, error) {
+	history, ok := s.store.(LeaseHistory)
+	if !ok {
+		return nil, fmt.Errorf("lease store does not keep history")
+	}
+	return history.History(query)
+}
+
+// MigrationResult итог переноса аренд между хранилищами
+type MigrationResult struct {
+	Loaded     int // Аренд в исходном хранилище
+	Migrated   int // Сохранено в новом хранилище
+	Skipped    int // Статические, истекшие и неверные записи
+	Duplicates int // Более старые записи того же адреса или клиента
+}
+
+// MigrateLeases переносит действующие динамические аренды из хранилища
+// from в хранилище to, например при смене способа хранения. Записи с
+// неверным адресом или MAC пропускаются. Если у адреса или клиента
+// несколько записей, сохраняется аренда с самым поздним сроком
+// окончания. Каждая аренда сохраняется событием LeaseAllocated.
+func MigrateLeases(from, to LeaseStore, now time.Time) (MigrationResult, error) {
+	var result MigrationResult
+	leases, err := from.Load()
+	if err != nil {
+		return result, fmt.Errorf("failed to load leases: %v", err)
+	}
+	result.Loaded = len(leases)
+
+	valid := make([]Lease, 0, len(leases))
+	for _, lease := range leases {
+		if lease.Type != DynamicAllocation.String() {
+			result.Skipped++
+			continue
+		}
+		if !lease.Expires.IsZero() && !lease.Expires.After(now) {
+			result.Skipped++
+			continue
+		}
+		ip := net.ParseIP(lease.IP).To4()
+		mac, err := config.NormalizeMAC(lease.MAC)
+		if ip == nil || (err != nil && lease.ClientID == "") {
+			result.Skipped++
+			continue
+		}
+		lease.IP = ip.String()
+		if err == nil {
+			lease.MAC = mac
+		}
+		valid = append(valid, lease)
+	}
+
+	// Бессрочные аренды считаются самыми поздними
+	sort.SliceStable(valid, func(i, j int) bool {
+		a, b := valid[i].Expires, valid[j].Expires
+		if a.IsZero() || b.IsZero() {
+			return a.IsZero() && !b.IsZero()
+		}
+		return a.After(b)
+	})
+	addresses := make(map[string]bool)
+	clients := make(map[string]bool)
+	for _, lease := range valid {
+		key := clientKey(lease.MAC, lease.ClientID)
+		if addresses[lease.IP] || clients[key] {
+			result.Duplicates++
+			continue
+		}
+		addresses[lease.IP], clients[key] = true, true
+
+		if err := to.Save(LeaseEvent{Type: LeaseAllocated, Time: now, Lease: lease}); err != nil {
+			return result, fmt.Errorf("failed to save lease %s: %v", lease.IP, err)
+		}
+		result.Migrated++
+	}
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// memoryLeaseStore хранилище аренд в памяти
+type memoryLeaseStore struct {
+	leases []Lease
+	events []LeaseEvent
+}
+
+func (m *memoryLeaseStore) Load() ([]Lease, error) {
+	return m.leases, nil
+}
+
+func (m *memoryLeaseStore) Save(event LeaseEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestMigrateLeases(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	from := &memoryLeaseStore{leases: []Lease{
+		{IP: "192.168.1.100", MAC: "02-00-00-00-00-01", Type: "dynamic", Expires: now.Add(time.Minute)},
+		{IP: "192.168.1.101", MAC: "02:00:00:00:00:01", Type: "dynamic", Expires: now.Add(time.Hour)},
+		{IP: "192.168.1.101", MAC: "02:00:00:00:00:02", Type: "dynamic", Expires: now.Add(2 * time.Minute)},
+		{IP: "192.168.1.102", ClientID: "01:02", Type: "dynamic"},
+		{IP: "192.168.1.103", MAC: "02:00:00:00:00:03", Type: "dynamic", Expires: now.Add(-time.Minute)},
+		{IP: "192.168.1.104", MAC: "02:00:00:00:00:04", Type: "static"},
+		{IP: "192.168.1.300", MAC: "02:00:00:00:00:05", Type: "dynamic"},
+		{IP: "192.168.1.106", MAC: "invalid", Type: "dynamic"},
+	}}
+	to := &memoryLeaseStore{}
+
+	result, err := MigrateLeases(from, to, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (MigrationResult{Loaded: 8, Migrated: 2, Skipped: 4, Duplicates: 2}) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(to.events) != 2 || to.events[0].Lease.IP != "192.168.1.102" || to.events[1].Lease.IP != "192.168.1.101" || to.events[1].Lease.MAC != "02:00:00:00:00:01" {
+		t.Fatalf("Unexpected migrated leases %+v", to.events)
+	}
+	if to.events[1].Type != LeaseAllocated || !to.events[1].Time.Equal(now) {
+		t.Errorf("Unexpected event %+v", to.events[1])
+	}
+}
+
+func TestLeaseHistoryWithoutStore(t *testing.T) {
+	for _, store := range []LeaseStore{nil, &memoryLeaseStore{}} {
+		server, err := NewBOOTPServer(&config.DHCPConfig{}, WithLeaseStore(store))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.LeaseHistory(HistoryQuery{}); err == nil {
+			t.Errorf("Expected history query to fail for store %T", store)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+//go:build freebsd || openbsd
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+)
+
+// listenUDPInterface открывает UDP сокет для интерфейса. В BSD нет
+// SO_BINDTODEVICE, поэтому сокеты всех интерфейсов слушают 0.0.0.0 с
+// SO_REUSEPORT (широковещательные пакеты получает каждый из них), а
+// интерфейс, на который пришел пакет, сообщает IP_RECVIF (см. newReceiver).
+func listenUDPInterface(iface string, port int) (*net.UDPConn, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+				if sockErr != nil {
+					return
+				}
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1)
+				if sockErr != nil {
+					return
+				}
+				sockErr = setOnesBroadcast(int(fd))
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	conn, err := config.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("listen on interface %s: %v", iface, err)
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// newReceiver читает запросы с IP_RECVIF и IP_RECVDSTADDR: по ним
+// определяется адрес интерфейса, на который пришел запрос, а сокет
+// интерфейса iface пропускает пакеты, пришедшие на другие интерфейсы
+func newReceiver(conn *net.UDPConn, iface string, batch int, dropped func(n uint64)) receiver {
+	for _, option := range []int{syscall.IP_RECVIF, syscall.IP_RECVDSTADDR} {
+		if err := setSocketOption(conn, syscall.IPPROTO_IP, option); err != nil {
+			if iface != "" {
+				logrus.Errorf("Failed to enable IP_RECVIF on %s socket, requests from other interfaces will be answered: %v", iface, err)
+			}
+			return plainReceiver(conn)
+		}
+	}
+
+	index := 0
+	if iface != "" {
+		if ifi, err := net.InterfaceByName(iface); err == nil {
+			index = ifi.Index
+		}
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(syscall.SizeofSockaddrDatalink)+syscall.CmsgSpace(net.IPv4len))
+	return func(buffer []byte) (int, *net.UDPAddr, net.IP, error) {
+		for {
+			n, oobn, _, addr, err := conn.ReadMsgUDP(buffer, oob)
+			if err != nil {
+				return n, addr, nil, err
+			}
+			received, dst := controlInfo(oob[:oobn])
+			if index != 0 && received != 0 && received != index {
+				continue
+			}
+			var local net.IP
+			if received != 0 {
+				local = arrivalAddress(received, dst)
+			}
+			return n, addr, local, nil
+		}
+	}
+}
+
+// controlInfo возвращает индекс интерфейса (IP_RECVIF) и адрес назначения
+// (IP_RECVDSTADDR) из управляющих сообщений
+func controlInfo(oob []byte) (int, net.IP) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, nil
+	}
+	index := 0
+	var dst net.IP
+	for _, m := range messages {
+		if m.Header.Level != syscall.IPPROTO_IP {
+			continue
+		}
+		switch {
+		case m.Header.Type == syscall.IP_RECVIF && len(m.Data) >= syscall.SizeofSockaddrDatalink:
+			index = int((*syscall.RawSockaddrDatalink)(unsafe.Pointer(&m.Data[0])).Index)
+		case m.Header.Type == syscall.IP_RECVDSTADDR && len(m.Data) >= net.IPv4len:
+			dst = net.IPv4(m.Data[0], m.Data[1], m.Data[2], m.Data[3]).To4()
+		}
+	}
+	return index, dst
+}
+
+// broadcastAddress для сокета интерфейса возвращает широковещательный адрес
+// его сети: маршрутизация по нему выбирает нужный интерфейс, в отличие от
+// 255.255.255.255. Для сокета на всех интерфейсах - 255.255.255.255:68.
+func broadcastAddress(iface string, yiaddr net.IP) *net.UDPAddr {
+	if iface == "" {
+		return limitedBroadcast()
+	}
+	if ipNet := interfaceNetwork(iface, yiaddr); ipNet != nil {
+		return directedBroadcast(ipNet)
+	}
+	return limitedBroadcast()
+}
@@ -0,0 +1,12 @@
+//go:build freebsd
+
+package server
+
+import "syscall"
+
+// setOnesBroadcast включает IP_ONESBCAST: пакет на широковещательный адрес
+// сети уходит через ее интерфейс с адресом назначения 255.255.255.255,
+// который принимают клиенты без адреса
+func setOnesBroadcast(fd int) error {
+	return syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_ONESBCAST, 1)
+}
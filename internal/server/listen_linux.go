@@ -0,0 +1,124 @@
+//go:build linux
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+)
+
+// listenUDPInterface открывает UDP сокет, привязанный к сетевому интерфейсу
+// через SO_BINDTODEVICE. SO_REUSEADDR позволяет открыть по сокету на каждый
+// интерфейс на одном и том же порту.
+func listenUDPInterface(iface string, port int) (*net.UDPConn, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+				if sockErr != nil {
+					return
+				}
+				sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+				sockErr = permissionError(sockErr, capNetRaw, "binding to an interface")
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	conn, err := config.ListenPacket(context.Background(), "udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		err = permissionError(err, capNetBindService, fmt.Sprintf("listening on port %d", port))
+		return nil, fmt.Errorf("listen on interface %s: %v", iface, err)
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// newReceiver читает запросы с IP_PKTINFO, чтобы узнать локальный адрес,
+// на который пришел запрос, и с SO_RXQ_OVFL, чтобы узнать, сколько
+// запросов ядро отбросило из-за переполнения буфера приема. Сокет,
+// привязанный через SO_BINDTODEVICE, получает только пакеты своего
+// интерфейса, поэтому iface не нужен. При batch > 1 запросы читаются
+// пачками через recvmmsg.
+func newReceiver(conn *net.UDPConn, iface string, batch int, dropped func(n uint64)) receiver {
+	if err := setSocketOption(conn, syscall.IPPROTO_IP, syscall.IP_PKTINFO); err != nil {
+		logrus.Warnf("Failed to enable IP_PKTINFO, server address will not follow the receiving interface: %v", err)
+	}
+	if err := setSocketOption(conn, syscall.SOL_SOCKET, syscall.SO_RXQ_OVFL); err != nil {
+		logrus.Debugf("Failed to enable SO_RXQ_OVFL, dropped requests will not be counted: %v", err)
+	}
+
+	// Счетчик SO_RXQ_OVFL накапливается с открытия сокета
+	var drops uint32
+	control := func(oob []byte) net.IP {
+		total, ok := overflowCount(oob)
+		if ok && total != drops {
+			dropped(uint64(total - drops))
+			drops = total
+		}
+		return pktinfoAddress(oob)
+	}
+
+	oobSize := syscall.CmsgSpace(syscall.SizeofInet4Pktinfo) + syscall.CmsgSpace(4)
+	if batch > 1 {
+		if raw, err := conn.SyscallConn(); err == nil {
+			return newBatchReceiver(raw, batch, oobSize, control)
+		}
+	}
+
+	oob := make([]byte, oobSize)
+	return func(buffer []byte) (int, *net.UDPAddr, net.IP, error) {
+		n, oobn, _, addr, err := conn.ReadMsgUDP(buffer, oob)
+		if err != nil {
+			return n, addr, nil, err
+		}
+		return n, addr, control(oob[:oobn]), nil
+	}
+}
+
+// overflowCount возвращает счетчик отброшенных ядром пакетов из сообщения
+// SO_RXQ_OVFL. Ядро добавляет сообщение, только если счетчик не нулевой.
+func overflowCount(oob []byte) (uint32, bool) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range messages {
+		if m.Header.Level == syscall.SOL_SOCKET && m.Header.Type == syscall.SO_RXQ_OVFL && len(m.Data) >= 4 {
+			return *(*uint32)(unsafe.Pointer(&m.Data[0])), true
+		}
+	}
+	return 0, false
+}
+
+// pktinfoAddress возвращает локальный адрес (ipi_spec_dst) из сообщения
+// IP_PKTINFO: адрес назначения unicast запроса или основной адрес
+// интерфейса для широковещательного
+func pktinfoAddress(oob []byte) net.IP {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+	for _, m := range messages {
+		if m.Header.Level == syscall.IPPROTO_IP && m.Header.Type == syscall.IP_PKTINFO &&
+			len(m.Data) >= syscall.SizeofInet4Pktinfo {
+			// struct in_pktinfo: ipi_ifindex (4 байта), ipi_spec_dst, ipi_addr
+			return net.IPv4(m.Data[4], m.Data[5], m.Data[6], m.Data[7]).To4()
+		}
+	}
+	return nil
+}
+
+// broadcastAddress возвращает 255.255.255.255:68. Сокет, привязанный
+// к интерфейсу, отправляет его через этот интерфейс.
+func broadcastAddress(iface string, yiaddr net.IP) *net.UDPAddr {
+	return limitedBroadcast()
+}
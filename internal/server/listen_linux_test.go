@@ -0,0 +1,93 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReceiverLocalAddress(t *testing.T) {
+	for _, batch := range []int{1, 4} {
+		t.Run(fmt.Sprintf("batch %d", batch), func(t *testing.T) {
+			conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			// IP_PKTINFO включается при создании приемника, до прихода пакета
+			receive := newReceiver(conn, "", batch, func(uint64) {})
+
+			sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sender.Close()
+			for i := 0; i < 3; i++ {
+				if _, err := fmt.Fprintf(sender, "request %d", i); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			buffer := make([]byte, 64)
+			for i := 0; i < 3; i++ {
+				n, addr, local, err := receive(buffer)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(buffer[:n]) != fmt.Sprintf("request %d", i) || addr.String() != sender.LocalAddr().String() {
+					t.Errorf("Unexpected packet %q from %v", buffer[:n], addr)
+				}
+				if !local.Equal(net.IPv4(127, 0, 0, 1)) {
+					t.Errorf("Expected local address 127.0.0.1, got %v", local)
+				}
+			}
+		})
+	}
+}
+
+func TestReceiverDropped(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := (socketTuning{receiveBuffer: 4096}).apply(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	var dropped uint64
+	receive := newReceiver(conn, "", 8, func(n uint64) { dropped += n })
+
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	// Буфер приема вмещает лишь несколько запросов, остальные ядро
+	// отбросит. Счетчик приходит с первым запросом, принятым после
+	// переполнения, поэтому дальше отправляем и читаем по одному.
+	request := make([]byte, 300)
+	for i := 0; i < 100; i++ {
+		if _, err := sender.Write(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, maxPacketSize)
+	for i := 0; i < 100 && dropped == 0; i++ {
+		if _, err := sender.Write(request); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := receive(buffer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dropped == 0 || dropped >= 100 {
+		t.Errorf("Unexpected dropped count %d", dropped)
+	}
+}
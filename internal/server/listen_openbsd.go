@@ -0,0 +1,9 @@
+//go:build openbsd
+
+package server
+
+// setOnesBroadcast ничего не делает: в OpenBSD нет IP_ONESBCAST, ответ
+// отправляется на широковещательный адрес сети
+func setOnesBroadcast(fd int) error {
+	return nil
+}
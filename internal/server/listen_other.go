@@ -0,0 +1,23 @@
+//go:build !linux && !freebsd && !openbsd && !windows
+
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// listenUDPInterface не поддерживается на этой платформе
+func listenUDPInterface(iface string, port int) (*net.UDPConn, error) {
+	return nil, fmt.Errorf("binding to interface %s is not supported on this platform", iface)
+}
+
+// newReceiver читает запросы напрямую
+func newReceiver(conn *net.UDPConn, iface string, batch int, dropped func(n uint64)) receiver {
+	return plainReceiver(conn)
+}
+
+// broadcastAddress возвращает 255.255.255.255:68
+func broadcastAddress(iface string, yiaddr net.IP) *net.UDPAddr {
+	return limitedBroadcast()
+}
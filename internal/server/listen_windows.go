@@ -0,0 +1,49 @@
+//go:build windows
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// listenUDPInterface открывает UDP сокет на IPv4 адресе интерфейса. Windows
+// доставляет такому сокету широковещательные пакеты, пришедшие на этот
+// интерфейс, и отправляет через него ответы на 255.255.255.255.
+func listenUDPInterface(iface string, port int) (*net.UDPConn, error) {
+	ip := interfaceAddress(iface, nil)
+	if ip == nil {
+		return nil, fmt.Errorf("listen on interface %s: no IPv4 address", iface)
+	}
+
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+
+	conn, err := config.ListenPacket(context.Background(), "udp4", fmt.Sprintf("%s:%d", ip, port))
+	if err != nil {
+		return nil, fmt.Errorf("listen on interface %s: %v", iface, err)
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// newReceiver читает запросы напрямую: сокет привязан к адресу интерфейса
+func newReceiver(conn *net.UDPConn, iface string, batch int, dropped func(n uint64)) receiver {
+	return plainReceiver(conn)
+}
+
+// broadcastAddress возвращает 255.255.255.255:68
+func broadcastAddress(iface string, yiaddr net.IP) *net.UDPAddr {
+	return limitedBroadcast()
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Request запрос клиента, передаваемый по цепочке обработчиков
+type Request struct {
+	Packet      *Packet      // Разобранный запрос, обработчики могут его изменять (например, giaddr)
+	MessageType uint8        // Тип DHCP сообщения (0 - BOOTP)
+	ClientAddr  *net.UDPAddr // Адрес отправителя (nil, если неизвестен)
+	Local       net.IP       // Адрес интерфейса, на который пришел запрос (nil, если неизвестен)
+	VLAN        uint16       // VLAN запроса с транкового интерфейса (0 - запрос принят сокетом интерфейса)
+
+	Interface string // Интерфейс сокета, принявшего запрос (пусто - сокет на всех интерфейсах или транк)
+
+	delay time.Duration // Задержка ответа (delay), определенная при выделении адреса
+}
+
+// Handler обрабатывает запрос и возвращает ответ. Ответ nil без ошибки
+// означает, что запрос остается без ответа; ошибка записывается в журнал,
+// и запрос также остается без ответа. ctx отменяется по истечении
+// request-timeout (см. serveRequest).
+type Handler func(ctx context.Context, req *Request) (*Packet, error)
+
+// Middleware оборачивает следующий обработчик цепочки. Обработчик может
+// ответить сам, отказать, изменить запрос перед передачей дальше или
+// изменить полученный ответ.
+type Middleware func(next Handler) Handler
+
+// Use добавляет обработчики запросов. Они вызываются в порядке добавления
+// после режима обучения и перед остальными встроенными (allowed-relays,
+// min-secs, DHCPINFORM, DHCPRELEASE, проверка запрошенного адреса,
+// выделение адреса). Должен вызываться до Start.
+func (s *BOOTPServer) Use(middleware ...Middleware) {
+	s.middleware = append(s.middleware, middleware...)
+	s.handler = s.chain()
+}
+
+// serve обрабатывает запрос цепочкой из добавленных и встроенных
+// обработчиков
+func (s *BOOTPServer) serve(ctx context.Context, req *Request) (*Packet, error) {
+	return s.handler(ctx, req)
+}
+
+// chain собирает цепочку обработчиков. Цепочка собирается один раз при
+// создании сервера и при добавлении обработчиков, а не для каждого запроса.
+func (s *BOOTPServer) chain() Handler {
+	chain := make([]Middleware, 0, len(s.middleware)+7)
+	chain = append(chain, s.learningMiddleware)
+	chain = append(chain, s.middleware...)
+	chain = append(chain, s.relayMiddleware, s.minSecsMiddleware, s.informMiddleware, s.releaseMiddleware, s.randomizedMACMiddleware, s.verifyRequestMiddleware)
+
+	handler := Handler(s.allocate)
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return handler
+}
+
+// informMiddleware отвечает на DHCPINFORM опциями без выделения адреса
+func (s *BOOTPServer) informMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		if req.MessageType != DHCPInform {
+			return next(ctx, req)
+		}
+		return s.processInform(req.Packet, newResponse(&req.Packet.Header)), nil
+	}
+}
+
+// releaseMiddleware освобождает аренду по DHCPRELEASE. На DHCPRELEASE
+// сервер не отвечает (RFC 2131, 4.3.4).
+func (s *BOOTPServer) releaseMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		if req.MessageType != DHCPRelease {
+			return next(ctx, req)
+		}
+		s.processRelease(req.Packet)
+		return nil, nil
+	}
+}
+
+// verifyRequestMiddleware сверяет адрес, запрошенный в DHCPREQUEST, с
+// назначением клиента. Если он не совпадает, авторитетный сервер отвечает
+// отказом, а неавторитетный не отвечает.
+func (s *BOOTPServer) verifyRequestMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		packet := req.Packet
+		requested := requestedAddress(packet)
+		if req.MessageType != DHCPRequest || requested == nil {
+			return next(ctx, req)
+		}
+
+		macAddr := chaddrToMAC(packet.Header.Chaddr, packet.Header.Hlen)
+		clientID := clientIDString(packet.Options[OptionClientIdentifier])
+		_, selecting := packet.Options[OptionServerIdentifier]
+		switch s.verifyRequestedAddress(macAddr, clientID, requested, selecting) {
+		case requestNak:
+			s.logger.Infof("Rejecting request for %s by %s", requested, macAddr)
+			s.recordAudit(AuditNak, macAddr, requested.String())
+			response := newResponse(&packet.Header)
+			response.Options[OptionMessageType] = []byte{DHCPNak}
+			return response, nil
+		case requestIgnore:
+			s.logger.Debugf("Ignoring request for %s by %s", requested, macAddr)
+			return nil, nil
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func discoverPacket(mac byte) *Packet {
+	return &Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, mac}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	server := newInformTestServer(t)
+
+	var order []string
+	server.Use(
+		func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Packet, error) {
+				order = append(order, "auth")
+				if req.Packet.Header.Chaddr[5] == 2 {
+					return nil, errors.New("client is not authorized")
+				}
+				return next(ctx, req)
+			}
+		},
+		func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Packet, error) {
+				order = append(order, "rewrite")
+				copy(req.Packet.Header.Giaddr[:], net.IPv4(192, 168, 1, 1).To4())
+				reply, err := next(ctx, req)
+				if reply != nil {
+					reply.Options[OptionHostName] = []byte("from-middleware")
+				}
+				return reply, err
+			}
+		},
+	)
+
+	packet := discoverPacket(1)
+	reply := server.processPacket(packet)
+	if reply == nil || net.IP(reply.Header.Yiaddr[:]).IsUnspecified() {
+		t.Fatalf("Expected address to be allocated after middlewares, got %+v", reply)
+	}
+	if len(order) != 2 || order[0] != "auth" || order[1] != "rewrite" {
+		t.Errorf("Expected middlewares in the order of Use, got %v", order)
+	}
+	if giaddr := net.IP(packet.Header.Giaddr[:]); !giaddr.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Expected request to be rewritten, got giaddr %v", giaddr)
+	}
+	if hostname := string(reply.Options[OptionHostName]); hostname != "from-middleware" {
+		t.Errorf("Expected reply to be changed by middleware, got %q", hostname)
+	}
+
+	// Отказ обработчика: ответа нет, адрес не выделяется
+	if reply := server.processPacket(discoverPacket(2)); reply != nil {
+		t.Errorf("Expected unauthorized client to be ignored, got %+v", reply)
+	}
+	if leases := server.Leases(); len(leases) != 1 {
+		t.Errorf("Expected one lease, got %+v", leases)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	server := newInformTestServer(t)
+
+	// Обработчик отвечает сам, встроенное выделение адреса не вызывается
+	server.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Packet, error) {
+			response := newResponse(&req.Packet.Header)
+			copy(response.Header.Yiaddr[:], net.IPv4(192, 168, 1, 200).To4())
+			response.Header.Magic = magicCookie
+			return response, nil
+		}
+	})
+
+	reply := server.processPacket(discoverPacket(1))
+	if reply == nil || !net.IP(reply.Header.Yiaddr[:]).Equal(net.IPv4(192, 168, 1, 200)) {
+		t.Fatalf("Expected reply from middleware, got %+v", reply)
+	}
+	if leases := server.Leases(); len(leases) != 0 {
+		t.Errorf("Expected no lease from built-in allocation, got %+v", leases)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// parseMinSecs читает опцию min-secs: сервер не отвечает на DHCPDISCOVER
+// и запросы BOOTP, в которых клиент ждет (поле secs) меньше заданного
+// числа секунд. Резервный сервер с min-secs отвечает, только если
+// основной не ответил, пока клиент повторял запрос. Как в ISC DHCP,
+// значение не больше 255.
+func parseMinSecs(options map[string]string) (uint16, error) {
+	value, ok := options["min-secs"]
+	if !ok {
+		return 0, nil
+	}
+	secs, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid min-secs: %s (must be 0-255)", value)
+	}
+	return uint16(secs), nil
+}
+
+// minSecsMiddleware оставляет без ответа новых клиентов, ждущих меньше
+// min-secs (см. parseMinSecs). Продления и остальные сообщения
+// обрабатываются сразу: клиент уже выбрал сервер.
+func (s *BOOTPServer) minSecsMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		if req.MessageType != DHCPDiscover && req.MessageType != 0 {
+			return next(ctx, req)
+		}
+		s.mutex.Lock()
+		minSecs := s.minSecs
+		s.mutex.Unlock()
+
+		if secs := req.Packet.Header.Secs; secs < minSecs {
+			s.counters.deferred.Add(1)
+			s.logger.Debugf("Deferring request from %s: secs %d is below min-secs %d",
+				chaddrToMAC(req.Packet.Header.Chaddr, req.Packet.Header.Hlen), secs, minSecs)
+			return nil, nil
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseMinSecs(t *testing.T) {
+	if secs, err := parseMinSecs(map[string]string{}); err != nil || secs != 0 {
+		t.Errorf("Expected no threshold by default, got %d (%v)", secs, err)
+	}
+	if secs, err := parseMinSecs(map[string]string{"min-secs": "5"}); err != nil || secs != 5 {
+		t.Errorf("Expected threshold 5, got %d (%v)", secs, err)
+	}
+	for _, value := range []string{"256", "-1", "5s"} {
+		if _, err := parseMinSecs(map[string]string{"min-secs": value}); err == nil {
+			t.Errorf("Expected error for min-secs %s", value)
+		}
+	}
+}
+
+func TestMinSecs(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"min-secs": "4"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Новый клиент ждет меньше min-secs: отвечает основной сервер
+	packet := discoverPacket(1)
+	packet.Header.Secs = 3
+	if reply := server.processPacket(packet); reply != nil {
+		t.Error("Expected discover below min-secs to be ignored")
+	}
+
+	// Повтор с большим временем ожидания получает ответ
+	packet.Header.Secs = 4
+	if reply := server.processPacket(packet); reply == nil {
+		t.Fatal("Expected reply once secs reaches min-secs")
+	}
+
+	// DHCPREQUEST обрабатывается сразу
+	request := discoverPacket(1)
+	request.Options[OptionMessageType] = []byte{DHCPRequest}
+	if reply := server.processPacket(request); reply == nil {
+		t.Error("Expected request to be answered regardless of secs")
+	}
+
+	// BOOTP запросы также откладываются
+	bootp := discoverPacket(2)
+	bootp.Options = map[uint8][]byte{}
+	if reply := server.processPacket(bootp); reply != nil {
+		t.Error("Expected BOOTP request below min-secs to be ignored")
+	}
+
+	if deferred := server.Stats().Counters.Deferred; deferred != 2 {
+		t.Errorf("Expected 2 deferred requests, got %d", deferred)
+	}
+}
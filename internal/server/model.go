@@ -0,0 +1,186 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// RuntimeModel действующая модель сервера после загрузки конфигурации:
+// пулы подсетей, резервирования и опции, которые получат их клиенты.
+// Опции классов зависят от запроса и в модель не входят.
+type RuntimeModel struct {
+	Subnets      []SubnetModel      `json:"subnets"`
+	Reservations []ReservationModel `json:"reservations"`
+
+	// Опции, которые сервер не сможет отправить клиентам (значение не
+	// соответствует типу опции). При работе о них пишется предупреждение
+	// в журнал на каждый ответ.
+	Issues []string `json:"issues"`
+}
+
+// SubnetModel подсеть с пулом и опциями, которые получают ее клиенты
+type SubnetModel struct {
+	SubnetUsage
+	LeaseTime int64             `json:"lease_time"` // Срок динамической аренды, секунды (0 - бессрочно)
+	Boot      BootModel         `json:"boot"`
+	Options   map[string]string `json:"options"`
+}
+
+// ReservationModel резервирование с опциями, которые получает клиент
+type ReservationModel struct {
+	Reservation
+	SubnetID  string            `json:"subnet_id,omitempty"`
+	LeaseTime int64             `json:"lease_time"`
+	Boot      BootModel         `json:"boot"`
+	Options   map[string]string `json:"options"`
+}
+
+// BootModel параметры загрузки: siaddr, sname и file ответа
+type BootModel struct {
+	NextServer string `json:"next_server,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+	Filename   string `json:"filename,omitempty"`
+}
+
+// RuntimeModel возвращает действующую модель сервера. Значения опций
+// проверяются так же, как при отправке ответа; выражения остаются в
+// исходном виде, так как вычисляются для каждого запроса.
+func (s *BOOTPServer) RuntimeModel() RuntimeModel {
+	s.mutex.Lock()
+	cfg, runtime := s.config, s.runtime
+	usage := make([]SubnetUsage, len(cfg.Subnets))
+	now := s.clock.Now()
+	for i := range cfg.Subnets {
+		usage[i] = s.subnetUsage(i, now)
+		usage[i].LowWater = s.poolLow[usage[i].ID]
+	}
+	managed := make(map[string]bool)
+	for _, host := range s.reservations {
+		managed[host.Name] = true
+	}
+	s.mutex.Unlock()
+
+	model := RuntimeModel{Subnets: []SubnetModel{}, Reservations: []ReservationModel{}, Issues: []string{}}
+	for i := range cfg.Subnets {
+		subnet := &cfg.Subnets[i]
+		options := mergeOptions(cfg.Options, subnet.Options)
+		sent, issues := sentOptions(cfg, runtime, options, subnet)
+		for _, issue := range issues {
+			model.Issues = append(model.Issues, fmt.Sprintf("subnet %s: %s", usage[i].ID, issue))
+		}
+		model.Subnets = append(model.Subnets, SubnetModel{
+			SubnetUsage: usage[i],
+			LeaseTime:   int64(s.leaseDuration(options, nil).Seconds()),
+			Boot:        mergeBoot(options, cfg.Boot, subnet.Boot),
+			Options:     sent,
+		})
+	}
+
+	reservation := func(compiled config.RuntimeHost, subnet *config.Subnet, isManaged bool) {
+		host := compiled.Host
+		if compiled.FixedIP == nil || (host.Hardware == "" && host.ClientID == "") {
+			return
+		}
+		r := ReservationModel{Reservation: Reservation{
+			Name:     host.Name,
+			MAC:      normalizeMAC(host.Hardware),
+			ClientID: host.ClientID,
+			IP:       compiled.FixedIP.String(),
+			Managed:  isManaged,
+		}}
+		options := mergeOptions(cfg.Options, host.Options)
+		boot := mergeBoot(options, cfg.Boot, host.Boot)
+		if subnet != nil {
+			r.Subnet, r.SubnetID = subnet.Network.IP.String(), subnetID(subnet)
+			options = mergeOptions(cfg.Options, subnet.Options, host.Options)
+			boot = mergeBoot(options, cfg.Boot, subnet.Boot, host.Boot)
+		}
+		sent, issues := sentOptions(cfg, runtime, options, subnet)
+		for _, issue := range issues {
+			model.Issues = append(model.Issues, fmt.Sprintf("host %s: %s", host.Name, issue))
+		}
+		r.LeaseTime, r.Boot, r.Options = int64(s.leaseDuration(options, nil).Seconds()), boot, sent
+		model.Reservations = append(model.Reservations, r)
+	}
+	for i := range runtime.Subnets {
+		for _, host := range runtime.Subnets[i].Hosts {
+			reservation(host, runtime.Subnets[i].Subnet, false)
+		}
+	}
+	for _, host := range runtime.Hosts {
+		if host.FixedIP != nil {
+			reservation(host, subnetOf(cfg, ipToInt(host.FixedIP)), managed[host.Host.Name])
+		}
+	}
+	return model
+}
+
+// mergeOptions объединяет опции уровней конфигурации: каждый следующий
+// уровень переопределяет значения предыдущего (см. clientOptions)
+func mergeOptions(levels ...map[string]string) map[string]string {
+	options := make(map[string]string)
+	for _, level := range levels {
+		for key, value := range level {
+			options[key] = value
+		}
+	}
+	return options
+}
+
+// mergeBoot объединяет параметры загрузки уровней конфигурации так же,
+// как bootParameters
+func mergeBoot(options map[string]string, levels ...config.BootParams) BootModel {
+	var boot BootModel
+	for _, level := range levels {
+		if level.NextServer != "" {
+			boot.NextServer = level.NextServer
+		}
+		if level.ServerName != "" {
+			boot.ServerName = level.ServerName
+		}
+		if level.Filename != "" {
+			boot.Filename = level.Filename
+		}
+	}
+	if boot.ServerName == "" {
+		boot.ServerName = options["tftp-server-name"]
+	}
+	if boot.Filename == "" {
+		boot.Filename = options["bootfile-name"]
+	}
+	return boot
+}
+
+// sentOptions возвращает опции, которые setConfigOptions добавит в ответ
+// клиенту подсети subnet, и ошибки кодирования значений
+func sentOptions(cfg *config.DHCPConfig, runtime *config.Runtime, options map[string]string, subnet *config.Subnet) (map[string]string, []string) {
+	sent := make(map[string]string)
+	if mask, broadcast := subnetBroadcast(subnet); mask != nil {
+		sent["subnet-mask"] = mask.String()
+		sent["broadcast-address"] = broadcast.String()
+	}
+
+	var issues []string
+	space := options[config.VendorOptionSpace]
+	for name, value := range options {
+		definition, ok := config.LookupOption(cfg, name)
+		if !ok || value == "" {
+			continue
+		}
+		if prefix, _, inSpace := strings.Cut(name, "."); inSpace && prefix != space {
+			continue
+		}
+		if _, expression := runtime.Expressions[value]; !expression {
+			if _, err := config.EncodeOption(definition.Type, value); err != nil {
+				issues = append(issues, fmt.Sprintf("option %s is not sent: %v", name, err))
+				continue
+			}
+		}
+		sent[name] = value
+	}
+	sort.Strings(issues)
+	return sent, issues
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestRuntimeModel(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Options: map[string]string{"domain-name-servers": "8.8.8.8", "routers": "10.0.0.1"},
+		Subnets: []config.Subnet{{
+			Network: config.MustParseNetwork("192.168.1.0/24"),
+			Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.109"}},
+			Options: map[string]string{
+				"routers":               "192.168.1.1",
+				"domain-name":           `concat("host-", binary-to-ascii(10, 8, "-", leased-address))`,
+				config.MaxLeaseTime:     "7200",
+				config.DefaultLeaseTime: "3600",
+			},
+			Boot: config.BootParams{Filename: "pxelinux.0"},
+			Hosts: []config.Host{{
+				Name: "client1", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10",
+				Options: map[string]string{"routers": "192.168.1.254"},
+				Boot:    config.BootParams{NextServer: "192.168.1.2"},
+			}},
+		}},
+		Hosts: []config.Host{
+			{Name: "outside", Hardware: "00:11:22:33:44:66", FixedIP: "10.1.0.5"},
+			{Name: "known", Hardware: "00:11:22:33:44:77"},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := server.RuntimeModel()
+	if len(model.Subnets) != 1 || len(model.Reservations) != 2 || len(model.Issues) != 0 {
+		t.Fatalf("Unexpected model %+v", model)
+	}
+	subnet := model.Subnets[0]
+	if subnet.Size != 10 || subnet.Static != 1 || subnet.LeaseTime != 3600 || subnet.Boot.Filename != "pxelinux.0" {
+		t.Errorf("Unexpected subnet %+v", subnet)
+	}
+	if subnet.Options["routers"] != "192.168.1.1" || subnet.Options["domain-name-servers"] != "8.8.8.8" || subnet.Options["broadcast-address"] != "192.168.1.255" {
+		t.Errorf("Unexpected subnet options %v", subnet.Options)
+	}
+	// Выражения вычисляются для каждого запроса и выводятся как есть
+	if subnet.Options["domain-name"] == "" {
+		t.Errorf("Expected expression in subnet options %v", subnet.Options)
+	}
+	if _, ok := subnet.Options[config.DefaultLeaseTime]; ok {
+		t.Errorf("Lease time is not an option: %v", subnet.Options)
+	}
+
+	host := model.Reservations[0]
+	if host.Name != "client1" || host.SubnetID != "192.168.1.0/24" || host.Options["routers"] != "192.168.1.254" || host.LeaseTime != 3600 {
+		t.Errorf("Unexpected reservation %+v", host)
+	}
+	if host.Boot.NextServer != "192.168.1.2" || host.Boot.Filename != "pxelinux.0" {
+		t.Errorf("Unexpected boot parameters %+v", host.Boot)
+	}
+	// Адрес вне подсетей получает только глобальные опции
+	outside := model.Reservations[1]
+	if outside.Subnet != "" || outside.Options["routers"] != "10.0.0.1" || outside.Options["subnet-mask"] != "" {
+		t.Errorf("Unexpected reservation outside subnets %+v", outside)
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import "sort"
+
+// Коды DHCP опций
+const (
+	OptionPad              = 0
+	OptionSubnetMask       = 1
+	OptionHostName         = 12
+	OptionDomainName       = 15
+	OptionBroadcastAddress = 28
+	OptionVendorSpecific   = 43
+	OptionRequestedIP      = 50
+	OptionLeaseTime        = 51
+	OptionOverload         = 52
+	OptionMessageType      = 53
+	OptionServerIdentifier = 54
+	OptionParameterList    = 55
+	OptionMaxMessageSize   = 57
+	OptionRenewalTime      = 58
+	OptionRebindingTime    = 59
+	OptionVendorClass      = 60
+	OptionClientIdentifier = 61
+	OptionTFTPServerName   = 66
+	OptionBootfileName     = 67
+	OptionUserClass        = 77
+	OptionRelayAgentInfo   = 82
+	OptionClientArch       = 93
+	OptionEnd              = 255
+)
+
+// Типы DHCP сообщений (опция 53)
+const (
+	DHCPDiscover = 1
+	DHCPOffer    = 2
+	DHCPRequest  = 3
+	DHCPDecline  = 4
+	DHCPAck      = 5
+	DHCPNak      = 6
+	DHCPRelease  = 7
+	DHCPInform   = 8
+)
+
+// bootpHeaderSize размер фиксированной части пакета вместе с magic cookie
+const bootpHeaderSize = 240
+
+// Значения опции 52: поля заголовка, содержащие опции (RFC 2132, 9.3)
+const (
+	overloadFile  = 1
+	overloadSname = 2
+)
+
+// parseOptions разбирает область опций DHCP (после magic cookie).
+// Некорректный хвост пакета игнорируется.
+func parseOptions(data []byte) map[uint8][]byte {
+	options := make(map[uint8][]byte)
+
+	for i := 0; i < len(data); {
+		code := data[i]
+		if code == OptionEnd {
+			break
+		}
+		if code == OptionPad {
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			break
+		}
+		length := int(data[i+1])
+		if i+2+length > len(data) {
+			break
+		}
+		value := data[i+2 : i+2+length]
+		if previous, exists := options[code]; exists {
+			// Длинные опции передаются несколькими экземплярами (RFC 3396).
+			// Срез ограничивается, чтобы не затереть данные пакета
+			value = append(previous[:len(previous):len(previous)], value...)
+		}
+		options[code] = value
+		i += 2 + length
+	}
+
+	return options
+}
+
+// copyOptions возвращает копию опций, не разделяющую память с исходными
+func copyOptions(options map[uint8][]byte) map[uint8][]byte {
+	result := make(map[uint8][]byte, len(options))
+	for code, value := range options {
+		result[code] = append([]byte(nil), value...)
+	}
+	return result
+}
+
+// messageType возвращает тип DHCP сообщения или 0 для чистого BOOTP
+func messageType(options map[uint8][]byte) uint8 {
+	if value, ok := options[OptionMessageType]; ok && len(value) == 1 {
+		return value[0]
+	}
+	return 0
+}
+
+// encodeOptions кодирует опции в порядке возрастания кодов и завершает
+// их опцией End
+func encodeOptions(options map[uint8][]byte) []byte {
+	return appendOptions(nil, options, nil)
+}
+
+// appendOptions дописывает к data опции и End. Если клиент прислал список
+// запрошенных параметров requested (опция 55), опции размещаются в том же
+// порядке, что и при нехватке места (см. optionPriority), иначе в порядке
+// возрастания кодов. Коды перебираются по порядку вместо сортировки, чтобы
+// не выделять память.
+func appendOptions(data []byte, options map[uint8][]byte, requested []byte) []byte {
+	var present [256]bool
+	for code := range options {
+		present[code] = true
+	}
+	if len(requested) > 0 {
+		for _, code := range requiredReplyOptions {
+			if present[code] {
+				data = appendOption(data, code, options[code])
+				present[code] = false
+			}
+		}
+		for _, code := range requested {
+			if present[code] {
+				data = appendOption(data, code, options[code])
+				present[code] = false
+			}
+		}
+	}
+	for code := range present {
+		if present[code] {
+			data = appendOption(data, uint8(code), options[uint8(code)])
+		}
+	}
+	return append(data, OptionEnd)
+}
+
+// optionCodes возвращает коды опций в порядке возрастания
+func optionCodes(options map[uint8][]byte) []uint8 {
+	codes := make([]int, 0, len(options))
+	for code := range options {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	result := make([]uint8, len(codes))
+	for i, code := range codes {
+		result[i] = uint8(code)
+	}
+	return result
+}
+
+// encodeOption кодирует одну опцию. Значения длиннее 255 байт разбиваются
+// на несколько экземпляров с тем же кодом (RFC 3396).
+func encodeOption(code uint8, value []byte) []byte {
+	return appendOption(make([]byte, 0, 2+len(value)+2*(len(value)/255)), code, value)
+}
+
+// appendOption дописывает к data одну опцию (см. encodeOption)
+func appendOption(data []byte, code uint8, value []byte) []byte {
+	for {
+		chunk := value
+		if len(chunk) > 255 {
+			chunk = chunk[:255]
+		}
+		data = append(data, code, uint8(len(chunk)))
+		data = append(data, chunk...)
+		if value = value[len(chunk):]; len(value) == 0 {
+			return data
+		}
+	}
+}
+
+// packOptions кодирует опции ответа размером не более limit байт. Опции
+// размещаются в порядке приоритета (см. optionPriority): сначала в области
+// опций, затем в пустых полях file и sname заголовка с отметкой перегрузки
+// опцией 52 (RFC 2132, 9.3). Поля, занятые именем файла или сервера
+// загрузки, не перегружаются. Опции, не поместившиеся никуда,
+// отбрасываются, их коды возвращаются вторым значением.
+func packOptions(header *BOOTPHeader, options map[uint8][]byte, limit int, requested []byte) ([]byte, []uint8) {
+	data := encodeOptions(options)
+	if bootpHeaderSize+len(data) <= limit {
+		return data, nil
+	}
+
+	type area struct {
+		field    []byte
+		overload uint8
+		data     []byte
+	}
+	var areas []*area
+	if fieldString(header.File[:]) == "" {
+		areas = append(areas, &area{field: header.File[:], overload: overloadFile})
+	}
+	if fieldString(header.Sname[:]) == "" {
+		areas = append(areas, &area{field: header.Sname[:], overload: overloadSname})
+	}
+
+	// 1 байт резервируется под End и 3 байта под опцию 52, если есть
+	// поля для перегрузки
+	free := limit - bootpHeaderSize - 1
+	if len(areas) > 0 {
+		free -= 3
+	}
+
+	var main [][]byte
+	var dropped []uint8
+	var overload uint8
+	for _, code := range optionPriority(options, requested) {
+		option := encodeOption(code, options[code])
+		if len(option) <= free {
+			main = append(main, option)
+			free -= len(option)
+			continue
+		}
+		placed := false
+		for _, a := range areas {
+			if len(a.data)+len(option)+1 <= len(a.field) {
+				a.data = append(a.data, option...)
+				overload |= a.overload
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			dropped = append(dropped, code)
+		}
+	}
+
+	data = nil
+	if overload != 0 {
+		for _, a := range areas {
+			if len(a.data) > 0 {
+				copy(a.field, append(a.data, OptionEnd))
+			}
+		}
+		data = encodeOption(OptionOverload, []byte{overload})
+	}
+	for _, option := range main {
+		data = append(data, option...)
+	}
+	return append(data, OptionEnd), dropped
+}
+
+// replyMessageType возвращает тип ответа на DHCP запрос или 0, если
+// запрос не требует OFFER/ACK
+func replyMessageType(msgType uint8) uint8 {
+	switch msgType {
+	case DHCPDiscover:
+		return DHCPOffer
+	case DHCPRequest, DHCPInform:
+		return DHCPAck
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	data := []byte{
+		OptionPad,
+		OptionMessageType, 1, DHCPDiscover,
+		12, 4, 'h', 'o', 's', 't',
+		OptionEnd,
+		1, 4, 255, 255, 255, 0, // После OptionEnd опции не разбираются
+	}
+
+	options := parseOptions(data)
+	if len(options) != 2 {
+		t.Fatalf("Expected 2 options, got %d", len(options))
+	}
+
+	if msgType := messageType(options); msgType != DHCPDiscover {
+		t.Errorf("Expected message type %d, got %d", DHCPDiscover, msgType)
+	}
+
+	if hostname := string(options[12]); hostname != "host" {
+		t.Errorf("Expected hostname host, got %s", hostname)
+	}
+}
+
+func TestParseOptionsTruncated(t *testing.T) {
+	// Длина опции превышает размер данных
+	options := parseOptions([]byte{OptionMessageType, 5, 1})
+	if len(options) != 0 {
+		t.Errorf("Expected no options for truncated data, got %d", len(options))
+	}
+
+	// Код опции без длины
+	options = parseOptions([]byte{OptionMessageType})
+	if len(options) != 0 {
+		t.Errorf("Expected no options for truncated data, got %d", len(options))
+	}
+
+	if msgType := messageType(options); msgType != 0 {
+		t.Errorf("Expected message type 0 for BOOTP, got %d", msgType)
+	}
+}
+
+func TestLongOptions(t *testing.T) {
+	// Значение длиннее 255 байт передается несколькими экземплярами опции
+	value := bytes.Repeat([]byte{'x'}, 300)
+	data := encodeOption(17, value)
+	if len(data) != 2+255+2+45 || data[0] != 17 || data[1] != 255 || data[257] != 17 || data[258] != 45 {
+		t.Fatalf("Unexpected encoding of long option: % x", data[:4])
+	}
+
+	options := parseOptions(append(data, OptionEnd))
+	if !bytes.Equal(options[17], value) {
+		t.Errorf("Expected instances to be concatenated, got %d bytes", len(options[17]))
+	}
+}
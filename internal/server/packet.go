@@ -0,0 +1,247 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// Ограничения для входящих пакетов
+const (
+	maxPacketSize = 1500 // Пакеты больше MTU Ethernet не принимаются
+	maxHops       = 16   // RFC 1542: пакеты с большим числом ретрансляций отбрасываются
+	minReplySize  = 300  // RFC 951: минимальный размер BOOTP пакета
+	maxReplySize  = 548  // RFC 2131: клиент обязан принимать сообщения с 312 байтами опций
+)
+
+// magicCookie значение magic cookie DHCP (RFC 2131)
+var magicCookie = [4]byte{99, 130, 83, 99}
+
+// replyBuffers буферы для сериализации ответов. Буфер возвращается в пул
+// после отправки ответа, поэтому обработка пакета не выделяет память под
+// его сериализацию.
+var replyBuffers = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, 0, maxPacketSize)
+		return &buffer
+	},
+}
+
+// Ошибки разбора пакета
+var (
+	ErrShortPacket  = errors.New("packet too short")
+	ErrLargePacket  = errors.New("packet too large")
+	ErrInvalidOp    = errors.New("invalid op code")
+	ErrInvalidHtype = errors.New("invalid hardware type")
+	ErrInvalidHlen  = errors.New("invalid hardware address length")
+	ErrTooManyHops  = errors.New("too many hops")
+)
+
+// Packet представляет разобранный входящий пакет
+type Packet struct {
+	Header  BOOTPHeader
+	Options map[uint8][]byte // Опции DHCP (пусто для чистого BOOTP)
+}
+
+// DecodePacket разбирает и проверяет пакет. В отличие от прямого чтения
+// в структуру, отвергает короткие и некорректные пакеты и никогда не
+// паникует на произвольных входных данных.
+func DecodePacket(data []byte) (*Packet, error) {
+	if len(data) < bootpHeaderSize {
+		return nil, ErrShortPacket
+	}
+	if len(data) > maxPacketSize {
+		return nil, ErrLargePacket
+	}
+
+	packet := &Packet{}
+	if err := packet.Header.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	header := &packet.Header
+	if header.Op != BOOTPRequest && header.Op != BOOTPReply {
+		return nil, ErrInvalidOp
+	}
+	if header.Htype == 0 {
+		return nil, ErrInvalidHtype
+	}
+	if header.Hlen == 0 || int(header.Hlen) > len(header.Chaddr) {
+		return nil, ErrInvalidHlen
+	}
+	if header.Htype == HTYPE_ETHER && header.Hlen != 6 {
+		return nil, ErrInvalidHlen
+	}
+	if header.Hops > maxHops {
+		return nil, ErrTooManyHops
+	}
+
+	// Опции разбираются только при наличии magic cookie
+	if header.Magic == magicCookie {
+		packet.Options = parseOptions(data[bootpHeaderSize:])
+	} else {
+		packet.Options = make(map[uint8][]byte)
+	}
+
+	// Перегруженные поля содержат опции, а не строки (RFC 2132, 9.3).
+	// Опции из области опций имеют приоритет. Значения копируются, так как
+	// поля затем очищаются
+	if overload := packet.Options[OptionOverload]; len(overload) == 1 {
+		for _, field := range overloadedFields(header, overload[0]) {
+			for code, value := range parseOptions(field) {
+				if _, exists := packet.Options[code]; !exists {
+					packet.Options[code] = append([]byte(nil), value...)
+				}
+			}
+			for i := range field {
+				field[i] = 0
+			}
+		}
+	}
+
+	// Строковые поля ограничены своим размером и обрезаются по первому нулю
+	sanitizeField(header.Sname[:])
+	sanitizeField(header.File[:])
+
+	return packet, nil
+}
+
+// overloadedFields возвращает поля заголовка, перегруженные опциями,
+// в порядке разбора: сначала file, затем sname
+func overloadedFields(header *BOOTPHeader, overload uint8) [][]byte {
+	var fields [][]byte
+	if overload&overloadFile != 0 {
+		fields = append(fields, header.File[:])
+	}
+	if overload&overloadSname != 0 {
+		fields = append(fields, header.Sname[:])
+	}
+	return fields
+}
+
+// sanitizeField обнуляет содержимое поля после первого нулевого байта,
+// чтобы мусор за концом строки не попадал в обработку
+func sanitizeField(field []byte) {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		for j := i; j < len(field); j++ {
+			field[j] = 0
+		}
+	}
+}
+
+// fieldString возвращает содержимое строкового поля до первого нулевого байта
+func fieldString(field []byte) string {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		return string(field[:i])
+	}
+	return string(field)
+}
+
+// Смещения полей заголовка в пакете (RFC 951). Формат пакета задается
+// ими, а не расположением полей структуры BOOTPHeader
+const (
+	offsetOp     = 0
+	offsetHtype  = 1
+	offsetHlen   = 2
+	offsetHops   = 3
+	offsetXid    = 4
+	offsetSecs   = 8
+	offsetFlags  = 10
+	offsetCiaddr = 12
+	offsetYiaddr = 16
+	offsetSiaddr = 20
+	offsetGiaddr = 24
+	offsetChaddr = 28
+	offsetSname  = 44
+	offsetFile   = 108
+	offsetMagic  = 236
+)
+
+// Marshal сериализует заголовок в bootpHeaderSize байт
+func (h *BOOTPHeader) Marshal() []byte {
+	data := make([]byte, bootpHeaderSize)
+	h.marshalTo(data)
+	return data
+}
+
+// marshalTo записывает заголовок в первые bootpHeaderSize байт data
+func (h *BOOTPHeader) marshalTo(data []byte) {
+	data[offsetOp] = h.Op
+	data[offsetHtype] = h.Htype
+	data[offsetHlen] = h.Hlen
+	data[offsetHops] = h.Hops
+	binary.BigEndian.PutUint32(data[offsetXid:], h.Xid)
+	binary.BigEndian.PutUint16(data[offsetSecs:], h.Secs)
+	binary.BigEndian.PutUint16(data[offsetFlags:], h.Flags)
+	copy(data[offsetCiaddr:], h.Ciaddr[:])
+	copy(data[offsetYiaddr:], h.Yiaddr[:])
+	copy(data[offsetSiaddr:], h.Siaddr[:])
+	copy(data[offsetGiaddr:], h.Giaddr[:])
+	copy(data[offsetChaddr:], h.Chaddr[:])
+	copy(data[offsetSname:], h.Sname[:])
+	copy(data[offsetFile:], h.File[:])
+	copy(data[offsetMagic:], h.Magic[:])
+}
+
+// Unmarshal разбирает заголовок из первых bootpHeaderSize байт data.
+// Поля не проверяются (см. DecodePacket).
+func (h *BOOTPHeader) Unmarshal(data []byte) error {
+	if len(data) < bootpHeaderSize {
+		return ErrShortPacket
+	}
+	h.Op = data[offsetOp]
+	h.Htype = data[offsetHtype]
+	h.Hlen = data[offsetHlen]
+	h.Hops = data[offsetHops]
+	h.Xid = binary.BigEndian.Uint32(data[offsetXid:])
+	h.Secs = binary.BigEndian.Uint16(data[offsetSecs:])
+	h.Flags = binary.BigEndian.Uint16(data[offsetFlags:])
+	copy(h.Ciaddr[:], data[offsetCiaddr:])
+	copy(h.Yiaddr[:], data[offsetYiaddr:])
+	copy(h.Siaddr[:], data[offsetSiaddr:])
+	copy(h.Giaddr[:], data[offsetGiaddr:])
+	copy(h.Chaddr[:], data[offsetChaddr:])
+	copy(h.Sname[:], data[offsetSname:])
+	copy(h.File[:], data[offsetFile:])
+	copy(h.Magic[:], data[offsetMagic:])
+	return nil
+}
+
+// EncodeReply сериализует заголовок ответа и опции DHCP в пределах
+// maxReplySize (см. encodeReply)
+func EncodeReply(header *BOOTPHeader, options map[uint8][]byte) []byte {
+	data, _ := encodeReply(nil, header, options, maxReplySize, nil)
+	return data
+}
+
+// encodeReply дописывает к dst ответ размером не более limit байт. Опции,
+// не поместившиеся в ответ, переносятся в пустые поля file и sname или
+// отбрасываются (см. packOptions), коды отброшенных опций возвращаются
+// вторым значением. requested - список запрошенных параметров клиента
+// (опция 55), задающий порядок опций. Ответ дополняется нулями до минимального размера BOOTP пакета.
+// При достаточной емкости dst память не выделяется, если все опции
+// помещаются в область опций.
+func encodeReply(dst []byte, header *BOOTPHeader, options map[uint8][]byte, limit int, requested []byte) ([]byte, []uint8) {
+	start := len(dst)
+	dst = append(dst, make([]byte, bootpHeaderSize)...)
+
+	var dropped []uint8
+	if len(options) == 0 {
+		header.marshalTo(dst[start:])
+	} else if dst = appendOptions(dst, options, requested); len(dst)-start <= limit {
+		header.marshalTo(dst[start:])
+	} else {
+		// Опции не помещаются: заголовок меняется при перегрузке полей
+		packed := *header
+		var encoded []byte
+		encoded, dropped = packOptions(&packed, options, limit, requested)
+		dst = append(dst[:start+bootpHeaderSize], encoded...)
+		packed.marshalTo(dst[start:])
+	}
+
+	if size := len(dst) - start; size < minReplySize {
+		dst = append(dst, make([]byte, minReplySize-size)...)
+	}
+	return dst, dropped
+}
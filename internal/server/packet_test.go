@@ -0,0 +1,347 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// encodeTestPacket сериализует заголовок и опции в пакет
+func encodeTestPacket(t testing.TB, header *BOOTPHeader, options []byte) []byte {
+	var buffer bytes.Buffer
+	if err := binary.Write(&buffer, binary.BigEndian, header); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Write(options)
+	return buffer.Bytes()
+}
+
+func validTestHeader() *BOOTPHeader {
+	return &BOOTPHeader{
+		Op:     BOOTPRequest,
+		Htype:  HTYPE_ETHER,
+		Hlen:   6,
+		Xid:    0x12345678,
+		Chaddr: [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Magic:  magicCookie,
+	}
+}
+
+func TestDecodePacket(t *testing.T) {
+	header := validTestHeader()
+	copy(header.File[:], "pxelinux.0\x00garbage")
+	data := encodeTestPacket(t, header, []byte{OptionMessageType, 1, DHCPDiscover, OptionEnd})
+
+	packet, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("Failed to decode packet: %v", err)
+	}
+
+	if packet.Header.Xid != 0x12345678 {
+		t.Errorf("Expected xid 0x12345678, got 0x%x", packet.Header.Xid)
+	}
+
+	if msgType := messageType(packet.Options); msgType != DHCPDiscover {
+		t.Errorf("Expected message type %d, got %d", DHCPDiscover, msgType)
+	}
+
+	// Мусор после завершающего нуля удаляется
+	if file := fieldString(packet.Header.File[:]); file != "pxelinux.0" {
+		t.Errorf("Expected file pxelinux.0, got %q", file)
+	}
+	if !bytes.Equal(packet.Header.File[11:18], make([]byte, 7)) {
+		t.Error("Expected bytes after terminator to be zeroed")
+	}
+}
+
+func TestDecodePacketErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*BOOTPHeader)
+		err    error
+	}{
+		{"invalid op", func(h *BOOTPHeader) { h.Op = 3 }, ErrInvalidOp},
+		{"zero htype", func(h *BOOTPHeader) { h.Htype = 0 }, ErrInvalidHtype},
+		{"zero hlen", func(h *BOOTPHeader) { h.Hlen = 0 }, ErrInvalidHlen},
+		{"hlen too large", func(h *BOOTPHeader) { h.Htype = 6; h.Hlen = 17 }, ErrInvalidHlen},
+		{"ethernet hlen", func(h *BOOTPHeader) { h.Hlen = 8 }, ErrInvalidHlen},
+		{"too many hops", func(h *BOOTPHeader) { h.Hops = 17 }, ErrTooManyHops},
+	}
+
+	for _, test := range tests {
+		header := validTestHeader()
+		test.modify(header)
+		if _, err := DecodePacket(encodeTestPacket(t, header, nil)); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	if _, err := DecodePacket(make([]byte, bootpHeaderSize-1)); err != ErrShortPacket {
+		t.Errorf("Expected %v for short packet, got %v", ErrShortPacket, err)
+	}
+
+	if _, err := DecodePacket(make([]byte, maxPacketSize+1)); err != ErrLargePacket {
+		t.Errorf("Expected %v for large packet, got %v", ErrLargePacket, err)
+	}
+}
+
+func TestDecodePacketWithoutMagic(t *testing.T) {
+	// Чистый BOOTP пакет без magic cookie: область vend не разбирается как опции
+	header := validTestHeader()
+	header.Magic = [4]byte{}
+	data := encodeTestPacket(t, header, []byte{OptionMessageType, 1, DHCPDiscover, OptionEnd})
+
+	packet, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("Failed to decode packet: %v", err)
+	}
+	if len(packet.Options) != 0 {
+		t.Errorf("Expected no options without magic cookie, got %d", len(packet.Options))
+	}
+}
+
+func TestDecodePacketOverload(t *testing.T) {
+	// Опции в поле file и sname, отмеченные опцией 52
+	header := validTestHeader()
+	copy(header.File[:], []byte{12, 4, 'h', 'o', 's', 't', OptionEnd, 'x'})
+	copy(header.Sname[:], []byte{OptionRequestedIP, 4, 192, 168, 1, 10, OptionEnd})
+	data := encodeTestPacket(t, header, []byte{
+		OptionMessageType, 1, DHCPRequest,
+		OptionOverload, 1, overloadFile | overloadSname,
+		12, 3, 'o', 'w', 'n',
+		OptionEnd,
+	})
+
+	packet, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("Failed to decode packet: %v", err)
+	}
+	if hostname := string(packet.Options[12]); hostname != "own" {
+		t.Errorf("Expected option from the options area to win, got %q", hostname)
+	}
+	if requested := packet.Options[OptionRequestedIP]; !bytes.Equal(requested, []byte{192, 168, 1, 10}) {
+		t.Errorf("Expected requested address from sname, got %v", requested)
+	}
+	if fieldString(packet.Header.File[:]) != "" || fieldString(packet.Header.Sname[:]) != "" {
+		t.Error("Expected overloaded fields to be cleared")
+	}
+}
+
+func TestEncodeReplyOverload(t *testing.T) {
+	header := &BOOTPHeader{Op: BOOTPReply, Htype: HTYPE_ETHER, Hlen: 6, Magic: magicCookie}
+	copy(header.File[:], "pxelinux.0")
+	options := map[uint8][]byte{
+		OptionMessageType:  {DHCPAck},
+		OptionBootfileName: []byte("pxelinux.0"),
+		OptionDomainName:   bytes.Repeat([]byte{'d'}, 200),
+		OptionHostName:     bytes.Repeat([]byte{'h'}, 60),
+		17:                 bytes.Repeat([]byte{'/'}, 40), // root-path
+	}
+
+	data := EncodeReply(header, options)
+	if len(data) > maxReplySize {
+		t.Errorf("Expected reply to fit into %d bytes, got %d", maxReplySize, len(data))
+	}
+	if fieldString(header.File[:]) != "pxelinux.0" {
+		t.Error("Expected caller's header not to be modified")
+	}
+
+	// Поле file занято именем файла, опции переносятся в sname
+	packet, err := DecodePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overload := packet.Options[OptionOverload]; !bytes.Equal(overload, []byte{overloadSname}) {
+		t.Errorf("Expected sname to be overloaded, got %v", overload)
+	}
+	if file := fieldString(packet.Header.File[:]); file != "pxelinux.0" {
+		t.Errorf("Expected boot file to stay in the file field, got %q", file)
+	}
+	for code, value := range options {
+		if !bytes.Equal(packet.Options[code], value) {
+			t.Errorf("Option %d lost or changed after overload", code)
+		}
+	}
+
+	// Опции, помещающиеся в ответ, не перегружают поля
+	data = EncodeReply(header, map[uint8][]byte{OptionMessageType: {DHCPAck}})
+	packet, err = DecodePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := packet.Options[OptionOverload]; exists {
+		t.Error("Expected no overload for a small reply")
+	}
+}
+
+func TestHeaderFieldOffsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    func(h *BOOTPHeader)
+		offset int
+		size   int
+	}{
+		{"op", func(h *BOOTPHeader) { h.Op = 0xff }, 0, 1},
+		{"htype", func(h *BOOTPHeader) { h.Htype = 0xff }, 1, 1},
+		{"hlen", func(h *BOOTPHeader) { h.Hlen = 0xff }, 2, 1},
+		{"hops", func(h *BOOTPHeader) { h.Hops = 0xff }, 3, 1},
+		{"xid", func(h *BOOTPHeader) { h.Xid = 0xffffffff }, 4, 4},
+		{"secs", func(h *BOOTPHeader) { h.Secs = 0xffff }, 8, 2},
+		{"flags", func(h *BOOTPHeader) { h.Flags = 0xffff }, 10, 2},
+		{"ciaddr", func(h *BOOTPHeader) { h.Ciaddr = [4]byte{0xff, 0xff, 0xff, 0xff} }, 12, 4},
+		{"yiaddr", func(h *BOOTPHeader) { h.Yiaddr = [4]byte{0xff, 0xff, 0xff, 0xff} }, 16, 4},
+		{"siaddr", func(h *BOOTPHeader) { h.Siaddr = [4]byte{0xff, 0xff, 0xff, 0xff} }, 20, 4},
+		{"giaddr", func(h *BOOTPHeader) { h.Giaddr = [4]byte{0xff, 0xff, 0xff, 0xff} }, 24, 4},
+		{"chaddr", func(h *BOOTPHeader) { copy(h.Chaddr[:], bytes.Repeat([]byte{0xff}, 16)) }, 28, 16},
+		{"sname", func(h *BOOTPHeader) { copy(h.Sname[:], bytes.Repeat([]byte{0xff}, 64)) }, 44, 64},
+		{"file", func(h *BOOTPHeader) { copy(h.File[:], bytes.Repeat([]byte{0xff}, 128)) }, 108, 128},
+		{"magic", func(h *BOOTPHeader) { h.Magic = [4]byte{0xff, 0xff, 0xff, 0xff} }, 236, 4},
+	}
+
+	covered := 0
+	for _, test := range tests {
+		var header BOOTPHeader
+		test.set(&header)
+
+		// Поле занимает ровно свои байты пакета
+		data := header.Marshal()
+		if len(data) != bootpHeaderSize {
+			t.Fatalf("%s: expected %d bytes, got %d", test.name, bootpHeaderSize, len(data))
+		}
+		for i, b := range data {
+			inField := i >= test.offset && i < test.offset+test.size
+			if inField && b != 0xff || !inField && b != 0 {
+				t.Errorf("%s: unexpected byte %#x at offset %d", test.name, b, i)
+			}
+		}
+
+		var decoded BOOTPHeader
+		if err := decoded.Unmarshal(data); err != nil || decoded != header {
+			t.Errorf("%s: round trip failed: %+v (%v)", test.name, decoded, err)
+		}
+		covered += test.size
+	}
+	if covered != bootpHeaderSize {
+		t.Errorf("Fields cover %d of %d header bytes", covered, bootpHeaderSize)
+	}
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		data := make([]byte, bootpHeaderSize)
+		random.Read(data)
+
+		var header BOOTPHeader
+		if err := header.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(header.Marshal(), data) {
+			t.Fatalf("Round trip changed header %x", data)
+		}
+
+		// Формат совпадает с прямой записью структуры
+		if reference := encodeTestPacket(t, &header, nil); !bytes.Equal(reference, data) {
+			t.Fatalf("Header %+v differs from binary.Write:\n%x\n%x", header, data, reference)
+		}
+	}
+
+	// Данные после заголовка игнорируются, короткие данные отвергаются
+	header := validTestHeader()
+	var decoded BOOTPHeader
+	if err := decoded.Unmarshal(append(header.Marshal(), OptionEnd)); err != nil || decoded != *header {
+		t.Errorf("Expected header to be decoded, got %+v (%v)", decoded, err)
+	}
+	if err := decoded.Unmarshal(make([]byte, bootpHeaderSize-1)); err != ErrShortPacket {
+		t.Errorf("Expected ErrShortPacket, got %v", err)
+	}
+}
+
+func TestEncodeReplyAppend(t *testing.T) {
+	header := validTestHeader()
+	header.Op = BOOTPReply
+	options := map[uint8][]byte{OptionMessageType: {DHCPAck}, OptionLeaseTime: {0, 0, 0x0e, 0x10}}
+
+	// Ответ дописывается после данных, уже находящихся в буфере
+	want := encodeTestPacket(t, header, []byte{OptionLeaseTime, 4, 0, 0, 0x0e, 0x10, OptionMessageType, 1, DHCPAck, OptionEnd})
+	data, _ := encodeReply([]byte("prefix"), header, options, maxReplySize, nil)
+	if string(data[:6]) != "prefix" || !bytes.Equal(data[6:6+len(want)], want) || len(data) != 6+minReplySize {
+		t.Fatalf("Unexpected reply encoding:\n%x", data)
+	}
+
+	// Ответ в буфер достаточной емкости не выделяет память
+	if raceEnabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	buffer := make([]byte, 0, maxPacketSize)
+	allocs := testing.AllocsPerRun(100, func() {
+		buffer, _ = encodeReply(buffer[:0], header, options, maxReplySize, nil)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkDecodePacket(b *testing.B) {
+	data := encodeTestPacket(b, validTestHeader(), []byte{
+		OptionMessageType, 1, DHCPDiscover,
+		OptionParameterList, 4, 1, 3, 6, 15,
+		OptionMaxMessageSize, 2, 0x05, 0xdc,
+		OptionEnd,
+	})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodePacket(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeReply(b *testing.B) {
+	header := validTestHeader()
+	header.Op = BOOTPReply
+	copy(header.File[:], "pxelinux.0")
+	options := map[uint8][]byte{
+		OptionMessageType:      {DHCPOffer},
+		1:                      {255, 255, 255, 0}, // subnet-mask
+		3:                      {192, 168, 1, 1},   // routers
+		OptionDomainName:       []byte("example.com"),
+		OptionLeaseTime:        {0, 0, 0x0e, 0x10},
+		OptionServerIdentifier: {192, 168, 1, 1},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buffer := replyBuffers.Get().(*[]byte)
+		data, _ := encodeReply((*buffer)[:0], header, options, maxReplySize, nil)
+		*buffer = data[:0]
+		replyBuffers.Put(buffer)
+	}
+}
+
+func FuzzDecodePacket(f *testing.F) {
+	f.Add(encodeTestPacket(f, validTestHeader(), []byte{OptionMessageType, 1, DHCPDiscover, OptionEnd}))
+	f.Add(encodeTestPacket(f, validTestHeader(), []byte{OptionMessageType, 200}))
+	f.Add(make([]byte, bootpHeaderSize))
+	f.Add([]byte{BOOTPRequest})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet, err := DecodePacket(data)
+		if err != nil {
+			return
+		}
+
+		// Разобранный пакет должен проходить все инварианты
+		if int(packet.Header.Hlen) > len(packet.Header.Chaddr) || packet.Header.Hlen == 0 {
+			t.Fatalf("Invalid hlen %d accepted", packet.Header.Hlen)
+		}
+		for code, value := range packet.Options {
+			if code == OptionPad || code == OptionEnd || len(value) > maxPacketSize {
+				t.Fatalf("Invalid option %d accepted", code)
+			}
+		}
+		_ = fieldString(packet.Header.File[:])
+		_ = fieldString(packet.Header.Sname[:])
+	})
+}
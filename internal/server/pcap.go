@@ -0,0 +1,367 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Направление захваченного пакета
+const (
+	CaptureReceived = "in"
+	CaptureSent     = "out"
+)
+
+// Параметры формата pcap
+const (
+	pcapMagic       = 0xa1b2c3d4
+	pcapSnapLen     = 65535
+	pcapLinkTypeRaw = 101 // LINKTYPE_RAW: пакет начинается с IP заголовка
+	pcapHeaderSize  = 24
+	pcapRecordSize  = 16
+	ipv4HeaderSize  = 20
+	udpHeaderSize   = 8
+)
+
+// Параметры ротации по умолчанию
+const (
+	defaultCaptureMaxSize  = 10 * 1024 * 1024
+	defaultCaptureMaxFiles = 5
+)
+
+// PacketDumper записывает входящие и исходящие пакеты для отладки
+type PacketDumper interface {
+	Dump(direction string, src, dst *net.UDPAddr, data []byte)
+	Close() error
+}
+
+// PcapWriter записывает пакеты в файл pcap с ротацией по размеру.
+// UDP датаграммы оборачиваются в синтетические IPv4/UDP заголовки,
+// чтобы файл открывался в Wireshark/tcpdump как обычный BOOTP трафик.
+type PcapWriter struct {
+	mutex    sync.Mutex
+	path     string
+	maxSize  int64 // Максимальный размер файла в байтах
+	maxFiles int   // Количество хранимых старых файлов
+	file     *os.File
+	size     int64
+}
+
+// NewPcapWriter создает файл захвата по пути path
+func NewPcapWriter(path string, maxSize int64, maxFiles int) (*PcapWriter, error) {
+	if maxSize <= 0 {
+		maxSize = defaultCaptureMaxSize
+	}
+	if maxFiles < 0 {
+		maxFiles = defaultCaptureMaxFiles
+	}
+
+	writer := &PcapWriter{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// open создает новый файл и записывает глобальный заголовок pcap
+func (w *PcapWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, pcapHeaderSize)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:6], 2) // Версия 2.4
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], pcapLinkTypeRaw)
+
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = pcapHeaderSize
+	return nil
+}
+
+// rotate переименовывает текущий файл в path.1, сдвигая старые файлы
+func (w *PcapWriter) rotate() error {
+	w.file.Close()
+
+	if w.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxFiles))
+		for i := w.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+		}
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return err
+		}
+	}
+
+	return w.open()
+}
+
+// Dump записывает пакет в файл
+func (w *PcapWriter) Dump(direction string, src, dst *net.UDPAddr, data []byte) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return
+	}
+
+	frame := buildIPv4UDP(src, dst, data)
+	now := time.Now()
+
+	record := make([]byte, pcapRecordSize, pcapRecordSize+len(frame))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(frame)))
+	record = append(record, frame...)
+
+	if w.size+int64(len(record)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			logrus.Errorf("Error rotating packet capture %s: %v", w.path, err)
+			w.file = nil
+			return
+		}
+	}
+
+	n, err := w.file.Write(record)
+	w.size += int64(n)
+	if err != nil {
+		logrus.Errorf("Error writing packet capture %s: %v", w.path, err)
+	}
+}
+
+// Close закрывает файл захвата
+func (w *PcapWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// buildIPv4UDP оборачивает данные в IPv4 и UDP заголовки
+func buildIPv4UDP(src, dst *net.UDPAddr, payload []byte) []byte {
+	total := ipv4HeaderSize + udpHeaderSize + len(payload)
+	frame := make([]byte, total)
+
+	// IPv4 заголовок
+	frame[0] = 0x45 // Версия 4, длина заголовка 5 слов
+	binary.BigEndian.PutUint16(frame[2:4], uint16(total))
+	frame[8] = 64 // TTL
+	frame[9] = 17 // UDP
+	copy(frame[12:16], udpAddrIP4(src))
+	copy(frame[16:20], udpAddrIP4(dst))
+	binary.BigEndian.PutUint16(frame[10:12], ipChecksum(frame[:ipv4HeaderSize]))
+
+	// UDP заголовок (контрольная сумма 0 допустима для IPv4)
+	udp := frame[ipv4HeaderSize:]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(udpAddrPort(src)))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(udpAddrPort(dst)))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderSize+len(payload)))
+	copy(udp[udpHeaderSize:], payload)
+
+	return frame
+}
+
+// ipChecksum вычисляет контрольную сумму IP заголовка
+func ipChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+func udpAddrIP4(addr *net.UDPAddr) []byte {
+	if addr != nil {
+		if ip := addr.IP.To4(); ip != nil {
+			return ip
+		}
+	}
+	return net.IPv4zero.To4()
+}
+
+func udpAddrPort(addr *net.UDPAddr) int {
+	if addr == nil {
+		return 0
+	}
+	return addr.Port
+}
+
+// HexDumper выводит пакеты в журнал в виде hex дампа
+type HexDumper struct{}
+
+// Dump выводит пакет в журнал
+func (HexDumper) Dump(direction string, src, dst *net.UDPAddr, data []byte) {
+	logrus.Infof("Packet %s %v -> %v (%d bytes)\n%s", direction, src, dst, len(data), hex.Dump(data))
+}
+
+// Close ничего не делает
+func (HexDumper) Close() error {
+	return nil
+}
+
+// captureSettings параметры захвата пакетов из глобальных опций
+type captureSettings struct {
+	hexdump  bool   // Вывод в журнал вместо файла
+	path     string // Файл pcap (пусто - захват выключен)
+	maxSize  int64
+	maxFiles int
+}
+
+// parseCaptureOptions читает параметры захвата пакетов из глобальных опций
+func parseCaptureOptions(options map[string]string) (captureSettings, error) {
+	settings := captureSettings{
+		path:     strings.Trim(options["packet-capture-file"], "\""),
+		maxSize:  defaultCaptureMaxSize,
+		maxFiles: defaultCaptureMaxFiles,
+	}
+	_, settings.hexdump = options["packet-capture-hexdump"]
+
+	// Размер файла задается в мегабайтах
+	if value, ok := options["packet-capture-max-size"]; ok {
+		mb, err := strconv.Atoi(value)
+		if err != nil || mb <= 0 {
+			return settings, fmt.Errorf("invalid packet-capture-max-size: %s", value)
+		}
+		settings.maxSize = int64(mb) * 1024 * 1024
+	}
+
+	if value, ok := options["packet-capture-files"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return settings, fmt.Errorf("invalid packet-capture-files: %s", value)
+		}
+		settings.maxFiles = n
+	}
+
+	return settings, nil
+}
+
+// configurePacketCapture включает захват пакетов по глобальным опциям
+func (s *BOOTPServer) configurePacketCapture(options map[string]string) error {
+	settings, err := parseCaptureOptions(options)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case settings.hexdump:
+		s.SetPacketDumper(HexDumper{})
+	case settings.path != "":
+		return s.StartPacketCapture(settings.path, settings.maxSize, settings.maxFiles)
+	}
+	return nil
+}
+
+// StartPacketCapture включает запись пакетов в файл pcap во время работы сервера
+func (s *BOOTPServer) StartPacketCapture(path string, maxSize int64, maxFiles int) error {
+	writer, err := NewPcapWriter(path, maxSize, maxFiles)
+	if err != nil {
+		return err
+	}
+	s.SetPacketDumper(writer)
+	s.logger.Infof("Packet capture enabled, writing to %s", path)
+	return nil
+}
+
+// StartHexDump включает вывод пакетов в журнал в виде hex дампа
+func (s *BOOTPServer) StartHexDump() {
+	s.SetPacketDumper(HexDumper{})
+	s.logger.Infof("Packet hex dump enabled")
+}
+
+// StopPacketCapture выключает захват пакетов
+func (s *BOOTPServer) StopPacketCapture() {
+	if s.PacketCaptureStatus().Mode != CaptureOff {
+		s.logger.Infof("Packet capture disabled")
+	}
+	s.SetPacketDumper(nil)
+}
+
+// Режимы захвата пакетов
+const (
+	CaptureOff     = "off"
+	CapturePcap    = "pcap"
+	CaptureHexDump = "hexdump"
+	CaptureCustom  = "custom" // Обработчик, установленный через SetPacketDumper
+)
+
+// CaptureStatus описывает текущий режим захвата пакетов
+type CaptureStatus struct {
+	Mode string `json:"mode"`           // off, pcap, hexdump или custom
+	File string `json:"file,omitempty"` // Файл pcap в режиме pcap
+}
+
+// PacketCaptureStatus возвращает текущий режим захвата пакетов
+func (s *BOOTPServer) PacketCaptureStatus() CaptureStatus {
+	s.captureMutex.RLock()
+	defer s.captureMutex.RUnlock()
+
+	switch dumper := s.capture.(type) {
+	case *PcapWriter:
+		return CaptureStatus{Mode: CapturePcap, File: dumper.path}
+	case HexDumper:
+		return CaptureStatus{Mode: CaptureHexDump}
+	case nil:
+		return CaptureStatus{Mode: CaptureOff}
+	}
+	return CaptureStatus{Mode: CaptureCustom}
+}
+
+// SetPacketDumper заменяет текущий обработчик захвата пакетов
+func (s *BOOTPServer) SetPacketDumper(dumper PacketDumper) {
+	s.captureMutex.Lock()
+	previous := s.capture
+	s.capture = dumper
+	s.captureMutex.Unlock()
+
+	if previous != nil {
+		if err := previous.Close(); err != nil {
+			s.logger.Errorf("Error closing packet capture: %v", err)
+		}
+	}
+}
+
+// dumpPacket передает пакет текущему обработчику захвата
+func (s *BOOTPServer) dumpPacket(direction string, src, dst *net.UDPAddr, data []byte) {
+	s.captureMutex.RLock()
+	defer s.captureMutex.RUnlock()
+
+	if s.capture != nil {
+		s.capture.Dump(direction, src, dst, data)
+	}
+}
+
+// localUDPAddr возвращает локальный адрес сокета
+func localUDPAddr(conn *net.UDPConn) *net.UDPAddr {
+	if conn != nil {
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			return addr
+		}
+	}
+	return &net.UDPAddr{IP: net.IPv4zero, Port: BOOTP_PORT}
+}
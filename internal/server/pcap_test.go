@@ -0,0 +1,185 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestPcapWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+
+	writer, err := NewPcapWriter(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create pcap writer: %v", err)
+	}
+
+	client := &net.UDPAddr{IP: net.IPv4(0, 0, 0, 0), Port: 68}
+	server := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1), Port: 67}
+	payload := []byte{BOOTPRequest, HTYPE_ETHER, 6, 0}
+	writer.Dump(CaptureReceived, client, server, payload)
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close pcap writer: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frameSize := ipv4HeaderSize + udpHeaderSize + len(payload)
+	if len(data) != pcapHeaderSize+pcapRecordSize+frameSize {
+		t.Fatalf("Unexpected capture size %d", len(data))
+	}
+
+	// Глобальный заголовок
+	if magic := binary.LittleEndian.Uint32(data[0:4]); magic != pcapMagic {
+		t.Errorf("Expected pcap magic 0x%x, got 0x%x", pcapMagic, magic)
+	}
+	if linkType := binary.LittleEndian.Uint32(data[20:24]); linkType != pcapLinkTypeRaw {
+		t.Errorf("Expected link type %d, got %d", pcapLinkTypeRaw, linkType)
+	}
+
+	// Заголовок записи
+	record := data[pcapHeaderSize:]
+	if captured := binary.LittleEndian.Uint32(record[8:12]); captured != uint32(frameSize) {
+		t.Errorf("Expected captured length %d, got %d", frameSize, captured)
+	}
+
+	// IPv4 и UDP заголовки
+	frame := record[pcapRecordSize:]
+	if frame[0] != 0x45 || frame[9] != 17 {
+		t.Errorf("Expected IPv4/UDP frame, got version 0x%x proto %d", frame[0], frame[9])
+	}
+	if !net.IP(frame[16:20]).Equal(server.IP) {
+		t.Errorf("Expected destination %v, got %v", server.IP, net.IP(frame[16:20]))
+	}
+	if ipChecksum(frame[:ipv4HeaderSize]) != 0 {
+		t.Error("Expected valid IPv4 header checksum")
+	}
+	if port := binary.BigEndian.Uint16(frame[22:24]); port != 67 {
+		t.Errorf("Expected destination port 67, got %d", port)
+	}
+	if string(frame[ipv4HeaderSize+udpHeaderSize:]) != string(payload) {
+		t.Error("Expected payload to be preserved")
+	}
+}
+
+func TestPcapWriterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+
+	// Размер файла позволяет записать не более одного пакета
+	recordSize := pcapRecordSize + ipv4HeaderSize + udpHeaderSize + 300
+	writer, err := NewPcapWriter(path, int64(pcapHeaderSize+recordSize), 2)
+	if err != nil {
+		t.Fatalf("Failed to create pcap writer: %v", err)
+	}
+	defer writer.Close()
+
+	for i := 0; i < 4; i++ {
+		writer.Dump(CaptureSent, nil, nil, make([]byte, 300))
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected capture file %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("Expected no more than 2 rotated files")
+	}
+}
+
+// recordingDumper запоминает захваченные пакеты
+type recordingDumper struct {
+	directions []string
+	closed     bool
+}
+
+func (d *recordingDumper) Dump(direction string, src, dst *net.UDPAddr, data []byte) {
+	d.directions = append(d.directions, direction)
+}
+
+func (d *recordingDumper) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestServerPacketCaptureToggle(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{GlobalOptions: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Без обработчика захват ничего не делает
+	server.dumpPacket(CaptureReceived, nil, nil, []byte{1})
+
+	if status := server.PacketCaptureStatus(); status.Mode != CaptureOff {
+		t.Errorf("Expected capture mode off, got %s", status.Mode)
+	}
+
+	dumper := &recordingDumper{}
+	server.SetPacketDumper(dumper)
+	if status := server.PacketCaptureStatus(); status.Mode != CaptureCustom {
+		t.Errorf("Expected capture mode custom, got %s", status.Mode)
+	}
+	server.dumpPacket(CaptureReceived, nil, nil, []byte{1})
+	server.dumpPacket(CaptureSent, nil, nil, []byte{2})
+
+	if len(dumper.directions) != 2 || dumper.directions[0] != CaptureReceived || dumper.directions[1] != CaptureSent {
+		t.Errorf("Unexpected captured directions %v", dumper.directions)
+	}
+
+	// Выключение захвата закрывает обработчик
+	server.StopPacketCapture()
+	if !dumper.closed {
+		t.Error("Expected dumper to be closed")
+	}
+	server.dumpPacket(CaptureReceived, nil, nil, []byte{3})
+	if len(dumper.directions) != 2 {
+		t.Error("Expected no packets captured after stop")
+	}
+
+	path := filepath.Join(t.TempDir(), "bootp.pcap")
+	if err := server.StartPacketCapture(path, 0, 1); err != nil {
+		t.Fatalf("Failed to start packet capture: %v", err)
+	}
+	if status := server.PacketCaptureStatus(); status.Mode != CapturePcap || status.File != path {
+		t.Errorf("Expected pcap capture to %s, got %+v", path, status)
+	}
+	server.StartHexDump()
+	if status := server.PacketCaptureStatus(); status.Mode != CaptureHexDump {
+		t.Errorf("Expected capture mode hexdump, got %s", status.Mode)
+	}
+	server.StopPacketCapture()
+}
+
+func TestConfigurePacketCapture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{
+			"packet-capture-file":     "\"" + path + "\"",
+			"packet-capture-max-size": "1",
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	defer server.Stop()
+
+	if _, ok := server.capture.(*PcapWriter); !ok {
+		t.Errorf("Expected pcap writer, got %T", server.capture)
+	}
+
+	cfg.GlobalOptions["packet-capture-max-size"] = "big"
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected error for invalid packet-capture-max-size")
+	}
+}
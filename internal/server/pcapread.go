@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Заголовки канального уровня, поддерживаемые ReadPcap
+const (
+	pcapLinkTypeEthernet = 1
+	pcapLinkTypeIPv4     = 228
+	pcapLinkTypeSLL      = 113 // Linux cooked capture (tcpdump -i any)
+	pcapLinkTypeSLL2     = 276
+)
+
+// pcapMagicNano сигнатура файла pcap с наносекундными отметками времени
+const pcapMagicNano = 0xa1b23c4d
+
+// CapturedPacket UDP датаграмма из файла захвата
+type CapturedPacket struct {
+	Time time.Time
+	Src  *net.UDPAddr
+	Dst  *net.UDPAddr
+	Data []byte
+}
+
+// ReadPcap читает UDP датаграммы IPv4 из файла pcap. Кроме файлов
+// PcapWriter понимает захваты tcpdump с заголовками Ethernet (в том числе
+// с VLAN) и Linux cooked; остальные пакеты и фрагменты пропускаются.
+func ReadPcap(r io.Reader) ([]CapturedPacket, error) {
+	reader := bufio.NewReader(r)
+
+	header := make([]byte, pcapHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("reading pcap header: %v", err)
+	}
+
+	var order binary.ByteOrder
+	var nano bool
+	switch {
+	case binary.LittleEndian.Uint32(header) == pcapMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == pcapMagic:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header) == pcapMagicNano:
+		order, nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header) == pcapMagicNano:
+		order, nano = binary.BigEndian, true
+	default:
+		return nil, errors.New("not a pcap file (pcapng is not supported)")
+	}
+	linkType := order.Uint32(header[20:24]) & 0xffff
+
+	var packets []CapturedPacket
+	record := make([]byte, pcapRecordSize)
+	for {
+		if _, err := io.ReadFull(reader, record); err != nil {
+			if err == io.EOF {
+				return packets, nil
+			}
+			return packets, fmt.Errorf("reading pcap record %d: %v", len(packets)+1, err)
+		}
+		length := order.Uint32(record[8:12])
+		if length > pcapSnapLen {
+			return packets, fmt.Errorf("pcap record too large: %d bytes", length)
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return packets, fmt.Errorf("reading pcap record: %v", err)
+		}
+
+		fraction := time.Duration(order.Uint32(record[4:8]))
+		if !nano {
+			fraction *= time.Microsecond
+		}
+		timestamp := time.Unix(int64(order.Uint32(record[0:4])), int64(fraction))
+
+		if packet, ok := parseCapturedFrame(linkType, frame); ok {
+			packet.Time = timestamp
+			packets = append(packets, packet)
+		}
+	}
+}
+
+// parseCapturedFrame снимает заголовок канального уровня и разбирает
+// IPv4/UDP заголовки
+func parseCapturedFrame(linkType uint32, frame []byte) (CapturedPacket, bool) {
+	var etherType uint16
+	switch linkType {
+	case pcapLinkTypeRaw, pcapLinkTypeIPv4:
+		etherType = 0x0800
+	case pcapLinkTypeEthernet:
+		if len(frame) < 14 {
+			return CapturedPacket{}, false
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[12:14]), frame[14:]
+		// Метки 802.1Q и 802.1ad
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= 4 {
+			etherType, frame = binary.BigEndian.Uint16(frame[2:4]), frame[4:]
+		}
+	case pcapLinkTypeSLL:
+		if len(frame) < 16 {
+			return CapturedPacket{}, false
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[14:16]), frame[16:]
+	case pcapLinkTypeSLL2:
+		if len(frame) < 20 {
+			return CapturedPacket{}, false
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[0:2]), frame[20:]
+	}
+	if etherType != 0x0800 || len(frame) < ipv4HeaderSize || frame[0]>>4 != 4 {
+		return CapturedPacket{}, false
+	}
+
+	headerSize := int(frame[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(frame[2:4]))
+	fragment := binary.BigEndian.Uint16(frame[6:8])
+	if frame[9] != 17 || headerSize < ipv4HeaderSize || fragment&0x3fff != 0 ||
+		total < headerSize+udpHeaderSize || total > len(frame) {
+		return CapturedPacket{}, false
+	}
+
+	udp := frame[headerSize:total]
+	length := int(binary.BigEndian.Uint16(udp[4:6]))
+	if length < udpHeaderSize || length > len(udp) {
+		return CapturedPacket{}, false
+	}
+	return CapturedPacket{
+		Src:  &net.UDPAddr{IP: net.IP(append([]byte(nil), frame[12:16]...)), Port: int(binary.BigEndian.Uint16(udp[0:2]))},
+		Dst:  &net.UDPAddr{IP: net.IP(append([]byte(nil), frame[16:20]...)), Port: int(binary.BigEndian.Uint16(udp[2:4]))},
+		Data: udp[udpHeaderSize:length],
+	}, true
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadPcapRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	writer, err := NewPcapWriter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1).To4(), Port: 67}
+	server := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1).To4(), Port: 67}
+	before := time.Now().Truncate(time.Microsecond)
+	writer.Dump(CaptureReceived, client, server, []byte{BOOTPRequest, 1, 2, 3})
+	writer.Dump(CaptureSent, server, client, []byte{BOOTPReply, 4})
+	writer.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	packets, err := ReadPcap(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packets) != 2 {
+		t.Fatalf("Expected 2 packets, got %d", len(packets))
+	}
+	first := packets[0]
+	if !bytes.Equal(first.Data, []byte{BOOTPRequest, 1, 2, 3}) {
+		t.Errorf("Unexpected payload %v", first.Data)
+	}
+	if first.Src.String() != client.String() || first.Dst.String() != server.String() {
+		t.Errorf("Expected %v -> %v, got %v -> %v", client, server, first.Src, first.Dst)
+	}
+	if first.Time.Before(before) || time.Since(first.Time) > time.Minute {
+		t.Errorf("Unexpected timestamp %v", first.Time)
+	}
+	if packets[1].Data[0] != BOOTPReply || packets[1].Time.Before(first.Time) {
+		t.Errorf("Unexpected second packet %+v", packets[1])
+	}
+}
+
+// pcapFile собирает файл pcap с заданным типом канального уровня
+func pcapFile(order binary.ByteOrder, magic, linkType uint32, frames ...[]byte) []byte {
+	header := make([]byte, pcapHeaderSize)
+	order.PutUint32(header[0:4], magic)
+	order.PutUint16(header[4:6], 2)
+	order.PutUint16(header[6:8], 4)
+	order.PutUint32(header[16:20], pcapSnapLen)
+	order.PutUint32(header[20:24], linkType)
+
+	data := header
+	for i, frame := range frames {
+		record := make([]byte, pcapRecordSize)
+		order.PutUint32(record[0:4], 1700000000)
+		order.PutUint32(record[4:8], uint32(i))
+		order.PutUint32(record[8:12], uint32(len(frame)))
+		order.PutUint32(record[12:16], uint32(len(frame)))
+		data = append(append(data, record...), frame...)
+	}
+	return data
+}
+
+func TestReadPcapLinkTypes(t *testing.T) {
+	src := &net.UDPAddr{IP: net.IPv4(0, 0, 0, 0), Port: 68}
+	dst := &net.UDPAddr{IP: net.IPv4bcast, Port: 67}
+	payload := []byte{BOOTPRequest, 9}
+	ip := buildIPv4UDP(src, dst, payload)
+
+	ethernet := append([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0x08, 0x00}, ip...)
+	vlan := append([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0x81, 0x00, 0x00, 0x0a, 0x08, 0x00}, ip...)
+	arp := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0x08, 0x06, 0, 0}
+	sll := append(make([]byte, 16), ip...)
+	binary.BigEndian.PutUint16(sll[14:16], 0x0800)
+	fragment := append([]byte(nil), ip...)
+	fragment[6] = 0x20 // Установлен флаг MF
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"raw big endian", pcapFile(binary.BigEndian, pcapMagic, pcapLinkTypeRaw, ip), 1},
+		{"ethernet", pcapFile(binary.LittleEndian, pcapMagic, pcapLinkTypeEthernet, ethernet, arp, vlan), 2},
+		{"linux cooked", pcapFile(binary.LittleEndian, pcapMagicNano, pcapLinkTypeSLL, sll), 1},
+		{"fragment", pcapFile(binary.LittleEndian, pcapMagic, pcapLinkTypeRaw, fragment), 0},
+	}
+	for _, tt := range tests {
+		packets, err := ReadPcap(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(packets) != tt.want {
+			t.Errorf("%s: expected %d packets, got %d", tt.name, tt.want, len(packets))
+			continue
+		}
+		for _, packet := range packets {
+			if !bytes.Equal(packet.Data, payload) || packet.Dst.Port != 67 || !packet.Dst.IP.Equal(net.IPv4bcast) {
+				t.Errorf("%s: unexpected packet %+v", tt.name, packet)
+			}
+		}
+	}
+
+	// Наносекундные отметки времени не умножаются на 1000
+	packets, _ := ReadPcap(bytes.NewReader(pcapFile(binary.LittleEndian, pcapMagicNano, pcapLinkTypeRaw, ip, ip)))
+	if len(packets) != 2 || packets[1].Time.Sub(packets[0].Time) != time.Nanosecond {
+		t.Errorf("Expected timestamps 1ns apart, got %+v", packets)
+	}
+
+	if _, err := ReadPcap(bytes.NewReader([]byte("not a capture at all, not a capture"))); err == nil {
+		t.Error("Expected error for invalid file")
+	}
+	truncated := pcapFile(binary.LittleEndian, pcapMagic, pcapLinkTypeRaw, ip)
+	if _, err := ReadPcap(bytes.NewReader(truncated[:len(truncated)-4])); err == nil {
+		t.Error("Expected error for truncated record")
+	}
+}
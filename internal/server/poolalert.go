@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPoolAlertTimeout время ожидания ответа pool-alert-url
+const defaultPoolAlertTimeout = 5 * time.Second
+
+// PoolAlertEvent тип уведомления о заполнении пула
+type PoolAlertEvent string
+
+const (
+	PoolLow       PoolAlertEvent = "low"       // Свободных адресов стало меньше pool-low-watermark
+	PoolRecovered PoolAlertEvent = "recovered" // Свободных адресов снова не меньше pool-low-watermark
+)
+
+// PoolAlert уведомление, отправляемое на pool-alert-url
+type PoolAlert struct {
+	Event     PoolAlertEvent `json:"event"`
+	Time      time.Time      `json:"time"`
+	Watermark int            `json:"watermark"` // Порог свободных адресов, %
+	Subnet    SubnetUsage    `json:"subnet"`
+}
+
+// poolAlerts настройки контроля заполнения пулов
+type poolAlerts struct {
+	lowWater int          // Порог свободных адресов, % (0 - не контролируется)
+	reserve  int          // Запас адресов для известных клиентов, % пула
+	url      string       // Адрес для уведомлений (пусто - только журнал)
+	client   *http.Client // Клиент для отправки уведомлений
+}
+
+// parsePoolAlerts читает глобальные опции pool-low-watermark (порог
+// свободных адресов в процентах), pool-reserve (процент пула, который
+// выдается только известным клиентам) и pool-alert-url:
+//
+//	pool-low-watermark 10;
+//	pool-reserve 5;
+//	pool-alert-url "http://alerts.example.com/dhcp";
+func parsePoolAlerts(options map[string]string) (poolAlerts, error) {
+	var alerts poolAlerts
+	for name, value := range map[string]*int{"pool-low-watermark": &alerts.lowWater, "pool-reserve": &alerts.reserve} {
+		text, ok := options[name]
+		if !ok {
+			continue
+		}
+		percent, err := strconv.Atoi(text)
+		if err != nil || percent < 0 || percent > 99 {
+			return poolAlerts{}, fmt.Errorf("invalid %s: %s (must be 0-99)", name, text)
+		}
+		*value = percent
+	}
+
+	if alerts.url = strings.Trim(options["pool-alert-url"], "\""); alerts.url != "" {
+		if !strings.HasPrefix(alerts.url, "http://") && !strings.HasPrefix(alerts.url, "https://") {
+			return poolAlerts{}, fmt.Errorf("pool-alert-url must be an http(s) URL: %s", alerts.url)
+		}
+		alerts.client = &http.Client{Timeout: defaultPoolAlertTimeout}
+	}
+	return alerts, nil
+}
+
+// low возвращает true, если свободных адресов меньше порога
+func (a poolAlerts) low(u SubnetUsage) bool {
+	return a.lowWater > 0 && u.Size > 0 && u.Free*100 < u.Size*a.lowWater
+}
+
+// reserved возвращает true, если в пуле остался только запас для
+// известных клиентов
+func (a poolAlerts) reserved(u SubnetUsage) bool {
+	return a.reserve > 0 && u.Free <= int(math.Ceil(float64(u.Size*a.reserve)/100))
+}
+
+// send отправляет уведомление на pool-alert-url
+func (a poolAlerts) send(alert PoolAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// checkPoolWatermarks проверяет заполнение пулов всех подсетей, например
+// после перезагрузки конфигурации. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) checkPoolWatermarks() {
+	previous := s.poolLow
+	s.poolLow = make(map[string]bool)
+	if s.alerts.lowWater == 0 {
+		return
+	}
+	now := s.clock.Now()
+	for i := range s.config.Subnets {
+		s.updatePoolLow(i, previous[subnetID(&s.config.Subnets[i])], now)
+	}
+}
+
+// checkPoolWatermark проверяет заполнение пула подсети, которой
+// принадлежит адрес, после выдачи или освобождения адреса. Вызывается с
+// захваченным мьютексом.
+func (s *BOOTPServer) checkPoolWatermark(ip uint32) {
+	if s.alerts.lowWater == 0 {
+		return
+	}
+	for i := range s.config.Subnets {
+		if subnetContains(&s.config.Subnets[i], ip) {
+			s.updatePoolLow(i, s.poolLow[subnetID(&s.config.Subnets[i])], s.clock.Now())
+			return
+		}
+	}
+}
+
+// updatePoolLow запоминает, ниже ли порога пул подсети index, и при
+// пересечении порога записывает это в журнал и отправляет уведомление.
+// wasLow - состояние пула при предыдущей проверке.
+func (s *BOOTPServer) updatePoolLow(index int, wasLow bool, now time.Time) {
+	u := s.subnetUsage(index, now)
+	low := s.alerts.low(u)
+	if low {
+		s.poolLow[u.ID] = true
+	} else {
+		delete(s.poolLow, u.ID)
+	}
+	if low == wasLow {
+		return
+	}
+
+	event := PoolRecovered
+	if low {
+		event = PoolLow
+		s.counters.poolAlerts.Add(1)
+		s.logger.Warnf("Pool of subnet %s is nearly exhausted: %d of %d addresses free", u.ID, u.Free, u.Size)
+	} else {
+		s.logger.Infof("Pool of subnet %s recovered: %d of %d addresses free", u.ID, u.Free, u.Size)
+	}
+
+	if s.alerts.url != "" {
+		u.LowWater = low
+		alerts, alert := s.alerts, PoolAlert{Event: event, Time: now, Watermark: s.alerts.lowWater, Subnet: u}
+		go func() {
+			if err := alerts.send(alert); err != nil {
+				s.logger.Warnf("Failed to send pool alert for subnet %s: %v", alert.Subnet.ID, err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParsePoolAlerts(t *testing.T) {
+	alerts, err := parsePoolAlerts(map[string]string{})
+	if err != nil || alerts.lowWater != 0 || alerts.reserve != 0 || alerts.client != nil {
+		t.Errorf("Expected no pool alerts by default, got %+v (%v)", alerts, err)
+	}
+
+	for _, options := range []map[string]string{
+		{"pool-low-watermark": "100"},
+		{"pool-low-watermark": "-1"},
+		{"pool-reserve": "5%"},
+		{"pool-alert-url": "\"alerts.example.com\""},
+	} {
+		if _, err := parsePoolAlerts(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+		if err := ValidateConfig(&config.DHCPConfig{GlobalOptions: options}); err == nil {
+			t.Errorf("Expected validation error for %v", options)
+		}
+	}
+}
+
+// requestPacket DHCPREQUEST клиента без запрошенного адреса
+func requestPacket(mac byte) *Packet {
+	packet := discoverPacket(mac)
+	packet.Options[OptionMessageType] = []byte{DHCPRequest}
+	return packet
+}
+
+func TestPoolWatermark(t *testing.T) {
+	alerts := make(chan PoolAlert, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert PoolAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+		alerts <- alert
+	}))
+	defer hook.Close()
+
+	// 11 адресов, порог 50%: предупреждение при 5 свободных
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"pool-low-watermark": "50", "pool-alert-url": "\"" + hook.URL + "\""},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for mac := byte(1); mac <= 6; mac++ {
+		if reply := server.processPacket(requestPacket(mac)); reply == nil {
+			t.Fatalf("Expected client %d to get an address", mac)
+		}
+		if low := server.SubnetUtilization()[0].LowWater; low != (mac == 6) {
+			t.Fatalf("After %d leases expected low water %v, got %v", mac, mac == 6, low)
+		}
+	}
+	select {
+	case alert := <-alerts:
+		if alert.Event != PoolLow || alert.Watermark != 50 || alert.Subnet.Free != 5 || !alert.Subnet.LowWater {
+			t.Errorf("Unexpected alert %+v", alert)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected pool alert to be sent")
+	}
+	if got := server.Stats().Counters.PoolAlerts; got != 1 {
+		t.Errorf("Expected 1 pool alert counted, got %d", got)
+	}
+
+	// Освобождение адреса возвращает пул выше порога
+	if _, err := server.ReleaseLease("192.168.1.100"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case alert := <-alerts:
+		if alert.Event != PoolRecovered || alert.Subnet.Free != 6 {
+			t.Errorf("Unexpected alert %+v", alert)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected recovery alert to be sent")
+	}
+
+	// Без порога после перезагрузки пул не отмечается
+	cfg.GlobalOptions = map[string]string{}
+	server.processPacket(requestPacket(7))
+	if err := server.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if server.SubnetUtilization()[0].LowWater {
+		t.Error("Expected no low water mark without pool-low-watermark")
+	}
+}
+
+func TestPoolReserve(t *testing.T) {
+	// 11 адресов, запас 20%: последние 3 адреса только известным клиентам
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"pool-reserve": "20"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Hosts:   []config.Host{{Name: "known", Hardware: "02:00:00:00:00:ff"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for mac := byte(1); mac <= 8; mac++ {
+		if reply := server.processPacket(requestPacket(mac)); reply == nil {
+			t.Fatalf("Expected client %d to get an address", mac)
+		}
+	}
+	if reply := server.processPacket(discoverPacket(9)); reply != nil {
+		t.Error("Expected unknown client to be refused the reserve")
+	}
+	if reply := server.processPacket(discoverPacket(0xff)); reply == nil {
+		t.Error("Expected known client to get an address from the reserve")
+	}
+}
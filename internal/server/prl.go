@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseAlwaysSendOptions читает опцию always-send-options: коды опций
+// через запятую, которые отправляются клиенту, даже если он их не
+// запросил в опции 55 (например, для клиентов с неполным списком):
+// always-send-options "43, 66, 67";
+func parseAlwaysSendOptions(options map[string]string) ([]uint8, error) {
+	var codes []uint8
+	for _, value := range strings.Split(strings.Trim(options["always-send-options"], "\""), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		code, err := strconv.ParseUint(value, 10, 8)
+		if err != nil || code == OptionPad || code == OptionEnd {
+			return nil, fmt.Errorf("invalid always-send-options: %s (must be option codes 1-254)", value)
+		}
+		codes = append(codes, uint8(code))
+	}
+	return codes, nil
+}
+
+// filterRequested удаляет из опций ответа те, которых нет в списке
+// запрошенных параметров клиента (опция 55), и возвращает их коды по
+// возрастанию. Обязательные опции (requiredReplyOptions) и опции из always
+// сохраняются. Без списка клиент получает все опции.
+func filterRequested(options map[uint8][]byte, requested []byte, always []uint8) []uint8 {
+	if len(requested) == 0 {
+		return nil
+	}
+
+	var keep [256]bool
+	for _, code := range requiredReplyOptions {
+		keep[code] = true
+	}
+	for _, code := range always {
+		keep[code] = true
+	}
+	for _, code := range requested {
+		keep[code] = true
+	}
+
+	var removed []uint8
+	for _, code := range optionCodes(options) {
+		if !keep[code] {
+			delete(options, code)
+			removed = append(removed, code)
+		}
+	}
+	return removed
+}
+
+// alwaysSendOptions возвращает коды опций always-send-options
+func (s *BOOTPServer) alwaysSendOptions() []uint8 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.alwaysSend
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseAlwaysSendOptions(t *testing.T) {
+	codes, err := parseAlwaysSendOptions(map[string]string{"always-send-options": `"43, 66,67"`})
+	if err != nil || !reflect.DeepEqual(codes, []uint8{43, 66, 67}) {
+		t.Errorf("Expected [43 66 67], got %v (%v)", codes, err)
+	}
+	if codes, err := parseAlwaysSendOptions(map[string]string{}); err != nil || codes != nil {
+		t.Errorf("Expected no codes by default, got %v (%v)", codes, err)
+	}
+	for _, value := range []string{"0", "255", "256", "routers"} {
+		if _, err := parseAlwaysSendOptions(map[string]string{"always-send-options": value}); err == nil {
+			t.Errorf("Expected error for always-send-options %s", value)
+		}
+	}
+}
+
+func TestFilterRequested(t *testing.T) {
+	newOptions := func() map[uint8][]byte {
+		return map[uint8][]byte{
+			OptionMessageType:      {DHCPOffer},
+			OptionServerIdentifier: {192, 168, 1, 1},
+			OptionLeaseTime:        {0, 0, 0x0e, 0x10},
+			OptionSubnetMask:       {255, 255, 255, 0},
+			3:                      {192, 168, 1, 1}, // routers
+			OptionDomainName:       []byte("example.com"),
+			43:                     {1, 1, 0},
+		}
+	}
+
+	// Без опции 55 ответ не меняется
+	options := newOptions()
+	if removed := filterRequested(options, nil, nil); removed != nil || len(options) != 7 {
+		t.Errorf("Expected all options without request list, removed %v", removed)
+	}
+
+	options = newOptions()
+	removed := filterRequested(options, []byte{3, OptionSubnetMask}, []uint8{43})
+	if !reflect.DeepEqual(removed, []uint8{OptionDomainName}) {
+		t.Errorf("Expected domain name to be removed, got %v", removed)
+	}
+	for _, code := range []uint8{OptionMessageType, OptionServerIdentifier, OptionLeaseTime, OptionSubnetMask, 3, 43} {
+		if _, exists := options[code]; !exists {
+			t.Errorf("Expected option %d to be kept", code)
+		}
+	}
+}
+
+func TestEncodeReplyRequestOrder(t *testing.T) {
+	header := validTestHeader()
+	header.Op = BOOTPReply
+	options := map[uint8][]byte{
+		OptionMessageType: {DHCPAck},
+		OptionLeaseTime:   {0, 0, 0x0e, 0x10},
+		OptionSubnetMask:  {255, 255, 255, 0},
+		3:                 {192, 168, 1, 1},
+		OptionDomainName:  []byte("example.com"),
+	}
+
+	// Обязательные опции, затем запрошенные в порядке запроса, затем остальные
+	data, _ := encodeReply(nil, header, options, maxReplySize, []byte{OptionDomainName, 3})
+	var order []uint8
+	for i := bootpHeaderSize; data[i] != OptionEnd; i += 2 + int(data[i+1]) {
+		order = append(order, data[i])
+	}
+	if expected := []uint8{OptionMessageType, OptionLeaseTime, OptionDomainName, 3, OptionSubnetMask}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected options in order %v, got %v", expected, order)
+	}
+
+	buffer := make([]byte, 0, maxPacketSize)
+	requested := []byte{OptionDomainName, 3}
+	allocs := testing.AllocsPerRun(100, func() {
+		buffer, _ = encodeReply(buffer[:0], header, options, maxReplySize, requested)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestRequestedReplyOptions(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Options: map[string]string{"routers": "192.168.1.1", "domain-name": `"example.com"`},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clientAddr := conn.LocalAddr().(*net.UDPAddr)
+
+	discover := func(xid uint32) map[uint8][]byte {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Xid: xid, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}, OptionParameterList: {OptionSubnetMask, 3}},
+		}
+		server.handlePacket(conn, packet, DHCPDiscover, clientAddr, nil)
+
+		buffer := make([]byte, maxPacketSize)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			t.Fatalf("Expected reply: %v", err)
+		}
+		reply, err := DecodePacket(buffer[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply.Options
+	}
+
+	options := discover(1)
+	if _, exists := options[3]; !exists {
+		t.Error("Expected requested routers option")
+	}
+	if _, exists := options[OptionDomainName]; exists {
+		t.Error("Expected unrequested domain name to be omitted")
+	}
+	if messageType(options) != DHCPOffer || options[OptionLeaseTime] == nil {
+		t.Errorf("Expected mandatory options, got %v", options)
+	}
+
+	cfg.GlobalOptions = map[string]string{"always-send-options": "15"}
+	if err := server.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if options := discover(2); options[OptionDomainName] == nil {
+		t.Error("Expected domain name from always-send-options")
+	}
+}
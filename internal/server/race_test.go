@@ -0,0 +1,7 @@
+//go:build race
+
+package server
+
+// raceEnabled сообщает, что тесты собраны с детектором гонок: он
+// выделяет память сам, и проверки отсутствия выделений пропускаются
+const raceEnabled = true
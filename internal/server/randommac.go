@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// randomizedMACPolicy обработка клиентов со случайными MAC адресами.
+// Такие клиенты (Android, iOS, Windows) меняют адрес для каждой сети или
+// периодически, и каждый новый адрес занимает аренду до ее истечения.
+// Отдельный пул задается диапазонами range randomized-mac.
+type randomizedMACPolicy struct {
+	leaseTime       time.Duration // randomized-mac-lease-time (0 - как у остальных клиентов)
+	requireClientID bool          // randomized-mac-require-client-id: без постоянного client-id не обслуживаются
+}
+
+// parseRandomizedMACPolicy читает опции randomized-mac-lease-time
+// (секунды) и randomized-mac-require-client-id
+func parseRandomizedMACPolicy(options map[string]string) (randomizedMACPolicy, error) {
+	var policy randomizedMACPolicy
+	if value, ok := options["randomized-mac-lease-time"]; ok {
+		duration, err := config.ParseLeaseTime(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid randomized-mac-lease-time: %s", value)
+		}
+		policy.leaseTime = duration
+	}
+	if value, ok := options["randomized-mac-require-client-id"]; ok {
+		policy.requireClientID = value == "" || isEnabled(value)
+	}
+	return policy, nil
+}
+
+// isRandomizedMAC проверяет, что MAC адрес случайный: у адреса Ethernet
+// установлен бит локального администрирования и сброшен бит группы.
+// Производители получают адреса без этого бита, поэтому он почти всегда
+// означает адрес, созданный операционной системой клиента.
+func isRandomizedMAC(macAddr string) bool {
+	hardware, err := net.ParseMAC(macAddr)
+	if err != nil || len(hardware) != 6 {
+		return false
+	}
+	return hardware[0]&0x02 != 0 && hardware[0]&0x01 == 0
+}
+
+// stableClientID проверяет, что клиент передал идентификатор, не
+// повторяющий его аппаратный адрес (тип оборудования и MAC): например,
+// DUID по RFC 4361. Такой идентификатор не меняется вместе с MAC адресом.
+func stableClientID(header *BOOTPHeader, clientID []byte) bool {
+	if len(clientID) == 0 {
+		return false
+	}
+	hlen := int(header.Hlen)
+	if hlen > len(header.Chaddr) {
+		hlen = len(header.Chaddr)
+	}
+	hardware := append([]byte{header.Htype}, header.Chaddr[:hlen]...)
+	return !bytes.Equal(clientID, hardware)
+}
+
+// randomizedLease ограничивает срок аренды клиента со случайным MAC
+// адресом опцией randomized-mac-lease-time
+func (s *BOOTPServer) randomizedLease(macAddr string, lease time.Duration) time.Duration {
+	s.mutex.Lock()
+	limit := s.randomMAC.leaseTime
+	s.mutex.Unlock()
+
+	if limit == 0 || !isRandomizedMAC(macAddr) {
+		return lease
+	}
+	if lease == 0 || lease > limit {
+		return limit
+	}
+	return lease
+}
+
+// randomizedMACMiddleware оставляет без ответа клиентов со случайными MAC
+// адресами без постоянного идентификатора, если задана опция
+// randomized-mac-require-client-id
+func (s *BOOTPServer) randomizedMACMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		if req.MessageType != DHCPDiscover && req.MessageType != DHCPRequest && req.MessageType != 0 {
+			return next(ctx, req)
+		}
+		s.mutex.Lock()
+		require := s.randomMAC.requireClientID
+		s.mutex.Unlock()
+
+		header := &req.Packet.Header
+		macAddr := chaddrToMAC(header.Chaddr, header.Hlen)
+		if require && isRandomizedMAC(macAddr) && !stableClientID(header, req.Packet.Options[OptionClientIdentifier]) {
+			s.logger.Debugf("Ignoring request from randomized MAC %s without a stable client identifier", macAddr)
+			return nil, nil
+		}
+		return next(ctx, req)
+	}
+}
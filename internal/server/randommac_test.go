@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestIsRandomizedMAC(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want bool
+	}{
+		{"02:00:00:00:00:01", true},
+		{"da:a1:19:00:00:01", true},
+		{"00:11:22:33:44:55", false},
+		{"03:00:00:00:00:01", false}, // Групповой адрес
+		{"invalid", false},
+	}
+	for _, test := range tests {
+		if got := isRandomizedMAC(test.mac); got != test.want {
+			t.Errorf("isRandomizedMAC(%s) = %v, expected %v", test.mac, got, test.want)
+		}
+	}
+
+	header := &BOOTPHeader{Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}}
+	if stableClientID(header, nil) || stableClientID(header, []byte{1, 0x02, 0, 0, 0, 0, 1}) {
+		t.Error("Expected client-id repeating the MAC not to be stable")
+	}
+	if !stableClientID(header, []byte{0xff, 0, 0, 0, 1, 0, 4}) {
+		t.Error("Expected DUID client-id to be stable")
+	}
+}
+
+func TestRandomizedMACPolicy(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{
+			"randomized-mac-lease-time":        "300",
+			"randomized-mac-require-client-id": "",
+		},
+		Subnets: []config.Subnet{{
+			Network: config.MustParseNetwork("192.168.1.0/24"),
+			Ranges: []config.Range{
+				{Start: "192.168.1.100", End: "192.168.1.109"},
+				{Start: "192.168.1.200", End: "192.168.1.209", RandomizedMAC: true},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Случайный MAC без постоянного идентификатора не обслуживается
+	random := discoverPacket(1)
+	if reply := server.processPacket(random); reply != nil {
+		t.Fatalf("Expected no reply without client-id, got %v", net.IP(reply.Header.Yiaddr[:]))
+	}
+
+	// С DUID клиент получает адрес из отдельного пула на короткий срок
+	random.Options[OptionClientIdentifier] = []byte{0xff, 0, 0, 0, 1, 0, 4, 1}
+	reply := server.processPacket(random)
+	if reply == nil {
+		t.Fatal("Expected reply with stable client-id")
+	}
+	if yiaddr := net.IP(reply.Header.Yiaddr[:]).String(); yiaddr != "192.168.1.200" {
+		t.Errorf("Expected address from randomized-mac range, got %s", yiaddr)
+	}
+	if lease := binary.BigEndian.Uint32(reply.Options[OptionLeaseTime]); lease != 300 {
+		t.Errorf("Expected lease time 300, got %d", lease)
+	}
+
+	// Клиент с адресом производителя получает обычный адрес и срок
+	vendor := discoverPacket(2)
+	vendor.Header.Chaddr[0] = 0x00
+	reply = server.processPacket(vendor)
+	if reply == nil {
+		t.Fatal("Expected reply for vendor MAC")
+	}
+	if yiaddr := net.IP(reply.Header.Yiaddr[:]).String(); yiaddr != "192.168.1.100" {
+		t.Errorf("Expected address from regular range, got %s", yiaddr)
+	}
+	if lease := binary.BigEndian.Uint32(reply.Options[OptionLeaseTime]); lease == 300 {
+		t.Error("Expected regular lease time for vendor MAC")
+	}
+
+	for _, lease := range server.Leases() {
+		if lease.RandomizedMAC != (lease.IP == "192.168.1.200") {
+			t.Errorf("Unexpected randomized flag for %+v", lease)
+		}
+	}
+
+	if err := ValidateConfig(&config.DHCPConfig{GlobalOptions: map[string]string{"randomized-mac-lease-time": "0"}}); err == nil {
+		t.Error("Expected error for zero randomized-mac-lease-time")
+	}
+}
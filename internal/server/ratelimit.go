@@ -0,0 +1,250 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Параметры ограничения частоты запросов по умолчанию
+const (
+	defaultRateLimitMaxDelay = time.Second
+	rateLimitCleanupInterval = time.Minute
+	rateLimitMaxClients      = 65536 // Максимальное количество отслеживаемых клиентов
+)
+
+// tokenBucket реализует алгоритм "ведро с токенами"
+type tokenBucket struct {
+	rate   float64   // Токенов в секунду
+	burst  float64   // Емкость ведра
+	tokens float64   // Текущее количество токенов
+	last   time.Time // Время последнего пополнения
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// refill пополняет ведро за прошедшее время
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// wait возвращает время ожидания до появления токена
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// RateLimitStats счетчики ограничителя частоты запросов
+type RateLimitStats struct {
+	DroppedGlobal uint64 `json:"dropped_global"` // Отброшено по глобальному лимиту
+	DroppedClient uint64 `json:"dropped_client"` // Отброшено по лимиту клиента
+	Delayed       uint64 `json:"delayed"`        // Отложено до появления токена
+}
+
+// RateLimiter ограничивает частоту запросов от отдельных клиентов и в целом
+type RateLimiter struct {
+	mutex       sync.Mutex
+	global      *tokenBucket            // Глобальное ведро (может быть nil)
+	clients     map[string]*tokenBucket // Ведра клиентов по MAC адресу
+	clientRate  float64                 // Запросов в секунду на клиента (0 - без ограничения)
+	clientBurst float64
+	delay       bool          // Откладывать запросы вместо отбрасывания
+	maxDelay    time.Duration // Максимальная задержка, после которой запрос отбрасывается
+	lastCleanup time.Time
+	stats       RateLimitStats
+}
+
+// NewRateLimiter создает ограничитель по глобальным опциям конфигурации.
+// Возвращает nil, если ни один лимит не задан.
+func NewRateLimiter(options map[string]string) (*RateLimiter, error) {
+	clientRate, clientBurst, err := parseRateOptions(options, "rate-limit-per-client")
+	if err != nil {
+		return nil, err
+	}
+	globalRate, globalBurst, err := parseRateOptions(options, "rate-limit-global")
+	if err != nil {
+		return nil, err
+	}
+	if clientRate == 0 && globalRate == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	limiter := &RateLimiter{
+		clients:     make(map[string]*tokenBucket),
+		clientRate:  clientRate,
+		clientBurst: clientBurst,
+		maxDelay:    defaultRateLimitMaxDelay,
+		lastCleanup: now,
+	}
+	if globalRate > 0 {
+		limiter.global = newTokenBucket(globalRate, globalBurst, now)
+	}
+
+	if value, ok := options["rate-limit-action"]; ok {
+		switch value {
+		case "drop":
+			limiter.delay = false
+		case "delay":
+			limiter.delay = true
+		default:
+			return nil, fmt.Errorf("invalid rate-limit-action: %s", value)
+		}
+	}
+
+	// Максимальная задержка задается в миллисекундах
+	if value, ok := options["rate-limit-max-delay"]; ok {
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid rate-limit-max-delay: %s", value)
+		}
+		limiter.maxDelay = time.Duration(ms) * time.Millisecond
+	}
+
+	return limiter, nil
+}
+
+// parseRateOptions читает пару опций <prefix> (запросов в секунду)
+// и <prefix>-burst (емкость). По умолчанию емкость равна удвоенной частоте,
+// но не меньше одного запроса, иначе при частоте ниже 0.5 ведро никогда
+// не накопит токен.
+func parseRateOptions(options map[string]string, prefix string) (float64, float64, error) {
+	value, ok := options[prefix]
+	if !ok {
+		return 0, 0, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		return 0, 0, fmt.Errorf("invalid %s: %s", prefix, value)
+	}
+
+	burst := math.Max(1, rate*2)
+	if value, ok := options[prefix+"-burst"]; ok {
+		burst, err = strconv.ParseFloat(value, 64)
+		if err != nil || burst < 1 {
+			return 0, 0, fmt.Errorf("invalid %s-burst: %s", prefix, value)
+		}
+	}
+
+	return rate, burst, nil
+}
+
+// Allow проверяет, можно ли обработать запрос клиента. Если запрос нужно
+// отложить, возвращается true и время задержки; false означает отбрасывание.
+func (l *RateLimiter) Allow(macAddr string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	// Сначала проверяем глобальное ведро, чтобы поток запросов с разных
+	// MAC адресов, отбрасываемый глобальным лимитом, не создавал ведра клиентов
+	var wait time.Duration
+	if l.global != nil {
+		l.global.refill(now)
+		wait = l.global.wait()
+		if wait > 0 && (!l.delay || wait > l.maxDelay) {
+			l.stats.DroppedGlobal++
+			return false, 0
+		}
+	}
+
+	var client *tokenBucket
+	if l.clientRate > 0 {
+		client = l.clients[macAddr]
+		if client == nil {
+			l.reserveClient(now)
+			client = newTokenBucket(l.clientRate, l.clientBurst, now)
+			l.clients[macAddr] = client
+		}
+		client.refill(now)
+	}
+
+	// Определяем необходимую задержку по обоим ведрам
+	limitedByClient := false
+	if client != nil {
+		if w := client.wait(); w > wait {
+			wait = w
+			limitedByClient = true
+		}
+	}
+
+	if wait > 0 && (!l.delay || wait > l.maxDelay) {
+		if limitedByClient {
+			l.stats.DroppedClient++
+		} else {
+			l.stats.DroppedGlobal++
+		}
+		return false, 0
+	}
+
+	// Забираем токены; при задержке баланс уходит в минус и
+	// восстанавливается к моменту обработки запроса
+	if client != nil {
+		client.tokens--
+	}
+	if l.global != nil {
+		l.global.tokens--
+	}
+	if wait > 0 {
+		l.stats.Delayed++
+	}
+
+	return true, wait
+}
+
+// cleanup удаляет ведра клиентов, которые давно заполнены полностью
+func (l *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < rateLimitCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+
+	for mac, bucket := range l.clients {
+		bucket.refill(now)
+		if bucket.tokens >= bucket.burst {
+			delete(l.clients, mac)
+		}
+	}
+}
+
+// reserveClient освобождает место для ведра нового клиента, если
+// достигнут предел rateLimitMaxClients: сначала удаляются заполненные
+// ведра, затем, если их нет, произвольное ведро
+func (l *RateLimiter) reserveClient(now time.Time) {
+	if len(l.clients) < rateLimitMaxClients {
+		return
+	}
+
+	l.lastCleanup = time.Time{}
+	l.cleanup(now)
+
+	for mac := range l.clients {
+		if len(l.clients) < rateLimitMaxClients {
+			break
+		}
+		delete(l.clients, mac)
+	}
+}
+
+// Stats возвращает копию счетчиков
+func (l *RateLimiter) Stats() RateLimitStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.stats
+}
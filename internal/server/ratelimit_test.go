@@ -0,0 +1,209 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(2, 2, now)
+
+	// Ведро изначально заполнено
+	if wait := bucket.wait(); wait != 0 {
+		t.Errorf("Expected no wait for full bucket, got %v", wait)
+	}
+
+	bucket.tokens = 0
+	if wait := bucket.wait(); wait != 500*time.Millisecond {
+		t.Errorf("Expected 500ms wait, got %v", wait)
+	}
+
+	// Через секунду ведро пополняется, но не выше емкости
+	bucket.refill(now.Add(5 * time.Second))
+	if bucket.tokens != 2 {
+		t.Errorf("Expected 2 tokens after refill, got %v", bucket.tokens)
+	}
+}
+
+func TestRateLimiterPerClientDrop(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]string{
+		"rate-limit-per-client":       "1",
+		"rate-limit-per-client-burst": "3",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	// Первые три запроса укладываются в емкость ведра
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("00:00:00:00:00:01"); !ok {
+			t.Errorf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	if ok, _ := limiter.Allow("00:00:00:00:00:01"); ok {
+		t.Error("Expected fourth request to be dropped")
+	}
+
+	// Другой клиент не затронут
+	if ok, _ := limiter.Allow("00:00:00:00:00:02"); !ok {
+		t.Error("Expected request from another client to be allowed")
+	}
+
+	stats := limiter.Stats()
+	if stats.DroppedClient != 1 || stats.DroppedGlobal != 0 {
+		t.Errorf("Expected 1 client drop and 0 global drops, got %+v", stats)
+	}
+}
+
+func TestRateLimiterGlobalDrop(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]string{
+		"rate-limit-global":       "1",
+		"rate-limit-global-burst": "2",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	limiter.Allow("00:00:00:00:00:01")
+	limiter.Allow("00:00:00:00:00:02")
+	if ok, _ := limiter.Allow("00:00:00:00:00:03"); ok {
+		t.Error("Expected request over global limit to be dropped")
+	}
+
+	if stats := limiter.Stats(); stats.DroppedGlobal != 1 {
+		t.Errorf("Expected 1 global drop, got %+v", stats)
+	}
+}
+
+func TestRateLimiterGlobalDropSkipsClientBuckets(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]string{
+		"rate-limit-per-client":   "1",
+		"rate-limit-global":       "1",
+		"rate-limit-global-burst": "1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	// Запросы, отброшенные глобальным лимитом, не создают ведра клиентов
+	for i := 0; i < 100; i++ {
+		limiter.Allow(fmt.Sprintf("00:00:00:00:%02x:%02x", i>>8, i&0xff))
+	}
+	if len(limiter.clients) != 1 {
+		t.Errorf("Expected 1 client bucket, got %d", len(limiter.clients))
+	}
+}
+
+func TestRateLimiterMaxClients(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]string{"rate-limit-per-client": "1"})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	for i := 0; i < rateLimitMaxClients+10; i++ {
+		limiter.Allow(fmt.Sprintf("00:00:00:%02x:%02x:%02x", i>>16, (i>>8)&0xff, i&0xff))
+	}
+	if len(limiter.clients) > rateLimitMaxClients {
+		t.Errorf("Expected at most %d client buckets, got %d", rateLimitMaxClients, len(limiter.clients))
+	}
+}
+
+func TestRateLimiterDelay(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]string{
+		"rate-limit-per-client":       "10",
+		"rate-limit-per-client-burst": "1",
+		"rate-limit-action":           "delay",
+		"rate-limit-max-delay":        "150",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	if ok, wait := limiter.Allow("00:00:00:00:00:01"); !ok || wait != 0 {
+		t.Errorf("Expected first request without delay, got %v, %v", ok, wait)
+	}
+
+	// Второй запрос откладывается примерно на 100 мс
+	ok, wait := limiter.Allow("00:00:00:00:00:01")
+	if !ok || wait <= 0 || wait > 150*time.Millisecond {
+		t.Errorf("Expected delayed request, got %v, %v", ok, wait)
+	}
+
+	// Третий запрос требует задержки больше максимальной и отбрасывается
+	if ok, _ := limiter.Allow("00:00:00:00:00:01"); ok {
+		t.Error("Expected request over max delay to be dropped")
+	}
+
+	stats := limiter.Stats()
+	if stats.Delayed != 1 || stats.DroppedClient != 1 {
+		t.Errorf("Expected 1 delayed and 1 dropped request, got %+v", stats)
+	}
+}
+
+func TestNewRateLimiterOptions(t *testing.T) {
+	// Без опций ограничитель не создается
+	limiter, err := NewRateLimiter(map[string]string{})
+	if err != nil || limiter != nil {
+		t.Errorf("Expected nil limiter without options, got %v, %v", limiter, err)
+	}
+
+	invalid := []map[string]string{
+		{"rate-limit-per-client": "abc"},
+		{"rate-limit-global": "0"},
+		{"rate-limit-global": "10", "rate-limit-global-burst": "0"},
+		{"rate-limit-global": "10", "rate-limit-action": "queue"},
+		{"rate-limit-global": "10", "rate-limit-max-delay": "-5"},
+	}
+	for _, options := range invalid {
+		if _, err := NewRateLimiter(options); err == nil {
+			t.Errorf("Expected error for options %v", options)
+		}
+	}
+
+	// Ошибка в опциях не дает создать сервер
+	cfg := &config.DHCPConfig{GlobalOptions: map[string]string{"rate-limit-global": "fast"}}
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected NewBOOTPServer to fail with invalid rate limit")
+	}
+}
+
+func TestRateLimiterLowRateBurst(t *testing.T) {
+	// Частота ниже одного запроса в две секунды: емкость по умолчанию 1
+	limiter, err := NewRateLimiter(map[string]string{"rate-limit-per-client": "0.2"})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+	if limiter.clientBurst != 1 {
+		t.Errorf("Expected default burst 1, got %v", limiter.clientBurst)
+	}
+
+	if ok, _ := limiter.Allow("00:00:00:00:00:01"); !ok {
+		t.Error("Expected first request to be allowed")
+	}
+	if ok, _ := limiter.Allow("00:00:00:00:00:01"); ok {
+		t.Error("Expected second request to be dropped")
+	}
+}
+
+func TestRateLimiterCleanup(t *testing.T) {
+	limiter, err := NewRateLimiter(map[string]string{"rate-limit-per-client": "5"})
+	if err != nil {
+		t.Fatalf("Failed to create rate limiter: %v", err)
+	}
+
+	limiter.Allow("00:00:00:00:00:01")
+	if len(limiter.clients) != 1 {
+		t.Fatalf("Expected 1 client bucket, got %d", len(limiter.clients))
+	}
+
+	// Ведро давно неактивного клиента удаляется
+	limiter.cleanup(time.Now().Add(2 * rateLimitCleanupInterval))
+	if len(limiter.clients) != 0 {
+		t.Errorf("Expected idle client bucket to be removed, got %d", len(limiter.clients))
+	}
+}
@@ -0,0 +1,123 @@
+//go:build linux
+
+package server
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// mmsghdr структура mmsghdr вызова recvmmsg: заголовок сообщения и
+// количество принятых байт
+type mmsghdr struct {
+	hdr syscall.Msghdr
+	len uint32
+}
+
+// batchReceiver читает несколько запросов одним вызовом recvmmsg и отдает
+// их по одному. Когда сервер не успевает, в очереди сокета копятся запросы,
+// и пачка заменяет десятки системных вызовов.
+type batchReceiver struct {
+	raw     syscall.RawConn
+	control func(oob []byte) net.IP // Разбор вспомогательных сообщений
+
+	messages []mmsghdr
+	iovecs   []syscall.Iovec
+	buffers  [][]byte
+	oobs     [][]byte
+	names    []syscall.RawSockaddrAny
+
+	count int // Принято сообщений последним вызовом
+	next  int // Следующее сообщение для чтения
+}
+
+func newBatchReceiver(raw syscall.RawConn, batch, oobSize int, control func(oob []byte) net.IP) receiver {
+	r := &batchReceiver{
+		raw:      raw,
+		control:  control,
+		messages: make([]mmsghdr, batch),
+		iovecs:   make([]syscall.Iovec, batch),
+		buffers:  make([][]byte, batch),
+		oobs:     make([][]byte, batch),
+		names:    make([]syscall.RawSockaddrAny, batch),
+	}
+	for i := range r.messages {
+		// Буфер больше максимального размера пакета, чтобы обнаруживать
+		// слишком большие пакеты
+		r.buffers[i] = make([]byte, maxPacketSize+1)
+		r.oobs[i] = make([]byte, oobSize)
+		r.iovecs[i].Base = &r.buffers[i][0]
+		r.iovecs[i].SetLen(len(r.buffers[i]))
+
+		hdr := &r.messages[i].hdr
+		hdr.Name = (*byte)(unsafe.Pointer(&r.names[i]))
+		hdr.Iov = &r.iovecs[i]
+		hdr.Iovlen = 1
+		hdr.Control = &r.oobs[i][0]
+	}
+	return r.receive
+}
+
+// receive копирует следующий принятый запрос в buffer, при необходимости
+// читая новую пачку
+func (r *batchReceiver) receive(buffer []byte) (int, *net.UDPAddr, net.IP, error) {
+	if r.next >= r.count {
+		if err := r.read(); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	m := &r.messages[r.next]
+	i := r.next
+	r.next++
+
+	n := copy(buffer, r.buffers[i][:m.len])
+	return n, sockaddrToUDP(&r.names[i]), r.control(r.oobs[i][:m.hdr.Controllen]), nil
+}
+
+// read принимает пачку запросов, ожидая хотя бы один
+func (r *batchReceiver) read() error {
+	for i := range r.messages {
+		r.messages[i].hdr.Namelen = syscall.SizeofSockaddrAny
+		r.messages[i].hdr.SetControllen(len(r.oobs[i]))
+		r.messages[i].hdr.Flags = 0
+	}
+
+	var errno syscall.Errno
+	err := r.raw.Read(func(fd uintptr) bool {
+		n, _, e := syscall.Syscall6(syscall.SYS_RECVMMSG, fd, uintptr(unsafe.Pointer(&r.messages[0])),
+			uintptr(len(r.messages)), 0, 0, 0)
+		if e == syscall.EAGAIN || e == syscall.EINTR {
+			return false // Ждем следующих запросов
+		}
+		errno = e
+		r.count, r.next = int(n), 0
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		r.count = 0
+		return &net.OpError{Op: "recvmmsg", Net: "udp", Err: errno}
+	}
+	return nil
+}
+
+// sockaddrToUDP преобразует адрес отправителя. Адреса IPv4 на сокете
+// IPv6 приходят в виде ::ffff:a.b.c.d.
+func sockaddrToUDP(sa *syscall.RawSockaddrAny) *net.UDPAddr {
+	switch sa.Addr.Family {
+	case syscall.AF_INET:
+		sa4 := (*syscall.RawSockaddrInet4)(unsafe.Pointer(sa))
+		port := (*[2]byte)(unsafe.Pointer(&sa4.Port))
+		return &net.UDPAddr{IP: net.IPv4(sa4.Addr[0], sa4.Addr[1], sa4.Addr[2], sa4.Addr[3]), Port: int(port[0])<<8 | int(port[1])}
+	case syscall.AF_INET6:
+		sa6 := (*syscall.RawSockaddrInet6)(unsafe.Pointer(sa))
+		port := (*[2]byte)(unsafe.Pointer(&sa6.Port))
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, sa6.Addr[:])
+		return &net.UDPAddr{IP: ip, Port: int(port[0])<<8 | int(port[1])}
+	}
+	return &net.UDPAddr{}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// relayPolicy список доверенных релеев (allowed-relays) и действие для
+// запросов от остальных (unknown-relay-action)
+type relayPolicy struct {
+	allowed []*net.IPNet
+	logOnly bool // Только записывать в журнал, но обрабатывать запрос
+}
+
+// parseRelayPolicy читает опции allowed-relays (адреса и сети через
+// запятую) и unknown-relay-action (drop или log, по умолчанию drop):
+//
+//	allowed-relays "10.0.0.1, 10.1.0.0/16";
+//	unknown-relay-action log;
+//
+// Без allowed-relays giaddr не проверяется и возвращается nil.
+func parseRelayPolicy(options map[string]string) (*relayPolicy, error) {
+	policy := &relayPolicy{}
+	for _, value := range strings.Split(strings.Trim(options["allowed-relays"], "\""), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			value += "/32"
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil || network.IP.To4() == nil {
+			return nil, fmt.Errorf("invalid allowed-relays: %s (must be IPv4 addresses or networks)", strings.TrimSuffix(value, "/32"))
+		}
+		policy.allowed = append(policy.allowed, network)
+	}
+
+	if value, ok := options["unknown-relay-action"]; ok {
+		switch value {
+		case "drop":
+			policy.logOnly = false
+		case "log":
+			policy.logOnly = true
+		default:
+			return nil, fmt.Errorf("invalid unknown-relay-action: %s", value)
+		}
+	}
+
+	if len(policy.allowed) == 0 {
+		return nil, nil
+	}
+	return policy, nil
+}
+
+// allows возвращает true, если адрес входит в список доверенных релеев
+func (p *relayPolicy) allows(ip net.IP) bool {
+	for _, network := range p.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// relayMiddleware проверяет запросы, пришедшие через релей (giaddr не
+// 0.0.0.0): и giaddr, и адрес отправителя должны входить в allowed-relays.
+// Иначе запрос с подложным giaddr мог бы исчерпать пул чужой подсети.
+// Запросы без giaddr пропускаются.
+func (s *BOOTPServer) relayMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Packet, error) {
+		giaddr := net.IP(req.Packet.Header.Giaddr[:])
+		if giaddr.Equal(net.IPv4zero) {
+			return next(ctx, req)
+		}
+		s.mutex.Lock()
+		policy := s.relays
+		s.mutex.Unlock()
+		if policy == nil {
+			return next(ctx, req)
+		}
+
+		source := giaddr
+		if req.ClientAddr != nil && req.ClientAddr.IP.To4() != nil {
+			source = req.ClientAddr.IP
+		}
+		if policy.allows(giaddr) && policy.allows(source) {
+			return next(ctx, req)
+		}
+
+		s.counters.unknownRelays.Add(1)
+		macAddr := chaddrToMAC(req.Packet.Header.Chaddr, req.Packet.Header.Hlen)
+		if policy.logOnly {
+			s.logger.Warnf("Request from %s relayed by unknown gateway %s (source %s)", macAddr, giaddr, source)
+			return next(ctx, req)
+		}
+		s.logger.Debugf("Dropping request from %s relayed by unknown gateway %s (source %s)", macAddr, giaddr, source)
+		return nil, nil
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseRelayPolicy(t *testing.T) {
+	if policy, err := parseRelayPolicy(map[string]string{}); err != nil || policy != nil {
+		t.Errorf("Expected no relay check by default, got %+v (%v)", policy, err)
+	}
+
+	policy, err := parseRelayPolicy(map[string]string{"allowed-relays": "\"10.0.0.1, 10.1.0.0/16\"", "unknown-relay-action": "log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.logOnly || len(policy.allowed) != 2 {
+		t.Errorf("Expected two networks in log mode, got %+v", policy)
+	}
+	for ip, want := range map[string]bool{"10.0.0.1": true, "10.0.0.2": false, "10.1.200.1": true} {
+		if got := policy.allows(net.ParseIP(ip)); got != want {
+			t.Errorf("allows(%s) = %v, want %v", ip, got, want)
+		}
+	}
+
+	for _, options := range []map[string]string{
+		{"allowed-relays": "\"10.0.0.256\""},
+		{"allowed-relays": "\"10.0.0.0/33\""},
+		{"allowed-relays": "\"fe80::1\""},
+		{"allowed-relays": "10.0.0.1", "unknown-relay-action": "reject"},
+	} {
+		if _, err := parseRelayPolicy(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+		if err := ValidateConfig(&config.DHCPConfig{GlobalOptions: options}); err == nil {
+			t.Errorf("Expected validation error for %v", options)
+		}
+	}
+}
+
+func TestRelayMiddleware(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"allowed-relays": "\"10.0.0.1\""},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relayed := func(mac byte, giaddr, source net.IP) *Packet {
+		packet := discoverPacket(mac)
+		copy(packet.Header.Giaddr[:], giaddr.To4())
+		reply, err := server.serve(context.Background(), &Request{
+			Packet:      packet,
+			MessageType: DHCPDiscover,
+			ClientAddr:  &net.UDPAddr{IP: source, Port: BOOTP_PORT},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	// Клиенты без релея обслуживаются как обычно
+	if reply := server.processPacket(discoverPacket(1)); reply == nil {
+		t.Error("Expected direct request to be answered")
+	}
+
+	// Подложный giaddr или подложный отправитель отбрасываются
+	if reply := relayed(2, net.IPv4(192, 168, 1, 1), net.IPv4(192, 168, 1, 1)); reply != nil {
+		t.Error("Expected request from unknown relay to be dropped")
+	}
+	if reply := relayed(3, net.IPv4(10, 0, 0, 1), net.IPv4(192, 168, 1, 50)); reply != nil {
+		t.Error("Expected request with allowed giaddr from unknown source to be dropped")
+	}
+	if got := server.Stats().Counters.UnknownRelays; got != 2 {
+		t.Errorf("Expected 2 unknown relay requests counted, got %d", got)
+	}
+
+	// В режиме log запрос обрабатывается
+	cfg.GlobalOptions["unknown-relay-action"] = "log"
+	if err := server.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if reply := relayed(2, net.IPv4(192, 168, 1, 1), net.IPv4(192, 168, 1, 1)); reply == nil {
+		t.Error("Expected request from unknown relay to be answered in log mode")
+	}
+	if got := server.Stats().Counters.UnknownRelays; got != 3 {
+		t.Errorf("Expected 3 unknown relay requests counted, got %d", got)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import "net"
+
+// processRelease освобождает аренду клиента по DHCPRELEASE. Освобождаемый
+// адрес клиент передает в ciaddr; адрес, назначенный другому клиенту, не
+// освобождается.
+func (s *BOOTPServer) processRelease(packet *Packet) {
+	macAddr := normalizeMAC(chaddrToMAC(packet.Header.Chaddr, packet.Header.Hlen))
+	clientID := clientIDString(packet.Options[OptionClientIdentifier])
+	ip := net.IP(packet.Header.Ciaddr[:])
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	allocated := s.allocatedIP[ipToInt(ip)]
+	if allocated == nil || !allocated.Active || s.lookupAllocation(macAddr, clientID) != allocated {
+		s.logger.Debugf("Ignoring release of %s by %s: no such lease", ip, macAddr)
+		return
+	}
+
+	s.releaseAllocation(allocated)
+	s.logger.Infof("Client %s released %s lease %s", macAddr, allocated.Type, ip)
+}
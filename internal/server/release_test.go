@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDHCPRelease(t *testing.T) {
+	server := newReuseTestServer(t, nil) // Диапазон 192.168.1.100-110
+
+	request := func(mac byte, msgType uint8, ciaddr net.IP) *Packet {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, mac}},
+			Options: map[uint8][]byte{OptionMessageType: {msgType}},
+		}
+		copy(packet.Header.Ciaddr[:], ciaddr.To4())
+		return server.processPacket(packet)
+	}
+
+	offer := request(1, DHCPDiscover, nil)
+	if offer == nil {
+		t.Fatal("Expected offer")
+	}
+	ip := net.IP(offer.Header.Yiaddr[:]).To4()
+
+	// Чужой адрес клиент освободить не может
+	if reply := request(2, DHCPRelease, ip); reply != nil {
+		t.Errorf("Expected no reply to DHCPRELEASE, got %+v", reply)
+	}
+	if _, exists := server.allocatedMAC["aa:bb:cc:dd:ee:01"]; !exists {
+		t.Fatal("Lease released by another client")
+	}
+
+	if reply := request(1, DHCPRelease, ip); reply != nil {
+		t.Errorf("Expected no reply to DHCPRELEASE, got %+v", reply)
+	}
+	if _, exists := server.allocatedMAC["aa:bb:cc:dd:ee:01"]; exists {
+		t.Error("Expected lease to be released")
+	}
+	if server.Stats().Counters.Releases != 1 {
+		t.Errorf("Expected 1 release, got %+v", server.Stats().Counters)
+	}
+
+	// Освобожденный адрес сразу доступен другим клиентам
+	if offer := request(3, DHCPDiscover, nil); offer == nil || !net.IP(offer.Header.Yiaddr[:]).Equal(ip) {
+		t.Errorf("Expected released address %s for new client, got %+v", ip, offer)
+	}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// defaultLeaseDuration срок динамической аренды по умолчанию
+const defaultLeaseDuration = time.Hour
+
+// leaseExpiry возвращает время истечения аренды, выданной или продленной
+// в момент now. Нулевое время означает бессрочную аренду BOOTP клиента.
+// Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) leaseExpiry(offer *leaseOffer, now time.Time) time.Time {
+	if !offer.bootp {
+		if offer.lease != 0 {
+			return now.Add(offer.lease)
+		}
+		return now.Add(s.defaultLease())
+	}
+	if s.bootpLease == 0 {
+		return time.Time{}
+	}
+	return now.Add(s.bootpLease)
+}
+
+// defaultLease возвращает глобальный срок аренды DHCP клиентов:
+// default-lease-time конфигурации или срок WithLeaseDuration. Вызывается с
+// захваченным мьютексом.
+func (s *BOOTPServer) defaultLease() time.Duration {
+	if s.runtime.DefaultLeaseTime != 0 {
+		return s.runtime.DefaultLeaseTime
+	}
+	return s.leaseTime
+}
+
+// leaseDuration определяет срок аренды DHCP клиента. default-lease-time и
+// max-lease-time наследуются по цепочке глобальные → подсеть → классы →
+// хост. Срок, запрошенный клиентом в опции 51, выдается, только если
+// задан max-lease-time, и ограничивается им.
+func (s *BOOTPServer) leaseDuration(options map[string]string, requested []byte) time.Duration {
+	s.mutex.Lock()
+	lease, limit := s.defaultLease(), s.runtime.MaxLeaseTime
+	s.mutex.Unlock()
+
+	// Значения проверены при компиляции конфигурации
+	if value, ok := options[config.DefaultLeaseTime]; ok {
+		if duration, err := config.ParseLeaseTime(value); err == nil {
+			lease = duration
+		}
+	}
+	if value, ok := options[config.MaxLeaseTime]; ok {
+		if duration, err := config.ParseLeaseTime(value); err == nil {
+			limit = duration
+		}
+	}
+
+	if limit == 0 {
+		return lease
+	}
+	if len(requested) == 4 && binary.BigEndian.Uint32(requested) != 0 {
+		lease = time.Duration(binary.BigEndian.Uint32(requested)) * time.Second
+	}
+	if lease > limit {
+		lease = limit
+	}
+	return lease
+}
+
+// requestVerdict решение по DHCPREQUEST с конкретным адресом
+type requestVerdict int
+
+const (
+	requestAccept requestVerdict = iota // Адрес совпадает с назначением клиента
+	requestNak                          // Ответить DHCPNAK
+	requestIgnore                       // Не отвечать
+)
+
+// requestedAddress возвращает адрес, который запрашивает клиент: ciaddr
+// при продлении (RENEWING, REBINDING) или опцию 50 (SELECTING,
+// INIT-REBOOT). Возвращает nil, если адрес не указан.
+func requestedAddress(packet *Packet) net.IP {
+	if ciaddr := net.IP(packet.Header.Ciaddr[:]); !ciaddr.IsUnspecified() {
+		return ciaddr
+	}
+	if value := packet.Options[OptionRequestedIP]; len(value) == 4 {
+		return net.IP(value)
+	}
+	return nil
+}
+
+// heldAddress возвращает адрес, который клиент подтверждает в DHCPREQUEST
+// без опции 54: продление (RENEWING, REBINDING) или перезагрузка без
+// освобождения аренды (INIT-REBOOT). Запрос в состоянии SELECTING
+// завершает новую выдачу, для него возвращается nil.
+func heldAddress(packet *Packet) net.IP {
+	if _, selecting := packet.Options[OptionServerIdentifier]; selecting {
+		return nil
+	}
+	return requestedAddress(packet)
+}
+
+// verifyRequestedAddress сверяет запрошенный адрес с назначением клиента.
+// Клиент, выбравший в состоянии SELECTING другой сервер (опция 54 задана,
+// адрес не наш), отказа не получает.
+func (s *BOOTPServer) verifyRequestedAddress(macAddr, clientID string, requested net.IP, selecting bool) requestVerdict {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ip := ipToInt(requested)
+	macAddr = normalizeMAC(macAddr)
+	if lease := s.lookupAllocation(macAddr, clientID); lease != nil && lease.IP == ip {
+		return requestAccept
+	}
+	// Адрес, отозванный при сверке с новой конфигурацией, отклоняется и
+	// неавторитетным сервером
+	if !selecting && (s.revokedRequest(clientKey(macAddr, clientID), ip) || s.authoritative(ip)) {
+		return requestNak
+	}
+	return requestIgnore
+}
+
+// authoritative проверяет, является ли сервер авторитетным для сети адреса.
+// Оператор подсети переопределяет глобальный, по умолчанию сервер не
+// авторитетен. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) authoritative(ip uint32) bool {
+	for i := range s.config.Subnets {
+		subnet := &s.config.Subnets[i]
+		if subnet.Authoritative != nil && subnetContains(subnet, ip) {
+			return *subnet.Authoritative
+		}
+	}
+	return s.config.Authoritative != nil && *s.config.Authoritative
+}
+
+// Доли срока аренды для T1 и T2 по умолчанию (RFC 2131, 4.4.5)
+const (
+	defaultRenewalPercent   = 50
+	defaultRebindingPercent = 87.5
+)
+
+// leaseTimeOptions формирует опции срока аренды (51) и времени продления
+// T1 (58) и T2 (59). T1 и T2 задаются опциями dhcp-renewal-time и
+// dhcp-rebinding-time клиента (с наследованием глобальные → подсеть →
+// классы → хост) в секундах или в процентах срока аренды ("50%"). По
+// умолчанию - 50% и 87.5% срока. Если итоговые таймеры нарушают порядок
+// T1 <= T2 <= срок аренды, используются значения по умолчанию.
+func leaseTimeOptions(duration time.Duration, options map[string]string) map[uint8][]byte {
+	seconds := uint32(duration / time.Second)
+	renewal, err := leaseTimer(options, "dhcp-renewal-time", defaultRenewalPercent, seconds)
+	if err != nil {
+		renewal = percentOf(seconds, defaultRenewalPercent)
+	}
+	rebinding, err := leaseTimer(options, "dhcp-rebinding-time", defaultRebindingPercent, seconds)
+	if err != nil {
+		rebinding = percentOf(seconds, defaultRebindingPercent)
+	}
+	if renewal > rebinding || rebinding > seconds {
+		renewal = percentOf(seconds, defaultRenewalPercent)
+		rebinding = percentOf(seconds, defaultRebindingPercent)
+	}
+
+	encode := func(value uint32) []byte {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, value)
+		return data
+	}
+	return map[uint8][]byte{
+		OptionLeaseTime:     encode(seconds),
+		OptionRenewalTime:   encode(renewal),
+		OptionRebindingTime: encode(rebinding),
+	}
+}
+
+// leaseTimer читает таймер name в секундах или в процентах срока аренды
+// lease. Без опции возвращает defaultPercent срока.
+func leaseTimer(options map[string]string, name string, defaultPercent float64, lease uint32) (uint32, error) {
+	value, ok := options[name]
+	if !ok {
+		return percentOf(lease, defaultPercent), nil
+	}
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent <= 0 || percent >= 100 {
+			return 0, fmt.Errorf("invalid %s: %s", name, value)
+		}
+		return percentOf(lease, percent), nil
+	}
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return uint32(seconds), nil
+}
+
+// percentOf возвращает percent процентов от seconds
+func percentOf(seconds uint32, percent float64) uint32 {
+	return uint32(float64(seconds) * percent / 100)
+}
+
+// checkLeaseTimers проверяет значения dhcp-renewal-time и
+// dhcp-rebinding-time на всех уровнях конфигурации. Порядок таймеров
+// проверяется при ответе: он зависит от наследования и срока аренды.
+func checkLeaseTimers(cfg *config.DHCPConfig) error {
+	check := func(options map[string]string, scope string) error {
+		for _, name := range []string{"dhcp-renewal-time", "dhcp-rebinding-time"} {
+			if _, err := leaseTimer(options, name, 0, 0); err != nil {
+				return fmt.Errorf("%s: %v", scope, err)
+			}
+		}
+		return nil
+	}
+
+	if err := check(cfg.Options, "global options"); err != nil {
+		return err
+	}
+	for _, subnet := range cfg.Subnets {
+		if err := check(subnet.Options, "subnet "+subnet.Network.IP.String()); err != nil {
+			return err
+		}
+		for _, host := range subnet.Hosts {
+			if err := check(host.Options, "host "+host.Name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, class := range cfg.Classes {
+		if err := check(class.Options, "class "+class.Name); err != nil {
+			return err
+		}
+	}
+	for _, host := range cfg.Hosts {
+		if err := check(host.Options, "host "+host.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replyAddress определяет адрес получателя ответа (RFC 2131, 4.1). Ответ
+// через ретранслятор отправляется ему, клиенту, который продлевает аренду
+// напрямую (ciaddr заполнен), - на его адрес и порт клиента. Клиенту без
+// адреса или с флагом broadcast нужен широковещательный ответ, тогда
+// возвращается nil (см. broadcastAddress). Иначе ответ отправляется по
+// адресу отправителя.
+func replyAddress(request *BOOTPHeader, clientAddr *net.UDPAddr) *net.UDPAddr {
+	if !net.IP(request.Giaddr[:]).IsUnspecified() {
+		return clientAddr
+	}
+	if ciaddr := net.IP(request.Ciaddr[:]); !ciaddr.IsUnspecified() {
+		return &net.UDPAddr{IP: ciaddr, Port: BOOTP_CLIENT_PORT}
+	}
+	if request.Flags&broadcastFlag != 0 || clientAddr == nil || clientAddr.IP.IsUnspecified() {
+		return nil
+	}
+	return clientAddr
+}
@@ -0,0 +1,370 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestUnicastRenewal(t *testing.T) {
+	authoritative := true
+	cfg := &config.DHCPConfig{
+		Authoritative: &authoritative,
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := func(chaddr [16]byte, ciaddr string) *Packet {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: chaddr},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPRequest}},
+		}
+		if ciaddr != "" {
+			copy(packet.Header.Ciaddr[:], net.ParseIP(ciaddr).To4())
+		}
+		return server.processPacket(packet)
+	}
+
+	client := [16]byte{0x02, 0, 0, 0, 0, 1}
+	reply := request(client, "")
+	if reply == nil {
+		t.Fatal("Expected reply to initial request")
+	}
+	leased := net.IP(reply.Header.Yiaddr[:]).String()
+
+	// Сдвигаем срок аренды, чтобы проверить продление
+	server.mutex.Lock()
+	server.allocatedMAC["02:00:00:00:00:01"].Expires = time.Now().Add(time.Minute)
+	server.mutex.Unlock()
+
+	// Продление своего адреса подтверждается с обновленными таймерами
+	reply = request(client, leased)
+	if reply == nil {
+		t.Fatal("Expected reply to renewal")
+	}
+	if _, exists := reply.Options[OptionMessageType]; exists {
+		t.Error("Expected message type to be left for ACK")
+	}
+	if ip := net.IP(reply.Header.Ciaddr[:]).String(); ip != leased {
+		t.Errorf("Expected ciaddr %s in reply, got %s", leased, ip)
+	}
+	if ip := net.IP(reply.Header.Yiaddr[:]).String(); ip != leased {
+		t.Errorf("Expected yiaddr %s in reply, got %s", leased, ip)
+	}
+	if value := reply.Options[OptionLeaseTime]; len(value) != 4 || binary.BigEndian.Uint32(value) != 3600 {
+		t.Errorf("Expected lease time 3600, got %v", value)
+	}
+	if value := reply.Options[OptionRenewalTime]; len(value) != 4 || binary.BigEndian.Uint32(value) != 1800 {
+		t.Errorf("Expected T1 1800, got %v", value)
+	}
+	server.mutex.Lock()
+	expires := server.allocatedMAC["02:00:00:00:00:01"].Expires
+	server.mutex.Unlock()
+	if time.Until(expires) < 50*time.Minute {
+		t.Errorf("Expected lease to be extended, expires %v", expires)
+	}
+
+	// Продление чужого адреса авторитетный сервер отклоняет
+	reply = request(client, "192.168.1.150")
+	if reply == nil || messageType(reply.Options) != DHCPNak {
+		t.Fatalf("Expected NAK for wrong ciaddr, got %+v", reply)
+	}
+	if ip := net.IP(reply.Header.Yiaddr[:]); !ip.IsUnspecified() {
+		t.Errorf("Expected empty yiaddr in NAK, got %s", ip)
+	}
+
+	// Неавторитетный сервер не отвечает на чужой адрес
+	authoritative = false
+	if reply := request(client, "192.168.1.150"); reply != nil {
+		t.Errorf("Expected no reply from non-authoritative server, got %+v", reply)
+	}
+}
+
+func TestAuthoritative(t *testing.T) {
+	yes, no := true, false
+	cfg := &config.DHCPConfig{
+		Authoritative: &yes,
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+			},
+			{
+				Network:       config.MustParseNetwork("10.0.0.0/24"),
+				Ranges:        []config.Range{{Start: "10.0.0.100", End: "10.0.0.200"}},
+				Authoritative: &no,
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := func(requested string, options map[uint8][]byte) *Packet {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPRequest}, OptionRequestedIP: net.ParseIP(requested).To4()},
+		}
+		for code, value := range options {
+			packet.Options[code] = value
+		}
+		return server.processPacket(packet)
+	}
+
+	// INIT-REBOOT незнакомого клиента: отказ в авторитетной подсети,
+	// молчание в неавторитетной
+	if reply := request("192.168.1.150", nil); reply == nil || messageType(reply.Options) != DHCPNak {
+		t.Errorf("Expected NAK in authoritative subnet, got %+v", reply)
+	}
+	if reply := request("10.0.0.150", nil); reply != nil {
+		t.Errorf("Expected no reply in non-authoritative subnet, got %+v", reply)
+	}
+	if reply := request("172.16.0.10", nil); reply == nil || messageType(reply.Options) != DHCPNak {
+		t.Errorf("Expected NAK for unknown network, got %+v", reply)
+	}
+
+	// Клиент выбрал предложение другого сервера
+	if reply := request("192.168.1.150", map[uint8][]byte{OptionServerIdentifier: {192, 168, 1, 2}}); reply != nil {
+		t.Errorf("Expected no reply to client selecting another server, got %+v", reply)
+	}
+
+	// Запрос своего адреса подтверждается
+	offered := server.processPacket(&Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	})
+	ip := net.IP(offered.Header.Yiaddr[:]).String()
+	if reply := request(ip, nil); reply == nil || messageType(reply.Options) == DHCPNak {
+		t.Errorf("Expected ACK for offered address %s, got %+v", ip, reply)
+	}
+}
+
+func TestReplyAddress(t *testing.T) {
+	source := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 100), Port: 68}
+
+	renewal := &BOOTPHeader{Ciaddr: [4]byte{192, 168, 1, 100}}
+	if addr := replyAddress(renewal, &net.UDPAddr{IP: net.IPv4zero, Port: 68}); addr.String() != "192.168.1.100:68" {
+		t.Errorf("Expected reply to ciaddr, got %s", addr)
+	}
+
+	relayed := &BOOTPHeader{Ciaddr: [4]byte{192, 168, 1, 100}, Giaddr: [4]byte{10, 0, 0, 1}}
+	if addr := replyAddress(relayed, source); addr != source {
+		t.Errorf("Expected reply to relay source, got %s", addr)
+	}
+
+	if addr := replyAddress(&BOOTPHeader{}, source); addr != source {
+		t.Errorf("Expected reply to source, got %s", addr)
+	}
+
+	// Клиенту без адреса и клиенту с флагом broadcast отвечаем широковещательно
+	if addr := replyAddress(&BOOTPHeader{}, &net.UDPAddr{IP: net.IPv4zero, Port: 68}); addr != nil {
+		t.Errorf("Expected broadcast reply to client without address, got %s", addr)
+	}
+	if addr := replyAddress(&BOOTPHeader{Flags: broadcastFlag}, source); addr != nil {
+		t.Errorf("Expected broadcast reply for BROADCAST flag, got %s", addr)
+	}
+	relayed.Flags = broadcastFlag
+	if addr := replyAddress(relayed, source); addr != source {
+		t.Errorf("Expected reply to relay despite BROADCAST flag, got %s", addr)
+	}
+}
+
+func TestBOOTPLeaseLength(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := func(mac byte, dhcp bool) *AllocatedIP {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, mac}},
+			Options: map[uint8][]byte{},
+		}
+		if dhcp {
+			packet.Options[OptionMessageType] = []byte{DHCPDiscover}
+		}
+		if server.processPacket(packet) == nil {
+			t.Fatalf("Expected reply for client %d", mac)
+		}
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return server.allocatedMAC[chaddrToMAC(packet.Header.Chaddr, 6)]
+	}
+
+	// BOOTP клиент получает бессрочную аренду
+	lease := request(1, false)
+	if !lease.BOOTP || !lease.Expires.IsZero() {
+		t.Errorf("Expected infinite BOOTP lease, got %+v", lease)
+	}
+	if newLease(lease, time.Now()).State != LeaseStateActive {
+		t.Errorf("Expected infinite lease to stay active")
+	}
+
+	// DHCP клиент - срочную
+	if lease := request(2, true); lease.BOOTP || time.Until(lease.Expires) > defaultLeaseDuration {
+		t.Errorf("Expected expiring DHCP lease, got %+v", lease)
+	}
+
+	// Срок аренды BOOTP задается опцией bootp-lease-length
+	if err := server.Reload(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"bootp-lease-length": "86400"},
+		Subnets:       cfg.Subnets,
+	}); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if lease := request(3, false); time.Until(lease.Expires) < 23*time.Hour {
+		t.Errorf("Expected one day BOOTP lease, got %+v", lease)
+	}
+
+	if err := ValidateConfig(&config.DHCPConfig{GlobalOptions: map[string]string{"bootp-lease-length": "forever"}}); err == nil {
+		t.Error("Expected error for invalid bootp-lease-length")
+	}
+}
+
+func TestLeaseTimers(t *testing.T) {
+	timers := func(options map[string]string) (uint32, uint32) {
+		encoded := leaseTimeOptions(time.Hour, options)
+		return binary.BigEndian.Uint32(encoded[OptionRenewalTime]), binary.BigEndian.Uint32(encoded[OptionRebindingTime])
+	}
+
+	tests := []struct {
+		options   map[string]string
+		renewal   uint32
+		rebinding uint32
+	}{
+		{map[string]string{}, 1800, 3150},
+		{map[string]string{"dhcp-renewal-time": "25%", "dhcp-rebinding-time": "75%"}, 900, 2700},
+		{map[string]string{"dhcp-renewal-time": "600"}, 600, 3150},
+		{map[string]string{"dhcp-rebinding-time": "1200"}, 1800, 3150},                            // T2 < T1: значения по умолчанию
+		{map[string]string{"dhcp-renewal-time": "60", "dhcp-rebinding-time": "7200"}, 1800, 3150}, // T2 больше срока аренды
+	}
+	for _, test := range tests {
+		if renewal, rebinding := timers(test.options); renewal != test.renewal || rebinding != test.rebinding {
+			t.Errorf("%v: expected T1 %d and T2 %d, got %d and %d", test.options, test.renewal, test.rebinding, renewal, rebinding)
+		}
+	}
+
+	// Таймеры подсети переопределяют глобальные и передаются клиентам подсети
+	cfg := &config.DHCPConfig{
+		Options: map[string]string{"dhcp-renewal-time": "40%"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.200"}},
+				Options: map[string]string{"dhcp-rebinding-time": "60%"},
+			},
+		},
+	}
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	reply := server.processPacket(discoverPacket(1))
+	if reply == nil {
+		t.Fatal("Expected reply")
+	}
+	if value := binary.BigEndian.Uint32(reply.Options[OptionRenewalTime]); value != 1440 {
+		t.Errorf("Expected T1 1440, got %d", value)
+	}
+	if value := binary.BigEndian.Uint32(reply.Options[OptionRebindingTime]); value != 2160 {
+		t.Errorf("Expected T2 2160, got %d", value)
+	}
+
+	for _, value := range []string{"0%", "100%", "half", "-5"} {
+		cfg.Subnets[0].Options["dhcp-renewal-time"] = value
+		if err := ValidateConfig(cfg); err == nil {
+			t.Errorf("Expected error for dhcp-renewal-time %s", value)
+		}
+	}
+}
+
+func TestScopedLeaseTime(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: map[string]string{"default-lease-time": "43200", "max-lease-time": "86400"},
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("10.0.0.0/24"),
+				Ranges:  []config.Range{{Start: "10.0.0.100", End: "10.0.0.200"}},
+			},
+			{
+				Network: config.MustParseNetwork("10.0.99.0/24"),
+				Ranges:  []config.Range{{Start: "10.0.99.100", End: "10.0.99.200"}},
+				Options: map[string]string{"default-lease-time": "900", "max-lease-time": "1800"},
+			},
+		},
+		Hosts: []config.Host{
+			{Name: "kiosk", Hardware: "02:00:00:00:00:03", FixedIP: "10.0.99.10", Options: map[string]string{"default-lease-time": "600"}},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	// Гостевая подсеть выбирается, когда основная недоступна
+	server.mutex.Lock()
+	server.config.Subnets[0].Access.DenyMACs = []string{"02:00:00:00:00:02", "02:00:00:00:00:04"}
+	server.mutex.Unlock()
+
+	tests := []struct {
+		name      string
+		mac       byte
+		requested uint32 // 0 - клиент не запрашивает срок
+		want      uint32
+	}{
+		{"global", 1, 0, 43200},
+		{"guest subnet", 2, 0, 900},
+		{"host", 3, 0, 600},
+		{"requested above subnet max", 4, 7200, 1800},
+		{"requested below global max", 5, 3600, 3600},
+	}
+
+	for _, tt := range tests {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, tt.mac}},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+		}
+		if tt.requested != 0 {
+			packet.Options[OptionLeaseTime] = make([]byte, 4)
+			binary.BigEndian.PutUint32(packet.Options[OptionLeaseTime], tt.requested)
+		}
+		reply := server.processPacket(packet)
+		if reply == nil {
+			t.Fatalf("%s: expected reply", tt.name)
+		}
+		if value := reply.Options[OptionLeaseTime]; len(value) != 4 || binary.BigEndian.Uint32(value) != tt.want {
+			t.Errorf("%s: expected lease time %d, got %v", tt.name, tt.want, value)
+		}
+	}
+
+	server.mutex.Lock()
+	expires := server.allocatedMAC["02:00:00:00:00:02"].Expires
+	server.mutex.Unlock()
+	if remaining := time.Until(expires); remaining > 15*time.Minute || remaining < 14*time.Minute {
+		t.Errorf("Expected 15 minute lease, expires in %v", remaining)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// ipUDPHeaderSize размер заголовков IP и UDP, которые учитывает опция 57
+const ipUDPHeaderSize = 28
+
+// requiredReplyOptions опции, которые размещаются в ответе первыми и не
+// отбрасываются при нехватке места
+var requiredReplyOptions = []uint8{
+	OptionMessageType,
+	OptionServerIdentifier,
+	OptionLeaseTime,
+	OptionRenewalTime,
+	OptionRebindingTime,
+}
+
+// parseMaxReplySize читает опцию max-reply-size: ограничение размера
+// ответа в байтах, даже если клиент сообщает (опция 57), что принимает
+// больше. Меньше maxReplySize, который обязан принимать любой клиент,
+// ограничение быть не может.
+func parseMaxReplySize(options map[string]string) (int, error) {
+	value, ok := options["max-reply-size"]
+	if !ok {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < maxReplySize || size > maxPacketSize-ipUDPHeaderSize {
+		return 0, fmt.Errorf("invalid max-reply-size: %s (must be %d-%d)", value, maxReplySize, maxPacketSize-ipUDPHeaderSize)
+	}
+	return size, nil
+}
+
+// replySizeLimit возвращает максимальный размер ответа клиенту. Без
+// опции 57 ответ не превышает maxReplySize (RFC 2131, 2), больший размер
+// ограничен MTU Ethernet и настройкой max-reply-size (configured, 0 - не
+// задана).
+func replySizeLimit(request map[uint8][]byte, configured int) int {
+	limit := maxReplySize
+	if value := request[OptionMaxMessageSize]; len(value) == 2 {
+		// Размер в опции включает заголовки IP и UDP (RFC 2132, 9.10)
+		if size := int(binary.BigEndian.Uint16(value)) - ipUDPHeaderSize; size > limit {
+			limit = size
+		}
+	}
+	if limit > maxPacketSize-ipUDPHeaderSize {
+		limit = maxPacketSize - ipUDPHeaderSize
+	}
+	if configured > 0 && limit > configured {
+		limit = configured
+	}
+	return limit
+}
+
+// optionPriority возвращает коды опций ответа в порядке размещения:
+// обязательные опции, затем запрошенные клиентом в порядке списка
+// requested (опция 55), затем остальные по возрастанию кодов
+func optionPriority(options map[uint8][]byte, requested []byte) []uint8 {
+	codes := make([]uint8, 0, len(options))
+	seen := make(map[uint8]bool, len(options))
+	add := func(code uint8) {
+		if _, exists := options[code]; exists && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+
+	for _, code := range requiredReplyOptions {
+		add(code)
+	}
+	for _, code := range requested {
+		add(code)
+	}
+	for _, code := range optionCodes(options) {
+		add(code)
+	}
+	return codes
+}
+
+// replySize возвращает ограничение размера ответа из max-reply-size
+func (s *BOOTPServer) replySize() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.maxReply
+}
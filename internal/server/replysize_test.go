@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseMaxReplySize(t *testing.T) {
+	if size, err := parseMaxReplySize(map[string]string{}); err != nil || size != 0 {
+		t.Errorf("Expected no limit by default, got %d (%v)", size, err)
+	}
+	if size, err := parseMaxReplySize(map[string]string{"max-reply-size": "1000"}); err != nil || size != 1000 {
+		t.Errorf("Expected limit 1000, got %d (%v)", size, err)
+	}
+	for _, value := range []string{"300", "1500", "big"} {
+		if _, err := parseMaxReplySize(map[string]string{"max-reply-size": value}); err == nil {
+			t.Errorf("Expected error for max-reply-size %s", value)
+		}
+	}
+}
+
+func TestReplySizeLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxMessage []byte
+		configured int
+		want       int
+	}{
+		{"no option 57", nil, 0, maxReplySize},
+		{"larger size", []byte{0x03, 0xe8}, 0, 972},
+		{"below minimum", []byte{0x01, 0x2c}, 0, maxReplySize},
+		{"above MTU", []byte{0xff, 0xff}, 0, 1472},
+		{"configured limit", []byte{0x05, 0xdc}, 800, 800},
+		{"malformed", []byte{0x05}, 0, maxReplySize},
+	}
+
+	for _, test := range tests {
+		request := map[uint8][]byte{}
+		if test.maxMessage != nil {
+			request[OptionMaxMessageSize] = test.maxMessage
+		}
+		if limit := replySizeLimit(request, test.configured); limit != test.want {
+			t.Errorf("%s: expected %d, got %d", test.name, test.want, limit)
+		}
+	}
+}
+
+func TestEncodeReplyLimit(t *testing.T) {
+	header := &BOOTPHeader{Op: BOOTPReply, Htype: HTYPE_ETHER, Hlen: 6, Magic: magicCookie}
+	copy(header.File[:], "pxelinux.0")
+	copy(header.Sname[:], "boot.example.com")
+	options := map[uint8][]byte{
+		OptionMessageType: {DHCPAck},
+		OptionLeaseTime:   {0, 0, 0x0e, 0x10},
+		OptionDomainName:  bytes.Repeat([]byte{'d'}, 80),
+		17:                bytes.Repeat([]byte{'/'}, 200), // root-path
+		43:                bytes.Repeat([]byte{'v'}, 400), // vendor-encapsulated-options
+	}
+
+	// Клиент, принимающий большие сообщения, получает все опции
+	data, dropped := encodeReply(nil, header, options, 1472, nil)
+	if len(dropped) != 0 || len(data) > 1472 {
+		t.Errorf("Expected all options in %d bytes, got %d bytes, dropped %v", 1472, len(data), dropped)
+	}
+	packet, err := DecodePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for code, value := range options {
+		if !bytes.Equal(packet.Options[code], value) {
+			t.Errorf("Option %d lost or changed", code)
+		}
+	}
+
+	// Поля file и sname заняты: не поместившиеся опции отбрасываются,
+	// запрошенные клиентом размещаются раньше остальных
+	data, dropped = encodeReply(nil, header, options, maxReplySize, []byte{17, 15})
+	if len(data) > maxReplySize {
+		t.Errorf("Expected reply to fit into %d bytes, got %d", maxReplySize, len(data))
+	}
+	if len(dropped) != 1 || dropped[0] != 43 {
+		t.Errorf("Expected vendor options to be dropped, got %v", dropped)
+	}
+	packet, err = DecodePacket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []uint8{OptionMessageType, OptionLeaseTime, OptionDomainName, 17} {
+		if !bytes.Equal(packet.Options[code], options[code]) {
+			t.Errorf("Option %d lost or changed", code)
+		}
+	}
+}
+
+func TestOptionPriority(t *testing.T) {
+	options := map[uint8][]byte{
+		OptionMessageType:      {DHCPOffer},
+		OptionServerIdentifier: {192, 168, 1, 1},
+		OptionDomainName:       []byte("example.com"),
+		OptionHostName:         []byte("node"),
+		17:                     []byte("/root"),
+	}
+	codes := optionPriority(options, []byte{17, 1, OptionDomainName, 17})
+	want := []uint8{OptionMessageType, OptionServerIdentifier, 17, OptionDomainName, OptionHostName}
+	if !bytes.Equal(codes, want) {
+		t.Errorf("Expected priority %v, got %v", want, codes)
+	}
+}
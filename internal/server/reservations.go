@@ -0,0 +1,289 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// ErrReservationNotFound возвращается, если резервирование не найдено
+var ErrReservationNotFound = errors.New("reservation not found")
+
+// reservationsFileHeader комментарий в начале файла резервирований
+const reservationsFileHeader = `# Host reservations managed by go-bootp.
+# This file is rewritten on every change made through the control socket;
+# edit it by hand only while the server is stopped.
+`
+
+// reservationsFile возвращает путь к файлу резервирований, заданный
+// глобальной опцией reservations-file "path"; (пусто - не задан)
+func reservationsFile(options map[string]string) string {
+	return strings.Trim(options["reservations-file"], "\"")
+}
+
+// loadReservationsFile читает блоки host из файла резервирований.
+// Отсутствующий файл означает, что резервирований еще нет.
+func loadReservationsFile(path string) ([]config.Host, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	cfg, err := config.ParseConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Subnets) > 0 {
+		return nil, fmt.Errorf("reservations file %s: only host declarations are allowed", path)
+	}
+	return cfg.Hosts, nil
+}
+
+// writeReservationsFile записывает резервирования в синтаксисе ISC-DHCP.
+// Файл заменяется атомарно, чтобы сбой не оставил его наполовину записанным.
+func writeReservationsFile(path string, hosts []config.Host) error {
+	data, err := config.Marshal(&config.DHCPConfig{
+		Comment: strings.TrimSuffix(reservationsFileHeader, "\n"),
+		Hosts:   hosts,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// withReservations возвращает конфигурацию, дополненную резервированиями,
+// управляемыми во время работы. Исходная конфигурация не меняется.
+func withReservations(cfg *config.DHCPConfig, managed []config.Host) *config.DHCPConfig {
+	if len(managed) == 0 {
+		return cfg
+	}
+	effective := *cfg
+	effective.Hosts = make([]config.Host, 0, len(cfg.Hosts)+len(managed))
+	effective.Hosts = append(effective.Hosts, cfg.Hosts...)
+	effective.Hosts = append(effective.Hosts, managed...)
+	return &effective
+}
+
+// effectiveConfig дополняет конфигурацию резервированиями из файла
+// reservations-file, если он задан
+func effectiveConfig(cfg *config.DHCPConfig) (*config.DHCPConfig, []config.Host, error) {
+	if cfg.GlobalOptions == nil {
+		return cfg, nil, nil
+	}
+	path := reservationsFile(cfg.GlobalOptions)
+	if path == "" {
+		return cfg, nil, nil
+	}
+	managed, err := loadReservationsFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return withReservations(cfg, managed), managed, nil
+}
+
+// newReservation проверяет и нормализует резервирование, добавляемое
+// во время работы
+func newReservation(r Reservation) (config.Host, error) {
+	if r.Name == "" || strings.ContainsAny(r.Name, " \t\r\n{};#\"") {
+		return config.Host{}, fmt.Errorf("invalid reservation name: %q", r.Name)
+	}
+	if r.MAC == "" && r.ClientID == "" {
+		return config.Host{}, fmt.Errorf("reservation %s: mac or client_id is required", r.Name)
+	}
+	ip := net.ParseIP(r.IP)
+	if ip == nil || ip.To4() == nil {
+		return config.Host{}, fmt.Errorf("reservation %s: invalid IPv4 address: %s", r.Name, r.IP)
+	}
+
+	host := config.Host{
+		Name:    r.Name,
+		FixedIP: ip.To4().String(),
+		Options: make(map[string]string),
+	}
+	if r.MAC != "" {
+		mac, err := config.NormalizeMAC(r.MAC)
+		if err != nil {
+			return config.Host{}, fmt.Errorf("reservation %s: %v", r.Name, err)
+		}
+		host.Hardware = mac
+	}
+	if r.ClientID != "" {
+		clientID, err := config.NormalizeClientID(r.ClientID)
+		if err != nil {
+			return config.Host{}, fmt.Errorf("reservation %s: %v", r.Name, err)
+		}
+		host.ClientID = clientID
+	}
+	return host, nil
+}
+
+// AddReservation добавляет резервирование во время работы. Резервирование
+// с тем же именем, добавленное ранее, заменяется. Если задан
+// reservations-file, список резервирований сохраняется в него до применения.
+func (s *BOOTPServer) AddReservation(r Reservation) (Reservation, error) {
+	host, err := newReservation(r)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	s.mutex.Lock()
+	base, current := s.baseConfig, s.reservations
+	s.mutex.Unlock()
+
+	for _, declared := range base.Hosts {
+		if declared.Name == host.Name {
+			return Reservation{}, fmt.Errorf("host %s is declared in the configuration file", host.Name)
+		}
+	}
+	for _, subnet := range base.Subnets {
+		for _, declared := range subnet.Hosts {
+			if declared.Name == host.Name {
+				return Reservation{}, fmt.Errorf("host %s is declared in the configuration file", host.Name)
+			}
+		}
+	}
+
+	managed := make([]config.Host, 0, len(current)+1)
+	for _, existing := range current {
+		if existing.Name != host.Name {
+			managed = append(managed, existing)
+		}
+	}
+	managed = append(managed, host)
+
+	effective := withReservations(base, managed)
+	if err := checkReservations(effective); err != nil {
+		return Reservation{}, err
+	}
+	runtime, err := config.Compile(effective)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	// Адрес не должен быть выдан в аренду другому клиенту
+	s.mutex.Lock()
+	allocated := s.allocatedIP[ipToInt(net.ParseIP(host.FixedIP))]
+	s.mutex.Unlock()
+	if allocated != nil && allocated.Type == DynamicAllocation && allocated.Active && allocated.key() != hostKey(&host) {
+		return Reservation{}, fmt.Errorf("address %s is leased to %s", host.FixedIP, allocated.MAC)
+	}
+
+	if err := s.saveReservations(managed); err != nil {
+		return Reservation{}, err
+	}
+
+	s.mutex.Lock()
+	s.reservations = managed
+	s.applyConfig(effective, runtime)
+	s.mutex.Unlock()
+
+	s.logger.Infof("Added reservation %s: %s for %s", host.Name, host.FixedIP, hostKey(&host))
+	return Reservation{
+		Name:     host.Name,
+		MAC:      host.Hardware,
+		ClientID: host.ClientID,
+		IP:       host.FixedIP,
+		Managed:  true,
+	}, nil
+}
+
+// DeleteReservation удаляет резервирование, добавленное во время работы.
+// Блоки host из конфигурационного файла удалить нельзя.
+func (s *BOOTPServer) DeleteReservation(name string) error {
+	s.adminMutex.Lock()
+	defer s.adminMutex.Unlock()
+
+	s.mutex.Lock()
+	base, current := s.baseConfig, s.reservations
+	s.mutex.Unlock()
+
+	managed := make([]config.Host, 0, len(current))
+	for _, existing := range current {
+		if existing.Name != name {
+			managed = append(managed, existing)
+		}
+	}
+	if len(managed) == len(current) {
+		for _, declared := range base.Hosts {
+			if declared.Name == name {
+				return fmt.Errorf("host %s is declared in the configuration file", name)
+			}
+		}
+		for _, subnet := range base.Subnets {
+			for _, declared := range subnet.Hosts {
+				if declared.Name == name {
+					return fmt.Errorf("host %s is declared in the configuration file", name)
+				}
+			}
+		}
+		return ErrReservationNotFound
+	}
+
+	effective := withReservations(base, managed)
+	runtime, err := config.Compile(effective)
+	if err != nil {
+		return err
+	}
+	if err := s.saveReservations(managed); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.reservations = managed
+	s.applyConfig(effective, runtime)
+	s.mutex.Unlock()
+
+	s.logger.Infof("Deleted reservation %s", name)
+	return nil
+}
+
+// saveReservations записывает резервирования в reservations-file, если он задан
+func (s *BOOTPServer) saveReservations(managed []config.Host) error {
+	if s.baseConfig.GlobalOptions == nil {
+		return nil
+	}
+	path := reservationsFile(s.baseConfig.GlobalOptions)
+	if path == "" {
+		return nil
+	}
+	if err := writeReservationsFile(path, managed); err != nil {
+		return fmt.Errorf("write reservations file: %v", err)
+	}
+	return nil
+}
+
+// isManagedReservation возвращает true для резервирований, добавленных
+// во время работы
+func (s *BOOTPServer) isManagedReservation(name string) bool {
+	for _, host := range s.reservations {
+		if host.Name == name {
+			return true
+		}
+	}
+	return false
+}
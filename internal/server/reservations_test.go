@@ -0,0 +1,185 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func newReservationTestServer(t *testing.T, path string) *BOOTPServer {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Hosts: []config.Host{
+					{Name: "static", Hardware: "00:11:22:33:44:55", FixedIP: "192.168.1.10"},
+				},
+			},
+		},
+		GlobalOptions: map[string]string{},
+	}
+	if path != "" {
+		cfg.GlobalOptions["reservations-file"] = "\"" + path + "\""
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return server
+}
+
+func TestAddReservation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reservations.conf")
+	server := newReservationTestServer(t, path)
+
+	r, err := server.AddReservation(Reservation{Name: "printer", MAC: "AA-BB-CC-DD-EE-01", IP: "192.168.1.20"})
+	if err != nil {
+		t.Fatalf("AddReservation failed: %v", err)
+	}
+	if r.MAC != "aa:bb:cc:dd:ee:01" || !r.Managed {
+		t.Errorf("Expected normalized managed reservation, got %+v", r)
+	}
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:01"); ip != "192.168.1.20" {
+		t.Errorf("Expected reserved address 192.168.1.20, got %s", ip)
+	}
+
+	// Файл должен читаться парсером ISC-DHCP
+	hosts, err := loadReservationsFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read reservations file: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "printer" || hosts[0].Hardware != "aa:bb:cc:dd:ee:01" || hosts[0].FixedIP != "192.168.1.20" {
+		t.Errorf("Unexpected reservations file contents: %+v", hosts)
+	}
+
+	// Резервирование с тем же именем заменяется
+	if _, err := server.AddReservation(Reservation{Name: "printer", ClientID: "01:aa:bb:cc:dd:ee:01", IP: "192.168.1.21"}); err != nil {
+		t.Fatalf("Replacing reservation failed: %v", err)
+	}
+	var managed []Reservation
+	for _, reservation := range server.Reservations() {
+		if reservation.Managed {
+			managed = append(managed, reservation)
+		}
+	}
+	if len(managed) != 1 || managed[0].IP != "192.168.1.21" || managed[0].ClientID != "01:aa:bb:cc:dd:ee:01" {
+		t.Errorf("Expected replaced reservation, got %+v", managed)
+	}
+
+	// Новый сервер читает резервирования из файла
+	restarted := newReservationTestServer(t, path)
+	if allocated := restarted.allocatedMAC["id:01:aa:bb:cc:dd:ee:01"]; allocated == nil || intToIP(allocated.IP).String() != "192.168.1.21" {
+		t.Errorf("Expected reservation loaded from file, got %+v", allocated)
+	}
+}
+
+func TestAddReservationErrors(t *testing.T) {
+	server := newReservationTestServer(t, "")
+
+	// Адрес, выданный в аренду другому клиенту
+	ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:02")
+
+	tests := []struct {
+		name        string
+		reservation Reservation
+		err         string
+	}{
+		{"invalid name", Reservation{Name: "bad name", MAC: "aa:bb:cc:dd:ee:01", IP: "192.168.1.20"}, "invalid reservation name"},
+		{"no client", Reservation{Name: "printer", IP: "192.168.1.20"}, "mac or client_id is required"},
+		{"invalid ip", Reservation{Name: "printer", MAC: "aa:bb:cc:dd:ee:01", IP: "2001:db8::1"}, "invalid IPv4 address"},
+		{"invalid mac", Reservation{Name: "printer", MAC: "aa:zz:cc:dd:ee:01", IP: "192.168.1.20"}, "invalid"},
+		{"config host", Reservation{Name: "static", MAC: "aa:bb:cc:dd:ee:01", IP: "192.168.1.20"}, "declared in the configuration file"},
+		{"duplicate address", Reservation{Name: "printer", MAC: "aa:bb:cc:dd:ee:01", IP: "192.168.1.10"}, "reserve the same address"},
+		{"duplicate client", Reservation{Name: "printer", MAC: "00:11:22:33:44:55", IP: "192.168.1.20"}, "declare the same client"},
+		{"leased address", Reservation{Name: "printer", MAC: "aa:bb:cc:dd:ee:01", IP: ip}, "is leased to aa:bb:cc:dd:ee:02"},
+	}
+
+	for _, test := range tests {
+		_, err := server.AddReservation(test.reservation)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+	if len(server.Reservations()) != 1 {
+		t.Errorf("Expected rejected reservations not to be applied, got %+v", server.Reservations())
+	}
+}
+
+func TestDeleteReservation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reservations.conf")
+	server := newReservationTestServer(t, path)
+	events, cancel := server.SubscribeLeaseEvents(0)
+	defer cancel()
+
+	if _, err := server.AddReservation(Reservation{Name: "printer", MAC: "aa:bb:cc:dd:ee:01", IP: "192.168.1.20"}); err != nil {
+		t.Fatal(err)
+	}
+	server.findClientConfig("aa:bb:cc:dd:ee:01")
+	receiveEvent(t, events)
+
+	if err := server.DeleteReservation("static"); err == nil || !strings.Contains(err.Error(), "declared in the configuration file") {
+		t.Errorf("Expected configuration host not to be deleted, got %v", err)
+	}
+	if err := server.DeleteReservation("missing"); err != ErrReservationNotFound {
+		t.Errorf("Expected ErrReservationNotFound, got %v", err)
+	}
+
+	if err := server.DeleteReservation("printer"); err != nil {
+		t.Fatalf("DeleteReservation failed: %v", err)
+	}
+	if event := receiveEvent(t, events); event.Type != LeaseReleased || event.Lease.IP != "192.168.1.20" {
+		t.Errorf("Expected release of deleted reservation, got %+v", event)
+	}
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:01"); ip == "192.168.1.20" {
+		t.Error("Expected deleted reservation not to be used")
+	}
+
+	hosts, err := loadReservationsFile(path)
+	if err != nil || len(hosts) != 0 {
+		t.Errorf("Expected empty reservations file, got %+v (%v)", hosts, err)
+	}
+}
+
+func TestReloadKeepsReservations(t *testing.T) {
+	server := newReservationTestServer(t, "")
+	if _, err := server.AddReservation(Reservation{Name: "printer", MAC: "aa:bb:cc:dd:ee:01", IP: "192.168.1.20"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Без reservations-file резервирования хранятся только в памяти
+	if err := server.Reload(server.baseConfig); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:01"); ip != "192.168.1.20" {
+		t.Errorf("Expected reservation to survive reload, got %s", ip)
+	}
+
+	// Конфликт резервирования с новой конфигурацией отклоняет перезагрузку
+	cfg := *server.baseConfig
+	cfg.Hosts = []config.Host{{Name: "other", Hardware: "aa:bb:cc:dd:ee:09", FixedIP: "192.168.1.20"}}
+	if err := server.Reload(&cfg); err == nil {
+		t.Error("Expected reload with conflicting reservation to fail")
+	}
+}
+
+func TestLoadReservationsFile(t *testing.T) {
+	dir := t.TempDir()
+
+	hosts, err := loadReservationsFile(filepath.Join(dir, "missing.conf"))
+	if err != nil || hosts != nil {
+		t.Errorf("Expected missing file to be empty, got %+v (%v)", hosts, err)
+	}
+
+	path := filepath.Join(dir, "subnet.conf")
+	if err := os.WriteFile(path, []byte("subnet 10.0.0.0 netmask 255.0.0.0 {\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadReservationsFile(path); err == nil {
+		t.Error("Expected subnet declaration in reservations file to fail")
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Параметры кэша ответов на повторные запросы по умолчанию
+const (
+	defaultRetransmitWindow = 10 * time.Second
+	retransmitMaxEntries    = 4096 // Максимальное количество сохраненных ответов
+)
+
+// retransmitKey идентифицирует запрос: клиенты повторяют запрос с тем же
+// xid и типом сообщения, пока не получат ответ
+type retransmitKey struct {
+	xid     uint32
+	chaddr  [16]byte
+	msgType uint8
+}
+
+// retransmitEntry сохраненный ответ
+type retransmitEntry struct {
+	reply   *Packet
+	expires time.Time
+}
+
+// retransmitCache хранит ответы на запросы в течение окна повтора, чтобы
+// повторный запрос получал тот же ответ без повторного выделения адреса
+type retransmitCache struct {
+	mutex   sync.Mutex
+	window  time.Duration // Время хранения ответа (0 - кэш отключен)
+	entries map[retransmitKey]retransmitEntry
+}
+
+func newRetransmitCache(window time.Duration) *retransmitCache {
+	return &retransmitCache{window: window, entries: make(map[retransmitKey]retransmitEntry)}
+}
+
+// parseRetransmitWindow читает глобальную опцию retransmit-cache-time
+// (секунды, 0 отключает кэш)
+func parseRetransmitWindow(options map[string]string) (time.Duration, error) {
+	value, ok := options["retransmit-cache-time"]
+	if !ok {
+		return defaultRetransmitWindow, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid retransmit-cache-time: %s", value)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// get возвращает копию сохраненного ответа на запрос (nil - нет ответа)
+func (c *retransmitCache) get(key retransmitKey, now time.Time) *Packet {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return clonePacket(entry.reply)
+}
+
+// put сохраняет копию ответа. При заполненном кэше удаляются истекшие
+// ответы; если места все равно нет, ответ не сохраняется.
+func (c *retransmitCache) put(key retransmitKey, reply *Packet, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.window <= 0 {
+		return
+	}
+	if len(c.entries) >= retransmitMaxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= retransmitMaxEntries {
+			return
+		}
+	}
+	c.entries[key] = retransmitEntry{reply: clonePacket(reply), expires: now.Add(c.window)}
+}
+
+// reset удаляет сохраненные ответы и задает новое окно
+func (c *retransmitCache) reset(window time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.window = window
+	c.entries = make(map[retransmitKey]retransmitEntry)
+}
+
+// clonePacket копирует пакет: при отправке в ответ добавляются опции
+func clonePacket(packet *Packet) *Packet {
+	return &Packet{Header: packet.Header, Options: copyOptions(packet.Options)}
+}
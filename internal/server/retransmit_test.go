@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetransmitCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := newRetransmitCache(10 * time.Second)
+	key := retransmitKey{xid: 1, chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}, msgType: DHCPDiscover}
+	reply := &Packet{Header: BOOTPHeader{Xid: 1}, Options: map[uint8][]byte{OptionMessageType: {DHCPOffer}}}
+
+	if cache.get(key, now) != nil {
+		t.Fatal("Expected empty cache")
+	}
+	cache.put(key, reply, now)
+
+	// Ответ, измененный после сохранения, не меняет сохраненную копию
+	reply.Options[OptionSubnetMask] = []byte{255, 255, 255, 0}
+	saved := cache.get(key, now.Add(5*time.Second))
+	if saved == nil || saved.Header.Xid != 1 || len(saved.Options) != 1 {
+		t.Fatalf("Unexpected saved reply: %+v", saved)
+	}
+	saved.Options[OptionSubnetMask] = []byte{255, 255, 255, 0}
+	if again := cache.get(key, now); len(again.Options) != 1 {
+		t.Errorf("Saved reply changed: %+v", again)
+	}
+
+	other := key
+	other.msgType = DHCPRequest
+	if cache.get(other, now) != nil {
+		t.Error("Expected no reply for another message type")
+	}
+	if cache.get(key, now.Add(10*time.Second)) != nil {
+		t.Error("Expected reply to expire")
+	}
+
+	cache.reset(0)
+	cache.put(key, reply, now)
+	if cache.get(key, now) != nil {
+		t.Error("Expected disabled cache to keep no replies")
+	}
+}
+
+func TestParseRetransmitWindow(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		valid    bool
+	}{
+		{"", defaultRetransmitWindow, true},
+		{"0", 0, true},
+		{"30", 30 * time.Second, true},
+		{"-1", 0, false},
+		{"10s", 0, false},
+	}
+	for _, tt := range tests {
+		options := map[string]string{}
+		if tt.value != "" {
+			options["retransmit-cache-time"] = tt.value
+		}
+		window, err := parseRetransmitWindow(options)
+		if (err == nil) != tt.valid || window != tt.expected {
+			t.Errorf("parseRetransmitWindow(%q) = %v, %v", tt.value, window, err)
+		}
+	}
+}
+
+func TestRetransmittedRequest(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clientAddr := conn.LocalAddr().(*net.UDPAddr)
+
+	send := func(xid uint32) {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Xid: xid, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+		}
+		server.handlePacket(conn, packet, DHCPDiscover, clientAddr, nil)
+	}
+
+	send(1)
+	send(1)
+	c := server.Stats().Counters
+	if c.Requests != 2 || c.Offers != 2 || c.Retransmits != 1 || c.Allocations != 1 {
+		t.Errorf("Unexpected counters after retransmission: %+v", c)
+	}
+
+	// Новый xid обрабатывается заново
+	send(2)
+	if c := server.Stats().Counters; c.Retransmits != 1 {
+		t.Errorf("Expected new transaction to be processed, got %+v", c)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Параметры проверки занятости адреса по умолчанию
+const (
+	defaultPingTimeout = time.Second
+	maxProbeAttempts   = 3 // Адресов, проверяемых для одного запроса
+)
+
+// reusePolicy задает, когда адрес истекшей аренды возвращается в пул
+type reusePolicy struct {
+	grace       time.Duration // Удержание адреса после истечения аренды
+	pingCheck   bool          // Проверять новый адрес ICMP эхо-запросом
+	pingTimeout time.Duration // Время ожидания эхо-ответа
+}
+
+// parseReusePolicy читает глобальные опции lease-grace-period (секунды),
+// ping-check и ping-timeout (секунды)
+func parseReusePolicy(options map[string]string) (reusePolicy, error) {
+	policy := reusePolicy{pingTimeout: defaultPingTimeout}
+
+	if value, ok := options["lease-grace-period"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return reusePolicy{}, fmt.Errorf("invalid lease-grace-period: %s", value)
+		}
+		policy.grace = time.Duration(seconds) * time.Second
+	}
+
+	if value, ok := options["ping-check"]; ok {
+		policy.pingCheck = value == "" || isEnabled(value)
+	}
+
+	if value, ok := options["ping-timeout"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return reusePolicy{}, fmt.Errorf("invalid ping-timeout: %s", value)
+		}
+		policy.pingTimeout = time.Duration(seconds) * time.Second
+	}
+
+	return policy, nil
+}
+
+// addressProber проверяет, отвечает ли адрес в сети
+type addressProber func(ip net.IP, timeout time.Duration) bool
+
+// held проверяет, удерживается ли адрес истекшей аренды за прежним
+// клиентом на момент now
+func (p reusePolicy) held(allocated *AllocatedIP, now time.Time) bool {
+	return !allocated.Expires.IsZero() && allocated.Expires.Add(p.grace).After(now)
+}
+
+// probeOffer проверяет, что новый адрес не отвечает на ICMP эхо-запрос.
+// Ответивший адрес считается занятым устройством вне таблицы аренд на срок
+// аренды, и для клиента выбирается следующий. Собственные адреса клиента
+// не проверяются. Адрес отвергнутого предложения освобождается.
+func (s *BOOTPServer) probeOffer(macAddr, clientID string, offer *leaseOffer) *leaseOffer {
+	s.mutex.Lock()
+	policy := s.reuse
+	s.mutex.Unlock()
+
+	for attempt := 1; offer != nil && offer.existing == nil && policy.pingCheck; attempt++ {
+		ip := intToIP(offer.ip)
+		if !s.probe(ip, policy.pingTimeout) {
+			return offer
+		}
+
+		s.logger.Warnf("Address %s answers ping, marking it as in use", ip)
+		s.mutex.Lock()
+		s.conflicts[offer.ip] = s.clock.Now().Add(s.leaseTime)
+		s.mutex.Unlock()
+		s.recordAudit(AuditConflict, macAddr, ip.String())
+
+		releaseOffer(offer)
+		if attempt == maxProbeAttempts {
+			s.logger.Warnf("No free address for %s after %d ping checks", macAddr, attempt)
+			return nil
+		}
+		offer = s.selectLease(macAddr, clientID, offer.bootp, offer.networks)
+	}
+	return offer
+}
+
+// conflicted проверяет, помечен ли адрес как занятый по результату
+// ICMP проверки. Вызывается с захваченным мьютексом.
+func (s *BOOTPServer) conflicted(ip uint32, now time.Time) bool {
+	until, exists := s.conflicts[ip]
+	if !exists {
+		return false
+	}
+	if !until.After(now) {
+		delete(s.conflicts, ip)
+		return false
+	}
+	return true
+}
+
+// pingAddress отправляет ICMP эхо-запрос и ожидает ответ не дольше timeout.
+// Для raw сокета нужны права root или CAP_NET_RAW; при ошибке адрес
+// считается свободным.
+func pingAddress(ip net.IP, timeout time.Duration) bool {
+	conn, err := net.DialIP("ip4:icmp", nil, &net.IPAddr{IP: ip})
+	if err != nil {
+		logrus.Debugf("Ping check of %s failed: %v", ip, err)
+		return false
+	}
+	defer conn.Close()
+
+	id := uint16(os.Getpid())
+	request := []byte{8, 0, 0, 0, byte(id >> 8), byte(id), 0, 1} // Echo Request, seq 1
+	binary.BigEndian.PutUint16(request[2:], icmpChecksum(request))
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(request); err != nil {
+		logrus.Debugf("Ping check of %s failed: %v", ip, err)
+		return false
+	}
+
+	buffer := make([]byte, 1500)
+	for {
+		// ReadFromIP отрезает IPv4 заголовок
+		n, _, err := conn.ReadFromIP(buffer)
+		if err != nil {
+			return false
+		}
+		reply := buffer[:n]
+		if len(reply) >= 8 && reply[0] == 0 && binary.BigEndian.Uint16(reply[4:]) == id {
+			return true
+		}
+	}
+}
+
+// icmpChecksum вычисляет контрольную сумму ICMP сообщения (RFC 1071)
+func icmpChecksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
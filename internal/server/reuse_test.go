@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func newReuseTestServer(t *testing.T, options map[string]string) *BOOTPServer {
+	cfg := &config.DHCPConfig{
+		GlobalOptions: options,
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	return server
+}
+
+func TestParseReusePolicy(t *testing.T) {
+	policy, err := parseReusePolicy(map[string]string{"lease-grace-period": "300", "ping-check": "true", "ping-timeout": "2"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy.grace != 5*time.Minute || !policy.pingCheck || policy.pingTimeout != 2*time.Second {
+		t.Errorf("Unexpected policy: %+v", policy)
+	}
+
+	if policy, _ := parseReusePolicy(map[string]string{"ping-check": "false"}); policy.pingCheck {
+		t.Error("Expected ping-check false to disable ping check")
+	}
+
+	for _, options := range []map[string]string{
+		{"lease-grace-period": "-1"},
+		{"lease-grace-period": "soon"},
+		{"ping-timeout": "0"},
+	} {
+		if _, err := parseReusePolicy(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
+
+func TestLeaseGracePeriod(t *testing.T) {
+	server := newReuseTestServer(t, map[string]string{"lease-grace-period": "600"})
+
+	ip, _ := server.findClientConfig("aa:bb:cc:dd:ee:01")
+	if ip != "192.168.1.100" {
+		t.Fatalf("Expected 192.168.1.100, got %s", ip)
+	}
+
+	// Аренда истекла несколько минут назад: адрес удерживается за клиентом
+	server.mutex.Lock()
+	server.allocatedMAC["aa:bb:cc:dd:ee:01"].Expires = time.Now().Add(-5 * time.Minute)
+	server.mutex.Unlock()
+
+	if other, _ := server.findClientConfig("aa:bb:cc:dd:ee:02"); other == ip {
+		t.Errorf("Expected held address %s not to be given to another client", ip)
+	}
+	if again, _ := server.findClientConfig("aa:bb:cc:dd:ee:01"); again != ip {
+		t.Errorf("Expected client to get back held address %s, got %s", ip, again)
+	}
+
+	// После окончания удержания адрес возвращается в пул
+	server.mutex.Lock()
+	server.allocatedMAC["aa:bb:cc:dd:ee:01"].Expires = time.Now().Add(-15 * time.Minute)
+	server.mutex.Unlock()
+
+	if other, _ := server.findClientConfig("aa:bb:cc:dd:ee:03"); other != ip {
+		t.Errorf("Expected released address %s for new client, got %s", ip, other)
+	}
+}
+
+func TestPingCheck(t *testing.T) {
+	server := newReuseTestServer(t, map[string]string{"ping-check": "true"})
+
+	var probed []string
+	server.probe = func(ip net.IP, timeout time.Duration) bool {
+		probed = append(probed, ip.String())
+		return ip.String() == "192.168.1.100" // Адрес занят устройством вне таблицы аренд
+	}
+
+	request := func(mac byte) string {
+		reply := server.processPacket(&Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, mac}},
+			Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+		})
+		if reply == nil {
+			return ""
+		}
+		return net.IP(reply.Header.Yiaddr[:]).String()
+	}
+
+	if ip := request(1); ip != "192.168.1.101" {
+		t.Errorf("Expected address after busy one, got %s", ip)
+	}
+	if len(probed) != 2 {
+		t.Errorf("Expected 2 probes, got %v", probed)
+	}
+
+	// Занятый адрес больше не предлагается, собственный адрес клиента не проверяется
+	probed = nil
+	if ip := request(2); ip != "192.168.1.102" {
+		t.Errorf("Expected 192.168.1.102, got %s", ip)
+	}
+	if ip := request(1); ip != "192.168.1.101" {
+		t.Errorf("Expected client to keep 192.168.1.101, got %s", ip)
+	}
+	if len(probed) != 1 || probed[0] != "192.168.1.102" {
+		t.Errorf("Expected single probe of 192.168.1.102, got %v", probed)
+	}
+}
+
+func TestICMPChecksum(t *testing.T) {
+	message := []byte{8, 0, 0, 0, 0x12, 0x34, 0, 1}
+	sum := icmpChecksum(message)
+	message[2], message[3] = byte(sum>>8), byte(sum)
+	if icmpChecksum(message) != 0 {
+		t.Errorf("Expected zero checksum over message with checksum, got %#x", icmpChecksum(message))
+	}
+}
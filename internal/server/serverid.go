@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// parseServerIdentifier читает адрес, заданный опцией server-identifier.
+// Возвращает nil, если опция не задана.
+func parseServerIdentifier(options map[string]string) (net.IP, error) {
+	value := strings.Trim(options["server-identifier"], "\"")
+	if value == "" {
+		return nil, nil
+	}
+
+	ip := net.ParseIP(value).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid server-identifier: %s", value)
+	}
+	return ip, nil
+}
+
+// replyOptions формирует опции DHCP ответа: тип сообщения и идентификатор
+// сервера (опция 54). Для BOOTP запросов опции не добавляются. local -
+// адрес, на который пришел запрос (nil, если платформа его не сообщает).
+func (s *BOOTPServer) replyOptions(conn *net.UDPConn, reply *BOOTPHeader, msgType uint8, local net.IP) map[uint8][]byte {
+	replyType := replyMessageType(msgType)
+	if replyType == 0 {
+		return nil
+	}
+
+	options := map[uint8][]byte{OptionMessageType: {replyType}}
+	if id := s.serverIdentifier(conn, reply, local); id != nil {
+		options[OptionServerIdentifier] = id
+	}
+	return options
+}
+
+// serverIdentifier определяет адрес сервера для опции 54 и siaddr по
+// умолчанию: адрес interface-server-identifier интерфейса сокета, адрес из
+// опции server-identifier, адрес интерфейса, на который пришел запрос,
+// адрес сокета, если он привязан к конкретному адресу, либо адрес
+// интерфейса, сеть которого содержит адрес клиента или ретранслятора
+func (s *BOOTPServer) serverIdentifier(conn *net.UDPConn, reply *BOOTPHeader, local net.IP) net.IP {
+	if override := s.interfaceOverride(s.connInterface(conn)); override != nil && override.serverID != nil {
+		return override.serverID
+	}
+
+	s.mutex.Lock()
+	id, _ := parseServerIdentifier(s.config.GlobalOptions)
+	s.mutex.Unlock()
+	if id != nil {
+		return id
+	}
+
+	if local = local.To4(); local != nil && !local.IsUnspecified() && !local.Equal(net.IPv4bcast) {
+		return local
+	}
+
+	if addr := localUDPAddr(conn); !addr.IP.IsUnspecified() {
+		return addr.IP.To4()
+	}
+
+	target := net.IP(reply.Giaddr[:])
+	if target.IsUnspecified() {
+		target = net.IP(reply.Yiaddr[:])
+	}
+	return interfaceAddress(s.connInterface(conn), target)
+}
+
+// connInterface возвращает имя интерфейса, к которому привязан сокет,
+// или пустую строку для сокета на всех интерфейсах
+func (s *BOOTPServer) connInterface(conn *net.UDPConn) string {
+	for i, c := range s.conns {
+		if c == conn && i < len(s.interfaces) {
+			return s.interfaces[i]
+		}
+	}
+	return ""
+}
+
+// interfaceAddress возвращает IPv4 адрес интерфейса name (или любого
+// интерфейса, кроме loopback, если name пусто), сеть которого содержит
+// target. Если такой сети нет, возвращается первый найденный адрес.
+func interfaceAddress(name string, target net.IP) net.IP {
+	if ipNet := interfaceNetwork(name, target); ipNet != nil {
+		return ipNet.IP.To4()
+	}
+	return nil
+}
+
+// interfaceNetwork возвращает IPv4 сеть интерфейса так же, как interfaceAddress
+func interfaceNetwork(name string, target net.IP) *net.IPNet {
+	var ifaces []net.Interface
+	if name != "" {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil
+		}
+		ifaces = []net.Interface{*iface}
+	} else {
+		var err error
+		if ifaces, err = net.Interfaces(); err != nil {
+			return nil
+		}
+	}
+
+	var fallback *net.IPNet
+	for _, iface := range ifaces {
+		if name == "" && iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if ipNet.Contains(target) {
+				return ipNet
+			}
+			if fallback == nil {
+				fallback = ipNet
+			}
+		}
+	}
+	return fallback
+}
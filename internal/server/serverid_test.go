@@ -0,0 +1,159 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseServerIdentifier(t *testing.T) {
+	if ip, err := parseServerIdentifier(map[string]string{}); ip != nil || err != nil {
+		t.Errorf("Expected no server identifier, got %v (%v)", ip, err)
+	}
+
+	ip, err := parseServerIdentifier(map[string]string{"server-identifier": "192.168.1.1"})
+	if err != nil || !ip.Equal(net.ParseIP("192.168.1.1")) {
+		t.Errorf("Expected 192.168.1.1, got %v (%v)", ip, err)
+	}
+
+	if _, err := parseServerIdentifier(map[string]string{"server-identifier": "dhcp.local"}); err == nil {
+		t.Error("Expected error for non-address server identifier")
+	}
+
+	cfg := &config.DHCPConfig{GlobalOptions: map[string]string{"server-identifier": "bad"}}
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected NewBOOTPServer to reject invalid server identifier")
+	}
+}
+
+func TestReplyOptions(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{GlobalOptions: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reply := &BOOTPHeader{Op: BOOTPReply, Htype: HTYPE_ETHER, Hlen: 6, Magic: magicCookie}
+
+	// BOOTP ответ не содержит DHCP опций
+	if options := server.replyOptions(conn, reply, 0, nil); options != nil {
+		t.Errorf("Expected no options for BOOTP reply, got %v", options)
+	}
+
+	// Идентификатор сервера берется из адреса сокета
+	options := server.replyOptions(conn, reply, DHCPDiscover, nil)
+	if msgType := messageType(options); msgType != DHCPOffer {
+		t.Errorf("Expected OFFER, got %d", msgType)
+	}
+	if id := net.IP(options[OptionServerIdentifier]); !id.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected server identifier 127.0.0.1, got %v", id)
+	}
+
+	// Заданный в конфигурации идентификатор имеет приоритет
+	server.config.GlobalOptions["server-identifier"] = "10.0.0.1"
+	options = server.replyOptions(conn, reply, DHCPRequest, nil)
+	if msgType := messageType(options); msgType != DHCPAck {
+		t.Errorf("Expected ACK, got %d", msgType)
+	}
+	if id := net.IP(options[OptionServerIdentifier]); !id.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("Expected server identifier 10.0.0.1, got %v", id)
+	}
+
+	// Ответ кодируется с опциями и разбирается обратно
+	data := EncodeReply(reply, options)
+	if len(data) < minReplySize {
+		t.Errorf("Expected reply of at least %d bytes, got %d", minReplySize, len(data))
+	}
+	packet, err := DecodePacket(data)
+	if err != nil {
+		t.Fatalf("DecodePacket failed: %v", err)
+	}
+	if id := net.IP(packet.Options[OptionServerIdentifier]); !id.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("Expected decoded server identifier 10.0.0.1, got %v", id)
+	}
+}
+
+func TestInterfaceAddressLoopback(t *testing.T) {
+	// На loopback интерфейсе адрес выбирается по сети клиента
+	loopback := ""
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+		}
+	}
+	if loopback == "" {
+		t.Skip("No loopback interface")
+	}
+
+	if ip := interfaceAddress(loopback, net.IPv4(127, 0, 0, 55)); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected 127.0.0.1, got %v", ip)
+	}
+	if ip := interfaceAddress("no-such-interface0", net.IPv4(127, 0, 0, 55)); ip != nil {
+		t.Errorf("Expected no address for missing interface, got %v", ip)
+	}
+}
+
+func TestServerIdentifierFromReceivingInterface(t *testing.T) {
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{},
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	// Сокет на всех адресах: адрес берется из интерфейса, на который пришел запрос
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	reply := &BOOTPHeader{Op: BOOTPReply}
+	if id := server.serverIdentifier(conn, reply, net.IPv4(192, 168, 1, 1)); !id.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Expected receiving address 192.168.1.1, got %v", id)
+	}
+	if id := server.serverIdentifier(conn, reply, net.IPv4bcast); id.Equal(net.IPv4bcast) {
+		t.Error("Broadcast destination must not be used as server identifier")
+	}
+
+	// Ответ без next-server получает siaddr сервера, NAK - нет
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	packet := &Packet{
+		Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, 1}},
+		Options: map[uint8][]byte{OptionMessageType: {DHCPDiscover}},
+	}
+	server.handlePacket(conn, packet, DHCPDiscover, client.LocalAddr().(*net.UDPAddr), net.IPv4(192, 168, 1, 1))
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, maxPacketSize)
+	n, err := client.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer, err := DecodePacket(buffer[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if siaddr := net.IP(offer.Header.Siaddr[:]); !siaddr.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Expected siaddr 192.168.1.1, got %v", siaddr)
+	}
+	if id := net.IP(offer.Options[OptionServerIdentifier]); !id.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Expected server identifier 192.168.1.1, got %v", id)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option параметр создания сервера (см. NewBOOTPServer)
+type Option func(s *BOOTPServer) error
+
+// WithListenAddress задает адрес и порт приема запросов вместо bootp-listen
+// из конфигурации. Порт 0 выбирает свободный порт, что позволяет запускать
+// сервер без прав на привилегированный порт (например, в тестах).
+func WithListenAddress(addr string) Option {
+	return func(s *BOOTPServer) error {
+		udpAddr, err := net.ResolveUDPAddr("udp4", addr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %s: %v", addr, err)
+		}
+		s.listen = udpAddr
+		return nil
+	}
+}
+
+// WithLogger задает журнал сервера вместо стандартного журнала logrus.
+// Встроенные TFTP и DNS серверы и хук выделения адресов пишут в
+// стандартный журнал.
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(s *BOOTPServer) error {
+		if logger == nil {
+			return fmt.Errorf("logger is nil")
+		}
+		s.logger = logger
+		return nil
+	}
+}
+
+// WithClock задает источник времени, по которому выдаются, продлеваются и
+// истекают аренды
+func WithClock(clock Clock) Option {
+	return func(s *BOOTPServer) error {
+		if clock == nil {
+			return fmt.Errorf("clock is nil")
+		}
+		s.clock = clock
+		return nil
+	}
+}
+
+// WithLeaseStore задает хранилище аренд: сохраненные аренды
+// восстанавливаются при создании сервера, изменения аренд сохраняются
+// во время работы (см. LeaseStore)
+func WithLeaseStore(store LeaseStore) Option {
+	return func(s *BOOTPServer) error {
+		s.store = store
+		return nil
+	}
+}
+
+// WithPacketConn задает уже открытые сокеты приема запросов (см.
+// SetListeners)
+func WithPacketConn(conns ...*net.UDPConn) Option {
+	return func(s *BOOTPServer) error {
+		s.conns = append(s.conns, conns...)
+		return nil
+	}
+}
+
+// WithLeaseDuration задает срок динамической аренды DHCP клиентов
+// (по умолчанию час). Операторы default-lease-time конфигурации имеют
+// приоритет.
+func WithLeaseDuration(duration time.Duration) Option {
+	return func(s *BOOTPServer) error {
+		if duration <= 0 {
+			return fmt.Errorf("invalid lease duration: %v", duration)
+		}
+		s.leaseTime = duration
+		return nil
+	}
+}
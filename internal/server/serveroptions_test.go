@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	addr, err := parseListenAddress(map[string]string{})
+	if err != nil || addr.Port != BOOTP_PORT || addr.IP != nil {
+		t.Errorf("Expected default :%d, got %v (%v)", BOOTP_PORT, addr, err)
+	}
+
+	addr, err = parseListenAddress(map[string]string{"bootp-listen": "\"127.0.0.1:1067\""})
+	if err != nil || addr.Port != 1067 || !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected 127.0.0.1:1067, got %v (%v)", addr, err)
+	}
+
+	for _, value := range []string{"127.0.0.1", "\"127.0.0.1:port\""} {
+		if _, err := parseListenAddress(map[string]string{"bootp-listen": value}); err == nil {
+			t.Errorf("Expected error for %s", value)
+		}
+		if err := ValidateConfig(&config.DHCPConfig{GlobalOptions: map[string]string{"bootp-listen": value}}); err == nil {
+			t.Errorf("Expected validation error for %s", value)
+		}
+	}
+
+	if _, err := NewBOOTPServer(&config.DHCPConfig{}, WithListenAddress("localhost:port")); err == nil {
+		t.Error("Expected error for invalid listen address option")
+	}
+}
+
+// bootpExchange отправляет запрос серверу и ждет ответ
+func bootpExchange(t *testing.T, client *net.UDPConn, server net.Addr, request *Packet) *Packet {
+	t.Helper()
+
+	data := EncodeReply(&request.Header, request.Options)
+	if _, err := client.WriteTo(data, server); err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buffer := make([]byte, maxPacketSize)
+	n, err := client.Read(buffer)
+	if err != nil {
+		t.Fatalf("No reply from server: %v", err)
+	}
+	reply, err := DecodePacket(buffer[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Header.Op != BOOTPReply || reply.Header.Xid != request.Header.Xid {
+		t.Fatalf("Unexpected reply %+v", reply.Header)
+	}
+	return reply
+}
+
+func TestEndToEndExchange(t *testing.T) {
+	// Конфигурация задает привилегированный порт, параметр переопределяет его
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		GlobalOptions: map[string]string{"bootp-listen": "\":67\""},
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}}},
+		},
+	}, WithListenAddress("127.0.0.1:0"))
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	if server.LocalAddr() != nil {
+		t.Error("Expected no local address before Start")
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	addr := server.LocalAddr().(*net.UDPAddr)
+	if addr.Port == 0 || addr.Port == BOOTP_PORT {
+		t.Fatalf("Expected ephemeral port, got %v", addr)
+	}
+
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// DISCOVER -> OFFER
+	discover := discoverPacket(1)
+	discover.Header.Xid = 0x1001
+	discover.Header.Magic = magicCookie
+	offer := bootpExchange(t, client, addr, discover)
+	if messageType(offer.Options) != DHCPOffer {
+		t.Fatalf("Expected OFFER, got %v", offer.Options[OptionMessageType])
+	}
+	yiaddr := net.IP(offer.Header.Yiaddr[:])
+	if !yiaddr.Equal(net.IPv4(192, 168, 1, 100)) {
+		t.Errorf("Expected 192.168.1.100 offered, got %v", yiaddr)
+	}
+
+	// REQUEST -> ACK
+	request := discoverPacket(1)
+	request.Header.Xid = 0x1002
+	request.Header.Magic = magicCookie
+	request.Options = map[uint8][]byte{
+		OptionMessageType:      {DHCPRequest},
+		OptionRequestedIP:      yiaddr.To4(),
+		OptionServerIdentifier: offer.Options[OptionServerIdentifier],
+	}
+	ack := bootpExchange(t, client, addr, request)
+	if messageType(ack.Options) != DHCPAck {
+		t.Fatalf("Expected ACK, got %v", ack.Options[OptionMessageType])
+	}
+	if !net.IP(ack.Header.Yiaddr[:]).Equal(yiaddr) {
+		t.Errorf("Expected %v acknowledged, got %v", yiaddr, net.IP(ack.Header.Yiaddr[:]))
+	}
+
+	if leases := server.Leases(); len(leases) != 1 || leases[0].IP != "192.168.1.100" {
+		t.Errorf("Expected one lease for 192.168.1.100, got %+v", leases)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	store := &memoryLeaseStore{leases: []Lease{
+		{IP: "192.168.1.105", MAC: "02:00:00:00:00:01", Type: "dynamic", Expires: now.Add(time.Minute)},
+		{IP: "192.168.1.106", MAC: "02:00:00:00:00:03", Type: "dynamic", Expires: now.Add(-time.Minute)},
+		{IP: "10.0.0.5", MAC: "02:00:00:00:00:04", Type: "dynamic", Expires: now.Add(time.Minute)},
+		{IP: "192.168.1.107", MAC: "02:00:00:00:00:05", Type: "static"},
+	}}
+	var output bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &output
+
+	server, err := NewBOOTPServer(&config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.1.0/24"), Ranges: []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}}},
+		},
+	}, WithClock(newFakeClock(now)), WithLeaseDuration(10*time.Minute), WithLeaseStore(store), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	if !strings.Contains(output.String(), "Restored 1 of 4 stored leases") {
+		t.Errorf("Expected restore to be logged to the server logger, got %q", output.String())
+	}
+
+	// Восстановленная аренда продолжается, новая выдается на заданный срок
+	tests := []struct {
+		mac  byte
+		want string
+	}{
+		{1, "192.168.1.105"},
+		{2, "192.168.1.100"},
+	}
+	for _, test := range tests {
+		reply := server.processPacket(discoverPacket(test.mac))
+		if reply == nil {
+			t.Fatalf("Expected reply for client %d", test.mac)
+		}
+		if yiaddr := net.IP(reply.Header.Yiaddr[:]).String(); yiaddr != test.want {
+			t.Errorf("Client %d: expected %s, got %s", test.mac, test.want, yiaddr)
+		}
+		if lease := binary.BigEndian.Uint32(reply.Options[OptionLeaseTime]); lease != 600 {
+			t.Errorf("Client %d: expected lease time 600, got %d", test.mac, lease)
+		}
+	}
+
+	// Аренда вне подсетей снимается и в хранилище
+	if len(store.events) != 3 || store.events[0].Type != LeaseReleased || store.events[2].Type != LeaseAllocated || !store.events[2].Time.Equal(now) {
+		t.Fatalf("Expected lease changes to be saved, got %+v", store.events)
+	}
+	if expires := store.events[2].Lease.Expires; !expires.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("Expected lease to expire at %v, got %v", now.Add(10*time.Minute), expires)
+	}
+
+	for _, opt := range []Option{WithClock(nil), WithLogger(nil), WithLeaseDuration(0)} {
+		if _, err := NewBOOTPServer(&config.DHCPConfig{}, opt); err == nil {
+			t.Error("Expected error for invalid option")
+		}
+	}
+}
+
+func TestWithPacketConn(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := NewBOOTPServer(&config.DHCPConfig{}, WithPacketConn(conn))
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop()
+
+	if server.LocalAddr().String() != conn.LocalAddr().String() {
+		t.Errorf("Expected server to use %v, got %v", conn.LocalAddr(), server.LocalAddr())
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Service встроенный сервер, работающий рядом с BOOTP сервером: TFTP,
+// HTTP boot или DNS. Start BOOTP сервера их не запускает: демон (см.
+// internal/daemon) запускает и останавливает каждый как отдельный модуль.
+type Service struct {
+	Name  string
+	Start func() error
+	Stop  func()
+}
+
+// Services возвращает встроенные серверы, настроенные в конфигурации
+func (s *BOOTPServer) Services() []Service {
+	var services []Service
+	if strings.Trim(s.config.GlobalOptions["tftp-root"], "\"") != "" {
+		services = append(services, Service{Name: "TFTP server", Start: s.startTFTP, Stop: s.stopTFTP})
+	}
+	if strings.Trim(s.config.GlobalOptions["http-boot-root"], "\"") != "" || ipxeScriptPath(s.config.GlobalOptions) != "" {
+		services = append(services, Service{Name: "HTTP boot server", Start: s.startHTTPBoot, Stop: s.stopHTTPBoot})
+	}
+	if strings.Trim(s.config.GlobalOptions["dns-listen"], "\"") != "" {
+		services = append(services, Service{Name: "DNS server", Start: s.startDNS, Stop: s.stopDNS})
+	}
+	return services
+}
+
+// fetchObserver записывает скачивание загрузочного файла в хронологию
+// загрузки клиента
+func (s *BOOTPServer) fetchObserver(clientIP, proto, filename string, err error) {
+	s.timeline.RecordFetch(clientIP, proto, filename, err)
+}
+
+// startTFTP запускает встроенный TFTP сервер (tftp-root, tftp-listen)
+func (s *BOOTPServer) startTFTP() error {
+	root := strings.Trim(s.config.GlobalOptions["tftp-root"], "\"")
+	listen := strings.Trim(s.config.GlobalOptions["tftp-listen"], "\"")
+	if listen == "" {
+		listen = ":69"
+	}
+	s.tftp = NewTFTPServer(root, s.fetchObserver)
+	if err := s.tftp.Start(listen); err != nil {
+		s.tftp = nil
+		return permissionError(err, capNetBindService, "TFTP server")
+	}
+	return nil
+}
+
+func (s *BOOTPServer) stopTFTP() {
+	if s.tftp != nil {
+		s.tftp.Stop()
+		s.tftp = nil
+	}
+}
+
+// startHTTPBoot запускает встроенный HTTP сервер загрузочных файлов
+// (http-boot-root) и сценариев iPXE (ipxe-script-path)
+func (s *BOOTPServer) startHTTPBoot() error {
+	root := strings.Trim(s.config.GlobalOptions["http-boot-root"], "\"")
+	script := ipxeScriptPath(s.config.GlobalOptions)
+	listen := strings.Trim(s.config.GlobalOptions["http-boot-listen"], "\"")
+	if listen == "" {
+		listen = ":8080"
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return permissionError(err, capNetBindService, "HTTP boot server")
+	}
+	handler := http.NotFoundHandler()
+	if root != "" {
+		handler = NewHTTPBootHandler(root, s.fetchObserver)
+		s.logger.Infof("HTTP boot server listening on %s, serving %s", listener.Addr().String(), root)
+	}
+	if script != "" {
+		handler = s.ipxeScriptHandler(script, s.fetchObserver, handler)
+		s.logger.Infof("HTTP boot server listening on %s, serving iPXE scripts at %s", listener.Addr().String(), script)
+	}
+	s.httpBoot = &http.Server{Handler: handler}
+	go s.httpBoot.Serve(listener)
+	return nil
+}
+
+func (s *BOOTPServer) stopHTTPBoot() {
+	if s.httpBoot != nil {
+		s.httpBoot.Close()
+		s.httpBoot = nil
+	}
+}
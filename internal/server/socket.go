@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// defaultReadBatch количество запросов, читаемых одним вызовом recvmmsg
+const defaultReadBatch = 16
+
+// socketTuning настройки сокетов приема запросов для нагрузки, когда
+// одновременно загружаются сотни машин
+type socketTuning struct {
+	receiveBuffer int // SO_RCVBUF в байтах (0 - по умолчанию системы)
+	sendBuffer    int // SO_SNDBUF в байтах (0 - по умолчанию системы)
+	readBatch     int // Запросов за один вызов recvmmsg (Linux), 1 - по одному
+}
+
+// parseSocketTuning читает глобальные опции socket-receive-buffer,
+// socket-send-buffer и read-batch-size
+func parseSocketTuning(options map[string]string) (socketTuning, error) {
+	tuning := socketTuning{readBatch: defaultReadBatch}
+	for _, option := range []struct {
+		name  string
+		value *int
+	}{
+		{"socket-receive-buffer", &tuning.receiveBuffer},
+		{"socket-send-buffer", &tuning.sendBuffer},
+		{"read-batch-size", &tuning.readBatch},
+	} {
+		value, ok := options[option.name]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return socketTuning{}, fmt.Errorf("invalid %s: %s", option.name, value)
+		}
+		*option.value = n
+	}
+	return tuning, nil
+}
+
+// apply задает размеры буферов сокета. Ядро может ограничить их
+// (в Linux - net.core.rmem_max и net.core.wmem_max).
+func (t socketTuning) apply(conn *net.UDPConn) error {
+	if t.receiveBuffer > 0 {
+		if err := conn.SetReadBuffer(t.receiveBuffer); err != nil {
+			return fmt.Errorf("set receive buffer: %v", err)
+		}
+	}
+	if t.sendBuffer > 0 {
+		if err := conn.SetWriteBuffer(t.sendBuffer); err != nil {
+			return fmt.Errorf("set send buffer: %v", err)
+		}
+	}
+	return nil
+}
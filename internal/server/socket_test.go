@@ -0,0 +1,29 @@
+package server
+
+import "testing"
+
+func TestParseSocketTuning(t *testing.T) {
+	tuning, err := parseSocketTuning(map[string]string{})
+	if err != nil || tuning != (socketTuning{readBatch: defaultReadBatch}) {
+		t.Errorf("Unexpected defaults: %+v, %v", tuning, err)
+	}
+
+	tuning, err = parseSocketTuning(map[string]string{
+		"socket-receive-buffer": "4194304",
+		"socket-send-buffer":    "1048576",
+		"read-batch-size":       "1",
+	})
+	if err != nil || tuning != (socketTuning{receiveBuffer: 4194304, sendBuffer: 1048576, readBatch: 1}) {
+		t.Errorf("Unexpected tuning: %+v, %v", tuning, err)
+	}
+
+	for _, options := range []map[string]string{
+		{"socket-receive-buffer": "0"},
+		{"socket-send-buffer": "1M"},
+		{"read-batch-size": "-4"},
+	} {
+		if _, err := parseSocketTuning(options); err == nil {
+			t.Errorf("Expected error for %v", options)
+		}
+	}
+}
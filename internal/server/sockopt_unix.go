@@ -0,0 +1,24 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"syscall"
+)
+
+// setSocketOption включает целочисленную опцию сокета
+func setSocketOption(conn *net.UDPConn, level, option int) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), level, option, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Counters счетчики обработки запросов и событий аренд с момента запуска
+type Counters struct {
+	Requests     uint64 `json:"requests"`      // Принятых запросов
+	Offers       uint64 `json:"offers"`        // Отправленных DHCPOFFER
+	Acks         uint64 `json:"acks"`          // Отправленных DHCPACK
+	Naks         uint64 `json:"naks"`          // Отправленных DHCPNAK
+	BOOTPReplies uint64 `json:"bootp_replies"` // Ответов BOOTP клиентам
+	Ignored      uint64 `json:"ignored"`       // Запросов, оставшихся без ответа
+	Allocations  uint64 `json:"allocations"`   // Выданных адресов
+	Renewals     uint64 `json:"renewals"`      // Продлений аренды
+	Releases     uint64 `json:"releases"`      // Освобожденных назначений
+	Expirations  uint64 `json:"expirations"`   // Истекших аренд
+	Conflicts    uint64 `json:"conflicts"`     // Конфликтов адресов, найденных сканированием ARP
+	Retransmits  uint64 `json:"retransmits"`   // Повторных запросов, получивших сохраненный ответ
+	Dropped      uint64 `json:"dropped"`       // Запросов, отброшенных ядром при переполнении буфера приема (Linux)
+	Deferred     uint64 `json:"deferred"`      // Запросов, оставленных без ответа по min-secs (входят в Ignored)
+
+	UnknownRelays uint64 `json:"unknown_relays"` // Запросов от релеев не из allowed-relays (при drop входят в Ignored)
+	PoolAlerts    uint64 `json:"pool_alerts"`    // Снижений свободных адресов пула ниже pool-low-watermark
+	Timeouts      uint64 `json:"timeouts"`       // Запросов, не обработанных за request-timeout (входят в Ignored)
+}
+
+// VendorClassCounters счетчики запросов и предложений клиентов одного
+// класса производителя (опция 60)
+type VendorClassCounters struct {
+	Class    string `json:"class"`
+	Requests uint64 `json:"requests"`
+	Offers   uint64 `json:"offers"`
+}
+
+// Stats сводная статистика сервера для планирования емкости
+type Stats struct {
+	Started   time.Time      `json:"started"`
+	Subnets   []SubnetUsage  `json:"subnets"`
+	Counters  Counters       `json:"counters"`
+	RateLimit RateLimitStats `json:"rate_limit"`
+
+	VendorClasses []VendorClassCounters `json:"vendor_classes"` // Классы, от которых были запросы
+}
+
+// vendorClasses классы производителя, которые учитываются отдельно.
+// Класс определяется по началу опции 60, iPXE - по классу пользователя
+// (опция 77). Остальные значения учитываются как other, запросы без
+// опции 60 - как none, поэтому число счетчиков не зависит от клиентов.
+var vendorClasses = [...]string{"PXEClient", "HTTPClient", "iPXE", "udhcp", "MSFT 5.0", "dhcpcd", "android-dhcp"}
+
+// Индексы счетчиков классов, не входящих в vendorClasses
+const (
+	vendorClassOther = len(vendorClasses)
+	vendorClassNone  = len(vendorClasses) + 1
+)
+
+// vendorClassIndex возвращает индекс счетчиков класса клиента по опциям запроса
+func vendorClassIndex(options map[uint8][]byte) int {
+	value := string(options[OptionVendorClass])
+	if bytes.Equal(options[OptionUserClass], []byte("iPXE")) {
+		value = "iPXE"
+	}
+	if value == "" {
+		return vendorClassNone
+	}
+	for i, class := range vendorClasses {
+		if strings.HasPrefix(value, class) {
+			return i
+		}
+	}
+	return vendorClassOther
+}
+
+// vendorClassName возвращает имя класса по индексу счетчиков
+func vendorClassName(index int) string {
+	switch index {
+	case vendorClassOther:
+		return "other"
+	case vendorClassNone:
+		return "none"
+	}
+	return vendorClasses[index]
+}
+
+// counters атомарные счетчики, см. Counters
+type counters struct {
+	requests, offers, acks, naks, bootpReplies, ignored atomic.Uint64
+	allocations, renewals, releases, expirations        atomic.Uint64
+
+	conflicts, retransmits, dropped, deferred atomic.Uint64
+	unknownRelays, poolAlerts, timeouts       atomic.Uint64
+
+	vendorRequests, vendorOffers [len(vendorClasses) + 2]atomic.Uint64 // По индексам vendorClassIndex
+}
+
+// snapshot возвращает текущие значения счетчиков
+func (c *counters) snapshot() Counters {
+	return Counters{
+		Requests:     c.requests.Load(),
+		Offers:       c.offers.Load(),
+		Acks:         c.acks.Load(),
+		Naks:         c.naks.Load(),
+		BOOTPReplies: c.bootpReplies.Load(),
+		Ignored:      c.ignored.Load(),
+		Allocations:  c.allocations.Load(),
+		Renewals:     c.renewals.Load(),
+		Releases:     c.releases.Load(),
+		Expirations:  c.expirations.Load(),
+		Conflicts:    c.conflicts.Load(),
+		Retransmits:  c.retransmits.Load(),
+		Dropped:      c.dropped.Load(),
+		Deferred:     c.deferred.Load(),
+
+		UnknownRelays: c.unknownRelays.Load(),
+		PoolAlerts:    c.poolAlerts.Load(),
+		Timeouts:      c.timeouts.Load(),
+	}
+}
+
+// vendorSnapshot возвращает счетчики классов, от которых были запросы
+func (c *counters) vendorSnapshot() []VendorClassCounters {
+	result := []VendorClassCounters{}
+	for i := range c.vendorRequests {
+		if requests := c.vendorRequests[i].Load(); requests > 0 {
+			result = append(result, VendorClassCounters{Class: vendorClassName(i), Requests: requests, Offers: c.vendorOffers[i].Load()})
+		}
+	}
+	return result
+}
+
+// countReply учитывает ответ по его типу (0 - BOOTP ответ)
+func (c *counters) countReply(replyType uint8) {
+	switch replyType {
+	case DHCPOffer:
+		c.offers.Add(1)
+	case DHCPAck:
+		c.acks.Add(1)
+	case DHCPNak:
+		c.naks.Add(1)
+	default:
+		c.bootpReplies.Add(1)
+	}
+}
+
+// countLeaseEvent учитывает событие аренды
+func (c *counters) countLeaseEvent(eventType LeaseEventType) {
+	switch eventType {
+	case LeaseAllocated:
+		c.allocations.Add(1)
+	case LeaseRenewed:
+		c.renewals.Add(1)
+	case LeaseReleased:
+		c.releases.Add(1)
+	case LeaseExpired:
+		c.expirations.Add(1)
+	}
+}
+
+// Stats возвращает заполненность пулов и счетчики с момента запуска
+func (s *BOOTPServer) Stats() Stats {
+	return Stats{
+		Started:   s.started,
+		Subnets:   s.SubnetUtilization(),
+		Counters:  s.counters.snapshot(),
+		RateLimit: s.RateLimitStats(),
+
+		VendorClasses: s.counters.vendorSnapshot(),
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsCounters(t *testing.T) {
+	server := newAdminTestServer(t) // Диапазон 192.168.1.100-110, статический 192.168.1.10
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clientAddr := conn.LocalAddr().(*net.UDPAddr)
+
+	send := func(mac byte, msgType uint8) {
+		packet := &Packet{
+			Header:  BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Chaddr: [16]byte{0x02, 0, 0, 0, 0, mac}},
+			Options: map[uint8][]byte{},
+		}
+		if msgType != 0 {
+			packet.Options[OptionMessageType] = []byte{msgType}
+		}
+		server.handlePacket(conn, packet, msgType, clientAddr, nil)
+	}
+
+	send(1, DHCPDiscover)
+	send(1, DHCPRequest)
+	send(2, 0)
+
+	// Адрес, ответивший на ICMP проверку, исключается из свободных
+	server.mutex.Lock()
+	server.conflicts[ipToInt(net.ParseIP("192.168.1.110"))] = time.Now().Add(time.Hour)
+	server.mutex.Unlock()
+
+	stats := server.Stats()
+	c := stats.Counters
+	if c.Requests != 3 || c.Offers != 1 || c.Acks != 1 || c.BOOTPReplies != 1 || c.Naks != 0 || c.Ignored != 0 {
+		t.Errorf("Unexpected request counters: %+v", c)
+	}
+	if c.Allocations != 2 {
+		t.Errorf("Expected 2 allocations, got %+v", c)
+	}
+	if stats.Started.IsZero() {
+		t.Error("Expected start time to be set")
+	}
+
+	if len(stats.Subnets) != 1 {
+		t.Fatalf("Expected 1 subnet, got %d", len(stats.Subnets))
+	}
+	if u := stats.Subnets[0]; u.Size != 11 || u.Active != 2 || u.Static != 1 || u.Abandoned != 1 || u.Free != 8 {
+		t.Errorf("Unexpected usage: %+v", u)
+	}
+}
+
+func TestVendorClassIndex(t *testing.T) {
+	tests := []struct {
+		options map[uint8][]byte
+		want    string
+	}{
+		{map[uint8][]byte{OptionVendorClass: []byte("PXEClient:Arch:00007:UNDI:003016")}, "PXEClient"},
+		{map[uint8][]byte{OptionVendorClass: []byte("PXEClient:Arch:00007:UNDI:003016"), OptionUserClass: []byte("iPXE")}, "iPXE"},
+		{map[uint8][]byte{OptionVendorClass: []byte("udhcp 1.36.1")}, "udhcp"},
+		{map[uint8][]byte{OptionVendorClass: []byte("MSFT 5.0")}, "MSFT 5.0"},
+		{map[uint8][]byte{OptionVendorClass: []byte("Cisco Systems, Inc. IP Phone")}, "other"},
+		{map[uint8][]byte{}, "none"},
+	}
+	for _, test := range tests {
+		if class := vendorClassName(vendorClassIndex(test.options)); class != test.want {
+			t.Errorf("%q: expected %s, got %s", test.options[OptionVendorClass], test.want, class)
+		}
+	}
+}
+
+func TestVendorClassCounters(t *testing.T) {
+	server := newAdminTestServer(t)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(mac byte, msgType uint8, vendorClass string) {
+		packet := discoverPacket(mac)
+		packet.Options[OptionMessageType] = []byte{msgType}
+		if vendorClass != "" {
+			packet.Options[OptionVendorClass] = []byte(vendorClass)
+		}
+		server.handlePacket(conn, packet, msgType, conn.LocalAddr().(*net.UDPAddr), nil)
+	}
+
+	if stats := server.Stats(); len(stats.VendorClasses) != 0 {
+		t.Errorf("Expected no vendor classes before requests, got %+v", stats.VendorClasses)
+	}
+
+	send(1, DHCPDiscover, "PXEClient:Arch:00000:UNDI:002001")
+	send(1, DHCPRequest, "PXEClient:Arch:00000:UNDI:002001")
+	send(2, DHCPDiscover, "udhcp 1.36.1")
+	send(3, DHCPDiscover, "")
+
+	want := []VendorClassCounters{
+		{Class: "PXEClient", Requests: 2, Offers: 1},
+		{Class: "udhcp", Requests: 1, Offers: 1},
+		{Class: "none", Requests: 1, Offers: 1},
+	}
+	if got := server.Stats().VendorClasses; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
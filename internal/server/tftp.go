@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Коды операций TFTP (RFC 1350)
+const (
+	tftpOpRRQ   = 1
+	tftpOpWRQ   = 2
+	tftpOpData  = 3
+	tftpOpAck   = 4
+	tftpOpError = 5
+)
+
+// Коды ошибок TFTP
+const (
+	tftpErrNotDefined = 0
+	tftpErrNotFound   = 1
+	tftpErrAccess     = 2
+	tftpErrIllegalOp  = 4
+)
+
+const (
+	tftpBlockSize = 512
+	tftpTimeout   = 3 * time.Second
+	tftpRetries   = 5
+
+	// Ограничение одновременных передач: каждая занимает горутину и сокет
+	maxTFTPTransfers = 64
+)
+
+// FetchObserver получает уведомления о скачивании файлов клиентами
+type FetchObserver func(clientIP string, proto string, filename string, err error)
+
+// TFTPServer встроенный TFTP сервер только для чтения
+type TFTPServer struct {
+	root     string
+	conn     *net.UDPConn
+	observer FetchObserver
+	slots    chan struct{} // Семафор активных передач
+}
+
+// NewTFTPServer создает TFTP сервер, отдающий файлы из каталога root
+func NewTFTPServer(root string, observer FetchObserver) *TFTPServer {
+	return &TFTPServer{root: root, observer: observer, slots: make(chan struct{}, maxTFTPTransfers)}
+}
+
+// Start запускает TFTP сервер на указанном адресе
+func (t *TFTPServer) Start(listen string) error {
+	addr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		return err
+	}
+
+	t.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("TFTP server listening on %s, serving %s", t.conn.LocalAddr().String(), t.root)
+
+	go t.handleRequests()
+
+	return nil
+}
+
+// Stop останавливает TFTP сервер
+func (t *TFTPServer) Stop() {
+	if t.conn != nil {
+		t.conn.Close()
+	}
+}
+
+// handleRequests принимает запросы на чтение
+func (t *TFTPServer) handleRequests() {
+	buffer := make([]byte, 1024)
+
+	for {
+		n, clientAddr, err := t.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			logrus.Errorf("Error reading TFTP message: %v", err)
+			continue
+		}
+
+		if n < 4 {
+			continue
+		}
+
+		opcode := binary.BigEndian.Uint16(buffer[:2])
+		if opcode != tftpOpRRQ {
+			t.sendError(t.conn, clientAddr, tftpErrIllegalOp, "only read requests are supported")
+			continue
+		}
+
+		// RRQ: имя файла и режим, каждое завершается нулевым байтом
+		fields := bytes.Split(buffer[2:n], []byte{0})
+		if len(fields) < 2 {
+			t.sendError(t.conn, clientAddr, tftpErrNotDefined, "malformed request")
+			continue
+		}
+
+		select {
+		case t.slots <- struct{}{}:
+		default:
+			logrus.Warnf("Too many TFTP transfers, rejecting request from %s", clientAddr)
+			t.sendError(t.conn, clientAddr, tftpErrNotDefined, "server busy")
+			continue
+		}
+
+		go func(clientAddr *net.UDPAddr, filename string) {
+			defer func() { <-t.slots }()
+			t.transfer(clientAddr, filename)
+		}(clientAddr, string(fields[0]))
+	}
+}
+
+// transfer передает файл клиенту с отдельного порта, как требует RFC 1350
+func (t *TFTPServer) transfer(clientAddr *net.UDPAddr, filename string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: t.conn.LocalAddr().(*net.UDPAddr).IP})
+	if err != nil {
+		logrus.Errorf("Error opening TFTP transfer socket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	clientIP := clientAddr.IP.String()
+
+	path, err := t.resolvePath(filename)
+	if err != nil {
+		t.sendError(conn, clientAddr, tftpErrAccess, err.Error())
+		t.notify(clientIP, filename, err)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.sendError(conn, clientAddr, tftpErrNotFound, "file not found")
+		t.notify(clientIP, filename, err)
+		return
+	}
+	defer file.Close()
+
+	logrus.Debugf("TFTP transfer of %s to %s", filename, clientAddr)
+
+	data := make([]byte, tftpBlockSize)
+	packet := make([]byte, 4+tftpBlockSize)
+	ack := make([]byte, 4)
+
+	for block := uint16(1); ; block++ {
+		n, err := io.ReadFull(file, data)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			t.sendError(conn, clientAddr, tftpErrNotDefined, "read error")
+			t.notify(clientIP, filename, err)
+			return
+		}
+
+		binary.BigEndian.PutUint16(packet[0:2], tftpOpData)
+		binary.BigEndian.PutUint16(packet[2:4], block)
+		copy(packet[4:], data[:n])
+
+		if err := t.sendBlock(conn, clientAddr, packet[:4+n], ack, block); err != nil {
+			t.notify(clientIP, filename, err)
+			return
+		}
+
+		// Блок короче 512 байт завершает передачу
+		if n < tftpBlockSize {
+			t.notify(clientIP, filename, nil)
+			return
+		}
+	}
+}
+
+// sendBlock отправляет блок и ждет подтверждения с повторами
+func (t *TFTPServer) sendBlock(conn *net.UDPConn, clientAddr *net.UDPAddr, packet, ack []byte, block uint16) error {
+	for attempt := 0; attempt < tftpRetries; attempt++ {
+		if _, err := conn.WriteToUDP(packet, clientAddr); err != nil {
+			return err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(tftpTimeout))
+		for {
+			n, addr, err := conn.ReadFromUDP(ack)
+			if err != nil {
+				break // Таймаут - повторяем отправку
+			}
+			if !addr.IP.Equal(clientAddr.IP) || addr.Port != clientAddr.Port || n < 4 {
+				continue
+			}
+			opcode := binary.BigEndian.Uint16(ack[0:2])
+			if opcode == tftpOpError {
+				return errors.New("transfer aborted by client")
+			}
+			if opcode == tftpOpAck && binary.BigEndian.Uint16(ack[2:4]) == block {
+				return nil
+			}
+		}
+	}
+
+	return errors.New("transfer timed out")
+}
+
+// resolvePath преобразует имя файла в путь внутри корневого каталога
+func (t *TFTPServer) resolvePath(filename string) (string, error) {
+	cleaned := filepath.Clean("/" + strings.ReplaceAll(filename, "\\", "/"))
+	path := filepath.Join(t.root, cleaned)
+	if !strings.HasPrefix(path, filepath.Clean(t.root)+string(filepath.Separator)) {
+		return "", errors.New("access violation")
+	}
+	return path, nil
+}
+
+// sendError отправляет клиенту пакет ошибки
+func (t *TFTPServer) sendError(conn *net.UDPConn, clientAddr *net.UDPAddr, code uint16, message string) {
+	packet := make([]byte, 4, 5+len(message))
+	binary.BigEndian.PutUint16(packet[0:2], tftpOpError)
+	binary.BigEndian.PutUint16(packet[2:4], code)
+	packet = append(packet, message...)
+	packet = append(packet, 0)
+	conn.WriteToUDP(packet, clientAddr)
+}
+
+// notify сообщает наблюдателю о результате передачи
+func (t *TFTPServer) notify(clientIP, filename string, err error) {
+	if err != nil {
+		logrus.Warnf("TFTP transfer of %s to %s failed: %v", filename, clientIP, err)
+	}
+	if t.observer != nil {
+		t.observer(clientIP, "tftp", filename, err)
+	}
+}
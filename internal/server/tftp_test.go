@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tftpFetch скачивает файл с TFTP сервера простым клиентом
+func tftpFetch(t *testing.T, server string, filename string) ([]byte, uint16) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := []byte{0, tftpOpRRQ}
+	request = append(request, filename...)
+	request = append(request, 0)
+	request = append(request, "octet"...)
+	request = append(request, 0)
+	if _, err := conn.WriteToUDP(request, serverAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []byte
+	buffer := make([]byte, 4+tftpBlockSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			t.Fatalf("Error reading TFTP reply: %v", err)
+		}
+
+		opcode := binary.BigEndian.Uint16(buffer[:2])
+		if opcode == tftpOpError {
+			return nil, binary.BigEndian.Uint16(buffer[2:4])
+		}
+
+		block := binary.BigEndian.Uint16(buffer[2:4])
+		result = append(result, buffer[4:n]...)
+
+		ack := []byte{0, tftpOpAck, 0, 0}
+		binary.BigEndian.PutUint16(ack[2:4], block)
+		conn.WriteToUDP(ack, addr)
+
+		if n-4 < tftpBlockSize {
+			return result, 0
+		}
+	}
+}
+
+func TestTFTPServerTransfer(t *testing.T) {
+	root := t.TempDir()
+
+	// Файл больше одного блока
+	content := bytes.Repeat([]byte("0123456789"), 120)
+	if err := os.WriteFile(filepath.Join(root, "pxelinux.0"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mutex sync.Mutex
+	var fetched []string
+	observer := func(clientIP, proto, filename string, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err == nil {
+			fetched = append(fetched, proto+":"+filename)
+		}
+	}
+
+	server := NewTFTPServer(root, observer)
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start TFTP server: %v", err)
+	}
+	defer server.Stop()
+
+	data, code := tftpFetch(t, server.conn.LocalAddr().String(), "pxelinux.0")
+	if code != 0 {
+		t.Fatalf("Expected successful transfer, got error code %d", code)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("Expected %d bytes, got %d", len(content), len(data))
+	}
+
+	// Ждем уведомления наблюдателя
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		mutex.Lock()
+		n := len(fetched)
+		mutex.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(fetched) != 1 || fetched[0] != "tftp:pxelinux.0" {
+		t.Errorf("Expected observer to see tftp:pxelinux.0, got %v", fetched)
+	}
+}
+
+func TestTFTPServerErrors(t *testing.T) {
+	root := t.TempDir()
+
+	server := NewTFTPServer(root, nil)
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start TFTP server: %v", err)
+	}
+	defer server.Stop()
+
+	if _, code := tftpFetch(t, server.conn.LocalAddr().String(), "missing.efi"); code != tftpErrNotFound {
+		t.Errorf("Expected not found error, got %d", code)
+	}
+}
+
+func TestTFTPServerBusy(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "pxelinux.0"), []byte("boot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewTFTPServer(root, nil)
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start TFTP server: %v", err)
+	}
+	defer server.Stop()
+
+	// Все слоты заняты - новый запрос отклоняется
+	for i := 0; i < maxTFTPTransfers; i++ {
+		server.slots <- struct{}{}
+	}
+	if data, code := tftpFetch(t, server.conn.LocalAddr().String(), "pxelinux.0"); data != nil || code != tftpErrNotDefined {
+		t.Errorf("Expected busy error, got %d bytes, code %d", len(data), code)
+	}
+
+	// После освобождения слота передача проходит
+	<-server.slots
+	if data, code := tftpFetch(t, server.conn.LocalAddr().String(), "pxelinux.0"); string(data) != "boot" || code != 0 {
+		t.Errorf("Expected successful transfer, got %q, code %d", data, code)
+	}
+}
+
+func TestTFTPResolvePath(t *testing.T) {
+	server := NewTFTPServer("/srv/tftp", nil)
+
+	path, err := server.resolvePath("../../etc/passwd")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Выход за пределы корня невозможен - путь очищается
+	if path != "/srv/tftp/etc/passwd" {
+		t.Errorf("Expected /srv/tftp/etc/passwd, got %s", path)
+	}
+
+	path, err = server.resolvePath("pxelinux.cfg\\default")
+	if err != nil || path != "/srv/tftp/pxelinux.cfg/default" {
+		t.Errorf("Expected /srv/tftp/pxelinux.cfg/default, got %s (%v)", path, err)
+	}
+
+	if _, err := server.resolvePath("/"); err == nil {
+		t.Error("Expected error for root directory")
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// BootStage этап сетевой загрузки клиента
+type BootStage string
+
+const (
+	StageDiscover    BootStage = "discover"    // Получен DHCPDISCOVER
+	StageOffer       BootStage = "offer"       // Отправлен ответ на DHCPDISCOVER
+	StageRequest     BootStage = "request"     // Получен DHCPREQUEST
+	StageAck         BootStage = "ack"         // Отправлен ответ на DHCPREQUEST или DHCPINFORM
+	StageNak         BootStage = "nak"         // Отправлен отказ на DHCPREQUEST
+	StageInform      BootStage = "inform"      // Получен DHCPINFORM
+	StageBootRequest BootStage = "bootp"       // Получен BOOTP запрос без типа DHCP
+	StageBootReply   BootStage = "bootp-reply" // Отправлен BOOTP ответ
+	StageFileFetch   BootStage = "file-fetch"  // Загрузочный файл успешно скачан
+	StageFileError   BootStage = "file-error"  // Ошибка при скачивании файла
+)
+
+// Ограничения хронологии загрузки
+const (
+	maxBootEvents   = 32   // Событий, хранимых для одного клиента
+	maxBootClients  = 4096 // Клиентов; дольше всех неактивные вытесняются
+	maxRecentEvents = 1024 // Последних событий всех клиентов
+)
+
+// BootEvent событие в хронологии загрузки клиента
+type BootEvent struct {
+	Time   time.Time `json:"time"`
+	Stage  BootStage `json:"stage"`
+	MAC    string    `json:"mac"`
+	IP     string    `json:"ip,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// clientTimeline хронология одного клиента
+type clientTimeline struct {
+	mac    string
+	events []BootEvent
+	ips    map[string]bool // Адреса клиента в сопоставлении byIP
+}
+
+// BootTimeline хранит хронологию загрузки клиентов и сопоставляет
+// скачивания файлов по TFTP/HTTP с DHCP транзакциями по IP адресу.
+// Количество клиентов ограничено: при переполнении вытесняется клиент
+// с самым давним событием.
+type BootTimeline struct {
+	mutex   sync.Mutex
+	clients map[string]*list.Element // Ключ - MAC адрес, значение - *clientTimeline
+	order   *list.List               // Клиенты от недавно активных к давно неактивным
+	byIP    map[string]string        // IP адрес -> MAC адрес
+	recent  []BootEvent              // Кольцевой буфер последних событий всех клиентов
+	next    int                      // Позиция следующей записи в recent
+}
+
+// NewBootTimeline создает пустую хронологию
+func NewBootTimeline() *BootTimeline {
+	return &BootTimeline{
+		clients: make(map[string]*list.Element),
+		order:   list.New(),
+		byIP:    make(map[string]string),
+		recent:  make([]BootEvent, 0, maxRecentEvents),
+	}
+}
+
+// Record добавляет событие DHCP транзакции
+func (t *BootTimeline) Record(mac, ip string, stage BootStage, detail string) {
+	mac = normalizeMAC(mac)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	client := t.append(BootEvent{Time: time.Now(), Stage: stage, MAC: mac, IP: ip, Detail: detail})
+	if ip != "" {
+		t.byIP[ip] = mac
+		client.ips[ip] = true
+	}
+}
+
+// RecordFetch добавляет событие скачивания файла клиентом с адресом ip.
+// Возвращает false, если адрес не удалось сопоставить с DHCP транзакцией.
+func (t *BootTimeline) RecordFetch(ip string, proto string, filename string, err error) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	mac, ok := t.byIP[ip]
+	if !ok {
+		return false
+	}
+
+	event := BootEvent{Time: time.Now(), Stage: StageFileFetch, MAC: mac, IP: ip, Detail: proto + ":" + filename}
+	if err != nil {
+		event.Stage = StageFileError
+		event.Detail += ": " + err.Error()
+	}
+	t.append(event)
+
+	return true
+}
+
+// append добавляет событие, отбрасывая самые старые при переполнении
+func (t *BootTimeline) append(event BootEvent) *clientTimeline {
+	var client *clientTimeline
+	if element, ok := t.clients[event.MAC]; ok {
+		client = element.Value.(*clientTimeline)
+		t.order.MoveToFront(element)
+	} else {
+		client = &clientTimeline{mac: event.MAC, ips: make(map[string]bool)}
+		t.clients[event.MAC] = t.order.PushFront(client)
+		if t.order.Len() > maxBootClients {
+			t.evict(t.order.Back())
+		}
+	}
+
+	client.events = append(client.events, event)
+	if len(client.events) > maxBootEvents {
+		client.events = client.events[len(client.events)-maxBootEvents:]
+	}
+
+	if len(t.recent) < maxRecentEvents {
+		t.recent = append(t.recent, event)
+	} else {
+		t.recent[t.next] = event
+	}
+	t.next = (t.next + 1) % maxRecentEvents
+
+	return client
+}
+
+// evict удаляет хронологию клиента и его адреса из сопоставления по IP
+func (t *BootTimeline) evict(element *list.Element) {
+	client := t.order.Remove(element).(*clientTimeline)
+	delete(t.clients, client.mac)
+	for ip := range client.ips {
+		if t.byIP[ip] == client.mac {
+			delete(t.byIP, ip)
+		}
+	}
+}
+
+// clientEvents возвращает события клиента. Вызывается с захваченным мьютексом.
+func (t *BootTimeline) clientEvents(mac string) []BootEvent {
+	if element, ok := t.clients[normalizeMAC(mac)]; ok {
+		return element.Value.(*clientTimeline).events
+	}
+	return nil
+}
+
+// Events возвращает копию хронологии клиента
+func (t *BootTimeline) Events(mac string) []BootEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	events := t.clientEvents(mac)
+	result := make([]BootEvent, len(events))
+	copy(result, events)
+	return result
+}
+
+// All возвращает копию хронологии всех клиентов
+func (t *BootTimeline) All() map[string][]BootEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	result := make(map[string][]BootEvent, len(t.clients))
+	for mac, element := range t.clients {
+		result[mac] = append([]BootEvent(nil), element.Value.(*clientTimeline).events...)
+	}
+	return result
+}
+
+// Recent возвращает последние события всех клиентов, начиная с самых новых
+func (t *BootTimeline) Recent(limit int) []BootEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	n := len(t.recent)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	events := make([]BootEvent, 0, n)
+	for i := 1; i <= n; i++ {
+		events = append(events, t.recent[(t.next-i+maxRecentEvents)%maxRecentEvents])
+	}
+	return events
+}
+
+// LastStage возвращает последний достигнутый клиентом этап загрузки
+func (t *BootTimeline) LastStage(mac string) (BootStage, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	events := t.clientEvents(mac)
+	if len(events) == 0 {
+		return "", false
+	}
+	return events[len(events)-1].Stage, true
+}
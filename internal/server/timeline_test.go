@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestBootTimelineCorrelation(t *testing.T) {
+	timeline := NewBootTimeline()
+
+	// DHCP транзакция: DISCOVER -> OFFER -> REQUEST -> ACK
+	timeline.Record("00:11:22:33:44:55", "", StageDiscover, "")
+	timeline.Record("00:11:22:33:44:55", "192.168.1.100", StageOffer, "pxelinux.0")
+	timeline.Record("00:11:22:33:44:55", "", StageRequest, "")
+	timeline.Record("00:11:22:33:44:55", "192.168.1.100", StageAck, "pxelinux.0")
+
+	// Скачивание файла сопоставляется с клиентом по IP адресу
+	if !timeline.RecordFetch("192.168.1.100", "tftp", "pxelinux.0", nil) {
+		t.Fatal("Expected fetch to be correlated with DHCP transaction")
+	}
+
+	// Скачивание с неизвестного адреса не сопоставляется
+	if timeline.RecordFetch("192.168.1.200", "tftp", "pxelinux.0", nil) {
+		t.Error("Expected fetch from unknown IP not to be correlated")
+	}
+
+	events := timeline.Events("00:11:22:33:44:55")
+	expected := []BootStage{StageDiscover, StageOffer, StageRequest, StageAck, StageFileFetch}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(events))
+	}
+	for i, stage := range expected {
+		if events[i].Stage != stage {
+			t.Errorf("Expected stage %s at position %d, got %s", stage, i, events[i].Stage)
+		}
+	}
+
+	if events[4].Detail != "tftp:pxelinux.0" {
+		t.Errorf("Expected detail tftp:pxelinux.0, got %s", events[4].Detail)
+	}
+}
+
+func TestBootTimelineFetchError(t *testing.T) {
+	timeline := NewBootTimeline()
+	timeline.Record("00:11:22:33:44:55", "192.168.1.100", StageAck, "")
+	timeline.RecordFetch("192.168.1.100", "http", "boot.ipxe", errors.New("status 404"))
+
+	stage, ok := timeline.LastStage("00:11:22:33:44:55")
+	if !ok || stage != StageFileError {
+		t.Errorf("Expected last stage %s, got %s", StageFileError, stage)
+	}
+}
+
+func TestBootTimelineLimit(t *testing.T) {
+	timeline := NewBootTimeline()
+	for i := 0; i < maxBootEvents+10; i++ {
+		timeline.Record("00:11:22:33:44:55", "", StageDiscover, "")
+	}
+
+	if n := len(timeline.Events("00:11:22:33:44:55")); n != maxBootEvents {
+		t.Errorf("Expected %d events, got %d", maxBootEvents, n)
+	}
+
+	if _, ok := timeline.LastStage("00:00:00:00:00:00"); ok {
+		t.Error("Expected no stage for unknown client")
+	}
+}
+
+func TestRecordRequestAndReplyStages(t *testing.T) {
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Options: map[string]string{"bootfile-name": "pxelinux.0"},
+			},
+		},
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+
+	request := &BOOTPHeader{
+		Op:     BOOTPRequest,
+		Htype:  HTYPE_ETHER,
+		Hlen:   6,
+		Chaddr: [16]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+	}
+
+	server.recordRequestStage(request, DHCPDiscover)
+	reply := server.processRequest(request)
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+	server.recordReplyStage(reply, DHCPDiscover)
+
+	events := server.BootTimeline("00:11:22:33:44:55")
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Stage != StageDiscover || events[1].Stage != StageOffer {
+		t.Errorf("Expected discover/offer stages, got %s/%s", events[0].Stage, events[1].Stage)
+	}
+	if events[1].IP != "192.168.1.100" || events[1].Detail != "pxelinux.0" {
+		t.Errorf("Expected offer of 192.168.1.100 with pxelinux.0, got %s with %s", events[1].IP, events[1].Detail)
+	}
+
+	// BOOTP запрос без опции 53
+	server.recordRequestStage(request, 0)
+	if stage, _ := server.timeline.LastStage("00:11:22:33:44:55"); stage != StageBootRequest {
+		t.Errorf("Expected stage %s, got %s", StageBootRequest, stage)
+	}
+}
+
+func TestBootTimelineEviction(t *testing.T) {
+	timeline := NewBootTimeline()
+	timeline.Record("00:00:00:00:00:00", "10.0.0.1", StageOffer, "")
+
+	// Новые клиенты вытесняют давно неактивного
+	for i := 1; i <= maxBootClients; i++ {
+		timeline.Record(fmt.Sprintf("00:00:00:00:%02x:%02x", i>>8, i&0xff), "", StageDiscover, "")
+	}
+
+	if n := len(timeline.All()); n != maxBootClients {
+		t.Errorf("Expected %d clients, got %d", maxBootClients, n)
+	}
+	if events := timeline.Events("00:00:00:00:00:00"); len(events) != 0 {
+		t.Errorf("Expected oldest client to be evicted, got %d events", len(events))
+	}
+	if timeline.RecordFetch("10.0.0.1", "tftp", "pxelinux.0", nil) {
+		t.Error("Expected address of evicted client to be forgotten")
+	}
+}
+
+func TestBootTimelineRecent(t *testing.T) {
+	timeline := NewBootTimeline()
+	for i := 0; i < maxRecentEvents+5; i++ {
+		timeline.Record(fmt.Sprintf("00:00:00:00:%02x:%02x", i>>8, i&0xff), "", StageDiscover, "")
+	}
+
+	recent := timeline.Recent(3)
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(recent))
+	}
+	last := maxRecentEvents + 4
+	if expected := fmt.Sprintf("00:00:00:00:%02x:%02x", last>>8, last&0xff); recent[0].MAC != expected {
+		t.Errorf("Expected newest event from %s, got %s", expected, recent[0].MAC)
+	}
+
+	if n := len(timeline.Recent(0)); n != maxRecentEvents {
+		t.Errorf("Expected %d recent events, got %d", maxRecentEvents, n)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import "net"
+
+// broadcastFlag флаг BROADCAST в поле flags запроса: клиент не может
+// принять unicast ответ до настройки адреса
+const broadcastFlag = 0x8000
+
+// Прием запросов и широковещательные ответы зависят от платформы:
+// listen_<os>.go реализуют listenUDPInterface (сокет одного интерфейса),
+// newReceiver (прием только запросов своего интерфейса) и broadcastAddress
+// (адрес, по которому широковещательный ответ уходит через нужный интерфейс).
+// newReceiver получает размер пакета чтения batch (используется, где есть
+// recvmmsg) и dropped, которому сообщается количество запросов, отброшенных
+// ядром из-за переполнения буфера приема (где ядро его сообщает).
+
+// replySender отправляет ответы на запросы, принятые одним сокетом:
+// *net.UDPConn или кадрами с меткой VLAN транкового интерфейса (vlanReply)
+type replySender interface {
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+	LocalAddr() net.Addr
+}
+
+// senderAddr возвращает локальный адрес отправителя ответов
+func senderAddr(conn replySender) *net.UDPAddr {
+	if udp, ok := conn.(*net.UDPConn); ok || conn == nil {
+		return localUDPAddr(udp)
+	}
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr
+	}
+	return &net.UDPAddr{IP: net.IPv4zero, Port: BOOTP_PORT}
+}
+
+// receiver читает следующий запрос в buffer и возвращает его размер, адрес
+// отправителя и адрес интерфейса, на который пришел запрос (nil, если
+// платформа его не сообщает)
+type receiver func(buffer []byte) (int, *net.UDPAddr, net.IP, error)
+
+// plainReceiver читает запросы без определения адреса интерфейса
+func plainReceiver(conn *net.UDPConn) receiver {
+	return func(buffer []byte) (int, *net.UDPAddr, net.IP, error) {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		return n, addr, nil, err
+	}
+}
+
+// arrivalAddress возвращает адрес интерфейса с индексом index, на который
+// пришел пакет с адресом назначения dst: сам dst, если это адрес
+// интерфейса (unicast запрос), иначе первый IPv4 адрес интерфейса
+func arrivalAddress(index int, dst net.IP) net.IP {
+	iface, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+
+	var first net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		if ipNet.IP.Equal(dst) {
+			return ipNet.IP.To4()
+		}
+		if first == nil {
+			first = ipNet.IP.To4()
+		}
+	}
+	return first
+}
+
+// limitedBroadcast адрес широковещательного ответа клиенту 255.255.255.255:68
+func limitedBroadcast() *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: BOOTP_CLIENT_PORT}
+}
+
+// directedBroadcast возвращает широковещательный адрес сети ipNet
+// на порту клиента
+func directedBroadcast(ipNet *net.IPNet) *net.UDPAddr {
+	ip := ipNet.IP.To4()
+	mask := net.IP(ipNet.Mask).To4()
+	if ip == nil || mask == nil {
+		return limitedBroadcast()
+	}
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = ip[i] | ^mask[i]
+	}
+	return &net.UDPAddr{IP: broadcast, Port: BOOTP_CLIENT_PORT}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDirectedBroadcast(t *testing.T) {
+	tests := []struct {
+		network  string
+		expected string
+	}{
+		{"192.168.1.10/24", "192.168.1.255:68"},
+		{"10.1.2.3/16", "10.1.255.255:68"},
+		{"172.16.5.1/30", "172.16.5.3:68"},
+	}
+	for _, test := range tests {
+		ip, ipNet, err := net.ParseCIDR(test.network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ipNet.IP = ip
+		if addr := directedBroadcast(ipNet); addr.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.network, test.expected, addr)
+		}
+	}
+
+	if addr := broadcastAddress("", nil); addr.String() != "255.255.255.255:68" {
+		t.Errorf("Expected limited broadcast without interface, got %s", addr)
+	}
+}
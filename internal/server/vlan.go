@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+// Параметры кадров Ethernet и 802.1Q
+const (
+	etherTypeIPv4      = 0x0800
+	etherTypeVLAN      = 0x8100
+	ethernetHeaderSize = 14
+	vlanTagSize        = 4
+	maxVLANID          = 4094
+	maxTrunkFrame      = 65535
+)
+
+var broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// trunkSocket сокет канального уровня транкового интерфейса (реализация
+// зависит от платформы, см. listenTrunk)
+type trunkSocket interface {
+	// readFrame читает кадр Ethernet и возвращает его размер и метку VLAN,
+	// снятую сетевой картой до передачи кадра (tagged false - не снята)
+	readFrame(buffer []byte) (n int, tci uint16, tagged bool, err error)
+	// writeFrame отправляет кадр Ethernet
+	writeFrame(frame []byte) error
+	hardwareAddr() net.HardwareAddr
+	Close() error
+}
+
+// vlanConfig обслуживание VLAN на транковых интерфейсах: сервер принимает
+// кадры с метками 802.1Q через сокет канального уровня и отвечает в ту же
+// VLAN, не требуя отдельного интерфейса на каждую VLAN
+type vlanConfig struct {
+	trunks    []string          // Транковые интерфейсы (vlan-trunk)
+	addresses map[uint16]net.IP // Адрес сервера в каждой VLAN (vlan-map)
+}
+
+// parseVLANOptions читает транковые интерфейсы и адреса сервера в VLAN:
+//
+//	vlan-trunk "eth1";
+//	vlan-map "10=192.168.10.1, 20=192.168.20.1";
+//
+// Подсетью VLAN считается подсеть конфигурации, содержащая адрес сервера.
+func parseVLANOptions(options map[string]string) (vlanConfig, error) {
+	var vlans vlanConfig
+	for _, name := range strings.Split(strings.Trim(options["vlan-trunk"], "\""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			vlans.trunks = append(vlans.trunks, name)
+		}
+	}
+
+	value := strings.Trim(options["vlan-map"], "\"")
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		id, address, found := strings.Cut(item, "=")
+		vlan, err := strconv.ParseUint(strings.TrimSpace(id), 10, 16)
+		ip := net.ParseIP(strings.TrimSpace(address)).To4()
+		if !found || err != nil || vlan < 1 || vlan > maxVLANID || ip == nil {
+			return vlanConfig{}, fmt.Errorf("invalid vlan-map entry %q, expected <vlan id>=<server address>", item)
+		}
+		if vlans.addresses == nil {
+			vlans.addresses = make(map[uint16]net.IP)
+		}
+		if _, exists := vlans.addresses[uint16(vlan)]; exists {
+			return vlanConfig{}, fmt.Errorf("duplicate vlan-map entry for VLAN %d", vlan)
+		}
+		vlans.addresses[uint16(vlan)] = ip
+	}
+
+	if len(vlans.trunks) > 0 && len(vlans.addresses) == 0 {
+		return vlanConfig{}, errors.New("vlan-trunk requires vlan-map")
+	}
+	if len(vlans.trunks) == 0 && len(vlans.addresses) > 0 {
+		return vlanConfig{}, errors.New("vlan-map requires vlan-trunk")
+	}
+	return vlans, nil
+}
+
+// checkSubnets проверяет, что адрес сервера каждой VLAN входит в подсеть
+// конфигурации
+func (v vlanConfig) checkSubnets(subnets []config.Subnet) error {
+	for vlan, address := range v.addresses {
+		found := false
+		for i := range subnets {
+			if subnets[i].Network != nil && subnets[i].Network.Contains(address) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("vlan-map: address %s of VLAN %d is not in any subnet", address, vlan)
+		}
+	}
+	return nil
+}
+
+// trunkFrame BOOTP запрос, принятый в VLAN транкового интерфейса
+type trunkFrame struct {
+	vlan     uint16
+	priority uint8            // Приоритет 802.1p, повторяется в ответе
+	source   net.HardwareAddr // MAC адрес отправителя: клиента или релея
+	client   *net.UDPAddr
+	payload  []byte
+}
+
+// parseTrunkFrame разбирает кадр Ethernet с меткой VLAN, снятой сетевой
+// картой (tci, tagged) или оставленной в кадре, и UDP датаграммой на порт
+// port. Кадры без метки обслуживаются обычным сокетом интерфейса.
+func parseTrunkFrame(frame []byte, tci uint16, tagged bool, port int) (trunkFrame, bool) {
+	if len(frame) < ethernetHeaderSize {
+		return trunkFrame{}, false
+	}
+	source := frame[6:12]
+	etherType := binary.BigEndian.Uint16(frame[12:14])
+	payload := frame[ethernetHeaderSize:]
+	if !tagged && etherType == etherTypeVLAN && len(payload) >= vlanTagSize {
+		tci, tagged = binary.BigEndian.Uint16(payload[0:2]), true
+		etherType, payload = binary.BigEndian.Uint16(payload[2:4]), payload[vlanTagSize:]
+	}
+	if !tagged || etherType != etherTypeIPv4 {
+		return trunkFrame{}, false
+	}
+
+	datagram, ok := parseCapturedFrame(pcapLinkTypeRaw, payload)
+	if !ok || datagram.Dst.Port != port {
+		return trunkFrame{}, false
+	}
+	return trunkFrame{
+		vlan:     tci & 0x0fff,
+		priority: uint8(tci >> 13),
+		source:   append(net.HardwareAddr(nil), source...),
+		client:   datagram.Src,
+		payload:  datagram.Data,
+	}, true
+}
+
+// vlanReply отправляет ответ на запрос из VLAN кадром с той же меткой.
+// Ответ адресуется MAC адресу отправителя запроса, широковещательный -
+// всем узлам VLAN.
+type vlanReply struct {
+	trunk    trunkSocket
+	vlan     uint16
+	priority uint8
+	local    *net.UDPAddr // Адрес сервера в VLAN
+	source   net.HardwareAddr
+}
+
+// WriteToUDP отправляет датаграмму data по адресу addr
+func (r *vlanReply) WriteToUDP(data []byte, addr *net.UDPAddr) (int, error) {
+	destination := r.source
+	if addr.IP.Equal(net.IPv4bcast) {
+		destination = broadcastMAC
+	}
+
+	frame := make([]byte, 0, ethernetHeaderSize+vlanTagSize+ipv4HeaderSize+udpHeaderSize+len(data))
+	frame = append(frame, destination...)
+	frame = append(frame, r.trunk.hardwareAddr()...)
+	tci := uint16(r.priority)<<13 | r.vlan
+	frame = append(frame, etherTypeVLAN>>8, etherTypeVLAN&0xff, byte(tci>>8), byte(tci), etherTypeIPv4>>8, etherTypeIPv4&0xff)
+	frame = append(frame, buildIPv4UDP(r.local, addr, data)...)
+
+	if err := r.trunk.writeFrame(frame); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// LocalAddr возвращает адрес сервера в VLAN
+func (r *vlanReply) LocalAddr() net.Addr {
+	return r.local
+}
+
+// startTrunks открывает сокеты транковых интерфейсов
+func (s *BOOTPServer) startTrunks() error {
+	s.mutex.Lock()
+	names := s.vlans.trunks
+	s.mutex.Unlock()
+
+	for _, name := range names {
+		trunk, err := listenTrunk(name)
+		if err != nil {
+			return err
+		}
+		s.trunks = append(s.trunks, trunk)
+		go s.handleTrunk(name, trunk)
+
+		s.logger.Infof("BOOTP server serving VLANs on trunk %s", name)
+	}
+	return nil
+}
+
+// handleTrunk обрабатывает запросы из VLAN транкового интерфейса name
+func (s *BOOTPServer) handleTrunk(name string, trunk trunkSocket) {
+	buffer := make([]byte, maxTrunkFrame)
+	for {
+		n, tci, tagged, err := trunk.readFrame(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, os.ErrClosed) {
+				return
+			}
+			s.logger.Errorf("Error reading frame from trunk %s: %v", name, err)
+			continue
+		}
+
+		frame, ok := parseTrunkFrame(buffer[:n], tci, tagged, s.listen.Port)
+		if !ok {
+			continue
+		}
+		s.mutex.Lock()
+		local := s.vlans.addresses[frame.vlan]
+		s.mutex.Unlock()
+		if local == nil {
+			s.logger.Debugf("Dropping request from %s in VLAN %d on trunk %s: VLAN is not in vlan-map", frame.source, frame.vlan, name)
+			continue
+		}
+
+		reply := &vlanReply{
+			trunk:    trunk,
+			vlan:     frame.vlan,
+			priority: frame.priority,
+			local:    &net.UDPAddr{IP: local, Port: s.listen.Port},
+			source:   frame.source,
+		}
+		s.receivePacket(reply, frame.payload, frame.client, local)
+	}
+}
+
+// requestNetworks возвращает адреса, подсетями которых ограничен выбор
+// адреса клиента: адрес сервера в VLAN запроса с транкового интерфейса
+// или подсети interface-subnets интерфейса запроса. Для остальных
+// запросов возвращает nil.
+func (s *BOOTPServer) requestNetworks(req *Request) []net.IP {
+	if req.VLAN != 0 {
+		return []net.IP{req.Local}
+	}
+	override := s.interfaceOverride(req.Interface)
+	if override == nil || override.subnets == nil {
+		return nil
+	}
+	networks := make([]net.IP, 0, len(override.subnets))
+	for _, subnet := range override.subnets {
+		networks = append(networks, subnet.IP)
+	}
+	return networks
+}
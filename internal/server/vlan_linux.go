@@ -0,0 +1,146 @@
+//go:build linux
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Параметры сокета канального уровня (linux/if_packet.h)
+const (
+	packetAuxData     = 8    // PACKET_AUXDATA: метка VLAN, снятая сетевой картой
+	packetOutgoing    = 4    // PACKET_OUTGOING: кадр, отправленный самим узлом
+	tpStatusVLANValid = 0x10 // TP_STATUS_VLAN_VALID
+)
+
+// tpacketAuxData структура tpacket_auxdata
+type tpacketAuxData struct {
+	status   uint32
+	length   uint32
+	snapLen  uint32
+	mac      uint16
+	net      uint16
+	vlanTCI  uint16
+	vlanTPID uint16
+}
+
+// packetTrunk сокет AF_PACKET, принимающий все кадры транкового интерфейса
+type packetTrunk struct {
+	file *os.File
+	raw  syscall.RawConn
+	mac  net.HardwareAddr
+	oob  []byte
+}
+
+// listenTrunk открывает сокет канального уровня на интерфейсе name. Сетевые
+// карты обычно снимают метку VLAN сами и передают ее во вспомогательных
+// данных (PACKET_AUXDATA).
+func listenTrunk(name string) (trunkSocket, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("vlan trunk %s: %v", name, err)
+	}
+	if len(iface.HardwareAddr) != 6 {
+		return nil, fmt.Errorf("vlan trunk %s is not an Ethernet interface", name)
+	}
+
+	protocol := htons(syscall.ETH_P_ALL)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, int(protocol))
+	if err != nil {
+		return nil, permissionError(os.NewSyscallError("socket", err), capNetRaw, "serving VLAN trunk "+name)
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_PACKET, packetAuxData, 1); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("vlan trunk %s: %v", name, os.NewSyscallError("setsockopt", err))
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: protocol, Ifindex: iface.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("vlan trunk %s: %v", name, os.NewSyscallError("bind", err))
+	}
+
+	file := os.NewFile(uintptr(fd), "vlan-trunk-"+name)
+	raw, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &packetTrunk{
+		file: file,
+		raw:  raw,
+		mac:  iface.HardwareAddr,
+		oob:  make([]byte, syscall.CmsgSpace(int(unsafe.Sizeof(tpacketAuxData{})))),
+	}, nil
+}
+
+// htons переводит значение в сетевой порядок байт
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
+
+func (t *packetTrunk) readFrame(buffer []byte) (int, uint16, bool, error) {
+	for {
+		var n, oobn int
+		var from syscall.Sockaddr
+		var errno error
+		err := t.raw.Read(func(fd uintptr) bool {
+			n, oobn, _, from, errno = syscall.Recvmsg(int(fd), buffer, t.oob, 0)
+			return errno != syscall.EAGAIN && errno != syscall.EINTR
+		})
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if errno != nil {
+			return 0, 0, false, os.NewSyscallError("recvmsg", errno)
+		}
+		// Собственные ответы сервера тоже видны сокету
+		if link, ok := from.(*syscall.SockaddrLinklayer); ok && link.Pkttype == packetOutgoing {
+			continue
+		}
+
+		tci, tagged := auxVLAN(t.oob[:oobn])
+		return n, tci, tagged, nil
+	}
+}
+
+// auxVLAN возвращает метку VLAN из вспомогательных данных кадра
+func auxVLAN(oob []byte) (uint16, bool) {
+	messages, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range messages {
+		if m.Header.Level == syscall.SOL_PACKET && m.Header.Type == packetAuxData &&
+			len(m.Data) >= int(unsafe.Sizeof(tpacketAuxData{})) {
+			aux := (*tpacketAuxData)(unsafe.Pointer(&m.Data[0]))
+			return aux.vlanTCI, aux.status&tpStatusVLANValid != 0
+		}
+	}
+	return 0, false
+}
+
+func (t *packetTrunk) writeFrame(frame []byte) error {
+	var errno error
+	err := t.raw.Write(func(fd uintptr) bool {
+		_, errno = syscall.Write(int(fd), frame)
+		return errno != syscall.EAGAIN && errno != syscall.EINTR
+	})
+	if err != nil {
+		return err
+	}
+	if errno != nil {
+		return os.NewSyscallError("write", errno)
+	}
+	return nil
+}
+
+func (t *packetTrunk) hardwareAddr() net.HardwareAddr {
+	return t.mac
+}
+
+func (t *packetTrunk) Close() error {
+	return t.file.Close()
+}
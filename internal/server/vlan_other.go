@@ -0,0 +1,10 @@
+//go:build !linux
+
+package server
+
+import "fmt"
+
+// listenTrunk не поддерживается на этой платформе
+func listenTrunk(name string) (trunkSocket, error) {
+	return nil, fmt.Errorf("vlan trunk %s: VLAN trunks are supported only on Linux", name)
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/go-bootp/internal/config"
+)
+
+func TestParseVLANOptions(t *testing.T) {
+	vlans, err := parseVLANOptions(map[string]string{})
+	if err != nil || len(vlans.trunks) != 0 || len(vlans.addresses) != 0 {
+		t.Errorf("Expected no VLANs, got %+v (%v)", vlans, err)
+	}
+
+	vlans, err = parseVLANOptions(map[string]string{
+		"vlan-trunk": `"eth1, eth2"`,
+		"vlan-map":   `"10=192.168.10.1, 20 = 192.168.20.1"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(vlans.trunks, ",") != "eth1,eth2" || len(vlans.addresses) != 2 || !vlans.addresses[20].Equal(net.IPv4(192, 168, 20, 1)) {
+		t.Errorf("Unexpected VLANs %+v", vlans)
+	}
+
+	tests := []struct {
+		trunk, vlans, err string
+	}{
+		{"eth1", "", "requires vlan-map"},
+		{"", "10=192.168.10.1", "requires vlan-trunk"},
+		{"eth1", "0=192.168.10.1", "invalid vlan-map entry"},
+		{"eth1", "4095=192.168.10.1", "invalid vlan-map entry"},
+		{"eth1", "10", "invalid vlan-map entry"},
+		{"eth1", "10=server", "invalid vlan-map entry"},
+		{"eth1", "10=192.168.10.1, 10=192.168.11.1", "duplicate vlan-map entry for VLAN 10"},
+	}
+	for _, tt := range tests {
+		_, err := parseVLANOptions(map[string]string{"vlan-trunk": tt.trunk, "vlan-map": tt.vlans})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q/%q: expected error containing %q, got %v", tt.trunk, tt.vlans, tt.err, err)
+		}
+	}
+
+	// Адрес сервера в VLAN должен входить в подсеть конфигурации
+	cfg := vlanTestConfig()
+	cfg.GlobalOptions["vlan-map"] = `"10=192.168.10.1, 30=192.168.30.1"`
+	if err := ValidateConfig(cfg); err == nil || !strings.Contains(err.Error(), "192.168.30.1 of VLAN 30 is not in any subnet") {
+		t.Errorf("Expected subnet error, got %v", err)
+	}
+	if _, err := NewBOOTPServer(cfg); err == nil {
+		t.Error("Expected NewBOOTPServer to reject VLAN address outside subnets")
+	}
+}
+
+// vlanTestConfig две подсети, обслуживаемые в VLAN 10 и 20 транка trunk0
+func vlanTestConfig() *config.DHCPConfig {
+	return &config.DHCPConfig{
+		GlobalOptions: map[string]string{
+			"vlan-trunk": `"trunk0"`,
+			"vlan-map":   `"10=192.168.10.1, 20=192.168.20.1"`,
+		},
+		Subnets: []config.Subnet{
+			{Network: config.MustParseNetwork("192.168.10.0/24"), Ranges: []config.Range{{Start: "192.168.10.100", End: "192.168.10.200"}}},
+			{Network: config.MustParseNetwork("192.168.20.0/24"), Ranges: []config.Range{{Start: "192.168.20.100", End: "192.168.20.200"}}},
+		},
+	}
+}
+
+// testFrame кадр, принятый сокетом транка
+type testFrame struct {
+	data   []byte
+	tci    uint16
+	tagged bool
+}
+
+// testTrunk транковый интерфейс, кадры которого передаются через каналы
+type testTrunk struct {
+	received chan testFrame
+	sent     chan []byte
+	closed   chan struct{}
+}
+
+func newTestTrunk() *testTrunk {
+	return &testTrunk{received: make(chan testFrame, 8), sent: make(chan []byte, 8), closed: make(chan struct{})}
+}
+
+func (t *testTrunk) readFrame(buffer []byte) (int, uint16, bool, error) {
+	select {
+	case frame := <-t.received:
+		return copy(buffer, frame.data), frame.tci, frame.tagged, nil
+	case <-t.closed:
+		return 0, 0, false, net.ErrClosed
+	}
+}
+
+func (t *testTrunk) writeFrame(frame []byte) error {
+	t.sent <- append([]byte(nil), frame...)
+	return nil
+}
+
+func (t *testTrunk) hardwareAddr() net.HardwareAddr {
+	return net.HardwareAddr{0x02, 0, 0, 0, 0, 0xfe}
+}
+
+func (t *testTrunk) Close() error {
+	close(t.closed)
+	return nil
+}
+
+// discoverFrame кадр Ethernet с DISCOVER клиента mac. tci 0 - кадр без метки.
+func discoverFrame(t *testing.T, mac byte, xid uint32, tci uint16) []byte {
+	header := BOOTPHeader{Op: BOOTPRequest, Htype: HTYPE_ETHER, Hlen: 6, Xid: xid, Magic: magicCookie}
+	header.Chaddr[0], header.Chaddr[5] = 0x02, mac
+	data := EncodeReply(&header, map[uint8][]byte{OptionMessageType: {DHCPDiscover}})
+
+	frame := append([]byte(nil), broadcastMAC...)
+	frame = append(frame, header.Chaddr[:6]...)
+	if tci != 0 {
+		frame = append(frame, 0x81, 0x00, byte(tci>>8), byte(tci))
+	}
+	frame = append(frame, 0x08, 0x00)
+	client := &net.UDPAddr{IP: net.IPv4zero, Port: BOOTP_CLIENT_PORT}
+	return append(frame, buildIPv4UDP(client, &net.UDPAddr{IP: net.IPv4bcast, Port: BOOTP_PORT}, data)...)
+}
+
+func TestParseTrunkFrame(t *testing.T) {
+	tagged := discoverFrame(t, 1, 1, 5<<13|20)
+	frame, ok := parseTrunkFrame(tagged, 0, false, BOOTP_PORT)
+	if !ok || frame.vlan != 20 || frame.priority != 5 || frame.source.String() != "02:00:00:00:00:01" {
+		t.Fatalf("Unexpected frame %+v (%v)", frame, ok)
+	}
+	if frame.client.Port != BOOTP_CLIENT_PORT || frame.payload[0] != BOOTPRequest {
+		t.Errorf("Unexpected datagram %+v", frame)
+	}
+
+	// Метка, снятая сетевой картой
+	untagged := discoverFrame(t, 1, 1, 0)
+	if frame, ok := parseTrunkFrame(untagged, 30, true, BOOTP_PORT); !ok || frame.vlan != 30 {
+		t.Errorf("Expected VLAN 30 from auxiliary data, got %+v (%v)", frame, ok)
+	}
+
+	if _, ok := parseTrunkFrame(untagged, 0, false, BOOTP_PORT); ok {
+		t.Error("Expected untagged frame to be ignored")
+	}
+	if _, ok := parseTrunkFrame(tagged, 0, false, 1067); ok {
+		t.Error("Expected frame for another port to be ignored")
+	}
+	if _, ok := parseTrunkFrame(tagged[:10], 0, false, BOOTP_PORT); ok {
+		t.Error("Expected truncated frame to be ignored")
+	}
+}
+
+// trunkReply ждет ответ сервера в транк и разбирает его
+func trunkReply(t *testing.T, trunk *testTrunk) (uint16, CapturedPacket, *Packet) {
+	t.Helper()
+	var frame []byte
+	select {
+	case frame = <-trunk.sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("No reply sent to trunk")
+	}
+
+	if !bytes.Equal(frame[0:6], broadcastMAC) || !bytes.Equal(frame[6:12], trunk.hardwareAddr()) {
+		t.Errorf("Unexpected MAC addresses %x", frame[:12])
+	}
+	if binary.BigEndian.Uint16(frame[12:14]) != etherTypeVLAN || binary.BigEndian.Uint16(frame[16:18]) != etherTypeIPv4 {
+		t.Fatalf("Reply is not a tagged IPv4 frame: %x", frame[12:18])
+	}
+	datagram, ok := parseCapturedFrame(pcapLinkTypeRaw, frame[18:])
+	if !ok {
+		t.Fatal("Failed to parse reply datagram")
+	}
+	packet, err := DecodePacket(datagram.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return binary.BigEndian.Uint16(frame[14:16]), datagram, packet
+}
+
+func TestTrunkVLANSubnets(t *testing.T) {
+	server, err := NewBOOTPServer(vlanTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trunk := newTestTrunk()
+	defer trunk.Close()
+	go server.handleTrunk("trunk0", trunk)
+
+	// Ответ уходит в VLAN запроса с тем же приоритетом и адресом из ее подсети
+	trunk.received <- testFrame{data: discoverFrame(t, 1, 1, 5<<13|20)}
+	tci, datagram, reply := trunkReply(t, trunk)
+	if tci != 5<<13|20 {
+		t.Errorf("Expected reply tagged with VLAN 20 priority 5, got %#x", tci)
+	}
+	if !datagram.Src.IP.Equal(net.IPv4(192, 168, 20, 1)) || datagram.Dst.Port != BOOTP_CLIENT_PORT {
+		t.Errorf("Unexpected reply addresses %v -> %v", datagram.Src, datagram.Dst)
+	}
+	yiaddr := net.IP(reply.Header.Yiaddr[:])
+	if !config.MustParseNetwork("192.168.20.0/24").Contains(yiaddr) {
+		t.Errorf("Expected address in VLAN 20 subnet, got %s", yiaddr)
+	}
+	if id := net.IP(reply.Options[OptionServerIdentifier]); !id.Equal(net.IPv4(192, 168, 20, 1)) {
+		t.Errorf("Expected server identifier 192.168.20.1, got %s", id)
+	}
+
+	// Клиент, перешедший в VLAN 10 (метка снята сетевой картой), получает
+	// адрес ее подсети
+	trunk.received <- testFrame{data: discoverFrame(t, 1, 2, 0), tci: 10, tagged: true}
+	tci, _, reply = trunkReply(t, trunk)
+	yiaddr = net.IP(reply.Header.Yiaddr[:])
+	if tci != 10 || !config.MustParseNetwork("192.168.10.0/24").Contains(yiaddr) {
+		t.Errorf("Expected address in VLAN 10 subnet, got %s in VLAN %d", yiaddr, tci)
+	}
+
+	// VLAN без адреса сервера и кадры без метки не обслуживаются
+	trunk.received <- testFrame{data: discoverFrame(t, 2, 3, 30)}
+	trunk.received <- testFrame{data: discoverFrame(t, 3, 4, 0)}
+	select {
+	case frame := <-trunk.sent:
+		t.Errorf("Unexpected reply %x", frame)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для хука выделения адресов
+const (
+	defaultHookTimeout          = 2 * time.Second
+	defaultHookFailureThreshold = 5
+	defaultHookCooldown         = 30 * time.Second
+)
+
+// HookRequest описывает выбранное сервером назначение, отправляемое во внешний хук
+type HookRequest struct {
+	MAC     string            `json:"mac"`
+	IP      string            `json:"ip"`
+	Subnet  string            `json:"subnet,omitempty"`
+	Options map[string]string `json:"options"`
+
+	Renewal bool `json:"renewal,omitempty"` // Клиент подтверждает адрес, который уже держит (замена ip не применяется)
+}
+
+// HookResponse представляет решение внешнего хука
+type HookResponse struct {
+	Veto    bool              `json:"veto"`              // Запретить выдачу адреса
+	IP      string            `json:"ip,omitempty"`      // Заменить выбранный IP адрес
+	Options map[string]string `json:"options,omitempty"` // Переопределить опции ответа
+}
+
+// AllocationHook вызывает внешний HTTP сервис перед отправкой ответа,
+// позволяя ему запретить или изменить выбранное назначение
+type AllocationHook struct {
+	url        string
+	client     *http.Client
+	failClosed bool          // Политика при недоступности хука: true - не отвечать клиенту
+	threshold  int           // Количество ошибок подряд до размыкания цепи
+	cooldown   time.Duration // Время, в течение которого хук не вызывается после размыкания
+
+	mutex     sync.Mutex
+	failures  int       // Количество ошибок подряд
+	openUntil time.Time // Время, до которого цепь разомкнута
+}
+
+// NewAllocationHook создает хук по глобальным опциям конфигурации.
+// Возвращает nil, если allocation-hook-url не задан.
+func NewAllocationHook(options map[string]string) (*AllocationHook, error) {
+	url := strings.Trim(options["allocation-hook-url"], "\"")
+	if url == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("allocation-hook-url must be an http(s) URL: %s", url)
+	}
+
+	hook := &AllocationHook{
+		url:       url,
+		client:    &http.Client{Timeout: defaultHookTimeout},
+		threshold: defaultHookFailureThreshold,
+		cooldown:  defaultHookCooldown,
+	}
+
+	// Таймаут задается в миллисекундах
+	if value, ok := options["allocation-hook-timeout"]; ok {
+		ms, err := strconv.Atoi(value)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid allocation-hook-timeout: %s", value)
+		}
+		hook.client.Timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	if value, ok := options["allocation-hook-policy"]; ok {
+		switch value {
+		case "fail-open":
+			hook.failClosed = false
+		case "fail-closed":
+			hook.failClosed = true
+		default:
+			return nil, fmt.Errorf("invalid allocation-hook-policy: %s", value)
+		}
+	}
+
+	if value, ok := options["allocation-hook-failure-threshold"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid allocation-hook-failure-threshold: %s", value)
+		}
+		hook.threshold = n
+	}
+
+	// Время размыкания задается в секундах
+	if value, ok := options["allocation-hook-cooldown"]; ok {
+		sec, err := strconv.Atoi(value)
+		if err != nil || sec < 0 {
+			return nil, fmt.Errorf("invalid allocation-hook-cooldown: %s", value)
+		}
+		hook.cooldown = time.Duration(sec) * time.Second
+	}
+
+	return hook, nil
+}
+
+// evaluateHook запрашивает решение у хука. Второе значение сообщает,
+// следует ли отправлять ответ клиенту. При ошибке хука или разомкнутой
+// цепи решение принимается согласно политике fail-open/fail-closed.
+// Запрос прерывается при отмене ctx (см. request-timeout).
+func (s *BOOTPServer) evaluateHook(ctx context.Context, h *AllocationHook, req *HookRequest) (*HookResponse, bool) {
+	if h.isOpen(s.clock.Now()) {
+		s.logger.Warnf("Allocation hook circuit is open, applying %s policy for %s", h.policyName(), req.MAC)
+		return nil, !h.failClosed
+	}
+
+	resp, err := h.call(ctx, req)
+	if err != nil {
+		s.logger.Errorf("Allocation hook failed for %s: %v", req.MAC, err)
+		if h.recordFailure(s.clock.Now()) {
+			s.logger.Warnf("Allocation hook circuit opened for %v", h.cooldown)
+		}
+		return nil, !h.failClosed
+	}
+	h.recordSuccess()
+
+	if resp.Veto {
+		s.logger.Infof("Allocation hook vetoed %s for %s", req.IP, req.MAC)
+		return resp, false
+	}
+
+	return resp, true
+}
+
+// call выполняет HTTP запрос к хуку
+func (h *AllocationHook) call(ctx context.Context, req *HookRequest) (*HookResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := h.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", httpResp.Status)
+	}
+
+	resp := &HookResponse{}
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+
+	if resp.IP != "" {
+		if ip := net.ParseIP(resp.IP).To4(); ip == nil {
+			return nil, fmt.Errorf("invalid IP in response: %s", resp.IP)
+		}
+	}
+
+	return resp, nil
+}
+
+// isOpen проверяет, разомкнута ли цепь в момент now
+func (h *AllocationHook) isOpen(now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return now.Before(h.openUntil)
+}
+
+// recordFailure учитывает ошибку и размыкает цепь при достижении порога.
+// Возвращает true, если цепь разомкнута этой ошибкой.
+func (h *AllocationHook) recordFailure(now time.Time) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.failures++
+	if h.failures < h.threshold {
+		return false
+	}
+	h.openUntil = now.Add(h.cooldown)
+	h.failures = 0
+	return true
+}
+
+// recordSuccess сбрасывает счетчик ошибок
+func (h *AllocationHook) recordSuccess() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.failures = 0
+}
+
+func (h *AllocationHook) policyName() string {
+	if h.failClosed {
+		return "fail-closed"
+	}
+	return "fail-open"
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/user/go-bootp/internal/config"
+)
+
+// newHookTestServer создает BOOTP сервер с хуком, указывающим на url
+func newHookTestServer(t *testing.T, url string, extra map[string]string) *BOOTPServer {
+	globals := map[string]string{
+		"allocation-hook-url": "\"" + url + "\"",
+	}
+	for key, value := range extra {
+		globals[key] = value
+	}
+
+	cfg := &config.DHCPConfig{
+		Subnets: []config.Subnet{
+			{
+				Network: config.MustParseNetwork("192.168.1.0/24"),
+				Ranges:  []config.Range{{Start: "192.168.1.100", End: "192.168.1.110"}},
+				Options: map[string]string{
+					"bootfile-name": "pxelinux.0",
+				},
+			},
+		},
+		GlobalOptions: globals,
+	}
+
+	server, err := NewBOOTPServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create BOOTP server: %v", err)
+	}
+	if server.hook == nil {
+		t.Fatal("Expected allocation hook to be configured")
+	}
+	return server
+}
+
+func hookTestRequest() *BOOTPHeader {
+	return &BOOTPHeader{
+		Op:     BOOTPRequest,
+		Htype:  HTYPE_ETHER,
+		Hlen:   6,
+		Xid:    0x1,
+		Chaddr: [16]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+	}
+}
+
+func TestAllocationHookAllow(t *testing.T) {
+	var received HookRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	server := newHookTestServer(t, ts.URL, nil)
+
+	reply := server.processRequest(hookTestRequest())
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+
+	// Проверяем, что хук получил выбранное назначение
+	if received.MAC != "00:00:00:00:00:01" {
+		t.Errorf("Expected hook MAC 00:00:00:00:00:01, got %s", received.MAC)
+	}
+	if received.IP != "192.168.1.100" {
+		t.Errorf("Expected hook IP 192.168.1.100, got %s", received.IP)
+	}
+	if received.Subnet != "192.168.1.0" {
+		t.Errorf("Expected hook subnet 192.168.1.0, got %s", received.Subnet)
+	}
+	if received.Options["bootfile-name"] != "pxelinux.0" {
+		t.Errorf("Expected hook option bootfile-name pxelinux.0, got %s", received.Options["bootfile-name"])
+	}
+}
+
+func TestAllocationHookVeto(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"veto": true}`))
+	}))
+	defer ts.Close()
+
+	server := newHookTestServer(t, ts.URL, nil)
+	events, cancel := server.SubscribeLeaseEvents(4)
+	defer cancel()
+
+	if reply := server.processRequest(hookTestRequest()); reply != nil {
+		t.Error("Expected nil reply when hook vetoes allocation")
+	}
+
+	// Запрещенное назначение не должно занимать адрес в пуле
+	if len(server.allocatedIP) != 0 || len(server.allocatedMAC) != 0 {
+		t.Errorf("Expected empty allocation tables after veto, got %d entries", len(server.allocatedIP))
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected no lease events after veto, got %s", event.Type)
+	default:
+	}
+
+	// Пул не истощается повторными запросами от запрещенного клиента
+	for i := 0; i < 20; i++ {
+		request := hookTestRequest()
+		request.Chaddr[5] = byte(i)
+		server.processRequest(request)
+	}
+	if usage := server.SubnetUtilization(); usage[0].Free != usage[0].Size {
+		t.Errorf("Expected all %d addresses free after vetoes, got %d", usage[0].Size, usage[0].Free)
+	}
+}
+
+func TestAllocationHookModify(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip": "192.168.1.105", "options": {"bootfile-name": "ipxe.efi"}}`))
+	}))
+	defer ts.Close()
+
+	server := newHookTestServer(t, ts.URL, nil)
+
+	reply := server.processRequest(hookTestRequest())
+	if reply == nil {
+		t.Fatal("Expected reply, got nil")
+	}
+
+	expectedIP := net.ParseIP("192.168.1.105").To4()
+	if !bytes.Equal(reply.Yiaddr[:], expectedIP) {
+		t.Errorf("Expected yiaddr %v, got %v", expectedIP, reply.Yiaddr[:])
+	}
+
+	if file := string(bytes.Trim(reply.File[:], "\x00")); file != "ipxe.efi" {
+		t.Errorf("Expected file ipxe.efi, got %s", file)
+	}
+
+	// Проверяем, что таблица назначений перенесена на новый адрес
+	if _, exists := server.allocatedIP[ipToInt(net.ParseIP("192.168.1.100"))]; exists {
+		t.Error("Expected original IP to be released")
+	}
+	if allocated, exists := server.allocatedIP[ipToInt(expectedIP)]; !exists || allocated.MAC != "00:00:00:00:00:01" {
+		t.Error("Expected modified IP to be allocated to the client")
+	}
+}
+
+func TestAllocationHookRenewal(t *testing.T) {
+	var renewals []bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var received HookRequest
+		json.NewDecoder(r.Body).Decode(&received)
+		renewals = append(renewals, received.Renewal)
+		if received.Renewal {
+			w.Write([]byte(`{"ip": "192.168.1.107"}`))
+			return
+		}
+		w.Write([]byte(`{"ip": "192.168.1.105"}`))
+	}))
+	defer ts.Close()
+
+	server := newHookTestServer(t, ts.URL, nil)
+	events, cancel := server.SubscribeLeaseEvents(8)
+	defer cancel()
+
+	request := func(options map[uint8][]byte) string {
+		packet := &Packet{Header: *hookTestRequest(), Options: options}
+		reply := server.processPacket(packet)
+		if reply == nil {
+			t.Fatalf("Expected reply to %v", options)
+		}
+		return net.IP(reply.Header.Yiaddr[:]).String()
+	}
+	address := net.ParseIP("192.168.1.105").To4()
+
+	// Новая выдача: DHCPDISCOVER и DHCPREQUEST в состоянии SELECTING
+	if ip := request(map[uint8][]byte{OptionMessageType: {DHCPDiscover}}); ip != "192.168.1.105" {
+		t.Fatalf("Expected offer of hook address, got %s", ip)
+	}
+	selecting := map[uint8][]byte{OptionMessageType: {DHCPRequest}, OptionRequestedIP: address, OptionServerIdentifier: {192, 168, 1, 1}}
+	if ip := request(selecting); ip != "192.168.1.105" {
+		t.Fatalf("Expected ack of hook address, got %s", ip)
+	}
+
+	// Перезагрузка без освобождения (INIT-REBOOT) продлевает ту же аренду,
+	// адрес хука для продления не применяется
+	if ip := request(map[uint8][]byte{OptionMessageType: {DHCPRequest}, OptionRequestedIP: address}); ip != "192.168.1.105" {
+		t.Errorf("Expected renewal of 192.168.1.105, got %s", ip)
+	}
+	if want := []bool{false, false, true}; !reflect.DeepEqual(renewals, want) {
+		t.Errorf("Expected hook renewal flags %v, got %v", want, renewals)
+	}
+	if len(server.allocatedIP) != 1 || len(server.allocatedMAC) != 1 {
+		t.Errorf("Expected a single lease, got %d addresses", len(server.allocatedIP))
+	}
+
+	// DHCPREQUEST в состоянии SELECTING завершает выдачу и продлением
+	// не считается
+	for _, want := range []LeaseEventType{LeaseAllocated, LeaseRenewed} {
+		if event := receiveEvent(t, events); event.Type != want || event.Lease.IP != "192.168.1.105" {
+			t.Errorf("Expected %s 192.168.1.105, got %s %s", want, event.Type, event.Lease.IP)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected lease event %s %s", event.Type, event.Lease.IP)
+	default:
+	}
+	if c := server.Stats().Counters; c.Allocations != 1 || c.Renewals != 1 {
+		t.Errorf("Expected 1 allocation and 1 renewal, got %+v", c)
+	}
+}
+
+func TestAllocationHookModifyRejected(t *testing.T) {
+	var hookIP string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip": "` + hookIP + `"}`))
+	}))
+	defer ts.Close()
+
+	// Адрес вне подсети клиента, адрес сети и широковещательный адрес
+	for _, ip := range []string{"10.0.0.5", "192.168.1.0", "192.168.1.255"} {
+		hookIP = ip
+		server := newHookTestServer(t, ts.URL, nil)
+		if reply := server.processRequest(hookTestRequest()); reply != nil {
+			t.Errorf("Expected nil reply when hook requests %s", ip)
+		}
+		if len(server.allocatedIP) != 0 {
+			t.Errorf("Expected no allocation when hook requests %s", ip)
+		}
+	}
+
+	// Статическое резервирование не переносится на другой адрес
+	hookIP = "192.168.1.105"
+	server := newHookTestServer(t, ts.URL, nil)
+	server.config.Subnets[0].Hosts = []config.Host{
+		{Name: "client", Hardware: "00:00:00:00:00:01", FixedIP: "192.168.1.10"},
+	}
+	server.runtime, _ = config.Compile(server.config)
+	server.initStaticAllocations()
+
+	if reply := server.processRequest(hookTestRequest()); reply != nil {
+		t.Error("Expected nil reply when hook moves a static reservation")
+	}
+	allocated := server.allocatedMAC["00:00:00:00:00:01"]
+	if allocated == nil || intToIP(allocated.IP).String() != "192.168.1.10" || allocated.Active {
+		t.Errorf("Expected static reservation 192.168.1.10 to stay inactive, got %+v", allocated)
+	}
+	if _, exists := server.allocatedIP[ipToInt(net.ParseIP(hookIP))]; exists {
+		t.Errorf("Expected %s to stay free", hookIP)
+	}
+}
+
+func TestAllocationHookFailPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// fail-open: ответ отправляется, несмотря на ошибку хука
+	server := newHookTestServer(t, ts.URL, map[string]string{"allocation-hook-policy": "fail-open"})
+	if reply := server.processRequest(hookTestRequest()); reply == nil {
+		t.Error("Expected reply with fail-open policy")
+	}
+
+	// fail-closed: ответ не отправляется
+	server = newHookTestServer(t, ts.URL, map[string]string{"allocation-hook-policy": "fail-closed"})
+	if reply := server.processRequest(hookTestRequest()); reply != nil {
+		t.Error("Expected nil reply with fail-closed policy")
+	}
+	if len(server.allocatedIP) != 0 {
+		t.Error("Expected no allocation with fail-closed policy")
+	}
+}
+
+func TestAllocationHookCircuitBreaker(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	server := newHookTestServer(t, ts.URL, map[string]string{
+		"allocation-hook-failure-threshold": "2",
+		"allocation-hook-cooldown":          "60",
+	})
+	clock := newFakeClock(time.Now())
+	server.clock = clock
+	var output bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &output
+	server.logger = logger
+
+	for i := 0; i < 5; i++ {
+		server.processRequest(hookTestRequest())
+	}
+
+	// После двух ошибок цепь размыкается и хук больше не вызывается
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected 2 hook calls before circuit opens, got %d", n)
+	}
+	if !strings.Contains(output.String(), "Allocation hook circuit opened for 1m0s") {
+		t.Errorf("Expected circuit opening to be logged to the server logger, got %q", output.String())
+	}
+
+	// Время размыкания отсчитывается по часам сервера
+	clock.Advance(61 * time.Second)
+	server.processRequest(hookTestRequest())
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("Expected hook to be called after cooldown, got %d calls", n)
+	}
+}
+
+func TestNewAllocationHookInvalidOptions(t *testing.T) {
+	tests := []map[string]string{
+		{"allocation-hook-url": "ftp://example.com"},
+		{"allocation-hook-url": "http://example.com", "allocation-hook-timeout": "abc"},
+		{"allocation-hook-url": "http://example.com", "allocation-hook-policy": "maybe"},
+		{"allocation-hook-url": "http://example.com", "allocation-hook-failure-threshold": "0"},
+		{"allocation-hook-url": "http://example.com", "allocation-hook-cooldown": "-1"},
+	}
+
+	for _, options := range tests {
+		if _, err := NewAllocationHook(options); err == nil {
+			t.Errorf("Expected error for options %v", options)
+		}
+	}
+
+	// Без URL хук не создается
+	hook, err := NewAllocationHook(map[string]string{})
+	if err != nil || hook != nil {
+		t.Errorf("Expected nil hook and nil error without URL, got %v, %v", hook, err)
+	}
+}
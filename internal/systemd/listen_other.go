@@ -0,0 +1,11 @@
+//go:build !unix
+
+package systemd
+
+import "net"
+
+// Listeners на этой платформе всегда возвращает nil: активация через
+// сокет поддерживается только в Unix системах
+func Listeners() ([]*net.UDPConn, error) {
+	return nil, nil
+}
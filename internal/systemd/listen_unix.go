@@ -0,0 +1,88 @@
+//go:build unix
+
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFDsStart номер первого дескриптора, переданного systemd
+const listenFDsStart = 3
+
+// Listeners возвращает UDP сокеты, переданные systemd при активации через
+// сокет (LISTEN_PID, LISTEN_FDS). Если сокеты не переданы, возвращает nil.
+// Переменные окружения удаляются, чтобы их не унаследовали дочерние процессы.
+func Listeners() ([]*net.UDPConn, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	return listeners(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), listenFDsStart)
+}
+
+// listeners разбирает LISTEN_PID и LISTEN_FDS и открывает count
+// дескрипторов, начиная с first
+func listeners(pid, fds string, first int) ([]*net.UDPConn, error) {
+	if pid == "" || fds == "" {
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(pid); err != nil || n != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS: %s", fds)
+	}
+
+	conns := make([]*net.UDPConn, 0, count)
+	closeAll := func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	for fd := first; fd < first+count; fd++ {
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		conn, err := net.FilePacketConn(file)
+		file.Close()
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("socket activation: descriptor %d: %v", fd, err)
+		}
+		udp, ok := conn.(*net.UDPConn)
+		if !ok {
+			conn.Close()
+			closeAll()
+			return nil, fmt.Errorf("socket activation: descriptor %d is not a UDP socket", fd)
+		}
+		// Go включает SO_BROADCAST только на собственных сокетах, а ответы
+		// клиентам без адреса отправляются широковещательно
+		if err := enableBroadcast(udp); err != nil {
+			udp.Close()
+			closeAll()
+			return nil, fmt.Errorf("socket activation: descriptor %d: %v", fd, err)
+		}
+		conns = append(conns, udp)
+	}
+	return conns, nil
+}
+
+// enableBroadcast разрешает отправку широковещательных датаграмм
+func enableBroadcast(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// dupFD возвращает дубликат дескриптора сокета, которым завладеет listeners
+func dupFD(t *testing.T, conn syscall.Conn) int {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := -1
+	var dupErr error
+	if err := raw.Control(func(s uintptr) { fd, dupErr = syscall.Dup(int(s)) }); err != nil {
+		t.Fatal(err)
+	}
+	if dupErr != nil {
+		t.Fatal(dupErr)
+	}
+	return fd
+}
+
+func TestListeners(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	if conns, err := listeners("", "", listenFDsStart); conns != nil || err != nil {
+		t.Errorf("Expected no sockets without LISTEN_FDS, got %v (%v)", conns, err)
+	}
+	if conns, err := listeners(strconv.Itoa(os.Getpid()+1), "1", listenFDsStart); conns != nil || err != nil {
+		t.Errorf("Expected sockets of another process to be ignored, got %v (%v)", conns, err)
+	}
+	if _, err := listeners(pid, "many", listenFDsStart); err == nil {
+		t.Error("Expected error for invalid LISTEN_FDS")
+	}
+
+	// Дубликат дескриптора UDP сокета передается так же, как от systemd
+	udp, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	conns, err := listeners(pid, "1", dupFD(t, udp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conns) != 1 || conns[0].LocalAddr().String() != udp.LocalAddr().String() {
+		t.Fatalf("Expected inherited socket on %v, got %v", udp.LocalAddr(), conns)
+	}
+	conns[0].Close()
+
+	// Потоковый сокет не подходит для BOOTP
+	tcp, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	if _, err := listeners(pid, "1", dupFD(t, tcp.(*net.TCPListener))); err == nil {
+		t.Error("Expected error for TCP socket")
+	}
+}
@@ -0,0 +1,35 @@
+package systemd
+
+import (
+	"net"
+	"os"
+)
+
+// Состояния, передаваемые systemd через sd_notify
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+)
+
+// Notify сообщает systemd о состоянии сервиса через сокет NOTIFY_SOCKET.
+// Если сервис запущен не systemd (переменная не задана), ничего не делает.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Сокет в абстрактном пространстве имен задается с префиксом @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
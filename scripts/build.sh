@@ -12,10 +12,10 @@ PROJECT_DIR="$(dirname "$SCRIPT_DIR")"
 cd "$PROJECT_DIR"
 
 # Build the binary
-go build -o bootpd cmd/bootpd/main.go
+go build -o go-bootp ./cmd/go-bootp
 
 if [ $? -eq 0 ]; then
-    echo "Build successful! Binary created: bootpd"
+    echo "Build successful! Binary created: go-bootp"
 else
     echo "Build failed!"
     exit 1
@@ -12,11 +12,11 @@ PROJECT_DIR="$(dirname "$SCRIPT_DIR")"
 cd "$PROJECT_DIR"
 
 # Check if binary exists
-if [ ! -f "bootpd" ]; then
-    echo "bootpd binary not found. Building..."
-    go build -o bootpd cmd/bootpd/main.go
+if [ ! -f "go-bootp" ]; then
+    echo "go-bootp binary not found. Building..."
+    go build -o go-bootp ./cmd/go-bootp
 fi
 
 # Run with sudo to bind to privileged port 67
 echo "Running GO-BOOTP server on Gentoo (requires root privileges)..."
-sudo ./bootpd -config configs/dhcpd.conf
\ No newline at end of file
+sudo ./go-bootp serve --config configs/dhcpd.conf
\ No newline at end of file